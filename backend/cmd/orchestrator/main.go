@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
@@ -9,18 +11,43 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/ai-support-assistant/backend/internal/abuse"
+	"github.com/ai-support-assistant/backend/internal/activity"
+	"github.com/ai-support-assistant/backend/internal/apidocs"
 	"github.com/ai-support-assistant/backend/internal/cache"
+	"github.com/ai-support-assistant/backend/internal/chaos"
 	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/crypto"
 	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/deadletter"
+	"github.com/ai-support-assistant/backend/internal/errortracking"
+	"github.com/ai-support-assistant/backend/internal/fingerprint"
 	"github.com/ai-support-assistant/backend/internal/handlers"
+	"github.com/ai-support-assistant/backend/internal/lifecycle"
+	"github.com/ai-support-assistant/backend/internal/logging"
 	"github.com/ai-support-assistant/backend/internal/middleware"
+	"github.com/ai-support-assistant/backend/internal/netguard"
+	"github.com/ai-support-assistant/backend/internal/notify"
+	"github.com/ai-support-assistant/backend/internal/ragclient"
 	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/ai-support-assistant/backend/internal/webhook"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 )
 
 func main() {
+	migrateOnly := flag.Bool("migrate", false, "run database migrations and exit")
+	encryptData := flag.Bool("encrypt-data", false, "encrypt existing conversation content and backfill query hashes, then exit")
+	checkConfig := flag.Bool("check-config", false, "validate configuration, print a report and exit non-zero on errors")
+	offline := flag.Bool("offline", false, "with -check-config, don't probe the RAG service")
+	flag.Parse()
+
+	if *checkConfig {
+		os.Exit(runConfigCheck(*offline))
+	}
+
 	// Setup logger
 	setupLogger()
 
@@ -32,12 +59,76 @@ func main() {
 		logrus.WithError(err).Fatal("Failed to load configuration")
 	}
 
+	// Apply logging config; the scrub hook keeps credentials and PII out of logs
+	scrubHook, err := logging.NewScrubHook(cfg.LogScrubPatterns, cfg.LogQueryMaxLength)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to configure log scrubbing")
+	}
+	if err := logging.Configure(cfg.LogLevel, cfg.LogFormat, scrubHook); err != nil {
+		logrus.WithError(err).Fatal("Failed to configure logging")
+	}
+
+	// Encrypt conversation content at rest when a key is configured
+	if cfg.EncryptionKey != "" {
+		previousKeys, err := crypto.ParseKeyList(cfg.EncryptionPreviousKeys)
+		if err != nil {
+			logrus.WithError(err).Fatal("Invalid ENCRYPTION_PREVIOUS_KEYS")
+		}
+		cipher, err := crypto.New(cfg.EncryptionKeyID, cfg.EncryptionKey, previousKeys, cfg.EncryptionHashKey)
+		if err != nil {
+			logrus.WithError(err).Fatal("Invalid encryption key configuration")
+		}
+		crypto.Enable(cipher)
+		logrus.WithField("key_id", cfg.EncryptionKeyID).Info("Encryption at rest enabled")
+	}
+
 	// Initialize database
-	if _, err := db.Initialize(cfg.DatabaseURL, cfg.IsDevelopment()); err != nil {
+	connectDelay := time.Duration(cfg.DBConnectDelayS) * time.Second
+	pool := db.PoolConfig{
+		MaxIdleConns:       cfg.DBMaxIdleConns,
+		MaxOpenConns:       cfg.DBMaxOpenConns,
+		ConnMaxLifetime:    time.Duration(cfg.DBConnMaxLifetimeS) * time.Second,
+		ConnMaxIdleTime:    time.Duration(cfg.DBConnMaxIdleTimeS) * time.Second,
+		SlowQueryThreshold: time.Duration(cfg.DBSlowQueryMs) * time.Millisecond,
+	}
+	if _, err := db.Initialize(cfg.DatabaseURL, cfg.IsDevelopment(), cfg.DBConnectAttempts, connectDelay, pool); err != nil {
 		logrus.WithError(err).Fatal("Failed to initialize database")
 	}
 	defer db.Close()
 
+	// Migrations run either as a one-off job or, for small deployments, on startup
+	switch {
+	case *migrateOnly:
+		logrus.Info("Migration mode: running database migrations")
+		if err := db.Migrate(); err != nil {
+			logrus.WithError(err).Fatal("Failed to run database migrations")
+		}
+		return
+	case cfg.RunMigrations:
+		logrus.Info("RUN_MIGRATIONS is set: running database migrations on startup")
+		if err := db.Migrate(); err != nil {
+			logrus.WithError(err).Fatal("Failed to run database migrations")
+		}
+	default:
+		logrus.Info("Skipping migrations: verifying database schema")
+		if err := db.VerifySchema(); err != nil {
+			logrus.WithError(err).Fatal("Database schema is not up to date")
+		}
+	}
+
+	if *encryptData {
+		logrus.Info("Encryption mode: encrypting existing rows")
+		queries, feedback, err := services.EncryptExistingRows(context.Background(), cfg.EncryptionBatchSize)
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to encrypt existing rows")
+		}
+		logrus.WithFields(logrus.Fields{
+			"chat_queries": queries,
+			"feedback":     feedback,
+		}).Info("Existing rows encrypted")
+		return
+	}
+
 	// Initialize Redis (optional - skip if not configured)
 	if cfg.RedisHost != "" && cfg.RedisHost != "localhost" {
 		if _, err := cache.Initialize(cfg.RedisHost, cfg.RedisPort, cfg.RedisPassword); err != nil {
@@ -48,19 +139,189 @@ func main() {
 	} else {
 		logrus.Info("Redis not configured, running without cache")
 	}
+	if len(cfg.SigningKeys) > 0 && cache.Client == nil {
+		logrus.Warn("SECURITY WARNING: signed requests can be replayed within SIGNATURE_MAX_SKEW without Redis")
+	}
+
+	// Chaos mode injects faults into dependencies for resilience testing; it
+	// is refused in production whatever the config says
+	var chaosInjector *chaos.Injector
+	if cfg.ChaosMode {
+		chaosInjector, err = chaos.NewInjector(cfg.Environment)
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to enable chaos mode")
+		}
+		if cache.Client != nil {
+			cache.Client.AddHook(chaosInjector.RedisHook())
+		}
+		logrus.Warn("CHAOS MODE ENABLED: admins can inject faults into the RAG service, Redis and database checks")
+	}
+
+	// Background work registers with the lifecycle manager so shutdown can drain it
+	lifecycleManager := lifecycle.NewManager()
+
+	// Initialize notifications (disabled when no webhook is configured)
+	slackNotifier := notify.NewSlackNotifier(cfg.SlackWebhookURL, time.Duration(cfg.SlackNotifyIntervalS)*time.Second, lifecycleManager)
+	if !slackNotifier.Enabled() {
+		logrus.Info("Slack webhook not configured, notifications disabled")
+	}
+
+	// Initialize outbound webhook dispatcher; webhooks may only be delivered to
+	// public addresses, and the allowed CIDRs were validated at config load
+	outboundGuard, _ := netguard.New(cfg.OutboundAllowedCIDRs)
+	webhookDispatcher := webhook.NewDispatcher(cfg.WebhookWorkers, cfg.WebhookQueueSize, cfg.WebhookMaxAttempts, outboundGuard)
+	webhookDispatcher.Start(lifecycleManager)
+
+	// Initialize the live activity feed, relayed between instances through Redis
+	activityBus := activity.NewBus()
+	activityBus.Start(lifecycleManager.Context())
+
+	// Initialize abuse detection
+	abuseDetector := abuse.NewDetector(abuse.Thresholds{
+		Window:             time.Duration(cfg.AbuseWindowS) * time.Second,
+		SessionMaxQueries:  cfg.AbuseSessionMaxQueries,
+		IPMaxQueries:       cfg.AbuseIPMaxQueries,
+		MinQueriesForRatio: cfg.AbuseDistinctMinQueries,
+		MaxDistinctRatio:   cfg.AbuseDistinctRatio,
+		BanDuration:        time.Duration(cfg.AbuseBanDurationS) * time.Second,
+	})
+
+	// Load runtime settings; they override env config and reload without a restart
+	settingsService := services.NewSettingsService(cfg)
+	settingsService.Start(lifecycleManager.Context())
+
+	// Feature flags switch expensive features off during incidents without a redeploy
+	featureFlagService := services.NewFeatureFlagService(cfg)
+	featureFlagService.Start(lifecycleManager.Context())
+
+	// Connect to the RAG service over the configured transport
+	ragTransport, err := services.NewRAGTransport(cfg)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to create RAG transport")
+	}
+	defer ragTransport.Close()
+	ragTransport = services.NewChaosRAGTransport(ragTransport, chaosInjector)
+
+	// Queries fail over to a secondary RAG deployment when one is configured
+	ragFallback := services.NewChaosRAGTransport(services.NewRAGFallbackTransport(cfg), chaosInjector)
+	if ragFallback != nil {
+		defer ragFallback.Close()
+		logrus.WithField("url", cfg.RAGServiceFallbackURL).Info("Fallback RAG endpoint configured for queries")
+	}
+
+	// Bound concurrent generations, shedding requests that would queue too long
+	ragLimiter := services.NewRAGLimiter(cfg)
+
+	// Probe each RAG endpoint so queries fail over, or fail fast while all are down
+	healthService := services.NewHealthService(cfg, ragTransport, ragFallback, ragLimiter, chaosInjector)
+	healthService.Start(lifecycleManager.Context())
+
+	// Export connection pool saturation
+	db.StartStatsCollector(lifecycleManager.Context(), time.Duration(cfg.DBStatsIntervalS)*time.Second)
 
 	// Initialize services
-	queryService := services.NewQueryService(cfg)
-	feedbackService := services.NewFeedbackService()
-	analyticsService := services.NewAnalyticsService()
-	documentService := services.NewDocumentService(cfg)
+	cannedAnswerService := services.NewCannedAnswerService()
+	promptService := services.NewPromptService()
+	experimentService := services.NewExperimentService(cfg, promptService)
+	modelRoutingService := services.NewModelRoutingService(cfg)
+	quotaService := services.NewQuotaService(cfg)
+	didYouMeanService := services.NewDidYouMeanService(cfg, featureFlagService)
+	didYouMeanService.Start(lifecycleManager.Context())
+	directAnswerService := services.NewDirectAnswerService(cfg, featureFlagService)
+	widgetService := services.NewWidgetService()
+	personaService := services.NewPersonaService(widgetService)
+	faqService := services.NewFAQService(cannedAnswerService)
+	queryService := services.NewQueryService(cfg, settingsService, webhookDispatcher, activityBus, cannedAnswerService, promptService, experimentService, modelRoutingService, healthService, ragTransport, ragFallback, ragLimiter, quotaService, didYouMeanService, personaService, directAnswerService, lifecycleManager)
+	queryJobService := services.NewQueryJobService(cfg, queryService)
+	queryJobService.Start(lifecycleManager)
+	ragClient := ragclient.NewClient(cfg.RAGServiceURL)
+	feedbackService := services.NewFeedbackService(cfg, slackNotifier, webhookDispatcher, activityBus, ragClient, lifecycleManager)
+	analyticsService := services.NewAnalyticsService(cfg, ragClient)
+	queryDebugService := services.NewQueryDebugService(ragClient, scrubHook)
+	segmentService := services.NewSegmentService(cfg)
+	emailSender := notify.NewEmailSender(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom)
+	services.NewReportScheduler(cfg, analyticsService, emailSender).Start(lifecycleManager.Context())
+	documentService := services.NewDocumentService(cfg, slackNotifier, webhookDispatcher, ragTransport, healthService, lifecycleManager)
+	documentService.StartQueue(lifecycleManager.Context())
+	crawlService := services.NewCrawlService(cfg, documentService, lifecycleManager)
+	reingestService := services.NewReingestService(cfg, documentService, lifecycleManager)
+	annotationService := services.NewAnnotationService(documentService, cannedAnswerService)
+	webhookService := services.NewWebhookService(cfg)
+	exportService := services.NewExportService(cfg)
+	collectionService := services.NewCollectionService()
+	dashboardService := services.NewDashboardService(cfg, analyticsService, feedbackService, documentService, settingsService, healthService)
+	auditService := services.NewAuditService()
+	idempotencyService := services.NewIdempotencyService(cfg)
+	idempotencyService.Start(lifecycleManager.Context())
+	sessionService := services.NewSessionService(cfg)
+	sessionService.Start(lifecycleManager.Context())
+	searchService := services.NewSearchService(cfg)
+	configBundleService := services.NewConfigBundleService(cannedAnswerService, promptService, widgetService, settingsService)
+	deadLetterService := services.NewDeadLetterService(map[string]deadletter.Replayer{
+		deadletter.OperationWebhookDelivery:   webhookDispatcher.Replay,
+		deadletter.OperationSlackNotification: slackNotifier.Replay,
+		deadletter.OperationRetrievalReport:   feedbackService.ReplayRetrievalReport,
+		deadletter.OperationObjectIngest:      documentService.ReplayObjectIngest,
+		deadletter.OperationTextIngest:        documentService.ReplayTextIngest,
+	})
+	deadLetterService.Start(lifecycleManager.Context())
+	purgeService := services.NewPurgeService(cfg, lifecycleManager)
+	purgeService.Start(lifecycleManager.Context())
+	knowledgeGapService := services.NewKnowledgeGapService(cfg, ragClient, lifecycleManager)
+	knowledgeGapService.Start(lifecycleManager.Context())
+	billingService := services.NewBillingService(cfg)
+	billingService.Start(lifecycleManager.Context())
+
+	// Shadow tests compare a candidate RAG deployment with the current one
+	shadowTestService := services.NewShadowTestService(cfg, ragTransport, ragLimiter, lifecycleManager)
+	if cfg.CacheWarmup {
+		if _, err := queryService.StartWarmup(services.WarmupTriggerStartup); err != nil {
+			logrus.WithError(err).Warn("Failed to start cache warm-up")
+		}
+	}
+	runtimeService := services.NewRuntimeService(healthService, ragLimiter, queryService, queryJobService, webhookDispatcher, lifecycleManager)
 
 	// Initialize handlers
-	queryHandler := handlers.NewQueryHandler(queryService)
-	feedbackHandler := handlers.NewFeedbackHandler(feedbackService)
-	analyticsHandler := handlers.NewAnalyticsHandler(analyticsService)
-	documentHandler := handlers.NewDocumentHandler(documentService)
-	healthHandler := handlers.NewHealthHandler(cfg)
+	queryHandler := handlers.NewQueryHandler(queryService, queryJobService, quotaService)
+	feedbackHandler := handlers.NewFeedbackHandler(feedbackService, segmentService)
+	analyticsHandler := handlers.NewAnalyticsHandler(analyticsService, segmentService)
+	documentHandler := handlers.NewDocumentHandler(documentService, quotaService)
+	healthHandler := handlers.NewHealthHandler(healthService, lifecycleManager)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+	cannedAnswerHandler := handlers.NewCannedAnswerHandler(cannedAnswerService)
+	exportHandler := handlers.NewExportHandler(exportService)
+	settingsHandler := handlers.NewSettingsHandler(settingsService)
+	featureFlagHandler := handlers.NewFeatureFlagHandler(featureFlagService)
+	banHandler := handlers.NewBanHandler(abuseDetector)
+	widgetHandler := handlers.NewWidgetHandler(widgetService)
+	collectionHandler := handlers.NewCollectionHandler(collectionService)
+	dashboardHandler := handlers.NewDashboardHandler(dashboardService)
+	promptTemplateHandler := handlers.NewPromptTemplateHandler(promptService)
+	experimentHandler := handlers.NewExperimentHandler(experimentService)
+	crawlHandler := handlers.NewCrawlHandler(crawlService)
+	reingestHandler := handlers.NewReingestHandler(reingestService)
+	auditHandler := handlers.NewAuditHandler(auditService)
+	sessionHandler := handlers.NewSessionHandler(sessionService)
+	runtimeHandler := handlers.NewRuntimeHandler(runtimeService)
+	searchHandler := handlers.NewSearchHandler(searchService)
+	configBundleHandler := handlers.NewConfigBundleHandler(configBundleService)
+	deadLetterHandler := handlers.NewDeadLetterHandler(deadLetterService)
+	activityHandler := handlers.NewActivityHandler(sessionService, activityBus)
+	annotationHandler := handlers.NewAnnotationHandler(annotationService)
+	routingRuleHandler := handlers.NewRoutingRuleHandler(modelRoutingService)
+	purgeHandler := handlers.NewPurgeHandler(purgeService)
+	shadowTestHandler := handlers.NewShadowTestHandler(shadowTestService)
+	quotaHandler := handlers.NewQuotaHandler(quotaService)
+	segmentHandler := handlers.NewSegmentHandler(segmentService)
+	personaHandler := handlers.NewPersonaHandler(personaService, queryService)
+	knowledgeGapHandler := handlers.NewKnowledgeGapHandler(knowledgeGapService)
+	billingHandler := handlers.NewBillingHandler(billingService)
+	faqHandler := handlers.NewFAQHandler(faqService)
+	queryDebugHandler := handlers.NewQueryDebugHandler(queryDebugService)
+	var chaosHandler *handlers.ChaosHandler
+	if chaosInjector != nil {
+		chaosHandler = handlers.NewChaosHandler(chaosInjector)
+	}
 
 	// Setup Gin router
 	if cfg.IsProduction() {
@@ -68,23 +329,87 @@ func main() {
 	}
 
 	router := gin.New()
+	apiDocsHandler := handlers.NewAPIDocsHandler(router)
+
+	// Only honor X-Forwarded-For / X-Real-IP from trusted proxies so that
+	// ClientIP (used for logging, rate limiting and allowlists) can't be spoofed
+	if err := middleware.TrustProxies(router, cfg.TrustedProxies); err != nil {
+		logrus.WithError(err).Fatal("Invalid trusted proxy configuration")
+	}
+	if len(cfg.TrustedProxies) == 0 {
+		logrus.Info("No trusted proxies configured, using direct peer address as client IP")
+	} else {
+		logrus.WithField("trusted_proxies", cfg.TrustedProxies).Info("Trusted proxies configured")
+	}
+
+	// Report panics, and optionally server errors, to the error tracker
+	errorReporter, err := errortracking.New(cfg.SentryDSN, errortracking.Options{
+		Environment: cfg.SentryEnvironment,
+		Release:     cfg.SentryRelease,
+		SampleRate:  cfg.SentrySampleRate,
+		QueueSize:   cfg.SentryQueueSize,
+		Mode:        cfg.ErrorReportingMode,
+	})
+	if err != nil {
+		logrus.WithError(err).Fatal("Invalid error reporting configuration")
+	}
+	errorReporter.Start(lifecycleManager)
 
 	// Apply middleware
-	router.Use(middleware.Recovery())
-	router.Use(middleware.Logger())
-	router.Use(middleware.CORS())
+	router.Use(middleware.Recovery(errorReporter))
+	router.Use(middleware.RequestID())
+
+	// Count visitors by a rotating pseudonymous ID rather than client-reported sessions
+	if cfg.VisitorFingerprintKey != "" {
+		fingerprinter, err := fingerprint.New(cfg.VisitorFingerprintKey, time.Duration(cfg.VisitorSaltRotationS)*time.Second)
+		if err != nil {
+			logrus.WithError(err).Fatal("Invalid visitor fingerprint configuration")
+		}
+		router.Use(middleware.VisitorFingerprint(fingerprinter))
+	} else {
+		logrus.Info("VISITOR_FINGERPRINT_KEY not set, unique visitor counting disabled")
+	}
+
+	router.Use(middleware.Logger(cfg.LogAccessSampleRate))
 	router.Use(middleware.Metrics())
-	router.Use(middleware.RateLimiter(cfg.RateLimitRequests, cfg.RateLimitWindow))
+	router.Use(middleware.CORS(cfg.CORSAllowedOrigins, widgetService.IsOriginAllowed, time.Duration(cfg.CORSMaxAgeS)*time.Second))
+	router.Use(middleware.MaxBodySize(cfg.MaxRequestBodyBytes))
+	router.Use(middleware.Compress(cfg.CompressionLevel, cfg.CompressionMinBytes))
+
+	// Protect /metrics; leaving it open in production is allowed but loudly flagged
+	metricsCIDRs, err := config.ParseCIDRs(cfg.MetricsAllowedCIDRs)
+	if err != nil {
+		logrus.WithError(err).Fatal("Invalid metrics allowlist")
+	}
+	if cfg.IsProduction() && cfg.MetricsAuthToken == "" && len(metricsCIDRs) == 0 {
+		logrus.Warn("SECURITY WARNING: /metrics is publicly accessible; set METRICS_AUTH_TOKEN or METRICS_ALLOWED_CIDRS")
+	}
+	metricsAuth := middleware.MetricsAuth(cfg.MetricsAuthToken, metricsCIDRs)
+
+	httpBuckets, err := config.ParseBuckets(cfg.MetricsHTTPBuckets)
+	if err != nil {
+		logrus.WithError(err).Fatal("Invalid HTTP duration buckets")
+	}
+	ragBuckets, err := config.ParseBuckets(cfg.MetricsRAGBuckets)
+	if err != nil {
+		logrus.WithError(err).Fatal("Invalid RAG duration buckets")
+	}
+	middleware.ConfigureMetrics(httpBuckets, ragBuckets, time.Duration(cfg.SlowRequestThresholdMs)*time.Millisecond)
 
 	// Setup routes
-	setupRoutes(router, queryHandler, feedbackHandler, analyticsHandler, documentHandler, healthHandler)
+	setupRoutes(router, cfg, settingsService, featureFlagService, abuseDetector, idempotencyService, metricsAuth, queryHandler, feedbackHandler, analyticsHandler, documentHandler, healthHandler, webhookHandler, cannedAnswerHandler, exportHandler, settingsHandler, banHandler, widgetHandler, collectionHandler, dashboardHandler, promptTemplateHandler, experimentHandler, crawlHandler, auditHandler, sessionHandler, apiDocsHandler, runtimeHandler, searchHandler, configBundleHandler, deadLetterHandler, featureFlagHandler, activityHandler, annotationHandler, routingRuleHandler, purgeHandler, shadowTestHandler, quotaHandler, segmentHandler, personaHandler, knowledgeGapHandler, billingHandler, chaosHandler, faqHandler, queryDebugHandler, reingestHandler)
+
+	// The OpenAPI spec lists every route, but undocumented ones only generically
+	if undocumented := apidocs.Undocumented(router.Routes()); len(undocumented) > 0 {
+		logrus.WithField("routes", undocumented).Warn("Routes missing from the OpenAPI spec")
+	}
 
 	// Start server
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%s", cfg.Port),
 		Handler:      router,
 		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
+		WriteTimeout: config.ServerWriteTimeoutS * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
 
@@ -101,6 +426,25 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
+	shutdown(server, lifecycleManager, cfg, quit)
+	logrus.Info("Server exited")
+}
+
+// shutdown stops the server after the first signal on quit. Readiness fails
+// first and requests are still served until load balancers have stopped
+// routing here, then the server and background work are drained. A second
+// signal skips the wait.
+func shutdown(server *http.Server, lifecycleManager *lifecycle.Manager, cfg *config.Config, quit <-chan os.Signal) {
+	lifecycleManager.Drain()
+	middleware.SetDraining(true)
+	delay := time.Duration(cfg.ShutdownDelayS) * time.Second
+	logrus.WithField("delay", delay).Info("Draining, readiness now failing")
+	select {
+	case <-time.After(delay):
+	case <-quit:
+		logrus.Warn("Second signal received, shutting down without waiting")
+	}
+
 	logrus.Info("Shutting down server...")
 
 	// Graceful shutdown
@@ -111,44 +455,364 @@ func main() {
 		logrus.WithError(err).Error("Server forced to shutdown")
 	}
 
-	logrus.Info("Server exited")
+	// Drain background work such as document ingestion and webhook deliveries
+	if abandoned := lifecycleManager.Shutdown(time.Duration(cfg.ShutdownDrainTimeoutS) * time.Second); abandoned > 0 {
+		logrus.WithField("abandoned", abandoned).Warn("Background tasks abandoned at shutdown")
+	}
 }
 
 // setupRoutes configures all API routes
 func setupRoutes(
 	router *gin.Engine,
+	cfg *config.Config,
+	settingsService *services.SettingsService,
+	featureFlagService *services.FeatureFlagService,
+	abuseDetector *abuse.Detector,
+	idempotencyStore middleware.IdempotencyStore,
+	metricsAuth gin.HandlerFunc,
 	queryHandler *handlers.QueryHandler,
 	feedbackHandler *handlers.FeedbackHandler,
 	analyticsHandler *handlers.AnalyticsHandler,
 	documentHandler *handlers.DocumentHandler,
 	healthHandler *handlers.HealthHandler,
+	webhookHandler *handlers.WebhookHandler,
+	cannedAnswerHandler *handlers.CannedAnswerHandler,
+	exportHandler *handlers.ExportHandler,
+	settingsHandler *handlers.SettingsHandler,
+	banHandler *handlers.BanHandler,
+	widgetHandler *handlers.WidgetHandler,
+	collectionHandler *handlers.CollectionHandler,
+	dashboardHandler *handlers.DashboardHandler,
+	promptTemplateHandler *handlers.PromptTemplateHandler,
+	experimentHandler *handlers.ExperimentHandler,
+	crawlHandler *handlers.CrawlHandler,
+	auditHandler *handlers.AuditHandler,
+	sessionHandler *handlers.SessionHandler,
+	apiDocsHandler *handlers.APIDocsHandler,
+	runtimeHandler *handlers.RuntimeHandler,
+	searchHandler *handlers.SearchHandler,
+	configBundleHandler *handlers.ConfigBundleHandler,
+	deadLetterHandler *handlers.DeadLetterHandler,
+	featureFlagHandler *handlers.FeatureFlagHandler,
+	activityHandler *handlers.ActivityHandler,
+	annotationHandler *handlers.AnnotationHandler,
+	routingRuleHandler *handlers.RoutingRuleHandler,
+	purgeHandler *handlers.PurgeHandler,
+	shadowTestHandler *handlers.ShadowTestHandler,
+	quotaHandler *handlers.QuotaHandler,
+	segmentHandler *handlers.SegmentHandler,
+	personaHandler *handlers.PersonaHandler,
+	knowledgeGapHandler *handlers.KnowledgeGapHandler,
+	billingHandler *handlers.BillingHandler,
+	chaosHandler *handlers.ChaosHandler,
+	faqHandler *handlers.FAQHandler,
+	queryDebugHandler *handlers.QueryDebugHandler,
+	reingestHandler *handlers.ReingestHandler,
 ) {
+	// CORS preflights, once the CORS middleware has allowed them
+	router.OPTIONS("/*path", middleware.Preflight)
+
 	// Health check
 	router.GET("/api/health", healthHandler.HandleHealth)
+	router.GET("/api/health/ready", healthHandler.HandleReady)
+
+	// Prometheus metrics; exemplars are only exposed in the OpenMetrics format
+	metricsHandler := promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}))
+	router.GET("/metrics", middleware.NoCompression(), metricsAuth, gin.WrapH(metricsHandler))
+
+	// Rate limit policies; each has independent buckets
+	defaultLimit := middleware.RateLimiter(rateLimitPolicy("default", settingsService))
+	queryLimit := middleware.RateLimiter(rateLimitPolicy("query", settingsService))
+	uploadLimit := middleware.RateLimiter(rateLimitPolicy("upload", settingsService))
+	readLimit := middleware.RateLimiter(rateLimitPolicy("read", settingsService))
+
+	// Claims are limited per user rather than per IP, and must come after
+	// authentication
+	claimPolicy := rateLimitPolicy("claim", settingsService)
+	claimPolicy.KeyFunc = middleware.KeyByUser
+	claimLimit := middleware.RateLimiter(claimPolicy)
+
+	// Abuse detection runs before the rate limiter so banned clients don't use up budget
+	abuseGuard := func(c *gin.Context) { c.Next() }
+	if cfg.AbuseDetectionEnabled {
+		abuseGuard = middleware.AbuseGuard(abuseDetector)
+	}
+
+	// Retries carrying an Idempotency-Key replay the original response
+	idempotencyWait := time.Duration(cfg.IdempotencyWaitS) * time.Second
+	queryIdempotency := middleware.Idempotency(idempotencyStore, "query", idempotencyWait)
+	feedbackIdempotency := middleware.Idempotency(idempotencyStore, "feedback", idempotencyWait)
+
+	// Per-route deadlines; services derive upstream calls from the request context.
+	// Uploads and exports stream bodies and are left to the server timeouts.
+	queryTimeout := middleware.Timeout(time.Duration(cfg.RequestTimeoutQueryS) * time.Second)
+	readTimeout := middleware.Timeout(time.Duration(cfg.RequestTimeoutReadS) * time.Second)
+	defaultTimeout := middleware.Timeout(time.Duration(cfg.RequestTimeoutDefaultS) * time.Second)
+
+	// Polled reads answer 304 when the client's ETag is still current
+	analyticsETag := middleware.ETag(time.Duration(cfg.CacheMaxAgeAnalyticsS) * time.Second)
+	docsETag := middleware.ETag(time.Duration(cfg.CacheMaxAgeDocsS) * time.Second)
+	feedbackETag := middleware.ETag(time.Duration(cfg.CacheMaxAgeFeedbackS) * time.Second)
+	faqETag := middleware.ETag(time.Duration(cfg.CacheMaxAgeFAQS) * time.Second)
 
-	// Prometheus metrics
-	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	// Partner backends may sign requests instead of sending a JWT; keys were
+	// validated when the config loaded
+	signingKeys, _ := config.ParseSigningKeys(cfg.SigningKeys)
+	requestSignature := middleware.RequestSignature(signingKeys, time.Duration(cfg.SignatureMaxSkewS)*time.Second, false)
+
+	// API specification; the interactive UI is only served outside production
+	router.GET("/api/openapi.json", readLimit, apiDocsHandler.HandleGetSpec)
+	if !cfg.IsProduction() {
+		router.GET("/api/docs-ui", readLimit, apiDocsHandler.HandleDocsUI)
+	}
+
+	// Feature-gated groups answer 503 feature_disabled while their flag is off
+	requireFeature := func(name string) gin.HandlerFunc {
+		return middleware.RequireFeature(featureFlagService.Feature, name)
+	}
 
 	// API routes
 	api := router.Group("/api")
 	{
 		// Query endpoints
-		api.POST("/query", queryHandler.HandleQuery)
+		query := api.Group("/query", requireFeature(services.FeatureQuery))
+		query.POST("", queryTimeout, abuseGuard, requestSignature, middleware.AuthMiddleware(cfg.JWTSecret, cfg.AuthEnabled), queryIdempotency, queryLimit, queryHandler.HandleQuery)
+		query.GET("/jobs/:id", readTimeout, readLimit, queryHandler.HandleGetQueryJob)
+		query.POST("/:query_id/regenerate", queryTimeout, abuseGuard, middleware.AuthMiddleware(cfg.JWTSecret, cfg.AuthEnabled), queryLimit, queryHandler.HandleRegenerateQuery)
 
 		// Feedback endpoints
-		api.POST("/feedback", feedbackHandler.HandleSubmitFeedback)
-		api.GET("/feedback", feedbackHandler.HandleGetFeedback)
-		api.GET("/feedback/stats", feedbackHandler.HandleGetFeedbackStats)
+		api.POST("/feedback", defaultTimeout, feedbackIdempotency, defaultLimit, feedbackHandler.HandleSubmitFeedback)
+		api.GET("/feedback", readTimeout, readLimit, feedbackHandler.HandleGetFeedback)
+		api.GET("/feedback/stats", readTimeout, readLimit, feedbackETag, feedbackHandler.HandleGetFeedbackStats)
+		// Importing survey exports is for admins
+		api.POST("/feedback/import", uploadLimit, middleware.RequireRole(cfg.JWTSecret, middleware.RoleAdmin), middleware.AuditContext(), feedbackHandler.HandleImportFeedback)
 
 		// Analytics endpoints
-		api.GET("/analytics", analyticsHandler.HandleGetAnalytics)
-		api.GET("/analytics/top-queries", analyticsHandler.HandleGetTopQueries)
-		api.GET("/analytics/trends", analyticsHandler.HandleGetQueryTrends)
+		api.GET("/analytics", readTimeout, readLimit, analyticsETag, analyticsHandler.HandleGetAnalytics)
+		api.GET("/analytics/top-queries", readTimeout, readLimit, analyticsETag, analyticsHandler.HandleGetTopQueries)
+		api.GET("/analytics/trends", readTimeout, readLimit, analyticsETag, analyticsHandler.HandleGetQueryTrends)
+		api.GET("/analytics/latency", readTimeout, readLimit, analyticsETag, analyticsHandler.HandleGetLatencyStats)
+		api.GET("/analytics/regenerations", readTimeout, readLimit, analyticsETag, analyticsHandler.HandleGetRegenerationStats)
+		api.GET("/analytics/grounding", readTimeout, readLimit, analyticsETag, analyticsHandler.HandleGetGroundingStats)
+		api.GET("/analytics/languages", readTimeout, readLimit, analyticsETag, analyticsHandler.HandleGetLanguageBreakdown)
+		api.GET("/analytics/prompt-versions", readTimeout, readLimit, analyticsETag, analyticsHandler.HandleGetPromptVersionStats)
+		api.GET("/analytics/pipelines", readTimeout, readLimit, analyticsETag, analyticsHandler.HandleGetPipelineStats)
+		api.GET("/analytics/pages", readTimeout, readLimit, analyticsETag, analyticsHandler.HandleGetPageStats)
+		api.GET("/analytics/feedback-themes", queryTimeout, readLimit, analyticsETag, analyticsHandler.HandleGetFeedbackThemes)
+		api.GET("/analytics/outcomes", readTimeout, readLimit, analyticsETag, analyticsHandler.HandleGetOutcomeTrends)
+		api.GET("/analytics/heatmap", readTimeout, readLimit, analyticsETag, analyticsHandler.HandleGetQueryHeatmap)
+
+		// Document ingestion endpoints
+		ingest := api.Group("/docs", requireFeature(services.FeatureUploads))
+		ingest.POST("/upload", uploadLimit, middleware.AuthMiddleware(cfg.JWTSecret, cfg.AuthEnabled), middleware.AuditContext(), documentHandler.HandleUploadDocument)
+		// Crawling sites and pulling in objects is for admins and agents
+		ingest.POST("/ingest-url", uploadLimit, middleware.RequireRole(cfg.JWTSecret, middleware.RoleAdmin, middleware.RoleAgent), middleware.AuditContext(), crawlHandler.HandleIngestURL)
+		ingest.POST("/ingest-object", defaultTimeout, uploadLimit, middleware.RequireRole(cfg.JWTSecret, middleware.RoleAdmin, middleware.RoleAgent), middleware.AuditContext(), documentHandler.HandleIngestObject)
 
 		// Document endpoints
-		api.POST("/docs/upload", documentHandler.HandleUploadDocument)
-		api.GET("/docs", documentHandler.HandleGetDocuments)
-		api.GET("/docs/:id", documentHandler.HandleGetDocument)
+		api.GET("/docs/crawl-jobs/:id", readTimeout, readLimit, docsETag, crawlHandler.HandleGetCrawlJob)
+		api.GET("/docs", readTimeout, readLimit, docsETag, documentHandler.HandleGetDocuments)
+		api.GET("/docs/:id", readTimeout, readLimit, docsETag, documentHandler.HandleGetDocument)
+		api.GET("/docs/:id/versions", readTimeout, readLimit, docsETag, documentHandler.HandleGetDocumentVersions)
+		api.GET("/docs/:id/status", readTimeout, readLimit, docsETag, documentHandler.HandleGetDocumentStatus)
+		api.GET("/docs/:id/preview", readTimeout, readLimit, middleware.AuthMiddleware(cfg.JWTSecret, cfg.AuthEnabled), docsETag, documentHandler.HandleGetDocumentPreview)
+		// Editing a document is for admins and agents
+		api.PATCH("/docs/:id", defaultTimeout, defaultLimit, middleware.RequireRole(cfg.JWTSecret, middleware.RoleAdmin, middleware.RoleAgent), documentHandler.HandleUpdateDocument)
+
+		// Streaming endpoints; progress streams end on their own, so they take no
+		// request timeout, while a streamed answer is bounded like any query
+		streams := api.Group("", middleware.NoCompression(), requireFeature(services.FeatureStreaming))
+		streams.GET("/docs/:id/progress", readLimit, middleware.AuthMiddleware(cfg.JWTSecret, cfg.AuthEnabled), documentHandler.HandleStreamDocumentProgress)
+		streams.POST("/query/stream", requireFeature(services.FeatureQuery), queryTimeout, abuseGuard, requestSignature, middleware.AuthMiddleware(cfg.JWTSecret, cfg.AuthEnabled), queryLimit, queryHandler.HandleStreamQuery)
+
+		// Collection endpoints
+		api.GET("/collections", readTimeout, readLimit, collectionHandler.HandleGetCollections)
+
+		// Public FAQ
+		api.GET("/faq", readTimeout, readLimit, faqETag, faqHandler.HandleGetFAQ)
+
+		// Widget endpoints
+		api.GET("/widget/config", readTimeout, readLimit, widgetHandler.HandleGetPublicWidgetConfig)
+
+		// Session endpoints
+		api.GET("/sessions/:session_id/suggestions", queryTimeout, readLimit, queryHandler.HandleGetSessionSuggestions)
+		api.POST("/sessions/:session_id/outcome", defaultTimeout, defaultLimit, sessionHandler.HandleSetSessionOutcome)
+		api.GET("/sessions/:session_id/export", readLimit, middleware.AuthMiddleware(cfg.JWTSecret, cfg.AuthEnabled), exportHandler.HandleExportSession)
+		api.POST("/sessions/:session_id/claim", defaultTimeout, middleware.AuthMiddleware(cfg.JWTSecret, cfg.AuthEnabled), middleware.AuditContext(), claimLimit, sessionHandler.HandleClaimSession)
+
+		// Full-text search over past conversations
+		api.GET("/search", readTimeout, readLimit, middleware.AuthMiddleware(cfg.JWTSecret, cfg.AuthEnabled), searchHandler.HandleSearch)
+
+		// Plan quota and usage of the caller
+		api.GET("/usage", readTimeout, readLimit, middleware.AuthMiddleware(cfg.JWTSecret, cfg.AuthEnabled), quotaHandler.HandleGetUsage)
+
+		// Daily usage per user, pulled by the billing system with an admin token
+		billing := api.Group("/billing", readLimit, middleware.RequireRole(cfg.JWTSecret, middleware.RoleAdmin))
+		billing.GET("/usage", readTimeout, billingHandler.HandleGetUsage)
+		billing.GET("/usage/export", readTimeout, billingHandler.HandleExportUsage)
+		billing.GET("/usage/verify", queryTimeout, billingHandler.HandleVerifyUsage)
+	}
+
+	// Admin routes take an admin token
+	admin := router.Group("/api/admin", defaultLimit, middleware.RequireAdmin(cfg.JWTSecret), middleware.AuditContext())
+	{
+		// Webhook endpoints
+		admin.GET("/webhooks", webhookHandler.HandleGetWebhooks)
+		admin.POST("/webhooks", webhookHandler.HandleCreateWebhook)
+		admin.GET("/webhooks/:id", webhookHandler.HandleGetWebhook)
+		admin.PUT("/webhooks/:id", webhookHandler.HandleUpdateWebhook)
+		admin.DELETE("/webhooks/:id", webhookHandler.HandleDeleteWebhook)
+		admin.GET("/webhooks/:id/deliveries", webhookHandler.HandleGetWebhookDeliveries)
+
+		// Canned answer endpoints
+		admin.GET("/answers", cannedAnswerHandler.HandleGetCannedAnswers)
+		admin.POST("/answers", cannedAnswerHandler.HandleCreateCannedAnswer)
+		admin.GET("/answers/:id", cannedAnswerHandler.HandleGetCannedAnswer)
+		admin.PUT("/answers/:id", cannedAnswerHandler.HandleUpdateCannedAnswer)
+		admin.DELETE("/answers/:id", cannedAnswerHandler.HandleDeleteCannedAnswer)
+
+		// Model routing rule endpoints
+		admin.GET("/routing-rules", routingRuleHandler.HandleGetRoutingRules)
+		admin.POST("/routing-rules", routingRuleHandler.HandleCreateRoutingRule)
+		admin.POST("/routing-rules/test", routingRuleHandler.HandleTestRoutingRules)
+		admin.GET("/routing-rules/:id", routingRuleHandler.HandleGetRoutingRule)
+		admin.PUT("/routing-rules/:id", routingRuleHandler.HandleUpdateRoutingRule)
+		admin.DELETE("/routing-rules/:id", routingRuleHandler.HandleDeleteRoutingRule)
+
+		// Analytics segment endpoints
+		admin.GET("/segments", segmentHandler.HandleGetSegments)
+		admin.POST("/segments", segmentHandler.HandleCreateSegment)
+		admin.GET("/segments/:id", segmentHandler.HandleGetSegment)
+		admin.PUT("/segments/:id", segmentHandler.HandleUpdateSegment)
+		admin.DELETE("/segments/:id", segmentHandler.HandleDeleteSegment)
+
+		// Persona endpoints
+		admin.GET("/personas", personaHandler.HandleGetPersonas)
+		admin.POST("/personas", personaHandler.HandleCreatePersona)
+		admin.GET("/personas/:id", personaHandler.HandleGetPersona)
+		admin.PUT("/personas/:id", personaHandler.HandleUpdatePersona)
+		admin.DELETE("/personas/:id", personaHandler.HandleDeletePersona)
+		admin.POST("/personas/:id/preview", queryTimeout, queryLimit, personaHandler.HandlePreviewPersona)
+
+		// Runtime settings endpoints
+		admin.GET("/settings", settingsHandler.HandleGetSettings)
+		admin.PUT("/settings", settingsHandler.HandleUpdateSettings)
+		admin.DELETE("/settings/:key", settingsHandler.HandleDeleteSetting)
+		admin.GET("/log-level", settingsHandler.HandleGetLogLevel)
+		admin.PUT("/log-level", settingsHandler.HandleUpdateLogLevel)
+
+		// Feature flag endpoints
+		admin.GET("/flags", featureFlagHandler.HandleGetFlags)
+		admin.PUT("/flags", featureFlagHandler.HandleUpdateFlags)
+
+		// Retrieval feedback endpoints
+		admin.GET("/retrieval-feedback", feedbackHandler.HandleGetRetrievalFeedback)
+
+		// Abuse ban endpoints
+		admin.GET("/bans", banHandler.HandleGetBans)
+		admin.DELETE("/bans/:kind/:value", banHandler.HandleLiftBan)
+
+		// Widget config endpoints
+		admin.GET("/widgets", widgetHandler.HandleGetWidgetConfigs)
+		admin.POST("/widgets", widgetHandler.HandleCreateWidgetConfig)
+		admin.GET("/widgets/:id", widgetHandler.HandleGetWidgetConfig)
+		admin.PUT("/widgets/:id", widgetHandler.HandleUpdateWidgetConfig)
+		admin.DELETE("/widgets/:id", widgetHandler.HandleDeleteWidgetConfig)
+
+		// Dashboard summary
+		admin.GET("/dashboard", dashboardHandler.HandleGetDashboard)
+
+		// Live activity endpoints
+		admin.GET("/sessions/active", activityHandler.HandleGetActiveSessions)
+		admin.GET("/activity/stream", middleware.NoCompression(), requireFeature(services.FeatureStreaming), activityHandler.HandleStreamActivity)
+
+		// Answer annotation endpoints
+		admin.GET("/annotations", annotationHandler.HandleGetAnnotations)
+		admin.POST("/annotations", annotationHandler.HandleCreateAnnotation)
+		admin.POST("/annotations/:id/approve", annotationHandler.HandleApproveAnnotation)
+
+		// Prompt template endpoints
+		admin.GET("/prompts", promptTemplateHandler.HandleGetPromptTemplates)
+		admin.POST("/prompts", promptTemplateHandler.HandleCreatePromptTemplate)
+		admin.GET("/prompts/:id", promptTemplateHandler.HandleGetPromptTemplate)
+		admin.PUT("/prompts/:id", promptTemplateHandler.HandleUpdatePromptTemplate)
+		admin.DELETE("/prompts/:id", promptTemplateHandler.HandleDeletePromptTemplate)
+		admin.POST("/prompts/:id/activate", promptTemplateHandler.HandleActivatePromptTemplate)
+
+		// Experiment endpoints
+		admin.GET("/experiments", experimentHandler.HandleGetExperiments)
+		admin.POST("/experiments", experimentHandler.HandleCreateExperiment)
+		admin.GET("/experiments/:id", experimentHandler.HandleGetExperiment)
+		admin.PUT("/experiments/:id", experimentHandler.HandleUpdateExperiment)
+		admin.DELETE("/experiments/:id", experimentHandler.HandleDeleteExperiment)
+		admin.GET("/experiments/:id/results", experimentHandler.HandleGetExperimentResults)
+
+		// Audit log (read-only; entries are never updated or deleted)
+		admin.GET("/audit", auditHandler.HandleGetAuditLogs)
+
+		// Temporary per-user quota overrides
+		admin.GET("/quota-overrides", quotaHandler.HandleGetQuotaOverrides)
+		admin.PUT("/quota-overrides/:user_id", quotaHandler.HandleSetQuotaOverride)
+		admin.DELETE("/quota-overrides/:user_id", quotaHandler.HandleDeleteQuotaOverride)
+
+		// Chat history purges
+		admin.POST("/purge", purgeHandler.HandlePurge)
+		admin.GET("/purge/:id", purgeHandler.HandleGetPurgeJob)
+
+		// FAQ curated from real user questions
+		admin.GET("/faq", faqHandler.HandleGetFAQEntries)
+		admin.POST("/faq", faqHandler.HandleCreateFAQEntry)
+		admin.POST("/faq/from-query/:query_id", faqHandler.HandleCreateFAQEntryFromQuery)
+		admin.POST("/faq/reorder", faqHandler.HandleReorderFAQEntries)
+		admin.GET("/faq/:id", faqHandler.HandleGetFAQEntry)
+		admin.PUT("/faq/:id", faqHandler.HandleUpdateFAQEntry)
+		admin.DELETE("/faq/:id", faqHandler.HandleDeleteFAQEntry)
+
+		// Debug bundles of single queries, for investigating bad answers
+		admin.GET("/queries/:id/debug", queryDebugHandler.HandleGetQueryDebug)
+
+		// Knowledge gap reports of questions the docs couldn't answer
+		admin.POST("/knowledge-gaps/generate", knowledgeGapHandler.HandleGenerateKnowledgeGaps)
+		admin.GET("/knowledge-gaps", knowledgeGapHandler.HandleGetKnowledgeGapReports)
+		admin.GET("/knowledge-gaps/:id", knowledgeGapHandler.HandleGetKnowledgeGapReport)
+
+		// Re-ingestion of stored documents, after the RAG service's chunking
+		// or embeddings change
+		admin.POST("/docs/reingest-all", reingestHandler.HandleReingestAll)
+		admin.GET("/docs/reingest-jobs/:id", reingestHandler.HandleGetReingestJob)
+		admin.DELETE("/docs/reingest-jobs/:id", reingestHandler.HandleCancelReingestJob)
+
+		// Shadow tests of a candidate RAG service
+		admin.POST("/shadow-test", shadowTestHandler.HandleStartShadowTest)
+		admin.GET("/shadow-test/:id", shadowTestHandler.HandleGetShadowTest)
+		admin.DELETE("/shadow-test/:id", shadowTestHandler.HandleCancelShadowTest)
+
+		// Analytics report endpoints
+		admin.POST("/reports/generate", analyticsHandler.HandleGenerateReport)
+		admin.GET("/reports/:id", analyticsHandler.HandleGetReport)
+
+		// Runtime state, and clearing a bucket when a client is wrongly throttled
+		admin.GET("/runtime", runtimeHandler.HandleGetRuntime)
+		admin.POST("/runtime/reset-ratelimit/:key", runtimeHandler.HandleResetRateLimit)
+
+		// Configuration bundles
+		admin.GET("/export", configBundleHandler.HandleExportConfig)
+		admin.POST("/import", configBundleHandler.HandleImportConfig)
+
+		// Async operations that failed for good
+		admin.GET("/dead-letters", deadLetterHandler.HandleGetDeadLetters)
+		admin.POST("/dead-letters/:id/replay", deadLetterHandler.HandleReplayDeadLetter)
+
+		// Preloading the cache with popular queries
+		admin.POST("/cache/warmup", queryHandler.HandleStartCacheWarmup)
+		admin.GET("/cache/warmup", queryHandler.HandleGetCacheWarmup)
+
+		// Fault injection, only routed in chaos mode
+		if chaosHandler != nil {
+			admin.POST("/chaos/faults", chaosHandler.HandleCreateFault)
+			admin.GET("/chaos/faults", chaosHandler.HandleGetFaults)
+			admin.DELETE("/chaos/faults/:id", chaosHandler.HandleDeleteFault)
+		}
 	}
 
 	// Root endpoint
@@ -161,6 +825,72 @@ func setupRoutes(
 	})
 }
 
+// rateLimitPolicy builds a per-IP rate limit policy whose budget follows the runtime settings
+func rateLimitPolicy(name string, settingsService *services.SettingsService) middleware.RateLimitPolicy {
+	limit := settingsService.RateLimit(name)
+	return middleware.RateLimitPolicy{
+		Name:     name,
+		Requests: limit.Requests,
+		Window:   time.Duration(limit.WindowS) * time.Second,
+		KeyFunc:  middleware.KeyByClientIP,
+		Current: func() (int, time.Duration) {
+			limit := settingsService.RateLimit(name)
+			return limit.Requests, time.Duration(limit.WindowS) * time.Second
+		},
+	}
+}
+
+// ragProbeTimeout bounds the RAG service probe made by -check-config
+const ragProbeTimeout = 5 * time.Second
+
+// runConfigCheck validates configuration and prints the report as JSON,
+// returning the exit code: 1 if there are errors. Unless offline, the RAG
+// service must also answer a health check.
+func runConfigCheck(offline bool) int {
+	cfg, report := config.Check()
+
+	if !offline && !report.HasError("RAG_TRANSPORT") && !report.HasError("RAG_SERVICE_URL") && !report.HasError("RAG_GRPC_ADDRESS") {
+		field := "RAG_SERVICE_URL"
+		if cfg.RAGTransport == "grpc" {
+			field = "RAG_GRPC_ADDRESS"
+		}
+		if err := probeRAG(cfg); err != nil {
+			report.AddError(field, "RAG service is not reachable: %v", err)
+		}
+	}
+
+	output := struct {
+		Environment string `json:"environment"`
+		Valid       bool   `json:"valid"`
+		*config.Report
+	}{cfg.Environment, len(report.Errors) == 0, report}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(output); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write config report: %v\n", err)
+		return 1
+	}
+
+	if !output.Valid {
+		return 1
+	}
+	return 0
+}
+
+// probeRAG checks that the RAG service answers its health check
+func probeRAG(cfg *config.Config) error {
+	transport, err := services.NewRAGTransport(cfg)
+	if err != nil {
+		return err
+	}
+	defer transport.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), ragProbeTimeout)
+	defer cancel()
+	return transport.Check(ctx)
+}
+
 // setupLogger configures the logger
 func setupLogger() {
 	logrus.SetFormatter(&logrus.JSONFormatter{
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ai-support-assistant/backend/internal/apidocs"
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// testRouter registers every route, with handlers that are never called
+func testRouter(cfg *config.Config) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	setupRoutes(router, cfg, services.NewSettingsService(cfg), nil, nil, nil, func(c *gin.Context) {},
+		nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil,
+		nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	return router
+}
+
+// The spec must describe every route setupRoutes registers, so it can't
+// drift from the router
+func TestOpenAPISpecCoversEveryRoute(t *testing.T) {
+	router := testRouter(&config.Config{Environment: "development"})
+
+	if missing := apidocs.Undocumented(router.Routes()); len(missing) > 0 {
+		t.Errorf("routes missing from the apidocs endpoint table:\n%s", strings.Join(missing, "\n"))
+	}
+
+	data, err := json.Marshal(apidocs.Build(router.Routes(), "test"))
+	if err != nil {
+		t.Fatalf("failed to marshal spec: %v", err)
+	}
+	var spec struct {
+		OpenAPI string                                       `json:"openapi"`
+		Paths   map[string]map[string]map[string]interface{} `json:"paths"`
+	}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		t.Fatalf("failed to unmarshal spec: %v", err)
+	}
+	if spec.OpenAPI == "" {
+		t.Error("spec has no openapi version")
+	}
+
+	for _, route := range router.Routes() {
+		if route.Method == http.MethodOptions {
+			continue
+		}
+		path := route.Path
+		for _, segment := range strings.Split(path, "/") {
+			if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+				path = strings.Replace(path, segment, "{"+segment[1:]+"}", 1)
+			}
+		}
+		if _, ok := spec.Paths[path][strings.ToLower(route.Method)]; !ok {
+			t.Errorf("%s %s is missing from the spec", route.Method, path)
+		}
+	}
+}
+
+func TestDocsUIOnlyOutsideProduction(t *testing.T) {
+	tests := []struct {
+		environment string
+		want        bool
+	}{
+		{"development", true},
+		{"production", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.environment, func(t *testing.T) {
+			served := false
+			for _, route := range testRouter(&config.Config{Environment: tt.environment}).Routes() {
+				if route.Path == "/api/docs-ui" {
+					served = true
+				}
+			}
+			if served != tt.want {
+				t.Errorf("docs UI served = %t, want %t", served, tt.want)
+			}
+		})
+	}
+}
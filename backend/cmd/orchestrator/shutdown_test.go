@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/handlers"
+	"github.com/ai-support-assistant/backend/internal/lifecycle"
+	"github.com/ai-support-assistant/backend/internal/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// drainingGauge reads the server_draining gauge
+func drainingGauge(t *testing.T) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() == "server_draining" {
+			return family.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	t.Fatal("server_draining gauge not registered")
+	return 0
+}
+
+// Readiness must fail as soon as SIGTERM arrives, while requests are still
+// served, and connections only be refused once the delay has passed
+func TestShutdownFailsReadinessBeforeRefusingConnections(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	lc := lifecycle.NewManager()
+	router := gin.New()
+	router.GET("/api/health/ready", handlers.NewHealthHandler(nil, lc).HandleReady)
+	router.GET("/api/faq", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+	t.Cleanup(func() { middleware.SetDraining(false) })
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGTERM)
+	defer signal.Stop(quit)
+
+	cfg := &config.Config{ShutdownDelayS: 1, ShutdownDrainTimeoutS: 1}
+	done := make(chan struct{})
+	go func() {
+		<-quit
+		shutdown(server.Config, lc, cfg, quit)
+		close(done)
+	}()
+
+	// New connections each time, so a refused connection shows
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}, Timeout: time.Second}
+	get := func(path string) (int, error) {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	if code, err := get("/api/health/ready"); err != nil || code != http.StatusOK {
+		t.Fatalf("ready before SIGTERM = %d, %v; want 200", code, err)
+	}
+
+	signaled := time.Now()
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatalf("failed to send SIGTERM: %v", err)
+	}
+
+	deadline := time.Now().Add(500 * time.Millisecond)
+	for {
+		code, err := get("/api/health/ready")
+		if err != nil {
+			t.Fatalf("connection refused %v after SIGTERM, before readiness failed: %v", time.Since(signaled), err)
+		}
+		if code == http.StatusServiceUnavailable {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("readiness still %d %v after SIGTERM", code, time.Since(signaled))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if drainingGauge(t) != 1 {
+		t.Error("server_draining gauge not set while draining")
+	}
+
+	// Load balancers may still route here during the delay
+	if code, err := get("/api/faq"); err != nil || code != http.StatusOK {
+		t.Errorf("request while draining = %d, %v; want it served", code, err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown didn't finish")
+	}
+	if elapsed := time.Since(signaled); elapsed < time.Second {
+		t.Errorf("server shut down %v after SIGTERM, before the 1s delay", elapsed)
+	}
+	if _, err := get("/api/faq"); err == nil {
+		t.Error("connections still accepted after shutdown")
+	}
+}
@@ -0,0 +1,220 @@
+package abuse
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/cache"
+	"github.com/go-redis/redis/v8"
+)
+
+// Ban subject kinds
+const (
+	KindSession = "session"
+	KindIP      = "ip"
+)
+
+// Thresholds configures when a client is considered abusive. A subject is
+// banned when it sends more than its max queries in a window, or when it sends
+// at least MinQueriesForRatio queries of which at least MaxDistinctRatio are distinct.
+type Thresholds struct {
+	Window             time.Duration
+	SessionMaxQueries  int
+	IPMaxQueries       int
+	MinQueriesForRatio int
+	MaxDistinctRatio   float64
+	BanDuration        time.Duration
+}
+
+// Ban is a temporary block on a session or IP address
+type Ban struct {
+	Kind      string    `json:"kind"`
+	Value     string    `json:"value"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Detector tracks per-session and per-IP query patterns in Redis
+type Detector struct {
+	thresholds Thresholds
+}
+
+// NewDetector creates a detector with the given thresholds
+func NewDetector(thresholds Thresholds) *Detector {
+	return &Detector{thresholds: thresholds}
+}
+
+// Observe records a query and returns any bans it triggered
+func (d *Detector) Observe(ctx context.Context, sessionID, ip, query string) ([]Ban, error) {
+	var bans []Ban
+
+	subjects := []struct {
+		kind  string
+		value string
+		max   int
+	}{
+		{KindSession, sessionID, d.thresholds.SessionMaxQueries},
+		{KindIP, ip, d.thresholds.IPMaxQueries},
+	}
+
+	for _, subject := range subjects {
+		if subject.value == "" {
+			continue
+		}
+
+		count, distinct, err := d.record(ctx, subject.kind, subject.value, query)
+		if err != nil {
+			return bans, err
+		}
+
+		reason := Evaluate(d.thresholds, subject.max, count, distinct)
+		if reason == "" {
+			continue
+		}
+
+		ban, err := d.ban(ctx, subject.kind, subject.value, reason)
+		if err != nil {
+			return bans, err
+		}
+		bans = append(bans, *ban)
+	}
+
+	return bans, nil
+}
+
+// Evaluate returns the reason a subject exceeds the thresholds, or "" if it doesn't
+func Evaluate(t Thresholds, maxQueries int, count, distinct int64) string {
+	if maxQueries > 0 && count > int64(maxQueries) {
+		return fmt.Sprintf("query flood: %d queries in %s", count, t.Window)
+	}
+
+	if t.MinQueriesForRatio > 0 && t.MaxDistinctRatio > 0 && count >= int64(t.MinQueriesForRatio) {
+		ratio := float64(distinct) / float64(count)
+		if ratio >= t.MaxDistinctRatio {
+			return fmt.Sprintf("probing: %d of %d queries distinct in %s", distinct, count, t.Window)
+		}
+	}
+
+	return ""
+}
+
+// record counts the query and its distinct fingerprint for the current window
+func (d *Detector) record(ctx context.Context, kind, value, query string) (int64, int64, error) {
+	countKey := fmt.Sprintf("abuse:count:%s:%s", kind, value)
+	distinctKey := fmt.Sprintf("abuse:distinct:%s:%s", kind, value)
+
+	count, err := cache.Increment(ctx, countKey)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	fingerprint := cache.GenerateCacheKey("q", strings.ToLower(strings.Join(strings.Fields(query), " ")))
+	if err := cache.SetAdd(ctx, distinctKey, fingerprint); err != nil {
+		return 0, 0, err
+	}
+
+	// First query in the window starts it for both counters
+	if count == 1 {
+		cache.Expire(ctx, countKey, d.thresholds.Window)
+		cache.Expire(ctx, distinctKey, d.thresholds.Window)
+	}
+
+	distinct, err := cache.SetCard(ctx, distinctKey)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return count, distinct, nil
+}
+
+// ban stores a ban entry that expires after the ban duration
+func (d *Detector) ban(ctx context.Context, kind, value, reason string) (*Ban, error) {
+	now := time.Now().UTC()
+	ban := &Ban{
+		Kind:      kind,
+		Value:     value,
+		Reason:    reason,
+		CreatedAt: now,
+		ExpiresAt: now.Add(d.thresholds.BanDuration),
+	}
+
+	if err := cache.Set(ctx, banKey(kind, value), ban, d.thresholds.BanDuration); err != nil {
+		return nil, fmt.Errorf("failed to store ban: %w", err)
+	}
+
+	// Start counting afresh once the ban expires
+	cache.Delete(ctx, fmt.Sprintf("abuse:count:%s:%s", kind, value))
+	cache.Delete(ctx, fmt.Sprintf("abuse:distinct:%s:%s", kind, value))
+
+	return ban, nil
+}
+
+// IsBanned returns the active ban for a subject, or nil
+func (d *Detector) IsBanned(ctx context.Context, kind, value string) (*Ban, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var ban Ban
+	err := cache.Get(ctx, banKey(kind, value), &ban)
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &ban, nil
+}
+
+// ListBans returns all active bans
+func (d *Detector) ListBans(ctx context.Context) ([]Ban, error) {
+	keys, err := cache.ScanKeys(ctx, "abuse:ban:*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bans: %w", err)
+	}
+
+	bans := make([]Ban, 0, len(keys))
+	for _, key := range keys {
+		var ban Ban
+		if err := cache.Get(ctx, key, &ban); err != nil {
+			// Expired between scan and get
+			continue
+		}
+		bans = append(bans, ban)
+	}
+
+	return bans, nil
+}
+
+// Lift removes a ban, returning false if there was no active ban
+func (d *Detector) Lift(ctx context.Context, kind, value string) (bool, error) {
+	key := banKey(kind, value)
+
+	exists, err := cache.Exists(ctx, key)
+	if err != nil {
+		return false, fmt.Errorf("failed to check ban: %w", err)
+	}
+	if !exists {
+		return false, nil
+	}
+
+	if err := cache.Delete(ctx, key); err != nil {
+		return false, fmt.Errorf("failed to lift ban: %w", err)
+	}
+
+	return true, nil
+}
+
+// IsValidKind returns true if the ban subject kind is supported
+func IsValidKind(kind string) bool {
+	return kind == KindSession || kind == KindIP
+}
+
+// banKey returns the Redis key holding a subject's ban
+func banKey(kind, value string) string {
+	return fmt.Sprintf("abuse:ban:%s:%s", kind, value)
+}
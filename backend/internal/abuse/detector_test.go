@@ -0,0 +1,69 @@
+package abuse
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvaluate(t *testing.T) {
+	thresholds := Thresholds{
+		Window:             time.Minute,
+		SessionMaxQueries:  30,
+		MinQueriesForRatio: 10,
+		MaxDistinctRatio:   0.9,
+	}
+
+	tests := []struct {
+		name     string
+		max      int
+		count    int64
+		distinct int64
+		want     string // prefix of the reason, "" for no ban
+	}{
+		{"below max", 30, 29, 1, ""},
+		{"at max", 30, 30, 1, ""},
+		{"one over max", 30, 31, 1, "query flood"},
+		{"no max", 0, 1000, 1, ""},
+		{"ratio below minimum queries", 30, 9, 9, ""},
+		{"ratio at minimum queries", 30, 10, 9, "probing"},
+		{"ratio just under", 30, 20, 17, ""},
+		{"ratio at threshold", 30, 20, 18, "probing"},
+		{"repeated questions", 30, 25, 2, ""},
+		// A flood is reported as a flood even when it is also probing
+		{"flood and probing", 30, 40, 40, "query flood"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Evaluate(thresholds, tt.max, tt.count, tt.distinct)
+			if tt.want == "" {
+				if got != "" {
+					t.Errorf("Evaluate = %q, want no ban", got)
+				}
+				return
+			}
+			if !strings.HasPrefix(got, tt.want) {
+				t.Errorf("Evaluate = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateRatioDisabled(t *testing.T) {
+	for _, thresholds := range []Thresholds{
+		{MinQueriesForRatio: 0, MaxDistinctRatio: 0.5},
+		{MinQueriesForRatio: 5, MaxDistinctRatio: 0},
+	} {
+		if got := Evaluate(thresholds, 0, 100, 100); got != "" {
+			t.Errorf("Evaluate(%+v) = %q, want no ban", thresholds, got)
+		}
+	}
+}
+
+func TestIsValidKind(t *testing.T) {
+	for kind, want := range map[string]bool{KindSession: true, KindIP: true, "user": false, "": false} {
+		if got := IsValidKind(kind); got != want {
+			t.Errorf("IsValidKind(%q) = %v, want %v", kind, got, want)
+		}
+	}
+}
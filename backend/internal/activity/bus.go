@@ -0,0 +1,272 @@
+package activity
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/cache"
+	"github.com/ai-support-assistant/backend/internal/logging"
+	"github.com/ai-support-assistant/backend/internal/middleware"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// Event types pushed to the activity feed
+const (
+	EventQueryCreated    = "query.created"
+	EventFeedbackCreated = "feedback.created"
+)
+
+// excerptLength caps the text events carry, in runes
+const excerptLength = 160
+
+// channel is the Redis pub/sub channel relaying events between instances
+const channel = "activity:events"
+
+// outboundQueueSize bounds the events waiting to be relayed to Redis; when
+// Redis falls behind, further events only reach local subscribers
+const outboundQueueSize = 256
+
+// envelope is an event relayed through Redis, tagged with the instance that
+// published it so that instance doesn't deliver it twice
+type envelope struct {
+	Origin string               `json:"origin"`
+	Event  models.ActivityEvent `json:"event"`
+}
+
+// Bus fans activity events out to the feed's subscribers. Events published on
+// this instance are delivered locally and relayed through Redis, so every
+// instance's subscribers see events from all instances.
+type Bus struct {
+	instanceID string
+	outbound   chan models.ActivityEvent
+
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+}
+
+// NewBus creates a bus; call Start to relay events between instances
+func NewBus() *Bus {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return &Bus{
+		instanceID:  hex.EncodeToString(b),
+		outbound:    make(chan models.ActivityEvent, outboundQueueSize),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Start relays events to and from Redis until ctx is cancelled. Without
+// Redis, subscribers only see events published on this instance.
+func (b *Bus) Start(ctx context.Context) {
+	if cache.Client == nil {
+		logrus.Info("Redis unavailable, activity feed limited to this instance")
+		return
+	}
+
+	go b.relay(ctx)
+	go b.subscribe(ctx)
+}
+
+// relay publishes this instance's events to Redis
+func (b *Bus) relay(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-b.outbound:
+			data, err := json.Marshal(envelope{Origin: b.instanceID, Event: event})
+			if err != nil {
+				logrus.WithError(err).Warn("Failed to encode activity event")
+				continue
+			}
+			if err := cache.Publish(ctx, channel, string(data)); err != nil && ctx.Err() == nil {
+				logrus.WithError(err).Debug("Failed to relay activity event")
+			}
+		}
+	}
+}
+
+// subscribe delivers events published on other instances
+func (b *Bus) subscribe(ctx context.Context) {
+	pubsub, err := cache.Subscribe(ctx, channel)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to subscribe to activity events, feed limited to this instance")
+		return
+	}
+	defer pubsub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-pubsub.Channel():
+			if !ok {
+				return
+			}
+			var env envelope
+			if err := json.Unmarshal([]byte(msg.Payload), &env); err != nil {
+				logrus.WithError(err).Debug("Ignoring malformed activity event")
+				continue
+			}
+			if env.Origin != b.instanceID {
+				b.deliver(env.Event)
+			}
+		}
+	}
+}
+
+// Publish delivers an event to this instance's subscribers and queues it for
+// the other instances. It never blocks.
+func (b *Bus) Publish(event models.ActivityEvent) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+
+	b.deliver(event)
+
+	if cache.Client == nil {
+		return
+	}
+	select {
+	case b.outbound <- event:
+	default:
+		logrus.WithField("type", event.Type).Debug("Activity relay queue full, event not relayed")
+	}
+}
+
+// deliver hands an event to every local subscriber
+func (b *Bus) deliver(event models.ActivityEvent) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subscribers {
+		sub.push(event)
+	}
+}
+
+// Subscribe registers a subscriber buffering up to size events; the caller
+// must Close it
+func (b *Bus) Subscribe(size int) *Subscription {
+	if size <= 0 {
+		size = 1
+	}
+	sub := &Subscription{bus: b, events: make(chan models.ActivityEvent, size)}
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	count := len(b.subscribers)
+	b.mu.Unlock()
+
+	middleware.SetActivitySubscribers(count)
+	return sub
+}
+
+// Subscription is one consumer of the feed. When its buffer is full the
+// oldest event is dropped, so a slow consumer misses events rather than
+// holding up publishers or other consumers.
+type Subscription struct {
+	bus    *Bus
+	events chan models.ActivityEvent
+
+	// pushMu serializes pushes so dropping the oldest event and adding the
+	// new one can't interleave with another push
+	pushMu  sync.Mutex
+	dropped atomic.Int64
+	closed  bool
+}
+
+// Events returns the subscriber's events; it is closed by Close
+func (s *Subscription) Events() <-chan models.ActivityEvent {
+	return s.events
+}
+
+// TakeDropped returns how many events were dropped since the last call
+func (s *Subscription) TakeDropped() int64 {
+	return s.dropped.Swap(0)
+}
+
+// Close unregisters the subscriber
+func (s *Subscription) Close() {
+	b := s.bus
+	b.mu.Lock()
+	if s.closed {
+		b.mu.Unlock()
+		return
+	}
+	s.closed = true
+	delete(b.subscribers, s)
+	count := len(b.subscribers)
+	close(s.events)
+	b.mu.Unlock()
+
+	middleware.SetActivitySubscribers(count)
+}
+
+// push adds an event, dropping the oldest buffered events to make room. The
+// bus's read lock is held, so the subscription can't close meanwhile.
+func (s *Subscription) push(event models.ActivityEvent) {
+	s.pushMu.Lock()
+	defer s.pushMu.Unlock()
+
+	for {
+		select {
+		case s.events <- event:
+			return
+		default:
+		}
+
+		select {
+		case <-s.events:
+			s.dropped.Add(1)
+			middleware.RecordActivityEventDropped()
+		default:
+		}
+	}
+}
+
+// QueryEvent describes a recorded query for the feed
+func QueryEvent(query models.ChatQuery) models.ActivityEvent {
+	return models.ActivityEvent{
+		Type:            EventQueryCreated,
+		SessionID:       query.SessionID,
+		UserID:          query.UserID,
+		QueryID:         query.ID,
+		QueryExcerpt:    Excerpt(query.Query),
+		ResponseExcerpt: Excerpt(query.Response),
+		Model:           query.Model,
+		CacheHit:        query.CacheHit,
+		LatencyMs:       query.LatencyMs,
+		Timestamp:       query.CreatedAt,
+	}
+}
+
+// FeedbackEvent describes recorded feedback on a query for the feed
+func FeedbackEvent(feedback models.Feedback, query models.ChatQuery) models.ActivityEvent {
+	return models.ActivityEvent{
+		Type:           EventFeedbackCreated,
+		SessionID:      feedback.SessionID,
+		UserID:         query.UserID,
+		QueryID:        query.ID,
+		QueryExcerpt:   Excerpt(query.Query),
+		FeedbackID:     feedback.ID,
+		Score:          feedback.Score,
+		CommentExcerpt: Excerpt(feedback.Comment),
+		Timestamp:      feedback.CreatedAt,
+	}
+}
+
+// Excerpt shortens text for the feed, redacting credentials
+func Excerpt(text string) string {
+	text = logging.ScrubSecrets(text)
+	runes := []rune(text)
+	if len(runes) <= excerptLength {
+		return text
+	}
+	return string(runes[:excerptLength]) + "..."
+}
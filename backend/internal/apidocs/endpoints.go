@@ -0,0 +1,482 @@
+package apidocs
+
+import (
+	"github.com/ai-support-assistant/backend/internal/abuse"
+	"github.com/ai-support-assistant/backend/internal/chaos"
+	"github.com/ai-support-assistant/backend/internal/models"
+)
+
+// endpoint documents one route. Request and Response are example values
+// whose types are described; Object describes gin.H bodies.
+type endpoint struct {
+	Tag         string
+	Summary     string
+	Query       []param
+	Request     interface{}
+	Form        []param // multipart/form-data fields, instead of a JSON Request
+	Status      int
+	Response    interface{}
+	ContentType string      // response content type, when not JSON
+	Accepted    interface{} // 202 response when the request is deferred
+	Auth        bool        // requires a bearer token; implied under /api/admin, which takes the admin role
+}
+
+// param is a query string or form field
+type param struct {
+	Name        string
+	Type        string
+	Description string
+	Required    bool
+}
+
+// Shared response shapes
+var (
+	deleted = func(key string) Object {
+		return Object{"message": "", key: uint(0)}
+	}
+)
+
+// Common query parameters
+var (
+	limitParam  = param{Name: "limit", Type: "integer", Description: "Maximum number of results"}
+	offsetParam = param{Name: "offset", Type: "integer", Description: "Number of results to skip"}
+	daysParam   = param{Name: "days", Type: "integer", Description: "Number of days to cover"}
+	tzParam     = param{Name: "tz", Type: "string", Description: "IANA time zone for bucketing, default UTC"}
+
+	segmentParam = param{Name: "segment_id", Type: "integer", Description: "Only count the queries in this analytics segment"}
+
+	usageParams = []param{
+		{Name: "user_id", Type: "string", Description: "Only this user; all users when omitted"},
+		{Name: "from", Type: "string", Description: "First day, YYYY-MM-DD UTC; default 29 days before to"},
+		{Name: "to", Type: "string", Description: "Last day, YYYY-MM-DD UTC; default today"},
+	}
+)
+
+// endpoints documents every route by "METHOD /path" in gin syntax. Keep it
+// in step with setupRoutes; Undocumented lists routes missing from it.
+var endpoints = map[string]endpoint{
+	"GET /": {Tag: "system", Summary: "Service information", Response: Object{"service": "", "version": "", "status": ""}},
+	"GET /metrics": {Tag: "system", Summary: "Prometheus metrics", ContentType: "text/plain",
+		Response: ""},
+	"GET /api/health": {Tag: "system", Summary: "Health of the service and its dependencies; 503 when degraded",
+		Response: models.HealthResponse{}},
+	"GET /api/health/ready": {Tag: "system", Summary: "Readiness for traffic; 503 once the instance starts draining for shutdown",
+		Response: Object{"status": ""}},
+	"GET /api/openapi.json": {Tag: "system", Summary: "This OpenAPI specification", Response: Object{}},
+	"GET /api/docs-ui": {Tag: "system", Summary: "Swagger UI for this specification (non-production only)", ContentType: "text/html",
+		Response: ""},
+
+	// Queries
+	"POST /api/query": {Tag: "query", Summary: "Answer a question; async requests return 202 with a job to poll. Images go base64 encoded in attachments, or as attachments file parts of a multipart form whose request field holds the JSON query; queries with images are never cached",
+		Request: models.QueryRequest{}, Response: models.QueryResponse{},
+		Accepted: Object{"job_id": "", "status": "", "status_url": ""}},
+	"POST /api/query/stream": {Tag: "query", Summary: "Answer a JSON question as server-sent events: token events as the answer is generated, then a response event with the final answer, which replaces the streamed text. Tokens aren't streamed while moderation is enforced; requires a plan with streaming",
+		Request: models.QueryRequest{}, ContentType: "text/event-stream", Response: ""},
+	"POST /api/query/:query_id/regenerate": {Tag: "query", Summary: "Answer a query again with different parameters, up to REGENERATE_MAX_ATTEMPTS times",
+		Request: models.RegenerateRequest{}, Response: models.QueryResponse{}},
+	"GET /api/query/jobs/:id": {Tag: "query", Summary: "Get an async query job",
+		Response: models.QueryJob{}},
+
+	// Feedback
+	"POST /api/feedback": {Tag: "feedback", Summary: "Rate an answer; 503 not_yet_visible with Retry-After when the query is still being saved, 404 when it doesn't exist",
+		Request: models.FeedbackRequest{}, Response: Object{"message": "", "query_id": uint(0)}},
+	"GET /api/feedback": {Tag: "feedback", Summary: "Recent feedback with its queries", Query: []param{limitParam},
+		Response: Object{"feedbacks": []models.Feedback{}, "count": 0}},
+	"GET /api/feedback/stats": {Tag: "feedback", Summary: "Feedback totals and positive rate",
+		Query:    []param{{Name: "exclude_imported", Type: "boolean", Description: "Leave out survey responses imported in bulk"}, segmentParam},
+		Response: Object{"total_feedback": int64(0), "positive_feedback": int64(0), "negative_feedback": int64(0), "positive_rate": 0.0}},
+	"POST /api/feedback/import": {Tag: "feedback", Auth: true,
+		Summary: "Import survey responses as feedback from a JSON array, a CSV body with a header row, or a multipart .csv or .json file; rows that fail validation or were already imported are reported and skipped; requires the admin role",
+		Request: []models.FeedbackImportRow{}, Response: models.FeedbackImportResult{}},
+
+	// Analytics
+	"GET /api/analytics": {Tag: "analytics", Summary: "Overall query and feedback analytics", Query: []param{segmentParam},
+		Response: models.Analytics{}},
+	"GET /api/analytics/top-queries": {Tag: "analytics", Summary: "Most frequent queries", Query: []param{limitParam, segmentParam},
+		Response: Object{"queries": []map[string]interface{}{}}},
+	"GET /api/analytics/trends": {Tag: "analytics", Summary: "Query volume over time",
+		Query:    []param{daysParam, {Name: "granularity", Type: "string", Description: "hour, day or week"}, tzParam, segmentParam},
+		Response: Object{"trends": []models.QueryTrend{}, "granularity": "", "tz": ""}},
+	"GET /api/analytics/latency": {Tag: "analytics", Summary: "Latency percentiles", Query: []param{daysParam},
+		Response: models.LatencyStats{}},
+	"GET /api/analytics/regenerations": {Tag: "analytics", Summary: "Regenerated answers and how often they turned negative feedback positive", Query: []param{daysParam},
+		Response: models.RegenerationStats{}},
+	"GET /api/analytics/grounding": {Tag: "analytics", Summary: "Distribution of answer grounding scores", Query: []param{daysParam},
+		Response: models.GroundingStats{}},
+	"GET /api/analytics/languages": {Tag: "analytics", Summary: "Query volume and answer translation rate by detected language", Query: []param{daysParam},
+		Response: Object{"languages": []models.LanguageCount{}, "translation_rate": 0.0, "days": 0}},
+	"GET /api/analytics/prompt-versions": {Tag: "analytics", Summary: "Feedback by prompt template version", Query: []param{daysParam},
+		Response: Object{"prompt_versions": []models.PromptVersionStats{}, "days": 0}},
+	"GET /api/analytics/pipelines": {Tag: "analytics", Summary: "Query volume, latency and feedback of RAG answers and direct LLM answers", Query: []param{daysParam},
+		Response: Object{"pipelines": []models.PipelineStats{}, "days": 0}},
+	"GET /api/analytics/pages": {Tag: "analytics", Summary: "Query volume and feedback by page", Query: []param{daysParam, limitParam},
+		Response: Object{"pages": []models.PageStats{}, "days": 0}},
+	"GET /api/analytics/feedback-themes": {Tag: "analytics", Summary: "Themes of negative feedback comments",
+		Query: []param{
+			{Name: "from", Type: "string", Description: "RFC 3339 start time"},
+			{Name: "to", Type: "string", Description: "RFC 3339 end time"},
+		},
+		Response: models.FeedbackThemeReport{}},
+	"GET /api/analytics/outcomes": {Tag: "analytics", Summary: "Session outcomes and deflection over time", Query: []param{daysParam, tzParam},
+		Response: Object{"outcomes": []models.OutcomeTrend{}, "days": 0, "tz": ""}},
+	"GET /api/analytics/heatmap": {Tag: "analytics", Summary: "Query volume by day of week and hour, for staffing",
+		Query: []param{daysParam, tzParam,
+			{Name: "include_feedback", Type: "boolean", Description: "Also count negative feedback by hour"},
+		},
+		Response: models.QueryHeatmap{}},
+
+	// Documents
+	"POST /api/docs/upload": {Tag: "documents", Summary: "Upload a document for ingestion; it is scanned for malware first and rejected with malware_detected if infected",
+		Form: []param{
+			{Name: "file", Type: "file", Description: "Document to ingest", Required: true},
+			{Name: "collection", Type: "string", Description: "Collection to place the document in"},
+			{Name: "document_key", Type: "string", Description: "Logical document this upload is a new version of; derived from the file name when omitted"},
+			{Name: "visibility", Type: "string", Description: "public or internal; internal documents only answer agent queries. Defaults to the previous version's, or public"},
+			{Name: "chunk_size", Type: "integer", Description: "Characters per chunk, 100 to 8000. Ingestion options default to the previous version's, or DEFAULT_CHUNK_SIZE etc."},
+			{Name: "chunk_overlap", Type: "integer", Description: "Characters shared by consecutive chunks; must be less than chunk_size"},
+			{Name: "ocr", Type: "boolean", Description: "Read scanned pages with OCR"},
+			{Name: "language", Type: "string", Description: "Language code of the text, e.g. en or pt-BR; detected when omitted"},
+		},
+		Response: models.DocumentUploadResponse{}},
+	"POST /api/docs/ingest-url": {Tag: "documents", Summary: "Crawl a URL or sitemap and ingest its pages; requires the admin or agent role", Auth: true,
+		Request: models.CrawlRequest{}, Status: 202, Response: models.CrawlJob{}},
+	"POST /api/docs/ingest-object": {Tag: "documents", Summary: "Ingest an object from S3, GCS or a presigned HTTPS URL; progress is reported on the document; requires the admin or agent role", Auth: true,
+		Request: models.ObjectIngestRequest{}, Status: 202, Response: models.DocumentUploadResponse{}},
+	"GET /api/docs/crawl-jobs/:id": {Tag: "documents", Summary: "Get a crawl job",
+		Response: models.CrawlJob{}},
+	"GET /api/docs": {Tag: "documents", Summary: "List documents",
+		Query: []param{limitParam, offsetParam,
+			{Name: "collection", Type: "string", Description: "Only documents in this collection"},
+			{Name: "visibility", Type: "string", Description: "Only public or only internal documents"},
+			{Name: "include_superseded", Type: "boolean", Description: "Include versions replaced by a newer upload"},
+		},
+		Response: Object{"documents": []models.Document{}, "count": 0}},
+	"GET /api/docs/:id": {Tag: "documents", Summary: "Get a document",
+		Response: models.Document{}},
+	"GET /api/docs/:id/status": {Tag: "documents", Summary: "Get a document's ingestion status and progress",
+		Response: models.DocumentStatus{}},
+	"GET /api/docs/:id/preview": {Tag: "documents", Summary: "Get the text extracted from a txt, md, csv or json upload, available before ingestion; internal documents need an admin or agent token",
+		Response: models.DocumentPreview{}},
+	"GET /api/docs/:id/progress": {Tag: "documents", Summary: "Stream a document's ingestion progress as server-sent progress events until ingestion ends",
+		ContentType: "text/event-stream", Response: ""},
+	"GET /api/docs/:id/versions": {Tag: "documents", Summary: "List every version of a document, newest first",
+		Response: Object{"versions": []models.Document{}, "count": 0}},
+	"PATCH /api/docs/:id": {Tag: "documents", Summary: "Update a document; requires the admin or agent role", Auth: true,
+		Request: models.DocumentUpdateRequest{}, Response: models.Document{}},
+
+	"GET /api/collections": {Tag: "documents", Summary: "List collections with document counts",
+		Response: Object{"collections": []models.CollectionSummary{}, "count": 0}},
+
+	"GET /api/faq": {Tag: "faq", Summary: "Published FAQ entries grouped by category, with an ETag for conditional requests",
+		Response: Object{"categories": []models.FAQCategory{}, "count": 0}},
+
+	"GET /api/widget/config": {Tag: "widget", Summary: "Widget configuration for the calling origin",
+		Response: models.PublicWidgetConfig{}},
+
+	// Sessions
+	"GET /api/sessions/:session_id/suggestions": {Tag: "sessions", Summary: "Suggested follow-up questions",
+		Response: Object{"session_id": "", "suggestions": []string{}}},
+	"POST /api/sessions/:session_id/outcome": {Tag: "sessions", Summary: "Record how a session ended",
+		Request: models.SessionOutcomeRequest{}, Response: models.Session{}},
+	"GET /api/sessions/:session_id/export": {Tag: "sessions", Summary: "Download a session transcript", Auth: true,
+		Query: []param{
+			{Name: "format", Type: "string", Description: "json, markdown or txt"},
+			{Name: "include_sources", Type: "boolean", Description: "Include retrieved context"},
+			{Name: "segment", Type: "integer", Description: "Only this conversation segment; a new one starts after SESSION_IDLE_MINUTES idle"},
+		},
+		ContentType: "text/markdown", Response: ""},
+	"POST /api/sessions/:session_id/claim": {Tag: "sessions", Summary: "Link an anonymous session and its history to the caller, with the claim token from its first answer", Auth: true,
+		Request: models.SessionClaimRequest{}, Response: models.SessionClaim{}},
+
+	// Search
+	"GET /api/search": {Tag: "sessions", Summary: "Search past conversations, best match first, with highlighted snippets", Auth: true,
+		Query: []param{
+			{Name: "q", Type: "string", Description: "Search text; quoted phrases, or and -exclusions are supported", Required: true},
+			{Name: "from", Type: "string", Description: "Earliest query time, RFC 3339 or YYYY-MM-DD"},
+			{Name: "to", Type: "string", Description: "Latest query time (exclusive), RFC 3339 or YYYY-MM-DD"},
+			{Name: "session_id", Type: "string", Description: "Only search this session"},
+			limitParam, offsetParam,
+		},
+		Response: Object{"results": []models.ConversationSearchResult{}, "count": 0, "total": 0, "limit": 0, "offset": 0}},
+
+	// Usage
+	"GET /api/usage": {Tag: "sessions", Summary: "The caller's plan, daily query quota usage and tokens used today", Auth: true,
+		Response: models.QuotaUsage{}},
+
+	// Billing
+	"GET /api/billing/usage": {Tag: "billing", Summary: "Daily AI usage per user with the period total, from usage records aggregated from chat queries; requires the admin role", Auth: true,
+		Query: usageParams, Response: models.UsageReport{}},
+	"GET /api/billing/usage/export": {Tag: "billing", Summary: "Download daily AI usage per user as CSV; requires the admin role", Auth: true,
+		Query: usageParams, ContentType: "text/csv", Response: ""},
+	"GET /api/billing/usage/verify": {Tag: "billing", Summary: "Recompute usage from chat queries and list usage records that differ; requires the admin role", Auth: true,
+		Query: usageParams, Response: models.UsageVerification{}},
+
+	// Admin: webhooks
+	"GET /api/admin/webhooks": {Tag: "admin", Summary: "List webhook subscriptions",
+		Response: Object{"webhooks": []models.WebhookSubscription{}, "count": 0}},
+	"POST /api/admin/webhooks": {Tag: "admin", Summary: "Create a webhook subscription",
+		Request: models.WebhookSubscriptionRequest{}, Status: 201, Response: models.WebhookSubscription{}},
+	"GET /api/admin/webhooks/:id": {Tag: "admin", Summary: "Get a webhook subscription",
+		Response: models.WebhookSubscription{}},
+	"PUT /api/admin/webhooks/:id": {Tag: "admin", Summary: "Update a webhook subscription",
+		Request: models.WebhookSubscriptionRequest{}, Response: models.WebhookSubscription{}},
+	"DELETE /api/admin/webhooks/:id": {Tag: "admin", Summary: "Delete a webhook subscription",
+		Response: deleted("id")},
+	"GET /api/admin/webhooks/:id/deliveries": {Tag: "admin", Summary: "Recent deliveries to a webhook", Query: []param{limitParam},
+		Response: Object{"deliveries": []models.WebhookDelivery{}, "count": 0}},
+
+	// Admin: FAQ
+	"GET /api/admin/faq": {Tag: "admin", Summary: "List FAQ entries, published or not, by category and position",
+		Response: Object{"entries": []models.FAQEntry{}, "count": 0}},
+	"POST /api/admin/faq": {Tag: "admin", Summary: "Create a FAQ entry at the end of its category",
+		Request: models.FAQEntryRequest{}, Status: 201, Response: models.FAQEntry{}},
+	"POST /api/admin/faq/from-query/:query_id": {Tag: "admin", Summary: "Draft an unpublished FAQ entry from a user question and its answer",
+		Status: 201, Response: models.FAQEntry{}},
+	"POST /api/admin/faq/reorder": {Tag: "admin", Summary: "Set the order of every entry in a category",
+		Request: models.FAQReorderRequest{}, Response: Object{"entries": []models.FAQEntry{}, "count": 0}},
+	"GET /api/admin/faq/:id": {Tag: "admin", Summary: "Get a FAQ entry",
+		Response: models.FAQEntry{}},
+	"PUT /api/admin/faq/:id": {Tag: "admin", Summary: "Update a FAQ entry; publishing with as_canned_answer also answers exact repeats of the question",
+		Request: models.FAQEntryRequest{}, Response: models.FAQEntry{}},
+	"DELETE /api/admin/faq/:id": {Tag: "admin", Summary: "Delete a FAQ entry and its canned answer",
+		Response: deleted("id")},
+
+	// Admin: query debugging
+	"GET /api/admin/queries/:id/debug": {Tag: "admin", Summary: "Export everything known about a query, with personal data redacted; each access is audited",
+		Query:    []param{{Name: "format", Type: "string", Description: "json or markdown"}},
+		Response: models.QueryDebugBundle{}},
+
+	// Admin: canned answers
+	"GET /api/admin/answers": {Tag: "admin", Summary: "List canned answers",
+		Response: Object{"answers": []models.CannedAnswer{}, "count": 0}},
+	"POST /api/admin/answers": {Tag: "admin", Summary: "Create a canned answer",
+		Request: models.CannedAnswerRequest{}, Status: 201, Response: models.CannedAnswer{}},
+	"GET /api/admin/answers/:id": {Tag: "admin", Summary: "Get a canned answer",
+		Response: models.CannedAnswer{}},
+	"PUT /api/admin/answers/:id": {Tag: "admin", Summary: "Update a canned answer",
+		Request: models.CannedAnswerRequest{}, Response: models.CannedAnswer{}},
+	"DELETE /api/admin/answers/:id": {Tag: "admin", Summary: "Delete a canned answer",
+		Response: deleted("id")},
+
+	// Admin: model routing rules
+	"GET /api/admin/routing-rules": {Tag: "admin", Summary: "List model routing rules in evaluation order",
+		Response: Object{"rules": []models.ModelRoutingRule{}, "count": 0}},
+	"POST /api/admin/routing-rules": {Tag: "admin", Summary: "Create a model routing rule",
+		Request: models.ModelRoutingRuleRequest{}, Status: 201, Response: models.ModelRoutingRule{}},
+	"POST /api/admin/routing-rules/test": {Tag: "admin", Summary: "Dry-run the routing rules against a sample query and return the rule that would fire",
+		Request: models.RoutingTestRequest{}, Response: models.RoutingDecision{}},
+	"GET /api/admin/routing-rules/:id": {Tag: "admin", Summary: "Get a model routing rule",
+		Response: models.ModelRoutingRule{}},
+	"PUT /api/admin/routing-rules/:id": {Tag: "admin", Summary: "Update a model routing rule",
+		Request: models.ModelRoutingRuleRequest{}, Response: models.ModelRoutingRule{}},
+	"DELETE /api/admin/routing-rules/:id": {Tag: "admin", Summary: "Delete a model routing rule",
+		Response: deleted("id")},
+
+	// Admin: analytics segments
+	"GET /api/admin/segments": {Tag: "admin", Summary: "List analytics segments",
+		Response: Object{"segments": []models.AnalyticsSegment{}, "count": 0}},
+	"POST /api/admin/segments": {Tag: "admin", Summary: "Create an analytics segment from user IDs, plans, metadata matchers and a date range",
+		Request: models.AnalyticsSegmentRequest{}, Status: 201, Response: models.AnalyticsSegment{}},
+	"GET /api/admin/segments/:id": {Tag: "admin", Summary: "Get an analytics segment",
+		Response: models.AnalyticsSegment{}},
+	"PUT /api/admin/segments/:id": {Tag: "admin", Summary: "Update an analytics segment",
+		Request: models.AnalyticsSegmentRequest{}, Response: models.AnalyticsSegment{}},
+	"DELETE /api/admin/segments/:id": {Tag: "admin", Summary: "Delete an analytics segment",
+		Response: deleted("id")},
+
+	// Admin: personas
+	"GET /api/admin/personas": {Tag: "admin", Summary: "List personas",
+		Response: Object{"personas": []models.Persona{}, "count": 0}},
+	"POST /api/admin/personas": {Tag: "admin", Summary: "Create a persona from a system prompt snippet, a tone and a signature line",
+		Request: models.PersonaRequest{}, Status: 201, Response: models.Persona{}},
+	"GET /api/admin/personas/:id": {Tag: "admin", Summary: "Get a persona",
+		Response: models.Persona{}},
+	"PUT /api/admin/personas/:id": {Tag: "admin", Summary: "Update a persona, bumping its version",
+		Request: models.PersonaRequest{}, Response: models.Persona{}},
+	"DELETE /api/admin/personas/:id": {Tag: "admin", Summary: "Delete a persona and remove it from the widget configs using it",
+		Response: deleted("id")},
+	"POST /api/admin/personas/:id/preview": {Tag: "admin", Summary: "Answer a sample question in a persona without storing anything",
+		Request: models.PersonaPreviewRequest{}, Response: models.PersonaPreview{}},
+
+	// Admin: settings
+	"GET /api/admin/settings": {Tag: "admin", Summary: "Runtime settings and their sources",
+		Response: Object{"settings": []models.SettingValue{}, "count": 0}},
+	"PUT /api/admin/settings": {Tag: "admin", Summary: "Override runtime settings",
+		Request: map[string]string{}, Response: Object{"settings": []models.SettingValue{}, "count": 0}},
+	"DELETE /api/admin/settings/:key": {Tag: "admin", Summary: "Reset a setting to its environment value",
+		Response: Object{"message": "", "key": ""}},
+	"GET /api/admin/flags": {Tag: "admin", Summary: "Feature flags; a disabled feature answers 503 feature_disabled",
+		Response: Object{"flags": []models.FeatureFlagState{}, "count": 0}},
+	"PUT /api/admin/flags": {Tag: "admin", Summary: "Switch features on or off, with an optional message for clients",
+		Request: map[string]models.FeatureFlagUpdate{}, Response: Object{"flags": []models.FeatureFlagState{}, "count": 0}},
+	"GET /api/admin/log-level": {Tag: "admin", Summary: "Current log level",
+		Response: Object{"level": ""}},
+	"PUT /api/admin/log-level": {Tag: "admin", Summary: "Change the log level on every instance",
+		Request: models.LogLevelRequest{}, Response: Object{"level": ""}},
+
+	"GET /api/admin/retrieval-feedback": {Tag: "admin", Summary: "Bad retrieval reports sent to the RAG service",
+		Query:    []param{limitParam, {Name: "status", Type: "string", Description: "pending, delivered or failed"}},
+		Response: Object{"retrieval_feedback": []models.RetrievalFeedback{}, "count": 0}},
+
+	// Admin: bans
+	"GET /api/admin/bans": {Tag: "admin", Summary: "Active abuse bans",
+		Response: Object{"bans": []abuse.Ban{}, "count": 0}},
+	"DELETE /api/admin/bans/:kind/:value": {Tag: "admin", Summary: "Lift a ban",
+		Response: Object{"message": "", "kind": "", "value": ""}},
+
+	// Admin: widgets
+	"GET /api/admin/widgets": {Tag: "admin", Summary: "List widget configurations",
+		Response: Object{"widgets": []models.WidgetConfig{}, "count": 0}},
+	"POST /api/admin/widgets": {Tag: "admin", Summary: "Create a widget configuration",
+		Request: models.WidgetConfigRequest{}, Status: 201, Response: models.WidgetConfig{}},
+	"GET /api/admin/widgets/:id": {Tag: "admin", Summary: "Get a widget configuration",
+		Response: models.WidgetConfig{}},
+	"PUT /api/admin/widgets/:id": {Tag: "admin", Summary: "Update a widget configuration",
+		Request: models.WidgetConfigRequest{}, Response: models.WidgetConfig{}},
+	"DELETE /api/admin/widgets/:id": {Tag: "admin", Summary: "Delete a widget configuration",
+		Response: deleted("id")},
+
+	"GET /api/admin/dashboard": {Tag: "admin", Summary: "Operational summary for the admin dashboard",
+		Response: models.DashboardSummary{}},
+	"GET /api/admin/sessions/active": {Tag: "admin", Summary: "Sessions with recent activity, most recently active first",
+		Query:    []param{{Name: "minutes", Type: "integer", Description: "Activity window in minutes, default 15"}, limitParam},
+		Response: Object{"sessions": []models.ActiveSession{}, "count": 0, "minutes": 0}},
+	"GET /api/admin/activity/stream": {Tag: "admin", Summary: "Stream queries and feedback from all instances as server-sent events, with a dropped event when the client falls behind",
+		ContentType: "text/event-stream", Response: ""},
+
+	// Admin: answer annotations
+	"GET /api/admin/annotations": {Tag: "admin", Summary: "List answer annotations with their original queries, most recent first",
+		Query: []param{
+			{Name: "status", Type: "string", Description: "draft or approved"},
+			{Name: "query_id", Type: "integer", Description: "Only annotations of this query"},
+			limitParam,
+		},
+		Response: Object{"annotations": []models.Annotation{}, "count": 0}},
+	"POST /api/admin/annotations": {Tag: "admin", Summary: "Draft a corrected answer to a query",
+		Request: models.AnnotationRequest{}, Status: 201, Response: models.Annotation{}},
+	"POST /api/admin/annotations/:id/approve": {Tag: "admin", Summary: "Approve a correction: ingest it as a correction document, evict the cached answer and serve it for exact repeats of the question",
+		Response: models.Annotation{}},
+
+	// Admin: prompt templates
+	"GET /api/admin/prompts": {Tag: "admin", Summary: "List prompt templates",
+		Response: Object{"prompts": []models.PromptTemplate{}, "count": 0}},
+	"POST /api/admin/prompts": {Tag: "admin", Summary: "Create a prompt template",
+		Request: models.PromptTemplateRequest{}, Status: 201, Response: models.PromptTemplate{}},
+	"GET /api/admin/prompts/:id": {Tag: "admin", Summary: "Get a prompt template",
+		Response: models.PromptTemplate{}},
+	"PUT /api/admin/prompts/:id": {Tag: "admin", Summary: "Update a prompt template",
+		Request: models.PromptTemplateRequest{}, Response: models.PromptTemplate{}},
+	"DELETE /api/admin/prompts/:id": {Tag: "admin", Summary: "Delete a prompt template",
+		Response: deleted("id")},
+	"POST /api/admin/prompts/:id/activate": {Tag: "admin", Summary: "Make a prompt template the active one",
+		Response: models.PromptTemplate{}},
+
+	// Admin: experiments
+	"GET /api/admin/experiments": {Tag: "admin", Summary: "List experiments",
+		Response: Object{"experiments": []models.Experiment{}, "count": 0}},
+	"POST /api/admin/experiments": {Tag: "admin", Summary: "Create an experiment",
+		Request: models.ExperimentRequest{}, Status: 201, Response: models.Experiment{}},
+	"GET /api/admin/experiments/:id": {Tag: "admin", Summary: "Get an experiment",
+		Response: models.Experiment{}},
+	"PUT /api/admin/experiments/:id": {Tag: "admin", Summary: "Update an experiment",
+		Request: models.ExperimentRequest{}, Response: models.Experiment{}},
+	"DELETE /api/admin/experiments/:id": {Tag: "admin", Summary: "Delete an experiment",
+		Response: deleted("id")},
+	"GET /api/admin/experiments/:id/results": {Tag: "admin", Summary: "Per-variant experiment results",
+		Response: models.ExperimentResults{}},
+
+	"GET /api/admin/audit": {Tag: "admin", Summary: "Audit log of admin changes",
+		Query: []param{limitParam, offsetParam,
+			{Name: "actor", Type: "string", Description: "Only changes by this user"},
+			{Name: "action", Type: "string", Description: "Only this action"},
+			{Name: "resource_type", Type: "string", Description: "Only this resource type"},
+			{Name: "resource_id", Type: "string", Description: "Only this resource"},
+			{Name: "from", Type: "string", Description: "RFC 3339 start time"},
+			{Name: "to", Type: "string", Description: "RFC 3339 end time"},
+		},
+		Response: Object{"entries": []models.AuditLog{}, "count": 0, "total": int64(0), "limit": 0, "offset": 0}},
+
+	// Admin: quota overrides
+	"GET /api/admin/quota-overrides": {Tag: "admin", Summary: "List unexpired quota overrides, soonest to expire first",
+		Response: Object{"overrides": []models.QuotaOverride{}, "count": 0}},
+	"PUT /api/admin/quota-overrides/:user_id": {Tag: "admin", Summary: "Replace a user's daily query limit until expires_at; 0 is unlimited",
+		Request: models.QuotaOverrideRequest{}, Response: models.QuotaOverride{}},
+	"DELETE /api/admin/quota-overrides/:user_id": {Tag: "admin", Summary: "Return a user to their plan's daily query limit",
+		Response: Object{"message": "", "user_id": ""}},
+
+	// Admin: chat history purges
+	"POST /api/admin/purge": {Tag: "admin", Summary: "Dry-run a purge of chat history by sessions, user or time range, then confirm the dry run by dry_run_id to delete in the background (202)",
+		Request: models.PurgeRequest{}, Response: models.PurgeJob{}},
+	"GET /api/admin/purge/:id": {Tag: "admin", Summary: "Get a purge job with its counts and progress",
+		Response: models.PurgeJob{}},
+
+	// Admin: knowledge gap reports
+	"POST /api/admin/knowledge-gaps/generate": {Tag: "admin", Summary: "Cluster the last window's low-confidence, context-less and thumbs-down questions into a knowledge gap report in the background (202)",
+		Status: 202, Response: models.KnowledgeGapReport{}},
+	"GET /api/admin/knowledge-gaps": {Tag: "admin", Summary: "List knowledge gap reports, newest first",
+		Response: Object{"reports": []models.KnowledgeGapReport{}, "count": 0}},
+	"GET /api/admin/knowledge-gaps/:id": {Tag: "admin", Summary: "Get a knowledge gap report with its clusters, most frequent first",
+		Response: models.KnowledgeGapReport{}},
+
+	// Admin: shadow tests
+	"POST /api/admin/shadow-test": {Tag: "admin", Summary: "Replay a random sample of historical queries against the current and a candidate RAG service in the background (202)",
+		Request: models.ShadowTestRequest{}, Status: 202, Response: models.ShadowRun{}},
+	"GET /api/admin/shadow-test/:id": {Tag: "admin", Summary: "Get a shadow run with its averages and a page of per-query comparisons",
+		Query:    []param{limitParam, offsetParam},
+		Response: Object{"run": models.ShadowRun{}, "comparisons": []models.ShadowComparison{}, "count": 0, "total": 0, "limit": 0, "offset": 0}},
+	"DELETE /api/admin/shadow-test/:id": {Tag: "admin", Summary: "Cancel a running shadow test, keeping the comparisons made so far",
+		Response: models.ShadowRun{}},
+
+	"POST /api/admin/docs/reingest-all": {Tag: "admin", Summary: "Re-ingest every stored document, optionally of a collection or status, in the background (202); documents whose text wasn't kept are skipped",
+		Request: models.ReingestRequest{}, Status: 202, Response: models.ReingestJob{}},
+	"GET /api/admin/docs/reingest-jobs/:id": {Tag: "admin", Summary: "Get a re-ingestion job with its progress and a page of its documents",
+		Query:    []param{limitParam, offsetParam, {Name: "status", Type: "string", Description: "pending, in_flight, completed, failed or skipped"}},
+		Response: Object{"job": models.ReingestJob{}, "documents": []models.ReingestItem{}, "count": 0, "total": 0, "limit": 0, "offset": 0}},
+	"DELETE /api/admin/docs/reingest-jobs/:id": {Tag: "admin", Summary: "Cancel a running re-ingestion job; documents not yet started stay pending",
+		Response: models.ReingestJob{}},
+
+	// Admin: reports
+	"POST /api/admin/reports/generate": {Tag: "admin", Summary: "Generate an analytics report",
+		Request: models.ReportRequest{}, Status: 201, Response: models.Report{}},
+	"GET /api/admin/reports/:id": {Tag: "admin", Summary: "Get a report; format=html renders it",
+		Query:    []param{{Name: "format", Type: "string", Description: "html to render the report"}},
+		Response: models.Report{}},
+
+	// Admin: runtime
+	"GET /api/admin/runtime": {Tag: "admin", Summary: "Rate limit buckets, cache size, breakers and queue depths",
+		Query:    []param{{Name: "limit", Type: "integer", Description: "Number of rate limit buckets, fullest first (max 100)"}},
+		Response: models.RuntimeState{}},
+	"POST /api/admin/runtime/reset-ratelimit/:key": {Tag: "admin", Summary: "Clear a rate limit bucket, keyed policy:subject",
+		Response: Object{"message": "", "bucket": models.RateLimitBucket{}}},
+
+	// Admin: configuration bundles
+	"GET /api/admin/export": {Tag: "admin", Summary: "Canned answers, prompt templates, widget configs and setting overrides as a bundle",
+		Response: models.ConfigBundle{}},
+	"POST /api/admin/import": {Tag: "admin", Summary: "Import a bundle; conflicts map resource types to skip (default) or overwrite",
+		Query:   []param{{Name: "dry_run", Type: "boolean", Description: "Return the changes without applying them"}},
+		Request: models.ConfigImportRequest{}, Response: models.ConfigImportResult{}},
+
+	// Admin: dead letters
+	"GET /api/admin/dead-letters": {Tag: "admin", Summary: "Async operations that failed after exhausting their retries",
+		Query: []param{limitParam, offsetParam,
+			{Name: "operation", Type: "string", Description: "Only this operation, e.g. webhook.delivery"},
+			{Name: "status", Type: "string", Description: "pending or replayed"},
+		},
+		Response: Object{"dead_letters": []models.DeadLetter{}, "count": 0, "total": 0, "limit": 0, "offset": 0}},
+	"POST /api/admin/dead-letters/:id/replay": {Tag: "admin", Summary: "Re-enqueue a dead-lettered operation with its attempts reset",
+		Response: Object{"message": "", "dead_letter": models.DeadLetter{}}},
+
+	// Admin: cache warm-up
+	"POST /api/admin/cache/warmup": {Tag: "admin", Summary: "Start preloading the cache with the most asked queries of the last week",
+		Response: models.CacheWarmupResult{}},
+	"GET /api/admin/cache/warmup": {Tag: "admin", Summary: "Progress and outcome of the latest cache warm-up",
+		Response: models.CacheWarmupResult{}},
+
+	// Admin: chaos mode, only routed when CHAOS_MODE is on
+	"POST /api/admin/chaos/faults": {Tag: "admin", Summary: "Inject a fault into calls to the RAG service, Redis or the database check until it expires",
+		Request: chaos.FaultRequest{}, Status: 201, Response: &chaos.Fault{}},
+	"GET /api/admin/chaos/faults": {Tag: "admin", Summary: "Active chaos faults on this instance",
+		Response: Object{"faults": []*chaos.Fault{}, "count": 0}},
+	"DELETE /api/admin/chaos/faults/:id": {Tag: "admin", Summary: "Remove a chaos fault",
+		Response: Object{"message": "", "id": ""}},
+}
@@ -0,0 +1,254 @@
+// Package apidocs builds the OpenAPI specification of the HTTP API from the
+// registered gin routes and the models they exchange.
+package apidocs
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// Document is an OpenAPI 3.0 document
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components Components                      `json:"components"`
+}
+
+// Info describes the API
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// Components holds schemas and security schemes shared by operations
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+// SecurityScheme describes how requests authenticate
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// Operation describes one method on a path
+type Operation struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary"`
+	OperationID string                `json:"operationId"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter is a path or query parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes an operation's request body by content type
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes a response by content type
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType gives the schema of a body
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// bearerAuth names the JWT security scheme
+const bearerAuth = "bearerAuth"
+
+// Build describes every route. Routes missing from the endpoint table are
+// still listed, with a generic operation, so the spec never omits a route.
+func Build(routes gin.RoutesInfo, version string) *Document {
+	registry := newSchemaRegistry()
+	errorSchema := registry.schemaOf(models.ErrorResponse{})
+
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info:    Info{Title: "AI Support Assistant API", Version: version},
+		Paths:   make(map[string]map[string]Operation),
+	}
+
+	for _, route := range sortedRoutes(routes) {
+		path, pathParams := openAPIPath(route.Path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]Operation)
+		}
+
+		spec, ok := endpoints[routeKey(route.Method, route.Path)]
+		if !ok {
+			spec = endpoint{Summary: "Undocumented", Response: Object{}}
+		}
+
+		op := Operation{
+			Summary:     spec.Summary,
+			OperationID: operationID(route.Method, route.Path, route.Handler),
+			Responses:   make(map[string]Response),
+		}
+		if spec.Tag != "" {
+			op.Tags = []string{spec.Tag}
+		}
+
+		for _, name := range pathParams {
+			op.Parameters = append(op.Parameters, Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+		for _, p := range spec.Query {
+			op.Parameters = append(op.Parameters, Parameter{Name: p.Name, In: "query", Description: p.Description, Required: p.Required, Schema: &Schema{Type: p.Type}})
+		}
+
+		if spec.Request != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]MediaType{"application/json": {Schema: registry.schemaOf(spec.Request)}},
+			}
+		} else if len(spec.Form) > 0 {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]MediaType{"multipart/form-data": {Schema: formSchema(spec.Form)}},
+			}
+		}
+
+		status := spec.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		contentType := spec.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		op.Responses[strconv.Itoa(status)] = Response{
+			Description: http.StatusText(status),
+			Content:     map[string]MediaType{contentType: {Schema: registry.schemaOf(spec.Response)}},
+		}
+		if spec.Accepted != nil {
+			op.Responses[strconv.Itoa(http.StatusAccepted)] = Response{
+				Description: http.StatusText(http.StatusAccepted),
+				Content:     map[string]MediaType{"application/json": {Schema: registry.schemaOf(spec.Accepted)}},
+			}
+		}
+		op.Responses["default"] = Response{
+			Description: "Error",
+			Content:     map[string]MediaType{"application/json": {Schema: errorSchema}},
+		}
+
+		if spec.Auth || strings.HasPrefix(route.Path, "/api/admin/") {
+			op.Security = []map[string][]string{{bearerAuth: {}}}
+		}
+
+		doc.Paths[path][strings.ToLower(route.Method)] = op
+	}
+
+	doc.Components = Components{
+		Schemas: registry.schemas,
+		SecuritySchemes: map[string]SecurityScheme{
+			bearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+		},
+	}
+	return doc
+}
+
+// Undocumented returns the routes missing from the endpoint table, as
+// "METHOD /path"
+func Undocumented(routes gin.RoutesInfo) []string {
+	var missing []string
+	for _, route := range sortedRoutes(routes) {
+		key := routeKey(route.Method, route.Path)
+		if _, ok := endpoints[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
+
+func routeKey(method, path string) string {
+	return method + " " + path
+}
+
+// sortedRoutes orders routes by path and method. The OPTIONS route answering
+// CORS preflights isn't an API operation and is left out.
+func sortedRoutes(routes gin.RoutesInfo) gin.RoutesInfo {
+	var sorted gin.RoutesInfo
+	for _, route := range routes {
+		if route.Method != http.MethodOptions {
+			sorted = append(sorted, route)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Path != sorted[j].Path {
+			return sorted[i].Path < sorted[j].Path
+		}
+		return sorted[i].Method < sorted[j].Method
+	})
+	return sorted
+}
+
+// openAPIPath converts gin's :param and *param segments to {param},
+// returning the parameter names
+func openAPIPath(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	var params []string
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			params = append(params, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// operationID derives an ID from the handler name, e.g. Query from
+// ".../handlers.(*QueryHandler).HandleQuery-fm", falling back to the method
+// and path for inline handlers
+func operationID(method, path, handler string) string {
+	name := strings.TrimSuffix(handler[strings.LastIndex(handler, ".")+1:], "-fm")
+	if strings.HasPrefix(name, "Handle") {
+		return strings.TrimPrefix(name, "Handle")
+	}
+
+	id := strings.ToLower(method)
+	for _, segment := range strings.Split(path, "/") {
+		segment = strings.Trim(segment, ":*")
+		for _, word := range strings.FieldsFunc(segment, func(r rune) bool { return r == '-' || r == '_' || r == '.' }) {
+			id += strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return id
+}
+
+// formSchema describes multipart form fields
+func formSchema(fields []param) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for _, field := range fields {
+		if field.Type == "file" {
+			schema.Properties[field.Name] = &Schema{Type: "string", Format: "binary", Description: field.Description}
+		} else {
+			schema.Properties[field.Name] = &Schema{Type: field.Type, Description: field.Description}
+		}
+		if field.Required {
+			schema.Required = append(schema.Required, field.Name)
+		}
+	}
+	return schema
+}
@@ -0,0 +1,43 @@
+package apidocs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOpenAPIPath(t *testing.T) {
+	tests := []struct {
+		path   string
+		want   string
+		params []string
+	}{
+		{"/api/feedback", "/api/feedback", nil},
+		{"/api/docs/:id", "/api/docs/{id}", []string{"id"}},
+		{"/api/query/:query_id/regenerate", "/api/query/{query_id}/regenerate", []string{"query_id"}},
+		{"/widget/*filepath", "/widget/{filepath}", []string{"filepath"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, params := openAPIPath(tt.path)
+			if got != tt.want || strings.Join(params, ",") != strings.Join(tt.params, ",") {
+				t.Errorf("openAPIPath = %q %v, want %q %v", got, params, tt.want, tt.params)
+			}
+		})
+	}
+}
+
+func TestOperationID(t *testing.T) {
+	tests := []struct {
+		method, path, handler string
+		want                  string
+	}{
+		{"POST", "/api/query", "github.com/ai-support-assistant/backend/internal/handlers.(*QueryHandler).HandleQuery-fm", "Query"},
+		{"GET", "/api/admin/dead-letters/:id", "main.setupRoutes.func1", "getApiAdminDeadLettersId"},
+		{"GET", "/api/health/ready", "main.setupRoutes.func2", "getApiHealthReady"},
+	}
+	for _, tt := range tests {
+		if got := operationID(tt.method, tt.path, tt.handler); got != tt.want {
+			t.Errorf("operationID(%s %s) = %q, want %q", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package apidocs
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is an OpenAPI 3.0 schema object
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+}
+
+// Object describes an ad hoc JSON object, such as a gin.H response, by
+// example values keyed by field name
+type Object map[string]interface{}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	durationType   = reflect.TypeOf(time.Duration(0))
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	objectType     = reflect.TypeOf(Object{})
+)
+
+// schemaRegistry builds schemas for Go values, collecting named struct types
+// as shared components
+type schemaRegistry struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+	taken   map[string]reflect.Type
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{
+		schemas: make(map[string]*Schema),
+		names:   make(map[reflect.Type]string),
+		taken:   make(map[string]reflect.Type),
+	}
+}
+
+// schemaOf returns the schema for an example value. Object values become
+// inline objects; named structs become references to components.
+func (r *schemaRegistry) schemaOf(value interface{}) *Schema {
+	if object, ok := value.(Object); ok {
+		schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		for name, field := range object {
+			schema.Properties[name] = r.schemaOf(field)
+			schema.Required = append(schema.Required, name)
+		}
+		sort.Strings(schema.Required)
+		return schema
+	}
+	if value == nil {
+		return &Schema{}
+	}
+	return r.schemaFor(reflect.TypeOf(value))
+}
+
+func (r *schemaRegistry) schemaFor(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &Schema{Type: "integer", Format: "int64", Description: "nanoseconds"}
+	case rawMessageType:
+		return &Schema{}
+	case objectType:
+		return &Schema{Type: "object"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := r.schemaFor(t.Elem())
+		if schema.Ref != "" {
+			return schema
+		}
+		schema.Nullable = true
+		return schema
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: r.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + r.component(t)}
+	default:
+		// interface{} and anything else accepts any JSON value
+		return &Schema{}
+	}
+}
+
+// component registers a named struct, returning its component name. Names
+// are the Go type name, qualified by package only when two types collide.
+func (r *schemaRegistry) component(t reflect.Type) string {
+	if name, ok := r.names[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if other, ok := r.taken[name]; ok && other != t {
+		pkg := t.PkgPath()
+		pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	r.names[t] = name
+	r.taken[name] = t
+
+	// Registered before recursing so self-referencing types terminate
+	r.schemas[name] = &Schema{}
+	*r.schemas[name] = *r.structSchema(t)
+	return name
+}
+
+// structSchema describes a struct's JSON fields, applying binding tags as
+// validation constraints
+func (r *schemaRegistry) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	r.addFields(schema, t)
+	sort.Strings(schema.Required)
+	return schema
+}
+
+func (r *schemaRegistry) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		// Embedded structs without a JSON name are flattened, as encoding/json does
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				r.addFields(schema, embedded)
+				continue
+			}
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		property := r.schemaFor(field.Type)
+		required := applyBinding(property, field.Type, field.Tag.Get("binding"))
+		if property.Ref != "" {
+			// Siblings of $ref are ignored, so constraints can't be attached
+			property = &Schema{Ref: property.Ref}
+		}
+		schema.Properties[name] = property
+
+		// Request bodies declare required fields with binding tags; response
+		// fields are always present unless omitted when empty
+		if required || (!isRequestBody(t) && !strings.Contains(opts, "omitempty") && field.Type.Kind() != reflect.Ptr) {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// isRequestBody reports whether t is validated on binding, i.e. any of its
+// fields has a binding tag
+func isRequestBody(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("binding") != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// applyBinding translates validator tags into schema constraints, returning
+// whether the field is required. Rules after dive apply to elements and are
+// not translated.
+func applyBinding(schema *Schema, t reflect.Type, binding string) bool {
+	if binding == "" {
+		return false
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	required := false
+	for _, rule := range strings.Split(binding, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "dive":
+			return required
+		case "required":
+			required = true
+		case "url":
+			schema.Format = "uri"
+		case "oneof":
+			for _, option := range strings.Fields(arg) {
+				if n, err := strconv.ParseFloat(option, 64); err == nil && schema.Type != "string" {
+					schema.Enum = append(schema.Enum, n)
+				} else {
+					schema.Enum = append(schema.Enum, option)
+				}
+			}
+		case "min", "max":
+			n, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				continue
+			}
+			setBound(schema, t, name == "min", n)
+		}
+	}
+	return required
+}
+
+// setBound sets the length, item count or value bound matching t's kind
+func setBound(schema *Schema, t reflect.Type, lower bool, n float64) {
+	count := int(n)
+	switch t.Kind() {
+	case reflect.String:
+		if lower {
+			schema.MinLength = &count
+		} else {
+			schema.MaxLength = &count
+		}
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if lower {
+			schema.MinItems = &count
+		} else {
+			schema.MaxItems = &count
+		}
+	default:
+		if lower {
+			schema.Minimum = &n
+		} else {
+			schema.Maximum = &n
+		}
+	}
+}
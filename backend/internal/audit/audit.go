@@ -0,0 +1,107 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// Actor identifies who performed an admin request
+type Actor struct {
+	UserID    string
+	RequestID string
+	IP        string
+}
+
+type actorKey struct{}
+
+// ignoredFields are bookkeeping fields left out of diffs
+var ignoredFields = map[string]bool{"created_at": true, "updated_at": true}
+
+// WithActor returns a context carrying the request's actor
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor stored in ctx, or an anonymous actor
+func ActorFrom(ctx context.Context) Actor {
+	if actor, ok := ctx.Value(actorKey{}).(Actor); ok {
+		return actor
+	}
+	return Actor{UserID: "anonymous"}
+}
+
+// Record writes an audit entry for an action on a resource using tx, so it
+// commits or rolls back with the mutation it describes. Before and after are
+// the resource state around the change; either may be nil for creates and
+// deletes. A failure to record must fail the caller's transaction.
+func Record(ctx context.Context, tx *gorm.DB, action, resourceType, resourceID string, before, after interface{}) error {
+	changes, err := Diff(before, after)
+	if err != nil {
+		return fmt.Errorf("failed to diff audit entry: %w", err)
+	}
+
+	actor := ActorFrom(ctx)
+	entry := models.AuditLog{
+		ActorID:      actor.UserID,
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Changes:      changes,
+		RequestID:    actor.RequestID,
+		IP:           actor.IP,
+	}
+	if err := tx.Create(&entry).Error; err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// Diff returns the fields that differ between two JSON-encodable values
+func Diff(before, after interface{}) (models.AuditChanges, error) {
+	beforeFields, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := models.AuditChanges{}
+	for name, value := range beforeFields {
+		if !ignoredFields[name] && !reflect.DeepEqual(value, afterFields[name]) {
+			changes[name] = models.AuditChange{Before: value, After: afterFields[name]}
+		}
+	}
+	for name, value := range afterFields {
+		if _, seen := beforeFields[name]; !seen && !ignoredFields[name] {
+			changes[name] = models.AuditChange{After: value}
+		}
+	}
+
+	return changes, nil
+}
+
+// fields flattens a value into its top-level JSON fields
+func fields(value interface{}) (map[string]interface{}, error) {
+	if value == nil || (reflect.ValueOf(value).Kind() == reflect.Ptr && reflect.ValueOf(value).IsNil()) {
+		return map[string]interface{}{}, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]interface{}{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
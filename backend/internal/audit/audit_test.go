@@ -0,0 +1,218 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// recordingDriver is a database/sql driver that records the statements it is
+// given and fails those containing failOn. Inserts return an ID of 1.
+type recordingDriver struct {
+	mu         sync.Mutex
+	statements []string
+	failOn     string
+}
+
+func (d *recordingDriver) Open(string) (driver.Conn, error) { return &recordingConn{d}, nil }
+
+func (d *recordingDriver) record(statement string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.statements = append(d.statements, statement)
+	if d.failOn != "" && strings.Contains(statement, d.failOn) {
+		return errors.New("statement failed")
+	}
+	return nil
+}
+
+// log returns the statements recorded so far, joined by newlines
+func (d *recordingDriver) log() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return strings.Join(d.statements, "\n")
+}
+
+type recordingConn struct{ d *recordingDriver }
+
+func (c *recordingConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+func (c *recordingConn) Close() error { return nil }
+func (c *recordingConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+func (c *recordingConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return &recordingTx{c.d}, c.d.record("BEGIN")
+}
+func (c *recordingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(1), c.d.record(query)
+}
+func (c *recordingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.d.record(query); err != nil {
+		return nil, err
+	}
+	return &idRows{}, nil
+}
+
+type recordingTx struct{ d *recordingDriver }
+
+func (t *recordingTx) Commit() error   { return t.d.record("COMMIT") }
+func (t *recordingTx) Rollback() error { return t.d.record("ROLLBACK") }
+
+// idRows returns a single row with an id column
+type idRows struct{ done bool }
+
+func (r *idRows) Columns() []string { return []string{"id"} }
+func (r *idRows) Close() error      { return nil }
+func (r *idRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(1)
+	return nil
+}
+
+var registerOnce sync.Once
+var testDriver = &recordingDriver{}
+
+// openTestDB returns a Postgres-dialect gorm DB over the recording driver,
+// reset for the test
+func openTestDB(t *testing.T, failOn string) (*gorm.DB, *recordingDriver) {
+	t.Helper()
+	registerOnce.Do(func() { sql.Register("audit-recording", testDriver) })
+	testDriver.mu.Lock()
+	testDriver.statements, testDriver.failOn = nil, failOn
+	testDriver.mu.Unlock()
+
+	conn, err := sql.Open("audit-recording", "")
+	if err != nil {
+		t.Fatalf("failed to open driver: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{
+		Logger:                 logger.Discard,
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to open gorm: %v", err)
+	}
+	return db, testDriver
+}
+
+type setting struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func TestRecordCommitsWithMutation(t *testing.T) {
+	db, recorded := openTestDB(t, "")
+	ctx := WithActor(context.Background(), Actor{UserID: "admin-1", RequestID: "req-1", IP: "10.0.0.1"})
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("UPDATE settings SET value = 'b'").Error; err != nil {
+			return err
+		}
+		return Record(ctx, tx, "setting.update", "setting", "greeting", setting{"greeting", "a"}, setting{"greeting", "b"})
+	})
+	if err != nil {
+		t.Fatalf("transaction: %v", err)
+	}
+
+	log := recorded.log()
+	if !strings.Contains(log, `INSERT INTO "audit_logs"`) || !strings.HasSuffix(log, "COMMIT") {
+		t.Errorf("statements = %s, want the audit insert committed", log)
+	}
+}
+
+func TestRecordFailureRollsBackMutation(t *testing.T) {
+	db, recorded := openTestDB(t, `INSERT INTO "audit_logs"`)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM canned_answers WHERE id = 1").Error; err != nil {
+			return err
+		}
+		return Record(context.Background(), tx, "canned_answer.delete", "canned_answer", "1", setting{"k", "v"}, nil)
+	})
+	if err == nil {
+		t.Fatal("transaction succeeded although the audit entry couldn't be recorded")
+	}
+
+	log := recorded.log()
+	if !strings.HasSuffix(log, "ROLLBACK") || strings.Contains(log, "COMMIT") {
+		t.Errorf("statements = %s, want the mutation rolled back", log)
+	}
+}
+
+func TestAuditEntriesAreImmutable(t *testing.T) {
+	db, recorded := openTestDB(t, "")
+	entry := models.AuditLog{ID: 1, Action: "setting.update"}
+
+	if err := db.Model(&entry).Update("action", "tampered").Error; !errors.Is(err, models.ErrAuditImmutable) {
+		t.Errorf("update error = %v, want ErrAuditImmutable", err)
+	}
+	if err := db.Delete(&entry).Error; !errors.Is(err, models.ErrAuditImmutable) {
+		t.Errorf("delete error = %v, want ErrAuditImmutable", err)
+	}
+	if log := recorded.log(); strings.Contains(log, "audit_logs") {
+		t.Errorf("statements = %s, want none against audit_logs", log)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	type resource struct {
+		Name      string `json:"name"`
+		Enabled   bool   `json:"enabled"`
+		UpdatedAt string `json:"updated_at"`
+	}
+
+	changes, err := Diff(
+		resource{Name: "faq", Enabled: true, UpdatedAt: "monday"},
+		&resource{Name: "faq", Enabled: false, UpdatedAt: "tuesday"},
+	)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if len(changes) != 1 || changes["enabled"].Before != true || changes["enabled"].After != false {
+		t.Errorf("Diff = %+v, want only enabled changed", changes)
+	}
+
+	// Creates and deletes diff against nothing
+	var missing *resource
+	created, err := Diff(missing, resource{Name: "faq"})
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if created["name"].Before != nil || created["name"].After != "faq" {
+		t.Errorf("Diff on create = %+v", created)
+	}
+	deleted, err := Diff(resource{Name: "faq"}, nil)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if deleted["name"].Before != "faq" || deleted["name"].After != nil {
+		t.Errorf("Diff on delete = %+v", deleted)
+	}
+}
+
+func TestActorFrom(t *testing.T) {
+	if actor := ActorFrom(context.Background()); actor.UserID != "anonymous" {
+		t.Errorf("ActorFrom without actor = %+v, want anonymous", actor)
+	}
+	ctx := WithActor(context.Background(), Actor{UserID: "admin-1"})
+	if actor := ActorFrom(ctx); actor.UserID != "admin-1" {
+		t.Errorf("ActorFrom = %+v, want admin-1", actor)
+	}
+}
@@ -6,6 +6,9 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -52,6 +55,20 @@ func Set(ctx context.Context, key string, value interface{}, ttl time.Duration)
 	return Client.Set(ctx, key, data, ttl).Err()
 }
 
+// SetNX stores a value only if the key does not exist, returning whether it was set
+func SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	if Client == nil {
+		return false, fmt.Errorf("redis client is not initialized")
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal value: %w", err)
+	}
+
+	return Client.SetNX(ctx, key, data, ttl).Result()
+}
+
 // Get retrieves a value from Redis
 func Get(ctx context.Context, key string, dest interface{}) error {
 	if Client == nil {
@@ -75,6 +92,25 @@ func Delete(ctx context.Context, key string) error {
 	return Client.Del(ctx, key).Err()
 }
 
+// DeleteKeys deletes keys from Redis in batches
+func DeleteKeys(ctx context.Context, keys []string) error {
+	if Client == nil {
+		return fmt.Errorf("redis client is not initialized")
+	}
+
+	for start := 0; start < len(keys); start += 500 {
+		end := start + 500
+		if end > len(keys) {
+			end = len(keys)
+		}
+		if err := Client.Del(ctx, keys[start:end]...).Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Exists checks if a key exists in Redis
 func Exists(ctx context.Context, key string) (bool, error) {
 	if Client == nil {
@@ -94,6 +130,15 @@ func Increment(ctx context.Context, key string) (int64, error) {
 	return Client.Incr(ctx, key).Result()
 }
 
+// IncrementBy increments a counter by n
+func IncrementBy(ctx context.Context, key string, n int64) (int64, error) {
+	if Client == nil {
+		return 0, fmt.Errorf("redis client is not initialized")
+	}
+
+	return Client.IncrBy(ctx, key, n).Result()
+}
+
 // Expire sets TTL on a key
 func Expire(ctx context.Context, key string, ttl time.Duration) error {
 	if Client == nil {
@@ -103,6 +148,259 @@ func Expire(ctx context.Context, key string, ttl time.Duration) error {
 	return Client.Expire(ctx, key, ttl).Err()
 }
 
+// TTL returns the remaining time to live of a key
+func TTL(ctx context.Context, key string) (time.Duration, error) {
+	if Client == nil {
+		return 0, fmt.Errorf("redis client is not initialized")
+	}
+
+	return Client.TTL(ctx, key).Result()
+}
+
+// SetAdd adds a member to a set
+func SetAdd(ctx context.Context, key string, member string) error {
+	if Client == nil {
+		return fmt.Errorf("redis client is not initialized")
+	}
+
+	return Client.SAdd(ctx, key, member).Err()
+}
+
+// SetCard returns the number of members in a set
+func SetCard(ctx context.Context, key string) (int64, error) {
+	if Client == nil {
+		return 0, fmt.Errorf("redis client is not initialized")
+	}
+
+	return Client.SCard(ctx, key).Result()
+}
+
+// HashSet stores a JSON-encoded value in a field of a hash
+func HashSet(ctx context.Context, key, field string, value interface{}) error {
+	if Client == nil {
+		return fmt.Errorf("redis client is not initialized")
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value: %w", err)
+	}
+
+	return Client.HSet(ctx, key, field, data).Err()
+}
+
+// HashGetAll returns every field of a hash; a missing hash is empty
+func HashGetAll(ctx context.Context, key string) (map[string]string, error) {
+	if Client == nil {
+		return nil, fmt.Errorf("redis client is not initialized")
+	}
+
+	return Client.HGetAll(ctx, key).Result()
+}
+
+// ScanKeys returns all keys matching a pattern without blocking Redis
+func ScanKeys(ctx context.Context, pattern string) ([]string, error) {
+	if Client == nil {
+		return nil, fmt.Errorf("redis client is not initialized")
+	}
+
+	var keys []string
+	iter := Client.Scan(ctx, 0, pattern, 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+
+	return keys, iter.Err()
+}
+
+// ScanKeysBounded returns keys matching a pattern, giving up after
+// maxIterations SCAN calls so the cost is bounded on large keyspaces.
+// Complete is false when the scan was cut short.
+func ScanKeysBounded(ctx context.Context, pattern string, count int64, maxIterations int) (keys []string, complete bool, err error) {
+	if Client == nil {
+		return nil, false, fmt.Errorf("redis client is not initialized")
+	}
+
+	var cursor uint64
+	for i := 0; i < maxIterations; i++ {
+		var batch []string
+		batch, cursor, err = Client.Scan(ctx, cursor, pattern, count).Result()
+		if err != nil {
+			return keys, false, err
+		}
+		keys = append(keys, batch...)
+		if cursor == 0 {
+			return keys, true, nil
+		}
+	}
+
+	return keys, false, nil
+}
+
+// Counters returns the integer value and remaining TTL of each key in one
+// round trip. Missing keys have a count of 0.
+func Counters(ctx context.Context, keys []string) ([]int64, []time.Duration, error) {
+	if Client == nil {
+		return nil, nil, fmt.Errorf("redis client is not initialized")
+	}
+
+	pipe := Client.Pipeline()
+	gets := make([]*redis.StringCmd, len(keys))
+	ttls := make([]*redis.DurationCmd, len(keys))
+	for i, key := range keys {
+		gets[i] = pipe.Get(ctx, key)
+		ttls[i] = pipe.TTL(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, nil, err
+	}
+
+	counts := make([]int64, len(keys))
+	durations := make([]time.Duration, len(keys))
+	for i := range keys {
+		counts[i], _ = gets[i].Int64()
+		durations[i] = ttls[i].Val()
+	}
+
+	return counts, durations, nil
+}
+
+// decayedIncrementScript adds one to a decaying counter, stored as a hash of
+// its score and when, in Unix milliseconds, it was last updated. The score is
+// returned as a string since Redis truncates Lua numbers to integers.
+var decayedIncrementScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local values = redis.call('HMGET', KEYS[1], 'score', 'at')
+local score = tonumber(values[1]) or 0
+local at = tonumber(values[2]) or now
+if now > at then
+	score = score * math.pow(0.5, (now - at) / tonumber(ARGV[2]))
+end
+score = score + 1
+redis.call('HSET', KEYS[1], 'score', tostring(score), 'at', math.max(now, at))
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return tostring(score)
+`)
+
+// DecayedIncrement adds one to a counter that halves every halfLife and
+// returns its value at now. The counter expires once untouched for ttl.
+func DecayedIncrement(ctx context.Context, key string, now time.Time, halfLife, ttl time.Duration) (float64, error) {
+	if Client == nil {
+		return 0, fmt.Errorf("redis client is not initialized")
+	}
+
+	result, err := decayedIncrementScript.Run(ctx, Client, []string{key},
+		now.UnixMilli(), halfLife.Milliseconds(), ttl.Milliseconds()).Text()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(result, 64)
+}
+
+// DecayedCounter returns the value at now of a counter kept by
+// DecayedIncrement, without changing it. A missing counter is 0.
+func DecayedCounter(ctx context.Context, key string, now time.Time, halfLife time.Duration) (float64, error) {
+	if Client == nil {
+		return 0, fmt.Errorf("redis client is not initialized")
+	}
+
+	values, err := Client.HMGet(ctx, key, "score", "at").Result()
+	if err != nil {
+		return 0, err
+	}
+	rawScore, _ := values[0].(string)
+	rawAt, _ := values[1].(string)
+	score, err := strconv.ParseFloat(rawScore, 64)
+	if err != nil {
+		return 0, nil
+	}
+	at, err := strconv.ParseInt(rawAt, 10, 64)
+	if err != nil {
+		return score, nil
+	}
+
+	if elapsed := now.UnixMilli() - at; elapsed > 0 {
+		score *= math.Pow(0.5, float64(elapsed)/float64(halfLife.Milliseconds()))
+	}
+	return score, nil
+}
+
+// MemoryUsage returns the total bytes Redis reports for keys in one round trip
+func MemoryUsage(ctx context.Context, keys []string) (int64, error) {
+	if Client == nil {
+		return 0, fmt.Errorf("redis client is not initialized")
+	}
+
+	pipe := Client.Pipeline()
+	cmds := make([]*redis.IntCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.MemoryUsage(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, cmd := range cmds {
+		total += cmd.Val()
+	}
+
+	return total, nil
+}
+
+// DBSize returns the number of keys in the database
+func DBSize(ctx context.Context) (int64, error) {
+	if Client == nil {
+		return 0, fmt.Errorf("redis client is not initialized")
+	}
+
+	return Client.DBSize(ctx).Result()
+}
+
+// UsedMemory returns the bytes allocated by Redis, from INFO memory
+func UsedMemory(ctx context.Context) (int64, error) {
+	if Client == nil {
+		return 0, fmt.Errorf("redis client is not initialized")
+	}
+
+	info, err := Client.Info(ctx, "memory").Result()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, line := range strings.Split(info, "\r\n") {
+		if value, ok := strings.CutPrefix(line, "used_memory:"); ok {
+			return strconv.ParseInt(value, 10, 64)
+		}
+	}
+
+	return 0, fmt.Errorf("used_memory missing from INFO")
+}
+
+// Publish sends a message on a pub/sub channel
+func Publish(ctx context.Context, channel, message string) error {
+	if Client == nil {
+		return fmt.Errorf("redis client is not initialized")
+	}
+
+	return Client.Publish(ctx, channel, message).Err()
+}
+
+// Subscribe subscribes to a pub/sub channel; the caller must close the subscription
+func Subscribe(ctx context.Context, channel string) (*redis.PubSub, error) {
+	if Client == nil {
+		return nil, fmt.Errorf("redis client is not initialized")
+	}
+
+	pubsub := Client.Subscribe(ctx, channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	return pubsub, nil
+}
+
 // GenerateCacheKey generates a cache key from query parameters
 func GenerateCacheKey(prefix string, params ...string) string {
 	hasher := sha256.New()
@@ -0,0 +1,290 @@
+package chaos
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	mathrand "math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/middleware"
+	"github.com/sirupsen/logrus"
+)
+
+// Fault targets
+const (
+	TargetRAG   = "rag"
+	TargetRedis = "redis"
+	TargetDB    = "db"
+)
+
+// Fault types
+const (
+	TypeLatency = "latency"
+	TypeError   = "error"
+	TypeTimeout = "timeout"
+)
+
+// Bounds on a registered fault
+const (
+	defaultLatency   = 2 * time.Second
+	maxLatency       = 60 * time.Second
+	defaultTimeout   = 30 * time.Second
+	maxFaultDuration = time.Hour
+)
+
+// productionEnvironment is the environment faults are never injected in,
+// whatever the config says
+const productionEnvironment = "production"
+
+// ErrInjected is returned by calls failed by an error fault
+var ErrInjected = errors.New("chaos fault injected")
+
+// ErrInvalidFault is returned when registering a fault that doesn't validate
+var ErrInvalidFault = errors.New("invalid chaos fault")
+
+// FaultRequest registers a fault. Latency is how long a latency fault delays
+// a call, or how long a timeout fault hangs when the caller set no deadline.
+type FaultRequest struct {
+	Target      string  `json:"target"`
+	Type        string  `json:"type"`
+	Probability float64 `json:"probability"`
+	LatencyMs   int     `json:"latency_ms"`
+	DurationS   int     `json:"duration_s"`
+}
+
+// Fault is a registered fault, injected into calls to its target with its
+// probability until it expires
+type Fault struct {
+	ID          string    `json:"id"`
+	Target      string    `json:"target"`
+	Type        string    `json:"type"`
+	Probability float64   `json:"probability"`
+	LatencyMs   int       `json:"latency_ms"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Injected    int64     `json:"injected"`
+
+	injected atomic.Int64
+}
+
+// Injector holds the faults registered on this instance. Faults live in
+// memory only, so they apply to the instance they were registered on and
+// are gone after a restart. A nil Injector injects nothing.
+type Injector struct {
+	mu     sync.Mutex
+	faults map[string]*Fault
+	now    func() time.Time
+}
+
+// NewInjector creates an injector for the environment, refusing production
+func NewInjector(environment string) (*Injector, error) {
+	if environment == productionEnvironment {
+		return nil, fmt.Errorf("chaos mode cannot be enabled in production")
+	}
+	return &Injector{
+		faults: make(map[string]*Fault),
+		now:    time.Now,
+	}, nil
+}
+
+// Add registers a fault
+func (i *Injector) Add(req FaultRequest) (*Fault, error) {
+	if err := validate(&req); err != nil {
+		return nil, err
+	}
+
+	id, err := newFaultID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := i.now()
+	fault := &Fault{
+		ID:          id,
+		Target:      req.Target,
+		Type:        req.Type,
+		Probability: req.Probability,
+		LatencyMs:   req.LatencyMs,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(time.Duration(req.DurationS) * time.Second),
+	}
+
+	i.mu.Lock()
+	i.expire(now)
+	i.faults[id] = fault
+	i.mu.Unlock()
+
+	logrus.WithField("chaos_fault", fault.fields()).Warn("Chaos fault registered")
+	return fault.snapshot(), nil
+}
+
+// List returns the faults that haven't expired, oldest first
+func (i *Injector) List() []*Fault {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.expire(i.now())
+	faults := make([]*Fault, 0, len(i.faults))
+	for _, fault := range i.faults {
+		faults = append(faults, fault.snapshot())
+	}
+	sort.Slice(faults, func(a, b int) bool {
+		return faults[a].CreatedAt.Before(faults[b].CreatedAt)
+	})
+	return faults
+}
+
+// Remove deletes a fault, returning whether it was registered
+func (i *Injector) Remove(id string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.expire(i.now())
+	fault, ok := i.faults[id]
+	if ok {
+		delete(i.faults, id)
+		logrus.WithField("chaos_fault", fault.fields()).Warn("Chaos fault removed")
+	}
+	return ok
+}
+
+// Inject applies the first fault on target that fires. A latency fault
+// delays the call and lets it proceed; error and timeout faults return the
+// error the call should fail with.
+func (i *Injector) Inject(ctx context.Context, target string) error {
+	if i == nil {
+		return nil
+	}
+
+	fault := i.pick(target)
+	if fault == nil {
+		return nil
+	}
+
+	fault.injected.Add(1)
+	middleware.RecordChaosFault(fault.Target, fault.Type)
+	logrus.WithField("chaos_fault", fault.fields()).Warn("Chaos fault injected")
+
+	switch fault.Type {
+	case TypeLatency:
+		return sleep(ctx, time.Duration(fault.LatencyMs)*time.Millisecond)
+	case TypeTimeout:
+		// Hang until the caller gives up, as an unresponsive dependency would
+		if _, ok := ctx.Deadline(); !ok {
+			if err := sleep(ctx, time.Duration(fault.LatencyMs)*time.Millisecond); err != nil {
+				return err
+			}
+			return fmt.Errorf("%w: %s timeout fault %s", context.DeadlineExceeded, fault.Target, fault.ID)
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	default:
+		return fmt.Errorf("%w: %s error fault %s", ErrInjected, fault.Target, fault.ID)
+	}
+}
+
+// pick rolls each live fault on target, returning the first that fires
+func (i *Injector) pick(target string) *Fault {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.expire(i.now())
+	for _, fault := range i.faults {
+		if fault.Target == target && mathrand.Float64() < fault.Probability {
+			return fault
+		}
+	}
+	return nil
+}
+
+// expire drops faults past their expiry. Callers hold mu.
+func (i *Injector) expire(now time.Time) {
+	for id, fault := range i.faults {
+		if !now.Before(fault.ExpiresAt) {
+			delete(i.faults, id)
+			logrus.WithField("chaos_fault", fault.fields()).Info("Chaos fault expired")
+		}
+	}
+}
+
+// snapshot copies the fault with its injection count, for the API
+func (f *Fault) snapshot() *Fault {
+	return &Fault{
+		ID:          f.ID,
+		Target:      f.Target,
+		Type:        f.Type,
+		Probability: f.Probability,
+		LatencyMs:   f.LatencyMs,
+		CreatedAt:   f.CreatedAt,
+		ExpiresAt:   f.ExpiresAt,
+		Injected:    f.injected.Load(),
+	}
+}
+
+// fields tags log entries with the fault
+func (f *Fault) fields() logrus.Fields {
+	return logrus.Fields{
+		"id":     f.ID,
+		"target": f.Target,
+		"type":   f.Type,
+	}
+}
+
+// validate checks a fault request, filling in the default latency
+func validate(req *FaultRequest) error {
+	switch req.Target {
+	case TargetRAG, TargetRedis, TargetDB:
+	default:
+		return fmt.Errorf("%w: target must be one of rag, redis, db", ErrInvalidFault)
+	}
+	switch req.Type {
+	case TypeLatency, TypeError, TypeTimeout:
+	default:
+		return fmt.Errorf("%w: type must be one of latency, error, timeout", ErrInvalidFault)
+	}
+	if req.Probability <= 0 || req.Probability > 1 {
+		return fmt.Errorf("%w: probability must be in (0, 1]", ErrInvalidFault)
+	}
+	if req.DurationS <= 0 || time.Duration(req.DurationS)*time.Second > maxFaultDuration {
+		return fmt.Errorf("%w: duration_s must be between 1 and %d", ErrInvalidFault, int(maxFaultDuration.Seconds()))
+	}
+	if req.LatencyMs < 0 || time.Duration(req.LatencyMs)*time.Millisecond > maxLatency {
+		return fmt.Errorf("%w: latency_ms must be between 0 and %d", ErrInvalidFault, maxLatency.Milliseconds())
+	}
+	if req.LatencyMs == 0 {
+		switch req.Type {
+		case TypeLatency:
+			req.LatencyMs = int(defaultLatency.Milliseconds())
+		case TypeTimeout:
+			req.LatencyMs = int(defaultTimeout.Milliseconds())
+		}
+	}
+	return nil
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func newFaultID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate fault ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
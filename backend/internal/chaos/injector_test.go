@@ -0,0 +1,85 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewInjectorRefusesProduction(t *testing.T) {
+	tests := []struct {
+		environment string
+		allowed     bool
+	}{
+		{"development", true},
+		{"staging", true},
+		{"test", true},
+		{"production", false},
+	}
+	for _, tt := range tests {
+		injector, err := NewInjector(tt.environment)
+		if tt.allowed && (err != nil || injector == nil) {
+			t.Errorf("NewInjector(%q) = %v, %v; want an injector", tt.environment, injector, err)
+		}
+		if !tt.allowed && (err == nil || injector != nil) {
+			t.Errorf("NewInjector(%q) = %v, %v; want it refused", tt.environment, injector, err)
+		}
+	}
+
+	// Refused injectors are nil, and a nil injector injects nothing
+	injector, _ := NewInjector("production")
+	if err := injector.Inject(context.Background(), TargetRAG); err != nil {
+		t.Errorf("nil injector injected %v", err)
+	}
+}
+
+func TestInjectorFaultsExpire(t *testing.T) {
+	injector, err := NewInjector("staging")
+	if err != nil {
+		t.Fatalf("NewInjector: %v", err)
+	}
+	now := time.Now()
+	injector.now = func() time.Time { return now }
+
+	if _, err := injector.Add(FaultRequest{Target: TargetRAG, Type: TypeError, Probability: 1, DurationS: 60}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := injector.Inject(context.Background(), TargetRedis); err != nil {
+		t.Errorf("redis call failed by a rag fault: %v", err)
+	}
+	if err := injector.Inject(context.Background(), TargetRAG); !errors.Is(err, ErrInjected) {
+		t.Errorf("rag call error = %v, want ErrInjected", err)
+	}
+	if faults := injector.List(); len(faults) != 1 || faults[0].Injected != 1 {
+		t.Errorf("faults = %+v, want one injected once", faults)
+	}
+
+	now = now.Add(time.Minute)
+	if err := injector.Inject(context.Background(), TargetRAG); err != nil {
+		t.Errorf("expired fault injected %v", err)
+	}
+	if faults := injector.List(); len(faults) != 0 {
+		t.Errorf("faults = %+v, want the expired fault dropped", faults)
+	}
+}
+
+func TestInjectorValidatesFaults(t *testing.T) {
+	injector, _ := NewInjector("staging")
+	tests := []struct {
+		name string
+		req  FaultRequest
+	}{
+		{"unknown target", FaultRequest{Target: "kafka", Type: TypeError, Probability: 1, DurationS: 60}},
+		{"unknown type", FaultRequest{Target: TargetRAG, Type: "crash", Probability: 1, DurationS: 60}},
+		{"zero probability", FaultRequest{Target: TargetRAG, Type: TypeError, DurationS: 60}},
+		{"no duration", FaultRequest{Target: TargetRAG, Type: TypeError, Probability: 1}},
+		{"over an hour", FaultRequest{Target: TargetRAG, Type: TypeError, Probability: 1, DurationS: 3601}},
+		{"latency too long", FaultRequest{Target: TargetRAG, Type: TypeLatency, Probability: 1, DurationS: 60, LatencyMs: 60001}},
+	}
+	for _, tt := range tests {
+		if _, err := injector.Add(tt.req); !errors.Is(err, ErrInvalidFault) {
+			t.Errorf("%s: error = %v, want ErrInvalidFault", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package chaos
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisHook injects redis faults into every command sent through a client
+func (i *Injector) RedisHook() redis.Hook {
+	return redisHook{injector: i}
+}
+
+type redisHook struct {
+	injector *Injector
+}
+
+func (h redisHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return ctx, h.injector.Inject(ctx, TargetRedis)
+}
+
+func (h redisHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (h redisHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return ctx, h.injector.Inject(ctx, TargetRedis)
+}
+
+func (h redisHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
@@ -2,9 +2,12 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"strconv"
+	"strings"
 
+	"github.com/ai-support-assistant/backend/internal/logging"
 	"github.com/joho/godotenv"
 	"github.com/sirupsen/logrus"
 )
@@ -12,11 +15,36 @@ import (
 // Config holds all configuration for the application
 type Config struct {
 	// Server
-	Port        string
-	Environment string
+	Port           string
+	Environment    string
+	TrustedProxies []string
+
+	// How long shutdown waits for in-flight background work before abandoning it
+	ShutdownDrainTimeoutS int
+
+	// How long the server keeps serving after SIGTERM with readiness failing,
+	// so load balancers stop routing to it before connections are refused
+	ShutdownDelayS int
+
+	// CORS origins always allowed to call the query API, in addition to enabled widget origins
+	CORSAllowedOrigins []string
+	// How long browsers may cache a preflight response, in seconds
+	CORSMaxAgeS int
 
 	// Database
-	DatabaseURL string
+	DatabaseURL       string
+	DBConnectAttempts int
+	DBConnectDelayS   int
+	RunMigrations     bool
+
+	// Database connection pool. Lifetimes are in seconds and 0 means no
+	// limit; a DBSlowQueryMs of 0 disables slow query logging.
+	DBMaxIdleConns     int
+	DBMaxOpenConns     int
+	DBConnMaxLifetimeS int
+	DBConnMaxIdleTimeS int
+	DBSlowQueryMs      int
+	DBStatsIntervalS   int
 
 	// Redis
 	RedisURL      string
@@ -24,57 +52,819 @@ type Config struct {
 	RedisPort     string
 	RedisPassword string
 
-	// RAG Service
-	RAGServiceURL string
+	// RAG Service. Queries that fail against it with a connection error,
+	// timeout or 5xx are retried once against RAGServiceFallbackURL, a
+	// secondary deployment always called over HTTP; documents are only ever
+	// ingested by the primary.
+	RAGServiceURL         string
+	RAGServiceFallbackURL string
+
+	// RAG transport, http or grpc. The gRPC connection uses TLS when
+	// RAGGRPCTLS is set, verified against RAGGRPCCAFile if given, and
+	// reconnects with backoff capped at RAGGRPCBackoffMaxS.
+	RAGTransport       string
+	RAGGRPCAddress     string
+	RAGGRPCTLS         bool
+	RAGGRPCCAFile      string
+	RAGGRPCServerName  string
+	RAGGRPCBackoffMaxS int
+
+	// Degraded mode: the RAG service is probed every RAGProbeIntervalS and marked
+	// unavailable after RAGProbeFailureThreshold consecutive failures
+	RAGProbeIntervalS        int
+	RAGProbeFailureThreshold int
+
+	// Load shedding: at most RAGMaxInFlight generations run at once (0 disables
+	// the limit), the rest wait in a FIFO queue of up to RAGQueueMaxLength.
+	// Requests whose estimated wait exceeds RAGQueueMaxWaitS are rejected.
+	RAGMaxInFlight    int
+	RAGQueueMaxLength int
+	RAGQueueMaxWaitS  int
+
+	// Visitors are counted by an HMAC of IP and user agent under
+	// VisitorFingerprintKey, salted per VisitorSaltRotationS period; fingerprinting
+	// is disabled without a key
+	VisitorFingerprintKey string
+	VisitorSaltRotationS  int
+
+	// Ingestion straight from object storage. ObjectStoreCredentials are
+	// "name=access_key:secret_key" entries that requests refer to by name.
+	ObjectIngestMaxBytes     int64
+	ObjectIngestAllowedTypes []string
+	ObjectIngestTimeoutS     int
+	ObjectStoreCredentials   []string
+	ObjectStoreS3Region      string
+	ObjectStoreS3Endpoint    string
+
+	// Plain-text uploads keep up to DocumentTextMaxBytes of their text for
+	// previews. Uploads whose whole text was kept are queued while the RAG
+	// service is down and ingested every DocumentQueueIntervalS once it's back.
+	DocumentTextMaxBytes   int64
+	DocumentQueueIntervalS int
+
+	// Uploads are scanned for malware by the clamd daemon at ClamAVHost and
+	// ClamAVPort when the host is set. Uploads larger than MalwareScanMaxBytes
+	// can't be scanned; with MalwareScanFailOpen they, and uploads made while
+	// the scanner is unavailable, are accepted unscanned instead of rejected.
+	ClamAVHost          string
+	ClamAVPort          int
+	MalwareScanMaxBytes int64
+	MalwareScanTimeoutS int
+	MalwareScanFailOpen bool
+
+	// Shadow tests replay at most ShadowTestMaxSample historical queries
+	// against the current and a candidate RAG service, ShadowTestConcurrency
+	// at a time. A run stops once it has taken ShadowTestMaxDurationS or used
+	// ShadowTestMaxTokens across both services; requests may only lower them.
+	ShadowTestMaxSample    int
+	ShadowTestConcurrency  int
+	ShadowTestMaxDurationS int
+	ShadowTestMaxTokens    int
+
+	// Re-ingestion jobs send ReingestConcurrency documents at a time to the
+	// RAG service, trying each up to ReingestMaxAttempts times
+	ReingestConcurrency int
+	ReingestMaxAttempts int
+
+	// Queries may carry up to AttachmentMaxCount png, jpeg or webp images of
+	// up to AttachmentMaxBytes each, forwarded to the RAG service's multimodal
+	// endpoint; 0 disables attachments. Only their size and digest are
+	// recorded unless AttachmentRetention is set. A query with attachments counts as
+	// AttachmentRateLimitWeight requests against the rate limit.
+	AttachmentMaxCount        int
+	AttachmentMaxBytes        int64
+	AttachmentRetention       bool
+	AttachmentRateLimitWeight int
+
+	// Upstream rate limits: a 429 from the RAG service is retried up to
+	// RAGRateLimitMaxRetries times within the request deadline, waiting for its
+	// Retry-After or RAGRateLimitRetryDelayMs. Health reports 429s over the
+	// last RAGRateLimitWindowS.
+	RAGRateLimitMaxRetries   int
+	RAGRateLimitRetryDelayMs int
+	RAGRateLimitWindowS      int
+
+	// Send the full active prompt body to the RAG service instead of only its name and version
+	PromptSendBody bool
+
+	// Query coalescing
+	QueryCoalesceTimeoutS    int
+	QueryCoalesceDistributed bool
+
+	// Async query jobs
+	QueryJobWorkers   int
+	QueryJobQueueSize int
+	QueryJobTimeoutS  int
+	QueryJobTTLS      int
+
+	// URL and sitemap crawl ingestion. CrawlDelayMs is the pause each
+	// worker takes between requests.
+	CrawlConcurrency  int
+	CrawlDelayMs      int
+	CrawlMaxPages     int
+	CrawlTimeoutS     int
+	CrawlMaxPageBytes int64
+	CrawlUserAgent    string
+
+	// Crawls, object fetches, webhooks and shadow tests refuse loopback,
+	// private and link-local destinations, except in these CIDR ranges
+	OutboundAllowedCIDRs []string
+
+	// Chat history purges delete PurgeBatchSize queries per transaction and
+	// pause PurgeBatchDelayMs between batches so replicas can keep up
+	PurgeBatchSize    int
+	PurgeBatchDelayMs int
+
+	// Ingestion options for documents that don't set their own: characters
+	// per chunk, characters shared by consecutive chunks and whether scanned
+	// pages are read with OCR
+	DefaultChunkSize    int
+	DefaultChunkOverlap int
+	DefaultOCR          bool
 
 	// JWT
-	JWTSecret string
+	JWTSecret   string
+	AuthEnabled bool
+
+	// Server-to-server request signing, an alternative to JWTs for partner
+	// backends. SigningKeys are "key_id=secret" entries; signed requests must
+	// be timestamped within SignatureMaxSkewS of the server clock.
+	SigningKeys       []string
+	SignatureMaxSkewS int
+
+	// Encryption at rest for conversation content, enabled by setting
+	// EncryptionKey. EncryptionPreviousKeys are "id=key" entries still
+	// accepted for decryption after a rotation.
+	EncryptionKey          string
+	EncryptionKeyID        string
+	EncryptionPreviousKeys []string
+	EncryptionHashKey      string
+	EncryptionBatchSize    int
+
+	// Export
+	ExportMaxQueries int
+
+	// Metrics
+	MetricsAuthToken    string
+	MetricsAllowedCIDRs []string
+
+	// Bucket upper bounds in seconds, ascending, for the HTTP and RAG request
+	// duration histograms. Requests taking longer than SlowRequestThresholdMs
+	// are also counted per endpoint.
+	MetricsHTTPBuckets     []string
+	MetricsRAGBuckets      []string
+	SlowRequestThresholdMs int
 
 	// Rate Limiting
 	RateLimitRequests int
 	RateLimitWindow   int
+	RateLimitQuery    RateLimit
+	RateLimitUpload   RateLimit
+	RateLimitRead     RateLimit
+	RateLimitClaim    RateLimit // per user, so session IDs can't be enumerated by claiming
+
+	// Abuse detection
+	AbuseDetectionEnabled   bool
+	AbuseWindowS            int
+	AbuseSessionMaxQueries  int
+	AbuseIPMaxQueries       int
+	AbuseDistinctMinQueries int
+	AbuseDistinctRatio      float64
+	AbuseBanDurationS       int
+
+	// Cache; responses are fresh for CacheTTL and served stale, while being
+	// refreshed in the background, until CacheStaleTTL
+	CacheTTL                int
+	CacheStaleTTL           int
+	CacheRefreshWindowS     int
+	CacheRefreshConcurrency int
+	CacheBypassPatterns     []string
+
+	// A query stops being cached for CacheNoCacheTTLS once its answers get
+	// CacheNoCacheAfterNegative thumbs-down within that time; 0 disables
+	CacheNoCacheAfterNegative int
+	CacheNoCacheTTLS          int
+
+	// Adaptive TTLs cache answers by how often their query is asked, a count
+	// halving every CachePopularityHalfLifeS: fresh for CacheTTLMin when asked
+	// once, rising to CacheTTLMax at CacheTTLHotHits. Queries asked fewer
+	// than CacheMinHits times aren't cached at all; 0 caches every query.
+	// They apply to CacheAdaptiveTTLPercent of queries, the rest being
+	// cached for CacheTTL.
+	CacheAdaptiveTTLPercent  int
+	CacheTTLMin              int
+	CacheTTLMax              int
+	CacheTTLHotHits          int
+	CacheMinHits             int
+	CachePopularityHalfLifeS int
+
+	// Cache warm-up replays the CacheWarmupQueries most asked questions of
+	// the last week into the cache, CacheWarmupConcurrency at a time, giving
+	// up after CacheWarmupBudgetS. CacheWarmup runs it at startup.
+	CacheWarmup            bool
+	CacheWarmupQueries     int
+	CacheWarmupConcurrency int
+	CacheWarmupBudgetS     int
+
+	// Regenerating an answer asks again with RegenerateTemperature, a larger
+	// top_k and RegenerateModel if set, at most RegenerateMaxAttempts times
+	// per original query; 0 disables regeneration
+	RegenerateMaxAttempts int
+	RegenerateTemperature float64
+	RegenerateModel       string
+
+	// Runtime settings
+	SettingsRefreshS int
+
+	// How often each instance reloads feature flags, bounding how long a
+	// flip takes to reach every instance
+	FeatureFlagRefreshS int
 
-	// Cache
-	CacheTTL int
+	// How long the assembled admin dashboard summary is cached
+	DashboardCacheTTLS int
 
 	// OpenAI
 	OpenAIKey   string
 	OpenAIModel string
+
+	// Direct answers: general questions, which need no retrieval, are
+	// answered by OpenAIModel straight from the chat completions API at
+	// OpenAIBaseURL instead of the RAG service. Queries matching a
+	// DirectQueryPatterns regex are general and those matching a
+	// RAGQueryPatterns regex, checked first, are not; with DirectClassifier
+	// set, the model labels queries no pattern matches, within
+	// DirectClassifierTimeoutMs. Calls time out after OpenAITimeoutS and are
+	// retried OpenAIMaxRetries times. Off unless OpenAIKey is set.
+	OpenAIBaseURL             string
+	OpenAITimeoutS            int
+	OpenAIMaxRetries          int
+	DirectQueryPatterns       []string
+	RAGQueryPatterns          []string
+	DirectClassifier          bool
+	DirectClassifierTimeoutMs int
+	DirectSystemPrompt        string
+
+	// AllowedModels are the models routing rules may send queries to, besides
+	// OpenAIModel; any model is allowed if empty
+	AllowedModels []string
+
+	// Before calling the RAG service the prompt is estimated from the query,
+	// the last PromptHistoryTurns turns of the conversation, top_k chunks of
+	// PromptChunkTokens each and PromptReserveTokens for the template and
+	// answer. Prompts over the model's context window are rejected, or lose
+	// their oldest turns if AutoTrimHistory is set. ModelContextLimits are
+	// "model=tokens" entries, with ModelContextLimit for other models (0
+	// disables the check); TokenCalibration are "model=factor" entries that
+	// scale estimates for a model's tokenizer.
+	ModelContextLimit   int
+	ModelContextLimits  []string
+	TokenCalibration    []string
+	PromptChunkTokens   int
+	PromptReserveTokens int
+	PromptHistoryTurns  int
+	AutoTrimHistory     bool
+
+	// Plan tiers are "name=daily_queries:max_upload_bytes:streaming" entries,
+	// e.g. "free=20:10485760:false"; 0 means unlimited. Quotas are off unless
+	// Plans is set. Callers whose token names no known plan are on
+	// DefaultPlan, and answers served from cache use up quota if
+	// QuotaCountCached is set.
+	Plans            []string
+	DefaultPlan      string
+	QuotaCountCached bool
+
+	// Notifications
+	SlackWebhookURL      string
+	SlackNotifyIntervalS int
+
+	// SMTP
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+
+	// Reports
+	ReportIntervalH  int
+	ReportPeriodDays int
+	ReportRecipients []string
+	ReportBaseURL    string
+	CostPer1KTokens  float64
+
+	// Webhooks
+	WebhookWorkers     int
+	WebhookQueueSize   int
+	WebhookMaxAttempts int
+
+	// Moderation
+	ModerationMode           string
+	ModerationURL            string
+	ModerationRefusalMessage string
+
+	// Grounding verification of generated answers against their context:
+	// off, log or enforce. In enforce mode answers scoring below
+	// VerificationMinScore are replaced by LowConfidenceMessage.
+	VerificationMode      string
+	VerificationMinScore  float64
+	VerificationTimeoutMs int
+	LowConfidenceMessage  string
+
+	// Response language: match_user answers in the language the query was
+	// detected in, fixed always in ResponseLanguage, and auto leaves it to
+	// the RAG service. With ResponseTranslation set, an answer that comes
+	// back in another language is translated, within TranslationTimeoutMs.
+	ResponseLanguageMode string
+	ResponseLanguage     string
+	ResponseTranslation  bool
+	TranslationTimeoutMs int
+
+	// PartialResponseNote accompanies answers cut short by a RAG timeout
+	PartialResponseNote string
+
+	// Response post-processing steps. ResponseMaxLength of 0 disables truncation.
+	PostprocessSanitizeHTML      bool
+	PostprocessNormalizeMarkdown bool
+	PostprocessRewriteLinks      bool
+	ResponseMaxLength            int
+	ResponseTruncationMarker     string
+
+	// Suggested follow-up questions: at most SuggestionsMax are returned, and
+	// the session endpoint generates them from the last SuggestionsHistory turns
+	SuggestionsMax     int
+	SuggestionsHistory int
+
+	// "Did you mean" suggestions for queries that retrieved no context, drawn
+	// from the DidYouMeanVocabulary most frequent queries of the last
+	// DidYouMeanWindowDays, refreshed every DidYouMeanRefreshS. Only queries
+	// asked in at least DidYouMeanMinSessions sessions are suggested, so one
+	// user's question is never shown to another.
+	DidYouMeanEnabled       bool
+	DidYouMeanVocabulary    int
+	DidYouMeanMinSessions   int
+	DidYouMeanWindowDays    int
+	DidYouMeanRefreshS      int
+	DidYouMeanMinSimilarity float64
+
+	// FeedbackSamplePercent is the share of answers the widget asks users to
+	// rate; overridable at runtime
+	FeedbackSamplePercent int
+
+	// Feedback theme classification: each request to the themes endpoint
+	// classifies at most FeedbackThemeBudget comments, FeedbackThemeConcurrency
+	// at a time, and returns FeedbackThemeExamples comments per theme
+	FeedbackThemeBudget      int
+	FeedbackThemeConcurrency int
+	FeedbackThemeExamples    int
+
+	// Feedback imports from survey tools are limited to FeedbackImportMaxRows
+	// rows and FeedbackImportMaxBytes of file
+	FeedbackImportMaxRows  int
+	FeedbackImportMaxBytes int64
+
+	// Knowledge gap reports cluster the questions of the last
+	// KnowledgeGapWindowDays that got low-confidence, context-free or
+	// thumbs-down answers, every KnowledgeGapIntervalH hours (0 only generates
+	// them on request). A report considers at most KnowledgeGapMaxQueries
+	// questions and keeps the KnowledgeGapMaxClusters largest clusters. A run
+	// works for at most KnowledgeGapBudgetS; a report not done by then pauses
+	// after its last saved batch and a later run resumes it from there.
+	// Questions join a cluster at KnowledgeGapMinSimilarity cosine similarity
+	// of their embeddings.
+	KnowledgeGapIntervalH     int
+	KnowledgeGapWindowDays    int
+	KnowledgeGapMaxQueries    int
+	KnowledgeGapMaxClusters   int
+	KnowledgeGapBudgetS       int
+	KnowledgeGapMinSimilarity float64
+
+	// Billing usage records are recomputed from the chat queries changed
+	// since the last run every BillingAggregationIntervalS seconds (0
+	// disables aggregation); the first run covers the last
+	// BillingBackfillDays days
+	BillingAggregationIntervalS int
+	BillingBackfillDays         int
+
+	// Idempotency-Key support: responses are replayed for IdempotencyWindowS,
+	// duplicates wait up to IdempotencyWaitS for the original to finish, and a
+	// claim on a key expires after IdempotencyLockS if its holder dies
+	IdempotencyWindowS int
+	IdempotencyWaitS   int
+	IdempotencyLockS   int
+
+	// Per-route request deadlines in seconds, 0 disables. Keep them below the
+	// server's 30s write timeout so clients get a 504 rather than a dropped
+	// connection.
+	RequestTimeoutQueryS   int
+	RequestTimeoutReadS    int
+	RequestTimeoutDefaultS int
+
+	// How long clients may reuse polled read responses before revalidating
+	// them with their ETag, in seconds, sent as Cache-Control: private
+	CacheMaxAgeAnalyticsS int
+	CacheMaxAgeDocsS      int
+	CacheMaxAgeFeedbackS  int
+	CacheMaxAgeFAQS       int
+
+	// JSON, CSV and text responses of at least CompressionMinBytes are
+	// compressed at CompressionLevel, 1 (fastest) to 9 (smallest); 0 disables
+	CompressionLevel    int
+	CompressionMinBytes int
+
+	// Request bodies other than multipart uploads are limited to
+	// MaxRequestBodyBytes; larger ones are rejected with 413
+	MaxRequestBodyBytes int64
+
+	// Open sessions idle for SessionAbandonAfterM minutes are marked
+	// abandoned by a sweep every SessionSweepIntervalS seconds. A query
+	// arriving after SessionIdleMinutes of inactivity starts a new
+	// conversation segment without the earlier history; 0 disables this.
+	SessionAbandonAfterM  int
+	SessionSweepIntervalS int
+	SessionIdleMinutes    int
+
+	// Logging. LogLevel and LogFormat default to debug/text in development and
+	// info/json otherwise. The access log records one in LogAccessSampleRate
+	// successful requests and every error. LogScrubPatterns are extra regexes
+	// redacted from log entries, and query text logged at debug level is cut
+	// to LogQueryMaxLength characters.
+	LogLevel            string
+	LogFormat           string
+	LogAccessSampleRate int
+	LogScrubPatterns    []string
+	LogQueryMaxLength   int
+
+	// Panics are reported to the Sentry-compatible error tracker at SentryDSN
+	// when it is set, and with ErrorReportingMode all_5xx so are server error
+	// responses. SentrySampleRate of the events are sent, from a queue of
+	// SentryQueueSize that drops events when full rather than hold up requests.
+	SentryDSN          string
+	SentryEnvironment  string
+	SentryRelease      string
+	SentrySampleRate   float64
+	SentryQueueSize    int
+	ErrorReportingMode string
+
+	// Chaos mode exposes admin endpoints that inject faults into calls to
+	// the RAG service, Redis and the database health check, for resilience
+	// testing in staging. It is refused in production.
+	ChaosMode bool
 }
 
+// DefaultDirectSystemPrompt instructs the model answering general questions
+const DefaultDirectSystemPrompt = "You are a helpful customer support assistant. Answer the user's request clearly and concisely."
+
+// RateLimit is a request budget per window
+type RateLimit struct {
+	Requests int
+	WindowS  int
+}
+
+// ServerWriteTimeoutS is the HTTP server's write timeout in seconds; request
+// deadlines must end before it
+const ServerWriteTimeoutS = 30
+
+// MaxDidYouMeanVocabulary bounds DID_YOU_MEAN_VOCABULARY, since the
+// vocabulary is searched while answering queries
+const MaxDidYouMeanVocabulary = 20000
+
 var AppConfig *Config
 
-// Load loads configuration from environment variables
+// Load loads configuration from environment variables, failing on any
+// validation error and logging warnings
 func Load() (*Config, error) {
+	config, report := Check()
+	for _, warning := range report.Warnings {
+		logrus.WithField("field", warning.Field).Warn(warning.Message)
+	}
+	if err := report.Err(); err != nil {
+		return nil, err
+	}
+
+	AppConfig = config
+	return config, nil
+}
+
+// Check loads configuration from environment variables and reports every
+// problem with it, rather than stopping at the first
+func Check() (*Config, *Report) {
 	// Load .env file if it exists
 	if err := godotenv.Load(); err != nil {
 		logrus.Warn("No .env file found, using environment variables")
 	}
 
 	config := &Config{
-		Port:              getEnv("BACKEND_PORT", "8080"),
-		Environment:       getEnv("GO_ENV", "development"),
-		DatabaseURL:       getEnv("POSTGRES_URL", ""),
-		RedisURL:          getEnv("REDIS_URL", "redis://localhost:6379"),
-		RedisHost:         getEnv("REDIS_HOST", "localhost"),
-		RedisPort:         getEnv("REDIS_PORT", "6379"),
-		RedisPassword:     getEnv("REDIS_PASSWORD", ""),
-		RAGServiceURL:     getEnv("RAG_SERVICE_URL", "http://localhost:8000"),
-		JWTSecret:         getEnv("JWT_SECRET", "your-secret-key-change-this"),
-		RateLimitRequests: getEnvAsInt("RATE_LIMIT_REQUESTS", 100),
-		RateLimitWindow:   getEnvAsInt("RATE_LIMIT_WINDOW", 60),
-		CacheTTL:          getEnvAsInt("CACHE_TTL", 3600),
-		OpenAIKey:         getEnv("OPENAI_API_KEY", ""),
-		OpenAIModel:       getEnv("OPENAI_MODEL", "gpt-4"),
-	}
-
-	// Validate required fields
-	if config.DatabaseURL == "" {
-		return nil, fmt.Errorf("POSTGRES_URL is required")
+		Port:                     getEnv("BACKEND_PORT", "8080"),
+		Environment:              getEnv("GO_ENV", "development"),
+		TrustedProxies:           getEnvAsList("TRUSTED_PROXIES", nil),
+		ShutdownDrainTimeoutS:    getEnvAsInt("SHUTDOWN_DRAIN_TIMEOUT", 30),
+		ShutdownDelayS:           getEnvAsInt("SHUTDOWN_DELAY", 5),
+		CORSAllowedOrigins:       getEnvAsList("CORS_ALLOWED_ORIGINS", []string{"http://localhost:3000"}),
+		CORSMaxAgeS:              getEnvAsInt("CORS_MAX_AGE", 600),
+		DatabaseURL:              getEnv("POSTGRES_URL", ""),
+		DBConnectAttempts:        getEnvAsInt("DB_CONNECT_ATTEMPTS", 10),
+		DBConnectDelayS:          getEnvAsInt("DB_CONNECT_DELAY", 2),
+		RunMigrations:            getEnvAsBool("RUN_MIGRATIONS", false),
+		DBMaxIdleConns:           getEnvAsInt("DB_MAX_IDLE_CONNS", 10),
+		DBMaxOpenConns:           getEnvAsInt("DB_MAX_OPEN_CONNS", 20),
+		DBConnMaxLifetimeS:       getEnvAsInt("DB_CONN_MAX_LIFETIME", 3600),
+		DBConnMaxIdleTimeS:       getEnvAsInt("DB_CONN_MAX_IDLE_TIME", 600),
+		DBSlowQueryMs:            getEnvAsInt("DB_SLOW_QUERY_MS", 500),
+		DBStatsIntervalS:         getEnvAsInt("DB_STATS_INTERVAL", 15),
+		RedisURL:                 getEnv("REDIS_URL", "redis://localhost:6379"),
+		RedisHost:                getEnv("REDIS_HOST", "localhost"),
+		RedisPort:                getEnv("REDIS_PORT", "6379"),
+		RedisPassword:            getEnv("REDIS_PASSWORD", ""),
+		RAGServiceURL:            getEnv("RAG_SERVICE_URL", "http://localhost:8000"),
+		RAGServiceFallbackURL:    getEnv("RAG_SERVICE_FALLBACK_URL", ""),
+		PromptSendBody:           getEnvAsBool("PROMPT_SEND_BODY", false),
+		RAGProbeIntervalS:        getEnvAsInt("RAG_PROBE_INTERVAL", 10),
+		RAGProbeFailureThreshold: getEnvAsInt("RAG_PROBE_FAILURE_THRESHOLD", 3),
+		QueryCoalesceTimeoutS:    getEnvAsInt("QUERY_COALESCE_TIMEOUT", 65),
+		QueryCoalesceDistributed: getEnvAsBool("QUERY_COALESCE_DISTRIBUTED", false),
+		QueryJobWorkers:          getEnvAsInt("QUERY_JOB_WORKERS", 4),
+		QueryJobQueueSize:        getEnvAsInt("QUERY_JOB_QUEUE_SIZE", 100),
+		QueryJobTimeoutS:         getEnvAsInt("QUERY_JOB_TIMEOUT", 300),
+		QueryJobTTLS:             getEnvAsInt("QUERY_JOB_TTL", 86400),
+		CrawlConcurrency:         getEnvAsInt("CRAWL_CONCURRENCY", 2),
+		CrawlDelayMs:             getEnvAsInt("CRAWL_DELAY_MS", 500),
+		CrawlMaxPages:            getEnvAsInt("CRAWL_MAX_PAGES", 100),
+		CrawlTimeoutS:            getEnvAsInt("CRAWL_TIMEOUT", 20),
+		CrawlMaxPageBytes:        int64(getEnvAsInt("CRAWL_MAX_PAGE_BYTES", 5*1024*1024)),
+		CrawlUserAgent:           getEnv("CRAWL_USER_AGENT", "SupportAssistantBot/1.0"),
+		OutboundAllowedCIDRs:     getEnvAsList("OUTBOUND_ALLOWED_CIDRS", nil),
+		PurgeBatchSize:           getEnvAsInt("PURGE_BATCH_SIZE", 500),
+		PurgeBatchDelayMs:        getEnvAsInt("PURGE_BATCH_DELAY_MS", 250),
+		DefaultChunkSize:         getEnvAsInt("DEFAULT_CHUNK_SIZE", 1000),
+		DefaultChunkOverlap:      getEnvAsInt("DEFAULT_CHUNK_OVERLAP", 200),
+		DefaultOCR:               getEnvAsBool("DEFAULT_OCR", false),
+		JWTSecret:                getEnv("JWT_SECRET", DefaultJWTSecret),
+		AuthEnabled:              getEnvAsBool("AUTH_ENABLED", false),
+		SigningKeys:              getEnvAsList("SIGNING_KEYS", nil),
+		SignatureMaxSkewS:        getEnvAsInt("SIGNATURE_MAX_SKEW", 300),
+		EncryptionKey:            getEnv("ENCRYPTION_KEY", ""),
+		EncryptionKeyID:          getEnv("ENCRYPTION_KEY_ID", "1"),
+		EncryptionPreviousKeys:   getEnvAsList("ENCRYPTION_PREVIOUS_KEYS", nil),
+		EncryptionHashKey:        getEnv("ENCRYPTION_HASH_KEY", ""),
+		EncryptionBatchSize:      getEnvAsInt("ENCRYPTION_BATCH_SIZE", 500),
+		ExportMaxQueries:         getEnvAsInt("EXPORT_MAX_QUERIES", 1000),
+		MetricsAuthToken:         getEnv("METRICS_AUTH_TOKEN", ""),
+		MetricsAllowedCIDRs:      getEnvAsList("METRICS_ALLOWED_CIDRS", nil),
+		MetricsHTTPBuckets:       getEnvAsList("METRICS_HTTP_BUCKETS", DefaultDurationBuckets),
+		MetricsRAGBuckets:        getEnvAsList("METRICS_RAG_BUCKETS", DefaultDurationBuckets),
+		SlowRequestThresholdMs:   getEnvAsInt("SLOW_REQUEST_THRESHOLD_MS", 10000),
+		RateLimitRequests:        getEnvAsInt("RATE_LIMIT_REQUESTS", 100),
+		RateLimitWindow:          getEnvAsInt("RATE_LIMIT_WINDOW", 60),
+		AbuseDetectionEnabled:    getEnvAsBool("ABUSE_DETECTION_ENABLED", true),
+		AbuseWindowS:             getEnvAsInt("ABUSE_WINDOW", 300),
+		AbuseSessionMaxQueries:   getEnvAsInt("ABUSE_SESSION_MAX_QUERIES", 60),
+		AbuseIPMaxQueries:        getEnvAsInt("ABUSE_IP_MAX_QUERIES", 200),
+		AbuseDistinctMinQueries:  getEnvAsInt("ABUSE_DISTINCT_MIN_QUERIES", 30),
+		AbuseDistinctRatio:       getEnvAsFloat("ABUSE_DISTINCT_RATIO", 0.95),
+		AbuseBanDurationS:        getEnvAsInt("ABUSE_BAN_DURATION", 900),
+		CacheTTL:                 getEnvAsInt("CACHE_FRESH_TTL", getEnvAsInt("CACHE_TTL", 3600)),
+		CacheStaleTTL:            getEnvAsInt("CACHE_STALE_TTL", 86400),
+		CacheRefreshWindowS:      getEnvAsInt("CACHE_REFRESH_WINDOW", 60),
+		CacheRefreshConcurrency:  getEnvAsInt("CACHE_REFRESH_CONCURRENCY", 4),
+		CacheBypassPatterns:      getEnvAsList("CACHE_BYPASS_PATTERNS", nil),
+		SettingsRefreshS:         getEnvAsInt("SETTINGS_REFRESH_INTERVAL", 30),
+		FeatureFlagRefreshS:      getEnvAsInt("FEATURE_FLAG_REFRESH_INTERVAL", 5),
+		DashboardCacheTTLS:       getEnvAsInt("DASHBOARD_CACHE_TTL", 15),
+		OpenAIKey:                getEnv("OPENAI_API_KEY", ""),
+		OpenAIModel:              getEnv("OPENAI_MODEL", "gpt-4"),
+		AllowedModels:            getEnvAsList("ALLOWED_MODELS", nil),
+
+		OpenAIBaseURL:             getEnv("OPENAI_BASE_URL", "https://api.openai.com/v1"),
+		OpenAITimeoutS:            getEnvAsInt("OPENAI_TIMEOUT", 20),
+		OpenAIMaxRetries:          getEnvAsInt("OPENAI_MAX_RETRIES", 2),
+		DirectQueryPatterns:       getEnvAsList("DIRECT_QUERY_PATTERNS", nil),
+		RAGQueryPatterns:          getEnvAsList("RAG_QUERY_PATTERNS", nil),
+		DirectClassifier:          getEnvAsBool("DIRECT_CLASSIFIER", false),
+		DirectClassifierTimeoutMs: getEnvAsInt("DIRECT_CLASSIFIER_TIMEOUT_MS", 2000),
+		DirectSystemPrompt:        getEnv("DIRECT_SYSTEM_PROMPT", DefaultDirectSystemPrompt),
+
+		ModelContextLimit:   getEnvAsInt("MODEL_CONTEXT_LIMIT", 8192),
+		ModelContextLimits:  getEnvAsList("MODEL_CONTEXT_LIMITS", nil),
+		TokenCalibration:    getEnvAsList("TOKEN_CALIBRATION", nil),
+		PromptChunkTokens:   getEnvAsInt("PROMPT_CHUNK_TOKENS", 300),
+		PromptReserveTokens: getEnvAsInt("PROMPT_RESERVE_TOKENS", 1024),
+		PromptHistoryTurns:  getEnvAsInt("PROMPT_HISTORY_TURNS", 10),
+		AutoTrimHistory:     getEnvAsBool("AUTO_TRIM_HISTORY", false),
+
+		Plans:            getEnvAsList("PLANS", nil),
+		DefaultPlan:      getEnv("DEFAULT_PLAN", "free"),
+		QuotaCountCached: getEnvAsBool("QUOTA_COUNT_CACHED", true),
+
+		SlackWebhookURL:      getEnv("SLACK_WEBHOOK_URL", ""),
+		SlackNotifyIntervalS: getEnvAsInt("SLACK_NOTIFY_INTERVAL", 60),
+
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnvAsInt("SMTP_PORT", 587),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:     getEnv("SMTP_FROM", ""),
+
+		ReportIntervalH:  getEnvAsInt("REPORT_INTERVAL_HOURS", 168),
+		ReportPeriodDays: getEnvAsInt("REPORT_PERIOD_DAYS", 7),
+		ReportRecipients: getEnvAsList("REPORT_RECIPIENTS", nil),
+		ReportBaseURL:    getEnv("REPORT_BASE_URL", "http://localhost:8080"),
+		CostPer1KTokens:  getEnvAsFloat("COST_PER_1K_TOKENS", 0.002),
+
+		WebhookWorkers:     getEnvAsInt("WEBHOOK_WORKERS", 4),
+		WebhookQueueSize:   getEnvAsInt("WEBHOOK_QUEUE_SIZE", 1000),
+		WebhookMaxAttempts: getEnvAsInt("WEBHOOK_MAX_ATTEMPTS", 5),
+
+		ModerationMode:           getEnv("MODERATION_MODE", "off"),
+		ModerationURL:            getEnv("MODERATION_URL", "https://api.openai.com/v1/moderations"),
+		ModerationRefusalMessage: getEnv("MODERATION_REFUSAL_MESSAGE", "I'm sorry, but I can't help with that request. Please rephrase your question or contact our support team."),
+		VerificationMode:         getEnv("VERIFICATION_MODE", "off"),
+		VerificationMinScore:     getEnvAsFloat("VERIFICATION_MIN_SCORE", 0.5),
+		VerificationTimeoutMs:    getEnvAsInt("VERIFICATION_TIMEOUT_MS", 3000),
+		LowConfidenceMessage:     getEnv("LOW_CONFIDENCE_MESSAGE", "I'm not confident I have an accurate answer to that. Please rephrase your question or contact our support team."),
+		ResponseLanguageMode:     getEnv("RESPONSE_LANGUAGE_MODE", "auto"),
+		ResponseLanguage:         getEnv("RESPONSE_LANGUAGE", "en"),
+		ResponseTranslation:      getEnvAsBool("RESPONSE_TRANSLATION", true),
+		TranslationTimeoutMs:     getEnvAsInt("TRANSLATION_TIMEOUT_MS", 5000),
+		PartialResponseNote:      getEnv("PARTIAL_RESPONSE_NOTE", "This answer was cut short because it took too long to generate. Ask again to get the complete answer."),
+
+		PostprocessSanitizeHTML:      getEnvAsBool("POSTPROCESS_SANITIZE_HTML", true),
+		PostprocessNormalizeMarkdown: getEnvAsBool("POSTPROCESS_NORMALIZE_MARKDOWN", true),
+		PostprocessRewriteLinks:      getEnvAsBool("POSTPROCESS_REWRITE_LINKS", true),
+		ResponseMaxLength:            getEnvAsInt("RESPONSE_MAX_LENGTH", 0),
+		ResponseTruncationMarker:     getEnv("RESPONSE_TRUNCATION_MARKER", " …"),
+
+		SuggestionsMax:     getEnvAsInt("SUGGESTIONS_MAX", 3),
+		SuggestionsHistory: getEnvAsInt("SUGGESTIONS_HISTORY", 5),
+
+		DidYouMeanEnabled:       getEnvAsBool("DID_YOU_MEAN_ENABLED", true),
+		DidYouMeanVocabulary:    getEnvAsInt("DID_YOU_MEAN_VOCABULARY", 5000),
+		DidYouMeanMinSessions:   getEnvAsInt("DID_YOU_MEAN_MIN_SESSIONS", 3),
+		DidYouMeanWindowDays:    getEnvAsInt("DID_YOU_MEAN_WINDOW_DAYS", 30),
+		DidYouMeanRefreshS:      getEnvAsInt("DID_YOU_MEAN_REFRESH_INTERVAL", 3600),
+		DidYouMeanMinSimilarity: getEnvAsFloat("DID_YOU_MEAN_MIN_SIMILARITY", 0.6),
+
+		FeedbackSamplePercent:    getEnvAsInt("FEEDBACK_SAMPLE_PERCENT", 100),
+		FeedbackThemeBudget:      getEnvAsInt("FEEDBACK_THEME_BUDGET", 50),
+		FeedbackThemeConcurrency: getEnvAsInt("FEEDBACK_THEME_CONCURRENCY", 4),
+		FeedbackThemeExamples:    getEnvAsInt("FEEDBACK_THEME_EXAMPLES", 3),
+		FeedbackImportMaxRows:    getEnvAsInt("FEEDBACK_IMPORT_MAX_ROWS", 10000),
+		FeedbackImportMaxBytes:   int64(getEnvAsInt("FEEDBACK_IMPORT_MAX_BYTES", 10*1024*1024)),
+
+		KnowledgeGapIntervalH:     getEnvAsInt("KNOWLEDGE_GAP_INTERVAL_HOURS", 168),
+		KnowledgeGapWindowDays:    getEnvAsInt("KNOWLEDGE_GAP_WINDOW_DAYS", 7),
+		KnowledgeGapMaxQueries:    getEnvAsInt("KNOWLEDGE_GAP_MAX_QUERIES", 2000),
+		KnowledgeGapMaxClusters:   getEnvAsInt("KNOWLEDGE_GAP_MAX_CLUSTERS", 50),
+		KnowledgeGapBudgetS:       getEnvAsInt("KNOWLEDGE_GAP_BUDGET", 300),
+		KnowledgeGapMinSimilarity: getEnvAsFloat("KNOWLEDGE_GAP_MIN_SIMILARITY", 0.85),
+
+		BillingAggregationIntervalS: getEnvAsInt("BILLING_AGGREGATION_INTERVAL", 3600),
+		BillingBackfillDays:         getEnvAsInt("BILLING_BACKFILL_DAYS", 90),
+
+		IdempotencyWindowS: getEnvAsInt("IDEMPOTENCY_WINDOW", 86400),
+		IdempotencyWaitS:   getEnvAsInt("IDEMPOTENCY_WAIT", 30),
+		IdempotencyLockS:   getEnvAsInt("IDEMPOTENCY_LOCK_TTL", 120),
+
+		RequestTimeoutQueryS:   getEnvAsInt("REQUEST_TIMEOUT_QUERY", 25),
+		RequestTimeoutReadS:    getEnvAsInt("REQUEST_TIMEOUT_READ", 5),
+		RequestTimeoutDefaultS: getEnvAsInt("REQUEST_TIMEOUT_DEFAULT", 15),
+		MaxRequestBodyBytes:    int64(getEnvAsInt("MAX_REQUEST_BODY_BYTES", 1024*1024)),
+
+		CacheMaxAgeAnalyticsS: getEnvAsInt("CACHE_MAX_AGE_ANALYTICS", 30),
+		CacheMaxAgeDocsS:      getEnvAsInt("CACHE_MAX_AGE_DOCS", 5),
+		CacheMaxAgeFeedbackS:  getEnvAsInt("CACHE_MAX_AGE_FEEDBACK", 10),
+		CacheMaxAgeFAQS:       getEnvAsInt("CACHE_MAX_AGE_FAQ", 300),
+
+		CompressionLevel:    getEnvAsInt("COMPRESSION_LEVEL", 5),
+		CompressionMinBytes: getEnvAsInt("COMPRESSION_MIN_BYTES", 1024),
+
+		SessionAbandonAfterM:  getEnvAsInt("SESSION_ABANDON_AFTER", 30),
+		SessionSweepIntervalS: getEnvAsInt("SESSION_SWEEP_INTERVAL", 300),
+		SessionIdleMinutes:    getEnvAsInt("SESSION_IDLE_MINUTES", 0),
+
+		LogLevel:            getEnv("LOG_LEVEL", ""),
+		LogFormat:           getEnv("LOG_FORMAT", ""),
+		LogAccessSampleRate: getEnvAsInt("LOG_ACCESS_SAMPLE_RATE", 1),
+		LogScrubPatterns:    getEnvAsList("LOG_SCRUB_PATTERNS", nil),
+		LogQueryMaxLength:   getEnvAsInt("LOG_QUERY_MAX_LENGTH", 200),
+
+		SentryDSN:          getEnv("SENTRY_DSN", ""),
+		SentryRelease:      getEnv("SENTRY_RELEASE", ""),
+		SentrySampleRate:   getEnvAsFloat("SENTRY_SAMPLE_RATE", 1.0),
+		SentryQueueSize:    getEnvAsInt("SENTRY_QUEUE_SIZE", 100),
+		ErrorReportingMode: getEnv("ERROR_REPORTING_MODE", "panics_only"),
+
+		RAGTransport:       getEnv("RAG_TRANSPORT", "http"),
+		RAGGRPCAddress:     getEnv("RAG_GRPC_ADDRESS", "localhost:50051"),
+		RAGGRPCTLS:         getEnvAsBool("RAG_GRPC_TLS", false),
+		RAGGRPCCAFile:      getEnv("RAG_GRPC_CA_FILE", ""),
+		RAGGRPCServerName:  getEnv("RAG_GRPC_SERVER_NAME", ""),
+		RAGGRPCBackoffMaxS: getEnvAsInt("RAG_GRPC_BACKOFF_MAX", 30),
+
+		CacheNoCacheAfterNegative: getEnvAsInt("CACHE_NOCACHE_AFTER_NEGATIVE", 3),
+		CacheNoCacheTTLS:          getEnvAsInt("CACHE_NOCACHE_TTL", 86400),
+
+		CacheAdaptiveTTLPercent:  getEnvAsInt("CACHE_ADAPTIVE_TTL_PERCENT", 0),
+		CacheTTLMin:              getEnvAsInt("CACHE_TTL_MIN", 300),
+		CacheTTLMax:              getEnvAsInt("CACHE_TTL_MAX", 86400),
+		CacheTTLHotHits:          getEnvAsInt("CACHE_TTL_HOT_HITS", 50),
+		CacheMinHits:             getEnvAsInt("CACHE_MIN_HITS", 0),
+		CachePopularityHalfLifeS: getEnvAsInt("CACHE_POPULARITY_HALF_LIFE", 86400),
+
+		CacheWarmup:            getEnvAsBool("CACHE_WARMUP", false),
+		CacheWarmupQueries:     getEnvAsInt("CACHE_WARMUP_QUERIES", 50),
+		CacheWarmupConcurrency: getEnvAsInt("CACHE_WARMUP_CONCURRENCY", 2),
+		CacheWarmupBudgetS:     getEnvAsInt("CACHE_WARMUP_BUDGET", 120),
+
+		RegenerateMaxAttempts: getEnvAsInt("REGENERATE_MAX_ATTEMPTS", 2),
+		RegenerateTemperature: getEnvAsFloat("REGENERATE_TEMPERATURE", 0.9),
+		RegenerateModel:       getEnv("REGENERATE_MODEL", ""),
+
+		RAGMaxInFlight:    getEnvAsInt("RAG_MAX_IN_FLIGHT", 32),
+		RAGQueueMaxLength: getEnvAsInt("RAG_QUEUE_MAX_LENGTH", 256),
+		RAGQueueMaxWaitS:  getEnvAsInt("RAG_QUEUE_MAX_WAIT", 10),
+
+		VisitorFingerprintKey: getEnv("VISITOR_FINGERPRINT_KEY", ""),
+		VisitorSaltRotationS:  getEnvAsInt("VISITOR_SALT_ROTATION", 86400),
+
+		ObjectIngestMaxBytes: int64(getEnvAsInt("OBJECT_INGEST_MAX_BYTES", 256*1024*1024)),
+		ObjectIngestAllowedTypes: getEnvAsList("OBJECT_INGEST_ALLOWED_TYPES", []string{
+			"application/pdf",
+			"text/plain",
+			"text/markdown",
+			"text/html",
+			"text/csv",
+			"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		}),
+		ObjectIngestTimeoutS:   getEnvAsInt("OBJECT_INGEST_TIMEOUT", 1800),
+		ObjectStoreCredentials: getEnvAsList("OBJECT_STORE_CREDENTIALS", nil),
+		ObjectStoreS3Region:    getEnv("OBJECT_STORE_S3_REGION", "us-east-1"),
+		ObjectStoreS3Endpoint:  getEnv("OBJECT_STORE_S3_ENDPOINT", ""),
+
+		DocumentTextMaxBytes:   int64(getEnvAsInt("DOCUMENT_TEXT_MAX_BYTES", 1024*1024)),
+		DocumentQueueIntervalS: getEnvAsInt("DOCUMENT_QUEUE_INTERVAL", 30),
+
+		ClamAVHost:          getEnv("CLAMAV_HOST", ""),
+		ClamAVPort:          getEnvAsInt("CLAMAV_PORT", 3310),
+		MalwareScanMaxBytes: int64(getEnvAsInt("MALWARE_SCAN_MAX_BYTES", 25*1024*1024)),
+		MalwareScanTimeoutS: getEnvAsInt("MALWARE_SCAN_TIMEOUT", 30),
+
+		ShadowTestMaxSample:    getEnvAsInt("SHADOW_TEST_MAX_SAMPLE", 500),
+		ShadowTestConcurrency:  getEnvAsInt("SHADOW_TEST_CONCURRENCY", 4),
+		ShadowTestMaxDurationS: getEnvAsInt("SHADOW_TEST_MAX_DURATION", 900),
+		ShadowTestMaxTokens:    getEnvAsInt("SHADOW_TEST_MAX_TOKENS", 500000),
+
+		ReingestConcurrency: getEnvAsInt("REINGEST_CONCURRENCY", 2),
+		ReingestMaxAttempts: getEnvAsInt("REINGEST_MAX_ATTEMPTS", 3),
+
+		AttachmentMaxCount:        getEnvAsInt("ATTACHMENT_MAX_COUNT", 4),
+		AttachmentMaxBytes:        int64(getEnvAsInt("ATTACHMENT_MAX_BYTES", 5*1024*1024)),
+		AttachmentRetention:       getEnvAsBool("ATTACHMENT_RETENTION", false),
+		AttachmentRateLimitWeight: getEnvAsInt("ATTACHMENT_RATE_LIMIT_WEIGHT", 5),
+
+		RAGRateLimitMaxRetries:   getEnvAsInt("RAG_RATE_LIMIT_MAX_RETRIES", 2),
+		RAGRateLimitRetryDelayMs: getEnvAsInt("RAG_RATE_LIMIT_RETRY_DELAY_MS", 1000),
+		RAGRateLimitWindowS:      getEnvAsInt("RAG_RATE_LIMIT_WINDOW", 300),
 	}
 
-	AppConfig = config
-	return config, nil
+	if config.LogLevel == "" {
+		config.LogLevel = "info"
+		if config.IsDevelopment() {
+			config.LogLevel = "debug"
+		}
+	}
+	if config.LogFormat == "" {
+		config.LogFormat = logging.FormatJSON
+		if config.IsDevelopment() {
+			config.LogFormat = logging.FormatText
+		}
+	}
+
+	// Errors are reported under the deployment's environment by default
+	config.SentryEnvironment = getEnv("SENTRY_ENVIRONMENT", config.Environment)
+
+	// Only production rejects uploads the scanner couldn't check by default
+	config.MalwareScanFailOpen = getEnvAsBool("MALWARE_SCAN_FAIL_OPEN", !config.IsProduction())
+
+	config.ChaosMode = getEnvAsBool("CHAOS_MODE", false)
+
+	// Per-route rate limits default to the global limit
+	report := newReport()
+	defaultLimit := RateLimit{Requests: config.RateLimitRequests, WindowS: config.RateLimitWindow}
+	var err error
+	if config.RateLimitQuery, err = getEnvAsRateLimit("RATE_LIMIT_QUERY", defaultLimit); err != nil {
+		report.AddError("RATE_LIMIT_QUERY", "%v", err)
+	}
+	if config.RateLimitUpload, err = getEnvAsRateLimit("RATE_LIMIT_UPLOAD", defaultLimit); err != nil {
+		report.AddError("RATE_LIMIT_UPLOAD", "%v", err)
+	}
+	if config.RateLimitRead, err = getEnvAsRateLimit("RATE_LIMIT_READ", defaultLimit); err != nil {
+		report.AddError("RATE_LIMIT_READ", "%v", err)
+	}
+	if config.RateLimitClaim, err = getEnvAsRateLimit("RATE_LIMIT_CLAIM", RateLimit{Requests: 10, WindowS: 3600}); err != nil {
+		report.AddError("RATE_LIMIT_CLAIM", "%v", err)
+	}
+
+	config.validate(report)
+	return config, report
 }
 
 // getEnv gets an environment variable or returns a default value
@@ -94,6 +884,228 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getEnvAsFloat gets an environment variable as float or returns a default value
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseFloat(valueStr, 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvAsBool gets an environment variable as bool or returns a default value
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvAsList gets a comma-separated environment variable as a list or returns a default value
+func getEnvAsList(key string, defaultValue []string) []string {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	var values []string
+	for _, value := range strings.Split(valueStr, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvAsRateLimit parses "requests" or "requests/window_seconds" or returns a default value
+func getEnvAsRateLimit(key string, defaultValue RateLimit) (RateLimit, error) {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue, nil
+	}
+
+	limit, err := ParseRateLimit(valueStr, defaultValue)
+	if err != nil {
+		return limit, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return limit, nil
+}
+
+// ParseRateLimit parses "requests" or "requests/window_seconds", keeping the
+// default window when none is given
+func ParseRateLimit(value string, defaultValue RateLimit) (RateLimit, error) {
+	limit := defaultValue
+	requestsStr, windowStr, hasWindow := strings.Cut(value, "/")
+
+	requests, err := strconv.Atoi(strings.TrimSpace(requestsStr))
+	if err != nil || requests <= 0 {
+		return limit, fmt.Errorf("%q: expected requests or requests/window_seconds", value)
+	}
+	limit.Requests = requests
+
+	if hasWindow {
+		window, err := strconv.Atoi(strings.TrimSpace(windowStr))
+		if err != nil || window <= 0 {
+			return limit, fmt.Errorf("%q: expected requests or requests/window_seconds", value)
+		}
+		limit.WindowS = window
+	}
+
+	return limit, nil
+}
+
+// ParseCIDR parses a CIDR block, accepting a bare IP address as a single-host block
+func ParseCIDR(value string) (*net.IPNet, error) {
+	if !strings.Contains(value, "/") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address")
+		}
+		if ip.To4() != nil {
+			value += "/32"
+		} else {
+			value += "/128"
+		}
+	}
+
+	_, ipNet, err := net.ParseCIDR(value)
+	return ipNet, err
+}
+
+// ParseCIDRs parses a list of CIDR blocks
+func ParseCIDRs(values []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		ipNet, err := ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", value, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// DefaultDurationBuckets extend past a minute, since RAG calls regularly
+// take tens of seconds
+var DefaultDurationBuckets = []string{
+	"0.005", "0.01", "0.025", "0.05", "0.1", "0.25", "0.5", "1", "2.5", "5",
+	"10", "15", "20", "30", "40", "60",
+}
+
+// ParseBuckets parses histogram bucket upper bounds, which must be positive
+// and strictly ascending
+func ParseBuckets(entries []string) ([]float64, error) {
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("at least one bucket is required")
+	}
+
+	buckets := make([]float64, len(entries))
+	for i, entry := range entries {
+		bound, err := strconv.ParseFloat(entry, 64)
+		if err != nil || bound <= 0 {
+			return nil, fmt.Errorf("%q: bucket must be a positive number of seconds", entry)
+		}
+		if i > 0 && bound <= buckets[i-1] {
+			return nil, fmt.Errorf("%q: buckets must be in ascending order", entry)
+		}
+		buckets[i] = bound
+	}
+	return buckets, nil
+}
+
+// ParseModelLimits parses "model=tokens" entries into a map from model to
+// a positive token count
+func ParseModelLimits(entries []string) (map[string]int, error) {
+	limits := make(map[string]int, len(entries))
+	for _, entry := range entries {
+		model, value, ok := strings.Cut(entry, "=")
+		if !ok || model == "" {
+			return nil, fmt.Errorf("%q: expected model=tokens", entry)
+		}
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("%q: token count must be a positive integer", entry)
+		}
+		limits[model] = limit
+	}
+	return limits, nil
+}
+
+// ParseModelFactors parses "model=factor" entries into a map from model to
+// a positive factor
+func ParseModelFactors(entries []string) (map[string]float64, error) {
+	factors := make(map[string]float64, len(entries))
+	for _, entry := range entries {
+		model, value, ok := strings.Cut(entry, "=")
+		if !ok || model == "" {
+			return nil, fmt.Errorf("%q: expected model=factor", entry)
+		}
+		factor, err := strconv.ParseFloat(value, 64)
+		if err != nil || factor <= 0 {
+			return nil, fmt.Errorf("%q: factor must be a positive number", entry)
+		}
+		factors[model] = factor
+	}
+	return factors, nil
+}
+
+// Plan is a tier of service. Zero limits are unlimited.
+type Plan struct {
+	Name           string `json:"name"`
+	DailyQueries   int    `json:"daily_queries"`
+	MaxUploadBytes int64  `json:"max_upload_bytes"`
+	Streaming      bool   `json:"streaming"`
+}
+
+// ParsePlans parses "name=daily_queries:max_upload_bytes:streaming" entries
+// into a map from plan name to plan
+func ParsePlans(entries []string) (map[string]Plan, error) {
+	plans := make(map[string]Plan, len(entries))
+	for _, entry := range entries {
+		name, value, ok := strings.Cut(entry, "=")
+		parts := strings.Split(value, ":")
+		if !ok || name == "" || len(parts) != 3 {
+			return nil, fmt.Errorf("%q: expected name=daily_queries:max_upload_bytes:streaming", entry)
+		}
+		queries, err := strconv.Atoi(parts[0])
+		if err != nil || queries < 0 {
+			return nil, fmt.Errorf("%q: daily queries must be a non-negative integer", entry)
+		}
+		uploadBytes, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || uploadBytes < 0 {
+			return nil, fmt.Errorf("%q: max upload bytes must be a non-negative integer", entry)
+		}
+		streaming, err := strconv.ParseBool(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("%q: streaming must be true or false", entry)
+		}
+		if _, exists := plans[name]; exists {
+			return nil, fmt.Errorf("%q: plan %s is defined twice", entry, name)
+		}
+		plans[name] = Plan{Name: name, DailyQueries: queries, MaxUploadBytes: uploadBytes, Streaming: streaming}
+	}
+	return plans, nil
+}
+
+// ParseSigningKeys parses "key_id=secret" request signing keys into a map
+// from key ID to secret
+func ParseSigningKeys(entries []string) (map[string]string, error) {
+	keys := make(map[string]string, len(entries))
+	for i, entry := range entries {
+		id, secret, ok := strings.Cut(entry, "=")
+		if !ok || id == "" || secret == "" {
+			// The entry itself isn't quoted, it may hold a secret
+			return nil, fmt.Errorf("invalid signing key entry %d: expected key_id=secret", i+1)
+		}
+		if _, dup := keys[id]; dup {
+			return nil, fmt.Errorf("duplicate signing key ID %q", id)
+		}
+		keys[id] = secret
+	}
+	return keys, nil
+}
+
 // IsDevelopment returns true if running in development mode
 func (c *Config) IsDevelopment() bool {
 	return c.Environment == "development"
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParseCIDR(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"10.0.0.0/8", "10.0.0.0/8", false},
+		{"10.1.2.3/8", "10.0.0.0/8", false},
+		{"192.168.1.5", "192.168.1.5/32", false},
+		{"2001:db8::/32", "2001:db8::/32", false},
+		{"2001:db8::1", "2001:db8::1/128", false},
+		{"::1", "::1/128", false},
+		{"not-an-ip", "", true},
+		{"10.0.0.0/33", "", true},
+		{"2001:db8::/129", "", true},
+	}
+	for _, tt := range tests {
+		ipNet, err := ParseCIDR(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseCIDR(%q) = %v, want an error", tt.value, ipNet)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseCIDR(%q): %v", tt.value, err)
+			continue
+		}
+		if ipNet.String() != tt.want {
+			t.Errorf("ParseCIDR(%q) = %s, want %s", tt.value, ipNet, tt.want)
+		}
+	}
+}
+
+func TestParseCIDRs(t *testing.T) {
+	nets, err := ParseCIDRs([]string{"10.0.0.0/8", "fd00::/8"})
+	if err != nil {
+		t.Fatalf("ParseCIDRs: %v", err)
+	}
+	if len(nets) != 2 || !nets[1].Contains(net.ParseIP("fd12::1")) {
+		t.Errorf("ParseCIDRs = %v", nets)
+	}
+
+	if _, err := ParseCIDRs([]string{"10.0.0.0/8", "bogus"}); err == nil {
+		t.Error("ParseCIDRs accepted an invalid entry")
+	}
+}
@@ -0,0 +1,461 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ai-support-assistant/backend/internal/errortracking"
+	"github.com/ai-support-assistant/backend/internal/langdetect"
+	"github.com/ai-support-assistant/backend/internal/logging"
+	"github.com/ai-support-assistant/backend/internal/netguard"
+	"github.com/ai-support-assistant/backend/internal/objectstore"
+)
+
+// DefaultJWTSecret is the JWT secret used when JWT_SECRET isn't set; it is
+// public, so production must override it
+const DefaultJWTSecret = "your-secret-key-change-this"
+
+// Issue is a problem with one setting, named by its environment variable
+type Issue struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Report lists the problems found validating configuration. Errors stop the
+// server from starting; warnings are only logged.
+type Report struct {
+	Errors   []Issue `json:"errors"`
+	Warnings []Issue `json:"warnings"`
+}
+
+func newReport() *Report {
+	return &Report{Errors: []Issue{}, Warnings: []Issue{}}
+}
+
+// AddError records a setting the server can't start with
+func (r *Report) AddError(field, format string, args ...interface{}) {
+	r.Errors = append(r.Errors, Issue{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// AddWarning records a setting that is valid but likely a mistake
+func (r *Report) AddWarning(field, format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, Issue{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// HasError reports whether a setting has an error
+func (r *Report) HasError(field string) bool {
+	for _, issue := range r.Errors {
+		if issue.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns the errors as one error, or nil if there are none
+func (r *Report) Err() error {
+	switch len(r.Errors) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("%s", r.Errors[0].Message)
+	}
+
+	messages := make([]string, len(r.Errors))
+	for i, issue := range r.Errors {
+		messages[i] = issue.Message
+	}
+	return fmt.Errorf("%d configuration errors: %s", len(messages), strings.Join(messages, "; "))
+}
+
+// intSetting is an integer setting checked against a bound
+type intSetting struct {
+	name  string
+	value int
+}
+
+// validate checks every setting, recording problems in the report
+func (c *Config) validate(r *Report) {
+	if c.DatabaseURL == "" {
+		r.AddError("POSTGRES_URL", "POSTGRES_URL is required")
+	} else if strings.Contains(c.DatabaseURL, "://") {
+		// Key=value DSNs are accepted too and aren't URLs
+		checkURL(r, "POSTGRES_URL", c.DatabaseURL, "postgres", "postgresql")
+	}
+	checkURL(r, "REDIS_URL", c.RedisURL, "redis", "rediss")
+	checkURL(r, "RAG_SERVICE_FALLBACK_URL", c.RAGServiceFallbackURL, "http", "https")
+	checkURL(r, "SLACK_WEBHOOK_URL", c.SlackWebhookURL, "http", "https")
+	checkURL(r, "REPORT_BASE_URL", c.ReportBaseURL, "http", "https")
+	checkURL(r, "OBJECT_STORE_S3_ENDPOINT", c.ObjectStoreS3Endpoint, "http", "https")
+	checkURL(r, "OPENAI_BASE_URL", c.OpenAIBaseURL, "http", "https")
+	if c.ModerationMode != "off" {
+		checkURL(r, "MODERATION_URL", c.ModerationURL, "http", "https")
+	}
+
+	switch c.Environment {
+	case "development", "staging", "production", "test":
+	default:
+		r.AddWarning("GO_ENV", "GO_ENV %q is not one of development, staging, production, test", c.Environment)
+	}
+
+	if c.JWTSecret == "" {
+		r.AddError("JWT_SECRET", "JWT_SECRET must not be empty")
+	} else if c.JWTSecret == DefaultJWTSecret {
+		if c.IsProduction() {
+			r.AddError("JWT_SECRET", "JWT_SECRET must be changed from the default in production")
+		} else if c.AuthEnabled {
+			r.AddWarning("JWT_SECRET", "JWT_SECRET is the insecure default")
+		}
+	}
+	if c.IsProduction() && c.ChaosMode {
+		r.AddError("CHAOS_MODE", "CHAOS_MODE must not be enabled in production")
+	} else if c.ChaosMode {
+		r.AddWarning("CHAOS_MODE", "CHAOS_MODE is on, so admins can inject faults into dependencies")
+	}
+	if c.IsProduction() && !c.AuthEnabled {
+		r.AddWarning("AUTH_ENABLED", "AUTH_ENABLED is off in production, so queries, uploads and other user routes accept anonymous requests")
+	}
+
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		r.AddError("LOG_LEVEL", "invalid LOG_LEVEL: %v", err)
+	}
+	if !logging.IsValidFormat(c.LogFormat) {
+		r.AddError("LOG_FORMAT", "LOG_FORMAT must be one of json, text")
+	}
+	for _, pattern := range c.LogScrubPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			r.AddError("LOG_SCRUB_PATTERNS", "invalid LOG_SCRUB_PATTERNS entry %q: %v", pattern, err)
+		}
+	}
+
+	switch c.RAGTransport {
+	case "http":
+		if c.RAGServiceURL == "" {
+			r.AddError("RAG_SERVICE_URL", "RAG_SERVICE_URL is required when RAG_TRANSPORT is http")
+		} else {
+			checkURL(r, "RAG_SERVICE_URL", c.RAGServiceURL, "http", "https")
+		}
+	case "grpc":
+		if c.RAGGRPCAddress == "" {
+			r.AddError("RAG_GRPC_ADDRESS", "RAG_GRPC_ADDRESS is required when RAG_TRANSPORT is grpc")
+		}
+		if c.RAGGRPCBackoffMaxS <= 0 {
+			r.AddError("RAG_GRPC_BACKOFF_MAX", "RAG_GRPC_BACKOFF_MAX must be positive")
+		}
+	default:
+		r.AddError("RAG_TRANSPORT", "RAG_TRANSPORT must be one of http, grpc")
+	}
+
+	for _, timeout := range []intSetting{
+		{"REQUEST_TIMEOUT_QUERY", c.RequestTimeoutQueryS},
+		{"REQUEST_TIMEOUT_READ", c.RequestTimeoutReadS},
+		{"REQUEST_TIMEOUT_DEFAULT", c.RequestTimeoutDefaultS},
+	} {
+		if timeout.value < 0 || timeout.value >= ServerWriteTimeoutS {
+			r.AddError(timeout.name, "%s must be between 0 and %d seconds", timeout.name, ServerWriteTimeoutS-1)
+		}
+	}
+
+	for _, setting := range []intSetting{
+		{"RATE_LIMIT_REQUESTS", c.RateLimitRequests},
+		{"RATE_LIMIT_WINDOW", c.RateLimitWindow},
+		{"DB_CONNECT_ATTEMPTS", c.DBConnectAttempts},
+		{"DB_MAX_OPEN_CONNS", c.DBMaxOpenConns},
+		{"CACHE_FRESH_TTL", c.CacheTTL},
+		{"CACHE_TTL_MIN", c.CacheTTLMin},
+		{"CACHE_TTL_MAX", c.CacheTTLMax},
+		{"CACHE_POPULARITY_HALF_LIFE", c.CachePopularityHalfLifeS},
+		{"QUERY_COALESCE_TIMEOUT", c.QueryCoalesceTimeoutS},
+		{"QUERY_JOB_TIMEOUT", c.QueryJobTimeoutS},
+		{"CRAWL_TIMEOUT", c.CrawlTimeoutS},
+		{"CRAWL_MAX_PAGES", c.CrawlMaxPages},
+		{"WEBHOOK_MAX_ATTEMPTS", c.WebhookMaxAttempts},
+		{"IDEMPOTENCY_WINDOW", c.IdempotencyWindowS},
+		{"IDEMPOTENCY_LOCK_TTL", c.IdempotencyLockS},
+		{"ENCRYPTION_BATCH_SIZE", c.EncryptionBatchSize},
+		{"SIGNATURE_MAX_SKEW", c.SignatureMaxSkewS},
+		{"DEFAULT_CHUNK_SIZE", c.DefaultChunkSize},
+		{"PURGE_BATCH_SIZE", c.PurgeBatchSize},
+		{"FEATURE_FLAG_REFRESH_INTERVAL", c.FeatureFlagRefreshS},
+		{"VERIFICATION_TIMEOUT_MS", c.VerificationTimeoutMs},
+		{"TRANSLATION_TIMEOUT_MS", c.TranslationTimeoutMs},
+		{"CACHE_WARMUP_QUERIES", c.CacheWarmupQueries},
+		{"CACHE_WARMUP_CONCURRENCY", c.CacheWarmupConcurrency},
+		{"CACHE_WARMUP_BUDGET", c.CacheWarmupBudgetS},
+		{"RAG_RATE_LIMIT_RETRY_DELAY_MS", c.RAGRateLimitRetryDelayMs},
+		{"RAG_RATE_LIMIT_WINDOW", c.RAGRateLimitWindowS},
+		{"OBJECT_INGEST_TIMEOUT", c.ObjectIngestTimeoutS},
+		{"DOCUMENT_QUEUE_INTERVAL", c.DocumentQueueIntervalS},
+		{"MALWARE_SCAN_TIMEOUT", c.MalwareScanTimeoutS},
+		{"SENTRY_QUEUE_SIZE", c.SentryQueueSize},
+		{"SHADOW_TEST_MAX_SAMPLE", c.ShadowTestMaxSample},
+		{"SHADOW_TEST_CONCURRENCY", c.ShadowTestConcurrency},
+		{"REINGEST_CONCURRENCY", c.ReingestConcurrency},
+		{"REINGEST_MAX_ATTEMPTS", c.ReingestMaxAttempts},
+		{"SHADOW_TEST_MAX_DURATION", c.ShadowTestMaxDurationS},
+		{"SHADOW_TEST_MAX_TOKENS", c.ShadowTestMaxTokens},
+		{"ATTACHMENT_RATE_LIMIT_WEIGHT", c.AttachmentRateLimitWeight},
+		{"SLOW_REQUEST_THRESHOLD_MS", c.SlowRequestThresholdMs},
+		{"DID_YOU_MEAN_MIN_SESSIONS", c.DidYouMeanMinSessions},
+		{"FEEDBACK_IMPORT_MAX_ROWS", c.FeedbackImportMaxRows},
+		{"DID_YOU_MEAN_WINDOW_DAYS", c.DidYouMeanWindowDays},
+		{"DID_YOU_MEAN_REFRESH_INTERVAL", c.DidYouMeanRefreshS},
+		{"KNOWLEDGE_GAP_WINDOW_DAYS", c.KnowledgeGapWindowDays},
+		{"KNOWLEDGE_GAP_MAX_QUERIES", c.KnowledgeGapMaxQueries},
+		{"KNOWLEDGE_GAP_MAX_CLUSTERS", c.KnowledgeGapMaxClusters},
+		{"KNOWLEDGE_GAP_BUDGET", c.KnowledgeGapBudgetS},
+		{"BILLING_BACKFILL_DAYS", c.BillingBackfillDays},
+		{"OPENAI_TIMEOUT", c.OpenAITimeoutS},
+		{"DIRECT_CLASSIFIER_TIMEOUT_MS", c.DirectClassifierTimeoutMs},
+	} {
+		if setting.value <= 0 {
+			r.AddError(setting.name, "%s must be positive", setting.name)
+		}
+	}
+
+	for _, setting := range []intSetting{
+		{"SHUTDOWN_DRAIN_TIMEOUT", c.ShutdownDrainTimeoutS},
+		{"SHUTDOWN_DELAY", c.ShutdownDelayS},
+		{"CORS_MAX_AGE", c.CORSMaxAgeS},
+		{"DB_CONNECT_DELAY", c.DBConnectDelayS},
+		{"DB_CONN_MAX_LIFETIME", c.DBConnMaxLifetimeS},
+		{"DB_CONN_MAX_IDLE_TIME", c.DBConnMaxIdleTimeS},
+		{"DB_SLOW_QUERY_MS", c.DBSlowQueryMs},
+		{"CACHE_STALE_TTL", c.CacheStaleTTL},
+		{"CACHE_NOCACHE_AFTER_NEGATIVE", c.CacheNoCacheAfterNegative},
+		{"CACHE_NOCACHE_TTL", c.CacheNoCacheTTLS},
+		{"CACHE_MIN_HITS", c.CacheMinHits},
+		{"MODEL_CONTEXT_LIMIT", c.ModelContextLimit},
+		{"PROMPT_CHUNK_TOKENS", c.PromptChunkTokens},
+		{"PROMPT_RESERVE_TOKENS", c.PromptReserveTokens},
+		{"PROMPT_HISTORY_TURNS", c.PromptHistoryTurns},
+		{"PURGE_BATCH_DELAY_MS", c.PurgeBatchDelayMs},
+		{"REGENERATE_MAX_ATTEMPTS", c.RegenerateMaxAttempts},
+		{"RAG_MAX_IN_FLIGHT", c.RAGMaxInFlight},
+		{"RAG_RATE_LIMIT_MAX_RETRIES", c.RAGRateLimitMaxRetries},
+		{"RESPONSE_MAX_LENGTH", c.ResponseMaxLength},
+		{"ATTACHMENT_MAX_COUNT", c.AttachmentMaxCount},
+		{"KNOWLEDGE_GAP_INTERVAL_HOURS", c.KnowledgeGapIntervalH},
+		{"BILLING_AGGREGATION_INTERVAL", c.BillingAggregationIntervalS},
+		{"CACHE_MAX_AGE_ANALYTICS", c.CacheMaxAgeAnalyticsS},
+		{"CACHE_MAX_AGE_DOCS", c.CacheMaxAgeDocsS},
+		{"CACHE_MAX_AGE_FEEDBACK", c.CacheMaxAgeFeedbackS},
+		{"CACHE_MAX_AGE_FAQ", c.CacheMaxAgeFAQS},
+		{"COMPRESSION_MIN_BYTES", c.CompressionMinBytes},
+		{"OPENAI_MAX_RETRIES", c.OpenAIMaxRetries},
+	} {
+		if setting.value < 0 {
+			r.AddError(setting.name, "%s must not be negative", setting.name)
+		}
+	}
+
+	if c.AbuseDetectionEnabled && (c.AbuseWindowS <= 0 || c.AbuseBanDurationS <= 0) {
+		r.AddError("ABUSE_WINDOW", "ABUSE_WINDOW and ABUSE_BAN_DURATION must be positive when ABUSE_DETECTION_ENABLED is set")
+	}
+
+	if _, err := netguard.ParseCIDRs(c.OutboundAllowedCIDRs); err != nil {
+		r.AddError("OUTBOUND_ALLOWED_CIDRS", "invalid OUTBOUND_ALLOWED_CIDRS: %v", err)
+	}
+
+	if _, err := ParseSigningKeys(c.SigningKeys); err != nil {
+		r.AddError("SIGNING_KEYS", "invalid SIGNING_KEYS: %v", err)
+	}
+
+	if c.MaxRequestBodyBytes <= 0 {
+		r.AddError("MAX_REQUEST_BODY_BYTES", "MAX_REQUEST_BODY_BYTES must be positive")
+	}
+
+	if c.DBMaxIdleConns < 0 || c.DBMaxIdleConns > c.DBMaxOpenConns {
+		r.AddError("DB_MAX_IDLE_CONNS", "DB_MAX_IDLE_CONNS must be between 0 and DB_MAX_OPEN_CONNS (%d)", c.DBMaxOpenConns)
+	}
+
+	if _, err := ParseModelLimits(c.ModelContextLimits); err != nil {
+		r.AddError("MODEL_CONTEXT_LIMITS", "invalid MODEL_CONTEXT_LIMITS: %v", err)
+	}
+	if _, err := ParseModelFactors(c.TokenCalibration); err != nil {
+		r.AddError("TOKEN_CALIBRATION", "invalid TOKEN_CALIBRATION: %v", err)
+	}
+	if plans, err := ParsePlans(c.Plans); err != nil {
+		r.AddError("PLANS", "invalid PLANS: %v", err)
+	} else if _, ok := plans[c.DefaultPlan]; len(plans) > 0 && !ok {
+		r.AddError("DEFAULT_PLAN", "DEFAULT_PLAN %q is not one of PLANS", c.DefaultPlan)
+	}
+	if len(c.AllowedModels) > 0 && !slices.Contains(c.AllowedModels, c.OpenAIModel) {
+		r.AddWarning("ALLOWED_MODELS", "ALLOWED_MODELS doesn't list OPENAI_MODEL %q, which is always allowed", c.OpenAIModel)
+	}
+
+	if c.DefaultChunkSize > 0 && (c.DefaultChunkOverlap < 0 || c.DefaultChunkOverlap >= c.DefaultChunkSize) {
+		r.AddError("DEFAULT_CHUNK_OVERLAP", "DEFAULT_CHUNK_OVERLAP must be at least 0 and less than DEFAULT_CHUNK_SIZE")
+	}
+	if c.CacheAdaptiveTTLPercent < 0 || c.CacheAdaptiveTTLPercent > 100 {
+		r.AddError("CACHE_ADAPTIVE_TTL_PERCENT", "CACHE_ADAPTIVE_TTL_PERCENT must be between 0 and 100")
+	}
+	if c.CacheTTLMin > c.CacheTTLMax {
+		r.AddError("CACHE_TTL_MIN", "CACHE_TTL_MIN must not exceed CACHE_TTL_MAX (%d)", c.CacheTTLMax)
+	}
+	if c.CacheTTLHotHits < 2 {
+		r.AddError("CACHE_TTL_HOT_HITS", "CACHE_TTL_HOT_HITS must be at least 2")
+	}
+	if c.FeedbackSamplePercent < 0 || c.FeedbackSamplePercent > 100 {
+		r.AddError("FEEDBACK_SAMPLE_PERCENT", "FEEDBACK_SAMPLE_PERCENT must be between 0 and 100")
+	}
+	if c.RegenerateTemperature < 0 || c.RegenerateTemperature > 2 {
+		r.AddError("REGENERATE_TEMPERATURE", "REGENERATE_TEMPERATURE must be between 0 and 2")
+	}
+
+	if c.CompressionLevel < 0 || c.CompressionLevel > 9 {
+		r.AddError("COMPRESSION_LEVEL", "COMPRESSION_LEVEL must be between 0 and 9")
+	}
+
+	if c.RAGMaxInFlight > 0 && c.RAGQueueMaxLength < 0 {
+		r.AddError("RAG_QUEUE_MAX_LENGTH", "RAG_QUEUE_MAX_LENGTH must not be negative")
+	}
+	if c.RAGMaxInFlight > 0 && c.RAGQueueMaxWaitS <= 0 {
+		r.AddError("RAG_QUEUE_MAX_WAIT", "RAG_QUEUE_MAX_WAIT must be positive")
+	}
+
+	if c.VisitorFingerprintKey != "" && c.VisitorSaltRotationS <= 0 {
+		r.AddError("VISITOR_SALT_ROTATION", "VISITOR_SALT_ROTATION must be positive")
+	}
+
+	if c.ObjectIngestMaxBytes <= 0 {
+		r.AddError("OBJECT_INGEST_MAX_BYTES", "OBJECT_INGEST_MAX_BYTES must be positive")
+	}
+	if c.DocumentTextMaxBytes <= 0 {
+		r.AddError("DOCUMENT_TEXT_MAX_BYTES", "DOCUMENT_TEXT_MAX_BYTES must be positive")
+	}
+	if c.MalwareScanMaxBytes <= 0 {
+		r.AddError("MALWARE_SCAN_MAX_BYTES", "MALWARE_SCAN_MAX_BYTES must be positive")
+	}
+	if c.ClamAVHost != "" && (c.ClamAVPort <= 0 || c.ClamAVPort > 65535) {
+		r.AddError("CLAMAV_PORT", "CLAMAV_PORT must be between 1 and 65535")
+	}
+	if c.IsProduction() && c.ClamAVHost == "" {
+		r.AddWarning("CLAMAV_HOST", "CLAMAV_HOST is not set; uploads are not scanned for malware")
+	} else if c.IsProduction() && c.MalwareScanFailOpen {
+		r.AddWarning("MALWARE_SCAN_FAIL_OPEN", "MALWARE_SCAN_FAIL_OPEN accepts uploads unscanned while the scanner is unavailable")
+	}
+	if c.FeedbackImportMaxBytes <= 0 {
+		r.AddError("FEEDBACK_IMPORT_MAX_BYTES", "FEEDBACK_IMPORT_MAX_BYTES must be positive")
+	}
+	if c.AttachmentMaxBytes <= 0 {
+		r.AddError("ATTACHMENT_MAX_BYTES", "ATTACHMENT_MAX_BYTES must be positive")
+	}
+	if _, err := objectstore.ParseCredentials(c.ObjectStoreCredentials); err != nil {
+		r.AddError("OBJECT_STORE_CREDENTIALS", "invalid OBJECT_STORE_CREDENTIALS: %v", err)
+	}
+
+	for _, pattern := range c.CacheBypassPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			r.AddError("CACHE_BYPASS_PATTERNS", "invalid CACHE_BYPASS_PATTERNS entry %q: %v", pattern, err)
+		}
+	}
+
+	for _, setting := range []struct {
+		name     string
+		patterns []string
+	}{
+		{"DIRECT_QUERY_PATTERNS", c.DirectQueryPatterns},
+		{"RAG_QUERY_PATTERNS", c.RAGQueryPatterns},
+	} {
+		for _, pattern := range setting.patterns {
+			if _, err := regexp.Compile(pattern); err != nil {
+				r.AddError(setting.name, "invalid %s entry %q: %v", setting.name, pattern, err)
+			}
+		}
+	}
+	if (len(c.DirectQueryPatterns) > 0 || c.DirectClassifier) && c.OpenAIKey == "" {
+		r.AddWarning("OPENAI_API_KEY", "OPENAI_API_KEY is not set, so direct answers are off and every query goes to the RAG service")
+	}
+
+	for _, cidr := range c.TrustedProxies {
+		if _, err := ParseCIDR(cidr); err != nil {
+			r.AddError("TRUSTED_PROXIES", "invalid TRUSTED_PROXIES entry %q: %v", cidr, err)
+		}
+	}
+
+	for _, cidr := range c.MetricsAllowedCIDRs {
+		if _, err := ParseCIDR(cidr); err != nil {
+			r.AddError("METRICS_ALLOWED_CIDRS", "invalid METRICS_ALLOWED_CIDRS entry %q: %v", cidr, err)
+		}
+	}
+	if _, err := ParseBuckets(c.MetricsHTTPBuckets); err != nil {
+		r.AddError("METRICS_HTTP_BUCKETS", "invalid METRICS_HTTP_BUCKETS: %v", err)
+	}
+	if _, err := ParseBuckets(c.MetricsRAGBuckets); err != nil {
+		r.AddError("METRICS_RAG_BUCKETS", "invalid METRICS_RAG_BUCKETS: %v", err)
+	}
+	if c.IsProduction() && c.MetricsAuthToken == "" && len(c.MetricsAllowedCIDRs) == 0 {
+		r.AddWarning("METRICS_AUTH_TOKEN", "/metrics is open to anyone: set METRICS_AUTH_TOKEN or METRICS_ALLOWED_CIDRS")
+	}
+
+	switch c.ModerationMode {
+	case "off", "log-only", "enforce":
+	default:
+		r.AddError("MODERATION_MODE", "MODERATION_MODE must be one of off, log-only, enforce")
+	}
+	switch c.VerificationMode {
+	case "off", "log", "enforce":
+	default:
+		r.AddError("VERIFICATION_MODE", "VERIFICATION_MODE must be one of off, log, enforce")
+	}
+	if c.VerificationMinScore < 0 || c.VerificationMinScore > 1 {
+		r.AddError("VERIFICATION_MIN_SCORE", "VERIFICATION_MIN_SCORE must be between 0 and 1")
+	}
+	if c.SentryDSN != "" {
+		if _, _, err := errortracking.ParseDSN(c.SentryDSN); err != nil {
+			r.AddError("SENTRY_DSN", "SENTRY_DSN is not a valid DSN: %v", err)
+		}
+	}
+	if c.SentrySampleRate < 0 || c.SentrySampleRate > 1 {
+		r.AddError("SENTRY_SAMPLE_RATE", "SENTRY_SAMPLE_RATE must be between 0 and 1")
+	}
+	switch c.ErrorReportingMode {
+	case errortracking.ModePanicsOnly, errortracking.ModeAll5xx:
+	default:
+		r.AddError("ERROR_REPORTING_MODE", "ERROR_REPORTING_MODE must be one of panics_only, all_5xx")
+	}
+	switch c.ResponseLanguageMode {
+	case "match_user", "auto":
+	case "fixed":
+		if !slices.Contains(langdetect.Languages(), c.ResponseLanguage) {
+			r.AddError("RESPONSE_LANGUAGE", "RESPONSE_LANGUAGE must be one of %s", strings.Join(langdetect.Languages(), ", "))
+		}
+	default:
+		r.AddError("RESPONSE_LANGUAGE_MODE", "RESPONSE_LANGUAGE_MODE must be one of match_user, fixed, auto")
+	}
+	if c.DidYouMeanVocabulary <= 0 || c.DidYouMeanVocabulary > MaxDidYouMeanVocabulary {
+		r.AddError("DID_YOU_MEAN_VOCABULARY", "DID_YOU_MEAN_VOCABULARY must be between 1 and %d", MaxDidYouMeanVocabulary)
+	}
+	if c.DidYouMeanMinSimilarity <= 0 || c.DidYouMeanMinSimilarity > 1 {
+		r.AddError("DID_YOU_MEAN_MIN_SIMILARITY", "DID_YOU_MEAN_MIN_SIMILARITY must be greater than 0 and at most 1")
+	}
+	if c.KnowledgeGapMinSimilarity <= 0 || c.KnowledgeGapMinSimilarity > 1 {
+		r.AddError("KNOWLEDGE_GAP_MIN_SIMILARITY", "KNOWLEDGE_GAP_MIN_SIMILARITY must be greater than 0 and at most 1")
+	}
+}
+
+// checkURL records an error unless a non-empty value is an absolute URL
+// with one of the schemes
+func checkURL(r *Report, field, value string, schemes ...string) {
+	if value == "" {
+		return
+	}
+
+	// The parse error isn't reported, as it quotes the URL and any credentials in it
+	u, err := url.Parse(value)
+	if err != nil {
+		r.AddError(field, "%s is not a valid URL", field)
+		return
+	}
+	if !slices.Contains(schemes, strings.ToLower(u.Scheme)) {
+		r.AddError(field, "%s must use one of the schemes %s", field, strings.Join(schemes, ", "))
+		return
+	}
+	if u.Host == "" {
+		r.AddError(field, "%s must include a host", field)
+	}
+}
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// validConfig loads the defaults with the one required setting supplied
+func validConfig(t *testing.T) *Config {
+	t.Helper()
+	t.Setenv("POSTGRES_URL", "postgres://support@db:5432/support")
+	cfg, report := Check()
+	if err := report.Err(); err != nil {
+		t.Fatalf("defaults don't validate: %v", err)
+	}
+	return cfg
+}
+
+func TestValidateDefaultsHaveNoErrors(t *testing.T) {
+	cfg := validConfig(t)
+	report := newReport()
+	cfg.validate(report)
+	if len(report.Errors) != 0 {
+		t.Errorf("errors = %+v, want none", report.Errors)
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		field  string
+		fail   func(*Config)
+		pass   func(*Config)
+		asWarn bool
+	}{
+		{"database required", "POSTGRES_URL",
+			func(c *Config) { c.DatabaseURL = "" },
+			func(c *Config) { c.DatabaseURL = "host=db user=support dbname=support" }, false},
+		{"database scheme", "POSTGRES_URL",
+			func(c *Config) { c.DatabaseURL = "mysql://db/support" },
+			func(c *Config) { c.DatabaseURL = "postgresql://db/support" }, false},
+		{"rag url scheme", "RAG_SERVICE_URL",
+			func(c *Config) { c.RAGServiceURL = "ftp://rag:8000" },
+			func(c *Config) { c.RAGServiceURL = "https://rag.internal" }, false},
+		{"rag url host", "RAG_SERVICE_URL",
+			func(c *Config) { c.RAGServiceURL = "http://" },
+			func(c *Config) { c.RAGServiceURL = "http://rag:8000" }, false},
+		{"rag url unparseable", "RAG_SERVICE_URL",
+			func(c *Config) { c.RAGServiceURL = "http://rag:port" },
+			func(c *Config) { c.RAGServiceURL = "http://rag:8000" }, false},
+		{"rag url required for http", "RAG_SERVICE_URL",
+			func(c *Config) { c.RAGTransport, c.RAGServiceURL = "http", "" },
+			func(c *Config) { c.RAGTransport, c.RAGServiceURL, c.RAGGRPCAddress = "grpc", "", "rag:50051" }, false},
+		{"rag grpc address", "RAG_GRPC_ADDRESS",
+			func(c *Config) { c.RAGTransport, c.RAGGRPCAddress = "grpc", "" },
+			func(c *Config) { c.RAGTransport, c.RAGGRPCAddress = "grpc", "rag:50051" }, false},
+		{"rag transport", "RAG_TRANSPORT",
+			func(c *Config) { c.RAGTransport = "carrier-pigeon" },
+			func(c *Config) { c.RAGTransport = "http" }, false},
+		{"redis scheme", "REDIS_URL",
+			func(c *Config) { c.RedisURL = "http://cache:6379" },
+			func(c *Config) { c.RedisURL = "rediss://cache:6379" }, false},
+		{"rate limit window zero", "RATE_LIMIT_WINDOW",
+			func(c *Config) { c.RateLimitWindow = 0 },
+			func(c *Config) { c.RateLimitWindow = 60 }, false},
+		{"rate limit requests negative", "RATE_LIMIT_REQUESTS",
+			func(c *Config) { c.RateLimitRequests = -1 },
+			func(c *Config) { c.RateLimitRequests = 1 }, false},
+		{"non-negative setting", "SHUTDOWN_DELAY",
+			func(c *Config) { c.ShutdownDelayS = -1 },
+			func(c *Config) { c.ShutdownDelayS = 0 }, false},
+		{"request timeout beyond write timeout", "REQUEST_TIMEOUT_QUERY",
+			func(c *Config) { c.RequestTimeoutQueryS = ServerWriteTimeoutS },
+			func(c *Config) { c.RequestTimeoutQueryS = ServerWriteTimeoutS - 1 }, false},
+		{"default jwt secret in production", "JWT_SECRET",
+			func(c *Config) { c.Environment, c.JWTSecret = "production", DefaultJWTSecret },
+			func(c *Config) { c.Environment, c.JWTSecret = "production", "a-real-secret" }, false},
+		{"empty jwt secret", "JWT_SECRET",
+			func(c *Config) { c.JWTSecret = "" },
+			func(c *Config) { c.JWTSecret = DefaultJWTSecret }, false},
+		{"default jwt secret with auth", "JWT_SECRET",
+			func(c *Config) { c.AuthEnabled, c.JWTSecret = true, DefaultJWTSecret },
+			func(c *Config) { c.AuthEnabled, c.JWTSecret = true, "a-real-secret" }, true},
+		{"chaos mode in production", "CHAOS_MODE",
+			func(c *Config) { c.Environment, c.ChaosMode = "production", true },
+			func(c *Config) { c.Environment, c.ChaosMode = "production", false }, false},
+		{"unknown environment", "GO_ENV",
+			func(c *Config) { c.Environment = "prod" },
+			func(c *Config) { c.Environment = "staging" }, true},
+		{"log level", "LOG_LEVEL",
+			func(c *Config) { c.LogLevel = "loud" },
+			func(c *Config) { c.LogLevel = "debug" }, false},
+		{"log format", "LOG_FORMAT",
+			func(c *Config) { c.LogFormat = "xml" },
+			func(c *Config) { c.LogFormat = "text" }, false},
+		{"log scrub pattern", "LOG_SCRUB_PATTERNS",
+			func(c *Config) { c.LogScrubPatterns = []string{"(unclosed"} },
+			func(c *Config) { c.LogScrubPatterns = []string{`\d{16}`} }, false},
+		{"idle above open connections", "DB_MAX_IDLE_CONNS",
+			func(c *Config) { c.DBMaxOpenConns, c.DBMaxIdleConns = 5, 6 },
+			func(c *Config) { c.DBMaxOpenConns, c.DBMaxIdleConns = 5, 5 }, false},
+		{"chunk overlap", "DEFAULT_CHUNK_OVERLAP",
+			func(c *Config) { c.DefaultChunkSize, c.DefaultChunkOverlap = 500, 500 },
+			func(c *Config) { c.DefaultChunkSize, c.DefaultChunkOverlap = 500, 499 }, false},
+		{"cache ttl bounds", "CACHE_TTL_MIN",
+			func(c *Config) { c.CacheTTLMin, c.CacheTTLMax = 600, 60 },
+			func(c *Config) { c.CacheTTLMin, c.CacheTTLMax = 60, 60 }, false},
+		{"feedback sample percent", "FEEDBACK_SAMPLE_PERCENT",
+			func(c *Config) { c.FeedbackSamplePercent = 101 },
+			func(c *Config) { c.FeedbackSamplePercent = 100 }, false},
+		{"compression level", "COMPRESSION_LEVEL",
+			func(c *Config) { c.CompressionLevel = 10 },
+			func(c *Config) { c.CompressionLevel = 9 }, false},
+		{"moderation mode", "MODERATION_MODE",
+			func(c *Config) { c.ModerationMode = "strict" },
+			func(c *Config) { c.ModerationMode = "log-only" }, false},
+		{"verification score", "VERIFICATION_MIN_SCORE",
+			func(c *Config) { c.VerificationMinScore = 1.5 },
+			func(c *Config) { c.VerificationMinScore = 1 }, false},
+		{"trusted proxy", "TRUSTED_PROXIES",
+			func(c *Config) { c.TrustedProxies = []string{"10.0.0.0/33"} },
+			func(c *Config) { c.TrustedProxies = []string{"10.0.0.0/8"} }, false},
+		{"fixed response language", "RESPONSE_LANGUAGE",
+			func(c *Config) { c.ResponseLanguageMode, c.ResponseLanguage = "fixed", "klingon" },
+			func(c *Config) { c.ResponseLanguageMode, c.ResponseLanguage = "fixed", "en" }, false},
+		{"clamav port", "CLAMAV_PORT",
+			func(c *Config) { c.ClamAVHost, c.ClamAVPort = "clamav", 70000 },
+			func(c *Config) { c.ClamAVHost, c.ClamAVPort = "clamav", 3310 }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := validConfig(t)
+			for _, check := range []struct {
+				modify func(*Config)
+				want   bool
+			}{{tt.fail, true}, {tt.pass, false}} {
+				cfg := *base
+				check.modify(&cfg)
+				report := newReport()
+				cfg.validate(report)
+
+				issues := report.Errors
+				if tt.asWarn {
+					issues = report.Warnings
+				}
+				found := false
+				for _, issue := range issues {
+					found = found || issue.Field == tt.field
+				}
+				if found != check.want {
+					t.Errorf("%s reported = %t, want %t (errors %+v, warnings %+v)", tt.field, found, check.want, report.Errors, report.Warnings)
+				}
+			}
+		})
+	}
+}
+
+func TestReportErr(t *testing.T) {
+	report := newReport()
+	if report.Err() != nil {
+		t.Error("empty report has an error")
+	}
+
+	report.AddError("RATE_LIMIT_WINDOW", "RATE_LIMIT_WINDOW must be positive")
+	if err := report.Err(); err == nil || err.Error() != "RATE_LIMIT_WINDOW must be positive" {
+		t.Errorf("Err = %v, want the single message", err)
+	}
+
+	report.AddError("LOG_FORMAT", "LOG_FORMAT must be one of json, text")
+	err := report.Err()
+	if err == nil || !strings.HasPrefix(err.Error(), "2 configuration errors: ") || !strings.Contains(err.Error(), "LOG_FORMAT") {
+		t.Errorf("Err = %v, want both errors", err)
+	}
+	if !report.HasError("LOG_FORMAT") || report.HasError("REDIS_URL") {
+		t.Error("HasError doesn't match the recorded fields")
+	}
+}
+
+func TestCheckURLHidesCredentials(t *testing.T) {
+	report := newReport()
+	checkURL(report, "POSTGRES_URL", "postgres://admin:hunter2@db:port/support", "postgres")
+	if len(report.Errors) != 1 || strings.Contains(report.Errors[0].Message, "hunter2") {
+		t.Errorf("errors = %+v, want one error without the password", report.Errors)
+	}
+}
@@ -0,0 +1,184 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrDisallowed is returned when robots.txt forbids fetching a URL
+var ErrDisallowed = errors.New("disallowed by robots.txt")
+
+// ErrUnsupportedContent is returned for responses that are not HTML or plain text
+var ErrUnsupportedContent = errors.New("unsupported content type")
+
+// Page is a fetched page reduced to text
+type Page struct {
+	URL         string
+	Title       string
+	Text        string
+	ContentType string
+}
+
+// Fetcher fetches pages and sitemaps, honouring robots.txt. Robots rules are
+// cached per host for the lifetime of the Fetcher.
+type Fetcher struct {
+	client       *http.Client
+	userAgent    string
+	maxBodyBytes int64
+
+	mu     sync.Mutex
+	robots map[string]*Robots
+}
+
+// NewFetcher creates a fetcher whose requests go through transport, which
+// decides which addresses pages and redirects may be fetched from
+func NewFetcher(userAgent string, timeout time.Duration, maxBodyBytes int64, transport http.RoundTripper) *Fetcher {
+	return &Fetcher{
+		client:       &http.Client{Timeout: timeout, Transport: transport},
+		userAgent:    userAgent,
+		maxBodyBytes: maxBodyBytes,
+		robots:       make(map[string]*Robots),
+	}
+}
+
+// Allowed reports whether robots.txt on the URL's host permits fetching it.
+// A missing or unreadable robots.txt allows everything.
+func (f *Fetcher) Allowed(ctx context.Context, u *url.URL) bool {
+	host := u.Scheme + "://" + u.Host
+
+	f.mu.Lock()
+	robots, ok := f.robots[host]
+	f.mu.Unlock()
+
+	if !ok {
+		robots = f.loadRobots(ctx, host)
+		f.mu.Lock()
+		f.robots[host] = robots
+		f.mu.Unlock()
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return robots.Allowed(path)
+}
+
+// loadRobots fetches and parses robots.txt for a host
+func (f *Fetcher) loadRobots(ctx context.Context, host string) *Robots {
+	resp, err := f.get(ctx, host+"/robots.txt")
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	return ParseRobots(io.LimitReader(resp.Body, f.maxBodyBytes), f.userAgent)
+}
+
+// Fetch downloads a page and extracts its text
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Page, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if !f.Allowed(ctx, u) {
+		return nil, ErrDisallowed
+	}
+
+	resp, err := f.get(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	body := io.LimitReader(resp.Body, f.maxBodyBytes)
+
+	page := &Page{URL: resp.Request.URL.String(), ContentType: contentType}
+	switch contentType {
+	case "text/html", "application/xhtml+xml", "":
+		page.Title, page.Text = ExtractText(body)
+	case "text/plain":
+		text, err := io.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		page.Text = strings.TrimSpace(string(text))
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedContent, contentType)
+	}
+
+	return page, nil
+}
+
+// Sitemap returns up to limit page URLs from a sitemap, following a
+// sitemap index one level deep
+func (f *Fetcher) Sitemap(ctx context.Context, rawURL string, limit int) ([]string, error) {
+	pages, children, err := f.fetchSitemap(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, child := range children {
+		if len(pages) >= limit {
+			break
+		}
+		childPages, _, err := f.fetchSitemap(ctx, child)
+		if err != nil {
+			continue // one broken child sitemap shouldn't fail the crawl
+		}
+		pages = append(pages, childPages...)
+	}
+
+	if len(pages) > limit {
+		pages = pages[:limit]
+	}
+	return pages, nil
+}
+
+// fetchSitemap downloads and parses a single sitemap document
+func (f *Fetcher) fetchSitemap(ctx context.Context, rawURL string) ([]string, []string, error) {
+	resp, err := f.get(ctx, rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("sitemap status %d", resp.StatusCode)
+	}
+
+	pages, children, err := ParseSitemap(io.LimitReader(resp.Body, f.maxBodyBytes))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid sitemap: %w", err)
+	}
+	return pages, children, nil
+}
+
+// get issues a GET request with the crawler's user agent
+func (f *Fetcher) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+	return f.client.Do(req)
+}
@@ -0,0 +1,89 @@
+package crawler
+
+import (
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// skippedElements hold no readable page content
+var skippedElements = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true,
+	"svg": true, "iframe": true, "nav": true, "footer": true, "head": true,
+}
+
+// blockElements end a line of text
+var blockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "section": true, "article": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"pre": true, "blockquote": true, "table": true, "ul": true, "ol": true, "header": true,
+}
+
+// ExtractText returns the title and readable text of an HTML page, with
+// scripts, styles and navigation removed and whitespace collapsed
+func ExtractText(r io.Reader) (title, text string) {
+	tokenizer := html.NewTokenizer(r)
+
+	var b strings.Builder
+	var titleBuilder strings.Builder
+	skipDepth := 0
+	inTitle := false
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return collapse(titleBuilder.String()), collapseLines(b.String())
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := tokenizer.TagName()
+			tag := string(name)
+			if tag == "title" {
+				inTitle = true
+			}
+			if skippedElements[tag] && tag != "head" {
+				skipDepth++
+			}
+			if blockElements[tag] {
+				b.WriteString("\n")
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			tag := string(name)
+			if tag == "title" {
+				inTitle = false
+			}
+			if skippedElements[tag] && tag != "head" && skipDepth > 0 {
+				skipDepth--
+			}
+			if blockElements[tag] {
+				b.WriteString("\n")
+			}
+		case html.TextToken:
+			data := string(tokenizer.Text())
+			if inTitle {
+				titleBuilder.WriteString(data)
+				continue
+			}
+			if skipDepth == 0 {
+				b.WriteString(data)
+				b.WriteString(" ")
+			}
+		}
+	}
+}
+
+// collapse joins whitespace runs into single spaces
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// collapseLines collapses whitespace within lines and drops empty lines
+func collapseLines(s string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = collapse(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,95 @@
+package crawler
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Robots holds the robots.txt rules that apply to the crawler
+type Robots struct {
+	rules []robotsRule
+}
+
+// robotsRule is an Allow or Disallow path prefix
+type robotsRule struct {
+	prefix string
+	allow  bool
+}
+
+// ParseRobots parses robots.txt, keeping the rules of the group for userAgent
+// if there is one, otherwise the rules of the "*" group
+func ParseRobots(r io.Reader, userAgent string) *Robots {
+	agent := strings.ToLower(userAgent)
+	if i := strings.Index(agent, "/"); i >= 0 {
+		agent = agent[:i]
+	}
+
+	var specific, wildcard []robotsRule
+	var groupAgents []string
+	inRules := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// A user-agent line after rules starts a new group
+			if inRules {
+				groupAgents = nil
+				inRules = false
+			}
+			groupAgents = append(groupAgents, strings.ToLower(value))
+		case "allow", "disallow":
+			inRules = true
+			if key == "disallow" && value == "" {
+				continue // empty Disallow allows everything
+			}
+			rule := robotsRule{prefix: value, allow: key == "allow"}
+			for _, groupAgent := range groupAgents {
+				switch {
+				case groupAgent == "*":
+					wildcard = append(wildcard, rule)
+				case agent != "" && strings.Contains(agent, groupAgent):
+					specific = append(specific, rule)
+				}
+			}
+		}
+	}
+
+	if specific != nil {
+		return &Robots{rules: specific}
+	}
+	return &Robots{rules: wildcard}
+}
+
+// Allowed reports whether path may be crawled. The longest matching rule wins
+// and Allow wins ties, as in Google's implementation.
+func (r *Robots) Allowed(path string) bool {
+	if r == nil {
+		return true
+	}
+
+	allowed := true
+	matched := -1
+	for _, rule := range r.rules {
+		if !strings.HasPrefix(path, rule.prefix) {
+			continue
+		}
+		if len(rule.prefix) > matched || (len(rule.prefix) == matched && rule.allow) {
+			matched = len(rule.prefix)
+			allowed = rule.allow
+		}
+	}
+	return allowed
+}
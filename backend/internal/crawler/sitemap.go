@@ -0,0 +1,49 @@
+package crawler
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// sitemapDocument covers both <urlset> and <sitemapindex> documents
+type sitemapDocument struct {
+	XMLName  xml.Name
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// ParseSitemap returns the page URLs of a <urlset> sitemap, or the child
+// sitemap URLs of a <sitemapindex>
+func ParseSitemap(r io.Reader) (pages []string, sitemaps []string, err error) {
+	var doc sitemapDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, nil, err
+	}
+
+	for _, u := range doc.URLs {
+		if loc := strings.TrimSpace(u.Loc); loc != "" {
+			pages = append(pages, loc)
+		}
+	}
+	for _, s := range doc.Sitemaps {
+		if loc := strings.TrimSpace(s.Loc); loc != "" {
+			sitemaps = append(sitemaps, loc)
+		}
+	}
+
+	return pages, sitemaps, nil
+}
+
+// IsSitemapURL reports whether a URL looks like a sitemap rather than a page
+func IsSitemapURL(rawURL string) bool {
+	path := strings.ToLower(rawURL)
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	return strings.HasSuffix(path, ".xml")
+}
@@ -0,0 +1,167 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// prefix marks encrypted values: "enc:<key id>:<base64 nonce+ciphertext>"
+const prefix = "enc:"
+
+// ErrUnknownKey is returned when a value was encrypted with a key that is not configured
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// Cipher encrypts values with AES-256-GCM under the primary key and decrypts
+// values written under the primary key or any previous key, so keys can be
+// rotated without re-encrypting everything at once
+type Cipher struct {
+	primaryID string
+	keys      map[string]cipher.AEAD
+	hashKey   []byte
+}
+
+// New creates a cipher. Keys are base64 or hex encoded 32-byte keys; previous
+// maps key IDs to keys that are still accepted for decryption. hashKey keys
+// Hash and, unlike the encryption key, must not change across rotations.
+func New(keyID, key string, previous map[string]string, hashKey string) (*Cipher, error) {
+	if keyID == "" || strings.Contains(keyID, ":") {
+		return nil, fmt.Errorf("invalid key id %q", keyID)
+	}
+
+	c := &Cipher{primaryID: keyID, keys: make(map[string]cipher.AEAD), hashKey: []byte(hashKey)}
+	for id, value := range previous {
+		if strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid key id %q", id)
+		}
+		aead, err := newAEAD(value)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		c.keys[id] = aead
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, fmt.Errorf("key %q: %w", keyID, err)
+	}
+	c.keys[keyID] = aead
+
+	return c, nil
+}
+
+// ParseKeyList parses "id=key" entries into a key map
+func ParseKeyList(entries []string) (map[string]string, error) {
+	keys := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		id, key, ok := strings.Cut(entry, "=")
+		if !ok || id == "" || key == "" {
+			return nil, fmt.Errorf("invalid key entry %q: expected id=key", entry)
+		}
+		keys[id] = key
+	}
+	return keys, nil
+}
+
+// newAEAD decodes a 32-byte key and creates an AES-GCM instance for it
+func newAEAD(encoded string) (cipher.AEAD, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		key, err = hex.DecodeString(encoded)
+	}
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("key must be 32 bytes, base64 or hex encoded")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt encrypts a value under the primary key. Empty values stay empty.
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	aead := c.keys[c.primaryID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(c.primaryID))
+	return prefix + c.primaryID + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value. Values without the encryption prefix are returned
+// unchanged so rows written before encryption was enabled remain readable.
+func (c *Cipher) Decrypt(value string) (string, error) {
+	keyID, payload, ok := split(value)
+	if !ok {
+		return value, nil
+	}
+
+	aead, found := c.keys[keyID]
+	if !found {
+		return "", fmt.Errorf("%w %q", ErrUnknownKey, keyID)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed ciphertext")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(keyID))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// NeedsReencryption reports whether a value is plaintext or was encrypted
+// under a key other than the primary one
+func (c *Cipher) NeedsReencryption(value string) bool {
+	if value == "" {
+		return false
+	}
+	keyID, _, ok := split(value)
+	return !ok || keyID != c.primaryID
+}
+
+// Hash returns a stable hex digest of a value, keyed with the hash key when
+// one is configured so short values can't be recovered by guessing
+func (c *Cipher) Hash(value string) string {
+	if c == nil || len(c.hashKey) == 0 {
+		sum := sha256.Sum256([]byte(value))
+		return hex.EncodeToString(sum[:])
+	}
+
+	mac := hmac.New(sha256.New, c.hashKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsEncrypted reports whether a value carries the encryption prefix
+func IsEncrypted(value string) bool {
+	_, _, ok := split(value)
+	return ok
+}
+
+// split separates an encrypted value into its key ID and payload
+func split(value string) (keyID, payload string, ok bool) {
+	if !strings.HasPrefix(value, prefix) {
+		return "", "", false
+	}
+	return strings.Cut(value[len(prefix):], ":")
+}
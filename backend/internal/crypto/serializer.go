@@ -0,0 +1,75 @@
+package crypto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+
+	"gorm.io/gorm/schema"
+)
+
+// active is the cipher used by the "encrypted" serializer. While it is nil,
+// values are written as plaintext.
+var active atomic.Pointer[Cipher]
+
+func init() {
+	schema.RegisterSerializer("encrypted", Serializer{})
+}
+
+// Enable makes the encrypted serializer encrypt with c
+func Enable(c *Cipher) {
+	active.Store(c)
+}
+
+// Active returns the configured cipher, or nil when encryption is disabled
+func Active() *Cipher {
+	return active.Load()
+}
+
+// Serializer transparently encrypts string fields tagged
+// `gorm:"serializer:encrypted"` on write and decrypts them on read
+type Serializer struct{}
+
+// Scan decrypts a column value into the field
+func (Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var value string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	default:
+		return fmt.Errorf("unsupported encrypted column type %T", dbValue)
+	}
+
+	if IsEncrypted(value) {
+		c := active.Load()
+		if c == nil {
+			return errors.New("column is encrypted but ENCRYPTION_KEY is not set")
+		}
+		plaintext, err := c.Decrypt(value)
+		if err != nil {
+			return err
+		}
+		value = plaintext
+	}
+
+	return field.Set(ctx, dst, value)
+}
+
+// Value encrypts the field for storage
+func (Serializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	value, ok := fieldValue.(string)
+	if !ok {
+		return nil, fmt.Errorf("encrypted serializer only supports strings, got %T", fieldValue)
+	}
+
+	c := active.Load()
+	if c == nil {
+		return value, nil
+	}
+	return c.Encrypt(value)
+}
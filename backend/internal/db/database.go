@@ -2,8 +2,11 @@ package db
 
 import (
 	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
+	"github.com/ai-support-assistant/backend/internal/crypto"
 	"github.com/ai-support-assistant/backend/internal/models"
 	"github.com/sirupsen/logrus"
 	"gorm.io/driver/postgres"
@@ -13,20 +16,76 @@ import (
 
 var DB *gorm.DB
 
-// Initialize initializes the database connection
-func Initialize(databaseURL string, isDevelopment bool) (*gorm.DB, error) {
+// maxConnectDelay caps the backoff between connection attempts
+const maxConnectDelay = 30 * time.Second
+
+// PoolConfig sizes the connection pool. Zero lifetimes keep connections
+// open indefinitely; a zero SlowQueryThreshold disables slow query logging.
+type PoolConfig struct {
+	MaxIdleConns       int
+	MaxOpenConns       int
+	ConnMaxLifetime    time.Duration
+	ConnMaxIdleTime    time.Duration
+	SlowQueryThreshold time.Duration
+}
+
+// Initialize initializes the database connection, retrying with exponential
+// backoff so the backend can start before Postgres is ready
+func Initialize(databaseURL string, isDevelopment bool, attempts int, delay time.Duration, pool PoolConfig) (*gorm.DB, error) {
 	logLevel := logger.Silent
 	if isDevelopment {
 		logLevel = logger.Info
 	}
 
 	config := &gorm.Config{
-		Logger: logger.Default.LogMode(logLevel),
+		Logger: newSlowQueryLogger(logger.Default.LogMode(logLevel), pool.SlowQueryThreshold),
 		NowFunc: func() time.Time {
 			return time.Now().UTC()
 		},
 	}
 
+	if attempts <= 0 {
+		attempts = 1
+	}
+
+	host := hostFromURL(databaseURL)
+
+	var db *gorm.DB
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		db, err = connect(databaseURL, config, pool)
+		if err == nil {
+			break
+		}
+
+		if attempt == attempts {
+			return nil, fmt.Errorf("failed to connect to database at %s after %d attempts: %w", host, attempts, err)
+		}
+
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"host":    host,
+			"attempt": attempt,
+			"retry":   delay.String(),
+		}).Warn("Database not ready, retrying")
+
+		time.Sleep(delay)
+		delay *= 2
+		if delay > maxConnectDelay {
+			delay = maxConnectDelay
+		}
+	}
+
+	DB = db
+	logrus.WithFields(logrus.Fields{
+		"host":           host,
+		"max_open_conns": pool.MaxOpenConns,
+		"max_idle_conns": pool.MaxIdleConns,
+	}).Info("Database connection established successfully")
+	return db, nil
+}
+
+// connect opens the connection, configures the pool and verifies it with a ping
+func connect(databaseURL string, config *gorm.Config, pool PoolConfig) (*gorm.DB, error) {
 	db, err := gorm.Open(postgres.Open(databaseURL), config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
@@ -38,27 +97,131 @@ func Initialize(databaseURL string, isDevelopment bool) (*gorm.DB, error) {
 	}
 
 	// Set connection pool settings
-	sqlDB.SetMaxIdleConns(10)
-	sqlDB.SetMaxOpenConns(20)
-	sqlDB.SetConnMaxLifetime(time.Hour)
+	sqlDB.SetMaxIdleConns(pool.MaxIdleConns)
+	sqlDB.SetMaxOpenConns(pool.MaxOpenConns)
+	sqlDB.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
 
-	// Auto-migrate models
-	if err := autoMigrate(db); err != nil {
-		return nil, fmt.Errorf("failed to auto-migrate: %w", err)
+	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	DB = db
-	logrus.Info("Database connection established successfully")
 	return db, nil
 }
 
-// autoMigrate runs database migrations
-func autoMigrate(db *gorm.DB) error {
-	return db.AutoMigrate(
+// schemaModels returns all models managed by migrations
+func schemaModels() []interface{} {
+	return []interface{}{
 		&models.ChatQuery{},
 		&models.Feedback{},
+		&models.Collection{},
 		&models.Document{},
-	)
+		&models.WebhookSubscription{},
+		&models.WebhookDelivery{},
+		&models.CannedAnswer{},
+		&models.Setting{},
+		&models.RetrievalFeedback{},
+		&models.QueryJob{},
+		&models.WidgetConfig{},
+		&models.Report{},
+		&models.PromptTemplate{},
+		&models.Experiment{},
+		&models.ExperimentVariant{},
+		&models.CrawlJob{},
+		&models.AuditLog{},
+		&models.IdempotencyRecord{},
+		&models.Session{},
+		&models.DeadLetter{},
+		&models.FeatureFlag{},
+		&models.Annotation{},
+		&models.ModelRoutingRule{},
+		&models.PurgeJob{},
+		&models.KnowledgeGapReport{},
+		&models.KnowledgeGapCluster{},
+		&models.UsageRecord{},
+		&models.QueryAttachmentRecord{},
+		&models.ShadowRun{},
+		&models.ShadowComparison{},
+		&models.QuotaOverride{},
+		&models.AnalyticsSegment{},
+		&models.Persona{},
+		&models.FAQEntry{},
+		&models.ReingestJob{},
+		&models.ReingestItem{},
+	}
+}
+
+// Migrate runs database migrations
+func Migrate() error {
+	if DB == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	if err := DB.AutoMigrate(schemaModels()...); err != nil {
+		return fmt.Errorf("failed to auto-migrate: %w", err)
+	}
+
+	if err := migrateSearchIndex(); err != nil {
+		return err
+	}
+
+	logrus.Info("Database migrations completed")
+	return nil
+}
+
+// migrateSearchIndex creates the GIN index behind conversation search, which
+// can't be declared on the model, and indexes rows written before search
+// existed. Encrypted rows are never indexed.
+func migrateSearchIndex() error {
+	if DB.Dialector.Name() != "postgres" {
+		return nil
+	}
+
+	if err := DB.Exec("CREATE INDEX IF NOT EXISTS idx_chat_queries_search ON chat_queries USING GIN (search_vector)").Error; err != nil {
+		return fmt.Errorf("failed to create search index: %w", err)
+	}
+
+	if crypto.Active() != nil {
+		return nil
+	}
+
+	result := DB.Exec(`UPDATE chat_queries
+		SET search_vector = to_tsvector(?::regconfig, coalesce(query, '') || E'\n' || coalesce(response, ''))
+		WHERE search_vector IS NULL AND query NOT LIKE 'enc:%' AND coalesce(response, '') NOT LIKE 'enc:%'`,
+		models.SearchConfig)
+	if result.Error != nil {
+		return fmt.Errorf("failed to backfill search index: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		logrus.WithField("rows", result.RowsAffected).Info("Backfilled conversation search index")
+	}
+
+	return nil
+}
+
+// VerifySchema checks that the tables for all models exist without modifying the schema
+func VerifySchema() error {
+	if DB == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	var missing []string
+	for _, model := range schemaModels() {
+		if !DB.Migrator().HasTable(model) {
+			stmt := &gorm.Statement{DB: DB}
+			if err := stmt.Parse(model); err != nil {
+				return fmt.Errorf("failed to parse model: %w", err)
+			}
+			missing = append(missing, stmt.Schema.Table)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("missing tables: %s (run with -migrate or set RUN_MIGRATIONS=true)", strings.Join(missing, ", "))
+	}
+
+	return nil
 }
 
 // HealthCheck checks if the database is healthy
@@ -97,3 +260,27 @@ func Close() error {
 func GetDB() *gorm.DB {
 	return DB
 }
+
+// hostFromURL extracts host:port from a postgres URL or key=value DSN for logging
+func hostFromURL(databaseURL string) string {
+	if u, err := url.Parse(databaseURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+
+	var host, port string
+	for _, field := range strings.Fields(databaseURL) {
+		if value, ok := strings.CutPrefix(field, "host="); ok {
+			host = value
+		} else if value, ok := strings.CutPrefix(field, "port="); ok {
+			port = value
+		}
+	}
+
+	if host == "" {
+		return "unknown"
+	}
+	if port != "" {
+		return host + ":" + port
+	}
+	return host
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/middleware"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm/logger"
+)
+
+// maxLoggedSQLLength caps the statement text included in slow query logs
+const maxLoggedSQLLength = 2000
+
+// slowQueryLogger wraps a gorm logger and logs statements slower than
+// threshold at Warn level, regardless of the wrapped logger's level
+type slowQueryLogger struct {
+	logger.Interface
+	threshold time.Duration
+}
+
+// newSlowQueryLogger returns base unchanged when threshold is zero or negative
+func newSlowQueryLogger(base logger.Interface, threshold time.Duration) logger.Interface {
+	if threshold <= 0 {
+		return base
+	}
+	return &slowQueryLogger{Interface: base, threshold: threshold}
+}
+
+// LogMode keeps the slow query wrapper when the level changes
+func (l *slowQueryLogger) LogMode(level logger.LogLevel) logger.Interface {
+	return &slowQueryLogger{Interface: l.Interface.LogMode(level), threshold: l.threshold}
+}
+
+// Trace passes the statement to the wrapped logger and logs it when slow
+func (l *slowQueryLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	l.Interface.Trace(ctx, begin, fc, err)
+
+	elapsed := time.Since(begin)
+	if elapsed < l.threshold {
+		return
+	}
+	middleware.RecordDBSlowQuery()
+
+	sql, rows := fc()
+	if len(sql) > maxLoggedSQLLength {
+		sql = sql[:maxLoggedSQLLength] + "..."
+	}
+
+	entry := logrus.WithFields(logrus.Fields{
+		"sql":         sql,
+		"duration_ms": elapsed.Milliseconds(),
+		"rows":        rows,
+	})
+	if err != nil {
+		entry = entry.WithError(err)
+	}
+	entry.Warn("Slow database query")
+}
@@ -0,0 +1,38 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/middleware"
+	"github.com/sirupsen/logrus"
+)
+
+// StartStatsCollector exports connection pool stats every interval until ctx
+// is cancelled
+func StartStatsCollector(ctx context.Context, interval time.Duration) {
+	if interval <= 0 || DB == nil {
+		return
+	}
+
+	sqlDB, err := DB.DB()
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to get database instance for pool stats")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		middleware.SetDBPoolStats(sqlDB.Stats())
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				middleware.SetDBPoolStats(sqlDB.Stats())
+			}
+		}
+	}()
+}
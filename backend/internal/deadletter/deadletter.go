@@ -0,0 +1,140 @@
+package deadletter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"unicode/utf8"
+
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/logging"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// Operations that are dead-lettered on terminal failure. Uploaded files
+// aren't kept after ingestion, so only object ingestion and queued uploads,
+// whose text is kept on the document, can be replayed.
+const (
+	OperationWebhookDelivery   = "webhook.delivery"
+	OperationSlackNotification = "slack.notification"
+	OperationRetrievalReport   = "retrieval_feedback.report"
+	OperationObjectIngest      = "document.ingest_object"
+	OperationTextIngest        = "document.ingest_text"
+)
+
+// Dead letter statuses
+const (
+	StatusPending  = "pending"
+	StatusReplayed = "replayed"
+)
+
+const (
+	// maxPayloadBytes caps a stored payload; longer payloads are truncated
+	maxPayloadBytes = 64 * 1024
+
+	// maxErrorLength caps the stored error message
+	maxErrorLength = 2000
+)
+
+// ErrNotReplayable is returned by replayers when an operation can no longer
+// be replayed, e.g. because what it refers to was deleted
+var ErrNotReplayable = errors.New("operation can't be replayed")
+
+// Replayer re-enqueues a dead-lettered operation from its payload, starting
+// over with a fresh attempt count. It must not wait for the operation to run.
+type Replayer func(ctx context.Context, payload json.RawMessage) error
+
+// Record stores an operation that failed after attempts tries. Failures to
+// record are only logged, since the caller has nothing left to fall back on.
+func Record(ctx context.Context, operation string, payload interface{}, attempts int, cause error) {
+	letter := models.DeadLetter{
+		Operation: operation,
+		Attempts:  attempts,
+		Status:    StatusPending,
+	}
+	if cause != nil {
+		letter.Error = truncate(logging.ScrubSecrets(cause.Error()), maxErrorLength)
+	}
+
+	data, err := scrubPayload(payload)
+	if err != nil {
+		logrus.WithError(err).WithField("operation", operation).Error("Failed to marshal dead letter payload")
+		return
+	}
+	if len(data) > maxPayloadBytes {
+		data = []byte(truncate(string(data), maxPayloadBytes))
+		letter.Truncated = true
+	}
+	letter.Payload = string(data)
+
+	if err := db.DB.WithContext(ctx).Create(&letter).Error; err != nil {
+		logrus.WithError(err).WithField("operation", operation).Error("Failed to record dead letter")
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"dead_letter_id": letter.ID,
+		"operation":      operation,
+		"attempts":       attempts,
+	}).Warn("Operation dead-lettered")
+}
+
+// scrubPayload encodes a payload as JSON with sensitive fields and secrets
+// in string values redacted
+func scrubPayload(payload interface{}) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+
+	// Keep URLs readable; payloads are never rendered as HTML
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(scrub(value)); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// scrub walks a decoded JSON value, redacting sensitive fields
+func scrub(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if logging.IsSensitiveField(key) {
+				v[key] = logging.Redacted
+				continue
+			}
+			v[key] = scrub(field)
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = scrub(v[i])
+		}
+		return v
+	case string:
+		return logging.ScrubSecrets(v)
+	default:
+		return v
+	}
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,335 @@
+package errortracking
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	mathrand "math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/lifecycle"
+	"github.com/sirupsen/logrus"
+)
+
+// Error reporting modes
+const (
+	ModePanicsOnly = "panics_only"
+	ModeAll5xx     = "all_5xx"
+)
+
+// Capture outcomes
+const (
+	OutcomeQueued     = "queued"
+	OutcomeSampledOut = "sampled_out"
+	OutcomeDropped    = "dropped" // the queue was full
+)
+
+// clientName identifies this sender to the error tracker
+const clientName = "ai-support-assistant/1.0"
+
+// Event is an error to report. Only request metadata is carried, never
+// request bodies.
+type Event struct {
+	Panic     bool   // a recovered panic rather than an error response
+	Message   string // the panic value or error
+	ErrorType string // the Go type of the panic value or error
+	Stack     []byte // debug.Stack output, for panics
+
+	RequestID string
+	TraceID   string
+	Method    string
+	Path      string // the URL path, without the query string
+	Route     string // the matched route pattern
+	Status    int
+	UserID    string
+	VisitorID string
+	SessionID string
+}
+
+// Reporter sends events to a Sentry-compatible error tracker from a bounded
+// queue, so reporting never blocks a request. A nil Reporter reports nothing.
+type Reporter struct {
+	endpoint    string
+	auth        string
+	environment string
+	release     string
+	serverName  string
+	sampleRate  float64
+	mode        string
+
+	queue     chan Event
+	client    *http.Client
+	lifecycle *lifecycle.Manager
+}
+
+// Options configure a Reporter
+type Options struct {
+	Environment string
+	Release     string
+	SampleRate  float64 // share of events sent, from 0 to 1
+	QueueSize   int
+	Mode        string
+}
+
+// New creates a reporter for a DSN, or returns nil when the DSN is empty;
+// call Start to begin sending
+func New(dsn string, opts Options) (*Reporter, error) {
+	if dsn == "" {
+		return nil, nil
+	}
+	endpoint, key, err := ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	serverName, _ := os.Hostname()
+	queueSize := opts.QueueSize
+	if queueSize <= 0 {
+		queueSize = 1
+	}
+
+	return &Reporter{
+		endpoint:    endpoint,
+		auth:        fmt.Sprintf("Sentry sentry_version=7, sentry_client=%s, sentry_key=%s", clientName, key),
+		environment: opts.Environment,
+		release:     opts.Release,
+		serverName:  serverName,
+		sampleRate:  opts.SampleRate,
+		mode:        opts.Mode,
+		queue:       make(chan Event, queueSize),
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}, nil
+}
+
+// ParseDSN parses a DSN of the form https://key@host/project_id into the
+// envelope endpoint and public key
+func ParseDSN(dsn string) (string, string, error) {
+	parsed, err := url.Parse(dsn)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid DSN: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return "", "", fmt.Errorf("invalid DSN: scheme must be http or https")
+	}
+	if parsed.User == nil || parsed.User.Username() == "" {
+		return "", "", fmt.Errorf("invalid DSN: missing public key")
+	}
+	if parsed.Host == "" {
+		return "", "", fmt.Errorf("invalid DSN: missing host")
+	}
+
+	path := strings.TrimSuffix(parsed.Path, "/")
+	slash := strings.LastIndex(path, "/")
+	projectID := path[slash+1:]
+	if slash < 0 || projectID == "" {
+		return "", "", fmt.Errorf("invalid DSN: missing project ID")
+	}
+
+	endpoint := fmt.Sprintf("%s://%s%s/api/%s/envelope/", parsed.Scheme, parsed.Host, path[:slash], projectID)
+	return endpoint, parsed.User.Username(), nil
+}
+
+// Start launches the sender. It stops when shutdown begins, logging any
+// events still queued.
+func (r *Reporter) Start(lc *lifecycle.Manager) {
+	if r == nil {
+		return
+	}
+	r.lifecycle = lc
+	lc.Go("error_reporter", nil, r.run)
+	logrus.WithFields(logrus.Fields{
+		"mode":        r.mode,
+		"sample_rate": r.sampleRate,
+	}).Info("Error reporting enabled")
+}
+
+// ReportsErrors returns true when server error responses are reported as
+// well as panics
+func (r *Reporter) ReportsErrors() bool {
+	return r != nil && r.mode == ModeAll5xx
+}
+
+// Capture queues an event without blocking and returns what became of it
+func (r *Reporter) Capture(event Event) string {
+	if r == nil {
+		return ""
+	}
+	if r.sampleRate < 1 && mathrand.Float64() >= r.sampleRate {
+		return OutcomeSampledOut
+	}
+	select {
+	case r.queue <- event:
+		return OutcomeQueued
+	default:
+		return OutcomeDropped
+	}
+}
+
+// run sends queued events until shutdown begins
+func (r *Reporter) run(ctx context.Context) {
+	stop := r.lifecycle.Context().Done()
+	for {
+		select {
+		case <-stop:
+			r.abandonQueued()
+			return
+		case event := <-r.queue:
+			if err := r.send(ctx, event); err != nil {
+				logrus.WithError(err).WithField("request_id", event.RequestID).Warn("Failed to report error")
+			}
+		}
+	}
+}
+
+// abandonQueued empties the queue at shutdown
+func (r *Reporter) abandonQueued() {
+	for {
+		select {
+		case event := <-r.queue:
+			logrus.WithFields(logrus.Fields{
+				"request_id": event.RequestID,
+				"error":      event.Message,
+			}).Warn("Abandoned queued error report at shutdown")
+		default:
+			return
+		}
+	}
+}
+
+// send posts an event to the tracker as an envelope
+func (r *Reporter) send(ctx context.Context, event Event) error {
+	body, err := r.envelope(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", r.auth)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send error report: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("error tracker returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// envelope encodes an event as an envelope: a header line, an item header
+// line and the event payload
+func (r *Reporter) envelope(event Event) ([]byte, error) {
+	eventID := newEventID()
+	payload, err := json.Marshal(r.payload(eventID, event))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode error report: %w", err)
+	}
+
+	header, _ := json.Marshal(map[string]string{
+		"event_id": eventID,
+		"sent_at":  time.Now().UTC().Format(time.RFC3339),
+	})
+	item, _ := json.Marshal(map[string]interface{}{
+		"type":   "event",
+		"length": len(payload),
+	})
+
+	var buf bytes.Buffer
+	buf.Write(header)
+	buf.WriteByte('\n')
+	buf.Write(item)
+	buf.WriteByte('\n')
+	buf.Write(payload)
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// payload builds the event payload
+func (r *Reporter) payload(eventID string, event Event) map[string]interface{} {
+	level := "error"
+	mechanism := map[string]interface{}{"type": "http_error", "handled": true}
+	if event.Panic {
+		level = "fatal"
+		mechanism = map[string]interface{}{"type": "panic", "handled": false}
+	}
+
+	exception := map[string]interface{}{
+		"type":      event.ErrorType,
+		"value":     event.Message,
+		"mechanism": mechanism,
+	}
+	if frames := parseStack(event.Stack); len(frames) > 0 {
+		exception["stacktrace"] = map[string]interface{}{"frames": frames}
+	}
+
+	tags := map[string]string{"request_id": event.RequestID}
+	if event.Route != "" {
+		tags["route"] = event.Route
+	}
+	if event.Status != 0 {
+		tags["status"] = fmt.Sprint(event.Status)
+	}
+	if event.TraceID != "" {
+		tags["trace_id"] = event.TraceID
+	}
+	if event.SessionID != "" {
+		tags["session_id"] = event.SessionID
+	}
+
+	payload := map[string]interface{}{
+		"event_id":    eventID,
+		"timestamp":   float64(time.Now().UnixNano()) / 1e9,
+		"platform":    "go",
+		"level":       level,
+		"logger":      "orchestrator",
+		"server_name": r.serverName,
+		"environment": r.environment,
+		"exception":   map[string]interface{}{"values": []interface{}{exception}},
+		"tags":        tags,
+		"request": map[string]string{
+			"method": event.Method,
+			"url":    event.Path,
+		},
+	}
+	if r.release != "" {
+		payload["release"] = r.release
+	}
+	if event.Route != "" {
+		payload["transaction"] = event.Method + " " + event.Route
+	}
+	if event.UserID != "" || event.VisitorID != "" {
+		user := map[string]string{}
+		if event.UserID != "" {
+			user["id"] = event.UserID
+		}
+		if event.VisitorID != "" {
+			user["visitor_id"] = event.VisitorID
+		}
+		payload["user"] = user
+	}
+	return payload
+}
+
+// newEventID returns a random 32 hex character event ID
+func newEventID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
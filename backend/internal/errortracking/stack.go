@@ -0,0 +1,78 @@
+package errortracking
+
+import (
+	"strconv"
+	"strings"
+)
+
+// modulePath marks this service's own frames as in-app
+const modulePath = "github.com/ai-support-assistant/backend/"
+
+// frame is a stack frame in the tracker's format
+type frame struct {
+	Function string `json:"function"`
+	Module   string `json:"module,omitempty"`
+	AbsPath  string `json:"abs_path,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	Lineno   int    `json:"lineno,omitempty"`
+	InApp    bool   `json:"in_app"`
+}
+
+// parseStack parses debug.Stack output into frames, oldest call first as the
+// tracker expects. The frames of the panic machinery and of the recovery
+// itself are left out.
+func parseStack(stack []byte) []frame {
+	lines := strings.Split(strings.TrimSpace(string(stack)), "\n")
+
+	var frames []frame
+	// The first line is the goroutine header; each frame is then a function
+	// line followed by an indented file:line line
+	for i := 1; i+1 < len(lines); i += 2 {
+		// Calls end in their argument list; the line naming the function
+		// that started the goroutine has none
+		function, created := strings.CutPrefix(lines[i], "created by ")
+		if created {
+			function, _, _ = strings.Cut(function, " in goroutine ")
+		} else if paren := strings.LastIndex(function, "("); paren > 0 {
+			function = function[:paren]
+		}
+		location := strings.TrimSpace(lines[i+1])
+		if space := strings.LastIndex(location, " +0x"); space > 0 {
+			location = location[:space]
+		}
+		path, line := location, 0
+		if colon := strings.LastIndex(location, ":"); colon > 0 {
+			path = location[:colon]
+			line, _ = strconv.Atoi(location[colon+1:])
+		}
+
+		module, name := function, function
+		if dot := strings.Index(function[strings.LastIndex(function, "/")+1:], "."); dot >= 0 {
+			split := strings.LastIndex(function, "/") + 1 + dot
+			module, name = function[:split], function[split+1:]
+		}
+
+		frames = append(frames, frame{
+			Function: name,
+			Module:   module,
+			AbsPath:  path,
+			Filename: path[strings.LastIndex(path, "/")+1:],
+			Lineno:   line,
+			InApp:    strings.HasPrefix(function, modulePath),
+		})
+	}
+
+	// Drop everything up to and including the runtime's panic frame, which
+	// leaves the frame that panicked on top
+	for i, f := range frames {
+		if f.Module == "runtime" && f.Function == "gopanic" || f.Module == "panic" {
+			frames = frames[i+1:]
+			break
+		}
+	}
+
+	for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+		frames[i], frames[j] = frames[j], frames[i]
+	}
+	return frames
+}
@@ -0,0 +1,70 @@
+// Package fingerprint derives pseudonymous visitor IDs from request
+// attributes, so visitors can be counted without trusting client-reported
+// session IDs and without storing their IP address.
+package fingerprint
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// idBytes is how much of the HMAC is kept; 128 bits is plenty to keep
+// distinct visitors apart
+const idBytes = 16
+
+// Fingerprinter computes visitor IDs as an HMAC of the IP and user agent
+// under a salt that rotates every period. The salt is derived from the key,
+// so every instance agrees on it without coordination, and a visitor can be
+// recognized within a period but not linked across periods.
+type Fingerprinter struct {
+	key      []byte
+	rotation time.Duration
+
+	// salt caches the current period's salt
+	salt atomic.Pointer[periodSalt]
+}
+
+type periodSalt struct {
+	period int64
+	value  []byte
+}
+
+// New creates a fingerprinter. The key must be kept secret: with it, the
+// salts can be recomputed and IDs tested against guessed IP addresses.
+func New(key string, rotation time.Duration) (*Fingerprinter, error) {
+	if key == "" {
+		return nil, errors.New("fingerprint key is required")
+	}
+	if rotation <= 0 {
+		return nil, errors.New("salt rotation must be positive")
+	}
+	return &Fingerprinter{key: []byte(key), rotation: rotation}, nil
+}
+
+// Visitor returns the hex-encoded ID of a visitor at the given time
+func (f *Fingerprinter) Visitor(ip, userAgent string, now time.Time) string {
+	mac := hmac.New(sha256.New, f.saltAt(now))
+	mac.Write([]byte(ip))
+	mac.Write([]byte{0})
+	mac.Write([]byte(userAgent))
+	return hex.EncodeToString(mac.Sum(nil)[:idBytes])
+}
+
+// saltAt returns the salt of the period containing now
+func (f *Fingerprinter) saltAt(now time.Time) []byte {
+	period := now.UnixNano() / int64(f.rotation)
+	if salt := f.salt.Load(); salt != nil && salt.period == period {
+		return salt.value
+	}
+
+	mac := hmac.New(sha256.New, f.key)
+	mac.Write([]byte("visitor-salt:" + strconv.FormatInt(period, 10)))
+	salt := &periodSalt{period: period, value: mac.Sum(nil)}
+	f.salt.Store(salt)
+	return salt.value
+}
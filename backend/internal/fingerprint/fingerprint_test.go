@@ -0,0 +1,72 @@
+package fingerprint
+
+import (
+	"testing"
+	"time"
+)
+
+const testUserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) AppleWebKit/605.1.15 Safari/605.1.15"
+
+func TestVisitor(t *testing.T) {
+	f, err := New("secret", 24*time.Hour)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	day := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	id := f.Visitor("203.0.113.7", testUserAgent, day)
+	if len(id) != 2*idBytes {
+		t.Errorf("len(id) = %d, want %d", len(id), 2*idBytes)
+	}
+	if again := f.Visitor("203.0.113.7", testUserAgent, day.Add(8*time.Hour)); again != id {
+		t.Error("same visitor got a different ID within the period")
+	}
+	if other := f.Visitor("203.0.113.8", testUserAgent, day); other == id {
+		t.Error("different IPs got the same ID")
+	}
+	if other := f.Visitor("203.0.113.7", "curl/8.0", day); other == id {
+		t.Error("different user agents got the same ID")
+	}
+	if next := f.Visitor("203.0.113.7", testUserAgent, day.Add(24*time.Hour)); next == id {
+		t.Error("visitor linked across periods")
+	}
+
+	// Another instance with the same key agrees; another key doesn't
+	same, _ := New("secret", 24*time.Hour)
+	if got := same.Visitor("203.0.113.7", testUserAgent, day); got != id {
+		t.Error("instances sharing a key disagree")
+	}
+	other, _ := New("other-secret", 24*time.Hour)
+	if got := other.Visitor("203.0.113.7", testUserAgent, day); got == id {
+		t.Error("different keys produced the same ID")
+	}
+}
+
+func TestNewValidation(t *testing.T) {
+	if _, err := New("", time.Hour); err == nil {
+		t.Error("New accepted an empty key")
+	}
+	if _, err := New("secret", 0); err == nil {
+		t.Error("New accepted a zero rotation")
+	}
+}
+
+func BenchmarkVisitor(b *testing.B) {
+	f, _ := New("secret", 24*time.Hour)
+	now := time.Now()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		f.Visitor("203.0.113.7", testUserAgent, now)
+	}
+}
+
+func BenchmarkVisitorParallel(b *testing.B) {
+	f, _ := New("secret", 24*time.Hour)
+	now := time.Now()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			f.Visitor("203.0.113.7", testUserAgent, now)
+		}
+	})
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/activity"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// Activity feed streams buffer up to activityStreamBuffer events per
+// connection, dropping the oldest when the client falls behind, send a
+// keep-alive comment every activityStreamKeepalive and end after
+// activityStreamMaxDuration, leaving the dashboard to reconnect
+const (
+	activityStreamBuffer      = 100
+	activityStreamKeepalive   = 15 * time.Second
+	activityStreamMaxDuration = time.Hour
+)
+
+type ActivityHandler struct {
+	sessionService *services.SessionService
+	bus            *activity.Bus
+}
+
+func NewActivityHandler(sessionService *services.SessionService, bus *activity.Bus) *ActivityHandler {
+	return &ActivityHandler{sessionService: sessionService, bus: bus}
+}
+
+// HandleGetActiveSessions handles GET /api/admin/sessions/active
+func (h *ActivityHandler) HandleGetActiveSessions(c *gin.Context) {
+	minutes, err := strconv.Atoi(c.DefaultQuery("minutes", "15"))
+	if err != nil || minutes <= 0 {
+		minutes = 15
+	}
+	if minutes > 1440 {
+		minutes = 1440
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		limit = 100
+	}
+	if limit > 500 {
+		limit = 500
+	}
+
+	sessions, err := h.sessionService.GetActiveSessions(c.Request.Context(), time.Duration(minutes)*time.Minute, limit)
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch active sessions")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessions": sessions,
+		"count":    len(sessions),
+		"minutes":  minutes,
+	})
+}
+
+// HandleStreamActivity handles GET /api/admin/activity/stream. It sends an
+// event named after its type for every query and feedback recorded on any
+// instance, preceded by a dropped event counting any events this connection
+// missed because it fell behind.
+func (h *ActivityHandler) HandleStreamActivity(c *gin.Context) {
+	ctx := c.Request.Context()
+	sub := h.bus.Subscribe(activityStreamBuffer)
+	defer sub.Close()
+
+	// The stream outlives the server's write timeout
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(activityStreamMaxDuration + time.Minute))
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	io.WriteString(c.Writer, ": connected\n\n")
+	c.Writer.Flush()
+
+	keepalive := time.NewTicker(activityStreamKeepalive)
+	defer keepalive.Stop()
+	deadline := time.NewTimer(activityStreamMaxDuration)
+	defer deadline.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-deadline.C:
+			return false
+		case <-keepalive.C:
+			// A comment keeps proxies from closing an idle stream
+			io.WriteString(w, ": keepalive\n\n")
+			return true
+		case event, ok := <-sub.Events():
+			if !ok {
+				return false
+			}
+			if dropped := sub.TakeDropped(); dropped > 0 {
+				c.SSEvent("dropped", gin.H{"count": dropped})
+			}
+			c.SSEvent(event.Type, event)
+			return true
+		}
+	})
+}
@@ -3,28 +3,32 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/ai-support-assistant/backend/internal/models"
 	"github.com/ai-support-assistant/backend/internal/services"
 	"github.com/gin-gonic/gin"
-	"github.com/sirupsen/logrus"
 )
 
 type AnalyticsHandler struct {
 	analyticsService *services.AnalyticsService
+	segmentService   *services.SegmentService
 }
 
-func NewAnalyticsHandler(analyticsService *services.AnalyticsService) *AnalyticsHandler {
-	return &AnalyticsHandler{analyticsService: analyticsService}
+func NewAnalyticsHandler(analyticsService *services.AnalyticsService, segmentService *services.SegmentService) *AnalyticsHandler {
+	return &AnalyticsHandler{analyticsService: analyticsService, segmentService: segmentService}
 }
 
 // HandleGetAnalytics handles GET /api/analytics
 func (h *AnalyticsHandler) HandleGetAnalytics(c *gin.Context) {
-	analytics, err := h.analyticsService.GetAnalytics(c.Request.Context())
+	segment, ok := querySegment(c, h.segmentService)
+	if !ok {
+		return
+	}
+
+	analytics, err := h.analyticsService.GetAnalytics(c.Request.Context(), segment)
 	if err != nil {
-		logrus.WithError(err).Error("Failed to get analytics")
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to fetch analytics",
-		})
+		respondError(c, err, "fetch_error", "Failed to fetch analytics")
 		return
 	}
 
@@ -39,12 +43,14 @@ func (h *AnalyticsHandler) HandleGetTopQueries(c *gin.Context) {
 		limit = 10
 	}
 
-	topQueries, err := h.analyticsService.GetTopQueries(c.Request.Context(), limit)
+	segment, ok := querySegment(c, h.segmentService)
+	if !ok {
+		return
+	}
+
+	topQueries, err := h.analyticsService.GetTopQueries(c.Request.Context(), limit, segment)
 	if err != nil {
-		logrus.WithError(err).Error("Failed to get top queries")
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to fetch top queries",
-		})
+		respondError(c, err, "fetch_error", "Failed to fetch top queries")
 		return
 	}
 
@@ -61,16 +67,316 @@ func (h *AnalyticsHandler) HandleGetQueryTrends(c *gin.Context) {
 		days = 7
 	}
 
-	trends, err := h.analyticsService.GetQueryTrends(c.Request.Context(), days)
+	granularity := c.DefaultQuery("granularity", services.GranularityDay)
+	if !services.IsValidGranularity(granularity) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_granularity",
+			Message: "Invalid granularity, must be one of hour, day, week",
+		})
+		return
+	}
+
+	loc, err := time.LoadLocation(c.DefaultQuery("tz", "UTC"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_timezone",
+			Message: "Invalid timezone",
+		})
+		return
+	}
+
+	segment, ok := querySegment(c, h.segmentService)
+	if !ok {
+		return
+	}
+
+	trends, err := h.analyticsService.GetQueryTrends(c.Request.Context(), days, granularity, loc, segment)
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch query trends")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"trends":      trends,
+		"granularity": granularity,
+		"tz":          loc.String(),
+	})
+}
+
+// HandleGetOutcomeTrends handles GET /api/analytics/outcomes
+func (h *AnalyticsHandler) HandleGetOutcomeTrends(c *gin.Context) {
+	daysStr := c.DefaultQuery("days", "30")
+	days, err := strconv.Atoi(daysStr)
+	if err != nil || days <= 0 {
+		days = 30
+	}
+
+	loc, err := time.LoadLocation(c.DefaultQuery("tz", "UTC"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_timezone",
+			Message: "Invalid timezone",
+		})
+		return
+	}
+
+	trends, err := h.analyticsService.GetOutcomeTrends(c.Request.Context(), days, loc)
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch outcome trends")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"outcomes": trends,
+		"days":     days,
+		"tz":       loc.String(),
+	})
+}
+
+// HandleGetQueryHeatmap handles GET /api/analytics/heatmap
+func (h *AnalyticsHandler) HandleGetQueryHeatmap(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "28"))
+	if err != nil || days <= 0 {
+		days = 28
+	}
+	if days > 365 {
+		days = 365
+	}
+
+	loc, err := time.LoadLocation(c.DefaultQuery("tz", "UTC"))
 	if err != nil {
-		logrus.WithError(err).Error("Failed to get query trends")
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to fetch query trends",
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_timezone",
+			Message: "Invalid timezone",
 		})
 		return
 	}
 
+	heatmap, err := h.analyticsService.GetQueryHeatmap(c.Request.Context(), days, loc, c.Query("include_feedback") == "true")
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch query heatmap")
+		return
+	}
+
+	c.JSON(http.StatusOK, heatmap)
+}
+
+// HandleGetLanguageBreakdown handles GET /api/analytics/languages
+func (h *AnalyticsHandler) HandleGetLanguageBreakdown(c *gin.Context) {
+	daysStr := c.DefaultQuery("days", "30")
+	days, err := strconv.Atoi(daysStr)
+	if err != nil || days <= 0 {
+		days = 30
+	}
+
+	languages, translationRate, err := h.analyticsService.GetLanguageBreakdown(c.Request.Context(), days)
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch language breakdown")
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"trends": trends,
+		"languages":        languages,
+		"translation_rate": translationRate,
+		"days":             days,
 	})
 }
+
+// HandleGetPromptVersionStats handles GET /api/analytics/prompt-versions
+func (h *AnalyticsHandler) HandleGetPromptVersionStats(c *gin.Context) {
+	daysStr := c.DefaultQuery("days", "30")
+	days, err := strconv.Atoi(daysStr)
+	if err != nil || days <= 0 {
+		days = 30
+	}
+
+	stats, err := h.analyticsService.GetPromptVersionStats(c.Request.Context(), days)
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch prompt version stats")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"prompt_versions": stats,
+		"days":            days,
+	})
+}
+
+// HandleGetPipelineStats handles GET /api/analytics/pipelines
+func (h *AnalyticsHandler) HandleGetPipelineStats(c *gin.Context) {
+	daysStr := c.DefaultQuery("days", "30")
+	days, err := strconv.Atoi(daysStr)
+	if err != nil || days <= 0 {
+		days = 30
+	}
+
+	stats, err := h.analyticsService.GetPipelineStats(c.Request.Context(), days)
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch pipeline stats")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"pipelines": stats,
+		"days":      days,
+	})
+}
+
+// HandleGetPageStats handles GET /api/analytics/pages
+func (h *AnalyticsHandler) HandleGetPageStats(c *gin.Context) {
+	daysStr := c.DefaultQuery("days", "30")
+	days, err := strconv.Atoi(daysStr)
+	if err != nil || days <= 0 {
+		days = 30
+	}
+
+	limitStr := c.DefaultQuery("limit", "50")
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+
+	pages, err := h.analyticsService.GetPageStats(c.Request.Context(), days, limit)
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch page stats")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"pages": pages,
+		"days":  days,
+	})
+}
+
+// HandleGetLatencyStats handles GET /api/analytics/latency
+func (h *AnalyticsHandler) HandleGetLatencyStats(c *gin.Context) {
+	daysStr := c.DefaultQuery("days", "7")
+	days, err := strconv.Atoi(daysStr)
+	if err != nil || days <= 0 {
+		days = 7
+	}
+
+	stats, err := h.analyticsService.GetLatencyStats(c.Request.Context(), days)
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch latency stats")
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// HandleGetRegenerationStats handles GET /api/analytics/regenerations
+func (h *AnalyticsHandler) HandleGetRegenerationStats(c *gin.Context) {
+	daysStr := c.DefaultQuery("days", "30")
+	days, err := strconv.Atoi(daysStr)
+	if err != nil || days <= 0 {
+		days = 30
+	}
+
+	stats, err := h.analyticsService.GetRegenerationStats(c.Request.Context(), days)
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch regeneration stats")
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// HandleGetGroundingStats handles GET /api/analytics/grounding
+func (h *AnalyticsHandler) HandleGetGroundingStats(c *gin.Context) {
+	daysStr := c.DefaultQuery("days", "30")
+	days, err := strconv.Atoi(daysStr)
+	if err != nil || days <= 0 {
+		days = 30
+	}
+
+	stats, err := h.analyticsService.GetGroundingStats(c.Request.Context(), days)
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch grounding stats")
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// HandleGetFeedbackThemes handles GET /api/analytics/feedback-themes
+func (h *AnalyticsHandler) HandleGetFeedbackThemes(c *gin.Context) {
+	from, ok := parseTimeQuery(c, "from")
+	if !ok {
+		return
+	}
+	to, ok := parseTimeQuery(c, "to")
+	if !ok {
+		return
+	}
+
+	if to.IsZero() {
+		to = time.Now().UTC()
+	}
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -30)
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "from must be before to",
+		})
+		return
+	}
+
+	report, err := h.analyticsService.GetFeedbackThemes(c.Request.Context(), from, to)
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch feedback themes")
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// HandleGenerateReport handles POST /api/admin/reports/generate
+func (h *AnalyticsHandler) HandleGenerateReport(c *gin.Context) {
+	var req models.ReportRequest
+
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+
+	days := req.Days
+	if days == 0 {
+		days = 7
+	}
+
+	end := time.Now().UTC()
+	report, err := h.analyticsService.GenerateReport(c.Request.Context(), end.AddDate(0, 0, -days), end, services.ReportManual)
+	if err != nil {
+		respondError(c, err, "report_error", "Failed to generate report")
+		return
+	}
+
+	c.JSON(http.StatusCreated, report)
+}
+
+// HandleGetReport handles GET /api/admin/reports/:id
+func (h *AnalyticsHandler) HandleGetReport(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid report ID",
+		})
+		return
+	}
+
+	report, err := h.analyticsService.GetReport(c.Request.Context(), uint(id))
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch report")
+		return
+	}
+
+	if c.Query("format") == "html" {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(report.HTML))
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type AnnotationHandler struct {
+	annotationService *services.AnnotationService
+}
+
+func NewAnnotationHandler(annotationService *services.AnnotationService) *AnnotationHandler {
+	return &AnnotationHandler{annotationService: annotationService}
+}
+
+// HandleCreateAnnotation handles POST /api/admin/annotations
+func (h *AnnotationHandler) HandleCreateAnnotation(c *gin.Context) {
+	var req models.AnnotationRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	annotation, err := h.annotationService.CreateAnnotation(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err, "create_error", "Failed to create annotation")
+		return
+	}
+
+	c.JSON(http.StatusCreated, annotation)
+}
+
+// HandleGetAnnotations handles GET /api/admin/annotations
+func (h *AnnotationHandler) HandleGetAnnotations(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		limit = 100
+	}
+	if limit > 500 {
+		limit = 500
+	}
+
+	var queryID uint
+	if raw := c.Query("query_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_query_id",
+				Message: "Invalid query ID",
+			})
+			return
+		}
+		queryID = uint(id)
+	}
+
+	annotations, err := h.annotationService.GetAnnotations(c.Request.Context(), c.Query("status"), queryID, limit)
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch annotations")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"annotations": annotations,
+		"count":       len(annotations),
+	})
+}
+
+// HandleApproveAnnotation handles POST /api/admin/annotations/:id/approve
+func (h *AnnotationHandler) HandleApproveAnnotation(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid annotation ID",
+		})
+		return
+	}
+
+	annotation, err := h.annotationService.ApproveAnnotation(c.Request.Context(), uint(id))
+	if err != nil {
+		respondError(c, err, "update_error", "Failed to approve annotation")
+		return
+	}
+
+	c.JSON(http.StatusOK, annotation)
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/ai-support-assistant/backend/internal/apidocs"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// apiVersion is reported in the OpenAPI spec
+const apiVersion = "1.0.0"
+
+// swaggerUIPage renders the spec with Swagger UI from its CDN
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>AI Support Assistant API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "/api/openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>`
+
+type APIDocsHandler struct {
+	router *gin.Engine
+
+	// The spec is built on first request, once every route is registered
+	once sync.Once
+	spec []byte
+	err  error
+}
+
+func NewAPIDocsHandler(router *gin.Engine) *APIDocsHandler {
+	return &APIDocsHandler{router: router}
+}
+
+// HandleGetSpec handles GET /api/openapi.json
+func (h *APIDocsHandler) HandleGetSpec(c *gin.Context) {
+	h.once.Do(func() {
+		h.spec, h.err = json.Marshal(apidocs.Build(h.router.Routes(), apiVersion))
+	})
+	if h.err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "spec_error",
+			Message: "Failed to build API specification",
+		})
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json", h.spec)
+}
+
+// HandleDocsUI handles GET /api/docs-ui
+func (h *APIDocsHandler) HandleDocsUI(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUIPage))
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// maxAuditPageSize caps the number of audit entries returned per page
+const maxAuditPageSize = 500
+
+type AuditHandler struct {
+	auditService *services.AuditService
+}
+
+func NewAuditHandler(auditService *services.AuditService) *AuditHandler {
+	return &AuditHandler{auditService: auditService}
+}
+
+// HandleGetAuditLogs handles GET /api/admin/audit
+func (h *AuditHandler) HandleGetAuditLogs(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+	if limit > maxAuditPageSize {
+		limit = maxAuditPageSize
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	filter := services.AuditFilter{
+		ActorID:      c.Query("actor"),
+		Action:       c.Query("action"),
+		ResourceType: c.Query("resource_type"),
+		ResourceID:   c.Query("resource_id"),
+		Limit:        limit,
+		Offset:       offset,
+	}
+
+	var ok bool
+	if filter.From, ok = parseTimeQuery(c, "from"); !ok {
+		return
+	}
+	if filter.To, ok = parseTimeQuery(c, "to"); !ok {
+		return
+	}
+
+	entries, total, err := h.auditService.GetAuditLogs(c.Request.Context(), filter)
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch audit logs")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"count":   len(entries),
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// parseTimeQuery parses an RFC 3339 timestamp or YYYY-MM-DD date query
+// parameter, writing a 400 response if it is malformed
+func parseTimeQuery(c *gin.Context, name string) (time.Time, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, true
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, true
+	}
+
+	c.JSON(http.StatusBadRequest, models.ErrorResponse{
+		Error:   "invalid_request",
+		Message: name + " must be an RFC 3339 timestamp or a YYYY-MM-DD date",
+	})
+	return time.Time{}, false
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ai-support-assistant/backend/internal/abuse"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type BanHandler struct {
+	detector *abuse.Detector
+}
+
+func NewBanHandler(detector *abuse.Detector) *BanHandler {
+	return &BanHandler{detector: detector}
+}
+
+// HandleGetBans handles GET /api/admin/bans
+func (h *BanHandler) HandleGetBans(c *gin.Context) {
+	bans, err := h.detector.ListBans(c.Request.Context())
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch bans")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"bans":  bans,
+		"count": len(bans),
+	})
+}
+
+// HandleLiftBan handles DELETE /api/admin/bans/:kind/:value
+func (h *BanHandler) HandleLiftBan(c *gin.Context) {
+	kind := c.Param("kind")
+	value := c.Param("value")
+
+	if !abuse.IsValidKind(kind) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_kind",
+			Message: "Invalid ban kind, must be one of session, ip",
+		})
+		return
+	}
+
+	lifted, err := h.detector.Lift(c.Request.Context(), kind, value)
+	if err != nil {
+		respondError(c, err, "delete_error", "Failed to lift ban")
+		return
+	}
+	if !lifted {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "No active ban for this subject",
+		})
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"kind":  kind,
+		"value": value,
+	}).Info("Ban lifted")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Ban lifted successfully",
+		"kind":    kind,
+		"value":   value,
+	})
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type BillingHandler struct {
+	billingService *services.BillingService
+}
+
+func NewBillingHandler(billingService *services.BillingService) *BillingHandler {
+	return &BillingHandler{billingService: billingService}
+}
+
+// HandleGetUsage handles GET /api/billing/usage
+func (h *BillingHandler) HandleGetUsage(c *gin.Context) {
+	report, err := h.billingService.GetUsage(c.Request.Context(), usageFilter(c))
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch usage")
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// HandleExportUsage handles GET /api/billing/usage/export, writing the daily
+// usage records as CSV
+func (h *BillingHandler) HandleExportUsage(c *gin.Context) {
+	report, err := h.billingService.GetUsage(c.Request.Context(), usageFilter(c))
+	if err != nil {
+		respondError(c, err, "export_error", "Failed to export usage")
+		return
+	}
+
+	filename := fmt.Sprintf("usage-%s-%s.csv", report.From, report.To)
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	if err := services.WriteUsageCSV(c.Writer, report.Days); err != nil {
+		logrus.WithError(err).Warn("Failed to write usage export")
+	}
+}
+
+// HandleVerifyUsage handles GET /api/billing/usage/verify, recomputing usage
+// from chat queries and reporting records that drifted
+func (h *BillingHandler) HandleVerifyUsage(c *gin.Context) {
+	verification, err := h.billingService.VerifyUsage(c.Request.Context(), usageFilter(c))
+	if err != nil {
+		respondError(c, err, "verify_error", "Failed to verify usage")
+		return
+	}
+
+	c.JSON(http.StatusOK, verification)
+}
+
+// usageFilter reads the usage filter query parameters
+func usageFilter(c *gin.Context) models.UsageFilter {
+	return models.UsageFilter{
+		UserID: c.Query("user_id"),
+		From:   c.Query("from"),
+		To:     c.Query("to"),
+	}
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// errTrailingData is returned when a body holds more than one JSON value
+var errTrailingData = errors.New("trailing data after JSON value")
+
+// Validation errors name fields by their JSON keys, as clients send them
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(jsonFieldName)
+	}
+}
+
+// bindJSON decodes a JSON request body into obj and validates it. Unlike
+// ShouldBindJSON, unknown fields are rejected, so a misspelled field isn't
+// silently dropped. On failure it responds with 415, 413 or 400, listing
+// the invalid fields, and returns false.
+func bindJSON(c *gin.Context, obj interface{}) bool {
+	return decodeJSON(c, obj, false)
+}
+
+// bindOptionalJSON is bindJSON for endpoints whose body may be left out
+func bindOptionalJSON(c *gin.Context, obj interface{}) bool {
+	return decodeJSON(c, obj, true)
+}
+
+func decodeJSON(c *gin.Context, obj interface{}, optional bool) bool {
+	if optional && c.Request.ContentLength == 0 {
+		return true
+	}
+
+	if c.ContentType() != binding.MIMEJSON {
+		respondBindingError(c, http.StatusUnsupportedMediaType, "unsupported_media_type", "Content-Type must be application/json", nil)
+		return false
+	}
+
+	return decodeValue(c, c.Request.Body, obj, optional)
+}
+
+// bindJSONField decodes and validates the JSON value of a form field, the
+// way bindJSON does a body
+func bindJSONField(c *gin.Context, field string, obj interface{}) bool {
+	value, ok := c.GetPostForm(field)
+	if !ok {
+		fields := []models.FieldError{{Field: field, Code: "required", Message: "is required"}}
+		respondBindingError(c, http.StatusBadRequest, "invalid_request", fieldsMessage(fields), fields)
+		return false
+	}
+	return decodeValue(c, strings.NewReader(value), obj, false)
+}
+
+// decodeValue decodes a single JSON value from r into obj and validates it
+func decodeValue(c *gin.Context, r io.Reader, obj interface{}, optional bool) bool {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(obj)
+	if err == nil {
+		// Anything but whitespace after the value is rejected
+		if _, err = decoder.Token(); err == io.EOF {
+			err = nil
+		} else if err == nil {
+			err = errTrailingData
+		}
+	}
+	if err != nil {
+		if errors.Is(err, io.EOF) && optional {
+			return true
+		}
+		respondDecodeError(c, err)
+		return false
+	}
+
+	if err := binding.Validator.ValidateStruct(obj); err != nil {
+		var validationErrors validator.ValidationErrors
+		if !errors.As(err, &validationErrors) {
+			respondBindingError(c, http.StatusBadRequest, "invalid_request", err.Error(), nil)
+			return false
+		}
+
+		fields := make([]models.FieldError, len(validationErrors))
+		for i, fe := range validationErrors {
+			fields[i] = fieldError(fe)
+		}
+		respondBindingError(c, http.StatusBadRequest, "invalid_request", fieldsMessage(fields), fields)
+		return false
+	}
+
+	return true
+}
+
+// respondDecodeError reports why a body couldn't be decoded
+func respondDecodeError(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &tooLarge):
+		respondBindingError(c, http.StatusRequestEntityTooLarge, "request_too_large",
+			fmt.Sprintf("Request body must not exceed %d bytes", tooLarge.Limit), nil)
+	case errors.Is(err, errTrailingData):
+		respondBindingError(c, http.StatusBadRequest, "invalid_request", "Request body must contain a single JSON value", nil)
+	case errors.Is(err, io.EOF):
+		respondBindingError(c, http.StatusBadRequest, "invalid_request", "Request body is required", nil)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		respondBindingError(c, http.StatusBadRequest, "invalid_request", "Request body is incomplete JSON", nil)
+	case errors.As(err, &syntaxErr):
+		respondBindingError(c, http.StatusBadRequest, "invalid_request",
+			fmt.Sprintf("Request body is not valid JSON at byte %d", syntaxErr.Offset), nil)
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		fields := []models.FieldError{{
+			Field:   typeErr.Field,
+			Code:    "invalid_type",
+			Message: "must be " + jsonTypeName(typeErr.Type),
+		}}
+		respondBindingError(c, http.StatusBadRequest, "invalid_request", fieldsMessage(fields), fields)
+	case errors.As(err, &typeErr):
+		respondBindingError(c, http.StatusBadRequest, "invalid_request", "Request body must be "+jsonTypeName(typeErr.Type), nil)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// The decoder reports only the key, not where it was nested
+		fields := []models.FieldError{{
+			Field:   strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`),
+			Code:    "unknown_field",
+			Message: "is not a recognized field",
+		}}
+		respondBindingError(c, http.StatusBadRequest, "invalid_request", fieldsMessage(fields), fields)
+	default:
+		respondBindingError(c, http.StatusBadRequest, "invalid_request", err.Error(), nil)
+	}
+}
+
+func respondBindingError(c *gin.Context, status int, code, message string, fields []models.FieldError) {
+	c.JSON(status, models.ErrorResponse{
+		Error:     code,
+		Message:   message,
+		Timestamp: time.Now().UTC(),
+		Fields:    fields,
+	})
+}
+
+// fieldError describes a failed validation rule. The code is the rule's tag.
+func fieldError(fe validator.FieldError) models.FieldError {
+	field := fe.Namespace()
+	if i := strings.Index(field, "."); i >= 0 {
+		field = field[i+1:]
+	}
+
+	var message string
+	switch fe.Tag() {
+	case "required":
+		message = "is required"
+	case "oneof":
+		message = "must be one of " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case "url":
+		message = "must be a valid URL"
+	case "min", "max":
+		bound := "at least"
+		if fe.Tag() == "max" {
+			bound = "at most"
+		}
+		switch fe.Kind() {
+		case reflect.String:
+			message = fmt.Sprintf("must be %s %s characters", bound, fe.Param())
+		case reflect.Slice, reflect.Array, reflect.Map:
+			message = fmt.Sprintf("must have %s %s items", bound, fe.Param())
+		default:
+			message = fmt.Sprintf("must be %s %s", bound, fe.Param())
+		}
+	default:
+		message = fmt.Sprintf("failed the %s rule", fe.Tag())
+	}
+
+	return models.FieldError{Field: field, Code: fe.Tag(), Message: message}
+}
+
+// fieldsMessage summarizes field errors in one line
+func fieldsMessage(fields []models.FieldError) string {
+	parts := make([]string, len(fields))
+	for i, f := range fields {
+		parts[i] = f.Field + " " + f.Message
+	}
+	return strings.Join(parts, "; ")
+}
+
+// jsonFieldName names a struct field by its JSON key
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// jsonTypeName describes the JSON value expected for a Go type
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	case reflect.Ptr:
+		return jsonTypeName(t.Elem())
+	default:
+		return "a " + t.Kind().String()
+	}
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ai-support-assistant/backend/internal/middleware"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// bindQuery posts body to a route binding a QueryRequest behind a body
+// limit, echoing the bound request back
+func bindQuery(body string, limit int64) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.POST("/api/query", middleware.MaxBodySize(limit), func(c *gin.Context) {
+		var req models.QueryRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+		c.JSON(http.StatusOK, req)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/query", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func decodeBody(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("failed to decode %s: %v", w.Body.String(), err)
+	}
+}
+
+func TestBindJSONUnknownFields(t *testing.T) {
+	w := bindQuery(`{"query":"Where is my order?","sesion_id":"s1"}`, 1<<20)
+	var resp models.ErrorResponse
+	decodeBody(t, w, &resp)
+	if w.Code != http.StatusBadRequest || len(resp.Fields) != 1 || resp.Fields[0].Field != "sesion_id" || resp.Fields[0].Code != "unknown_field" {
+		t.Errorf("misspelled field = %d %+v, want 400 naming sesion_id", w.Code, resp)
+	}
+}
+
+func TestBindJSONDropsUnknownMetadataKeys(t *testing.T) {
+	w := bindQuery(`{"query":"Where is my order?","session_id":"s1","metadata":{"page_url":"https://shop.example.com/orders","theme":"dark","screen":{"width":390}}}`, 1<<20)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s; want unknown metadata keys dropped", w.Code, w.Body.String())
+	}
+	var req models.QueryRequest
+	decodeBody(t, w, &req)
+	if req.Metadata == nil || req.Metadata.PageURL != "https://shop.example.com/orders" {
+		t.Errorf("metadata = %+v, want the known keys kept", req.Metadata)
+	}
+	if strings.Contains(w.Body.String(), "theme") {
+		t.Errorf("unknown metadata key kept: %s", w.Body.String())
+	}
+}
+
+func TestBindJSONValidatesMetadata(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		field string
+	}{
+		{"invalid value", `{"query":"hi","session_id":"s1","metadata":{"page_url":"not a url","theme":"dark"}}`, "metadata.page_url"},
+		{"wrong type", `{"query":"hi","session_id":"s1","metadata":{"locale":5}}`, "metadata.locale"},
+		{"not an object", `{"query":"hi","session_id":"s1","metadata":"page"}`, "metadata"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := bindQuery(tt.body, 1<<20)
+			var resp models.ErrorResponse
+			decodeBody(t, w, &resp)
+			if w.Code != http.StatusBadRequest || len(resp.Fields) != 1 || resp.Fields[0].Field != tt.field {
+				t.Errorf("response = %d %+v, want 400 naming %s", w.Code, resp, tt.field)
+			}
+		})
+	}
+}
+
+func TestBindJSONBodyLimit(t *testing.T) {
+	padding := strings.Repeat("x", 200)
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"within the limit", `{"query":"hi","session_id":"s1"}`, http.StatusOK},
+		{"over the limit", `{"query":"` + padding + `"}`, http.StatusRequestEntityTooLarge},
+		// Metadata is decoded leniently, but its bytes still count
+		{"over the limit in metadata", `{"query":"hi","session_id":"s1","metadata":{"theme":"` + padding + `"}}`, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := bindQuery(tt.body, 100)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, body %s; want %d", w.Code, w.Body.String(), tt.status)
+			}
+			if tt.status == http.StatusRequestEntityTooLarge {
+				var resp models.ErrorResponse
+				decodeBody(t, w, &resp)
+				if resp.Error != "request_too_large" || !strings.Contains(resp.Message, "100 bytes") {
+					t.Errorf("response = %+v, want request_too_large naming the limit", resp)
+				}
+			}
+		})
+	}
+}
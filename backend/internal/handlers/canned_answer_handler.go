@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type CannedAnswerHandler struct {
+	cannedAnswerService *services.CannedAnswerService
+}
+
+func NewCannedAnswerHandler(cannedAnswerService *services.CannedAnswerService) *CannedAnswerHandler {
+	return &CannedAnswerHandler{cannedAnswerService: cannedAnswerService}
+}
+
+// HandleCreateCannedAnswer handles POST /api/admin/answers
+func (h *CannedAnswerHandler) HandleCreateCannedAnswer(c *gin.Context) {
+	req, ok := bindCannedAnswerRequest(c)
+	if !ok {
+		return
+	}
+
+	answer, err := h.cannedAnswerService.CreateCannedAnswer(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err, "create_error", "Failed to create canned answer")
+		return
+	}
+
+	c.JSON(http.StatusCreated, answer)
+}
+
+// HandleGetCannedAnswers handles GET /api/admin/answers
+func (h *CannedAnswerHandler) HandleGetCannedAnswers(c *gin.Context) {
+	answers, err := h.cannedAnswerService.GetCannedAnswers(c.Request.Context())
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch canned answers")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"answers": answers,
+		"count":   len(answers),
+	})
+}
+
+// HandleGetCannedAnswer handles GET /api/admin/answers/:id
+func (h *CannedAnswerHandler) HandleGetCannedAnswer(c *gin.Context) {
+	id, ok := parseCannedAnswerID(c)
+	if !ok {
+		return
+	}
+
+	answer, err := h.cannedAnswerService.GetCannedAnswerByID(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch canned answer")
+		return
+	}
+
+	c.JSON(http.StatusOK, answer)
+}
+
+// HandleUpdateCannedAnswer handles PUT /api/admin/answers/:id
+func (h *CannedAnswerHandler) HandleUpdateCannedAnswer(c *gin.Context) {
+	id, ok := parseCannedAnswerID(c)
+	if !ok {
+		return
+	}
+
+	req, ok := bindCannedAnswerRequest(c)
+	if !ok {
+		return
+	}
+
+	answer, err := h.cannedAnswerService.UpdateCannedAnswer(c.Request.Context(), id, req)
+	if err != nil {
+		respondError(c, err, "update_error", "Failed to update canned answer")
+		return
+	}
+
+	c.JSON(http.StatusOK, answer)
+}
+
+// HandleDeleteCannedAnswer handles DELETE /api/admin/answers/:id
+func (h *CannedAnswerHandler) HandleDeleteCannedAnswer(c *gin.Context) {
+	id, ok := parseCannedAnswerID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.cannedAnswerService.DeleteCannedAnswer(c.Request.Context(), id); err != nil {
+		respondError(c, err, "delete_error", "Failed to delete canned answer")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Canned answer deleted successfully",
+		"id":      id,
+	})
+}
+
+// bindCannedAnswerRequest binds and validates a canned answer request body
+func bindCannedAnswerRequest(c *gin.Context) (models.CannedAnswerRequest, bool) {
+	var req models.CannedAnswerRequest
+
+	if !bindJSON(c, &req) {
+		return req, false
+	}
+
+	return req, true
+}
+
+// parseCannedAnswerID parses the :id path parameter
+func parseCannedAnswerID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid canned answer ID",
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ai-support-assistant/backend/internal/chaos"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+type ChaosHandler struct {
+	injector *chaos.Injector
+}
+
+func NewChaosHandler(injector *chaos.Injector) *ChaosHandler {
+	return &ChaosHandler{injector: injector}
+}
+
+// HandleCreateFault handles POST /api/admin/chaos/faults
+func (h *ChaosHandler) HandleCreateFault(c *gin.Context) {
+	var req chaos.FaultRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	fault, err := h.injector.Add(req)
+	if err != nil {
+		if errors.Is(err, chaos.ErrInvalidFault) {
+			c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+				Error:   "validation_error",
+				Message: err.Error(),
+			})
+			return
+		}
+		respondError(c, err, "create_error", "Failed to register chaos fault")
+		return
+	}
+
+	c.JSON(http.StatusCreated, fault)
+}
+
+// HandleGetFaults handles GET /api/admin/chaos/faults
+func (h *ChaosHandler) HandleGetFaults(c *gin.Context) {
+	faults := h.injector.List()
+
+	c.JSON(http.StatusOK, gin.H{
+		"faults": faults,
+		"count":  len(faults),
+	})
+}
+
+// HandleDeleteFault handles DELETE /api/admin/chaos/faults/:id
+func (h *ChaosHandler) HandleDeleteFault(c *gin.Context) {
+	id := c.Param("id")
+	if !h.injector.Remove(id) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "No active chaos fault with this ID",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Chaos fault removed successfully",
+		"id":      id,
+	})
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type CollectionHandler struct {
+	collectionService *services.CollectionService
+}
+
+func NewCollectionHandler(collectionService *services.CollectionService) *CollectionHandler {
+	return &CollectionHandler{collectionService: collectionService}
+}
+
+// HandleGetCollections handles GET /api/collections
+func (h *CollectionHandler) HandleGetCollections(c *gin.Context) {
+	collections, err := h.collectionService.GetCollections(c.Request.Context())
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch collections")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"collections": collections,
+		"count":       len(collections),
+	})
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type ConfigBundleHandler struct {
+	configBundleService *services.ConfigBundleService
+}
+
+func NewConfigBundleHandler(configBundleService *services.ConfigBundleService) *ConfigBundleHandler {
+	return &ConfigBundleHandler{configBundleService: configBundleService}
+}
+
+// HandleExportConfig handles GET /api/admin/export
+func (h *ConfigBundleHandler) HandleExportConfig(c *gin.Context) {
+	bundle, err := h.configBundleService.Export(c.Request.Context())
+	if err != nil {
+		respondError(c, err, "export_error", "Failed to export configuration")
+		return
+	}
+
+	c.JSON(http.StatusOK, bundle)
+}
+
+// HandleImportConfig handles POST /api/admin/import
+func (h *ConfigBundleHandler) HandleImportConfig(c *gin.Context) {
+	var req models.ConfigImportRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	result, err := h.configBundleService.Import(c.Request.Context(), req, c.Query("dry_run") == "true")
+	if err != nil {
+		respondError(c, err, "import_error", "Failed to import configuration")
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
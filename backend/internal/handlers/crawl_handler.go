@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type CrawlHandler struct {
+	crawlService *services.CrawlService
+}
+
+func NewCrawlHandler(crawlService *services.CrawlService) *CrawlHandler {
+	return &CrawlHandler{crawlService: crawlService}
+}
+
+// HandleIngestURL handles POST /api/docs/ingest-url
+func (h *CrawlHandler) HandleIngestURL(c *gin.Context) {
+	var req models.CrawlRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	createdBy := c.GetString("user_id")
+	if createdBy == "" {
+		createdBy = "anonymous"
+	}
+
+	job, err := h.crawlService.StartCrawl(c.Request.Context(), req, createdBy)
+	if err != nil {
+		respondError(c, err, "crawl_error", "Failed to start crawl")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// HandleGetCrawlJob handles GET /api/docs/crawl-jobs/:id
+func (h *CrawlHandler) HandleGetCrawlJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid crawl job ID",
+		})
+		return
+	}
+
+	job, err := h.crawlService.GetCrawlJob(c.Request.Context(), uint(id))
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch crawl job")
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type DashboardHandler struct {
+	dashboardService *services.DashboardService
+}
+
+func NewDashboardHandler(dashboardService *services.DashboardService) *DashboardHandler {
+	return &DashboardHandler{dashboardService: dashboardService}
+}
+
+// HandleGetDashboard handles GET /api/admin/dashboard
+func (h *DashboardHandler) HandleGetDashboard(c *gin.Context) {
+	summary, err := h.dashboardService.GetSummary(c.Request.Context())
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch dashboard")
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
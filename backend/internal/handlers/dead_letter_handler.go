@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// maxDeadLetterPageSize caps the number of dead letters returned per page
+const maxDeadLetterPageSize = 200
+
+type DeadLetterHandler struct {
+	deadLetterService *services.DeadLetterService
+}
+
+func NewDeadLetterHandler(deadLetterService *services.DeadLetterService) *DeadLetterHandler {
+	return &DeadLetterHandler{deadLetterService: deadLetterService}
+}
+
+// HandleGetDeadLetters handles GET /api/admin/dead-letters
+func (h *DeadLetterHandler) HandleGetDeadLetters(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+	if limit > maxDeadLetterPageSize {
+		limit = maxDeadLetterPageSize
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	letters, total, err := h.deadLetterService.GetDeadLetters(c.Request.Context(), services.DeadLetterFilter{
+		Operation: c.Query("operation"),
+		Status:    c.Query("status"),
+		Limit:     limit,
+		Offset:    offset,
+	})
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch dead letters")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"dead_letters": letters,
+		"count":        len(letters),
+		"total":        total,
+		"limit":        limit,
+		"offset":       offset,
+	})
+}
+
+// HandleReplayDeadLetter handles POST /api/admin/dead-letters/:id/replay
+func (h *DeadLetterHandler) HandleReplayDeadLetter(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid dead letter ID",
+		})
+		return
+	}
+
+	letter, err := h.deadLetterService.Replay(c.Request.Context(), uint(id))
+	if err != nil {
+		respondError(c, err, "replay_error", "Failed to replay dead letter")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Dead letter replayed successfully",
+		"dead_letter": letter,
+	})
+}
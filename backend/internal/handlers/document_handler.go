@@ -1,21 +1,34 @@
 package handlers
 
 import (
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/ai-support-assistant/backend/internal/middleware"
 	"github.com/ai-support-assistant/backend/internal/models"
 	"github.com/ai-support-assistant/backend/internal/services"
 	"github.com/gin-gonic/gin"
-	"github.com/sirupsen/logrus"
+)
+
+// Document progress streams poll the document at documentProgressPollInterval,
+// send a keep-alive comment when idle for documentProgressKeepalive and end
+// after documentProgressMaxDuration, e.g. if ingestion stalls
+const (
+	documentProgressPollInterval = time.Second
+	documentProgressKeepalive    = 15 * time.Second
+	documentProgressMaxDuration  = 30 * time.Minute
 )
 
 type DocumentHandler struct {
 	documentService *services.DocumentService
+	quotaService    *services.QuotaService
 }
 
-func NewDocumentHandler(documentService *services.DocumentService) *DocumentHandler {
-	return &DocumentHandler{documentService: documentService}
+func NewDocumentHandler(documentService *services.DocumentService, quotaService *services.QuotaService) *DocumentHandler {
+	return &DocumentHandler{documentService: documentService, quotaService: quotaService}
 }
 
 // HandleUploadDocument handles POST /api/docs/upload
@@ -30,24 +43,92 @@ func (h *DocumentHandler) HandleUploadDocument(c *gin.Context) {
 	}
 	defer file.Close()
 
+	// The caller's plan may cap upload sizes
+	plan := h.quotaService.Plan(c.GetString("plan"))
+	if plan.MaxUploadBytes > 0 && header.Size > plan.MaxUploadBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, models.ErrorResponse{
+			Error:   "file_too_large",
+			Message: fmt.Sprintf("The %s plan allows uploads of up to %d bytes", plan.Name, plan.MaxUploadBytes),
+		})
+		return
+	}
+
 	uploadedBy := c.GetString("user_id")
 	if uploadedBy == "" {
 		uploadedBy = "anonymous"
 	}
 
-	response, err := h.documentService.UploadDocument(c.Request.Context(), file, header, uploadedBy)
+	options, err := ingestOptionsForm(c)
 	if err != nil {
-		logrus.WithError(err).Error("Failed to upload document")
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "upload_error",
-			Message: "Failed to upload document",
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+			Error:   "validation_error",
+			Message: err.Error(),
 		})
 		return
 	}
 
+	response, err := h.documentService.UploadDocument(c.Request.Context(), file, header, uploadedBy, c.PostForm("collection"), c.PostForm("document_key"), c.PostForm("visibility"), options)
+	if err != nil {
+		respondError(c, err, "upload_error", "Failed to upload document")
+		return
+	}
+
 	c.JSON(http.StatusOK, response)
 }
 
+// ingestOptionsForm reads the optional ingestion option fields of an upload
+// form; ranges are checked by the service
+func ingestOptionsForm(c *gin.Context) (models.IngestOptionsRequest, error) {
+	var options models.IngestOptionsRequest
+
+	if value, ok := c.GetPostForm("chunk_size"); ok && value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil {
+			return options, fmt.Errorf("chunk_size must be an integer")
+		}
+		options.ChunkSize = &size
+	}
+	if value, ok := c.GetPostForm("chunk_overlap"); ok && value != "" {
+		overlap, err := strconv.Atoi(value)
+		if err != nil {
+			return options, fmt.Errorf("chunk_overlap must be an integer")
+		}
+		options.ChunkOverlap = &overlap
+	}
+	if value, ok := c.GetPostForm("ocr"); ok && value != "" {
+		ocr, err := strconv.ParseBool(value)
+		if err != nil {
+			return options, fmt.Errorf("ocr must be true or false")
+		}
+		options.OCR = &ocr
+	}
+	if value, ok := c.GetPostForm("language"); ok {
+		options.Language = &value
+	}
+	return options, nil
+}
+
+// HandleIngestObject handles POST /api/docs/ingest-object
+func (h *DocumentHandler) HandleIngestObject(c *gin.Context) {
+	var req models.ObjectIngestRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	uploadedBy := c.GetString("user_id")
+	if uploadedBy == "" {
+		uploadedBy = "anonymous"
+	}
+
+	response, err := h.documentService.IngestObject(c.Request.Context(), req, uploadedBy)
+	if err != nil {
+		respondError(c, err, "ingest_error", "Failed to start object ingestion")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, response)
+}
+
 // HandleGetDocuments handles GET /api/docs
 func (h *DocumentHandler) HandleGetDocuments(c *gin.Context) {
 	limitStr := c.DefaultQuery("limit", "50")
@@ -63,13 +144,11 @@ func (h *DocumentHandler) HandleGetDocuments(c *gin.Context) {
 		offset = 0
 	}
 
-	documents, err := h.documentService.GetDocuments(c.Request.Context(), limit, offset)
+	includeSuperseded := c.Query("include_superseded") == "true"
+
+	documents, err := h.documentService.GetDocuments(c.Request.Context(), limit, offset, c.Query("collection"), c.Query("visibility"), includeSuperseded)
 	if err != nil {
-		logrus.WithError(err).Error("Failed to get documents")
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "fetch_error",
-			Message: "Failed to fetch documents",
-		})
+		respondError(c, err, "fetch_error", "Failed to fetch documents")
 		return
 	}
 
@@ -93,12 +172,195 @@ func (h *DocumentHandler) HandleGetDocument(c *gin.Context) {
 
 	document, err := h.documentService.GetDocumentByID(c.Request.Context(), uint(id))
 	if err != nil {
-		c.JSON(http.StatusNotFound, models.ErrorResponse{
-			Error:   "not_found",
-			Message: "Document not found",
+		respondError(c, err, "fetch_error", "Failed to fetch document")
+		return
+	}
+
+	c.JSON(http.StatusOK, document)
+}
+
+// HandleGetDocumentVersions handles GET /api/docs/:id/versions
+func (h *DocumentHandler) HandleGetDocumentVersions(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid document ID",
+		})
+		return
+	}
+
+	versions, err := h.documentService.GetDocumentVersions(c.Request.Context(), uint(id))
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch document versions")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"versions": versions,
+		"count":    len(versions),
+	})
+}
+
+// HandleUpdateDocument handles PATCH /api/docs/:id
+func (h *DocumentHandler) HandleUpdateDocument(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid document ID",
 		})
 		return
 	}
 
+	var req models.DocumentUpdateRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	document, err := h.documentService.UpdateDocument(c.Request.Context(), uint(id), req)
+	if err != nil {
+		respondError(c, err, "update_error", "Failed to update document")
+		return
+	}
+
 	c.JSON(http.StatusOK, document)
 }
+
+// HandleGetDocumentStatus handles GET /api/docs/:id/status
+func (h *DocumentHandler) HandleGetDocumentStatus(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid document ID",
+		})
+		return
+	}
+
+	status, err := h.documentService.GetDocumentStatus(c.Request.Context(), uint(id))
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch document status")
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// HandleGetDocumentPreview handles GET /api/docs/:id/preview
+func (h *DocumentHandler) HandleGetDocumentPreview(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid document ID",
+		})
+		return
+	}
+
+	preview, err := h.documentService.GetDocumentPreview(c.Request.Context(), uint(id), callerAudience(c))
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch document preview")
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
+
+// callerAudience is the agent audience for admins, agents and tokens issued
+// for the agent audience, and the customer audience for everyone else
+func callerAudience(c *gin.Context) string {
+	switch c.GetString("role") {
+	case middleware.RoleAdmin, middleware.RoleAgent:
+		return models.AudienceAgent
+	}
+	if c.GetString("audience") == models.AudienceAgent {
+		return models.AudienceAgent
+	}
+	return models.AudienceCustomer
+}
+
+// HandleStreamDocumentProgress handles GET /api/docs/:id/progress. It sends
+// the document's status as a progress event whenever it changes, ending the
+// stream once ingestion is done, the client disconnects or
+// documentProgressMaxDuration has passed.
+func (h *DocumentHandler) HandleStreamDocumentProgress(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid document ID",
+		})
+		return
+	}
+
+	if plan := h.quotaService.Plan(c.GetString("plan")); !plan.Streaming {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "streaming_not_allowed",
+			Message: fmt.Sprintf("The %s plan doesn't include streaming; poll /api/docs/%d/status instead", plan.Name, id),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	status, err := h.documentService.GetDocumentStatus(ctx, uint(id))
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch document status")
+		return
+	}
+
+	// The stream outlives the server's write timeout
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(documentProgressMaxDuration + time.Minute))
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	c.SSEvent("progress", status)
+	c.Writer.Flush()
+	if services.IngestionDone(status.Status) {
+		return
+	}
+
+	ticker := time.NewTicker(documentProgressPollInterval)
+	defer ticker.Stop()
+	deadline := time.NewTimer(documentProgressMaxDuration)
+	defer deadline.Stop()
+	lastSent := time.Now()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-deadline.C:
+			return false
+		case <-ticker.C:
+		}
+
+		current, err := h.documentService.GetDocumentStatus(ctx, uint(id))
+		if err != nil {
+			if ctx.Err() == nil {
+				c.SSEvent("error", gin.H{"error": "fetch_error", "message": "Failed to fetch document status"})
+			}
+			return false
+		}
+
+		if current.Status != status.Status || !current.UpdatedAt.Equal(status.UpdatedAt) {
+			status = current
+			lastSent = time.Now()
+			c.SSEvent("progress", status)
+		} else if time.Since(lastSent) >= documentProgressKeepalive {
+			// A comment keeps proxies from closing an idle stream
+			lastSent = time.Now()
+			io.WriteString(w, ": keepalive\n\n")
+		}
+
+		return !services.IngestionDone(status.Status)
+	})
+}
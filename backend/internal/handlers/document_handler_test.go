@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ai-support-assistant/backend/internal/middleware"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+func TestCallerAudience(t *testing.T) {
+	tests := []struct {
+		name     string
+		role     string
+		audience string
+		want     string
+	}{
+		{"anonymous", "", "", models.AudienceCustomer},
+		{"customer token", "", models.AudienceCustomer, models.AudienceCustomer},
+		{"agent audience token", "", models.AudienceAgent, models.AudienceAgent},
+		{"agent", middleware.RoleAgent, "", models.AudienceAgent},
+		{"admin", middleware.RoleAdmin, "", models.AudienceAgent},
+		{"other role", "billing", models.AudienceCustomer, models.AudienceCustomer},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		if tt.role != "" {
+			c.Set("role", tt.role)
+		}
+		if tt.audience != "" {
+			c.Set("audience", tt.audience)
+		}
+		if got := callerAudience(c); got != tt.want {
+			t.Errorf("%s: callerAudience = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
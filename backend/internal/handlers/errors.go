@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// statusClientClosedRequest is the non-standard status used when the client disconnects
+const statusClientClosedRequest = 499
+
+// respondError maps a service error to an HTTP status and a stable error code.
+// Unrecognized errors are reported as 500 with the given fallback code and message.
+func respondError(c *gin.Context, err error, fallbackCode, fallbackMessage string) {
+	status := http.StatusInternalServerError
+	code := fallbackCode
+	message := fallbackMessage
+	var queueDepth, retryAfter, excessTokens *int
+	var generationID string
+	var quota *models.QuotaUsage
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		// The client went away; there is nobody to respond to and nothing to alert on
+		logrus.WithField("path", c.FullPath()).Debug("Request cancelled by client")
+		c.AbortWithStatus(statusClientClosedRequest)
+		return
+	case errors.Is(err, services.ErrInvalidRequest):
+		status, code, message = http.StatusBadRequest, "invalid_request", err.Error()
+	case errors.Is(err, services.ErrValidation):
+		status, code, message = http.StatusUnprocessableEntity, "validation_error", err.Error()
+	case errors.Is(err, services.ErrForbidden):
+		status, code, message = http.StatusForbidden, "forbidden", "You do not have access to this resource"
+	case errors.Is(err, services.ErrNotYetVisible):
+		// Saved but not yet readable; asking again shortly succeeds
+		status, code, message = http.StatusServiceUnavailable, "not_yet_visible", err.Error()
+		c.Header("Retry-After", "1")
+	case errors.Is(err, services.ErrNotFound):
+		status, code, message = http.StatusNotFound, "not_found", err.Error()
+	case errors.Is(err, services.ErrConflict):
+		status, code, message = http.StatusConflict, "conflict", err.Error()
+	case errors.Is(err, services.ErrRAGBadRequest):
+		status, code, message = http.StatusBadRequest, "rag_bad_request", "The request could not be processed. Please shorten or rephrase it."
+	case errors.Is(err, services.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		status, code, message = http.StatusGatewayTimeout, "timeout", "The request timed out. Please try again."
+		var timedOut *services.GenerationTimeoutError
+		if errors.As(err, &timedOut) {
+			// The answer may still be generating; asking again with its ID picks it up
+			seconds := int(math.Ceil(timedOut.RetryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			message = fmt.Sprintf("The answer is taking longer than usual. Please ask again in %d seconds with the generation_id to get it.", seconds)
+			retryAfter = &seconds
+			generationID = timedOut.GenerationID
+		}
+	case errors.Is(err, services.ErrDegraded):
+		status, code, message = http.StatusServiceUnavailable, "service_degraded", "The answer service is temporarily unavailable. Please try again shortly."
+		var degraded *services.DegradedError
+		if errors.As(err, &degraded) && degraded.RetryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(degraded.RetryAfter.Seconds())))
+		}
+	case errors.Is(err, services.ErrBusy):
+		status, code, message = http.StatusServiceUnavailable, "system_busy", "The service is handling too many requests. Please try again shortly."
+		var busy *services.BusyError
+		if errors.As(err, &busy) {
+			c.Header("Retry-After", strconv.Itoa(int(busy.RetryAfter.Seconds())))
+			queueDepth = &busy.QueueDepth
+		}
+	case errors.Is(err, services.ErrUpstreamRateLimited):
+		status, code, message = http.StatusTooManyRequests, "upstream_rate_limited", "The answer service is receiving too many requests. Please try again shortly."
+		var limited *services.UpstreamRateLimitError
+		if errors.As(err, &limited) {
+			seconds := int(math.Ceil(limited.RetryAfter.Seconds()))
+			if seconds < 1 {
+				seconds = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			message = fmt.Sprintf("The answer service is receiving too many requests. Please try again in %d seconds.", seconds)
+			retryAfter = &seconds
+		}
+	case errors.Is(err, services.ErrPromptTooLong):
+		status, code, message = http.StatusUnprocessableEntity, "prompt_too_long", "The question is too long to answer. Please shorten it."
+		var tooLong *services.PromptTooLongError
+		if errors.As(err, &tooLong) {
+			message = fmt.Sprintf("The question is too long to answer: about %d tokens against a limit of %d. Please remove about %d characters.",
+				tooLong.Estimated, tooLong.Limit, tooLong.TrimChars)
+			excess := tooLong.Excess()
+			excessTokens = &excess
+		}
+	case errors.Is(err, services.ErrQuotaExceeded):
+		status, code, message = http.StatusTooManyRequests, "quota_exceeded", "You have used all the questions your plan allows today."
+		var exceeded *services.QuotaExceededError
+		if errors.As(err, &exceeded) {
+			usage := exceeded.Usage
+			seconds := int(math.Ceil(time.Until(usage.ResetAt).Seconds()))
+			if seconds < 1 {
+				seconds = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			message = fmt.Sprintf("You have used all %d questions your %s plan allows today. Your quota resets at %s.",
+				usage.Limit, usage.Plan, usage.ResetAt.Format(time.RFC3339))
+			retryAfter = &seconds
+			quota = &usage
+		}
+	case errors.Is(err, services.ErrMalwareDetected):
+		status, code, message = http.StatusUnprocessableEntity, "malware_detected", "The file was rejected because it contains malware."
+	case errors.Is(err, services.ErrScanUnavailable):
+		status, code, message = http.StatusServiceUnavailable, "scan_unavailable", "Uploads can't be scanned for malware right now. Please try again shortly."
+	case errors.Is(err, services.ErrLimitExceeded):
+		status, code, message = http.StatusTooManyRequests, "limit_exceeded", err.Error()
+	case errors.Is(err, services.ErrOverloaded):
+		status, code, message = http.StatusServiceUnavailable, "overloaded", "The service is busy. Please try again shortly."
+	case errors.Is(err, services.ErrRAGUnavailable):
+		status, code, message = http.StatusBadGateway, "rag_unavailable", "The answer service is temporarily unavailable. Please try again."
+	}
+
+	logger := logrus.WithError(err).WithFields(logrus.Fields{
+		"path":   c.FullPath(),
+		"status": status,
+		"code":   code,
+	})
+	if status >= http.StatusInternalServerError {
+		logger.Error(fallbackMessage)
+		// Picked up by the recovery middleware for the error tracker
+		_ = c.Error(err)
+	} else {
+		logger.Warn(fallbackMessage)
+	}
+
+	c.JSON(status, models.ErrorResponse{
+		Error:      code,
+		Message:    message,
+		Timestamp:  time.Now().UTC(),
+		QueueDepth: queueDepth,
+		RetryAfter: retryAfter,
+
+		ExcessTokens: excessTokens,
+		GenerationID: generationID,
+		Quota:        quota,
+	})
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// respondWith runs respondError for err and returns the recorded response
+func respondWith(err error) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	respondError(c, err, "internal_error", "Something went wrong")
+	return w
+}
+
+func TestRespondErrorMapping(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"invalid request", fmt.Errorf("%w: bad json", services.ErrInvalidRequest), http.StatusBadRequest, "invalid_request"},
+		{"validation", fmt.Errorf("%w: query too long", services.ErrValidation), http.StatusUnprocessableEntity, "validation_error"},
+		{"forbidden", services.ErrForbidden, http.StatusForbidden, "forbidden"},
+		{"not found", fmt.Errorf("session: %w", services.ErrNotFound), http.StatusNotFound, "not_found"},
+		{"conflict", services.ErrConflict, http.StatusConflict, "conflict"},
+		{"not yet visible", fmt.Errorf("query 7 %w", services.ErrNotYetVisible), http.StatusServiceUnavailable, "not_yet_visible"},
+		{"rag bad request", fmt.Errorf("%w: input too long for embedder", services.ErrRAGBadRequest), http.StatusBadRequest, "rag_bad_request"},
+		{"timeout", fmt.Errorf("failed to call RAG service: %w", services.ErrTimeout), http.StatusGatewayTimeout, "timeout"},
+		{"deadline exceeded", context.DeadlineExceeded, http.StatusGatewayTimeout, "timeout"},
+		{"rag unavailable", fmt.Errorf("%w: connection refused", services.ErrRAGUnavailable), http.StatusBadGateway, "rag_unavailable"},
+		{"degraded", &services.DegradedError{RetryAfter: 10 * time.Second}, http.StatusServiceUnavailable, "service_degraded"},
+		{"overloaded", services.ErrOverloaded, http.StatusServiceUnavailable, "overloaded"},
+		{"upstream rate limited", &services.UpstreamRateLimitError{RetryAfter: 2 * time.Second}, http.StatusTooManyRequests, "upstream_rate_limited"},
+		{"malware", services.ErrMalwareDetected, http.StatusUnprocessableEntity, "malware_detected"},
+		{"scan unavailable", services.ErrScanUnavailable, http.StatusServiceUnavailable, "scan_unavailable"},
+		{"prompt too long", &services.PromptTooLongError{Estimated: 9000, Limit: 8000, TrimChars: 4000}, http.StatusUnprocessableEntity, "prompt_too_long"},
+		{"unrecognized", errors.New("boom"), http.StatusInternalServerError, "internal_error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := respondWith(tt.err)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			var body models.ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to decode body %q: %v", w.Body.String(), err)
+			}
+			if body.Error != tt.code {
+				t.Errorf("code = %q, want %q", body.Error, tt.code)
+			}
+		})
+	}
+}
+
+func TestRespondErrorClientCancelled(t *testing.T) {
+	w := respondWith(fmt.Errorf("failed to call RAG service: %w", context.Canceled))
+	if w.Code != statusClientClosedRequest {
+		t.Errorf("status = %d, want %d", w.Code, statusClientClosedRequest)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("body = %q, want none", w.Body.String())
+	}
+}
+
+func TestRespondErrorRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		retryAfter string
+	}{
+		{"generation timeout", &services.GenerationTimeoutError{GenerationID: "gen-1", RetryAfter: 1500 * time.Millisecond, Err: services.ErrTimeout}, "2"},
+		{"busy", &services.BusyError{RetryAfter: 5 * time.Second, QueueDepth: 3}, "5"},
+		{"upstream rate limited", &services.UpstreamRateLimitError{RetryAfter: 200 * time.Millisecond}, "1"},
+		{"degraded", &services.DegradedError{RetryAfter: 30 * time.Second}, "30"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := respondWith(tt.err)
+			if got := w.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.retryAfter)
+			}
+		})
+	}
+
+	w := respondWith(&services.GenerationTimeoutError{GenerationID: "gen-1", RetryAfter: time.Second, Err: services.ErrTimeout})
+	var body models.ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if body.GenerationID != "gen-1" {
+		t.Errorf("GenerationID = %q, want gen-1", body.GenerationID)
+	}
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type ExperimentHandler struct {
+	experimentService *services.ExperimentService
+}
+
+func NewExperimentHandler(experimentService *services.ExperimentService) *ExperimentHandler {
+	return &ExperimentHandler{experimentService: experimentService}
+}
+
+// HandleCreateExperiment handles POST /api/admin/experiments
+func (h *ExperimentHandler) HandleCreateExperiment(c *gin.Context) {
+	req, ok := bindExperimentRequest(c)
+	if !ok {
+		return
+	}
+
+	experiment, err := h.experimentService.CreateExperiment(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err, "create_error", "Failed to create experiment")
+		return
+	}
+
+	c.JSON(http.StatusCreated, experiment)
+}
+
+// HandleGetExperiments handles GET /api/admin/experiments
+func (h *ExperimentHandler) HandleGetExperiments(c *gin.Context) {
+	experiments, err := h.experimentService.GetExperiments(c.Request.Context())
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch experiments")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"experiments": experiments,
+		"count":       len(experiments),
+	})
+}
+
+// HandleGetExperiment handles GET /api/admin/experiments/:id
+func (h *ExperimentHandler) HandleGetExperiment(c *gin.Context) {
+	id, ok := parseExperimentID(c)
+	if !ok {
+		return
+	}
+
+	experiment, err := h.experimentService.GetExperimentByID(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch experiment")
+		return
+	}
+
+	c.JSON(http.StatusOK, experiment)
+}
+
+// HandleUpdateExperiment handles PUT /api/admin/experiments/:id
+func (h *ExperimentHandler) HandleUpdateExperiment(c *gin.Context) {
+	id, ok := parseExperimentID(c)
+	if !ok {
+		return
+	}
+
+	req, ok := bindExperimentRequest(c)
+	if !ok {
+		return
+	}
+
+	experiment, err := h.experimentService.UpdateExperiment(c.Request.Context(), id, req)
+	if err != nil {
+		respondError(c, err, "update_error", "Failed to update experiment")
+		return
+	}
+
+	c.JSON(http.StatusOK, experiment)
+}
+
+// HandleDeleteExperiment handles DELETE /api/admin/experiments/:id
+func (h *ExperimentHandler) HandleDeleteExperiment(c *gin.Context) {
+	id, ok := parseExperimentID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.experimentService.DeleteExperiment(c.Request.Context(), id); err != nil {
+		respondError(c, err, "delete_error", "Failed to delete experiment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Experiment deleted successfully",
+		"id":      id,
+	})
+}
+
+// HandleGetExperimentResults handles GET /api/admin/experiments/:id/results
+func (h *ExperimentHandler) HandleGetExperimentResults(c *gin.Context) {
+	id, ok := parseExperimentID(c)
+	if !ok {
+		return
+	}
+
+	results, err := h.experimentService.GetResults(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch experiment results")
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+// bindExperimentRequest binds and validates a experiment request body
+func bindExperimentRequest(c *gin.Context) (models.ExperimentRequest, bool) {
+	var req models.ExperimentRequest
+
+	if !bindJSON(c, &req) {
+		return req, false
+	}
+
+	return req, true
+}
+
+// parseExperimentID parses the :id path parameter
+func parseExperimentID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid experiment ID",
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// unsafeFilenameChars matches characters not allowed in download file names
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+type ExportHandler struct {
+	exportService *services.ExportService
+}
+
+func NewExportHandler(exportService *services.ExportService) *ExportHandler {
+	return &ExportHandler{exportService: exportService}
+}
+
+// HandleExportSession handles GET /api/sessions/:session_id/export
+func (h *ExportHandler) HandleExportSession(c *gin.Context) {
+	sessionID := c.Param("session_id")
+	format := c.DefaultQuery("format", services.ExportMarkdown)
+	includeSources := c.Query("include_sources") == "true"
+
+	if !services.IsValidExportFormat(format) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_format",
+			Message: "Invalid format, must be one of json, markdown, txt",
+		})
+		return
+	}
+
+	var segment *int
+	if raw := c.Query("segment"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 0 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_segment",
+				Message: "segment must be a non-negative integer",
+			})
+			return
+		}
+		segment = &value
+	}
+
+	queries, err := h.exportService.GetSessionTranscript(c.Request.Context(), sessionID, c.GetString("user_id"), segment)
+	if err != nil {
+		respondError(c, err, "export_error", "Failed to export session")
+		return
+	}
+
+	contentType, extension := "text/markdown; charset=utf-8", "md"
+	switch format {
+	case services.ExportJSON:
+		contentType, extension = "application/json", "json"
+	case services.ExportText:
+		contentType, extension = "text/plain; charset=utf-8", "txt"
+	}
+
+	filename := fmt.Sprintf("session-%s.%s", unsafeFilenameChars.ReplaceAllString(sessionID, "_"), extension)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	if err := services.WriteTranscript(c.Writer, format, queries, includeSources); err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Warn("Failed to write session export")
+	}
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type FAQHandler struct {
+	faqService *services.FAQService
+}
+
+func NewFAQHandler(faqService *services.FAQService) *FAQHandler {
+	return &FAQHandler{faqService: faqService}
+}
+
+// HandleGetFAQ handles GET /api/faq, the published entries by category
+func (h *FAQHandler) HandleGetFAQ(c *gin.Context) {
+	categories, err := h.faqService.GetPublished(c.Request.Context())
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch FAQ")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"categories": categories,
+		"count":      len(categories),
+	})
+}
+
+// HandleGetFAQEntries handles GET /api/admin/faq
+func (h *FAQHandler) HandleGetFAQEntries(c *gin.Context) {
+	entries, err := h.faqService.GetEntries(c.Request.Context())
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch FAQ entries")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"count":   len(entries),
+	})
+}
+
+// HandleCreateFAQEntry handles POST /api/admin/faq
+func (h *FAQHandler) HandleCreateFAQEntry(c *gin.Context) {
+	var req models.FAQEntryRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	entry, err := h.faqService.CreateEntry(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err, "create_error", "Failed to create FAQ entry")
+		return
+	}
+
+	c.JSON(http.StatusCreated, entry)
+}
+
+// HandleCreateFAQEntryFromQuery handles POST /api/admin/faq/from-query/:query_id,
+// drafting an entry from a user question and its answer
+func (h *FAQHandler) HandleCreateFAQEntryFromQuery(c *gin.Context) {
+	queryID, err := strconv.ParseUint(c.Param("query_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid query ID",
+		})
+		return
+	}
+
+	entry, err := h.faqService.CreateEntryFromQuery(c.Request.Context(), uint(queryID))
+	if err != nil {
+		respondError(c, err, "create_error", "Failed to create FAQ entry")
+		return
+	}
+
+	c.JSON(http.StatusCreated, entry)
+}
+
+// HandleReorderFAQEntries handles POST /api/admin/faq/reorder
+func (h *FAQHandler) HandleReorderFAQEntries(c *gin.Context) {
+	var req models.FAQReorderRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	entries, err := h.faqService.Reorder(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err, "update_error", "Failed to reorder FAQ entries")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"count":   len(entries),
+	})
+}
+
+// HandleGetFAQEntry handles GET /api/admin/faq/:id
+func (h *FAQHandler) HandleGetFAQEntry(c *gin.Context) {
+	id, ok := parseFAQEntryID(c)
+	if !ok {
+		return
+	}
+
+	entry, err := h.faqService.GetEntry(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch FAQ entry")
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// HandleUpdateFAQEntry handles PUT /api/admin/faq/:id
+func (h *FAQHandler) HandleUpdateFAQEntry(c *gin.Context) {
+	id, ok := parseFAQEntryID(c)
+	if !ok {
+		return
+	}
+
+	var req models.FAQEntryRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	entry, err := h.faqService.UpdateEntry(c.Request.Context(), id, req)
+	if err != nil {
+		respondError(c, err, "update_error", "Failed to update FAQ entry")
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// HandleDeleteFAQEntry handles DELETE /api/admin/faq/:id
+func (h *FAQHandler) HandleDeleteFAQEntry(c *gin.Context) {
+	id, ok := parseFAQEntryID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.faqService.DeleteEntry(c.Request.Context(), id); err != nil {
+		respondError(c, err, "delete_error", "Failed to delete FAQ entry")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "FAQ entry deleted successfully",
+		"id":      id,
+	})
+}
+
+// parseFAQEntryID parses the :id path parameter
+func parseFAQEntryID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid FAQ entry ID",
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type FeatureFlagHandler struct {
+	featureFlagService *services.FeatureFlagService
+}
+
+func NewFeatureFlagHandler(featureFlagService *services.FeatureFlagService) *FeatureFlagHandler {
+	return &FeatureFlagHandler{featureFlagService: featureFlagService}
+}
+
+// HandleGetFlags handles GET /api/admin/flags
+func (h *FeatureFlagHandler) HandleGetFlags(c *gin.Context) {
+	flags := h.featureFlagService.GetFlags()
+
+	c.JSON(http.StatusOK, gin.H{
+		"flags": flags,
+		"count": len(flags),
+	})
+}
+
+// HandleUpdateFlags handles PUT /api/admin/flags
+func (h *FeatureFlagHandler) HandleUpdateFlags(c *gin.Context) {
+	var updates map[string]models.FeatureFlagUpdate
+
+	if !bindJSON(c, &updates) {
+		return
+	}
+
+	flags, err := h.featureFlagService.UpdateFlags(c.Request.Context(), updates)
+	if err != nil {
+		respondError(c, err, "update_error", "Failed to update feature flags")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"flags": flags,
+		"count": len(flags),
+	})
+}
@@ -1,41 +1,38 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/ai-support-assistant/backend/internal/models"
 	"github.com/ai-support-assistant/backend/internal/services"
 	"github.com/gin-gonic/gin"
-	"github.com/sirupsen/logrus"
 )
 
 type FeedbackHandler struct {
 	feedbackService *services.FeedbackService
+	segmentService  *services.SegmentService
 }
 
-func NewFeedbackHandler(feedbackService *services.FeedbackService) *FeedbackHandler {
-	return &FeedbackHandler{feedbackService: feedbackService}
+func NewFeedbackHandler(feedbackService *services.FeedbackService, segmentService *services.SegmentService) *FeedbackHandler {
+	return &FeedbackHandler{feedbackService: feedbackService, segmentService: segmentService}
 }
 
 // HandleSubmitFeedback handles POST /api/feedback
 func (h *FeedbackHandler) HandleSubmitFeedback(c *gin.Context) {
 	var req models.FeedbackRequest
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "invalid_request",
-			Message: err.Error(),
-		})
+	if !bindJSON(c, &req) {
 		return
 	}
 
 	if err := h.feedbackService.SubmitFeedback(c.Request.Context(), req); err != nil {
-		logrus.WithError(err).Error("Failed to submit feedback")
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "submission_error",
-			Message: "Failed to submit feedback. Please try again.",
-		})
+		respondError(c, err, "submission_error", "Failed to submit feedback. Please try again.")
 		return
 	}
 
@@ -55,11 +52,7 @@ func (h *FeedbackHandler) HandleGetFeedback(c *gin.Context) {
 
 	feedbacks, err := h.feedbackService.GetRecentFeedback(c.Request.Context(), limit)
 	if err != nil {
-		logrus.WithError(err).Error("Failed to get feedback")
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "fetch_error",
-			Message: "Failed to fetch feedback",
-		})
+		respondError(c, err, "fetch_error", "Failed to fetch feedback")
 		return
 	}
 
@@ -69,17 +62,110 @@ func (h *FeedbackHandler) HandleGetFeedback(c *gin.Context) {
 	})
 }
 
+// HandleGetRetrievalFeedback handles GET /api/admin/retrieval-feedback
+func (h *FeedbackHandler) HandleGetRetrievalFeedback(c *gin.Context) {
+	limitStr := c.DefaultQuery("limit", "50")
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+
+	records, err := h.feedbackService.GetRetrievalFeedback(c.Request.Context(), c.Query("status"), limit)
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch retrieval feedback")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"retrieval_feedback": records,
+		"count":              len(records),
+	})
+}
+
 // HandleGetFeedbackStats handles GET /api/feedback/stats
 func (h *FeedbackHandler) HandleGetFeedbackStats(c *gin.Context) {
-	stats, err := h.feedbackService.GetFeedbackStats(c.Request.Context())
+	includeImported := c.Query("exclude_imported") != "true"
+
+	segment, ok := querySegment(c, h.segmentService)
+	if !ok {
+		return
+	}
+
+	stats, err := h.feedbackService.GetFeedbackStats(c.Request.Context(), includeImported, segment)
 	if err != nil {
-		logrus.WithError(err).Error("Failed to get feedback stats")
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "fetch_error",
-			Message: "Failed to fetch feedback stats",
-		})
+		respondError(c, err, "fetch_error", "Failed to fetch feedback stats")
 		return
 	}
 
 	c.JSON(http.StatusOK, stats)
 }
+
+// HandleImportFeedback handles POST /api/feedback/import. The survey
+// responses are read from the "file" part of a multipart form, as CSV or JSON
+// by its extension, or from a text/csv or application/json body.
+func (h *FeedbackHandler) HandleImportFeedback(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.feedbackService.MaxImportBytes())
+
+	body, format, err := importSource(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_file",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	result, err := h.feedbackService.ImportFeedback(c.Request.Context(), body, format)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondBindingError(c, http.StatusRequestEntityTooLarge, "request_too_large",
+				fmt.Sprintf("Imports must not exceed %d bytes", tooLarge.Limit), nil)
+			return
+		}
+		respondError(c, err, "import_error", "Failed to import feedback")
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// importSource finds the import in the request without reading it into
+// memory, returning it with its format
+func importSource(c *gin.Context) (io.Reader, string, error) {
+	switch c.ContentType() {
+	case "text/csv":
+		return c.Request.Body, services.FeedbackImportCSV, nil
+	case "application/json":
+		return c.Request.Body, services.FeedbackImportJSON, nil
+	case "multipart/form-data":
+	default:
+		return nil, "", fmt.Errorf("send a multipart file, or a text/csv or application/json body")
+	}
+
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid multipart form: %v", err)
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, "", fmt.Errorf("no file provided")
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid multipart form: %v", err)
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+
+		switch strings.ToLower(filepath.Ext(part.FileName())) {
+		case ".csv":
+			return part, services.FeedbackImportCSV, nil
+		case ".json":
+			return part, services.FeedbackImportJSON, nil
+		default:
+			return nil, "", fmt.Errorf("the file must be .csv or .json")
+		}
+	}
+}
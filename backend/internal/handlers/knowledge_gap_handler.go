@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type KnowledgeGapHandler struct {
+	knowledgeGapService *services.KnowledgeGapService
+}
+
+func NewKnowledgeGapHandler(knowledgeGapService *services.KnowledgeGapService) *KnowledgeGapHandler {
+	return &KnowledgeGapHandler{knowledgeGapService: knowledgeGapService}
+}
+
+// HandleGenerateKnowledgeGaps handles POST /api/admin/knowledge-gaps/generate.
+// The report is generated in the background; it returns 202 with the report
+// to poll.
+func (h *KnowledgeGapHandler) HandleGenerateKnowledgeGaps(c *gin.Context) {
+	report, err := h.knowledgeGapService.Generate(c.Request.Context())
+	if err != nil {
+		respondError(c, err, "knowledge_gap_error", "Failed to generate knowledge gap report")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, report)
+}
+
+// HandleGetKnowledgeGapReports handles GET /api/admin/knowledge-gaps
+func (h *KnowledgeGapHandler) HandleGetKnowledgeGapReports(c *gin.Context) {
+	reports, err := h.knowledgeGapService.GetReports(c.Request.Context())
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch knowledge gap reports")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reports": reports,
+		"count":   len(reports),
+	})
+}
+
+// HandleGetKnowledgeGapReport handles GET /api/admin/knowledge-gaps/:id
+func (h *KnowledgeGapHandler) HandleGetKnowledgeGapReport(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid knowledge gap report ID",
+		})
+		return
+	}
+
+	report, err := h.knowledgeGapService.GetReport(c.Request.Context(), uint(id))
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch knowledge gap report")
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
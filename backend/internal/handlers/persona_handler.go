@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type PersonaHandler struct {
+	personaService *services.PersonaService
+	queryService   *services.QueryService
+}
+
+func NewPersonaHandler(personaService *services.PersonaService, queryService *services.QueryService) *PersonaHandler {
+	return &PersonaHandler{personaService: personaService, queryService: queryService}
+}
+
+// HandleCreatePersona handles POST /api/admin/personas
+func (h *PersonaHandler) HandleCreatePersona(c *gin.Context) {
+	var req models.PersonaRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	persona, err := h.personaService.CreatePersona(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err, "create_error", "Failed to create persona")
+		return
+	}
+
+	c.JSON(http.StatusCreated, persona)
+}
+
+// HandleGetPersonas handles GET /api/admin/personas
+func (h *PersonaHandler) HandleGetPersonas(c *gin.Context) {
+	personas, err := h.personaService.GetPersonas(c.Request.Context())
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch personas")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"personas": personas,
+		"count":    len(personas),
+	})
+}
+
+// HandleGetPersona handles GET /api/admin/personas/:id
+func (h *PersonaHandler) HandleGetPersona(c *gin.Context) {
+	id, ok := parsePersonaID(c)
+	if !ok {
+		return
+	}
+
+	persona, err := h.personaService.GetPersonaByID(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch persona")
+		return
+	}
+
+	c.JSON(http.StatusOK, persona)
+}
+
+// HandleUpdatePersona handles PUT /api/admin/personas/:id
+func (h *PersonaHandler) HandleUpdatePersona(c *gin.Context) {
+	id, ok := parsePersonaID(c)
+	if !ok {
+		return
+	}
+
+	var req models.PersonaRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	persona, err := h.personaService.UpdatePersona(c.Request.Context(), id, req)
+	if err != nil {
+		respondError(c, err, "update_error", "Failed to update persona")
+		return
+	}
+
+	c.JSON(http.StatusOK, persona)
+}
+
+// HandleDeletePersona handles DELETE /api/admin/personas/:id
+func (h *PersonaHandler) HandleDeletePersona(c *gin.Context) {
+	id, ok := parsePersonaID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.personaService.DeletePersona(c.Request.Context(), id); err != nil {
+		respondError(c, err, "delete_error", "Failed to delete persona")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Persona deleted successfully",
+		"id":      id,
+	})
+}
+
+// HandlePreviewPersona handles POST /api/admin/personas/:id/preview
+func (h *PersonaHandler) HandlePreviewPersona(c *gin.Context) {
+	id, ok := parsePersonaID(c)
+	if !ok {
+		return
+	}
+
+	var req models.PersonaPreviewRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	preview, err := h.queryService.PreviewPersona(c.Request.Context(), id, req.Query)
+	if err != nil {
+		respondError(c, err, "preview_error", "Failed to preview persona")
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
+
+// parsePersonaID parses the :id path parameter
+func parsePersonaID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid persona ID",
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type PromptTemplateHandler struct {
+	promptService *services.PromptService
+}
+
+func NewPromptTemplateHandler(promptService *services.PromptService) *PromptTemplateHandler {
+	return &PromptTemplateHandler{promptService: promptService}
+}
+
+// HandleCreatePromptTemplate handles POST /api/admin/prompts
+func (h *PromptTemplateHandler) HandleCreatePromptTemplate(c *gin.Context) {
+	req, ok := bindPromptTemplateRequest(c)
+	if !ok {
+		return
+	}
+
+	template, err := h.promptService.CreatePromptTemplate(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err, "create_error", "Failed to create prompt template")
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+// HandleGetPromptTemplates handles GET /api/admin/prompts
+func (h *PromptTemplateHandler) HandleGetPromptTemplates(c *gin.Context) {
+	templates, err := h.promptService.GetPromptTemplates(c.Request.Context())
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch prompt templates")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"prompts": templates,
+		"count":   len(templates),
+	})
+}
+
+// HandleGetPromptTemplate handles GET /api/admin/prompts/:id
+func (h *PromptTemplateHandler) HandleGetPromptTemplate(c *gin.Context) {
+	id, ok := parsePromptTemplateID(c)
+	if !ok {
+		return
+	}
+
+	template, err := h.promptService.GetPromptTemplateByID(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch prompt template")
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// HandleUpdatePromptTemplate handles PUT /api/admin/prompts/:id
+func (h *PromptTemplateHandler) HandleUpdatePromptTemplate(c *gin.Context) {
+	id, ok := parsePromptTemplateID(c)
+	if !ok {
+		return
+	}
+
+	req, ok := bindPromptTemplateRequest(c)
+	if !ok {
+		return
+	}
+
+	template, err := h.promptService.UpdatePromptTemplate(c.Request.Context(), id, req)
+	if err != nil {
+		respondError(c, err, "update_error", "Failed to update prompt template")
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// HandleDeletePromptTemplate handles DELETE /api/admin/prompts/:id
+func (h *PromptTemplateHandler) HandleDeletePromptTemplate(c *gin.Context) {
+	id, ok := parsePromptTemplateID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.promptService.DeletePromptTemplate(c.Request.Context(), id); err != nil {
+		respondError(c, err, "delete_error", "Failed to delete prompt template")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Prompt template deleted successfully",
+		"id":      id,
+	})
+}
+
+// HandleActivatePromptTemplate handles POST /api/admin/prompts/:id/activate
+func (h *PromptTemplateHandler) HandleActivatePromptTemplate(c *gin.Context) {
+	id, ok := parsePromptTemplateID(c)
+	if !ok {
+		return
+	}
+
+	template, err := h.promptService.ActivatePromptTemplate(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err, "update_error", "Failed to activate prompt template")
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// bindPromptTemplateRequest binds and validates a prompt template request body
+func bindPromptTemplateRequest(c *gin.Context) (models.PromptTemplateRequest, bool) {
+	var req models.PromptTemplateRequest
+
+	if !bindJSON(c, &req) {
+		return req, false
+	}
+
+	return req, true
+}
+
+// parsePromptTemplateID parses the :id path parameter
+func parsePromptTemplateID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid prompt template ID",
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
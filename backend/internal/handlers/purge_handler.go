@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type PurgeHandler struct {
+	purgeService *services.PurgeService
+}
+
+func NewPurgeHandler(purgeService *services.PurgeService) *PurgeHandler {
+	return &PurgeHandler{purgeService: purgeService}
+}
+
+// HandlePurge handles POST /api/admin/purge. A dry run returns the rows the
+// filter matches; confirming it by dry_run_id starts the purge and returns
+// 202 with the job to poll.
+func (h *PurgeHandler) HandlePurge(c *gin.Context) {
+	var req models.PurgeRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	job, err := h.purgeService.Purge(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err, "purge_error", "Failed to purge chat history")
+		return
+	}
+
+	status := http.StatusOK
+	if job.Status != services.PurgeDryRun {
+		status = http.StatusAccepted
+	}
+	c.JSON(status, job)
+}
+
+// HandleGetPurgeJob handles GET /api/admin/purge/:id
+func (h *PurgeHandler) HandleGetPurgeJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid purge job ID",
+		})
+		return
+	}
+
+	job, err := h.purgeService.GetPurgeJob(c.Request.Context(), uint(id))
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch purge job")
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
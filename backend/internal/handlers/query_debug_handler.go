@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type QueryDebugHandler struct {
+	queryDebugService *services.QueryDebugService
+}
+
+func NewQueryDebugHandler(queryDebugService *services.QueryDebugService) *QueryDebugHandler {
+	return &QueryDebugHandler{queryDebugService: queryDebugService}
+}
+
+// HandleGetQueryDebug handles GET /api/admin/queries/:id/debug, the debug
+// bundle for one query as JSON or a markdown download
+func (h *QueryDebugHandler) HandleGetQueryDebug(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid query ID",
+		})
+		return
+	}
+
+	format := c.DefaultQuery("format", services.DebugFormatJSON)
+	if !services.IsValidDebugFormat(format) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_format",
+			Message: "Invalid format, must be one of json, markdown",
+		})
+		return
+	}
+
+	bundle, err := h.queryDebugService.GetBundle(c.Request.Context(), uint(id), format)
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to build query debug bundle")
+		return
+	}
+
+	if format == services.DebugFormatJSON {
+		c.JSON(http.StatusOK, bundle)
+		return
+	}
+
+	c.Header("Content-Type", "text/markdown; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="query-%d-debug.md"`, id))
+	c.Status(http.StatusOK)
+
+	if err := services.WriteDebugBundle(c.Writer, bundle); err != nil {
+		logrus.WithError(err).WithField("query_id", id).Warn("Failed to write query debug bundle")
+	}
+}
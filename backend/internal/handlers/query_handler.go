@@ -1,8 +1,15 @@
 package handlers
 
 import (
+	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/ai-support-assistant/backend/internal/middleware"
 	"github.com/ai-support-assistant/backend/internal/models"
 	"github.com/ai-support-assistant/backend/internal/services"
 	"github.com/gin-gonic/gin"
@@ -10,34 +17,276 @@ import (
 )
 
 type QueryHandler struct {
-	queryService *services.QueryService
+	queryService    *services.QueryService
+	queryJobService *services.QueryJobService
+	quotaService    *services.QuotaService
 }
 
-func NewQueryHandler(queryService *services.QueryService) *QueryHandler {
-	return &QueryHandler{queryService: queryService}
+func NewQueryHandler(queryService *services.QueryService, queryJobService *services.QueryJobService, quotaService *services.QuotaService) *QueryHandler {
+	return &QueryHandler{queryService: queryService, queryJobService: queryJobService, quotaService: quotaService}
 }
 
-// HandleQuery handles POST /api/query
+// A multipart query may exceed MAX_REQUEST_BODY_BYTES by the attachment
+// limits plus queryFormOverhead for the request field and part headers. Up
+// to queryFormMemory of it is held in memory, the rest in temporary files.
+const (
+	queryFormOverhead = 1 << 20
+	queryFormMemory   = 32 << 20
+)
+
+// HandleQuery handles POST /api/query. The body is a JSON query, or a
+// multipart form with the JSON query in the request field and images as
+// attachments file parts.
 func (h *QueryHandler) HandleQuery(c *gin.Context) {
 	var req models.QueryRequest
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "invalid_request",
-			Message: err.Error(),
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		if !h.bindQueryForm(c, &req) {
+			return
+		}
+	} else if !bindJSON(c, &req) {
+		return
+	}
+
+	// Queries with images cost more to answer than text alone
+	if len(req.Attachments) > 0 && !middleware.ChargeRateLimit(c, h.queryService.AttachmentRateLimitWeight()-1) {
+		return
+	}
+
+	fillFromRequest(c, &req)
+
+	// Long-running queries can be processed in the background and polled
+	if req.Async {
+		job, err := h.queryJobService.Submit(c.Request.Context(), req)
+		if err != nil {
+			respondError(c, err, "submission_error", "Failed to submit query job. Please try again.")
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"job_id":     job.ID,
+			"status":     job.Status,
+			"status_url": "/api/query/jobs/" + job.ID,
 		})
 		return
 	}
 
 	response, err := h.queryService.ProcessQuery(c.Request.Context(), req)
 	if err != nil {
-		logrus.WithError(err).Error("Failed to process query")
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "processing_error",
-			Message: "Failed to process query. Please try again.",
+		respondError(c, err, "processing_error", "Failed to process query. Please try again.")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// HandleStreamQuery handles POST /api/query/stream. It takes the JSON query
+// of POST /api/query and answers with server-sent events: a token event for
+// each fragment of the answer as it is generated, then a response event with
+// the final answer, which replaces the streamed text. Errors before the
+// first event get the usual JSON error response; later ones an error event.
+func (h *QueryHandler) HandleStreamQuery(c *gin.Context) {
+	var req models.QueryRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if plan := h.quotaService.Plan(c.GetString("plan")); !plan.Streaming {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "streaming_not_allowed",
+			Message: fmt.Sprintf("The %s plan doesn't include streaming; send the query to /api/query instead", plan.Name),
 		})
 		return
 	}
 
+	fillFromRequest(c, &req)
+
+	started := false
+	start := func() {
+		if started {
+			return
+		}
+		started = true
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+	}
+
+	ctx := c.Request.Context()
+	response, err := h.queryService.ProcessQueryStream(ctx, req, func(token string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		start()
+		c.SSEvent("token", gin.H{"token": token})
+		c.Writer.Flush()
+		return nil
+	})
+	if err != nil && !started {
+		respondError(c, err, "processing_error", "Failed to process query. Please try again.")
+		return
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			logrus.WithError(err).WithField("session_id", req.SessionID).Error("Streamed query failed")
+			c.SSEvent("error", models.ErrorResponse{
+				Error:     "processing_error",
+				Message:   "Failed to process query. Please try again.",
+				Timestamp: time.Now().UTC(),
+			})
+		}
+		return
+	}
+
+	start()
+	c.SSEvent("response", response)
+	c.Writer.Flush()
+}
+
+// fillFromRequest sets the parts of a query that come from the request
+// rather than its body: the caller's identity, origin and user agent
+func fillFromRequest(c *gin.Context, req *models.QueryRequest) {
+	if strings.Contains(strings.ToLower(c.GetHeader("Cache-Control")), "no-cache") {
+		req.NoCacheHeader = true
+	}
+
+	req.VisitorID = c.GetString("visitor_id")
+	req.TokenAudience = c.GetString("audience")
+	req.TokenUserID = c.GetString("user_id")
+	req.TokenPlan = c.GetString("plan")
+	req.Origin = c.GetHeader("Origin")
+
+	// The user agent always comes from the header so clients can't spoof it in the body
+	if req.Metadata != nil {
+		req.Metadata.UserAgent = ""
+	}
+	if userAgent := c.Request.UserAgent(); userAgent != "" {
+		if req.Metadata == nil {
+			req.Metadata = &models.QueryMetadata{}
+		}
+		req.Metadata.UserAgent = userAgent
+	}
+}
+
+// bindQueryForm reads a multipart query. Attachments are only taken from
+// file parts, not base64 in the request field.
+func (h *QueryHandler) bindQueryForm(c *gin.Context, req *models.QueryRequest) bool {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.queryService.MaxAttachmentBytes()+queryFormOverhead)
+	if err := c.Request.ParseMultipartForm(queryFormMemory); err != nil {
+		respondDecodeError(c, err)
+		return false
+	}
+
+	if !bindJSONField(c, "request", req) {
+		return false
+	}
+	if len(req.Attachments) > 0 {
+		respondBindingError(c, http.StatusBadRequest, "invalid_request", "Multipart queries must send attachments as file parts", nil)
+		return false
+	}
+
+	for _, header := range c.Request.MultipartForm.File["attachments"] {
+		data, err := readFormFile(header)
+		if err != nil {
+			respondBindingError(c, http.StatusBadRequest, "invalid_file", "Failed to read attachment "+header.Filename, nil)
+			return false
+		}
+		req.Attachments = append(req.Attachments, models.QueryAttachment{
+			FileName: header.Filename,
+			Data:     data,
+		})
+	}
+	return true
+}
+
+// readFormFile reads an uploaded file part
+func readFormFile(header *multipart.FileHeader) ([]byte, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// HandleRegenerateQuery handles POST /api/query/:query_id/regenerate
+func (h *QueryHandler) HandleRegenerateQuery(c *gin.Context) {
+	queryID, err := strconv.ParseUint(c.Param("query_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid query ID",
+		})
+		return
+	}
+
+	var req models.RegenerateRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	response, err := h.queryService.RegenerateQuery(c.Request.Context(), uint(queryID), req.SessionID, c.GetString("visitor_id"), c.GetString("audience"), c.GetString("user_id"), c.GetString("plan"))
+	if err != nil {
+		respondError(c, err, "processing_error", "Failed to regenerate answer. Please try again.")
+		return
+	}
+
 	c.JSON(http.StatusOK, response)
 }
+
+// HandleGetQueryJob handles GET /api/query/jobs/:id
+func (h *QueryHandler) HandleGetQueryJob(c *gin.Context) {
+	job, err := h.queryJobService.GetJob(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch query job")
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// HandleGetSessionSuggestions handles GET /api/sessions/:session_id/suggestions
+func (h *QueryHandler) HandleGetSessionSuggestions(c *gin.Context) {
+	sessionID := c.Param("session_id")
+
+	suggestions, err := h.queryService.GetSessionSuggestions(c.Request.Context(), sessionID)
+	if err != nil {
+		respondError(c, err, "suggestions_error", "Failed to generate suggestions")
+		return
+	}
+	if suggestions == nil {
+		suggestions = []string{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id":  sessionID,
+		"suggestions": suggestions,
+	})
+}
+
+// HandleStartCacheWarmup handles POST /api/admin/cache/warmup
+func (h *QueryHandler) HandleStartCacheWarmup(c *gin.Context) {
+	run, err := h.queryService.StartWarmup(services.WarmupTriggerAdmin)
+	if err != nil {
+		respondError(c, err, "warmup_error", "Failed to start cache warm-up")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, run)
+}
+
+// HandleGetCacheWarmup handles GET /api/admin/cache/warmup
+func (h *QueryHandler) HandleGetCacheWarmup(c *gin.Context) {
+	run := h.queryService.LastWarmup()
+	if run == nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "not_found",
+			Message: "No cache warm-up has run",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
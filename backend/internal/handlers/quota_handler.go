@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type QuotaHandler struct {
+	quotaService *services.QuotaService
+}
+
+func NewQuotaHandler(quotaService *services.QuotaService) *QuotaHandler {
+	return &QuotaHandler{quotaService: quotaService}
+}
+
+// HandleGetUsage handles GET /api/usage
+func (h *QuotaHandler) HandleGetUsage(c *gin.Context) {
+	usage, err := h.quotaService.GetUsage(c.Request.Context(), c.GetString("user_id"), c.GetString("visitor_id"), c.GetString("plan"))
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch usage")
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
+
+// HandleGetQuotaOverrides handles GET /api/admin/quota-overrides
+func (h *QuotaHandler) HandleGetQuotaOverrides(c *gin.Context) {
+	overrides, err := h.quotaService.GetOverrides(c.Request.Context())
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch quota overrides")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"overrides": overrides,
+		"count":     len(overrides),
+	})
+}
+
+// HandleSetQuotaOverride handles PUT /api/admin/quota-overrides/:user_id
+func (h *QuotaHandler) HandleSetQuotaOverride(c *gin.Context) {
+	var req models.QuotaOverrideRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	override, err := h.quotaService.SetOverride(c.Request.Context(), c.Param("user_id"), req)
+	if err != nil {
+		respondError(c, err, "update_error", "Failed to set quota override")
+		return
+	}
+
+	c.JSON(http.StatusOK, override)
+}
+
+// HandleDeleteQuotaOverride handles DELETE /api/admin/quota-overrides/:user_id
+func (h *QuotaHandler) HandleDeleteQuotaOverride(c *gin.Context) {
+	userID := c.Param("user_id")
+	if err := h.quotaService.DeleteOverride(c.Request.Context(), userID); err != nil {
+		respondError(c, err, "delete_error", "Failed to delete quota override")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Quota override deleted successfully",
+		"user_id": userID,
+	})
+}
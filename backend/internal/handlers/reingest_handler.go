@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// maxReingestItemPageSize caps the documents returned per page
+const maxReingestItemPageSize = 200
+
+type ReingestHandler struct {
+	reingestService *services.ReingestService
+}
+
+func NewReingestHandler(reingestService *services.ReingestService) *ReingestHandler {
+	return &ReingestHandler{reingestService: reingestService}
+}
+
+// HandleReingestAll handles POST /api/admin/docs/reingest-all and returns 202
+// with the job to poll. The body, filtering the documents by collection or
+// status, may be left out.
+func (h *ReingestHandler) HandleReingestAll(c *gin.Context) {
+	var req models.ReingestRequest
+	if !bindOptionalJSON(c, &req) {
+		return
+	}
+
+	job, err := h.reingestService.StartReingest(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err, "reingest_error", "Failed to start re-ingestion")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// HandleGetReingestJob handles GET /api/admin/docs/reingest-jobs/:id
+func (h *ReingestHandler) HandleGetReingestJob(c *gin.Context) {
+	id, ok := reingestJobID(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+	if limit > maxReingestItemPageSize {
+		limit = maxReingestItemPageSize
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	status := c.Query("status")
+	job, items, total, err := h.reingestService.GetReingestJob(c.Request.Context(), id, status, limit, offset)
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch re-ingestion job")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"job":       job,
+		"documents": items,
+		"count":     len(items),
+		"total":     total,
+		"limit":     limit,
+		"offset":    offset,
+	})
+}
+
+// HandleCancelReingestJob handles DELETE /api/admin/docs/reingest-jobs/:id
+func (h *ReingestHandler) HandleCancelReingestJob(c *gin.Context) {
+	id, ok := reingestJobID(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.reingestService.CancelReingestJob(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err, "cancel_error", "Failed to cancel re-ingestion job")
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// reingestJobID parses the job ID path parameter, responding 400 if it's invalid
+func reingestJobID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid re-ingestion job ID",
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
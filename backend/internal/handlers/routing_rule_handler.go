@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type RoutingRuleHandler struct {
+	routingService *services.ModelRoutingService
+}
+
+func NewRoutingRuleHandler(routingService *services.ModelRoutingService) *RoutingRuleHandler {
+	return &RoutingRuleHandler{routingService: routingService}
+}
+
+// HandleCreateRoutingRule handles POST /api/admin/routing-rules
+func (h *RoutingRuleHandler) HandleCreateRoutingRule(c *gin.Context) {
+	var req models.ModelRoutingRuleRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	rule, err := h.routingService.CreateRoutingRule(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err, "create_error", "Failed to create routing rule")
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// HandleGetRoutingRules handles GET /api/admin/routing-rules
+func (h *RoutingRuleHandler) HandleGetRoutingRules(c *gin.Context) {
+	rules, err := h.routingService.GetRoutingRules(c.Request.Context())
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch routing rules")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"rules": rules,
+		"count": len(rules),
+	})
+}
+
+// HandleGetRoutingRule handles GET /api/admin/routing-rules/:id
+func (h *RoutingRuleHandler) HandleGetRoutingRule(c *gin.Context) {
+	id, ok := parseRoutingRuleID(c)
+	if !ok {
+		return
+	}
+
+	rule, err := h.routingService.GetRoutingRuleByID(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch routing rule")
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// HandleUpdateRoutingRule handles PUT /api/admin/routing-rules/:id
+func (h *RoutingRuleHandler) HandleUpdateRoutingRule(c *gin.Context) {
+	id, ok := parseRoutingRuleID(c)
+	if !ok {
+		return
+	}
+
+	var req models.ModelRoutingRuleRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	rule, err := h.routingService.UpdateRoutingRule(c.Request.Context(), id, req)
+	if err != nil {
+		respondError(c, err, "update_error", "Failed to update routing rule")
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// HandleDeleteRoutingRule handles DELETE /api/admin/routing-rules/:id
+func (h *RoutingRuleHandler) HandleDeleteRoutingRule(c *gin.Context) {
+	id, ok := parseRoutingRuleID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.routingService.DeleteRoutingRule(c.Request.Context(), id); err != nil {
+		respondError(c, err, "delete_error", "Failed to delete routing rule")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Routing rule deleted successfully",
+		"id":      id,
+	})
+}
+
+// HandleTestRoutingRules handles POST /api/admin/routing-rules/test
+func (h *RoutingRuleHandler) HandleTestRoutingRules(c *gin.Context) {
+	var req models.RoutingTestRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	c.JSON(http.StatusOK, h.routingService.TestRoute(c.Request.Context(), req))
+}
+
+// parseRoutingRuleID parses the :id path parameter
+func parseRoutingRuleID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid routing rule ID",
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// maxRuntimeBuckets caps the rate limit buckets returned by the runtime endpoint
+const maxRuntimeBuckets = 100
+
+type RuntimeHandler struct {
+	runtimeService *services.RuntimeService
+}
+
+func NewRuntimeHandler(runtimeService *services.RuntimeService) *RuntimeHandler {
+	return &RuntimeHandler{runtimeService: runtimeService}
+}
+
+// HandleGetRuntime handles GET /api/admin/runtime
+func (h *RuntimeHandler) HandleGetRuntime(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+	if limit > maxRuntimeBuckets {
+		limit = maxRuntimeBuckets
+	}
+
+	c.JSON(http.StatusOK, h.runtimeService.GetState(c.Request.Context(), limit))
+}
+
+// HandleResetRateLimit handles POST /api/admin/runtime/reset-ratelimit/:key
+func (h *RuntimeHandler) HandleResetRateLimit(c *gin.Context) {
+	bucket, err := h.runtimeService.ResetRateLimit(c.Request.Context(), c.Param("key"))
+	if err != nil {
+		respondError(c, err, "reset_error", "Failed to reset rate limit")
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"key":   bucket.Key,
+		"count": bucket.Count,
+	}).Info("Rate limit bucket reset")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Rate limit reset successfully",
+		"bucket":  bucket,
+	})
+}
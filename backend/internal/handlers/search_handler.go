@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// maxSearchPageSize caps the number of search results returned per page
+const maxSearchPageSize = 100
+
+type SearchHandler struct {
+	searchService *services.SearchService
+}
+
+func NewSearchHandler(searchService *services.SearchService) *SearchHandler {
+	return &SearchHandler{searchService: searchService}
+}
+
+// HandleSearch handles GET /api/search
+func (h *SearchHandler) HandleSearch(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+	if limit > maxSearchPageSize {
+		limit = maxSearchPageSize
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	filter := services.SearchFilter{
+		Query:     c.Query("q"),
+		SessionID: c.Query("session_id"),
+		Limit:     limit,
+		Offset:    offset,
+	}
+
+	var ok bool
+	if filter.From, ok = parseTimeQuery(c, "from"); !ok {
+		return
+	}
+	if filter.To, ok = parseTimeQuery(c, "to"); !ok {
+		return
+	}
+
+	results, total, err := h.searchService.SearchConversations(c.Request.Context(), filter, c.GetString("user_id"))
+	if err != nil {
+		respondError(c, err, "search_error", "Failed to search conversations")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": results,
+		"count":   len(results),
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
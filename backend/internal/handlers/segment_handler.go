@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type SegmentHandler struct {
+	segmentService *services.SegmentService
+}
+
+func NewSegmentHandler(segmentService *services.SegmentService) *SegmentHandler {
+	return &SegmentHandler{segmentService: segmentService}
+}
+
+// HandleCreateSegment handles POST /api/admin/segments
+func (h *SegmentHandler) HandleCreateSegment(c *gin.Context) {
+	var req models.AnalyticsSegmentRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	segment, err := h.segmentService.CreateSegment(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err, "create_error", "Failed to create segment")
+		return
+	}
+
+	c.JSON(http.StatusCreated, segment)
+}
+
+// HandleGetSegments handles GET /api/admin/segments
+func (h *SegmentHandler) HandleGetSegments(c *gin.Context) {
+	segments, err := h.segmentService.GetSegments(c.Request.Context())
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch segments")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"segments": segments,
+		"count":    len(segments),
+	})
+}
+
+// HandleGetSegment handles GET /api/admin/segments/:id
+func (h *SegmentHandler) HandleGetSegment(c *gin.Context) {
+	id, ok := parseSegmentID(c)
+	if !ok {
+		return
+	}
+
+	segment, err := h.segmentService.GetSegmentByID(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch segment")
+		return
+	}
+
+	c.JSON(http.StatusOK, segment)
+}
+
+// HandleUpdateSegment handles PUT /api/admin/segments/:id
+func (h *SegmentHandler) HandleUpdateSegment(c *gin.Context) {
+	id, ok := parseSegmentID(c)
+	if !ok {
+		return
+	}
+
+	var req models.AnalyticsSegmentRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	segment, err := h.segmentService.UpdateSegment(c.Request.Context(), id, req)
+	if err != nil {
+		respondError(c, err, "update_error", "Failed to update segment")
+		return
+	}
+
+	c.JSON(http.StatusOK, segment)
+}
+
+// HandleDeleteSegment handles DELETE /api/admin/segments/:id
+func (h *SegmentHandler) HandleDeleteSegment(c *gin.Context) {
+	id, ok := parseSegmentID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.segmentService.DeleteSegment(c.Request.Context(), id); err != nil {
+		respondError(c, err, "delete_error", "Failed to delete segment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Segment deleted successfully",
+		"id":      id,
+	})
+}
+
+// parseSegmentID parses the :id path parameter
+func parseSegmentID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid segment ID",
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// querySegment loads the segment named by the segment_id query parameter,
+// responding with an error if it's invalid or doesn't exist. The segment is
+// nil when the parameter is absent.
+func querySegment(c *gin.Context, segmentService *services.SegmentService) (*models.AnalyticsSegment, bool) {
+	value := c.Query("segment_id")
+	if value == "" {
+		return nil, true
+	}
+
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid segment ID",
+		})
+		return nil, false
+	}
+
+	segment, err := segmentService.GetSegmentByID(c.Request.Context(), uint(id))
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch segment")
+		return nil, false
+	}
+	return segment, true
+}
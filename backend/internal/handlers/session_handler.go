@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type SessionHandler struct {
+	sessionService *services.SessionService
+}
+
+func NewSessionHandler(sessionService *services.SessionService) *SessionHandler {
+	return &SessionHandler{sessionService: sessionService}
+}
+
+// HandleSetSessionOutcome handles POST /api/sessions/:session_id/outcome
+func (h *SessionHandler) HandleSetSessionOutcome(c *gin.Context) {
+	var req models.SessionOutcomeRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	session, err := h.sessionService.SetOutcome(c.Request.Context(), c.Param("session_id"), req.Outcome)
+	if err != nil {
+		respondError(c, err, "update_error", "Failed to record session outcome")
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+// HandleClaimSession handles POST /api/sessions/:session_id/claim
+func (h *SessionHandler) HandleClaimSession(c *gin.Context) {
+	var req models.SessionClaimRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	claim, err := h.sessionService.ClaimSession(c.Request.Context(), c.Param("session_id"), c.GetString("user_id"), req.ClaimToken)
+	if err != nil {
+		respondError(c, err, "claim_error", "Failed to claim session")
+		return
+	}
+
+	c.JSON(http.StatusOK, claim)
+}
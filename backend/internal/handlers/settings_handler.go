@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type SettingsHandler struct {
+	settingsService *services.SettingsService
+}
+
+func NewSettingsHandler(settingsService *services.SettingsService) *SettingsHandler {
+	return &SettingsHandler{settingsService: settingsService}
+}
+
+// HandleGetSettings handles GET /api/admin/settings
+func (h *SettingsHandler) HandleGetSettings(c *gin.Context) {
+	settings := h.settingsService.GetSettings(c.Request.Context())
+
+	c.JSON(http.StatusOK, gin.H{
+		"settings": settings,
+		"count":    len(settings),
+	})
+}
+
+// HandleUpdateSettings handles PUT /api/admin/settings
+func (h *SettingsHandler) HandleUpdateSettings(c *gin.Context) {
+	var updates map[string]string
+
+	if !bindJSON(c, &updates) {
+		return
+	}
+
+	settings, err := h.settingsService.UpdateSettings(c.Request.Context(), updates)
+	if err != nil {
+		respondError(c, err, "update_error", "Failed to update settings")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"settings": settings,
+		"count":    len(settings),
+	})
+}
+
+// HandleDeleteSetting handles DELETE /api/admin/settings/:key
+func (h *SettingsHandler) HandleDeleteSetting(c *gin.Context) {
+	key := c.Param("key")
+
+	if err := h.settingsService.DeleteSetting(c.Request.Context(), key); err != nil {
+		respondError(c, err, "delete_error", "Failed to delete setting")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Setting reset to environment value",
+		"key":     key,
+	})
+}
+
+// HandleGetLogLevel handles GET /api/admin/log-level
+func (h *SettingsHandler) HandleGetLogLevel(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"level": h.settingsService.LogLevel(),
+	})
+}
+
+// HandleUpdateLogLevel handles PUT /api/admin/log-level. The level is stored
+// as the log_level setting so it applies to every instance.
+func (h *SettingsHandler) HandleUpdateLogLevel(c *gin.Context) {
+	var req models.LogLevelRequest
+
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	_, err := h.settingsService.UpdateSettings(c.Request.Context(), map[string]string{services.SettingLogLevel: req.Level})
+	if err != nil {
+		respondError(c, err, "update_error", "Failed to update log level")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"level": h.settingsService.LogLevel(),
+	})
+}
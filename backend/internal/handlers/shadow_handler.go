@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// maxShadowComparisonPageSize caps the comparisons returned per page
+const maxShadowComparisonPageSize = 200
+
+type ShadowTestHandler struct {
+	shadowTestService *services.ShadowTestService
+}
+
+func NewShadowTestHandler(shadowTestService *services.ShadowTestService) *ShadowTestHandler {
+	return &ShadowTestHandler{shadowTestService: shadowTestService}
+}
+
+// HandleStartShadowTest handles POST /api/admin/shadow-test and returns 202
+// with the run to poll
+func (h *ShadowTestHandler) HandleStartShadowTest(c *gin.Context) {
+	var req models.ShadowTestRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	run, err := h.shadowTestService.StartShadowTest(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err, "shadow_test_error", "Failed to start shadow test")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, run)
+}
+
+// HandleGetShadowTest handles GET /api/admin/shadow-test/:id
+func (h *ShadowTestHandler) HandleGetShadowTest(c *gin.Context) {
+	id, ok := shadowRunID(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+	if limit > maxShadowComparisonPageSize {
+		limit = maxShadowComparisonPageSize
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	run, comparisons, total, err := h.shadowTestService.GetShadowRun(c.Request.Context(), id, limit, offset)
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch shadow test")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"run":         run,
+		"comparisons": comparisons,
+		"count":       len(comparisons),
+		"total":       total,
+		"limit":       limit,
+		"offset":      offset,
+	})
+}
+
+// HandleCancelShadowTest handles DELETE /api/admin/shadow-test/:id
+func (h *ShadowTestHandler) HandleCancelShadowTest(c *gin.Context) {
+	id, ok := shadowRunID(c)
+	if !ok {
+		return
+	}
+
+	run, err := h.shadowTestService.CancelShadowTest(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err, "cancel_error", "Failed to cancel shadow test")
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
+// shadowRunID parses the run ID path parameter, responding 400 if it's invalid
+func shadowRunID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid shadow run ID",
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
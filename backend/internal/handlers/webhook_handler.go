@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookHandler struct {
+	webhookService *services.WebhookService
+}
+
+func NewWebhookHandler(webhookService *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{webhookService: webhookService}
+}
+
+// HandleCreateWebhook handles POST /api/admin/webhooks
+func (h *WebhookHandler) HandleCreateWebhook(c *gin.Context) {
+	req, ok := bindWebhookRequest(c)
+	if !ok {
+		return
+	}
+
+	sub, err := h.webhookService.CreateSubscription(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err, "create_error", "Failed to create webhook")
+		return
+	}
+
+	c.JSON(http.StatusCreated, sub)
+}
+
+// HandleGetWebhooks handles GET /api/admin/webhooks
+func (h *WebhookHandler) HandleGetWebhooks(c *gin.Context) {
+	subs, err := h.webhookService.GetSubscriptions(c.Request.Context())
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch webhooks")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"webhooks": subs,
+		"count":    len(subs),
+	})
+}
+
+// HandleGetWebhook handles GET /api/admin/webhooks/:id
+func (h *WebhookHandler) HandleGetWebhook(c *gin.Context) {
+	id, ok := parseWebhookID(c)
+	if !ok {
+		return
+	}
+
+	sub, err := h.webhookService.GetSubscriptionByID(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch webhook")
+		return
+	}
+
+	c.JSON(http.StatusOK, sub)
+}
+
+// HandleUpdateWebhook handles PUT /api/admin/webhooks/:id
+func (h *WebhookHandler) HandleUpdateWebhook(c *gin.Context) {
+	id, ok := parseWebhookID(c)
+	if !ok {
+		return
+	}
+
+	req, ok := bindWebhookRequest(c)
+	if !ok {
+		return
+	}
+
+	sub, err := h.webhookService.UpdateSubscription(c.Request.Context(), id, req)
+	if err != nil {
+		respondError(c, err, "update_error", "Failed to update webhook")
+		return
+	}
+
+	c.JSON(http.StatusOK, sub)
+}
+
+// HandleDeleteWebhook handles DELETE /api/admin/webhooks/:id
+func (h *WebhookHandler) HandleDeleteWebhook(c *gin.Context) {
+	id, ok := parseWebhookID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.webhookService.DeleteSubscription(c.Request.Context(), id); err != nil {
+		respondError(c, err, "delete_error", "Failed to delete webhook")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Webhook deleted successfully",
+		"id":      id,
+	})
+}
+
+// HandleGetWebhookDeliveries handles GET /api/admin/webhooks/:id/deliveries
+func (h *WebhookHandler) HandleGetWebhookDeliveries(c *gin.Context) {
+	id, ok := parseWebhookID(c)
+	if !ok {
+		return
+	}
+
+	limitStr := c.DefaultQuery("limit", "50")
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+
+	deliveries, err := h.webhookService.GetDeliveries(c.Request.Context(), id, limit)
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch webhook deliveries")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deliveries": deliveries,
+		"count":      len(deliveries),
+	})
+}
+
+// bindWebhookRequest binds and validates a webhook request body
+func bindWebhookRequest(c *gin.Context) (models.WebhookSubscriptionRequest, bool) {
+	var req models.WebhookSubscriptionRequest
+
+	if !bindJSON(c, &req) {
+		return req, false
+	}
+
+	return req, true
+}
+
+// parseWebhookID parses the :id path parameter
+func parseWebhookID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid webhook ID",
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
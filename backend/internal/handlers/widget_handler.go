@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type WidgetHandler struct {
+	widgetService *services.WidgetService
+}
+
+func NewWidgetHandler(widgetService *services.WidgetService) *WidgetHandler {
+	return &WidgetHandler{widgetService: widgetService}
+}
+
+// HandleCreateWidgetConfig handles POST /api/admin/widgets
+func (h *WidgetHandler) HandleCreateWidgetConfig(c *gin.Context) {
+	req, ok := bindWidgetConfigRequest(c)
+	if !ok {
+		return
+	}
+
+	config, err := h.widgetService.CreateWidgetConfig(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err, "create_error", "Failed to create widget config")
+		return
+	}
+
+	c.JSON(http.StatusCreated, config)
+}
+
+// HandleGetWidgetConfigs handles GET /api/admin/widgets
+func (h *WidgetHandler) HandleGetWidgetConfigs(c *gin.Context) {
+	configs, err := h.widgetService.GetWidgetConfigs(c.Request.Context())
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch widget configs")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"widgets": configs,
+		"count":   len(configs),
+	})
+}
+
+// HandleGetWidgetConfig handles GET /api/admin/widgets/:id
+func (h *WidgetHandler) HandleGetWidgetConfig(c *gin.Context) {
+	id, ok := parseWidgetConfigID(c)
+	if !ok {
+		return
+	}
+
+	config, err := h.widgetService.GetWidgetConfigByID(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err, "fetch_error", "Failed to fetch widget config")
+		return
+	}
+
+	c.JSON(http.StatusOK, config)
+}
+
+// HandleUpdateWidgetConfig handles PUT /api/admin/widgets/:id
+func (h *WidgetHandler) HandleUpdateWidgetConfig(c *gin.Context) {
+	id, ok := parseWidgetConfigID(c)
+	if !ok {
+		return
+	}
+
+	req, ok := bindWidgetConfigRequest(c)
+	if !ok {
+		return
+	}
+
+	config, err := h.widgetService.UpdateWidgetConfig(c.Request.Context(), id, req)
+	if err != nil {
+		respondError(c, err, "update_error", "Failed to update widget config")
+		return
+	}
+
+	c.JSON(http.StatusOK, config)
+}
+
+// HandleDeleteWidgetConfig handles DELETE /api/admin/widgets/:id
+func (h *WidgetHandler) HandleDeleteWidgetConfig(c *gin.Context) {
+	id, ok := parseWidgetConfigID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.widgetService.DeleteWidgetConfig(c.Request.Context(), id); err != nil {
+		respondError(c, err, "delete_error", "Failed to delete widget config")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Widget config deleted successfully",
+		"id":      id,
+	})
+}
+
+// bindWidgetConfigRequest binds and validates a widget config request body
+func bindWidgetConfigRequest(c *gin.Context) (models.WidgetConfigRequest, bool) {
+	var req models.WidgetConfigRequest
+
+	if !bindJSON(c, &req) {
+		return req, false
+	}
+
+	return req, true
+}
+
+// parseWidgetConfigID parses the :id path parameter
+func parseWidgetConfigID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_id",
+			Message: "Invalid widget config ID",
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// HandleGetPublicWidgetConfig handles GET /api/widget/config
+func (h *WidgetHandler) HandleGetPublicWidgetConfig(c *gin.Context) {
+	origin := c.GetHeader("Origin")
+	if origin == "" {
+		origin = services.DefaultWidgetOrigin
+	}
+
+	c.JSON(http.StatusOK, h.widgetService.GetPublicConfig(c.Request.Context(), origin))
+}
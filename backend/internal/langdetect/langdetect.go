@@ -0,0 +1,149 @@
+package langdetect
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Undetermined is returned when the text is too short or ambiguous to classify
+const Undetermined = "und"
+
+const (
+	// minLetters is the least amount of text we attempt to classify
+	minLetters = 12
+	// minMargin is the per-trigram score lead the best language needs over the runner-up
+	minMargin = 0.3
+	// unseenLogProb is the log probability assigned to trigrams missing from a profile
+	unseenLogProb = -12.0
+)
+
+// profile maps trigrams to log probabilities for one language
+type profile map[string]float64
+
+var profiles = buildProfiles()
+
+// Languages returns the languages the detector can identify
+func Languages() []string {
+	languages := make([]string, 0, len(profiles))
+	for lang := range profiles {
+		languages = append(languages, lang)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// Detect returns the ISO 639-1 code of the text's language, or Undetermined
+// when the text is too short or no language is a clear winner
+func Detect(text string) string {
+	lang, _ := DetectWithConfidence(text)
+	return lang
+}
+
+// DetectWithConfidence returns the detected language and the per-trigram
+// score margin over the runner-up
+func DetectWithConfidence(text string) (string, float64) {
+	normalized := normalize(text)
+	if countLetters(normalized) < minLetters {
+		return Undetermined, 0
+	}
+
+	grams := trigrams(normalized)
+	if len(grams) == 0 {
+		return Undetermined, 0
+	}
+
+	best, second := "", ""
+	bestScore, secondScore := math.Inf(-1), math.Inf(-1)
+	for _, lang := range Languages() {
+		score := 0.0
+		for _, gram := range grams {
+			if logProb, ok := profiles[lang][gram]; ok {
+				score += logProb
+			} else {
+				score += unseenLogProb
+			}
+		}
+
+		switch {
+		case score > bestScore:
+			second, secondScore = best, bestScore
+			best, bestScore = lang, score
+		case score > secondScore:
+			second, secondScore = lang, score
+		}
+	}
+
+	if second == "" {
+		return best, 1
+	}
+
+	margin := (bestScore - secondScore) / float64(len(grams))
+	if margin < minMargin {
+		return Undetermined, margin
+	}
+
+	return best, margin
+}
+
+// buildProfiles computes smoothed trigram log probabilities from the samples
+func buildProfiles() map[string]profile {
+	built := make(map[string]profile, len(samples))
+
+	for lang, sample := range samples {
+		counts := make(map[string]int)
+		total := 0
+		for _, gram := range trigrams(normalize(sample)) {
+			counts[gram]++
+			total++
+		}
+
+		p := make(profile, len(counts))
+		for gram, count := range counts {
+			p[gram] = math.Log(float64(count) / float64(total))
+		}
+		built[lang] = p
+	}
+
+	return built
+}
+
+// normalize lowercases text and replaces everything but letters with single spaces
+func normalize(text string) string {
+	var b strings.Builder
+	space := true
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) {
+			b.WriteRune(r)
+			space = false
+		} else if !space {
+			b.WriteRune(' ')
+			space = true
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// trigrams returns the character trigrams of each word, padded with spaces
+func trigrams(normalized string) []string {
+	var grams []string
+	for _, word := range strings.Fields(normalized) {
+		runes := []rune(" " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			grams = append(grams, string(runes[i:i+3]))
+		}
+	}
+	return grams
+}
+
+// countLetters returns the number of letters in the text
+func countLetters(text string) int {
+	count := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			count++
+		}
+	}
+	return count
+}
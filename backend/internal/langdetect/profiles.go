@@ -0,0 +1,45 @@
+package langdetect
+
+// samples are representative customer-support texts used to build the trigram
+// profiles at startup. They favor the vocabulary users actually send us.
+var samples = map[string]string{
+	"en": `How do I reset my password? I forgot my password and I cannot log in to my account.
+Where can I find my invoice for last month? The payment was charged twice on my credit card.
+I would like to cancel my subscription and get a refund. What is the difference between the basic plan and the premium plan?
+My order has not arrived yet, can you tell me when it will be delivered? The tracking number does not work.
+How can I change the email address on my profile? Please help me update my billing information.
+The application keeps crashing when I open the settings page. Is there a way to export my data?
+Thank you for your help, this is very useful. Why was my account suspended? I need to speak with someone from support.
+Can I upgrade my plan at any time and what happens to the features that are included? How long does shipping take to my country?
+What are your opening hours and how can I contact customer service by phone or chat?`,
+
+	"es": `¿Cómo puedo restablecer mi contraseña? Olvidé mi contraseña y no puedo iniciar sesión en mi cuenta.
+¿Dónde puedo encontrar la factura del mes pasado? El pago se cobró dos veces en mi tarjeta de crédito.
+Quisiera cancelar mi suscripción y obtener un reembolso. ¿Cuál es la diferencia entre el plan básico y el plan premium?
+Mi pedido todavía no ha llegado, ¿me pueden decir cuándo será entregado? El número de seguimiento no funciona.
+¿Cómo puedo cambiar la dirección de correo electrónico de mi perfil? Por favor ayúdenme a actualizar mis datos de facturación.
+La aplicación se cierra cuando abro la página de configuración. ¿Hay alguna forma de exportar mis datos?
+Gracias por su ayuda, esto es muy útil. ¿Por qué se suspendió mi cuenta? Necesito hablar con alguien del servicio de atención.
+¿Puedo mejorar mi plan en cualquier momento y qué pasa con las funciones incluidas? ¿Cuánto tarda el envío a mi país?
+¿Cuál es el horario de atención y cómo puedo contactar con el servicio al cliente por teléfono o por chat?`,
+
+	"de": `Wie kann ich mein Passwort zurücksetzen? Ich habe mein Passwort vergessen und kann mich nicht in mein Konto einloggen.
+Wo finde ich die Rechnung für den letzten Monat? Die Zahlung wurde zweimal von meiner Kreditkarte abgebucht.
+Ich möchte mein Abonnement kündigen und eine Rückerstattung erhalten. Was ist der Unterschied zwischen dem Basistarif und dem Premiumtarif?
+Meine Bestellung ist noch nicht angekommen, können Sie mir sagen, wann sie geliefert wird? Die Sendungsnummer funktioniert nicht.
+Wie kann ich die E-Mail-Adresse in meinem Profil ändern? Bitte helfen Sie mir, meine Zahlungsinformationen zu aktualisieren.
+Die Anwendung stürzt ständig ab, wenn ich die Einstellungen öffne. Gibt es eine Möglichkeit, meine Daten zu exportieren?
+Vielen Dank für Ihre Hilfe, das ist sehr nützlich. Warum wurde mein Konto gesperrt? Ich muss mit jemandem vom Kundendienst sprechen.
+Kann ich meinen Tarif jederzeit wechseln und was passiert mit den enthaltenen Funktionen? Wie lange dauert der Versand in mein Land?
+Wann sind Ihre Öffnungszeiten und wie kann ich den Kundenservice telefonisch oder per Chat erreichen?`,
+
+	"fr": `Comment puis-je réinitialiser mon mot de passe ? J'ai oublié mon mot de passe et je ne peux pas me connecter à mon compte.
+Où puis-je trouver la facture du mois dernier ? Le paiement a été débité deux fois sur ma carte de crédit.
+Je voudrais annuler mon abonnement et obtenir un remboursement. Quelle est la différence entre le forfait de base et le forfait premium ?
+Ma commande n'est pas encore arrivée, pouvez-vous me dire quand elle sera livrée ? Le numéro de suivi ne fonctionne pas.
+Comment puis-je changer l'adresse e-mail de mon profil ? Merci de m'aider à mettre à jour mes informations de facturation.
+L'application plante chaque fois que j'ouvre la page des paramètres. Est-il possible d'exporter mes données ?
+Merci pour votre aide, c'est très utile. Pourquoi mon compte a-t-il été suspendu ? Je dois parler avec quelqu'un du service client.
+Puis-je changer de forfait à tout moment et que deviennent les fonctionnalités incluses ? Combien de temps prend la livraison dans mon pays ?
+Quels sont vos horaires d'ouverture et comment puis-je contacter le service client par téléphone ou par chat ?`,
+}
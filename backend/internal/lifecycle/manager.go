@@ -0,0 +1,170 @@
+package lifecycle
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// task is a registered background goroutine
+type task struct {
+	name    string
+	fields  logrus.Fields
+	started time.Time
+}
+
+// Manager tracks background goroutines so shutdown can drain them instead of
+// killing them mid-flight. Long-running loops watch Context and exit when
+// shutdown begins; tasks started with Go are waited for up to the drain timeout.
+type Manager struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// taskCtx is passed to tasks and only cancelled once the drain timeout expires
+	taskCtx    context.Context
+	taskCancel context.CancelFunc
+
+	mu       sync.Mutex
+	draining bool
+	stopping bool
+	nextID   uint64
+	tasks    map[uint64]task
+	wg       sync.WaitGroup
+}
+
+// NewManager creates a lifecycle manager
+func NewManager() *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	taskCtx, taskCancel := context.WithCancel(context.Background())
+
+	return &Manager{
+		ctx:        ctx,
+		cancel:     cancel,
+		taskCtx:    taskCtx,
+		taskCancel: taskCancel,
+		tasks:      make(map[uint64]task),
+	}
+}
+
+// Context is cancelled as soon as shutdown begins
+func (m *Manager) Context() context.Context {
+	return m.ctx
+}
+
+// Drain marks the instance as draining ahead of shutdown: readiness fails so
+// load balancers stop routing to it, while requests are still served
+func (m *Manager) Drain() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.draining = true
+}
+
+// Draining returns true once draining or shutdown has begun
+func (m *Manager) Draining() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.draining || m.stopping
+}
+
+// Stopping returns true once shutdown has begun
+func (m *Manager) Stopping() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stopping
+}
+
+// Go runs fn in a tracked goroutine. The fields identify the task in the logs
+// if it has to be abandoned. Returns false without running fn once shutdown has begun.
+func (m *Manager) Go(name string, fields logrus.Fields, fn func(ctx context.Context)) bool {
+	m.mu.Lock()
+	if m.stopping {
+		m.mu.Unlock()
+		logrus.WithFields(fields).WithField("task", name).Warn("Shutting down, background task not started")
+		return false
+	}
+	m.nextID++
+	id := m.nextID
+	m.tasks[id] = task{name: name, fields: fields, started: time.Now()}
+	m.wg.Add(1)
+	m.mu.Unlock()
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				logrus.WithFields(fields).WithField("task", name).Errorf("Background task panicked: %v", r)
+			}
+
+			m.mu.Lock()
+			delete(m.tasks, id)
+			m.mu.Unlock()
+			m.wg.Done()
+		}()
+
+		fn(m.taskCtx)
+	}()
+
+	return true
+}
+
+// Running returns the number of tasks in flight, by name
+func (m *Manager) Running() map[string]int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	running := make(map[string]int)
+	for _, t := range m.tasks {
+		running[t.name]++
+	}
+	return running
+}
+
+// Shutdown stops accepting new tasks, signals loops to exit and waits up to
+// timeout for in-flight tasks. Tasks still running after the timeout have their
+// context cancelled and are logged as abandoned. Returns the number abandoned.
+func (m *Manager) Shutdown(timeout time.Duration) int {
+	m.mu.Lock()
+	m.stopping = true
+	inFlight := len(m.tasks)
+	m.mu.Unlock()
+
+	m.cancel()
+
+	logrus.WithFields(logrus.Fields{
+		"in_flight": inFlight,
+		"timeout":   timeout,
+	}).Info("Draining background tasks")
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		m.taskCancel()
+		logrus.Info("Background tasks drained")
+		return 0
+	case <-time.After(timeout):
+	}
+
+	m.mu.Lock()
+	abandoned := make([]task, 0, len(m.tasks))
+	for _, t := range m.tasks {
+		abandoned = append(abandoned, t)
+	}
+	m.mu.Unlock()
+
+	for _, t := range abandoned {
+		logrus.WithFields(t.fields).WithFields(logrus.Fields{
+			"task":       t.name,
+			"started_at": t.started.UTC(),
+			"running":    time.Since(t.started).Round(time.Millisecond),
+		}).Warn("Abandoned background task at shutdown")
+	}
+
+	m.taskCancel()
+	return len(abandoned)
+}
@@ -0,0 +1,383 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message roles
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// retryDelay is the first wait before retrying a failed call; it doubles on
+// each attempt unless the API asks for longer with Retry-After
+const retryDelay = 500 * time.Millisecond
+
+// maxRetryDelay caps the wait between attempts
+const maxRetryDelay = 10 * time.Second
+
+// Message is a chat message
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Request is a chat completion request; an empty Model uses the client's
+type Request struct {
+	Model       string
+	Messages    []Message
+	Temperature *float64
+	MaxTokens   int
+}
+
+// Response is a completed answer and the tokens it used
+type Response struct {
+	Content          string
+	Model            string
+	FinishReason     string
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+
+	// TimeToFirstToken is set for streamed answers
+	TimeToFirstToken time.Duration
+}
+
+// APIError is an error status returned by the API
+type APIError struct {
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration // from a 429's or 503's Retry-After, when given
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("LLM API returned status %d: %s", e.StatusCode, e.Message)
+}
+
+// retryable reports whether the call may succeed if made again
+func (e *APIError) retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// Client calls an OpenAI compatible chat completions API. Each attempt is
+// bounded by the timeout, and calls failing on rate limits, server errors
+// or the network are retried up to maxRetries times with a doubling delay.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	timeout    time.Duration
+	maxRetries int
+	httpClient *http.Client
+}
+
+// NewClient creates a client for the API at baseURL, e.g.
+// https://api.openai.com/v1, answering with model unless a request names
+// another
+func NewClient(baseURL, apiKey, model string, timeout time.Duration, maxRetries int) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		model:      model,
+		timeout:    timeout,
+		maxRetries: maxRetries,
+		// Attempts are bounded by their context rather than the client, so
+		// a streamed answer isn't cut off mid-way by a fixed timeout
+		httpClient: &http.Client{},
+	}
+}
+
+// Model returns the model used when a request doesn't name one
+func (c *Client) Model() string {
+	return c.model
+}
+
+// chatRequest mirrors the chat completions request format
+type chatRequest struct {
+	Model         string         `json:"model"`
+	Messages      []Message      `json:"messages"`
+	Temperature   *float64       `json:"temperature,omitempty"`
+	MaxTokens     int            `json:"max_tokens,omitempty"`
+	Stream        bool           `json:"stream,omitempty"`
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// chatResponse mirrors the chat completions response format
+type chatResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message      Message `json:"message"`
+		FinishReason string  `json:"finish_reason"`
+	} `json:"choices"`
+	Usage usage `json:"usage"`
+}
+
+// chatChunk mirrors a streamed chat completions event
+type chatChunk struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *usage `json:"usage"`
+}
+
+// Complete returns the model's answer to a chat
+func (c *Client) Complete(ctx context.Context, req Request) (*Response, error) {
+	var result *Response
+	err := c.withRetries(ctx, func(ctx context.Context) (bool, error) {
+		resp, err := c.post(ctx, c.chatRequest(req, false))
+		if err != nil {
+			return false, err
+		}
+		defer resp.Body.Close()
+
+		var chat chatResponse
+		if err := json.NewDecoder(resp.Body).Decode(&chat); err != nil {
+			return false, fmt.Errorf("failed to decode response: %w", err)
+		}
+		if len(chat.Choices) == 0 {
+			return false, errors.New("LLM API returned no choices")
+		}
+
+		result = &Response{
+			Content:          chat.Choices[0].Message.Content,
+			Model:            chat.Model,
+			FinishReason:     chat.Choices[0].FinishReason,
+			PromptTokens:     chat.Usage.PromptTokens,
+			CompletionTokens: chat.Usage.CompletionTokens,
+			TotalTokens:      chat.Usage.TotalTokens,
+		}
+		return false, nil
+	})
+	return result, err
+}
+
+// Stream calls onToken with each fragment of the answer as it is generated
+// and returns the whole answer. An error from onToken ends the stream.
+// Attempts are only retried until the first fragment was delivered, so
+// onToken never sees an answer twice.
+func (c *Client) Stream(ctx context.Context, req Request, onToken func(string) error) (*Response, error) {
+	var result *Response
+	err := c.withRetries(ctx, func(ctx context.Context) (bool, error) {
+		start := time.Now()
+		resp, err := c.post(ctx, c.chatRequest(req, true))
+		if err != nil {
+			return false, err
+		}
+		defer resp.Body.Close()
+
+		result = &Response{Model: c.modelFor(req)}
+		var content strings.Builder
+		delivered := false
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data:")
+			if !ok {
+				continue
+			}
+			data = strings.TrimSpace(data)
+			if data == "[DONE]" {
+				result.Content = content.String()
+				return delivered, nil
+			}
+
+			var chunk chatChunk
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				return delivered, fmt.Errorf("failed to decode stream event: %w", err)
+			}
+			if chunk.Model != "" {
+				result.Model = chunk.Model
+			}
+			if chunk.Usage != nil {
+				result.PromptTokens = chunk.Usage.PromptTokens
+				result.CompletionTokens = chunk.Usage.CompletionTokens
+				result.TotalTokens = chunk.Usage.TotalTokens
+			}
+			for _, choice := range chunk.Choices {
+				if choice.FinishReason != nil {
+					result.FinishReason = *choice.FinishReason
+				}
+				if choice.Delta.Content == "" {
+					continue
+				}
+				if !delivered {
+					result.TimeToFirstToken = time.Since(start)
+				}
+				delivered = true
+				content.WriteString(choice.Delta.Content)
+				if err := onToken(choice.Delta.Content); err != nil {
+					return true, err
+				}
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return delivered, fmt.Errorf("failed to read stream: %w", err)
+		}
+		return delivered, io.ErrUnexpectedEOF
+	})
+	return result, err
+}
+
+// withRetries makes an attempt, and retries it while it fails in a way
+// worth retrying and nothing was delivered to the caller yet
+func (c *Client) withRetries(ctx context.Context, attempt func(ctx context.Context) (bool, error)) error {
+	for n := 0; ; n++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, c.timeout)
+		delivered, err := attempt(attemptCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		wait, retry := c.retryAfter(err, n)
+		if delivered || !retry || n >= c.maxRetries {
+			return err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= wait {
+			return err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryAfter decides whether a failed attempt is retried and after how long
+func (c *Client) retryAfter(err error, attempt int) (time.Duration, bool) {
+	wait := min(retryDelay<<attempt, maxRetryDelay)
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if !apiErr.retryable() {
+			return 0, false
+		}
+		if apiErr.RetryAfter > 0 {
+			wait = min(apiErr.RetryAfter, maxRetryDelay)
+		}
+		return wait, true
+	}
+	// Timeouts and network failures are worth another attempt
+	return wait, true
+}
+
+func (c *Client) chatRequest(req Request, stream bool) chatRequest {
+	chat := chatRequest{
+		Model:       c.modelFor(req),
+		Messages:    req.Messages,
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+		Stream:      stream,
+	}
+	if stream {
+		chat.StreamOptions = &streamOptions{IncludeUsage: true}
+	}
+	return chat
+}
+
+func (c *Client) modelFor(req Request) string {
+	if req.Model != "" {
+		return req.Model
+	}
+	return c.model
+}
+
+// post sends a chat completions request, returning the response if it
+// succeeded and an APIError otherwise
+func (c *Client) post(ctx context.Context, chat chatRequest) (*http.Response, error) {
+	jsonData, err := json.Marshal(chat)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	if chat.Stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call LLM API: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, &APIError{
+			StatusCode: resp.StatusCode,
+			Message:    errorMessage(body),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+	return resp, nil
+}
+
+// errorMessage extracts the message from an API error body, falling back to
+// the body itself
+func errorMessage(body []byte) string {
+	var apiErr struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &apiErr); err == nil && apiErr.Error.Message != "" {
+		return apiErr.Error.Message
+	}
+	return string(body)
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP
+// date. It returns 0 when the header is missing or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
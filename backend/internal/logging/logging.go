@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Log output formats
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// IsValidFormat returns true if format is a supported output format
+func IsValidFormat(format string) bool {
+	return format == FormatJSON || format == FormatText
+}
+
+// ParseLevel parses a logrus level name such as debug, info or warn
+func ParseLevel(level string) (logrus.Level, error) {
+	parsed, err := logrus.ParseLevel(level)
+	if err != nil {
+		return 0, fmt.Errorf("%q: must be one of trace, debug, info, warn, error", level)
+	}
+	return parsed, nil
+}
+
+// Configure sets the standard logger's level and format and installs hook
+func Configure(level, format string, hook logrus.Hook) error {
+	parsed, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	if !IsValidFormat(format) {
+		return fmt.Errorf("%q: must be one of json, text", format)
+	}
+
+	if format == FormatText {
+		logrus.SetFormatter(&logrus.TextFormatter{
+			FullTimestamp: true,
+		})
+	} else {
+		logrus.SetFormatter(&logrus.JSONFormatter{
+			TimestampFormat: time.RFC3339,
+		})
+	}
+	logrus.SetOutput(os.Stdout)
+	logrus.SetLevel(parsed)
+
+	if hook != nil {
+		logrus.AddHook(hook)
+	}
+	return nil
+}
+
+// SetLevel changes the standard logger's level, returning whether it changed
+func SetLevel(level string) (bool, error) {
+	parsed, err := ParseLevel(level)
+	if err != nil {
+		return false, err
+	}
+	if logrus.GetLevel() == parsed {
+		return false, nil
+	}
+	logrus.SetLevel(parsed)
+	return true, nil
+}
@@ -0,0 +1,143 @@
+package logging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Redacted replaces scrubbed values
+const Redacted = "[REDACTED]"
+
+// QueryField is the log field carrying user query text. Outside debug level
+// it is replaced with query_hash and query_length.
+const QueryField = "query"
+
+// sensitiveFields are log fields whose values are always redacted
+var sensitiveFields = map[string]bool{
+	"authorization": true,
+	"cookie":        true,
+	"password":      true,
+	"secret":        true,
+	"token":         true,
+	"access_token":  true,
+	"refresh_token": true,
+	"api_key":       true,
+	"jwt":           true,
+}
+
+// scrubRule replaces matches of a pattern with a replacement
+type scrubRule struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// defaultScrubRules match credentials and common PII. Card numbers are
+// matched before phone numbers so their digits aren't partially replaced.
+var defaultScrubRules = []scrubRule{
+	{regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/=-]+`), "Bearer " + Redacted},
+	{regexp.MustCompile(`(?i)\b(token|key|secret|password|api_key|signature|credential)=[^&\s]+`), "${1}=" + Redacted},
+	{regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), "[EMAIL]"},
+	{regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`), "[SSN]"},
+	{regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), "[CARD]"},
+	{regexp.MustCompile(`\+\d{1,3}[ .-]?\(?\d{1,4}\)?(?:[ .-]?\d{2,4}){2,4}\b`), "[PHONE]"},
+	{regexp.MustCompile(`\(?\b\d{3}\)?[ .-]\d{3}[ .-]\d{4}\b`), "[PHONE]"},
+}
+
+// secretScrubRules are the default rules matching credentials rather than PII
+var secretScrubRules = defaultScrubRules[:2]
+
+// ScrubSecrets redacts bearer tokens and secret query parameters in s
+func ScrubSecrets(s string) string {
+	for _, rule := range secretScrubRules {
+		s = rule.pattern.ReplaceAllString(s, rule.replacement)
+	}
+	return s
+}
+
+// IsSensitiveField reports whether values of a field are always redacted
+func IsSensitiveField(name string) bool {
+	return sensitiveFields[strings.ToLower(name)]
+}
+
+// ScrubHook removes credentials and personal data from log entries before
+// they are written
+type ScrubHook struct {
+	rules          []scrubRule
+	maxQueryLength int
+}
+
+// NewScrubHook creates a hook applying the default rules plus extraPatterns.
+// Query text logged at debug level is truncated to maxQueryLength
+// characters; zero leaves it whole.
+func NewScrubHook(extraPatterns []string, maxQueryLength int) (*ScrubHook, error) {
+	rules := append([]scrubRule{}, defaultScrubRules...)
+	for _, pattern := range extraPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scrub pattern %q: %w", pattern, err)
+		}
+		rules = append(rules, scrubRule{pattern: re, replacement: Redacted})
+	}
+	return &ScrubHook{rules: rules, maxQueryLength: maxQueryLength}, nil
+}
+
+// Levels applies the hook to every level
+func (h *ScrubHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire scrubs the entry's message and fields in place
+func (h *ScrubHook) Fire(entry *logrus.Entry) error {
+	entry.Message = h.Scrub(entry.Message)
+
+	for key, value := range entry.Data {
+		if sensitiveFields[strings.ToLower(key)] {
+			entry.Data[key] = Redacted
+			continue
+		}
+
+		switch v := value.(type) {
+		case string:
+			if key == QueryField {
+				h.scrubQuery(entry, v)
+				continue
+			}
+			entry.Data[key] = h.Scrub(v)
+		case error:
+			entry.Data[key] = h.Scrub(v.Error())
+		}
+	}
+
+	return nil
+}
+
+// scrubQuery logs query text in full only when debug logging is enabled;
+// otherwise it is replaced by a hash that still correlates repeats
+func (h *ScrubHook) scrubQuery(entry *logrus.Entry, query string) {
+	if entry.Logger != nil && entry.Logger.IsLevelEnabled(logrus.DebugLevel) {
+		text := h.Scrub(query)
+		if h.maxQueryLength > 0 && len([]rune(text)) > h.maxQueryLength {
+			text = string([]rune(text)[:h.maxQueryLength]) + "…"
+		}
+		entry.Data[QueryField] = text
+		return
+	}
+
+	sum := sha256.Sum256([]byte(query))
+	delete(entry.Data, QueryField)
+	entry.Data["query_hash"] = hex.EncodeToString(sum[:])[:16]
+	entry.Data["query_length"] = len([]rune(query))
+}
+
+// Scrub applies every rule to s, redacting credentials and personal data
+func (h *ScrubHook) Scrub(s string) string {
+	for _, rule := range h.rules {
+		s = rule.pattern.ReplaceAllString(s, rule.replacement)
+	}
+	return s
+}
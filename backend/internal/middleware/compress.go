@@ -0,0 +1,265 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Response content encodings, in order of preference
+const (
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+// noCompressionKey marks a request whose response must not be compressed
+const noCompressionKey = "no_compression"
+
+// compressibleTypes are the media types worth compressing. Event streams are
+// text but are left alone so each event reaches the client when flushed.
+var compressibleTypes = []string{"application/json", "text/csv", "text/plain", "text/html"}
+
+// Compress encodes responses with gzip or deflate, whichever the request's
+// Accept-Encoding prefers, once they reach minSize bytes. Only JSON, CSV and
+// text bodies are compressed; routes opt out with NoCompression. Responses
+// are held back until minSize is reached or the handler returns or flushes,
+// so small ones go out untouched. A level of 0 disables compression.
+func Compress(level, minSize int) gin.HandlerFunc {
+	if level == 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	pools := map[string]*sync.Pool{
+		EncodingGzip: {New: func() interface{} {
+			w, _ := gzip.NewWriterLevel(io.Discard, level)
+			return w
+		}},
+		EncodingDeflate: {New: func() interface{} {
+			w, _ := zlib.NewWriterLevel(io.Discard, level)
+			return w
+		}},
+	}
+
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		writer := &compressWriter{
+			ResponseWriter: c.Writer,
+			c:              c,
+			encoding:       acceptedEncoding(c.GetHeader("Accept-Encoding")),
+			minSize:        minSize,
+			pools:          pools,
+		}
+		c.Writer = writer
+		defer func() { c.Writer = writer.ResponseWriter }()
+
+		c.Next()
+		writer.finish()
+	}
+}
+
+// NoCompression opts a route out of Compress, e.g. for streams whose events
+// must not wait in the encoder, or /metrics, which Prometheus encodes itself
+func NoCompression() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(noCompressionKey, true)
+		c.Next()
+	}
+}
+
+// acceptedEncoding picks the preferred encoding the client accepts, or ""
+// if it accepts neither
+func acceptedEncoding(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != EncodingGzip && name != EncodingDeflate {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 {
+			continue
+		}
+		// Ties go to gzip, the better supported of the two
+		if q > bestQ || (q == bestQ && name == EncodingGzip) {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// isCompressible reports whether a Content-Type is on the allow-list
+func isCompressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range compressibleTypes {
+		if mediaType == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// compressWriter holds back the start of a response until it knows whether
+// to compress it, then either encodes the rest or passes it through
+type compressWriter struct {
+	gin.ResponseWriter
+	c        *gin.Context
+	encoding string
+	minSize  int
+	pools    map[string]*sync.Pool
+
+	buf       bytes.Buffer
+	headerNow bool // WriteHeaderNow was called while holding back
+	decided   bool
+	encoder   responseEncoder
+}
+
+// responseEncoder is what gzip and zlib writers have in common
+type responseEncoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+func (w *compressWriter) WriteHeaderNow() {
+	if w.decided {
+		w.ResponseWriter.WriteHeaderNow()
+		return
+	}
+	w.headerNow = true
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.decided {
+		return w.write(b)
+	}
+	w.buf.Write(b)
+	if w.buf.Len() >= w.minSize {
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressWriter) Written() bool {
+	if w.decided {
+		return w.ResponseWriter.Written()
+	}
+	return w.headerNow || w.buf.Len() > 0
+}
+
+// Flush sends what was held back, compressed or not, then everything written
+// so far
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if err := w.decide(); err != nil {
+			return
+		}
+	}
+	if w.encoder != nil {
+		if err := w.encoder.Flush(); err != nil {
+			return
+		}
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) write(b []byte) (int, error) {
+	if w.encoder != nil {
+		return w.encoder.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// decide settles whether the response is compressed, setting its headers
+// accordingly, and sends what was held back
+func (w *compressWriter) decide() error {
+	w.decided = true
+	header := w.Header()
+	if w.c.GetBool(noCompressionKey) {
+		return w.release()
+	}
+	header.Add("Vary", "Accept-Encoding")
+
+	status := w.Status()
+	if w.encoding == "" || w.buf.Len() < w.minSize || w.buf.Len() == 0 ||
+		status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified ||
+		header.Get("Content-Encoding") != "" || !isCompressible(header.Get("Content-Type")) {
+		return w.release()
+	}
+
+	header.Set("Content-Encoding", w.encoding)
+	header.Del("Content-Length")
+	// The compressed body is a different representation of the same content
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		header.Set("ETag", "W/"+etag)
+	}
+
+	w.encoder = w.pools[w.encoding].Get().(responseEncoder)
+	w.encoder.Reset(w.ResponseWriter)
+	compressedResponseCounter.WithLabelValues(w.encoding).Inc()
+	return w.release()
+}
+
+// release sends the held back start of the response through the encoder,
+// if any
+func (w *compressWriter) release() error {
+	if w.headerNow || w.buf.Len() > 0 {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	_, err := w.write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+// finish sends a response that stayed below the threshold, or ends the
+// encoded stream
+func (w *compressWriter) finish() {
+	if !w.decided {
+		if err := w.decide(); err != nil {
+			logrus.WithError(err).Debug("Failed to write response")
+			return
+		}
+	}
+	if w.encoder == nil {
+		return
+	}
+	if err := w.encoder.Close(); err != nil {
+		logrus.WithError(err).Debug("Failed to finish compressed response")
+	}
+	w.encoder.Reset(io.Discard)
+	w.pools[w.encoding].Put(w.encoder)
+	w.encoder = nil
+}
@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// analyticsPayload is a large, repetitive JSON body like an analytics response
+var analyticsPayload = func() string {
+	var b strings.Builder
+	b.WriteString(`{"top_queries":[`)
+	for i := 0; i < 500; i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `{"query":"where is my order %d","count":%d}`, i%10, i)
+	}
+	b.WriteString(`]}`)
+	return b.String()
+}()
+
+// compressRouter serves routes with fixed bodies behind Compress
+func compressRouter(level, minSize int) *gin.Engine {
+	router := gin.New()
+	router.Use(Compress(level, minSize))
+	router.GET("/api/analytics", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", []byte(analyticsPayload))
+	})
+	router.GET("/api/health", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"status": "ok"}) })
+	router.GET("/logo.png", func(c *gin.Context) { c.Data(http.StatusOK, "image/png", []byte(analyticsPayload)) })
+	router.GET("/metrics", NoCompression(), func(c *gin.Context) { c.String(http.StatusOK, analyticsPayload) })
+	router.GET("/events", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		c.Writer.WriteString("data: first\n\n")
+		c.Writer.Flush()
+	})
+	return router
+}
+
+func decode(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+	var reader io.Reader
+	var err error
+	switch encoding {
+	case EncodingGzip:
+		reader, err = gzip.NewReader(bytes.NewReader(body))
+	case EncodingDeflate:
+		reader, err = zlib.NewReader(bytes.NewReader(body))
+	default:
+		return string(body)
+	}
+	if err != nil {
+		t.Fatalf("failed to open %s body: %v", encoding, err)
+	}
+	decoded, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to decode %s body: %v", encoding, err)
+	}
+	return string(decoded)
+}
+
+func TestCompressNegotiation(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"gzip", EncodingGzip},
+		{"deflate", EncodingDeflate},
+		{"gzip, deflate, br", EncodingGzip},
+		{"deflate;q=1, gzip;q=0.5", EncodingDeflate},
+		{"deflate, gzip", EncodingGzip},
+		{"gzip;q=0, deflate;q=0.1", EncodingDeflate},
+		{"GZIP", EncodingGzip},
+		{"br, zstd", ""},
+		{"gzip;q=bogus", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := acceptedEncoding(tt.accept); got != tt.want {
+			t.Errorf("acceptedEncoding(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestCompressShrinksLargeResponses(t *testing.T) {
+	router := compressRouter(gzip.DefaultCompression, 1024)
+	for _, encoding := range []string{EncodingGzip, EncodingDeflate} {
+		w := getWith(router, "/api/analytics", map[string]string{"Accept-Encoding": encoding})
+		if got := w.Header().Get("Content-Encoding"); got != encoding {
+			t.Fatalf("%s: Content-Encoding = %q", encoding, got)
+		}
+		if w.Header().Get("Vary") != "Accept-Encoding" || w.Header().Get("Content-Length") != "" {
+			t.Errorf("%s: headers = %v, want Vary and no Content-Length", encoding, w.Header())
+		}
+		if w.Body.Len()*5 > len(analyticsPayload) {
+			t.Errorf("%s: %d bytes compressed to %d, want it at least five times smaller", encoding, len(analyticsPayload), w.Body.Len())
+		}
+		if decode(t, encoding, w.Body.Bytes()) != analyticsPayload {
+			t.Errorf("%s: body changed by compression", encoding)
+		}
+	}
+}
+
+func TestCompressPassesThrough(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		accept string
+		level  int
+		vary   bool
+	}{
+		{"below the threshold", "/api/health", "gzip", gzip.DefaultCompression, true},
+		{"not accepted", "/api/analytics", "br", gzip.DefaultCompression, true},
+		{"not compressible", "/logo.png", "gzip", gzip.DefaultCompression, true},
+		{"opted out", "/metrics", "gzip", gzip.DefaultCompression, false},
+		{"disabled", "/api/analytics", "gzip", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plain := getWith(compressRouter(0, 1024), tt.path, nil)
+			w := getWith(compressRouter(tt.level, 1024), tt.path, map[string]string{"Accept-Encoding": tt.accept})
+			if w.Header().Get("Content-Encoding") != "" || w.Body.String() != plain.Body.String() {
+				t.Errorf("response = %v, %d bytes; want it untouched", w.Header(), w.Body.Len())
+			}
+			if got := w.Header().Get("Vary") == "Accept-Encoding"; got != tt.vary {
+				t.Errorf("Vary: Accept-Encoding set = %t, want %t", got, tt.vary)
+			}
+		})
+	}
+}
+
+func TestCompressLeavesEventStreams(t *testing.T) {
+	w := getWith(compressRouter(gzip.DefaultCompression, 1), "/events", map[string]string{"Accept-Encoding": "gzip"})
+	if !w.Flushed || w.Header().Get("Content-Encoding") != "" || w.Body.String() != "data: first\n\n" {
+		t.Errorf("stream = flushed %t, %v, %q; want it flushed as written", w.Flushed, w.Header(), w.Body.String())
+	}
+}
+
+func TestCompressSkipsHead(t *testing.T) {
+	router := compressRouter(gzip.DefaultCompression, 1)
+	router.HEAD("/api/analytics", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodHead, "/api/analytics", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	router.ServeHTTP(w, req)
+	if w.Header().Get("Content-Encoding") != "" {
+		t.Error("HEAD response claims an encoding")
+	}
+}
+
+func BenchmarkCompressAnalytics(b *testing.B) {
+	for _, tt := range []struct {
+		name   string
+		accept string
+	}{{"identity", ""}, {"gzip", "gzip"}, {"deflate", "deflate"}} {
+		b.Run(tt.name, func(b *testing.B) {
+			router := compressRouter(gzip.DefaultCompression, 1024)
+			req := httptest.NewRequest(http.MethodGet, "/api/analytics", nil)
+			req.Header.Set("Accept-Encoding", tt.accept)
+			var size int
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				size = w.Body.Len()
+			}
+			b.ReportMetric(float64(size), "bytes/response")
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// corsRouter serves the query API and FAQ behind CORS, allowing the shop
+// origin statically and widget origins through the lookup
+func corsRouter() *gin.Engine {
+	widgets := func(ctx context.Context, origin string) bool {
+		return origin == "https://widget.example.org"
+	}
+	router := gin.New()
+	router.Use(Metrics(), CORS([]string{"https://shop.example.com/"}, widgets, 10*time.Minute))
+	router.POST("/api/query", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/api/faq", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.OPTIONS("/*path", Preflight)
+	return router
+}
+
+func corsRequest(method, path, origin string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	corsRouter().ServeHTTP(w, req)
+	return w
+}
+
+func TestCORSPreflight(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		origin string
+		method string
+		status int
+		allow  string
+	}{
+		{"allowed origin", "/api/query", "https://shop.example.com", http.MethodPost, http.StatusNoContent, "https://shop.example.com"},
+		{"origin case and slash", "/api/query", "HTTPS://Shop.Example.com/", http.MethodPost, http.StatusNoContent, "HTTPS://Shop.Example.com/"},
+		{"widget origin", "/api/query", "https://widget.example.org", http.MethodPost, http.StatusNoContent, "https://widget.example.org"},
+		{"disallowed origin", "/api/query", "https://evil.example.net", http.MethodPost, http.StatusForbidden, ""},
+		{"unsupported method", "/api/query", "https://shop.example.com", "TRACE", http.StatusForbidden, "https://shop.example.com"},
+		{"other routes open to any origin", "/api/faq", "https://evil.example.net", http.MethodGet, http.StatusNoContent, "*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := corsRequest(http.MethodOptions, tt.path, tt.origin, map[string]string{"Access-Control-Request-Method": tt.method})
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.allow {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.allow)
+			}
+			if tt.status != http.StatusNoContent {
+				return
+			}
+			if got := w.Header().Get("Access-Control-Allow-Methods"); got != tt.method {
+				t.Errorf("Access-Control-Allow-Methods = %q, want only %s", got, tt.method)
+			}
+			if got := w.Header().Get("Access-Control-Max-Age"); got != "600" {
+				t.Errorf("Access-Control-Max-Age = %q, want 600", got)
+			}
+			if got := w.Header().Get("Access-Control-Expose-Headers"); got != "" {
+				t.Errorf("preflight exposed headers %q", got)
+			}
+		})
+	}
+}
+
+func TestCORSPreflightEchoesRequestedHeaders(t *testing.T) {
+	tests := []struct {
+		requested string
+		want      string
+	}{
+		{"X-Request-ID", "X-Request-ID"},
+		{"content-type, X-Request-ID ,Idempotency-Key", "content-type, X-Request-ID, Idempotency-Key"},
+		{"X-Request-ID, bad header, ", "X-Request-ID"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		w := corsRequest(http.MethodOptions, "/api/query", "https://shop.example.com", map[string]string{
+			"Access-Control-Request-Method":  http.MethodPost,
+			"Access-Control-Request-Headers": tt.requested,
+		})
+		if got := w.Header().Get("Access-Control-Allow-Headers"); got != tt.want {
+			t.Errorf("requested %q: Access-Control-Allow-Headers = %q, want %q", tt.requested, got, tt.want)
+		}
+		if vary := w.Header().Values("Vary"); len(vary) != 3 {
+			t.Errorf("Vary = %v, want Origin and the request method and headers", vary)
+		}
+	}
+}
+
+func TestCORSSimpleRequest(t *testing.T) {
+	w := corsRequest(http.MethodPost, "/api/query", "https://shop.example.com", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://shop.example.com" {
+		t.Errorf("allowed simple request = %d %v", w.Code, w.Header())
+	}
+	if w.Header().Get("Access-Control-Expose-Headers") != corsExposedHeaders {
+		t.Error("simple request doesn't expose the rate limit and request ID headers")
+	}
+	if w.Header().Get("Access-Control-Allow-Methods") != "" || w.Header().Get("Access-Control-Max-Age") != "" {
+		t.Error("simple request has preflight headers")
+	}
+
+	// A disallowed origin still reaches the handler, but the browser blocks
+	// the response without the CORS headers
+	w = corsRequest(http.MethodPost, "/api/query", "https://evil.example.net", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("disallowed simple request = %d %v, want no CORS headers", w.Code, w.Header())
+	}
+
+	// A plain OPTIONS request isn't a preflight
+	w = corsRequest(http.MethodOptions, "/api/query", "https://shop.example.com", nil)
+	if w.Header().Get("Access-Control-Max-Age") != "" {
+		t.Error("OPTIONS without a requested method answered as a preflight")
+	}
+}
+
+func TestCORSPreflightMetricsLabel(t *testing.T) {
+	count := func() float64 {
+		var m dto.Metric
+		httpRequestsTotal.WithLabelValues(http.MethodOptions, preflightRoute, "204").Write(&m)
+		return m.GetCounter().GetValue()
+	}
+
+	before := count()
+	corsRequest(http.MethodOptions, "/api/query", "https://shop.example.com", map[string]string{"Access-Control-Request-Method": http.MethodPost})
+	if got := count() - before; got != 1 {
+		t.Errorf("preflights counted = %v, want 1 under the %q route", got, preflightRoute)
+	}
+}
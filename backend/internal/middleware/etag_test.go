@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// analyticsRouter serves GET /api/analytics behind ETag, reporting how many
+// queries have been saved
+func analyticsRouter(queries *int64, handlers ...gin.HandlerFunc) *gin.Engine {
+	router := gin.New()
+	handlers = append(handlers, ETag(30*time.Second), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"total_queries": atomic.LoadInt64(queries), "top_queries": strings.Repeat("where is my order ", 20)})
+	})
+	router.GET("/api/analytics", handlers...)
+	return router
+}
+
+func getWith(router http.Handler, path string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestETagRepeatReadsNotModified(t *testing.T) {
+	var queries int64 = 10
+	router := analyticsRouter(&queries)
+
+	first := getWith(router, "/api/analytics", nil)
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || !strings.HasPrefix(etag, `"`) {
+		t.Fatalf("first read = %d with ETag %q, want 200 with a strong ETag", first.Code, etag)
+	}
+	if got := first.Header().Get("Cache-Control"); got != "private, max-age=30" {
+		t.Errorf("Cache-Control = %q, want private, max-age=30", got)
+	}
+
+	repeat := getWith(router, "/api/analytics", map[string]string{"If-None-Match": etag})
+	if repeat.Code != http.StatusNotModified || repeat.Body.Len() != 0 {
+		t.Errorf("repeat read = %d with %d bytes, want an empty 304", repeat.Code, repeat.Body.Len())
+	}
+	if repeat.Header().Get("ETag") != etag || repeat.Header().Get("Content-Type") != "" {
+		t.Errorf("304 headers = %v, want the ETag and no Content-Type", repeat.Header())
+	}
+
+	// A new query row changes the payload
+	atomic.AddInt64(&queries, 1)
+	changed := getWith(router, "/api/analytics", map[string]string{"If-None-Match": etag})
+	if changed.Code != http.StatusOK || changed.Header().Get("ETag") == etag {
+		t.Errorf("read after a change = %d with ETag %q, want 200 with a new ETag", changed.Code, changed.Header().Get("ETag"))
+	}
+	if !strings.Contains(changed.Body.String(), `"total_queries":11`) {
+		t.Errorf("body = %s, want the new data", changed.Body.String())
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	const etag = `"abc123"`
+	tests := []struct {
+		ifNoneMatch string
+		want        bool
+	}{
+		{`"abc123"`, true},
+		{`W/"abc123"`, true},
+		{`"zzz", "abc123"`, true},
+		{`*`, true},
+		{`"abc12"`, false},
+		{``, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.ifNoneMatch, etag); got != tt.want {
+			t.Errorf("etagMatches(%q) = %t, want %t", tt.ifNoneMatch, got, tt.want)
+		}
+	}
+}
+
+func TestETagOnlyTagsSuccessfulGets(t *testing.T) {
+	router := gin.New()
+	router.Use(ETag(time.Minute))
+	router.GET("/missing", func(c *gin.Context) { c.JSON(http.StatusNotFound, gin.H{"error": "not_found"}) })
+	router.POST("/api/query", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"response": "hi"}) })
+
+	if w := getWith(router, "/missing", nil); w.Code != http.StatusNotFound || w.Header().Get("ETag") != "" || !strings.Contains(w.Body.String(), "not_found") {
+		t.Errorf("404 = %d %v %s, want it untagged", w.Code, w.Header(), w.Body.String())
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/query", nil))
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != "" {
+		t.Errorf("POST = %d %v, want it untagged", w.Code, w.Header())
+	}
+}
+
+func TestETagPassesStreamsThrough(t *testing.T) {
+	router := gin.New()
+	router.GET("/events", ETag(time.Minute), func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		c.Writer.WriteString("data: first\n\n")
+		c.Writer.Flush()
+		c.Writer.WriteString("data: second\n\n")
+	})
+
+	w := getWith(router, "/events", nil)
+	if !w.Flushed || w.Header().Get("ETag") != "" {
+		t.Errorf("stream flushed = %t with ETag %q, want it flushed untagged", w.Flushed, w.Header().Get("ETag"))
+	}
+	if w.Body.String() != "data: first\n\ndata: second\n\n" {
+		t.Errorf("body = %q, want both events", w.Body.String())
+	}
+}
+
+func TestETagWithCompression(t *testing.T) {
+	var queries int64 = 10
+	router := analyticsRouter(&queries, Compress(gzip.DefaultCompression, 64))
+	gzipped := map[string]string{"Accept-Encoding": "gzip"}
+
+	first := getWith(router, "/api/analytics", gzipped)
+	etag := first.Header().Get("ETag")
+	if first.Header().Get("Content-Encoding") != "gzip" || !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("compressed read has encoding %q and ETag %q, want gzip with a weak ETag", first.Header().Get("Content-Encoding"), etag)
+	}
+	reader, err := gzip.NewReader(first.Body)
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	body, _ := io.ReadAll(reader)
+	if !strings.Contains(string(body), `"total_queries":10`) {
+		t.Errorf("decompressed body = %s", body)
+	}
+
+	// The weakened ETag still matches, and the 304 isn't encoded
+	repeat := getWith(router, "/api/analytics", map[string]string{"Accept-Encoding": "gzip", "If-None-Match": etag})
+	if repeat.Code != http.StatusNotModified || repeat.Body.Len() != 0 || repeat.Header().Get("Content-Encoding") != "" {
+		t.Errorf("repeat read = %d, %d bytes, encoding %q; want an empty unencoded 304", repeat.Code, repeat.Body.Len(), repeat.Header().Get("Content-Encoding"))
+	}
+
+	// So does the strong ETag of an uncompressed read
+	plain := getWith(router, "/api/analytics", nil)
+	if repeat := getWith(router, "/api/analytics", map[string]string{"Accept-Encoding": "gzip", "If-None-Match": plain.Header().Get("ETag")}); repeat.Code != http.StatusNotModified {
+		t.Errorf("read with the uncompressed ETag = %d, want 304", repeat.Code)
+	}
+}
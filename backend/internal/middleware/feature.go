@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FeatureChecker reports whether a feature is enabled and, if it isn't, the
+// operator's message for clients. It is called on every request, so it must
+// not do I/O.
+type FeatureChecker func(name string) (enabled bool, message string)
+
+// RequireFeature rejects requests with 503 feature_disabled while the named
+// feature is switched off
+func RequireFeature(check FeatureChecker, name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		enabled, message := check(name)
+		if enabled {
+			c.Next()
+			return
+		}
+
+		if message == "" {
+			message = "This feature is temporarily disabled. Please try again later."
+		}
+		featureDisabledCounter.WithLabelValues(name).Inc()
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "feature_disabled",
+			"message": message,
+			"feature": name,
+		})
+		c.Abort()
+	}
+}
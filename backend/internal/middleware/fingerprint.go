@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/fingerprint"
+	"github.com/gin-gonic/gin"
+)
+
+// VisitorFingerprint sets "visitor_id" on the context to a pseudonymous ID
+// derived from the client IP and user agent. Only the ID is kept; the IP is
+// never passed on.
+func VisitorFingerprint(fingerprinter *fingerprint.Fingerprinter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("visitor_id", fingerprinter.Visitor(c.ClientIP(), c.Request.UserAgent(), time.Now()))
+		c.Next()
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/fingerprint"
+	"github.com/gin-gonic/gin"
+)
+
+func TestVisitorFingerprint(t *testing.T) {
+	fingerprinter, _ := fingerprint.New("secret", 24*time.Hour)
+	var visitorID string
+	router := gin.New()
+	router.GET("/", VisitorFingerprint(fingerprinter), func(c *gin.Context) {
+		visitorID = c.GetString("visitor_id")
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.7:4321"
+	req.Header.Set("User-Agent", "widget/1.0")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if want := fingerprinter.Visitor("203.0.113.7", "widget/1.0", time.Now()); visitorID != want {
+		t.Errorf("visitor_id = %q, want %q", visitorID, want)
+	}
+}
+
+func BenchmarkVisitorFingerprint(b *testing.B) {
+	fingerprinter, _ := fingerprint.New("secret", 24*time.Hour)
+	router := gin.New()
+	router.GET("/", VisitorFingerprint(fingerprinter), func(c *gin.Context) {})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.7:4321"
+	req.Header.Set("User-Agent", "widget/1.0")
+	w := httptest.NewRecorder()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		router.ServeHTTP(w, req)
+	}
+}
@@ -0,0 +1,246 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// IdempotencyKeyHeader carries the client's key for safely retrying a POST
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayHeader marks a response replayed from an earlier request
+const IdempotentReplayHeader = "Idempotent-Replay"
+
+// maxIdempotencyKeyLength bounds client keys; UUIDs and ULIDs fit comfortably
+const maxIdempotencyKeyLength = 255
+
+// idempotencyPollInterval is how often a duplicate request checks whether
+// the original has finished
+const idempotencyPollInterval = 100 * time.Millisecond
+
+// idempotencyKeyPattern limits accepted keys to safe characters
+var idempotencyKeyPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]+$`)
+
+// StoredResponse is a response saved for replay to retries with the same key
+type StoredResponse struct {
+	Endpoint    string `json:"endpoint"`
+	RequestHash string `json:"request_hash"`
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+// IdempotencyStore saves responses and serializes requests by scoped key
+type IdempotencyStore interface {
+	// Lookup returns the saved response for key, or nil if there is none
+	Lookup(ctx context.Context, key string) (*StoredResponse, error)
+	// Acquire claims key for one request, returning false while another holds it
+	Acquire(ctx context.Context, key string) (bool, error)
+	// Release gives up a claim taken with Acquire
+	Release(ctx context.Context, key string)
+	// Save stores the response to the request that claimed key
+	Save(ctx context.Context, key string, resp *StoredResponse) error
+}
+
+// Idempotency replays the saved response to requests repeating an
+// Idempotency-Key. Keys are scoped to the endpoint, the authenticated user and
+// the body's session ID (or the client IP without one), so it must run after
+// AuthMiddleware. A duplicate that arrives while the original is still
+// running waits up to wait for its result. Only successful responses are
+// saved, so failed requests can be retried; streaming requests are not
+// covered. Store errors let the request through rather than fail it.
+func Idempotency(store IdempotencyStore, endpoint string, wait time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength || !idempotencyKeyPattern.MatchString(key) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid_idempotency_key",
+				"message": "Idempotency-Key must be at most 255 letters, digits or ._:- characters",
+			})
+			c.Abort()
+			return
+		}
+
+		// The whole body is hashed and handed on; MaxBodySize bounds it
+		var data []byte
+		if c.Request.Body != nil {
+			var err error
+			data, err = io.ReadAll(c.Request.Body)
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+					"error":   "request_too_large",
+					"message": fmt.Sprintf("Request body must not exceed %d bytes", tooLarge.Limit),
+				})
+				c.Abort()
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "invalid_request",
+					"message": "Failed to read request body",
+				})
+				c.Abort()
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(data))
+		}
+
+		var body struct {
+			SessionID string `json:"session_id"`
+			Stream    bool   `json:"stream"`
+		}
+		json.Unmarshal(data, &body)
+		if body.Stream {
+			c.Next()
+			return
+		}
+
+		subject := body.SessionID
+		if subject == "" {
+			subject = "ip:" + c.ClientIP()
+		}
+		scoped := idempotencyScope(endpoint, c.GetString("user_id"), subject, key)
+		requestHash := sha256.Sum256(data)
+		fingerprint := hex.EncodeToString(requestHash[:])
+
+		ctx := c.Request.Context()
+		deadline := time.Now().Add(wait)
+		for {
+			saved, err := store.Lookup(ctx, scoped)
+			if err != nil {
+				logrus.WithError(err).Warn("Failed to look up idempotency key, processing request")
+				c.Next()
+				return
+			}
+			if saved != nil {
+				replayResponse(c, endpoint, fingerprint, saved)
+				return
+			}
+
+			acquired, err := store.Acquire(ctx, scoped)
+			if err != nil {
+				logrus.WithError(err).Warn("Failed to claim idempotency key, processing request")
+				c.Next()
+				return
+			}
+			if acquired {
+				break
+			}
+
+			if time.Now().After(deadline) {
+				c.Header("Retry-After", "1")
+				c.JSON(http.StatusConflict, gin.H{
+					"error":   "request_in_progress",
+					"message": "A request with this Idempotency-Key is still being processed",
+				})
+				c.Abort()
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				c.Abort()
+				return
+			case <-time.After(idempotencyPollInterval):
+			}
+		}
+
+		// The claim and the saved response outlive a client that disconnects
+		storeCtx := context.WithoutCancel(ctx)
+		defer store.Release(storeCtx, scoped)
+
+		writer := &capturingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		status := writer.Status()
+		if status < 200 || status >= 300 {
+			return
+		}
+
+		saveCtx, cancel := context.WithTimeout(storeCtx, 5*time.Second)
+		defer cancel()
+		err := store.Save(saveCtx, scoped, &StoredResponse{
+			Endpoint:    endpoint,
+			RequestHash: fingerprint,
+			StatusCode:  status,
+			ContentType: writer.Header().Get("Content-Type"),
+			Body:        writer.body.Bytes(),
+		})
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to save idempotent response")
+		}
+	}
+}
+
+// idempotencyScope hashes the client key with the endpoint, user and subject
+// so a key can't replay another endpoint's, user's or session's response
+func idempotencyScope(endpoint, userID, subject, key string) string {
+	hasher := sha256.New()
+	for _, part := range []string{endpoint, userID, subject, key} {
+		hasher.Write([]byte(part))
+		hasher.Write([]byte{0})
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// replayResponse writes a saved response, marking it as a replay. A key
+// reused with a different request body is rejected instead.
+func replayResponse(c *gin.Context, endpoint, fingerprint string, saved *StoredResponse) {
+	if saved.RequestHash != fingerprint {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "idempotency_key_reused",
+			"message": "Idempotency-Key was already used with a different request",
+		})
+		c.Abort()
+		return
+	}
+
+	idempotentReplayCounter.WithLabelValues(endpoint).Inc()
+
+	body := saved.Body
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) == nil && fields != nil {
+		fields["idempotent_replay"] = json.RawMessage("true")
+		if marked, err := json.Marshal(fields); err == nil {
+			body = marked
+		}
+	}
+
+	c.Header(IdempotentReplayHeader, "true")
+	c.Data(saved.StatusCode, saved.ContentType, body)
+	c.Abort()
+}
+
+// capturingWriter copies the response body as it is written
+type capturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *capturingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
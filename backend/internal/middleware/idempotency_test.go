@@ -0,0 +1,295 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// memoryIdempotencyStore keeps saved responses and claims in memory
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	saved   map[string]*StoredResponse
+	claimed map[string]bool
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{saved: make(map[string]*StoredResponse), claimed: make(map[string]bool)}
+}
+
+func (s *memoryIdempotencyStore) Lookup(ctx context.Context, key string) (*StoredResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saved[key], nil
+}
+
+func (s *memoryIdempotencyStore) Acquire(ctx context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.claimed[key] {
+		return false, nil
+	}
+	s.claimed[key] = true
+	return true, nil
+}
+
+func (s *memoryIdempotencyStore) Release(ctx context.Context, key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.claimed, key)
+}
+
+func (s *memoryIdempotencyStore) Save(ctx context.Context, key string, resp *StoredResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saved[key] = resp
+	return nil
+}
+
+// idempotentRouter serves POST /query behind Idempotency, answering with
+// handler and counting the requests that reach it
+func idempotentRouter(store IdempotencyStore, wait time.Duration, calls *int32, handler gin.HandlerFunc) *gin.Engine {
+	router := gin.New()
+	router.POST("/query", Idempotency(store, "query", wait), func(c *gin.Context) {
+		n := atomic.AddInt32(calls, 1)
+		if handler != nil {
+			handler(c)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"response": "answer", "call": n})
+	})
+	return router
+}
+
+func postQuery(router http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func decodeBody(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode body %q: %v", w.Body.String(), err)
+	}
+	return body
+}
+
+func TestIdempotencyReplaysSavedResponse(t *testing.T) {
+	var calls int32
+	router := idempotentRouter(newMemoryIdempotencyStore(), time.Second, &calls, nil)
+	body := `{"session_id":"s1","query":"hi"}`
+
+	first := postQuery(router, "key-1", body)
+	if first.Code != http.StatusOK || first.Header().Get(IdempotentReplayHeader) != "" {
+		t.Fatalf("first response = %d %v", first.Code, first.Header())
+	}
+
+	replay := postQuery(router, "key-1", body)
+	if calls != 1 {
+		t.Errorf("handler ran %d times, want 1", calls)
+	}
+	if replay.Code != http.StatusOK || replay.Header().Get(IdempotentReplayHeader) != "true" {
+		t.Errorf("replay = %d %v, want 200 marked as a replay", replay.Code, replay.Header())
+	}
+	if got := decodeBody(t, replay); got["idempotent_replay"] != true || got["call"] != float64(1) {
+		t.Errorf("replay body = %v, want the first response marked as a replay", got)
+	}
+}
+
+func TestIdempotencyRejectsReusedKey(t *testing.T) {
+	var calls int32
+	router := idempotentRouter(newMemoryIdempotencyStore(), time.Second, &calls, nil)
+
+	postQuery(router, "key-1", `{"session_id":"s1","query":"hi"}`)
+	w := postQuery(router, "key-1", `{"session_id":"s1","query":"something else"}`)
+	if w.Code != http.StatusUnprocessableEntity || decodeBody(t, w)["error"] != "idempotency_key_reused" {
+		t.Errorf("response = %d %s, want 422 idempotency_key_reused", w.Code, w.Body.String())
+	}
+	if calls != 1 {
+		t.Errorf("handler ran %d times, want 1", calls)
+	}
+}
+
+func TestIdempotencyScopesKeys(t *testing.T) {
+	var calls int32
+	router := idempotentRouter(newMemoryIdempotencyStore(), time.Second, &calls, nil)
+
+	postQuery(router, "key-1", `{"session_id":"s1"}`)
+	w := postQuery(router, "key-1", `{"session_id":"s2"}`)
+	if calls != 2 || w.Header().Get(IdempotentReplayHeader) != "" {
+		t.Errorf("another session's request was replayed: calls = %d, headers = %v", calls, w.Header())
+	}
+}
+
+func TestIdempotencyScopesKeysToUser(t *testing.T) {
+	var calls int32
+	router := gin.New()
+	router.POST("/query", func(c *gin.Context) {
+		if user := c.GetHeader("X-Test-User"); user != "" {
+			c.Set("user_id", user)
+		}
+	}, Idempotency(newMemoryIdempotencyStore(), "query", time.Second), func(c *gin.Context) {
+		atomic.AddInt32(&calls, 1)
+		c.JSON(http.StatusOK, gin.H{"response": "answer for " + c.GetString("user_id")})
+	})
+	post := func(user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(`{"session_id":"s1"}`))
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		if user != "" {
+			req.Header.Set("X-Test-User", user)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	post("alice")
+	// Another user guessing the session ID and key gets their own answer
+	for _, user := range []string{"mallory", ""} {
+		if w := post(user); w.Header().Get(IdempotentReplayHeader) != "" {
+			t.Errorf("user %q was replayed alice's response: %s", user, w.Body.String())
+		}
+	}
+	if w := post("alice"); w.Header().Get(IdempotentReplayHeader) != "true" {
+		t.Errorf("alice's retry = %v, want a replay", w.Header())
+	}
+	if calls != 3 {
+		t.Errorf("handler ran %d times, want once per user", calls)
+	}
+}
+
+func TestIdempotencyHashesWholeBody(t *testing.T) {
+	var calls int32
+	router := idempotentRouter(newMemoryIdempotencyStore(), time.Second, &calls, nil)
+	padding := strings.Repeat("a", 2<<20)
+
+	// Bodies past a megabyte that differ only at the end aren't the same request
+	postQuery(router, "key-1", `{"session_id":"s1","query":"`+padding+`1"}`)
+	w := postQuery(router, "key-1", `{"session_id":"s1","query":"`+padding+`2"}`)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("response = %d, want 422 for a key reused with a different body", w.Code)
+	}
+}
+
+func TestIdempotencyBodyLimit(t *testing.T) {
+	var calls int32
+	router := gin.New()
+	router.POST("/query", MaxBodySize(64), Idempotency(newMemoryIdempotencyStore(), "query", time.Second), func(c *gin.Context) {
+		atomic.AddInt32(&calls, 1)
+		c.Status(http.StatusOK)
+	})
+
+	if w := postQuery(router, "key-1", `{"session_id":"s1"}`); w.Code != http.StatusOK {
+		t.Errorf("small body = %d, want 200", w.Code)
+	}
+	w := postQuery(router, "key-2", `{"session_id":"s1","query":"`+strings.Repeat("a", 100)+`"}`)
+	if w.Code != http.StatusRequestEntityTooLarge || decodeBody(t, w)["error"] != "request_too_large" {
+		t.Errorf("large body = %d %s, want 413 request_too_large", w.Code, w.Body.String())
+	}
+	if calls != 1 {
+		t.Errorf("handler ran %d times, want the large body stopped", calls)
+	}
+}
+
+func TestIdempotencyDoesNotSaveFailures(t *testing.T) {
+	var calls int32
+	router := idempotentRouter(newMemoryIdempotencyStore(), time.Second, &calls, func(c *gin.Context) {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "rag_unavailable"})
+	})
+
+	postQuery(router, "key-1", `{"session_id":"s1"}`)
+	postQuery(router, "key-1", `{"session_id":"s1"}`)
+	if calls != 2 {
+		t.Errorf("handler ran %d times, want the failed request retried", calls)
+	}
+}
+
+func TestIdempotencyKeyValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+		want int
+	}{
+		{"no key", "", http.StatusOK},
+		{"uuid", "3f2b8c1e-6a0d-4c55-9d8e-1b2c3d4e5f60", http.StatusOK},
+		{"longest allowed", strings.Repeat("k", maxIdempotencyKeyLength), http.StatusOK},
+		{"too long", strings.Repeat("k", maxIdempotencyKeyLength+1), http.StatusBadRequest},
+		{"unsafe characters", "key with spaces", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			router := idempotentRouter(newMemoryIdempotencyStore(), time.Second, &calls, nil)
+			if w := postQuery(router, tt.key, `{}`); w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestIdempotencyCoalescesConcurrentDuplicates(t *testing.T) {
+	var calls int32
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	router := idempotentRouter(newMemoryIdempotencyStore(), 5*time.Second, &calls, func(c *gin.Context) {
+		close(started)
+		<-finish
+		c.JSON(http.StatusOK, gin.H{"response": "answer"})
+	})
+	body := `{"session_id":"s1"}`
+
+	responses := make([]*httptest.ResponseRecorder, 2)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		responses[0] = postQuery(router, "key-1", body)
+	}()
+	<-started
+	go func() {
+		defer wg.Done()
+		responses[1] = postQuery(router, "key-1", body)
+	}()
+
+	// Let the duplicate find the key claimed before the original finishes
+	time.Sleep(2 * idempotencyPollInterval)
+	close(finish)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("handler ran %d times, want 1", calls)
+	}
+	if responses[1].Code != http.StatusOK || responses[1].Header().Get(IdempotentReplayHeader) != "true" {
+		t.Errorf("duplicate = %d %v, want the original's response replayed", responses[1].Code, responses[1].Header())
+	}
+}
+
+func TestIdempotencyInProgressTimesOut(t *testing.T) {
+	var calls int32
+	store := newMemoryIdempotencyStore()
+	router := idempotentRouter(store, 0, &calls, nil)
+	store.claimed[idempotencyScope("query", "", "s1", "key-1")] = true
+
+	w := postQuery(router, "key-1", `{"session_id":"s1"}`)
+	if w.Code != http.StatusConflict || w.Header().Get("Retry-After") == "" {
+		t.Errorf("response = %d %v, want 409 with Retry-After", w.Code, w.Header())
+	}
+	if calls != 0 {
+		t.Errorf("handler ran %d times while the key was claimed", calls)
+	}
+}
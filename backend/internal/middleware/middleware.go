@@ -1,18 +1,36 @@
 package middleware
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"regexp"
+	"runtime/debug"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/ai-support-assistant/backend/internal/abuse"
+	"github.com/ai-support-assistant/backend/internal/audit"
 	"github.com/ai-support-assistant/backend/internal/cache"
+	"github.com/ai-support-assistant/backend/internal/errortracking"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/net/http/httpguts"
 )
 
 var (
@@ -24,11 +42,12 @@ var (
 		[]string{"method", "endpoint", "status"},
 	)
 
-	httpRequestDuration = promauto.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "http_request_duration_seconds",
-			Help:    "HTTP request duration in seconds",
-			Buckets: prometheus.DefBuckets,
+	httpRequestDuration = newHTTPRequestDuration(prometheus.DefBuckets)
+
+	httpSlowRequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_slow_requests_total",
+			Help: "Total number of HTTP requests slower than SLOW_REQUEST_THRESHOLD_MS",
 		},
 		[]string{"method", "endpoint"},
 	)
@@ -41,17 +60,535 @@ var (
 		[]string{"cache_type"},
 	)
 
-	ragRequestDuration = promauto.NewHistogram(
+	cacheLookupCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_lookups_total",
+			Help: "Total number of cache lookups by result (hit, stale, miss, bypassed); bypassed lookups are excluded from hit rate",
+		},
+		[]string{"cache_type", "result"},
+	)
+
+	ragDegradedGauge = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "rag_degraded_mode",
+			Help: "1 while every RAG endpoint is marked unavailable and queries are served from cache only",
+		},
+	)
+
+	ragFailoverCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rag_failovers_total",
+			Help: "Total number of queries sent to the fallback RAG endpoint, by reason (error or breaker_open)",
+		},
+		[]string{"reason"},
+	)
+
+	modelRoutingCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "model_routing_decisions_total",
+			Help: "Total number of queries routed to a model, by model and whether a routing rule or the default chose it",
+		},
+		[]string{"model", "source"},
+	)
+
+	generationRecoveryCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rag_generation_recoveries_total",
+			Help: "Total number of timed-out or retried queries by outcome (attached, partial_served, completed, unavailable, disallowed)",
+		},
+		[]string{"outcome"},
+	)
+
+	ragInFlightGauge = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "rag_in_flight",
+			Help: "Number of RAG generations currently running",
+		},
+	)
+
+	ragQueueDepthGauge = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "rag_queue_depth",
+			Help: "Number of requests waiting for a RAG generation slot",
+		},
+	)
+
+	ragShedCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rag_requests_shed_total",
+			Help: "Total number of requests rejected as system_busy by reason (queue_full, wait_budget, wait_timeout)",
+		},
+		[]string{"reason"},
+	)
+
+	ragUpstreamRateLimitedCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rag_upstream_rate_limited_total",
+			Help: "Total number of 429 responses from the RAG service by outcome (retried, rejected)",
+		},
+		[]string{"outcome"},
+	)
+
+	cacheRefreshCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_refresh_total",
+			Help: "Total number of background refreshes of stale cache entries by result",
+		},
+		[]string{"cache_type", "result"},
+	)
+
+	cacheBypassCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_bypass_total",
+			Help: "Total number of cache bypasses by reason",
+		},
+		[]string{"cache_type", "reason"},
+	)
+
+	cacheFeedbackEvictionCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_feedback_evictions_total",
+			Help: "Total number of cached answers evicted (evicted) or excluded from caching (suppressed) after negative feedback",
+		},
+		[]string{"cache_type", "action"},
+	)
+
+	cacheWriteCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_writes_total",
+			Help: "Total number of answers written to the cache (written) or left out as too rarely asked (skipped)",
+		},
+		[]string{"cache_type", "result"},
+	)
+
+	cacheSkippedBytesCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_skipped_bytes_total",
+			Help: "Total size of the answers left out of the cache as too rarely asked, in bytes",
+		},
+		[]string{"cache_type"},
+	)
+
+	cacheWriteTTL = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "cache_write_ttl_seconds",
+			Help:    "Fresh TTL answers were cached for",
+			Buckets: []float64{60, 300, 900, 1800, 3600, 7200, 21600, 43200, 86400, 172800},
+		},
+		[]string{"cache_type"},
+	)
+
+	ragCoalescedCounter = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "rag_coalesced_requests_total",
+			Help: "Total number of queries served by sharing an in-flight RAG call",
+		},
+	)
+
+	moderationFlagCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "moderation_flags_total",
+			Help: "Total number of moderation flags by stage and category",
+		},
+		[]string{"stage", "category"},
+	)
+
+	abuseBanCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "abuse_bans_total",
+			Help: "Total number of automatic temporary bans by subject kind",
+		},
+		[]string{"kind"},
+	)
+
+	abuseBlockedCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "abuse_blocked_requests_total",
+			Help: "Total number of requests rejected because of an active ban",
+		},
+		[]string{"kind"},
+	)
+
+	ragRequestDuration = newRAGRequestDuration(prometheus.DefBuckets)
+
+	ragPhaseDuration = newRAGPhaseDuration(prometheus.DefBuckets)
+
+	queryStageDuration = newQueryStageDuration(prometheus.DefBuckets)
+
+	serverDrainingGauge = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "server_draining",
+			Help: "1 while the instance is draining ahead of shutdown and failing readiness",
+		},
+	)
+
+	dbConnectionsGauge = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_pool_connections",
+			Help: "Database pool connections by state (open, in_use, idle, max_open)",
+		},
+		[]string{"state"},
+	)
+
+	dbWaitCounter = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "db_pool_wait_total",
+			Help: "Total number of times a query waited for a free database connection",
+		},
+	)
+
+	dbWaitDurationCounter = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "db_pool_wait_seconds_total",
+			Help: "Total time spent waiting for a free database connection in seconds",
+		},
+	)
+
+	dbSlowQueryCounter = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "db_slow_queries_total",
+			Help: "Total number of database queries slower than the slow query threshold",
+		},
+	)
+
+	idempotentReplayCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "idempotent_replays_total",
+			Help: "Total number of responses replayed for a repeated Idempotency-Key",
+		},
+		[]string{"endpoint"},
+	)
+
+	requestTimeoutCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_request_timeouts_total",
+			Help: "Total number of requests that exceeded their route timeout",
+		},
+		[]string{"endpoint"},
+	)
+
+	notModifiedCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_not_modified_total",
+			Help: "Total number of conditional reads answered with 304 Not Modified",
+		},
+		[]string{"endpoint"},
+	)
+
+	compressedResponseCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_compressed_responses_total",
+			Help: "Total number of responses compressed by encoding (gzip, deflate)",
+		},
+		[]string{"encoding"},
+	)
+
+	promptEstimateRatio = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "prompt_token_estimate_ratio",
+			Help:    "Tokens the RAG service reported using divided by the backend's prompt estimate",
+			Buckets: []float64{0.25, 0.5, 0.75, 0.9, 1, 1.1, 1.25, 1.5, 2, 4},
+		},
+		[]string{"model"},
+	)
+
+	promptRejectedCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "prompt_budget_total",
+			Help: "Total number of prompts over the model's context window, by whether history was trimmed or the query rejected",
+		},
+		[]string{"outcome"},
+	)
+
+	groundingScoreHistogram = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "answer_grounding_score",
+			Help:    "How well verified answers are supported by their retrieved context, from 0 to 1",
+			Buckets: []float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9, 1},
+		},
+	)
+
+	verificationCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "answer_verifications_total",
+			Help: "Total number of answer grounding verifications by result (grounded, low_score, error)",
+		},
+		[]string{"result"},
+	)
+
+	errorReportCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "error_reports_total",
+			Help: "Total number of panics and server errors captured for the error tracker by kind (panic, error) and outcome (queued, sampled_out, dropped)",
+		},
+		[]string{"kind", "outcome"},
+	)
+
+	malwareScanCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "malware_scans_total",
+			Help: "Total number of uploads scanned for malware by verdict (clean, infected, skipped, unavailable)",
+		},
+		[]string{"verdict"},
+	)
+
+	sessionClaimCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "session_claims_total",
+			Help: "Total number of attempts to claim an anonymous session by outcome (claimed, already_claimed, invalid_token, conflict)",
+		},
+		[]string{"outcome"},
+	)
+
+	feedbackLookupRetryCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "feedback_lookup_retries_total",
+			Help: "Total number of feedback submissions whose query wasn't found at first by outcome (found, not_yet_visible, not_found)",
+		},
+		[]string{"outcome"},
+	)
+
+	chaosFaultCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chaos_faults_injected_total",
+			Help: "Total number of faults injected in chaos mode by target (rag, redis, db) and type (latency, error, timeout)",
+		},
+		[]string{"target", "type"},
+	)
+
+	queryPipelineCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "query_pipeline_total",
+			Help: "Total number of generated answers by pipeline (rag, direct) and how it was chosen (rule, classifier, default, fallback)",
+		},
+		[]string{"pipeline", "method"},
+	)
+
+	llmRequestCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "llm_requests_total",
+			Help: "Total number of direct LLM API calls by operation (answer, classify) and status (success, error)",
+		},
+		[]string{"operation", "status"},
+	)
+
+	responseLanguageCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "response_language_checks_total",
+			Help: "Total number of answers checked against their target language by outcome (match, translated, mismatch, error)",
+		},
+		[]string{"outcome"},
+	)
+
+	featureDisabledCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "feature_disabled_requests_total",
+			Help: "Total number of requests rejected because their feature flag was off",
+		},
+		[]string{"feature"},
+	)
+
+	deadLetterBacklogGauge = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "dead_letter_backlog",
+			Help: "Number of dead-lettered operations waiting to be replayed, by operation",
+		},
+		[]string{"operation"},
+	)
+	activitySubscribersGauge = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "activity_stream_subscribers",
+			Help: "Number of open live activity feed connections on this instance",
+		},
+	)
+
+	activityDroppedCounter = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "activity_events_dropped_total",
+			Help: "Total number of activity events dropped because a feed connection fell behind",
+		},
+	)
+)
+
+// slowRequestThreshold is the duration past which a request is counted as
+// slow; set by ConfigureMetrics
+var slowRequestThreshold = 10 * time.Second
+
+func newHTTPRequestDuration(buckets []float64) *prometheus.HistogramVec {
+	return promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request duration in seconds",
+			Buckets: buckets,
+		},
+		[]string{"method", "endpoint"},
+	)
+}
+
+func newRAGRequestDuration(buckets []float64) prometheus.Histogram {
+	return promauto.NewHistogram(
 		prometheus.HistogramOpts{
 			Name:    "rag_request_duration_seconds",
 			Help:    "RAG service request duration in seconds",
-			Buckets: prometheus.DefBuckets,
+			Buckets: buckets,
 		},
 	)
-)
+}
+
+func newRAGPhaseDuration(buckets []float64) *prometheus.HistogramVec {
+	return promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "rag_phase_duration_seconds",
+			Help:    "Duration of RAG request phases reported by the RAG service, in seconds",
+			Buckets: buckets,
+		},
+		[]string{"phase"}, // retrieval, generation, time_to_first_token
+	)
+}
+
+func newQueryStageDuration(buckets []float64) *prometheus.HistogramVec {
+	return promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "query_stage_duration_seconds",
+			Help:    "Duration of each stage of the query pipeline, in seconds",
+			Buckets: buckets,
+		},
+		[]string{"stage"},
+	)
+}
+
+// ConfigureMetrics re-registers the duration histograms with the configured
+// buckets and sets the slow request threshold. RAG phases and query stages
+// share the RAG buckets. It must be called before the server starts.
+func ConfigureMetrics(httpBuckets, ragBuckets []float64, slowThreshold time.Duration) {
+	prometheus.Unregister(httpRequestDuration)
+	httpRequestDuration = newHTTPRequestDuration(httpBuckets)
+
+	prometheus.Unregister(ragRequestDuration)
+	ragRequestDuration = newRAGRequestDuration(ragBuckets)
+
+	prometheus.Unregister(ragPhaseDuration)
+	ragPhaseDuration = newRAGPhaseDuration(ragBuckets)
+
+	prometheus.Unregister(queryStageDuration)
+	queryStageDuration = newQueryStageDuration(ragBuckets)
+
+	slowRequestThreshold = slowThreshold
+}
+
+// RequestIDHeader carries the request ID in requests and responses
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDPattern limits accepted client request IDs to safe characters
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// traceparentPattern matches a W3C traceparent header, capturing its trace ID
+var traceparentPattern = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-[0-9a-f]{16}-[0-9a-f]{2}$`)
+
+// requestIDsKey keys a request's IDs in its context
+type requestIDsKey struct{}
+
+type requestIDs struct {
+	requestID string
+	traceID   string
+}
+
+// RequestID assigns each request an ID, reusing the client's X-Request-ID when
+// valid, and picks up the trace ID of a W3C traceparent header. Both are
+// stored in the request context and attached to duration metrics as
+// exemplars.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			b := make([]byte, 16)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+
+		var traceID string
+		if match := traceparentPattern.FindStringSubmatch(c.GetHeader("traceparent")); match != nil && strings.Trim(match[1], "0") != "" {
+			traceID = match[1]
+			c.Set("trace_id", traceID)
+		}
+
+		c.Set("request_id", id)
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), requestIDsKey{}, requestIDs{requestID: id, traceID: traceID}))
+		c.Next()
+	}
+}
+
+// RequestIDFrom returns the ID of the request ctx belongs to, or ""
+func RequestIDFrom(ctx context.Context) string {
+	ids, _ := ctx.Value(requestIDsKey{}).(requestIDs)
+	return ids.requestID
+}
+
+// TraceIDFrom returns the trace ID the request ctx belongs to was sent with, or ""
+func TraceIDFrom(ctx context.Context) string {
+	ids, _ := ctx.Value(requestIDsKey{}).(requestIDs)
+	return ids.traceID
+}
+
+// exemplarLabels links an observation to the request ctx belongs to; nil
+// outside a request
+func exemplarLabels(ctx context.Context) prometheus.Labels {
+	ids, ok := ctx.Value(requestIDsKey{}).(requestIDs)
+	if !ok {
+		return nil
+	}
+
+	labels := prometheus.Labels{"request_id": ids.requestID}
+	if ids.traceID != "" {
+		labels["trace_id"] = ids.traceID
+	}
+	return labels
+}
+
+// observe records value, with an exemplar when labels are given
+func observe(observer prometheus.Observer, value float64, labels prometheus.Labels) {
+	if exemplarObserver, ok := observer.(prometheus.ExemplarObserver); ok && len(labels) > 0 {
+		exemplarObserver.ObserveWithExemplar(value, labels)
+		return
+	}
+	observer.Observe(value)
+}
+
+// MaxBodySize caps request bodies at limit bytes; reading past it fails with
+// *http.MaxBytesError. Multipart uploads are left to their handlers' limits.
+func MaxBodySize(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Body != nil && !strings.HasPrefix(c.ContentType(), "multipart/") {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		}
+		c.Next()
+	}
+}
+
+// AuditContext stores the request's actor in the request context so services
+// can attribute audit entries. It must run after AuthMiddleware.
+func AuditContext() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor := audit.Actor{
+			UserID:    c.GetString("user_id"),
+			RequestID: c.GetString("request_id"),
+			IP:        c.ClientIP(),
+		}
+		if actor.UserID == "" {
+			actor.UserID = "anonymous"
+		}
+
+		c.Request = c.Request.WithContext(audit.WithActor(c.Request.Context(), actor))
+		c.Next()
+	}
+}
+
+// Logger middleware for logging requests. Only one in sampleRate requests
+// below 400 is logged; client and server errors are always logged.
+func Logger(sampleRate int) gin.HandlerFunc {
+	var successes atomic.Uint64
 
-// Logger middleware for logging requests
-func Logger() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
 		path := c.Request.URL.Path
@@ -64,18 +601,28 @@ func Logger() gin.HandlerFunc {
 		method := c.Request.Method
 		statusCode := c.Writer.Status()
 
+		if statusCode < http.StatusBadRequest && sampleRate > 1 && successes.Add(1)%uint64(sampleRate) != 1 {
+			return
+		}
+
 		if raw != "" {
 			path = path + "?" + raw
 		}
 
-		logrus.WithFields(logrus.Fields{
+		fields := logrus.Fields{
 			"status":     statusCode,
 			"method":     method,
 			"path":       path,
 			"ip":         clientIP,
 			"latency":    latency,
+			"request_id": c.GetString("request_id"),
+			"trace_id":   c.GetString("trace_id"),
 			"user_agent": c.Request.UserAgent(),
-		}).Info("HTTP request")
+		}
+		if IsPreflight(c.Request) {
+			fields["preflight"] = true
+		}
+		logrus.WithFields(fields).Info("HTTP request")
 	}
 }
 
@@ -85,19 +632,70 @@ func Metrics() gin.HandlerFunc {
 		start := time.Now()
 		path := c.FullPath()
 		method := c.Request.Method
+		if IsPreflight(c.Request) {
+			path = preflightRoute
+		}
 
 		c.Next()
 
-		duration := time.Since(start).Seconds()
+		// Requests abandoned by the client are not counted towards error rates
+		if errors.Is(c.Request.Context().Err(), context.Canceled) {
+			return
+		}
+
+		duration := time.Since(start)
 		status := fmt.Sprintf("%d", c.Writer.Status())
 
 		httpRequestsTotal.WithLabelValues(method, path, status).Inc()
-		httpRequestDuration.WithLabelValues(method, path).Observe(duration)
+		observe(httpRequestDuration.WithLabelValues(method, path), duration.Seconds(), exemplarLabels(c.Request.Context()))
+		if duration > slowRequestThreshold {
+			httpSlowRequestsTotal.WithLabelValues(method, path).Inc()
+		}
 	}
 }
 
-// RateLimiter middleware for rate limiting
-func RateLimiter(requestsPerWindow int, windowSeconds int) gin.HandlerFunc {
+// RateLimitPolicy describes a named request budget and how clients are identified
+type RateLimitPolicy struct {
+	Name     string
+	Requests int
+	Window   time.Duration
+	KeyFunc  func(c *gin.Context) string
+
+	// Current, when set, overrides Requests and Window on every request so
+	// limits can be changed at runtime
+	Current func() (requests int, window time.Duration)
+}
+
+// TrustProxies makes the engine resolve ClientIP from X-Forwarded-For or
+// X-Real-IP, but only on requests whose direct peer is one of the trusted
+// proxies; other requests use the peer address, so the headers can't be
+// spoofed. With no proxies the peer address is always used.
+func TrustProxies(engine *gin.Engine, proxies []string) error {
+	engine.RemoteIPHeaders = []string{"X-Forwarded-For", "X-Real-IP"}
+	return engine.SetTrustedProxies(proxies)
+}
+
+// KeyByClientIP identifies clients by their resolved IP address
+func KeyByClientIP(c *gin.Context) string {
+	return c.ClientIP()
+}
+
+// KeyByUser identifies clients by authenticated user, falling back to IP
+func KeyByUser(c *gin.Context) string {
+	if userID := c.GetString("user_id"); userID != "" {
+		return "user:" + userID
+	}
+	return c.ClientIP()
+}
+
+// RateLimiter middleware for rate limiting. Each policy has its own Redis
+// buckets, so limits on different route groups are independent.
+func RateLimiter(policy RateLimitPolicy) gin.HandlerFunc {
+	keyFunc := policy.KeyFunc
+	if keyFunc == nil {
+		keyFunc = KeyByClientIP
+	}
+
 	return func(c *gin.Context) {
 		// Skip rate limiting if Redis is not initialized
 		if cache.Client == nil {
@@ -105,73 +703,269 @@ func RateLimiter(requestsPerWindow int, windowSeconds int) gin.HandlerFunc {
 			return
 		}
 
-		clientIP := c.ClientIP()
-		key := fmt.Sprintf("ratelimit:%s", clientIP)
-		ctx := context.Background()
+		requests, window := policy.Requests, policy.Window
+		if policy.Current != nil {
+			requests, window = policy.Current()
+		}
+
+		key := fmt.Sprintf("ratelimit:%s:%s", policy.Name, keyFunc(c))
+		ctx := c.Request.Context()
 
-		// Check if key exists
-		exists, err := cache.Exists(ctx, key)
+		count, err := cache.Increment(ctx, key)
 		if err != nil {
-			logrus.WithError(err).Debug("Failed to check rate limit key, skipping")
+			logrus.WithError(err).Debug("Failed to increment rate limit, skipping")
 			c.Next()
 			return
 		}
 
-		if !exists {
-			// First request in window
-			if err := cache.Set(ctx, key, 1, time.Duration(windowSeconds)*time.Second); err != nil {
-				logrus.WithError(err).Debug("Failed to set rate limit, skipping")
+		// First request in window starts the window
+		if count == 1 {
+			if err := cache.Expire(ctx, key, window); err != nil {
+				logrus.WithError(err).Debug("Failed to set rate limit window, skipping")
+			}
+		}
+
+		reset := window
+		if ttl, err := cache.TTL(ctx, key); err == nil && ttl > 0 {
+			reset = ttl
+		}
+
+		remaining := int64(requests) - count
+		if remaining < 0 {
+			remaining = 0
+		}
+
+		c.Header("X-RateLimit-Policy", policy.Name)
+		c.Header("X-RateLimit-Limit", strconv.Itoa(requests))
+		c.Header("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(int(reset.Seconds())))
+
+		if count > int64(requests) {
+			rejectRateLimited(c, requests, window, reset)
+			return
+		}
+
+		c.Set(rateLimitChargeKey, func(extra int) bool {
+			count, err := cache.IncrementBy(ctx, key, int64(extra))
+			if err != nil {
+				logrus.WithError(err).Debug("Failed to charge rate limit, skipping")
+				return true
+			}
+
+			remaining := int64(requests) - count
+			if remaining < 0 {
+				remaining = 0
+			}
+			c.Header("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+			if count > int64(requests) {
+				rejectRateLimited(c, requests, window, reset)
+				return false
+			}
+			return true
+		})
+
+		c.Next()
+	}
+}
+
+// rateLimitChargeKey holds the function charging more requests to the
+// bucket that admitted a request
+const rateLimitChargeKey = "ratelimit_charge"
+
+// ChargeRateLimit counts a request that turned out costlier than usual as
+// extra more requests against the bucket that admitted it. If that exceeds
+// the limit it responds 429 and returns false. Requests that passed no rate
+// limiter, or while Redis is unavailable, aren't charged.
+func ChargeRateLimit(c *gin.Context, extra int) bool {
+	if extra <= 0 {
+		return true
+	}
+	charge, ok := c.Get(rateLimitChargeKey)
+	if !ok {
+		return true
+	}
+	return charge.(func(int) bool)(extra)
+}
+
+// rejectRateLimited responds 429 to a request over its rate limit
+func rejectRateLimited(c *gin.Context, requests int, window, reset time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(reset.Seconds())))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":   "rate_limit_exceeded",
+		"message": fmt.Sprintf("Rate limit exceeded. Maximum %d requests per %d seconds", requests, int(window.Seconds())),
+	})
+	c.Abort()
+}
+
+// corsAllowedMethods are the methods a preflight may ask for
+var corsAllowedMethods = map[string]bool{
+	http.MethodGet:    true,
+	http.MethodPost:   true,
+	http.MethodPut:    true,
+	http.MethodPatch:  true,
+	http.MethodDelete: true,
+}
+
+// corsExposedHeaders are the response headers cross-origin callers may read
+const corsExposedHeaders = "X-RateLimit-Policy, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, Retry-After, Idempotent-Replay, " + RequestIDHeader
+
+// preflightRoute is the route label of CORS preflights in metrics
+const preflightRoute = "preflight"
+
+// IsPreflight reports whether a request is a CORS preflight: an OPTIONS
+// request from an origin naming the method it intends to use
+func IsPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Origin") != "" && r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// CORS middleware for handling CORS. The query API is only exposed
+// cross-origin to allowed origins and origins for which originAllowed returns
+// true; other routes remain open to any origin. Preflights from disallowed
+// origins or for unsupported methods are refused with 403; allowed ones are
+// answered by the OPTIONS route with only the requested method and headers,
+// cacheable for maxAge.
+func CORS(allowedOrigins []string, originAllowed func(ctx context.Context, origin string) bool, maxAge time.Duration) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		allowed[strings.TrimRight(strings.ToLower(origin), "/")] = true
+	}
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		preflight := IsPreflight(c.Request)
+		header := c.Writer.Header()
+
+		if preflight {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if origin != "" && strings.HasPrefix(c.Request.URL.Path, "/api/query") {
+			normalized := strings.TrimRight(strings.ToLower(origin), "/")
+			header.Add("Vary", "Origin")
+			if !allowed[normalized] && (originAllowed == nil || !originAllowed(c.Request.Context(), normalized)) {
+				// Without CORS headers the browser blocks the response
+				if preflight {
+					c.AbortWithStatus(http.StatusForbidden)
+					return
+				}
 				c.Next()
 				return
 			}
-			c.Next()
-			return
+			header.Set("Access-Control-Allow-Origin", origin)
+		} else {
+			header.Set("Access-Control-Allow-Origin", "*")
 		}
+		header.Set("Access-Control-Allow-Credentials", "true")
 
-		// Increment counter
-		count, err := cache.Increment(ctx, key)
-		if err != nil {
-			logrus.WithError(err).Debug("Failed to increment rate limit, skipping")
+		if !preflight {
+			header.Set("Access-Control-Expose-Headers", corsExposedHeaders)
 			c.Next()
 			return
 		}
 
-		if count > int64(requestsPerWindow) {
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error":   "rate_limit_exceeded",
-				"message": fmt.Sprintf("Rate limit exceeded. Maximum %d requests per %d seconds", requestsPerWindow, windowSeconds),
-			})
-			c.Abort()
+		method := strings.ToUpper(strings.TrimSpace(c.GetHeader("Access-Control-Request-Method")))
+		if !corsAllowedMethods[method] {
+			c.AbortWithStatus(http.StatusForbidden)
 			return
 		}
+		header.Set("Access-Control-Allow-Methods", method)
+		if requested := corsRequestedHeaders(c.GetHeader("Access-Control-Request-Headers")); requested != "" {
+			header.Set("Access-Control-Allow-Headers", requested)
+		}
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(maxAge.Seconds())))
 
 		c.Next()
 	}
 }
 
-// CORS middleware for handling CORS
-func CORS() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
+// corsRequestedHeaders echoes the header names a preflight asks to send,
+// dropping anything that isn't a valid header name
+func corsRequestedHeaders(requested string) string {
+	var names []string
+	for _, name := range strings.Split(requested, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" && httpguts.ValidHeaderFieldName(name) {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// Preflight answers OPTIONS requests once the CORS middleware has set the
+// headers of an allowed preflight
+func Preflight(c *gin.Context) {
+	c.Status(http.StatusNoContent)
+}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
+// MetricsAuth protects the metrics endpoint with an optional bearer token and IP allowlist.
+// When both are configured, a request must satisfy both.
+func MetricsAuth(token string, allowed []*net.IPNet) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(allowed) > 0 && !IPAllowed(c.ClientIP(), allowed) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "forbidden",
+				"message": "Access to metrics is not allowed from this address",
+			})
+			c.Abort()
 			return
 		}
 
+		if token != "" {
+			provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error":   "invalid_token",
+					"message": "Invalid or missing metrics token",
+				})
+				c.Abort()
+				return
+			}
+		}
+
 		c.Next()
 	}
 }
 
-// Recovery middleware for recovering from panics
-func Recovery() gin.HandlerFunc {
+// IPAllowed reports whether an IP address falls within any of the allowed networks
+func IPAllowed(ipStr string, allowed []*net.IPNet) bool {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return false
+	}
+
+	for _, ipNet := range allowed {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Recovery middleware for recovering from panics. Panics are logged with
+// their stack trace and, when an error tracker is configured, reported to
+// it; with ERROR_REPORTING_MODE all_5xx so are the errors handlers respond
+// to with a server error. Reports are queued, never delaying the response.
+func Recovery(reporter *errortracking.Reporter) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		defer func() {
 			if err := recover(); err != nil {
-				logrus.WithField("error", err).Error("Panic recovered")
+				stack := debug.Stack()
+				logrus.WithFields(logrus.Fields{
+					"error":      err,
+					"request_id": c.GetString("request_id"),
+					"path":       c.Request.URL.Path,
+					"stack":      string(stack),
+				}).Error("Panic recovered")
+
+				reportError(c, reporter, errortracking.Event{
+					Panic:     true,
+					Message:   fmt.Sprint(err),
+					ErrorType: fmt.Sprintf("%T", err),
+					Stack:     stack,
+					Status:    http.StatusInternalServerError,
+				})
+
 				c.JSON(http.StatusInternalServerError, gin.H{
 					"error":   "internal_server_error",
 					"message": "An unexpected error occurred",
@@ -180,60 +974,461 @@ func Recovery() gin.HandlerFunc {
 			}
 		}()
 		c.Next()
+
+		if reporter.ReportsErrors() && c.Writer.Status() >= http.StatusInternalServerError && len(c.Errors) > 0 {
+			err := c.Errors.Last().Err
+			root := err
+			for errors.Unwrap(root) != nil {
+				root = errors.Unwrap(root)
+			}
+			reportError(c, reporter, errortracking.Event{
+				Message:   err.Error(),
+				ErrorType: fmt.Sprintf("%T", root),
+				Status:    c.Writer.Status(),
+			})
+		}
 	}
 }
 
-// AuthMiddleware validates JWT tokens (optional for this implementation)
-func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
+// reportError fills in an event's request metadata and queues it for the
+// error tracker. Request bodies are never included.
+func reportError(c *gin.Context, reporter *errortracking.Reporter, event errortracking.Event) {
+	if reporter == nil {
+		return
+	}
+
+	event.RequestID = c.GetString("request_id")
+	event.TraceID = c.GetString("trace_id")
+	event.Method = c.Request.Method
+	event.Path = c.Request.URL.Path
+	event.Route = c.FullPath()
+	event.UserID = c.GetString("user_id")
+	event.VisitorID = c.GetString("visitor_id")
+	event.SessionID = c.Param("session_id")
+
+	kind := "error"
+	if event.Panic {
+		kind = "panic"
+	}
+	errorReportCounter.WithLabelValues(kind, reporter.Capture(event)).Inc()
+}
+
+// Roles carried in the role claim of a JWT
+const (
+	RoleAdmin = "admin"
+	RoleAgent = "agent"
+)
+
+// AuthMiddleware validates JWT tokens. Requests without one are let through
+// anonymously unless required is set, which AUTH_ENABLED turns on; a request
+// already authenticated by its signature needs no token either way.
+func AuthMiddleware(jwtSecret string, required bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			// For demo purposes, allow requests without auth
+		if c.GetHeader("Authorization") == "" && (!required || c.GetString("user_id") != "") {
 			c.Next()
 			return
 		}
 
-		bearerToken := strings.Split(authHeader, " ")
-		if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error":   "invalid_token",
-				"message": "Invalid authorization header format",
-			})
+		if _, ok := authenticate(c, jwtSecret); !ok {
 			c.Abort()
 			return
 		}
 
-		tokenString := bearerToken[1]
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(jwtSecret), nil
-		})
+		c.Next()
+	}
+}
 
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error":   "invalid_token",
-				"message": "Invalid or expired token",
-			})
+// RequireRole validates JWT tokens and only lets through callers whose role
+// claim is one of roles, or any authenticated caller if no roles are given.
+// Unlike AuthMiddleware, a token is always required.
+func RequireRole(jwtSecret string, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := authenticate(c, jwtSecret)
+		if !ok {
 			c.Abort()
 			return
 		}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			c.Set("user_id", claims["user_id"])
+		if len(roles) > 0 {
+			role, _ := claims["role"].(string)
+			allowed := false
+			for _, r := range roles {
+				if role == r {
+					allowed = true
+					break
+				}
+			}
+			if !allowed {
+				c.JSON(http.StatusForbidden, gin.H{
+					"error":   "forbidden",
+					"message": "This endpoint requires the " + strings.Join(roles, " or ") + " role",
+				})
+				c.Abort()
+				return
+			}
 		}
 
 		c.Next()
 	}
 }
 
-// RecordCacheHit records a cache hit metric
+// RequireAdmin only lets through callers with an admin token
+func RequireAdmin(jwtSecret string) gin.HandlerFunc {
+	return RequireRole(jwtSecret, RoleAdmin)
+}
+
+// authenticate validates the request's bearer token and stores its claims on
+// the context, responding 401 if it is missing or invalid
+func authenticate(c *gin.Context, jwtSecret string) (jwt.MapClaims, bool) {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "unauthorized",
+			"message": "Authorization header is required",
+		})
+		return nil, false
+	}
+
+	bearerToken := strings.Split(authHeader, " ")
+	if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "invalid_token",
+			"message": "Invalid authorization header format",
+		})
+		return nil, false
+	}
+
+	tokenString := bearerToken[1]
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(jwtSecret), nil
+	})
+
+	if err != nil || !token.Valid {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "invalid_token",
+			"message": "Invalid or expired token",
+		})
+		return nil, false
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		claims = jwt.MapClaims{}
+	}
+	c.Set("user_id", claims["user_id"])
+	// The audience claim decides whether the caller may see internal documents
+	c.Set("audience", claims["audience"])
+	// The plan claim picks the caller's plan tier and its quotas
+	c.Set("plan", claims["plan"])
+	c.Set("role", claims["role"])
+	return claims, true
+}
+
+// RecordCacheHit records a fresh cache hit
 func RecordCacheHit(cacheType string) {
 	cacheHitCounter.WithLabelValues(cacheType).Inc()
+	cacheLookupCounter.WithLabelValues(cacheType, "hit").Inc()
+}
+
+// RecordCacheStaleHit records a hit on an entry past its fresh TTL
+func RecordCacheStaleHit(cacheType string) {
+	cacheHitCounter.WithLabelValues(cacheType).Inc()
+	cacheLookupCounter.WithLabelValues(cacheType, "stale").Inc()
+}
+
+// RecordCacheRefresh records the outcome of a background cache refresh
+func RecordCacheRefresh(cacheType, result string) {
+	cacheRefreshCounter.WithLabelValues(cacheType, result).Inc()
+}
+
+// RecordCacheMiss records a cache miss metric
+func RecordCacheMiss(cacheType string) {
+	cacheLookupCounter.WithLabelValues(cacheType, "miss").Inc()
+}
+
+// RecordCacheBypass records a request that skipped the cache
+func RecordCacheBypass(cacheType, reason string) {
+	cacheLookupCounter.WithLabelValues(cacheType, "bypassed").Inc()
+	cacheBypassCounter.WithLabelValues(cacheType, reason).Inc()
+}
+
+// RecordCacheFeedbackEviction records a cache entry evicted or suppressed because of negative feedback
+func RecordCacheFeedbackEviction(cacheType, action string) {
+	cacheFeedbackEvictionCounter.WithLabelValues(cacheType, action).Inc()
+}
+
+// RecordCacheWrite records an answer written to the cache, fresh for ttl
+func RecordCacheWrite(cacheType string, ttl time.Duration) {
+	cacheWriteCounter.WithLabelValues(cacheType, "written").Inc()
+	cacheWriteTTL.WithLabelValues(cacheType).Observe(ttl.Seconds())
+}
+
+// RecordCacheWriteSkipped records an answer of size bytes left out of the
+// cache because its query is rarely asked
+func RecordCacheWriteSkipped(cacheType string, size int) {
+	cacheWriteCounter.WithLabelValues(cacheType, "skipped").Inc()
+	cacheSkippedBytesCounter.WithLabelValues(cacheType).Add(float64(size))
+}
+
+// RecordRAGDuration records RAG request duration, with the request ctx
+// belongs to as an exemplar
+func RecordRAGDuration(ctx context.Context, duration time.Duration) {
+	observe(ragRequestDuration, duration.Seconds(), exemplarLabels(ctx))
+}
+
+// RecordRAGPhases records the phase timings the RAG service reported, in
+// milliseconds; phases it left out are skipped
+func RecordRAGPhases(retrievalMs, generationMs, timeToFirstTokenMs *int) {
+	for phase, ms := range map[string]*int{
+		"retrieval":           retrievalMs,
+		"generation":          generationMs,
+		"time_to_first_token": timeToFirstTokenMs,
+	} {
+		if ms != nil {
+			ragPhaseDuration.WithLabelValues(phase).Observe(float64(*ms) / 1000)
+		}
+	}
+}
+
+// RecordPromptEstimate compares the tokens a RAG call used with the estimate
+// made before it; calls without either are skipped
+func RecordPromptEstimate(model string, estimated, used int) {
+	if estimated > 0 && used > 0 {
+		promptEstimateRatio.WithLabelValues(model).Observe(float64(used) / float64(estimated))
+	}
+}
+
+// RecordPromptOverBudget records a prompt over the context window that was
+// trimmed or rejected
+func RecordPromptOverBudget(outcome string) {
+	promptRejectedCounter.WithLabelValues(outcome).Inc()
+}
+
+// RecordModerationFlag records a moderation flag for a stage (query or response) and category
+func RecordModerationFlag(stage, category string) {
+	moderationFlagCounter.WithLabelValues(stage, category).Inc()
+}
+
+// SetRAGDegraded records whether the service is in degraded mode
+func SetRAGDegraded(degraded bool) {
+	if degraded {
+		ragDegradedGauge.Set(1)
+	} else {
+		ragDegradedGauge.Set(0)
+	}
+}
+
+// RecordRAGFailover records a query sent to the fallback RAG endpoint
+func RecordRAGFailover(reason string) {
+	ragFailoverCounter.WithLabelValues(reason).Inc()
+}
+
+// RecordQueryStage records how long a stage of the query pipeline took
+func RecordQueryStage(stage string, duration time.Duration) {
+	queryStageDuration.WithLabelValues(stage).Observe(duration.Seconds())
+}
+
+// RecordMalwareScan records the verdict of scanning an upload
+func RecordMalwareScan(verdict string) {
+	malwareScanCounter.WithLabelValues(verdict).Inc()
+}
+
+// RecordSessionClaim records the outcome of an attempt to claim a session
+func RecordSessionClaim(outcome string) {
+	sessionClaimCounter.WithLabelValues(outcome).Inc()
+}
+
+// RecordFeedbackLookupRetry records the outcome of retrying the lookup of
+// a rated query that wasn't found at first
+func RecordFeedbackLookupRetry(outcome string) {
+	feedbackLookupRetryCounter.WithLabelValues(outcome).Inc()
+}
+
+// RecordChaosFault records a fault injected in chaos mode
+func RecordChaosFault(target, faultType string) {
+	chaosFaultCounter.WithLabelValues(target, faultType).Inc()
+}
+
+// RecordQueryPipeline records the pipeline chosen to answer a query and how
+func RecordQueryPipeline(pipeline, method string) {
+	queryPipelineCounter.WithLabelValues(pipeline, method).Inc()
 }
 
-// RecordRAGDuration records RAG request duration
-func RecordRAGDuration(duration time.Duration) {
-	ragRequestDuration.Observe(duration.Seconds())
+// RecordLLMRequest records a direct call to the LLM API
+func RecordLLMRequest(operation, status string) {
+	llmRequestCounter.WithLabelValues(operation, status).Inc()
+}
+
+// RecordResponseLanguage records the outcome of checking an answer's language
+func RecordResponseLanguage(outcome string) {
+	responseLanguageCounter.WithLabelValues(outcome).Inc()
+}
+
+// RecordVerification records an answer grounding verification; score is
+// only observed when the verifier answered
+func RecordVerification(result string, score float64) {
+	verificationCounter.WithLabelValues(result).Inc()
+	if result != "error" {
+		groundingScoreHistogram.Observe(score)
+	}
+}
+
+// RecordGenerationRecovery records how a query picked up an answer
+// generation after a timeout or retry
+func RecordGenerationRecovery(outcome string) {
+	generationRecoveryCounter.WithLabelValues(outcome).Inc()
+}
+
+// RecordModelRouting records the model a query was routed to; source is rule or default
+func RecordModelRouting(model, source string) {
+	modelRoutingCounter.WithLabelValues(model, source).Inc()
+}
+
+// SetRAGQueue records the number of running and queued RAG generations
+func SetRAGQueue(inFlight, queued int) {
+	ragInFlightGauge.Set(float64(inFlight))
+	ragQueueDepthGauge.Set(float64(queued))
+}
+
+// RecordRAGShed records a request rejected because RAG capacity was exhausted
+func RecordRAGShed(reason string) {
+	ragShedCounter.WithLabelValues(reason).Inc()
+}
+
+// RecordRAGUpstreamRateLimited records a 429 from the RAG service and whether it was retried
+func RecordRAGUpstreamRateLimited(outcome string) {
+	ragUpstreamRateLimitedCounter.WithLabelValues(outcome).Inc()
+}
+
+// RecordCoalescedQuery records a query that shared another caller's RAG call
+func RecordCoalescedQuery() {
+	ragCoalescedCounter.Inc()
+}
+
+// dbWaitSeen holds the cumulative pool wait stats already exported, so the
+// counters only advance by what is new since the last collection
+var dbWaitSeen struct {
+	sync.Mutex
+	count    int64
+	duration time.Duration
+}
+
+// SetDraining exports whether the instance is draining ahead of shutdown
+func SetDraining(draining bool) {
+	if draining {
+		serverDrainingGauge.Set(1)
+	} else {
+		serverDrainingGauge.Set(0)
+	}
+}
+
+// SetDBPoolStats exports a snapshot of the database connection pool
+func SetDBPoolStats(stats sql.DBStats) {
+	dbConnectionsGauge.WithLabelValues("open").Set(float64(stats.OpenConnections))
+	dbConnectionsGauge.WithLabelValues("in_use").Set(float64(stats.InUse))
+	dbConnectionsGauge.WithLabelValues("idle").Set(float64(stats.Idle))
+	dbConnectionsGauge.WithLabelValues("max_open").Set(float64(stats.MaxOpenConnections))
+
+	dbWaitSeen.Lock()
+	defer dbWaitSeen.Unlock()
+	// The stats reset when the pool is reopened; start over from the new totals
+	if stats.WaitCount < dbWaitSeen.count || stats.WaitDuration < dbWaitSeen.duration {
+		dbWaitSeen.count, dbWaitSeen.duration = 0, 0
+	}
+	dbWaitCounter.Add(float64(stats.WaitCount - dbWaitSeen.count))
+	dbWaitDurationCounter.Add((stats.WaitDuration - dbWaitSeen.duration).Seconds())
+	dbWaitSeen.count, dbWaitSeen.duration = stats.WaitCount, stats.WaitDuration
+}
+
+// RecordDBSlowQuery records a query that exceeded the slow query threshold
+func RecordDBSlowQuery() {
+	dbSlowQueryCounter.Inc()
+}
+
+// SetDeadLetterBacklog exports the pending dead letters per operation;
+// operations missing from counts have none
+func SetDeadLetterBacklog(counts map[string]int64) {
+	deadLetterBacklogGauge.Reset()
+	for operation, count := range counts {
+		deadLetterBacklogGauge.WithLabelValues(operation).Set(float64(count))
+	}
+}
+
+// SetActivitySubscribers records the number of open activity feed connections
+func SetActivitySubscribers(n int) {
+	activitySubscribersGauge.Set(float64(n))
+}
+
+// RecordActivityEventDropped records an activity event dropped for a slow feed connection
+func RecordActivityEventDropped() {
+	activityDroppedCounter.Inc()
+}
+
+// AbuseGuard rejects banned sessions and IPs, then records the query so that
+// floods and probing trigger temporary bans. It peeks at the JSON body for the
+// session ID and query without consuming it.
+func AbuseGuard(detector *abuse.Detector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Skip abuse detection if Redis is not initialized
+		if cache.Client == nil {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		ip := c.ClientIP()
+
+		var body struct {
+			Query     string `json:"query"`
+			SessionID string `json:"session_id"`
+		}
+		if c.Request.Body != nil {
+			data, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+			if err == nil {
+				json.Unmarshal(data, &body)
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(data))
+		}
+
+		for _, subject := range []struct{ kind, value string }{
+			{abuse.KindIP, ip},
+			{abuse.KindSession, body.SessionID},
+		} {
+			ban, err := detector.IsBanned(ctx, subject.kind, subject.value)
+			if err != nil {
+				logrus.WithError(err).Debug("Failed to check ban, skipping")
+				continue
+			}
+			if ban != nil {
+				abuseBlockedCounter.WithLabelValues(ban.Kind).Inc()
+				c.Header("Retry-After", strconv.Itoa(int(time.Until(ban.ExpiresAt).Seconds())+1))
+				c.JSON(http.StatusForbidden, gin.H{
+					"error":      "temporarily_banned",
+					"message":    "Too many suspicious requests. Please try again later.",
+					"expires_at": ban.ExpiresAt,
+				})
+				c.Abort()
+				return
+			}
+		}
+
+		bans, err := detector.Observe(ctx, body.SessionID, ip, body.Query)
+		if err != nil {
+			logrus.WithError(err).Debug("Failed to record query for abuse detection")
+		}
+		for _, ban := range bans {
+			abuseBanCounter.WithLabelValues(ban.Kind).Inc()
+			logrus.WithFields(logrus.Fields{
+				"kind":       ban.Kind,
+				"value":      ban.Value,
+				"reason":     ban.Reason,
+				"expires_at": ban.ExpiresAt,
+			}).Warn("Temporarily banned abusive client")
+		}
+
+		c.Next()
+	}
 }
@@ -0,0 +1,229 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testJWTSecret = "test-secret"
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// signToken signs claims with testJWTSecret
+func signToken(t *testing.T, claims jwt.MapClaims) string {
+	return signTokenWith(t, testJWTSecret, claims)
+}
+
+func signTokenWith(t *testing.T, secret string, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return token
+}
+
+// serve runs a request with the given Authorization header through handlers
+// ending in one that answers 200
+func serve(authorization string, handlers ...gin.HandlerFunc) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.GET("/", append(handlers, func(c *gin.Context) { c.Status(http.StatusOK) })...)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	valid := "Bearer " + signToken(t, jwt.MapClaims{"user_id": "u1"})
+	tests := []struct {
+		name          string
+		required      bool
+		authorization string
+		want          int
+	}{
+		{"anonymous allowed", false, "", http.StatusOK},
+		{"anonymous rejected when required", true, "", http.StatusUnauthorized},
+		{"valid token", true, valid, http.StatusOK},
+		{"malformed header", false, "Token abc", http.StatusUnauthorized},
+		{"wrong secret", false, "Bearer " + signTokenWith(t, "other-secret", jwt.MapClaims{"user_id": "u1"}), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(tt.authorization, AuthMiddleware(testJWTSecret, tt.required))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareAcceptsSignedRequests(t *testing.T) {
+	signed := func(c *gin.Context) { c.Set("user_id", "key:partner") }
+	if w := serve("", signed, AuthMiddleware(testJWTSecret, true)); w.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	tests := []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{"anonymous", "", http.StatusUnauthorized},
+		{"invalid token", "Bearer not-a-token", http.StatusUnauthorized},
+		{"user token", "Bearer " + signToken(t, jwt.MapClaims{"user_id": "u1"}), http.StatusForbidden},
+		{"agent token", "Bearer " + signToken(t, jwt.MapClaims{"user_id": "u1", "role": RoleAgent}), http.StatusForbidden},
+		{"admin token", "Bearer " + signToken(t, jwt.MapClaims{"user_id": "u1", "role": RoleAdmin}), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(tt.authorization, RequireAdmin(testJWTSecret))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireRoleAnyAuthenticated(t *testing.T) {
+	if w := serve("", RequireRole(testJWTSecret)); w.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous: status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	token := "Bearer " + signToken(t, jwt.MapClaims{"user_id": "u1"})
+	if w := serve(token, RequireRole(testJWTSecret)); w.Code != http.StatusOK {
+		t.Errorf("authenticated: status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if w := serve(token, RequireRole(testJWTSecret, RoleAdmin, RoleAgent)); w.Code != http.StatusForbidden {
+		t.Errorf("no role: status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+// mustParseCIDRs parses CIDR blocks for tests
+func mustParseCIDRs(t *testing.T, values ...string) []*net.IPNet {
+	t.Helper()
+	nets := make([]*net.IPNet, len(values))
+	for i, value := range values {
+		_, ipNet, err := net.ParseCIDR(value)
+		if err != nil {
+			t.Fatalf("invalid CIDR %q: %v", value, err)
+		}
+		nets[i] = ipNet
+	}
+	return nets
+}
+
+func TestIPAllowed(t *testing.T) {
+	allowed := mustParseCIDRs(t, "10.0.0.0/8", "192.168.1.10/32", "2001:db8::/32", "fd00::/8")
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.20.30.40", true},
+		{"11.0.0.1", false},
+		{"192.168.1.10", true},
+		{"192.168.1.11", false},
+		{"2001:db8:1::5", true},
+		{"2001:db9::1", false},
+		{"fd12:3456::1", true},
+		{"::ffff:10.1.1.1", true}, // IPv4-mapped addresses match their IPv4 block
+		{"::1", false},
+		{"", false},
+		{"not-an-ip", false},
+	}
+	for _, tt := range tests {
+		if got := IPAllowed(tt.ip, allowed); got != tt.want {
+			t.Errorf("IPAllowed(%q) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestMetricsAuth(t *testing.T) {
+	loopback := mustParseCIDRs(t, "127.0.0.0/8")
+	others := mustParseCIDRs(t, "10.0.0.0/8")
+
+	tests := []struct {
+		name          string
+		token         string
+		allowed       []*net.IPNet
+		authorization string
+		want          int
+	}{
+		{"open", "", nil, "", http.StatusOK},
+		{"token required", "secret", nil, "", http.StatusUnauthorized},
+		{"wrong token", "secret", nil, "Bearer nope", http.StatusUnauthorized},
+		{"right token", "secret", nil, "Bearer secret", http.StatusOK},
+		{"address allowed", "", loopback, "", http.StatusOK},
+		{"address not allowed", "", others, "", http.StatusForbidden},
+		{"both required", "secret", loopback, "", http.StatusUnauthorized},
+		{"both satisfied", "secret", loopback, "Bearer secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// httptest requests come from 192.0.2.1; serve from loopback instead
+			fromLoopback := func(c *gin.Context) { c.Request.RemoteAddr = "127.0.0.1:1234" }
+			w := serve(tt.authorization, fromLoopback, MetricsAuth(tt.token, tt.allowed))
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrustProxies(t *testing.T) {
+	tests := []struct {
+		name       string
+		proxies    []string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{"no proxies ignores headers", nil, "203.0.113.7:4000", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "203.0.113.7"},
+		{"trusted peer", []string{"10.0.0.0/8"}, "10.0.0.5:4000", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+		{"untrusted peer can't spoof", []string{"10.0.0.0/8"}, "203.0.113.7:4000", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "203.0.113.7"},
+		{"real ip header", []string{"10.0.0.0/8"}, "10.0.0.5:4000", map[string]string{"X-Real-IP": "198.51.100.2"}, "198.51.100.2"},
+		// The rightmost untrusted hop is the client; entries a client added
+		// before reaching the proxy are ignored
+		{"chain through trusted proxies", []string{"10.0.0.0/8"}, "10.0.0.5:4000", map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.1, 10.0.0.9"}, "198.51.100.1"},
+		{"ipv6 trusted peer", []string{"fd00::/8"}, "[fd00::5]:4000", map[string]string{"X-Forwarded-For": "2001:db8::1"}, "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			if err := TrustProxies(router, tt.proxies); err != nil {
+				t.Fatalf("TrustProxies: %v", err)
+			}
+			var key string
+			router.GET("/", func(c *gin.Context) { key = KeyByClientIP(c) })
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			if key != tt.want {
+				t.Errorf("client IP = %q, want %q", key, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrustProxiesRejectsInvalid(t *testing.T) {
+	if err := TrustProxies(gin.New(), []string{"10.0.0.0/99"}); err == nil {
+		t.Error("TrustProxies accepted an invalid CIDR")
+	}
+}
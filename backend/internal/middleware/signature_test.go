@@ -0,0 +1,203 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/cache"
+	"github.com/ai-support-assistant/backend/pkg/client"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+var signingKeys = map[string]string{"partner": "partner-secret"}
+
+// useReplayRedis points cache.Client at an in-memory Redis that only knows
+// the SET NX the replay check needs, until the test ends
+func useReplayRedis(t *testing.T) {
+	t.Helper()
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+			args := make([]string, n)
+			for i := range args {
+				if _, err := r.ReadString('\n'); err != nil {
+					return
+				}
+				if args[i], err = r.ReadString('\n'); err != nil {
+					return
+				}
+				args[i] = strings.TrimSuffix(args[i], "\r\n")
+			}
+
+			reply := "+OK\r\n"
+			if strings.EqualFold(args[0], "SET") {
+				mu.Lock()
+				if seen[args[1]] {
+					reply = "$-1\r\n"
+				}
+				seen[args[1]] = true
+				mu.Unlock()
+			} else if strings.EqualFold(args[0], "PING") {
+				reply = "+PONG\r\n"
+			}
+			if _, err := io.WriteString(conn, reply); err != nil {
+				return
+			}
+		}
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Dialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			server, conn := net.Pipe()
+			go serve(server)
+			return conn, nil
+		},
+	})
+	previous := cache.Client
+	cache.Client = rdb
+	t.Cleanup(func() {
+		cache.Client = previous
+		rdb.Close()
+	})
+}
+
+// signedRouter serves POST /api/query behind RequestSignature, echoing the
+// attributed user and the body the handler read
+func signedRouter(required bool) *gin.Engine {
+	router := gin.New()
+	router.POST("/api/query", RequestSignature(signingKeys, time.Minute, required), func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.JSON(http.StatusOK, gin.H{"user_id": c.GetString("user_id"), "body": string(body)})
+	})
+	return router
+}
+
+// signedQuery builds a request to /api/query signed by the client SDK
+func signedQuery(keyID, secret, body string, at time.Time) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/query", strings.NewReader(body))
+	client.SignRequest(req, keyID, secret, []byte(body), at)
+	return req
+}
+
+func serveSigned(router http.Handler, req *http.Request) (int, map[string]string) {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var got map[string]string
+	json.Unmarshal(w.Body.Bytes(), &got)
+	return w.Code, got
+}
+
+func TestRequestSignatureAcceptsClientSignature(t *testing.T) {
+	useReplayRedis(t)
+	body := `{"query":"Where is my order?"}`
+
+	code, got := serveSigned(signedRouter(true), signedQuery("partner", "partner-secret", body, time.Now()))
+	if code != http.StatusOK {
+		t.Fatalf("status = %d (%v), want 200", code, got)
+	}
+	if got["user_id"] != "key:partner" || got["body"] != body {
+		t.Errorf("handler saw %v, want the key's identity and the original body", got)
+	}
+}
+
+func TestRequestSignatureRejections(t *testing.T) {
+	body := `{"query":"Where is my order?"}`
+	tests := []struct {
+		name     string
+		request  func() *http.Request
+		required bool
+		status   int
+		code     string
+	}{
+		{"unsigned when required", func() *http.Request {
+			return httptest.NewRequest(http.MethodPost, "/api/query", strings.NewReader(body))
+		}, true, http.StatusUnauthorized, "signature_required"},
+		{"unknown key", func() *http.Request {
+			return signedQuery("stranger", "partner-secret", body, time.Now())
+		}, false, http.StatusUnauthorized, "invalid_signature"},
+		{"wrong secret", func() *http.Request {
+			return signedQuery("partner", "guessed-secret", body, time.Now())
+		}, false, http.StatusUnauthorized, "invalid_signature"},
+		{"tampered body", func() *http.Request {
+			req := signedQuery("partner", "partner-secret", body, time.Now())
+			req.Body = io.NopCloser(strings.NewReader(`{"query":"Refund everything"}`))
+			return req
+		}, false, http.StatusUnauthorized, "invalid_signature"},
+		{"malformed timestamp", func() *http.Request {
+			req := signedQuery("partner", "partner-secret", body, time.Now())
+			req.Header.Set(client.HeaderTimestamp, "yesterday")
+			return req
+		}, false, http.StatusUnauthorized, "invalid_timestamp"},
+		{"timestamp too old", func() *http.Request {
+			return signedQuery("partner", "partner-secret", body, time.Now().Add(-2*time.Minute))
+		}, false, http.StatusUnauthorized, "timestamp_expired"},
+		{"timestamp in the future", func() *http.Request {
+			return signedQuery("partner", "partner-secret", body, time.Now().Add(2*time.Minute))
+		}, false, http.StatusUnauthorized, "timestamp_expired"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useReplayRedis(t)
+			code, got := serveSigned(signedRouter(tt.required), tt.request())
+			if code != tt.status || got["error"] != tt.code {
+				t.Errorf("response = %d %v, want %d %s", code, got, tt.status, tt.code)
+			}
+		})
+	}
+}
+
+func TestRequestSignatureAllowsSkewWithinWindow(t *testing.T) {
+	useReplayRedis(t)
+	router := signedRouter(true)
+	for _, offset := range []time.Duration{-50 * time.Second, 50 * time.Second} {
+		if code, got := serveSigned(router, signedQuery("partner", "partner-secret", "{}", time.Now().Add(offset))); code != http.StatusOK {
+			t.Errorf("clock %v off: %d %v, want 200", offset, code, got)
+		}
+	}
+}
+
+func TestRequestSignatureRejectsReplay(t *testing.T) {
+	useReplayRedis(t)
+	router := signedRouter(true)
+	body := `{"query":"Where is my order?"}`
+	at := time.Now()
+
+	if code, got := serveSigned(router, signedQuery("partner", "partner-secret", body, at)); code != http.StatusOK {
+		t.Fatalf("first request = %d %v, want 200", code, got)
+	}
+	code, got := serveSigned(router, signedQuery("partner", "partner-secret", body, at))
+	if code != http.StatusUnauthorized || got["error"] != "request_replayed" {
+		t.Errorf("replayed request = %d %v, want 401 request_replayed", code, got)
+	}
+
+	// A retry signed with a later timestamp is a new request
+	if code, got := serveSigned(router, signedQuery("partner", "partner-secret", body, at.Add(time.Second))); code != http.StatusOK {
+		t.Errorf("re-signed retry = %d %v, want 200", code, got)
+	}
+}
+
+func TestRequestSignatureOptional(t *testing.T) {
+	code, got := serveSigned(signedRouter(false), httptest.NewRequest(http.MethodPost, "/api/query", strings.NewReader("{}")))
+	if code != http.StatusOK || got["user_id"] != "" {
+		t.Errorf("unsigned request = %d %v, want it passed on unattributed", code, got)
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Timeout bounds each request with a deadline on its context. Services derive
+// their database and upstream calls from the request context, so an expired
+// deadline cancels them and the handler responds with a timeout error. A
+// handler that returns after the deadline without responding gets a 504.
+// A timeout of zero or less disables the deadline.
+func Timeout(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return
+		}
+		requestTimeoutCounter.WithLabelValues(c.FullPath()).Inc()
+
+		if !c.Writer.Written() {
+			c.AbortWithStatusJSON(http.StatusGatewayTimeout, gin.H{
+				"error":   "timeout",
+				"message": "The request timed out. Please try again.",
+			})
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// timeoutRouter serves GET / behind Timeout with handler
+func timeoutRouter(timeout time.Duration, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.GET("/", Timeout(timeout), handler)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	return w
+}
+
+func TestTimeoutHandlerFinishesInTime(t *testing.T) {
+	w := timeoutRouter(time.Second, func(c *gin.Context) {
+		if _, ok := c.Request.Context().Deadline(); !ok {
+			t.Error("request context has no deadline")
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", w.Code)
+	}
+}
+
+func TestTimeoutCancelsUpstreamCall(t *testing.T) {
+	cancelled := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			close(cancelled)
+		case <-time.After(5 * time.Second):
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer upstream.Close()
+
+	start := time.Now()
+	w := timeoutRouter(50*time.Millisecond, func(c *gin.Context) {
+		req, _ := http.NewRequestWithContext(c.Request.Context(), http.MethodPost, upstream.URL, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			t.Error("upstream call outlived the request deadline")
+		}
+	})
+
+	if w.Code != http.StatusGatewayTimeout || !strings.Contains(w.Body.String(), `"error":"timeout"`) {
+		t.Errorf("response = %d %s, want 504 timeout", w.Code, w.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("request took %v, want it cut off at the deadline", elapsed)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(2 * time.Second):
+		t.Error("upstream never saw the request cancelled")
+	}
+}
+
+func TestTimeoutKeepsHandlerResponse(t *testing.T) {
+	// A handler that maps the expired context to its own error response keeps it
+	w := timeoutRouter(10*time.Millisecond, func(c *gin.Context) {
+		<-c.Request.Context().Done()
+		if errors.Is(c.Request.Context().Err(), context.DeadlineExceeded) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "overloaded"})
+		}
+	})
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want the handler's 503", w.Code)
+	}
+}
+
+func TestTimeoutDisabled(t *testing.T) {
+	w := timeoutRouter(0, func(c *gin.Context) {
+		if _, ok := c.Request.Context().Deadline(); ok {
+			t.Error("request context has a deadline with the timeout disabled")
+		}
+		c.Status(http.StatusNoContent)
+	})
+	if w.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want 204", w.Code)
+	}
+}
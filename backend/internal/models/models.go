@@ -1,23 +1,124 @@
 package models
 
 import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
+
+	// Also registers the "encrypted" serializer used by conversation content columns
+	"github.com/ai-support-assistant/backend/internal/crypto"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // ChatQuery represents a user query to the system
 type ChatQuery struct {
-	ID         uint      `gorm:"primaryKey" json:"id"`
-	SessionID  string    `gorm:"index;not null" json:"session_id"`
-	UserID     string    `gorm:"index" json:"user_id,omitempty"`
-	Query      string    `gorm:"type:text;not null" json:"query"`
-	Response   string    `gorm:"type:text" json:"response"`
-	Context    string    `gorm:"type:text" json:"context,omitempty"`
-	Model      string    `gorm:"type:varchar(100)" json:"model"`
-	TokensUsed int       `json:"tokens_used"`
-	LatencyMs  int       `json:"latency_ms"`
-	CacheHit   bool      `json:"cache_hit"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID                   uint           `gorm:"primaryKey" json:"id"`
+	SessionID            string         `gorm:"index;not null" json:"session_id"`
+	UserID               string         `gorm:"index" json:"user_id,omitempty"`
+	VisitorID            string         `gorm:"type:varchar(32);index" json:"-"` // pseudonymous, rotating fingerprint of the client
+	Query                string         `gorm:"type:text;not null;serializer:encrypted" json:"query"`
+	QueryHash            string         `gorm:"type:varchar(64);index" json:"-"` // digest of the normalized query, used for grouping
+	Response             string         `gorm:"type:text;serializer:encrypted" json:"response"`
+	Context              string         `gorm:"type:text;serializer:encrypted" json:"context,omitempty"`
+	Model                string         `gorm:"type:varchar(100)" json:"model"`
+	Language             string         `gorm:"type:varchar(10);index" json:"language,omitempty"`
+	PromptTemplate       string         `gorm:"type:varchar(100);index" json:"prompt_template,omitempty"`
+	PromptVersion        int            `json:"prompt_version,omitempty"`
+	ExperimentID         *uint          `gorm:"index" json:"experiment_id,omitempty"`
+	ExperimentVariant    string         `gorm:"type:varchar(50)" json:"experiment_variant,omitempty"`
+	Metadata             *QueryMetadata `gorm:"type:jsonb" json:"metadata,omitempty"`
+	TokensUsed           int            `json:"tokens_used"`
+	TokensIn             int            `gorm:"not null;default:0" json:"tokens_in,omitempty"`          // prompt tokens; estimated when the RAG service only reports the total
+	TokensOut            int            `gorm:"not null;default:0" json:"tokens_out,omitempty"`         // completion tokens
+	Plan                 string         `gorm:"type:varchar(50);index" json:"plan,omitempty"`           // the caller's plan tier
+	EstimatedTokens      int            `json:"estimated_tokens,omitempty"`                             // prompt estimate made before calling the RAG service, to compare with tokens_used
+	RAGEndpoint          string         `gorm:"type:varchar(20);index" json:"rag_endpoint,omitempty"`   // primary or fallback, when the RAG service answered
+	Pipeline             string         `gorm:"type:varchar(10);index;default:'rag'" json:"pipeline"`   // rag, or direct when the LLM answered without retrieval
+	RoutingRuleID        *uint          `gorm:"index" json:"routing_rule_id,omitempty"`                 // the rule that picked the model, if any
+	PersonaID            *uint          `gorm:"index" json:"persona_id,omitempty"`                      // the persona the answer was given in, if any
+	PersonaVersion       int            `json:"persona_version,omitempty"`                              // the persona's version at the time
+	GroundingScore       *float64       `json:"grounding_score,omitempty"`                              // how well the answer is supported by its context, 0 to 1; nil unless verified
+	UnsupportedCount     int            `json:"unsupported_count,omitempty"`                            // sentences of the answer the context doesn't support
+	LowConfidence        bool           `gorm:"not null;default:false" json:"low_confidence,omitempty"` // the answer was replaced for being poorly grounded
+	NoContext            bool           `gorm:"not null;default:false" json:"no_context,omitempty"`     // retrieval found nothing; false for queries recorded before it was tracked
+	Partial              bool           `gorm:"not null;default:false" json:"partial,omitempty"`        // cut short by a RAG timeout
+	ResponseLanguage     string         `gorm:"type:varchar(10)" json:"response_language,omitempty"`    // language the answer was asked for; empty when left to the RAG service
+	Translated           bool           `gorm:"not null;default:false" json:"translated,omitempty"`     // the answer came back in another language and was translated
+	AttachmentCount      int            `gorm:"not null;default:0" json:"attachment_count,omitempty"`   // images sent with the query
+	AttachmentBytes      int64          `gorm:"not null;default:0" json:"attachment_bytes,omitempty"`   // total size of the images
+	AttachmentHash       string         `gorm:"type:varchar(64)" json:"attachment_hash,omitempty"`      // digest of the images, in order
+	FeedbackRequested    bool           `gorm:"index;not null;default:false" json:"feedback_requested"` // the user was asked to rate the answer; false for queries recorded before sampling
+	LatencyMs            int            `json:"latency_ms"`
+	CacheHit             bool           `json:"cache_hit"`
+	CacheBypassed        bool           `json:"cache_bypassed"`
+	CacheKey             string         `gorm:"type:varchar(64);index" json:"-"`                    // response cache entry holding the answer, if it was cached
+	RequestID            string         `gorm:"type:varchar(64);index" json:"request_id,omitempty"` // the request that asked, for finding its log entries
+	ModerationFlag       bool           `gorm:"index" json:"moderation_flag"`
+	ModerationCategories string         `gorm:"type:varchar(500)" json:"moderation_categories,omitempty"` // comma-separated categories
+	Segment              int            `gorm:"not null;default:0" json:"segment"`                        // conversation segment within the session; see Session.Segment
+	Synthetic            bool           `gorm:"index;not null;default:false" json:"-"`                    // generated internally, e.g. by cache warm-up; left out of analytics
+	ParentQueryID        *uint          `gorm:"index" json:"parent_query_id,omitempty"`                   // the original query, when this is a regenerated answer
+	SearchVector         SearchVector   `gorm:"type:tsvector;->:false;<-:create" json:"-"`                // full-text index over query and response
+	CreatedAt            time.Time      `json:"created_at"`
+	UpdatedAt            time.Time      `json:"updated_at"`
+
+	PhaseTimings
+}
+
+// QueryAttachmentRecord keeps an image sent with a query, only while
+// ATTACHMENT_RETENTION is enabled. The image is stored base64 encoded so it
+// can be encrypted at rest like the rest of the conversation.
+type QueryAttachmentRecord struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	QueryID     uint      `gorm:"index;not null" json:"query_id"`
+	Position    int       `gorm:"not null" json:"position"` // order within the query, from 0
+	FileName    string    `gorm:"type:varchar(255)" json:"file_name,omitempty"`
+	ContentType string    `gorm:"type:varchar(50)" json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `gorm:"type:varchar(64)" json:"sha256"`
+	Data        string    `gorm:"type:text;serializer:encrypted" json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// PhaseTimings break the latency of a RAG call down by phase, in
+// milliseconds. A phase is nil when the RAG service didn't report it, so it
+// is left out of statistics rather than counted as zero.
+type PhaseTimings struct {
+	RetrievalMs        *int `json:"retrieval_ms,omitempty"`
+	GenerationMs       *int `json:"generation_ms,omitempty"`
+	TimeToFirstTokenMs *int `json:"time_to_first_token_ms,omitempty"` // only reported for streamed answers
+}
+
+// BeforeCreate fills the search vector from the plaintext. It stays empty
+// while encryption at rest is enabled, so the index can't reveal what the
+// encrypted columns protect.
+func (q *ChatQuery) BeforeCreate(tx *gorm.DB) error {
+	if crypto.Active() == nil {
+		q.SearchVector = SearchVector(q.Query + "\n" + q.Response)
+	}
+	return nil
+}
+
+// SearchConfig is the Postgres text search configuration used to index and
+// query conversations
+const SearchConfig = "english"
+
+// SearchVector is the text of a tsvector column. It is written through
+// to_tsvector and is never read back.
+type SearchVector string
+
+// GormValue converts the text to a tsvector. Other dialects have no tsvector
+// and store NULL.
+func (v SearchVector) GormValue(ctx context.Context, tx *gorm.DB) clause.Expr {
+	if v == "" || tx.Dialector.Name() != "postgres" {
+		return clause.Expr{SQL: "NULL"}
+	}
+	return clause.Expr{SQL: "to_tsvector(?::regconfig, ?::text)", Vars: []interface{}{SearchConfig, string(v)}}
 }
 
 // Feedback represents user feedback on a response
@@ -25,12 +126,46 @@ type Feedback struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
 	QueryID   uint      `gorm:"index;not null" json:"query_id"`
 	SessionID string    `gorm:"index" json:"session_id"`
+	UserID    string    `gorm:"type:varchar(255);index;default:''" json:"user_id,omitempty"`
 	Score     int       `gorm:"not null" json:"score"` // 1 for thumbs up, -1 for thumbs down
-	Comment   string    `gorm:"type:text" json:"comment,omitempty"`
+	Comment   string    `gorm:"type:text;serializer:encrypted" json:"comment,omitempty"`
 	Tags      string    `gorm:"type:varchar(500)" json:"tags,omitempty"` // JSON array of tags
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	Query     ChatQuery `gorm:"foreignKey:QueryID" json:"query,omitempty"`
+
+	// Theme is set once a negative comment has been classified
+	Theme        string     `gorm:"type:varchar(50);default:'';index" json:"theme,omitempty"`
+	ClassifiedAt *time.Time `json:"classified_at,omitempty"`
+
+	// Source is user for ratings given in the widget and import for survey
+	// responses imported in bulk; ExternalID is the survey tool's response ID
+	Source     string  `gorm:"type:varchar(20);not null;default:'user';index" json:"source"`
+	ExternalID *string `gorm:"type:varchar(255);uniqueIndex" json:"external_id,omitempty"`
+}
+
+// Feedback sources
+const (
+	FeedbackSourceUser   = "user"
+	FeedbackSourceImport = "import"
+)
+
+// Annotation is an agent's correction of an answer. Once approved, the
+// question and corrected answer are ingested as a correction document and
+// answer exact repeats of the question.
+type Annotation struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	QueryID         uint       `gorm:"index;not null" json:"query_id"`
+	Question        string     `gorm:"type:text;not null;serializer:encrypted" json:"question"` // the original query, copied so approved corrections can be matched
+	CorrectedAnswer string     `gorm:"type:text;not null;serializer:encrypted" json:"corrected_answer"`
+	Author          string     `gorm:"type:varchar(200)" json:"author"`
+	Status          string     `gorm:"type:varchar(20);default:'draft';index" json:"status"` // draft or approved
+	ApprovedBy      string     `gorm:"type:varchar(200)" json:"approved_by,omitempty"`
+	ApprovedAt      *time.Time `json:"approved_at,omitempty"`
+	DocumentID      *uint      `json:"document_id,omitempty"` // correction document ingested on approval
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	Query           ChatQuery  `gorm:"foreignKey:QueryID" json:"query,omitempty"`
 }
 
 // Document represents an uploaded document
@@ -41,11 +176,791 @@ type Document struct {
 	FileSize      int64     `json:"file_size"`
 	FilePath      string    `gorm:"type:varchar(1000)" json:"file_path"`
 	VectorStoreID string    `gorm:"type:varchar(200)" json:"vector_store_id,omitempty"`
-	Status        string    `gorm:"type:varchar(50);default:'pending'" json:"status"` // pending, processing, completed, failed
+	Status        string    `gorm:"type:varchar(50);default:'pending'" json:"status"` // pending, queued, processing, completed, failed, superseded
 	ChunkCount    int       `json:"chunk_count"`
 	UploadedBy    string    `gorm:"type:varchar(200)" json:"uploaded_by,omitempty"`
+	CollectionID  *uint     `gorm:"index" json:"collection_id,omitempty"`
+	SourceURL     string    `gorm:"type:varchar(2048);index" json:"source_url,omitempty"` // set for crawled pages
+	CrawlJobID    *uint     `gorm:"index" json:"crawl_job_id,omitempty"`
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
+
+	// Uploads sharing a DocumentKey within a collection are versions of one
+	// logical document; a completed upload supersedes the older versions
+	DocumentKey    string `gorm:"type:varchar(200);index" json:"document_key,omitempty"`
+	Version        int    `gorm:"default:1" json:"version"`
+	SupersededByID *uint  `json:"superseded_by_id,omitempty"`
+
+	// Progress of documents streamed from object storage, and why ingestion
+	// failed, e.g. source_unreachable
+	BytesRead    int64  `json:"bytes_read,omitempty"`
+	ErrorCode    string `gorm:"type:varchar(50)" json:"error_code,omitempty"`
+	ErrorMessage string `gorm:"type:varchar(500)" json:"error_message,omitempty"`
+
+	// Ingestion progress as reported by the RAG service. ProgressPercent
+	// stays nil while progress is indeterminate, e.g. the service doesn't
+	// report it or hasn't finished chunking the document.
+	ProgressPercent *int `json:"progress_percent"`
+	ChunksProcessed int  `json:"chunks_processed,omitempty"`
+	ChunksTotal     int  `json:"chunks_total,omitempty"`
+
+	// Visibility is public or internal; internal documents are only
+	// retrieved for agent-audience queries
+	Visibility string `gorm:"type:varchar(20);default:'public';index" json:"visibility"`
+
+	// IngestOptions are the options the document was ingested with, reused
+	// when it is ingested again. Documents from before options were recorded
+	// have a zero chunk size and were ingested with the RAG service defaults.
+	IngestOptions IngestOptions `gorm:"embedded" json:"ingest_options"`
+
+	// Text of plain-text uploads (txt, md, csv, json), extracted at upload so
+	// it can be previewed, and ingested later while the RAG service is down.
+	// Text over DOCUMENT_TEXT_MAX_BYTES is cut to an excerpt and
+	// TextTruncated set.
+	TextExtracted bool   `json:"text_extracted,omitempty"`
+	TextTruncated bool   `json:"text_truncated,omitempty"`
+	ExtractedText string `gorm:"type:text" json:"-"`
+
+	// ScanVerdict is the malware scan's verdict on an upload, clean or
+	// skipped when it wasn't scanned, and ScanEngine the scanner version
+	ScanVerdict string `gorm:"type:varchar(20)" json:"scan_verdict,omitempty"`
+	ScanEngine  string `gorm:"type:varchar(200)" json:"scan_engine,omitempty"`
+
+	Collection *Collection `gorm:"foreignKey:CollectionID" json:"collection,omitempty"`
+}
+
+// IngestOptions control how the RAG service reads and chunks a document
+type IngestOptions struct {
+	ChunkSize    int    `json:"chunk_size"`    // characters per chunk
+	ChunkOverlap int    `json:"chunk_overlap"` // characters shared by consecutive chunks
+	OCR          bool   `gorm:"column:ocr" json:"ocr"`
+	Language     string `gorm:"type:varchar(20)" json:"language,omitempty"` // language of the text, e.g. for OCR; detected when empty
+}
+
+// Collection groups documents so retrieval can be scoped to them
+type Collection struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"type:varchar(100);uniqueIndex;not null" json:"name"`
+	Description string    `gorm:"type:text" json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookSubscription represents an outbound webhook registered by an admin
+type WebhookSubscription struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	URL        string    `gorm:"type:varchar(1000);not null" json:"url"`
+	Secret     string    `gorm:"type:varchar(200)" json:"-"`
+	EventTypes string    `gorm:"type:varchar(500);not null" json:"event_types"` // comma-separated event types
+	Enabled    bool      `gorm:"default:true" json:"enabled"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery records a delivery of an event to a webhook subscription
+type WebhookDelivery struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	SubscriptionID uint      `gorm:"index;not null" json:"subscription_id"`
+	EventType      string    `gorm:"type:varchar(100);not null" json:"event_type"`
+	Payload        string    `gorm:"type:text" json:"payload"`
+	Status         string    `gorm:"type:varchar(50);default:'pending'" json:"status"` // pending, success, failed
+	Attempts       int       `json:"attempts"`
+	ResponseCode   int       `json:"response_code"`
+	Error          string    `gorm:"type:text" json:"error,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// CannedAnswer represents an admin-pinned answer that bypasses the RAG pipeline
+type CannedAnswer struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Pattern   string    `gorm:"type:text;not null" json:"pattern"`
+	MatchType string    `gorm:"type:varchar(20);default:'exact'" json:"match_type"` // exact, keyword, regex
+	Answer    string    `gorm:"type:text;not null" json:"answer"`
+	Enabled   bool      `gorm:"default:true" json:"enabled"`
+	Priority  int       `gorm:"default:0" json:"priority"` // higher priority wins on overlap
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// AnnotationID is set when the answer is an approved annotation's correction
+	AnnotationID *uint `gorm:"-" json:"-"`
+}
+
+// ModelRoutingRule sends queries meeting all of its conditions to a model.
+// Enabled rules are evaluated by position and the first match wins; queries
+// matching none go to the default model. Unset conditions match any query.
+type ModelRoutingRule struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"type:varchar(100);not null" json:"name"`
+	Position    int       `gorm:"not null;default:0;index" json:"position"` // lower positions are evaluated first
+	MinTokens   int       `json:"min_tokens,omitempty"`                     // estimated prompt tokens
+	MaxTokens   int       `json:"max_tokens,omitempty"`
+	MinLength   int       `json:"min_length,omitempty"` // query length in characters
+	MaxLength   int       `json:"max_length,omitempty"`
+	Language    string    `gorm:"type:varchar(10)" json:"language,omitempty"`
+	Collection  string    `gorm:"type:varchar(100)" json:"collection,omitempty"` // the query must be scoped to this collection
+	Pattern     string    `gorm:"type:text" json:"pattern,omitempty"`            // regex on the normalized query
+	TargetModel string    `gorm:"type:varchar(100);not null" json:"target_model"`
+	Enabled     bool      `gorm:"default:true" json:"enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// AnalyticsSegment is a saved slice of queries, such as enterprise customers
+// or mobile widget users, that analytics can be filtered by
+type AnalyticsSegment struct {
+	ID          uint          `gorm:"primaryKey" json:"id"`
+	Name        string        `gorm:"type:varchar(100);uniqueIndex;not null" json:"name"`
+	Description string        `gorm:"type:text" json:"description,omitempty"`
+	Filter      SegmentFilter `gorm:"type:jsonb;not null" json:"filter"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+// SegmentFilter selects the queries in a segment. A query must meet every
+// condition that is set; a list condition matches any of its values.
+type SegmentFilter struct {
+	UserIDs  []string          `json:"user_ids,omitempty"`
+	Plans    []string          `json:"plans,omitempty"`
+	Metadata []MetadataMatcher `json:"metadata,omitempty"`
+	From     *time.Time        `json:"from,omitempty"`
+	To       *time.Time        `json:"to,omitempty"`
+}
+
+// MetadataMatcher matches a field of the metadata sent with a query
+type MetadataMatcher struct {
+	Field string `json:"field"` // page_url, referrer, locale, client_version or user_agent
+	Op    string `json:"op"`    // equals, prefix or contains
+	Value string `json:"value"`
+}
+
+// Value stores a segment filter as JSON
+func (f SegmentFilter) Value() (driver.Value, error) {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads a segment filter stored as JSON
+func (f *SegmentFilter) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, f)
+	case string:
+		return json.Unmarshal([]byte(v), f)
+	default:
+		return fmt.Errorf("unsupported segment filter type %T", value)
+	}
+}
+
+// RoutingDecision is the model chosen for a query and the rule that chose
+// it; Rule is nil when no rule matched and the default model was chosen
+type RoutingDecision struct {
+	Model           string            `json:"model"`
+	Rule            *ModelRoutingRule `json:"rule"`
+	EstimatedTokens int               `json:"estimated_tokens"`
+	QueryLength     int               `json:"query_length"`
+	Language        string            `json:"language"`
+}
+
+// PromptTemplate is a versioned system prompt used by the RAG service; at most one is active
+type PromptTemplate struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_prompt_name_version" json:"name"`
+	Version   int       `gorm:"not null;uniqueIndex:idx_prompt_name_version" json:"version"`
+	Body      string    `gorm:"type:text;not null" json:"body"`
+	Active    bool      `gorm:"default:false;index" json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Experiment routes a share of sessions across model and prompt variants to compare feedback
+type Experiment struct {
+	ID             uint                `gorm:"primaryKey" json:"id"`
+	Name           string              `gorm:"type:varchar(100);uniqueIndex;not null" json:"name"`
+	Description    string              `gorm:"type:text" json:"description,omitempty"`
+	TrafficPercent int                 `gorm:"not null;default:100" json:"traffic_percent"` // share of sessions enrolled
+	Enabled        bool                `gorm:"default:true" json:"enabled"`
+	StartsAt       *time.Time          `json:"starts_at,omitempty"`
+	EndsAt         *time.Time          `json:"ends_at,omitempty"`
+	Variants       []ExperimentVariant `gorm:"constraint:OnDelete:CASCADE" json:"variants"`
+	CreatedAt      time.Time           `json:"created_at"`
+	UpdatedAt      time.Time           `json:"updated_at"`
+}
+
+// ExperimentVariant is one arm of an experiment. Empty model or prompt fields keep the defaults.
+type ExperimentVariant struct {
+	ID              uint    `gorm:"primaryKey" json:"id"`
+	ExperimentID    uint    `gorm:"index;not null" json:"experiment_id"`
+	Name            string  `gorm:"type:varchar(50);not null" json:"name"`
+	Weight          int     `gorm:"not null" json:"weight"`
+	Model           string  `gorm:"type:varchar(100)" json:"model,omitempty"`
+	PromptTemplate  string  `gorm:"type:varchar(100)" json:"prompt_template,omitempty"`
+	PromptVersion   int     `json:"prompt_version,omitempty"`
+	CostPer1KTokens float64 `json:"cost_per_1k_tokens,omitempty"` // overrides COST_PER_1K_TOKENS in results
+}
+
+// WidgetConfig holds branding and behavior for the chat widget embedded on an origin
+type WidgetConfig struct {
+	ID                 uint      `gorm:"primaryKey" json:"id"`
+	Origin             string    `gorm:"type:varchar(500);uniqueIndex;not null" json:"origin"` // "default" applies to unknown origins
+	WelcomeMessage     string    `gorm:"type:text" json:"welcome_message"`
+	ThemeColor         string    `gorm:"type:varchar(20)" json:"theme_color"`
+	SuggestedQuestions string    `gorm:"type:text" json:"suggested_questions"` // JSON array of questions
+	RateLimitTier      string    `gorm:"type:varchar(50);default:'standard'" json:"rate_limit_tier"`
+	PersonaID          *uint     `gorm:"index" json:"persona_id,omitempty"` // persona answers on this origin are given in
+	Enabled            bool      `gorm:"default:true" json:"enabled"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// Persona customizes the voice of answers on the origins whose widget config
+// uses it. Version is bumped on every update so cached answers given in an
+// earlier version aren't served.
+type Persona struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"type:varchar(100);uniqueIndex;not null" json:"name"`
+	Prompt    string    `gorm:"type:text" json:"prompt"`                 // instructions added to the system prompt
+	Tone      string    `gorm:"type:varchar(100)" json:"tone,omitempty"` // e.g. "friendly and concise"
+	Signature string    `gorm:"type:varchar(200)" json:"signature,omitempty"`
+	Version   int       `gorm:"not null;default:1" json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// FAQEntry is a curated question and answer for the public FAQ, often
+// drafted from real user questions. Entries are ordered by position within
+// their category. A published entry with AsCannedAnswer set is also served
+// to exact repeats of its question as the canned answer CannedAnswerID.
+type FAQEntry struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	Question       string     `gorm:"type:text;not null" json:"question"`
+	Answer         string     `gorm:"type:text;not null" json:"answer"`
+	Category       string     `gorm:"type:varchar(100);not null;index:idx_faq_category_position" json:"category"`
+	Position       int        `gorm:"not null;default:0;index:idx_faq_category_position" json:"position"`
+	SourceQueryIDs UintList   `gorm:"type:jsonb" json:"source_query_ids"` // the user questions it was drafted from
+	Published      bool       `gorm:"default:false;index" json:"published"`
+	AsCannedAnswer bool       `gorm:"default:false" json:"as_canned_answer"`
+	CannedAnswerID *uint      `json:"canned_answer_id,omitempty"`
+	PublishedAt    *time.Time `json:"published_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// QueryJob tracks a query processed asynchronously
+type QueryJob struct {
+	ID          string         `gorm:"primaryKey;type:varchar(64)" json:"job_id"`
+	SessionID   string         `gorm:"index" json:"session_id"`
+	Status      string         `gorm:"type:varchar(20);index;default:'pending'" json:"status"` // pending, running, completed, failed
+	QueryID     uint           `json:"query_id,omitempty"`
+	Result      string         `gorm:"type:text" json:"-"` // JSON-encoded QueryResponse
+	Error       string         `gorm:"type:text" json:"error,omitempty"`
+	CreatedAt   time.Time      `gorm:"index" json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	CompletedAt *time.Time     `json:"completed_at,omitempty"`
+	Response    *QueryResponse `gorm:"-" json:"result,omitempty"`
+}
+
+// CrawlJob tracks a URL or sitemap crawl whose pages are ingested as documents
+type CrawlJob struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	URL             string     `gorm:"type:varchar(2048);not null" json:"url"`
+	Sitemap         bool       `json:"sitemap"`
+	IncludePatterns StringList `gorm:"type:jsonb" json:"include_patterns,omitempty"`
+	ExcludePatterns StringList `gorm:"type:jsonb" json:"exclude_patterns,omitempty"`
+	MaxPages        int        `json:"max_pages"`
+	Collection      string     `gorm:"type:varchar(100)" json:"collection,omitempty"`
+	Status          string     `gorm:"type:varchar(20);index;default:'pending'" json:"status"` // pending, running, completed, failed
+	Total           int        `json:"total"`
+	Fetched         int        `json:"fetched"`
+	Failed          int        `json:"failed"`
+	Skipped         int        `json:"skipped"`
+	Error           string     `gorm:"type:text" json:"error,omitempty"`
+	CreatedBy       string     `gorm:"type:varchar(200)" json:"created_by,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+}
+
+// ReingestJob re-ingests every stored document matching its filters, e.g.
+// after chunking changed on the RAG side. Each document is a ReingestItem
+// of the job; the counters track the items.
+type ReingestJob struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	Collection     string     `gorm:"type:varchar(100)" json:"collection,omitempty"`
+	DocumentStatus string     `gorm:"type:varchar(50)" json:"document_status,omitempty"`
+	Status         string     `gorm:"type:varchar(20);index;default:'running'" json:"status"` // running, completed, cancelled, failed
+	Total          int        `json:"total"`
+	Completed      int        `json:"completed"`
+	Failed         int        `json:"failed"`
+	Skipped        int        `json:"skipped"` // documents whose source wasn't kept
+	InFlight       int        `json:"in_flight"`
+	Error          string     `gorm:"type:text" json:"error,omitempty"`
+	CreatedBy      string     `gorm:"type:varchar(200)" json:"created_by,omitempty"`
+	CancelledBy    string     `gorm:"type:varchar(200)" json:"cancelled_by,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	CompletedAt    *time.Time `json:"completed_at,omitempty"`
+}
+
+// ReingestItem is one document of a re-ingestion job
+type ReingestItem struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	JobID      uint      `gorm:"index;not null" json:"job_id"`
+	DocumentID uint      `gorm:"index" json:"document_id"`
+	FileName   string    `gorm:"type:varchar(500)" json:"file_name"`
+	Status     string    `gorm:"type:varchar(20);index" json:"status"`     // pending, in_flight, completed, failed, skipped
+	Reason     string    `gorm:"type:varchar(50)" json:"reason,omitempty"` // why it was skipped, e.g. source_missing
+	Attempts   int       `json:"attempts"`
+	Error      string    `gorm:"type:varchar(500)" json:"error,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// QuotaOverride temporarily replaces a user's plan daily query limit
+type QuotaOverride struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	UserID       string    `gorm:"type:varchar(255);uniqueIndex;not null" json:"user_id"`
+	DailyQueries int       `json:"daily_queries"` // 0 is unlimited
+	Reason       string    `gorm:"type:varchar(500)" json:"reason,omitempty"`
+	ExpiresAt    time.Time `gorm:"index" json:"expires_at"`
+	CreatedBy    string    `gorm:"type:varchar(200)" json:"created_by,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// PurgeJob deletes the chat history matching a filter. A dry run records
+// the rows the filter matches; confirming it runs the purge in batches.
+// LastQueryID is the highest query deleted, so an interrupted purge resumes
+// after it.
+type PurgeJob struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	SessionIDs StringList `gorm:"type:jsonb" json:"session_ids,omitempty"`
+	UserID     string     `gorm:"type:varchar(255)" json:"user_id,omitempty"`
+	After      *time.Time `json:"after,omitempty"`                                        // queries created at or after
+	Before     *time.Time `json:"before,omitempty"`                                       // queries created before
+	Status     string     `gorm:"type:varchar(20);index;default:'dry_run'" json:"status"` // dry_run, pending, running, completed, failed
+
+	// Rows the dry run found
+	MatchedQueries   int64 `json:"matched_queries"`
+	MatchedFeedback  int64 `json:"matched_feedback"`
+	MatchedRelated   int64 `json:"matched_related"` // annotations, retrieval reports, async query jobs and retained attachments
+	MatchedCacheKeys int64 `json:"matched_cache_keys"`
+
+	// Progress of the purge
+	DeletedQueries   int64 `json:"deleted_queries"`
+	DeletedFeedback  int64 `json:"deleted_feedback"`
+	DeletedRelated   int64 `json:"deleted_related"`
+	EvictedCacheKeys int64 `json:"evicted_cache_keys"`
+	LastQueryID      uint  `json:"last_query_id"`
+
+	Error       string     `gorm:"type:text" json:"error,omitempty"`
+	CreatedBy   string     `gorm:"type:varchar(200)" json:"created_by,omitempty"`
+	StartedBy   string     `gorm:"type:varchar(200)" json:"started_by,omitempty"`
+	HeartbeatAt *time.Time `json:"-"` // last progress of the instance running the purge
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// KnowledgeGapReport clusters the questions of a period that the docs
+// couldn't answer: answers replaced for low confidence, answers without
+// retrieved context and thumbs-down answers. Reports are generated in
+// batches; LastQueryID is the highest query clustered, so a report
+// interrupted or out of time for its run resumes after it.
+type KnowledgeGapReport struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Trigger     string    `gorm:"type:varchar(20)" json:"trigger"`                        // manual or scheduled
+	Status      string    `gorm:"type:varchar(20);index;default:'pending'" json:"status"` // pending, running, completed, failed
+	Method      string    `gorm:"type:varchar(20)" json:"method,omitempty"`               // embedding or trigram, picked by the first batch
+	WindowStart time.Time `json:"window_start"`
+	WindowEnd   time.Time `json:"window_end"`
+
+	QueryCount    int64 `json:"query_count"`    // questions clustered
+	ClusterCount  int   `json:"cluster_count"`  // clusters kept once the report completed
+	ResolvedCount int   `json:"resolved_count"` // clusters of earlier reports this one found resolved
+	Truncated     bool  `json:"truncated"`      // stopped at KNOWLEDGE_GAP_MAX_QUERIES
+	Runs          int   `json:"runs"`           // runs generating it, more than one when it ran out of time
+	LastQueryID   uint  `json:"-"`
+
+	Error       string     `gorm:"type:text" json:"error,omitempty"`
+	CreatedBy   string     `gorm:"type:varchar(200)" json:"created_by,omitempty"`
+	HeartbeatAt *time.Time `json:"-"` // last progress of the instance generating the report
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+
+	Clusters []KnowledgeGapCluster `gorm:"-" json:"clusters,omitempty"`
+}
+
+// KnowledgeGapCluster is a group of near-duplicate unanswered questions.
+// Representative is the question that started the cluster. A cluster is
+// resolved by a later report once the same questions get confident answers.
+type KnowledgeGapCluster struct {
+	ID             uint   `gorm:"primaryKey" json:"id"`
+	ReportID       uint   `gorm:"index;not null" json:"report_id"`
+	Rank           int    `json:"rank"` // 1 for the most frequent; 0 until the report completes
+	Representative string `gorm:"type:text;serializer:encrypted" json:"representative"`
+	Normalized     string `gorm:"type:text;serializer:encrypted" json:"-"`
+	Frequency      int    `json:"frequency"`
+
+	// Why the cluster's questions count as unanswered; a question can count
+	// under more than one
+	LowConfidenceCount    int `json:"low_confidence_count"`
+	NoContextCount        int `json:"no_context_count"`
+	NegativeFeedbackCount int `json:"negative_feedback_count"`
+
+	ExampleSessions StringList `gorm:"type:jsonb" json:"example_sessions"`
+	Pages           StringList `gorm:"type:jsonb" json:"pages,omitempty"` // pages the questions were asked on, from query metadata
+	QueryHashes     StringList `gorm:"type:jsonb" json:"-"`               // normalized query digests, to find the questions again
+
+	Status             string     `gorm:"type:varchar(20);index;default:'open'" json:"status"` // open or resolved
+	ResolvedAt         *time.Time `json:"resolved_at,omitempty"`
+	ResolvedByReportID *uint      `json:"resolved_by_report_id,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+// UsageCounts is AI usage summed over chat queries. TokensIn and TokensOut
+// are zero for queries recorded before they were tracked.
+type UsageCounts struct {
+	QueryCount    int64   `gorm:"not null;default:0" json:"query_count"`
+	CacheHits     int64   `gorm:"not null;default:0" json:"cache_hits"`
+	TokensIn      int64   `gorm:"not null;default:0" json:"tokens_in"`
+	TokensOut     int64   `gorm:"not null;default:0" json:"tokens_out"`
+	TokensUsed    int64   `gorm:"not null;default:0" json:"tokens_used"`
+	EstimatedCost float64 `gorm:"not null;default:0" json:"estimated_cost"` // at COST_PER_1K_TOKENS
+}
+
+// UsageRecord is a user's AI usage on a UTC day, for billing. Records are
+// recomputed from the day's chat queries rather than incremented, so they
+// match the queries' sums as of AggregatedAt. Anonymous queries are
+// recorded under an empty user ID.
+type UsageRecord struct {
+	ID     uint   `gorm:"primaryKey" json:"-"`
+	UserID string `gorm:"type:varchar(255);not null;default:'';uniqueIndex:idx_usage_user_date" json:"user_id"`
+	Date   string `gorm:"type:varchar(10);not null;uniqueIndex:idx_usage_user_date;index" json:"date"` // YYYY-MM-DD
+	UsageCounts
+	AggregatedAt time.Time `gorm:"index" json:"aggregated_at"`
+}
+
+// UsageFilter selects usage records: an inclusive range of YYYY-MM-DD dates
+// and, optionally, one user
+type UsageFilter struct {
+	UserID string
+	From   string
+	To     string
+}
+
+// UsageReport is the daily usage of a period with its total
+type UsageReport struct {
+	UserID string        `json:"user_id,omitempty"`
+	From   string        `json:"from"`
+	To     string        `json:"to"`
+	Days   []UsageRecord `json:"days"`
+	Total  UsageCounts   `json:"total"`
+}
+
+// UsageDrift is a day whose recorded usage differs from its chat queries
+type UsageDrift struct {
+	UserID   string      `json:"user_id"`
+	Date     string      `json:"date"`
+	Recorded UsageCounts `json:"recorded"`
+	Actual   UsageCounts `json:"actual"`
+}
+
+// UsageVerification compares recorded usage with usage recomputed from chat
+// queries. Queries changed since LastAggregatedAt show as drift until the
+// next aggregation.
+type UsageVerification struct {
+	UserID           string       `json:"user_id,omitempty"`
+	From             string       `json:"from"`
+	To               string       `json:"to"`
+	CheckedRecords   int          `json:"checked_records"`
+	InSync           bool         `json:"in_sync"`
+	Drift            []UsageDrift `json:"drift"`
+	LastAggregatedAt *time.Time   `json:"last_aggregated_at,omitempty"`
+}
+
+// ShadowRun replays a sample of historical queries against the current RAG
+// service and a candidate deployment, to compare them before switching over.
+// Deltas are the candidate's value minus the current service's.
+type ShadowRun struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	CandidateURL string     `gorm:"type:varchar(2048);not null" json:"candidate_url"`
+	SampleSize   int        `json:"sample_size"`                                            // queries requested
+	After        *time.Time `json:"after,omitempty"`                                        // queries created at or after
+	Before       *time.Time `json:"before,omitempty"`                                       // queries created before
+	Status       string     `gorm:"type:varchar(20);index;default:'running'" json:"status"` // running, completed, cancelled, failed
+	StopReason   string     `gorm:"type:varchar(30)" json:"stop_reason,omitempty"`          // time_budget, token_budget, cancelled or shutdown, when stopped early
+
+	// Budgets of the run
+	MaxDurationS int `json:"max_duration_s"`
+	MaxTokens    int `json:"max_tokens"` // across both services
+
+	// Progress of the run
+	Sampled         int `json:"sampled"`          // historical queries found
+	Compared        int `json:"compared"`         // queries both services answered
+	CurrentErrors   int `json:"current_errors"`   // queries the current service failed
+	CandidateErrors int `json:"candidate_errors"` // queries the candidate failed
+	Skipped         int `json:"skipped"`          // queries not run because the run stopped
+	TokensUsed      int `json:"tokens_used"`      // across both services
+
+	// Averages over compared queries
+	AvgLatencyDeltaMs *float64 `json:"avg_latency_delta_ms,omitempty"`
+	AvgTokenDelta     *float64 `json:"avg_token_delta,omitempty"`
+	AvgLengthDelta    *float64 `json:"avg_length_delta,omitempty"`
+	AvgSimilarity     *float64 `json:"avg_similarity,omitempty"`
+
+	Error       string     `gorm:"type:text" json:"error,omitempty"`
+	CreatedBy   string     `gorm:"type:varchar(200)" json:"created_by,omitempty"`
+	CancelledBy string     `gorm:"type:varchar(200)" json:"cancelled_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// ShadowComparison is one historical query of a shadow run answered by both
+// services. Answers aren't kept, only how they differ; deltas and similarity
+// are nil unless both services answered.
+type ShadowComparison struct {
+	ID      uint `gorm:"primaryKey" json:"id"`
+	RunID   uint `gorm:"index;not null" json:"run_id"`
+	QueryID uint `gorm:"index" json:"query_id"`
+
+	CurrentLatencyMs   int    `json:"current_latency_ms"`
+	CandidateLatencyMs int    `json:"candidate_latency_ms"`
+	CurrentTokens      int    `json:"current_tokens"`
+	CandidateTokens    int    `json:"candidate_tokens"`
+	CurrentLength      int    `json:"current_length"` // characters
+	CandidateLength    int    `json:"candidate_length"`
+	CurrentModel       string `gorm:"type:varchar(100)" json:"current_model,omitempty"`
+	CandidateModel     string `gorm:"type:varchar(100)" json:"candidate_model,omitempty"`
+	CurrentError       string `gorm:"type:text" json:"current_error,omitempty"`
+	CandidateError     string `gorm:"type:text" json:"candidate_error,omitempty"`
+
+	LatencyDeltaMs *int     `json:"latency_delta_ms,omitempty"`
+	TokenDelta     *int     `json:"token_delta,omitempty"`
+	LengthDelta    *int     `json:"length_delta,omitempty"`
+	Similarity     *float64 `json:"similarity,omitempty"` // word overlap of the two answers, 0 to 1
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Session tracks a conversation's last activity and how it ended. Outcome is
+// open, resolved, escalated or abandoned; new activity reopens a closed session.
+type Session struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	SessionID      string     `gorm:"type:varchar(255);uniqueIndex;not null" json:"session_id"`
+	Outcome        string     `gorm:"type:varchar(20);default:'open';index" json:"outcome"`
+	OutcomeAt      *time.Time `gorm:"index" json:"outcome_at,omitempty"`
+	LastActivityAt time.Time  `gorm:"index" json:"last_activity_at"`
+	Segment        int        `gorm:"not null;default:0" json:"segment"` // bumped when a query arrives after the idle timeout
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+
+	// UserID is the user who claimed the session after chatting anonymously.
+	// ClaimTokenHash is the SHA-256 of the secret handed out with the
+	// session's first answer, which claiming requires.
+	UserID         string     `gorm:"type:varchar(255);index;default:''" json:"user_id,omitempty"`
+	ClaimedAt      *time.Time `json:"claimed_at,omitempty"`
+	ClaimTokenHash string     `gorm:"type:varchar(64);default:''" json:"-"`
+}
+
+// SessionClaimRequest represents the request body for claiming a session
+type SessionClaimRequest struct {
+	ClaimToken string `json:"claim_token" binding:"required"`
+}
+
+// SessionClaim reports a claimed session and how many of its rows were
+// linked to the user by this claim
+type SessionClaim struct {
+	SessionID      string    `json:"session_id"`
+	UserID         string    `json:"user_id"`
+	ClaimedAt      time.Time `json:"claimed_at"`
+	QueriesLinked  int64     `json:"queries_linked"`
+	FeedbackLinked int64     `json:"feedback_linked"`
+}
+
+// IdempotencyRecord durably stores the response to a request sent with an
+// Idempotency-Key, keyed by a hash of the endpoint, session and client key
+type IdempotencyRecord struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Key         string    `gorm:"type:varchar(64);uniqueIndex;not null" json:"key"`
+	Endpoint    string    `gorm:"type:varchar(100)" json:"endpoint"`
+	RequestHash string    `gorm:"type:varchar(64)" json:"request_hash"`
+	StatusCode  int       `json:"status_code"`
+	ContentType string    `gorm:"type:varchar(100)" json:"content_type"`
+	Body        string    `gorm:"type:text;serializer:encrypted" json:"body"`
+	ExpiresAt   time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// AuditLog records an admin action. Entries are append-only: updates and
+// deletes are rejected.
+type AuditLog struct {
+	ID           uint         `gorm:"primaryKey" json:"id"`
+	ActorID      string       `gorm:"type:varchar(200);index" json:"actor_id"`
+	Action       string       `gorm:"type:varchar(100);index;not null" json:"action"` // e.g. setting.update, canned_answer.delete
+	ResourceType string       `gorm:"type:varchar(50);index:idx_audit_resource" json:"resource_type"`
+	ResourceID   string       `gorm:"type:varchar(200);index:idx_audit_resource" json:"resource_id"`
+	Changes      AuditChanges `gorm:"type:jsonb" json:"changes,omitempty"`
+	RequestID    string       `gorm:"type:varchar(64)" json:"request_id,omitempty"`
+	IP           string       `gorm:"type:varchar(64)" json:"ip,omitempty"`
+	CreatedAt    time.Time    `gorm:"index" json:"created_at"`
+}
+
+// ErrAuditImmutable is returned when an audit entry would be modified
+var ErrAuditImmutable = errors.New("audit log entries are immutable")
+
+// BeforeUpdate keeps audit entries immutable
+func (AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditImmutable
+}
+
+// BeforeDelete keeps audit entries immutable
+func (AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditImmutable
+}
+
+// AuditChange is the before and after value of one changed field
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditChanges maps field names to their changes, stored as JSON
+type AuditChanges map[string]AuditChange
+
+// Value stores the changes as JSON
+func (c AuditChanges) Value() (driver.Value, error) {
+	if c == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(map[string]AuditChange(c))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads changes stored as JSON
+func (c *AuditChanges) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, c)
+	case string:
+		return json.Unmarshal([]byte(v), c)
+	default:
+		return fmt.Errorf("unsupported audit changes type %T", value)
+	}
+}
+
+// RetrievalFeedback records a bad retrieval report sent to the RAG service
+type RetrievalFeedback struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	FeedbackID uint      `gorm:"index;not null" json:"feedback_id"`
+	QueryID    uint      `gorm:"index;not null" json:"query_id"`
+	Query      string    `gorm:"type:text" json:"query"`
+	ChunkIDs   string    `gorm:"type:text" json:"chunk_ids"`                       // comma-separated chunk IDs
+	Status     string    `gorm:"type:varchar(50);default:'pending'" json:"status"` // pending, delivered, failed
+	Attempts   int       `json:"attempts"`
+	Error      string    `gorm:"type:text" json:"error,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// DeadLetter records an async operation that failed for good, with the
+// payload needed to replay it. Payloads are scrubbed of secrets and capped
+// in size; a truncated payload can't be replayed.
+type DeadLetter struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	Operation  string     `gorm:"type:varchar(100);index;not null" json:"operation"` // e.g. webhook.delivery
+	Payload    string     `gorm:"type:text;serializer:encrypted" json:"payload"`     // JSON
+	Truncated  bool       `json:"truncated,omitempty"`
+	Error      string     `gorm:"type:text" json:"error"`
+	Attempts   int        `json:"attempts"`
+	Status     string     `gorm:"type:varchar(20);default:'pending';index" json:"status"` // pending, replayed
+	ReplayedAt *time.Time `json:"replayed_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Report is a generated analytics digest for a period
+type Report struct {
+	ID          uint        `gorm:"primaryKey" json:"id"`
+	PeriodStart time.Time   `json:"period_start"`
+	PeriodEnd   time.Time   `json:"period_end"`
+	Trigger     string      `gorm:"type:varchar(20)" json:"trigger"` // manual, scheduled
+	Data        string      `gorm:"type:text" json:"-"`              // JSON-encoded ReportData
+	HTML        string      `gorm:"type:text" json:"-"`
+	CreatedAt   time.Time   `json:"created_at"`
+	Summary     *ReportData `gorm:"-" json:"data,omitempty"`
+}
+
+// ReportData holds the key metrics of an analytics report
+type ReportData struct {
+	PeriodStart          time.Time    `json:"period_start"`
+	PeriodEnd            time.Time    `json:"period_end"`
+	QueryVolume          int64        `json:"query_volume"`
+	FeedbackCount        int64        `json:"feedback_count"`
+	PositiveFeedbackRate float64      `json:"positive_feedback_rate"`
+	TopQueries           []QueryCount `json:"top_queries"`
+	TopNegativeTopics    []QueryCount `json:"top_negative_topics"`
+	LatencyP95Ms         float64      `json:"latency_p95_ms"`
+	TokensUsed           int64        `json:"tokens_used"`
+	EstimatedCost        float64      `json:"estimated_cost"`
+}
+
+// QueryCount represents how often a query was asked
+type QueryCount struct {
+	Query string `json:"query"`
+	Count int64  `json:"count"`
+}
+
+// Setting is a runtime override for a hot-reloadable config value
+type Setting struct {
+	Key       string    `gorm:"primaryKey;type:varchar(100)" json:"key"`
+	Value     string    `gorm:"type:text;not null" json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// FeatureFlag switches a feature off during incidents. Flags without a row
+// are enabled; Message is shown to clients while the feature is off.
+type FeatureFlag struct {
+	Name      string    `gorm:"primaryKey;type:varchar(50)" json:"name"`
+	Enabled   bool      `gorm:"not null;default:true" json:"enabled"`
+	Message   string    `gorm:"type:varchar(500)" json:"message,omitempty"`
+	UpdatedBy string    `gorm:"type:varchar(200)" json:"updated_by,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// FeatureFlagState is a feature flag as listed by GET /api/admin/flags
+type FeatureFlagState struct {
+	FeatureFlag
+	Description string `json:"description"`
+}
+
+// FeatureFlagUpdate is the new state of a flag in PUT /api/admin/flags,
+// whose body maps flag names to updates
+type FeatureFlagUpdate struct {
+	Enabled *bool  `json:"enabled"`
+	Message string `json:"message,omitempty"`
+}
+
+// SettingValue is the effective value of a hot-reloadable setting
+type SettingValue struct {
+	Key       string     `json:"key"`
+	Value     string     `json:"value"`
+	Type      string     `json:"type"`
+	Source    string     `json:"source"` // database or environment
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
 }
 
 // Analytics represents aggregated analytics data
@@ -59,6 +974,220 @@ type Analytics struct {
 	TotalTokensUsed  int64   `json:"total_tokens_used"`
 	TotalDocuments   int64   `json:"total_documents"`
 	ActiveSessions   int64   `json:"active_sessions"`
+	UniqueVisitors   int64   `json:"unique_visitors"`
+	CannedAnswers    int64   `json:"canned_answers"`
+	LLMAnswers       int64   `json:"llm_answers"`
+	ClosedSessions   int64   `json:"closed_sessions"`
+	DeflectionRate   float64 `json:"deflection_rate"`
+
+	// Answers cut short by a RAG timeout, and their share of LLM answers
+	PartialResponses    int64   `json:"partial_responses"`
+	PartialResponseRate float64 `json:"partial_response_rate"`
+}
+
+// LatencyPercentiles holds latency percentiles in milliseconds
+type LatencyPercentiles struct {
+	Count int64   `json:"count"`
+	Avg   float64 `json:"avg_ms"`
+	P50   float64 `json:"p50_ms"`
+	P90   float64 `json:"p90_ms"`
+	P99   float64 `json:"p99_ms"`
+}
+
+// LatencyStats represents latency percentiles over a window, split by cache hits and RAG calls
+type LatencyStats struct {
+	WindowDays int                `json:"window_days"`
+	Overall    LatencyPercentiles `json:"overall"`
+	CacheHit   LatencyPercentiles `json:"cache_hit"`
+	RAG        LatencyPercentiles `json:"rag"`
+	Phases     LatencyPhases      `json:"phases"`
+}
+
+// LatencyPhases breaks RAG call latency down by phase. Each covers only the
+// queries whose RAG service reported that phase.
+type LatencyPhases struct {
+	Retrieval        LatencyPercentiles `json:"retrieval"`
+	Generation       LatencyPercentiles `json:"generation"`
+	TimeToFirstToken LatencyPercentiles `json:"time_to_first_token"`
+}
+
+// QueryTrend represents query volume and latency for a single day
+type QueryTrend struct {
+	Date         string  `json:"date"`
+	Count        int64   `json:"count"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	P95LatencyMs float64 `json:"p95_latency_ms"`
+}
+
+// PromptVersionStats represents query volume and feedback for one prompt template version
+type PromptVersionStats struct {
+	PromptTemplate string  `json:"prompt_template"`
+	PromptVersion  int     `json:"prompt_version"`
+	QueryCount     int64   `json:"query_count"`
+	FeedbackCount  int64   `json:"feedback_count"`
+	PositiveCount  int64   `json:"positive_count"`
+	PositiveRate   float64 `json:"positive_rate"`
+}
+
+// PipelineStats compares the answers of a query pipeline and their feedback
+type PipelineStats struct {
+	Pipeline      string  `json:"pipeline"`
+	QueryCount    int64   `json:"query_count"`
+	AvgLatencyMs  float64 `json:"avg_latency_ms"`
+	FeedbackCount int64   `json:"feedback_count"`
+	PositiveCount int64   `json:"positive_count"`
+	PositiveRate  float64 `json:"positive_rate"`
+}
+
+// RegenerationStats describes regenerated answers and their feedback.
+// FlipRate is the percentage of rated regenerations of a thumbed-down
+// answer that were rated positively.
+type RegenerationStats struct {
+	Days               int     `json:"days"`
+	Regenerations      int64   `json:"regenerations"`
+	QueriesRegenerated int64   `json:"queries_regenerated"`
+	AfterNegative      int64   `json:"after_negative"`
+	RatedCount         int64   `json:"rated_count"`
+	PositiveCount      int64   `json:"positive_count"`
+	PositiveRate       float64 `json:"positive_rate"`
+	RatedAfterNegative int64   `json:"rated_after_negative"`
+	FlippedToPositive  int64   `json:"flipped_to_positive"`
+	FlipRate           float64 `json:"flip_rate"`
+}
+
+// GroundingStats describes the grounding scores of verified answers.
+// LowScoreCount counts answers scoring below VERIFICATION_MIN_SCORE, and
+// Buckets split scores into tenths, the last one including 1.
+type GroundingStats struct {
+	Days               int               `json:"days"`
+	Verified           int64             `json:"verified"`
+	AverageScore       float64           `json:"average_score"`
+	AverageUnsupported float64           `json:"average_unsupported"`
+	LowScoreCount      int64             `json:"low_score_count"`
+	LowScoreRate       float64           `json:"low_score_rate"`
+	Buckets            []GroundingBucket `json:"buckets"`
+}
+
+// GroundingBucket counts the verified answers scoring from Min up to Max
+type GroundingBucket struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Count int64   `json:"count"`
+}
+
+// ExperimentResults represents per-variant outcomes of an experiment
+type ExperimentResults struct {
+	ExperimentID uint                      `json:"experiment_id"`
+	Name         string                    `json:"name"`
+	Variants     []ExperimentVariantResult `json:"variants"`
+}
+
+// ExperimentVariantResult represents query volume, feedback, latency and cost for one variant
+type ExperimentVariantResult struct {
+	Variant       string  `json:"variant"`
+	QueryCount    int64   `json:"query_count"`
+	FeedbackCount int64   `json:"feedback_count"`
+	PositiveCount int64   `json:"positive_count"`
+	PositiveRate  float64 `json:"positive_rate"`
+	AvgLatencyMs  float64 `json:"avg_latency_ms"`
+	TokensUsed    int64   `json:"tokens_used"`
+	EstimatedCost float64 `json:"estimated_cost"`
+}
+
+// PageStats represents query volume and negative feedback for one page
+type PageStats struct {
+	PageURL       string  `json:"page_url"`
+	QueryCount    int64   `json:"query_count"`
+	FeedbackCount int64   `json:"feedback_count"`
+	NegativeCount int64   `json:"negative_count"`
+	NegativeRate  float64 `json:"negative_rate"`
+}
+
+// OutcomeTrend counts sessions closed on one day by outcome
+type OutcomeTrend struct {
+	Date           string  `json:"date"`
+	Resolved       int64   `json:"resolved"`
+	Escalated      int64   `json:"escalated"`
+	Abandoned      int64   `json:"abandoned"`
+	DeflectionRate float64 `json:"deflection_rate"`
+}
+
+// WeekHourCounts counts events by local day of week (0 is Sunday) and hour
+type WeekHourCounts [7][24]int64
+
+// QueryHeatmap is query volume, and optionally negative feedback, by hour of
+// the week over the last Days days. Every cell is present, zero if empty.
+type QueryHeatmap struct {
+	Days             int             `json:"days"`
+	TZ               string          `json:"tz"`
+	Queries          WeekHourCounts  `json:"queries"`
+	NegativeFeedback *WeekHourCounts `json:"negative_feedback,omitempty"`
+	GeneratedAt      time.Time       `json:"generated_at"`
+}
+
+// FeedbackThemeReport groups negative feedback comments over a range by theme.
+// PendingClassification counts comments not yet classified.
+type FeedbackThemeReport struct {
+	From                  time.Time           `json:"from"`
+	To                    time.Time           `json:"to"`
+	Total                 int64               `json:"total"`
+	PendingClassification int64               `json:"pending_classification"`
+	Themes                []FeedbackThemeStat `json:"themes"`
+}
+
+// FeedbackThemeStat is the comment count and latest example comments of one theme
+type FeedbackThemeStat struct {
+	Theme    string                 `json:"theme"`
+	Count    int64                  `json:"count"`
+	Examples []FeedbackThemeExample `json:"examples"`
+}
+
+// FeedbackThemeExample is a classified feedback comment
+type FeedbackThemeExample struct {
+	FeedbackID uint      `json:"feedback_id"`
+	QueryID    uint      `json:"query_id"`
+	Comment    string    `json:"comment"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// RateLimitState represents the limit currently enforced by a rate limit policy
+type RateLimitState struct {
+	Requests      int `json:"requests"`
+	WindowSeconds int `json:"window_seconds"`
+}
+
+// DashboardSummary combines everything the admin dashboard shows on load.
+// Sections that fail are null, with the reason in Errors.
+type DashboardSummary struct {
+	GeneratedAt     time.Time                 `json:"generated_at"`
+	Analytics       *Analytics                `json:"analytics"`
+	FeedbackStats   map[string]interface{}    `json:"feedback_stats"`
+	Trends          []QueryTrend              `json:"trends"`
+	FailedDocuments []Document                `json:"failed_documents"`
+	RateLimits      map[string]RateLimitState `json:"rate_limits"`
+	Health          *HealthResponse           `json:"health"`
+	Errors          map[string]string         `json:"errors,omitempty"`
+}
+
+// CollectionSummary represents a collection with its document and chunk counts
+type CollectionSummary struct {
+	ID            uint   `json:"id"`
+	Name          string `json:"name"`
+	Description   string `json:"description,omitempty"`
+	DocumentCount int64  `json:"document_count"`
+	ChunkCount    int64  `json:"chunk_count"`
+}
+
+// LanguageCount represents query volume for a detected language
+type LanguageCount struct {
+	Language   string  `json:"language"`
+	Count      int64   `json:"count"`
+	Percentage float64 `json:"percentage"`
+
+	// Translated answers came back in another language than asked for;
+	// TranslationRate is their percentage of the answers checked
+	Translated      int64   `json:"translated"`
+	TranslationRate float64 `json:"translation_rate"`
 }
 
 // QueryRequest represents the request body for /api/query
@@ -67,6 +1196,138 @@ type QueryRequest struct {
 	SessionID string `json:"session_id" binding:"required"`
 	UserID    string `json:"user_id,omitempty"`
 	Stream    bool   `json:"stream,omitempty"`
+	NoCache   bool   `json:"no_cache,omitempty"`
+	Async     bool   `json:"async,omitempty"`
+
+	// Collections scopes retrieval to documents in the named collections
+	Collections []string `json:"collections,omitempty"`
+
+	// Audience is customer or agent; only agent queries retrieve internal
+	// documents. Defaults to the audience of the caller's token, or customer.
+	Audience string `json:"audience,omitempty" binding:"omitempty,oneof=customer agent"`
+
+	// Metadata describes where the client asked the question
+	Metadata *QueryMetadata `json:"metadata,omitempty"`
+
+	// Attachments are images sent with the query, base64 encoded in JSON
+	// bodies. Multipart requests send them as attachments file parts instead.
+	Attachments []QueryAttachment `json:"attachments,omitempty" binding:"omitempty,dive"`
+
+	// IncludeSuggestions asks for suggested follow-up questions with the answer
+	IncludeSuggestions bool `json:"include_suggestions,omitempty"`
+
+	// SkipVerification skips checking the answer against its context, for
+	// callers that can't afford the extra latency
+	SkipVerification bool `json:"skip_verification,omitempty"`
+
+	// AllowPartial, on by default, lets a query whose answer times out get
+	// what was generated so far. GenerationID, from a timed-out response,
+	// picks up that generation instead of starting over.
+	AllowPartial *bool  `json:"allow_partial,omitempty"`
+	GenerationID string `json:"generation_id,omitempty"`
+
+	// Debug asks for how long each stage of processing took; it is only
+	// honored for tokens with the agent audience
+	Debug bool `json:"debug,omitempty"`
+
+	// NoCacheHeader is set by the handler when the request sent Cache-Control: no-cache
+	NoCacheHeader bool `json:"-"`
+
+	// VisitorID is set by the handler from the visitor fingerprint
+	VisitorID string `json:"-"`
+
+	// TokenAudience is set by the handler from the caller's token; empty
+	// for unauthenticated requests
+	TokenAudience string `json:"-"`
+
+	// TokenUserID and TokenPlan are set by the handler from the caller's
+	// token; quotas are counted against them rather than the body's user ID
+	TokenUserID string `json:"-"`
+	TokenPlan   string `json:"-"`
+
+	// Segment is the session's conversation segment, set by the query
+	// service; ContextReset marks the first query of a new segment
+	Segment      int  `json:"-"`
+	ContextReset bool `json:"-"`
+
+	// Synthetic marks queries generated internally, such as cache warm-up;
+	// they are kept out of analytics, sessions, experiments and webhooks
+	Synthetic bool `json:"-"`
+
+	// Origin is set by the handler from the Origin header; its widget config
+	// picks the persona. PersonaID overrides it, keeping a regenerated
+	// answer in the persona of the original.
+	Origin    string `json:"-"`
+	PersonaID *uint  `json:"-"`
+
+	// ParentQueryID is set when regenerating the answer to an earlier query;
+	// RegenerateAttempt numbers the regenerations of that query from 1
+	ParentQueryID     *uint `json:"-"`
+	RegenerateAttempt int   `json:"-"`
+}
+
+// RegenerateRequest is the body of POST /api/query/:query_id/regenerate; the
+// session must be the one the query was asked in
+type RegenerateRequest struct {
+	SessionID string `json:"session_id" binding:"required"`
+}
+
+// QueryAttachment is an image sent with a query. Its type is detected from
+// the content; only png, jpeg and webp are accepted.
+type QueryAttachment struct {
+	FileName string `json:"file_name,omitempty" binding:"max=255"`
+	Data     []byte `json:"data" binding:"required"`
+
+	// ContentType is set by the query service from the detected type
+	ContentType string `json:"-"`
+}
+
+// QueryMetadata is client context sent with a query and stored on its ChatQuery.
+// Only these keys are kept; anything else the client sends is dropped.
+type QueryMetadata struct {
+	PageURL       string `json:"page_url,omitempty" binding:"omitempty,url,max=2048"`
+	Referrer      string `json:"referrer,omitempty" binding:"omitempty,url,max=2048"`
+	Locale        string `json:"locale,omitempty" binding:"omitempty,max=35"`
+	ClientVersion string `json:"client_version,omitempty" binding:"omitempty,max=50"`
+
+	// UserAgent is set by the handler from the request header, never from the body
+	UserAgent string `json:"user_agent,omitempty"`
+}
+
+// UnmarshalJSON decodes metadata leniently, dropping unknown keys even for
+// decoders that otherwise reject unknown fields. Type errors name the field
+// under metadata, as the decoder doesn't for errors from an Unmarshaler.
+func (m *QueryMetadata) UnmarshalJSON(data []byte) error {
+	type plain QueryMetadata
+	err := json.Unmarshal(data, (*plain)(m))
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		typeErr.Field = strings.TrimSuffix("metadata."+typeErr.Field, ".")
+	}
+	return err
+}
+
+// Value stores metadata as JSON
+func (m QueryMetadata) Value() (driver.Value, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads metadata stored as JSON
+func (m *QueryMetadata) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, m)
+	case string:
+		return json.Unmarshal([]byte(v), m)
+	default:
+		return fmt.Errorf("unsupported metadata type %T", value)
+	}
 }
 
 // QueryResponse represents the response for /api/query
@@ -77,9 +1338,120 @@ type QueryResponse struct {
 	Response  string    `json:"response"`
 	Context   []string  `json:"context,omitempty"`
 	Model     string    `json:"model"`
+	Language  string    `json:"language"`
 	Latency   int       `json:"latency_ms"`
 	CacheHit  bool      `json:"cache_hit"`
+	Moderated bool      `json:"moderated,omitempty"`
 	Timestamp time.Time `json:"timestamp"`
+
+	// GroundingScore is how well the answer is supported by its context,
+	// when verified; LowConfidence is set when a poorly grounded answer was
+	// replaced by the low-confidence message
+	GroundingScore *float64 `json:"grounding_score,omitempty"`
+	LowConfidence  bool     `json:"low_confidence,omitempty"`
+
+	// Partial is set when the answer was cut short by a timeout;
+	// PartialNote explains that to the user, and asking again with the
+	// GenerationID picks up the rest of the answer
+	Partial      bool   `json:"partial,omitempty"`
+	PartialNote  string `json:"partial_note,omitempty"`
+	GenerationID string `json:"generation_id,omitempty"`
+
+	// Translated is set when the answer came back in another language than
+	// the one asked for and was translated
+	Translated bool `json:"translated,omitempty"`
+
+	// Pipeline is rag, or direct when the LLM answered without retrieval
+	Pipeline string `json:"pipeline,omitempty"`
+
+	// FeedbackRequested tells the widget to ask the user to rate the answer
+	FeedbackRequested bool `json:"feedback_requested"`
+
+	// Suggestions are follow-up questions, present when requested and supported by the RAG service
+	Suggestions []string `json:"suggestions,omitempty"`
+
+	// DidYouMean are frequent past queries close to this one, offered when
+	// it retrieved nothing, most likely because of a typo
+	DidYouMean []string `json:"did_you_mean,omitempty"`
+
+	// Stale responses are served from cache past their fresh TTL while a refresh runs
+	Stale       bool       `json:"stale,omitempty"`
+	GeneratedAt *time.Time `json:"generated_at,omitempty"`
+
+	CacheBypassed     bool   `json:"cache_bypassed"`
+	CacheBypassReason string `json:"cache_bypass_reason,omitempty"`
+
+	// ContextReset is set when the session was idle long enough that this
+	// query started a fresh conversation without the earlier history
+	ContextReset bool `json:"context_reset,omitempty"`
+
+	// ParentQueryID is the original query when this answer was regenerated
+	ParentQueryID *uint `json:"parent_query_id,omitempty"`
+
+	// ClaimToken is returned with the first answer of a session; presenting
+	// it lets a user who logs in later claim the session's history
+	ClaimToken string `json:"claim_token,omitempty"`
+
+	// StageTimings are the milliseconds each query pipeline stage took, for
+	// debug requests
+	StageTimings map[string]int `json:"stage_timings_ms,omitempty"`
+
+	// CacheTTL is how long the answer was, or would have been, cached, for
+	// debug requests
+	CacheTTL *CacheTTLDecision `json:"cache_ttl,omitempty"`
+
+	// Phase timings of the RAG call that generated the answer; not set on cache hits
+	PhaseTimings
+}
+
+// CacheTTLDecision is how long an answer is cached. Adaptive TTLs are chosen
+// by Popularity, how often the query was asked recently; Skipped is set when
+// it is asked too rarely to be cached at all.
+type CacheTTLDecision struct {
+	FreshTTLS  int     `json:"fresh_ttl_s"`
+	LifetimeS  int     `json:"lifetime_s"`
+	Adaptive   bool    `json:"adaptive"`
+	Popularity float64 `json:"popularity,omitempty"`
+	Skipped    bool    `json:"skipped,omitempty"`
+}
+
+// LogLevelRequest represents the request body for /api/admin/log-level
+type LogLevelRequest struct {
+	Level string `json:"level" binding:"required"`
+}
+
+// ActiveSession summarizes a session with recent activity for supervisors
+type ActiveSession struct {
+	SessionID             string    `json:"session_id"`
+	UserID                string    `json:"user_id,omitempty"`
+	QueryCount            int64     `json:"query_count"`
+	LastQueryExcerpt      string    `json:"last_query_excerpt"`
+	NegativeFeedbackCount int64     `json:"negative_feedback_count"`
+	Outcome               string    `json:"outcome"`
+	LastActivityAt        time.Time `json:"last_activity_at"`
+}
+
+// ActivityEvent is pushed to the live activity feed when a query or feedback
+// is recorded. It carries excerpts only, never a full response.
+type ActivityEvent struct {
+	Type            string    `json:"type"` // query.created or feedback.created
+	SessionID       string    `json:"session_id"`
+	UserID          string    `json:"user_id,omitempty"`
+	QueryID         uint      `json:"query_id"`
+	QueryExcerpt    string    `json:"query_excerpt,omitempty"`
+	ResponseExcerpt string    `json:"response_excerpt,omitempty"`
+	Model           string    `json:"model,omitempty"`
+	CacheHit        bool      `json:"cache_hit,omitempty"`
+	LatencyMs       int       `json:"latency_ms,omitempty"`
+	FeedbackID      uint      `json:"feedback_id,omitempty"`
+	Score           int       `json:"score,omitempty"`
+	CommentExcerpt  string    `json:"comment_excerpt,omitempty"`
+	Timestamp       time.Time `json:"timestamp"`
+}
+
+// SessionOutcomeRequest represents the request body for /api/sessions/:session_id/outcome
+type SessionOutcomeRequest struct {
+	Outcome string `json:"outcome" binding:"required,oneof=resolved escalated abandoned"`
 }
 
 // FeedbackRequest represents the request body for /api/feedback
@@ -91,12 +1463,327 @@ type FeedbackRequest struct {
 	Tags      string `json:"tags,omitempty"`
 }
 
+// FeedbackImportRow is one survey response in a feedback import. CSV imports
+// name the same columns in a header row.
+type FeedbackImportRow struct {
+	QueryID     uint       `json:"query_id"`
+	Score       int        `json:"score"` // 1 for positive, -1 for negative
+	Comment     string     `json:"comment,omitempty"`
+	ExternalID  string     `json:"external_id"`
+	SubmittedAt *time.Time `json:"submitted_at,omitempty"` // defaults to the import time
+}
+
+// Outcomes of a feedback import row
+const (
+	FeedbackImportImported = "imported"
+	FeedbackImportSkipped  = "skipped"
+	FeedbackImportError    = "error"
+)
+
+// FeedbackImportRowResult reports what happened to one import row
+type FeedbackImportRowResult struct {
+	Row        int    `json:"row"` // from 1, not counting a CSV header
+	QueryID    uint   `json:"query_id,omitempty"`
+	ExternalID string `json:"external_id,omitempty"`
+	Status     string `json:"status"`
+	Reason     string `json:"reason,omitempty"`
+	FeedbackID uint   `json:"feedback_id,omitempty"`
+}
+
+// FeedbackImportResult is the per-row report of a feedback import
+type FeedbackImportResult struct {
+	Imported int                       `json:"imported"`
+	Skipped  int                       `json:"skipped"`
+	Errors   int                       `json:"errors"`
+	Rows     []FeedbackImportRowResult `json:"rows"`
+}
+
+// AnnotationRequest represents the request body for /api/admin/annotations
+type AnnotationRequest struct {
+	QueryID         uint   `json:"query_id" binding:"required"`
+	CorrectedAnswer string `json:"corrected_answer" binding:"required,max=10000"`
+}
+
 // DocumentUploadResponse represents the response for document upload
 type DocumentUploadResponse struct {
+	DocumentID  uint   `json:"document_id"`
+	FileName    string `json:"file_name"`
+	DocumentKey string `json:"document_key"`
+	Version     int    `json:"version"`
+	Visibility  string `json:"visibility"`
+	Status      string `json:"status"`
+	Message     string `json:"message"`
+	ScanVerdict string `json:"scan_verdict,omitempty"`
+
+	IngestOptions IngestOptions `json:"ingest_options"`
+}
+
+// DocumentPreview is the text extracted from a plain-text upload
+type DocumentPreview struct {
 	DocumentID uint   `json:"document_id"`
 	FileName   string `json:"file_name"`
+	FileType   string `json:"file_type"`
 	Status     string `json:"status"`
-	Message    string `json:"message"`
+	Text       string `json:"text"`
+	Truncated  bool   `json:"truncated"`
+}
+
+// DocumentStatus is a document's ingestion status and progress
+type DocumentStatus struct {
+	DocumentID      uint      `json:"document_id"`
+	Status          string    `json:"status"`
+	ProgressPercent *int      `json:"progress_percent"`
+	ChunksProcessed int       `json:"chunks_processed"`
+	ChunksTotal     int       `json:"chunks_total"`
+	ChunkCount      int       `json:"chunk_count"`
+	BytesRead       int64     `json:"bytes_read,omitempty"`
+	ErrorCode       string    `json:"error_code,omitempty"`
+	ErrorMessage    string    `json:"error_message,omitempty"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// WebhookSubscriptionRequest represents the request body for creating or updating a webhook
+type WebhookSubscriptionRequest struct {
+	URL        string   `json:"url" binding:"required,url"`
+	Secret     string   `json:"secret,omitempty"`
+	EventTypes []string `json:"event_types" binding:"required,min=1"`
+	Enabled    *bool    `json:"enabled,omitempty"`
+}
+
+// ReportRequest represents the request body for POST /api/admin/reports/generate
+type ReportRequest struct {
+	Days int `json:"days" binding:"omitempty,min=1,max=365"`
+}
+
+// DocumentUpdateRequest represents the request body for PATCH /api/docs/:id
+type DocumentUpdateRequest struct {
+	Collection *string `json:"collection"` // empty string removes the document from its collection
+	Visibility *string `json:"visibility" binding:"omitempty,oneof=public internal"`
+}
+
+// CrawlRequest represents the request body for POST /api/docs/ingest-url.
+// Include and exclude patterns are regular expressions matched against the URL path.
+type CrawlRequest struct {
+	URL             string   `json:"url" binding:"required,url,max=2048"`
+	Sitemap         *bool    `json:"sitemap"` // defaults to true for URLs ending in .xml
+	IncludePatterns []string `json:"include_patterns" binding:"max=20,dive,max=200"`
+	ExcludePatterns []string `json:"exclude_patterns" binding:"max=20,dive,max=200"`
+	MaxPages        int      `json:"max_pages" binding:"omitempty,min=1"`
+	Collection      string   `json:"collection" binding:"max=100"`
+}
+
+// IngestOptionsRequest overrides the default ingestion options. Options
+// left out default to the previous version's, or to the configured defaults.
+type IngestOptionsRequest struct {
+	ChunkSize    *int    `json:"chunk_size,omitempty"`
+	ChunkOverlap *int    `json:"chunk_overlap,omitempty"`
+	OCR          *bool   `json:"ocr,omitempty"`
+	Language     *string `json:"language,omitempty"`
+}
+
+// ObjectIngestRequest asks to ingest an object from cloud storage, located by
+// bucket and key or by a (presigned) URL
+type ObjectIngestRequest struct {
+	Provider    string `json:"provider" binding:"required,oneof=s3 gcs https"`
+	Bucket      string `json:"bucket" binding:"max=255"`
+	Key         string `json:"key" binding:"max=1024"`
+	URL         string `json:"url" binding:"omitempty,url,max=4096"`
+	Credentials string `json:"credentials" binding:"max=100"` // name of configured credentials for bucket and key access
+	FileName    string `json:"file_name" binding:"max=500"`   // defaults to the last segment of the key or URL path
+	Collection  string `json:"collection" binding:"max=100"`
+	DocumentKey string `json:"document_key" binding:"max=200"`
+	Visibility  string `json:"visibility" binding:"omitempty,oneof=public internal"` // defaults to the previous version's, or public
+
+	IngestOptionsRequest
+}
+
+// WidgetConfigRequest represents the request to create or update a widget config
+type WidgetConfigRequest struct {
+	Origin             string   `json:"origin" binding:"required"`
+	WelcomeMessage     string   `json:"welcome_message"`
+	ThemeColor         string   `json:"theme_color"`
+	SuggestedQuestions []string `json:"suggested_questions"`
+	RateLimitTier      string   `json:"rate_limit_tier" binding:"omitempty,oneof=standard elevated"`
+	PersonaID          *uint    `json:"persona_id,omitempty"` // 0 removes the persona; absent leaves it unchanged
+	Enabled            *bool    `json:"enabled,omitempty"`
+}
+
+// PublicWidgetConfig is the widget config served to embedding pages
+type PublicWidgetConfig struct {
+	Origin             string   `json:"origin"`
+	WelcomeMessage     string   `json:"welcome_message"`
+	ThemeColor         string   `json:"theme_color"`
+	SuggestedQuestions []string `json:"suggested_questions"`
+	RateLimitTier      string   `json:"rate_limit_tier"`
+}
+
+// CannedAnswerRequest represents the request body for creating or updating a canned answer
+type CannedAnswerRequest struct {
+	Pattern   string `json:"pattern" binding:"required"`
+	MatchType string `json:"match_type" binding:"omitempty,oneof=exact keyword regex"`
+	Answer    string `json:"answer" binding:"required"`
+	Enabled   *bool  `json:"enabled,omitempty"`
+	Priority  int    `json:"priority"`
+}
+
+// ModelRoutingRuleRequest represents the request body for creating or updating a model routing rule
+type ModelRoutingRuleRequest struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Position    int    `json:"position"`
+	MinTokens   int    `json:"min_tokens" binding:"min=0"`
+	MaxTokens   int    `json:"max_tokens" binding:"min=0"`
+	MinLength   int    `json:"min_length" binding:"min=0"`
+	MaxLength   int    `json:"max_length" binding:"min=0"`
+	Language    string `json:"language,omitempty" binding:"max=10"`
+	Collection  string `json:"collection,omitempty" binding:"max=100"`
+	Pattern     string `json:"pattern,omitempty" binding:"max=1000"`
+	TargetModel string `json:"target_model" binding:"required,max=100"`
+	Enabled     *bool  `json:"enabled,omitempty"`
+}
+
+// PersonaRequest represents the request body for creating or updating a persona
+type PersonaRequest struct {
+	Name      string `json:"name" binding:"required,max=100"`
+	Prompt    string `json:"prompt"`
+	Tone      string `json:"tone,omitempty" binding:"max=100"`
+	Signature string `json:"signature,omitempty" binding:"max=200"`
+}
+
+// FAQEntryRequest represents the request body for creating or updating a FAQ
+// entry. Entries are drafts unless published.
+type FAQEntryRequest struct {
+	Question       string `json:"question" binding:"required,max=1000"`
+	Answer         string `json:"answer" binding:"required"`
+	Category       string `json:"category,omitempty" binding:"max=100"`
+	SourceQueryIDs []uint `json:"source_query_ids,omitempty"`
+	Published      *bool  `json:"published,omitempty"`
+	AsCannedAnswer *bool  `json:"as_canned_answer,omitempty"`
+}
+
+// FAQReorderRequest represents the request body for /api/admin/faq/reorder:
+// every entry of a category, in their new order
+type FAQReorderRequest struct {
+	Category string `json:"category" binding:"max=100"`
+	IDs      []uint `json:"ids" binding:"required,min=1"`
+}
+
+// FAQCategory is a category of the public FAQ with its published entries in order
+type FAQCategory struct {
+	Category string    `json:"category"`
+	Entries  []FAQItem `json:"entries"`
+}
+
+// FAQItem is a published FAQ entry as shown to the public
+type FAQItem struct {
+	ID       uint   `json:"id"`
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+}
+
+// PersonaPreviewRequest represents the request body for /api/admin/personas/:id/preview
+type PersonaPreviewRequest struct {
+	Query string `json:"query" binding:"required,max=10000"`
+}
+
+// PersonaPreview is a sample answer given in a persona; nothing about it is stored
+type PersonaPreview struct {
+	PersonaID      uint     `json:"persona_id"`
+	PersonaVersion int      `json:"persona_version"`
+	Query          string   `json:"query"`
+	Response       string   `json:"response"`
+	Context        []string `json:"context"`
+	Model          string   `json:"model"`
+	Language       string   `json:"language,omitempty"`
+	TokensUsed     int      `json:"tokens_used"`
+	Latency        int      `json:"latency_ms"`
+	Moderated      bool     `json:"moderated"`
+	GroundingScore *float64 `json:"grounding_score,omitempty"`
+	LowConfidence  bool     `json:"low_confidence,omitempty"`
+	Translated     bool     `json:"translated,omitempty"`
+}
+
+// AnalyticsSegmentRequest represents the request body for creating or updating an analytics segment
+type AnalyticsSegmentRequest struct {
+	Name        string        `json:"name" binding:"required,max=100"`
+	Description string        `json:"description,omitempty" binding:"max=1000"`
+	Filter      SegmentFilter `json:"filter"`
+}
+
+// RoutingTestRequest represents the request body for /api/admin/routing-rules/test
+type RoutingTestRequest struct {
+	Query       string   `json:"query" binding:"required,max=10000"`
+	Language    string   `json:"language,omitempty" binding:"max=10"` // detected from the query if empty
+	Collections []string `json:"collections,omitempty"`
+
+	// EstimatedTokens stands in for the prompt estimate, which otherwise
+	// counts no conversation history
+	EstimatedTokens *int `json:"estimated_tokens,omitempty" binding:"omitempty,min=0"`
+}
+
+// QuotaOverrideRequest represents the request body for PUT
+// /api/admin/quota-overrides/:user_id
+type QuotaOverrideRequest struct {
+	DailyQueries *int      `json:"daily_queries" binding:"required,min=0"`
+	ExpiresAt    time.Time `json:"expires_at" binding:"required"`
+	Reason       string    `json:"reason,omitempty" binding:"max=500"`
+}
+
+// PurgeRequest represents the request body for /api/admin/purge. A purge
+// starts with a dry run of the filter; the purge itself confirms the dry run
+// by ID.
+type PurgeRequest struct {
+	SessionIDs []string   `json:"session_ids,omitempty" binding:"max=1000,dive,max=255"`
+	UserID     string     `json:"user_id,omitempty" binding:"max=255"`
+	After      *time.Time `json:"after,omitempty"`
+	Before     *time.Time `json:"before,omitempty"`
+	DryRun     bool       `json:"dry_run"`
+	DryRunID   *uint      `json:"dry_run_id,omitempty"`
+}
+
+// ShadowTestRequest starts a shadow test of a candidate RAG service.
+// Budgets default to, and may not exceed, the configured limits.
+type ShadowTestRequest struct {
+	CandidateURL string     `json:"candidate_url" binding:"required,url,max=2048"`
+	SampleSize   int        `json:"sample_size" binding:"required,min=1"`
+	After        *time.Time `json:"after,omitempty"`
+	Before       *time.Time `json:"before,omitempty"`
+	MaxDurationS int        `json:"max_duration_s,omitempty" binding:"min=0"`
+	MaxTokens    int        `json:"max_tokens,omitempty" binding:"min=0"`
+}
+
+// ReingestRequest starts re-ingesting stored documents, optionally only a
+// collection's or those with a status, completed or failed
+type ReingestRequest struct {
+	Collection string `json:"collection,omitempty" binding:"max=100"`
+	Status     string `json:"status,omitempty"`
+}
+
+// PromptTemplateRequest represents the request body for creating or updating a prompt template
+type PromptTemplateRequest struct {
+	Name    string `json:"name" binding:"required,max=100"`
+	Version int    `json:"version" binding:"required,min=1"`
+	Body    string `json:"body" binding:"required"`
+}
+
+// ExperimentRequest represents the request body for creating or updating an experiment
+type ExperimentRequest struct {
+	Name           string                     `json:"name" binding:"required,max=100"`
+	Description    string                     `json:"description,omitempty"`
+	TrafficPercent *int                       `json:"traffic_percent,omitempty" binding:"omitempty,min=0,max=100"`
+	Enabled        *bool                      `json:"enabled,omitempty"`
+	StartsAt       *time.Time                 `json:"starts_at,omitempty"`
+	EndsAt         *time.Time                 `json:"ends_at,omitempty"`
+	Variants       []ExperimentVariantRequest `json:"variants" binding:"required,min=1,dive"`
+}
+
+// ExperimentVariantRequest represents one variant in an experiment request
+type ExperimentVariantRequest struct {
+	Name            string  `json:"name" binding:"required,max=50"`
+	Weight          int     `json:"weight" binding:"required,min=1"`
+	Model           string  `json:"model,omitempty" binding:"omitempty,max=100"`
+	PromptTemplate  string  `json:"prompt_template,omitempty" binding:"omitempty,max=100"`
+	PromptVersion   int     `json:"prompt_version,omitempty" binding:"omitempty,min=1"`
+	CostPer1KTokens float64 `json:"cost_per_1k_tokens,omitempty" binding:"omitempty,min=0"`
 }
 
 // HealthResponse represents the health check response
@@ -107,6 +1794,209 @@ type HealthResponse struct {
 	Database   string    `json:"database"`
 	Redis      string    `json:"redis"`
 	RAGService string    `json:"rag_service"`
+	Mode       string    `json:"mode"` // normal, or degraded while every RAG endpoint is marked unavailable
+
+	// RAGServiceFallback is only reported when a fallback RAG endpoint is configured
+	RAGServiceFallback string `json:"rag_service_fallback,omitempty"`
+
+	// RAGQueue is omitted when the concurrency limit is disabled
+	RAGQueue *RAGQueueStatus `json:"rag_queue,omitempty"`
+
+	RAGRateLimits *RAGRateLimitStatus `json:"rag_rate_limits,omitempty"`
+}
+
+// RAGRateLimitStatus reports 429 responses from the RAG service over a
+// recent window, to alert before rate limiting reaches users
+type RAGRateLimitStatus struct {
+	WindowSeconds int     `json:"window_seconds"`
+	Calls         int64   `json:"calls"`        // RAG call attempts, retries included
+	RateLimited   int64   `json:"rate_limited"` // attempts answered with 429
+	PerMinute     float64 `json:"per_minute"`   // 429s per minute
+	Ratio         float64 `json:"ratio"`        // share of attempts answered with 429
+}
+
+// RAGQueueStatus reports load on the RAG concurrency limiter
+type RAGQueueStatus struct {
+	InFlight     int   `json:"in_flight"`
+	MaxInFlight  int   `json:"max_in_flight"`
+	QueueDepth   int   `json:"queue_depth"`
+	MaxQueue     int   `json:"max_queue"`
+	AvgLatencyMs int64 `json:"avg_latency_ms"`
+	Shed         int64 `json:"shed"` // requests rejected since startup
+}
+
+// ConversationSearchResult is a past query matching a search, with the
+// matching terms of the query and response highlighted in <mark> tags
+type ConversationSearchResult struct {
+	QueryID          uint      `json:"query_id"`
+	SessionID        string    `json:"session_id"`
+	UserID           string    `json:"user_id,omitempty"`
+	Rank             float64   `json:"rank"`
+	QueryHeadline    string    `json:"query_headline"`
+	ResponseHeadline string    `json:"response_headline"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// ConfigBundle is admin configuration exported from one environment to be
+// imported into another. Resources are identified by natural keys rather
+// than IDs: canned answers by match type and pattern, prompt templates by
+// name and version, widget configs by origin and settings by key. Settings
+// hold only database overrides, not values from the environment.
+type ConfigBundle struct {
+	Version         int                    `json:"version" binding:"required"`
+	ExportedAt      time.Time              `json:"exported_at"`
+	CannedAnswers   []CannedAnswerRequest  `json:"canned_answers" binding:"dive"`
+	PromptTemplates []BundlePromptTemplate `json:"prompt_templates" binding:"dive"`
+	WidgetConfigs   []WidgetConfigRequest  `json:"widget_configs" binding:"dive"`
+	Settings        map[string]string      `json:"settings"`
+}
+
+// BundlePromptTemplate is a prompt template in a config bundle
+type BundlePromptTemplate struct {
+	PromptTemplateRequest
+	Active bool `json:"active"`
+}
+
+// ConfigImportRequest is the request body for POST /api/admin/import.
+// Conflicts maps a resource type (canned_answers, prompt_templates,
+// widget_configs, settings) to skip or overwrite; skip is the default. Prune
+// deletes resources of those types that the bundle doesn't contain.
+type ConfigImportRequest struct {
+	Bundle    ConfigBundle      `json:"bundle"`
+	Conflicts map[string]string `json:"conflicts"`
+	Prune     bool              `json:"prune"`
+}
+
+// ConfigImportChange is one change an import makes, or would make in a dry run
+type ConfigImportChange struct {
+	ResourceType string       `json:"resource_type"`
+	Key          string       `json:"key"`
+	Action       string       `json:"action"`            // create, update, delete or skip
+	Changes      AuditChanges `json:"changes,omitempty"` // fields that differ, for updates and skipped conflicts
+}
+
+// ConfigImportResult lists the changes of an import; unchanged resources
+// are only counted
+type ConfigImportResult struct {
+	DryRun    bool                 `json:"dry_run"`
+	Changes   []ConfigImportChange `json:"changes"`
+	Created   int                  `json:"created"`
+	Updated   int                  `json:"updated"`
+	Deleted   int                  `json:"deleted"`
+	Skipped   int                  `json:"skipped"`
+	Unchanged int                  `json:"unchanged"`
+}
+
+// CacheWarmupResult reports a cache warm-up run. Skipped queries have
+// answers that are never cached, such as canned or moderated ones; Remaining
+// ones weren't tried before the time budget ran out or the RAG service became
+// unavailable.
+type CacheWarmupResult struct {
+	Status        string     `json:"status"`  // running or completed
+	Trigger       string     `json:"trigger"` // startup or admin
+	Queries       int        `json:"queries"`
+	Warmed        int        `json:"warmed"`
+	AlreadyCached int        `json:"already_cached"`
+	Skipped       int        `json:"skipped"`
+	Failed        int        `json:"failed"`
+	Remaining     int        `json:"remaining"`
+	Error         string     `json:"error,omitempty"`
+	StartedAt     time.Time  `json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+}
+
+// Document visibilities
+const (
+	VisibilityPublic   = "public"
+	VisibilityInternal = "internal"
+)
+
+// Query audiences; agents may see internal documents, customers may not
+const (
+	AudienceCustomer = "customer"
+	AudienceAgent    = "agent"
+)
+
+// Query pipelines: answered by the RAG service from retrieved context, or
+// by the LLM directly for general questions that need no retrieval
+const (
+	PipelineRAG    = "rag"
+	PipelineDirect = "direct"
+)
+
+// Circuit breaker states
+const (
+	BreakerClosed = "closed"
+	BreakerOpen   = "open"
+)
+
+// CircuitBreakerState reports whether calls to a dependency are being cut off
+type CircuitBreakerState struct {
+	Name                string `json:"name"`
+	State               string `json:"state"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	FailureThreshold    int    `json:"failure_threshold"`
+}
+
+// RuntimeState is a snapshot of live server state for diagnosing throttling
+// and load. Sections that fail are null, with the reason in Errors.
+type RuntimeState struct {
+	GeneratedAt     time.Time             `json:"generated_at"`
+	RateLimits      *RateLimitBuckets     `json:"rate_limits"`
+	QueryCache      *QueryCacheStats      `json:"query_cache"`
+	CircuitBreakers []CircuitBreakerState `json:"circuit_breakers"`
+	RAGQueue        *RAGQueueStatus       `json:"rag_queue,omitempty"`
+	WorkerQueues    []WorkerQueueState    `json:"worker_queues"`
+	Errors          map[string]string     `json:"errors,omitempty"`
+}
+
+// RateLimitBuckets lists the fullest rate limit buckets. Complete is false
+// when the key scan was capped, so noisier buckets may be missing.
+type RateLimitBuckets struct {
+	Buckets  []RateLimitBucket `json:"buckets"`
+	Scanned  int               `json:"scanned"`
+	Complete bool              `json:"complete"`
+}
+
+// RateLimitBucket is the request count of one client under one policy
+type RateLimitBucket struct {
+	Key          string `json:"key"`
+	Policy       string `json:"policy"`
+	Subject      string `json:"subject"`
+	Count        int64  `json:"count"`
+	ResetSeconds int    `json:"reset_seconds"`
+}
+
+// QueryCacheStats estimates the size of the query cache. Keys and
+// EstimatedBytes are extrapolated from a sample when Exact is false.
+type QueryCacheStats struct {
+	Keys                 int64 `json:"keys"`
+	EstimatedBytes       int64 `json:"estimated_bytes"`
+	Exact                bool  `json:"exact"`
+	RedisKeys            int64 `json:"redis_keys"`
+	RedisUsedMemoryBytes int64 `json:"redis_used_memory_bytes"`
+}
+
+// WorkerQueueState reports the backlog of a background worker pool
+type WorkerQueueState struct {
+	Name     string `json:"name"`
+	Depth    int    `json:"depth"`
+	Capacity int    `json:"capacity"`
+	Running  int    `json:"running"`
+}
+
+// QuotaUsage reports a caller's plan and how much of its daily query quota
+// is used. Limit is 0 and Remaining nil when queries are unlimited.
+type QuotaUsage struct {
+	Plan           string         `json:"plan"`
+	Limit          int            `json:"limit"`
+	Used           int64          `json:"used"`
+	Remaining      *int64         `json:"remaining"`
+	ResetAt        time.Time      `json:"reset_at"`
+	MaxUploadBytes int64          `json:"max_upload_bytes"`
+	Streaming      bool           `json:"streaming"`
+	Override       *QuotaOverride `json:"override,omitempty"`
+	TokensUsed     int64          `json:"tokens_used"` // today, by queries of the token's user
 }
 
 // ErrorResponse represents an error response
@@ -114,4 +2004,157 @@ type ErrorResponse struct {
 	Error     string    `json:"error"`
 	Message   string    `json:"message"`
 	Timestamp time.Time `json:"timestamp"`
+
+	// Set on system_busy responses: requests waiting for the answer service
+	QueueDepth *int `json:"queue_depth,omitempty"`
+
+	// Set on upstream_rate_limited and timeout responses: seconds until a retry may succeed
+	RetryAfter *int `json:"retry_after,omitempty"`
+
+	// Set on invalid_request responses: what is wrong with each field
+	Fields []FieldError `json:"fields,omitempty"`
+
+	// Set on prompt_too_long responses: estimated tokens over the limit
+	ExcessTokens *int `json:"excess_tokens,omitempty"`
+
+	// Set on timeout responses when the answer may still be generating:
+	// asking again with it picks up the generation
+	GenerationID string `json:"generation_id,omitempty"`
+
+	// Set on quota_exceeded responses: the plan's limit, usage and reset time
+	Quota *QuotaUsage `json:"quota,omitempty"`
+}
+
+// FieldError describes one invalid field of a request body. Field is the JSON
+// path, e.g. metadata.page_url; Code is a stable reason such as required,
+// unknown_field or invalid_type.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// StringList is a list of strings stored as a JSON array
+type StringList []string
+
+// Value stores the list as JSON
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal([]string(l))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads a list stored as JSON
+func (l *StringList) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, l)
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	default:
+		return fmt.Errorf("unsupported string list type %T", value)
+	}
+}
+
+// QueryDebugBundle is everything known about one answered query, assembled
+// for debugging a bad answer offline. Conversation text in it is redacted
+// of credentials and personal data.
+type QueryDebugBundle struct {
+	Query       ChatQuery            `json:"query"`
+	Chunks      []QueryDebugChunk    `json:"chunks"`
+	Prompt      *PromptTemplate      `json:"prompt,omitempty"` // the template version the answer was generated with
+	Cache       QueryDebugCache      `json:"cache"`
+	Feedback    []QueryDebugFeedback `json:"feedback"`
+	GeneratedAt time.Time            `json:"generated_at"`
+
+	// Retrieval is what else the RAG service reports about the retrieval;
+	// RetrievalError says why the RAG service couldn't be asked
+	Retrieval      map[string]interface{} `json:"retrieval,omitempty"`
+	RetrievalError string                 `json:"retrieval_error,omitempty"`
+}
+
+// QueryDebugChunk is a context chunk an answer was generated from, with
+// what the RAG service and the document store know about its source
+type QueryDebugChunk struct {
+	ID       string                 `json:"id"`
+	Text     string                 `json:"text"`
+	Score    *float64               `json:"score,omitempty"`
+	Source   string                 `json:"source,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	Document *QueryDebugDocument    `json:"document,omitempty"`
+}
+
+// QueryDebugDocument is the metadata of a chunk's source document
+type QueryDebugDocument struct {
+	ID            uint      `json:"id"`
+	FileName      string    `json:"file_name"`
+	FileType      string    `json:"file_type"`
+	VectorStoreID string    `json:"vector_store_id"`
+	Version       int       `json:"version"`
+	Status        string    `json:"status"`
+	Visibility    string    `json:"visibility"`
+	CollectionID  *uint     `json:"collection_id,omitempty"`
+	SourceURL     string    `json:"source_url,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// QueryDebugCache is how a query's answer relates to the response cache.
+// Cached is whether the entry is still there; TTLSeconds is left out when
+// it isn't.
+type QueryDebugCache struct {
+	Key         string `json:"key,omitempty"`
+	Hit         bool   `json:"hit"`
+	Bypassed    bool   `json:"bypassed"`
+	Cached      bool   `json:"cached"`
+	TTLSeconds  int    `json:"ttl_seconds,omitempty"`
+	Unavailable bool   `json:"unavailable,omitempty"` // the cache couldn't be checked
+}
+
+// QueryDebugFeedback is a rating of the answer
+type QueryDebugFeedback struct {
+	ID        uint      `json:"id"`
+	Score     int       `json:"score"`
+	Comment   string    `json:"comment,omitempty"`
+	Tags      string    `json:"tags,omitempty"`
+	Theme     string    `json:"theme,omitempty"`
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UintList is a list of IDs stored as a JSON array
+type UintList []uint
+
+// Value stores the list as JSON
+func (l UintList) Value() (driver.Value, error) {
+	if l == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal([]uint(l))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads a list stored as JSON
+func (l *UintList) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, l)
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	default:
+		return fmt.Errorf("unsupported ID list type %T", value)
+	}
 }
@@ -0,0 +1,106 @@
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Moderation modes
+const (
+	ModeOff     = "off"
+	ModeLogOnly = "log-only"
+	ModeEnforce = "enforce"
+)
+
+// IsValidMode returns true if the moderation mode is supported
+func IsValidMode(mode string) bool {
+	return mode == ModeOff || mode == ModeLogOnly || mode == ModeEnforce
+}
+
+// Result is the outcome of a moderation check
+type Result struct {
+	Flagged    bool
+	Categories []string
+}
+
+// Client calls a moderation endpoint compatible with the OpenAI moderation API
+type Client struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+// NewClient creates a moderation client for the given endpoint
+func NewClient(url, apiKey string) *Client {
+	return &Client{
+		url:    url,
+		apiKey: apiKey,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// moderationResponse mirrors the OpenAI moderation response format
+type moderationResponse struct {
+	Results []struct {
+		Flagged    bool            `json:"flagged"`
+		Categories map[string]bool `json:"categories"`
+	} `json:"results"`
+}
+
+// Check sends text to the moderation endpoint and returns the flagged categories
+func (c *Client) Check(ctx context.Context, text string) (*Result, error) {
+	jsonData, err := json.Marshal(map[string]string{"input": text})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call moderation endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("moderation endpoint returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var modResp moderationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&modResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	result := &Result{}
+	for _, r := range modResp.Results {
+		if !r.Flagged {
+			continue
+		}
+		result.Flagged = true
+		for category, flagged := range r.Categories {
+			if flagged {
+				result.Categories = append(result.Categories, category)
+			}
+		}
+	}
+	sort.Strings(result.Categories)
+
+	return result, nil
+}
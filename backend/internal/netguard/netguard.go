@@ -0,0 +1,157 @@
+// Package netguard keeps outbound requests to caller-supplied URLs, such as
+// crawls, object fetches and webhooks, away from the server's own network:
+// loopback, private, link-local and other non-public addresses are refused
+// unless an operator allows them.
+package netguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrBlockedDestination is returned for a URL or connection whose address
+// isn't public
+var ErrBlockedDestination = errors.New("destination address is not allowed")
+
+// blockedNets are non-public ranges not covered by the net.IP predicates
+var blockedNets = mustParseCIDRs(
+	"0.0.0.0/8",     // "this" network
+	"100.64.0.0/10", // carrier-grade NAT
+	"192.0.0.0/24",  // IETF protocol assignments
+	"198.18.0.0/15", // benchmarking
+	"240.0.0.0/4",   // reserved
+	"64:ff9b::/96",  // NAT64, which can reach IPv4 private ranges
+)
+
+// Guard decides which addresses outbound requests may reach
+type Guard struct {
+	allowed []*net.IPNet
+}
+
+// New creates a guard that also lets through the allowed CIDRs, for internal
+// services operators deliberately point requests at
+func New(allowedCIDRs []string) (*Guard, error) {
+	allowed, err := ParseCIDRs(allowedCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	return &Guard{allowed: allowed}, nil
+}
+
+// ParseCIDRs parses CIDR ranges such as 10.1.0.0/16
+func ParseCIDRs(entries []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		_, n, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", entry)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func mustParseCIDRs(entries ...string) []*net.IPNet {
+	nets, err := ParseCIDRs(entries)
+	if err != nil {
+		panic(err)
+	}
+	return nets
+}
+
+// Allowed reports whether requests may reach ip
+func (g *Guard) Allowed(ip net.IP) bool {
+	for _, n := range g.allowed {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return public(ip)
+}
+
+// public reports whether ip is a public unicast address
+func public(ip net.IP) bool {
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	for _, n := range blockedNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckURL rejects a URL that isn't http or https or whose host resolves to
+// an address requests may not reach. It's for refusing a URL up front; the
+// connection itself is checked again by Dialer, since DNS answers can change.
+func (g *Guard) CheckURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("%w: %s is not an http or https URL", ErrBlockedDestination, raw)
+	}
+
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if !g.Allowed(ip) {
+			return fmt.Errorf("%w: %s", ErrBlockedDestination, host)
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if !g.Allowed(addr.IP) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrBlockedDestination, host, addr.IP)
+		}
+	}
+	return nil
+}
+
+// Dialer returns a dialer refusing connections to addresses requests may not
+// reach. The address is checked after DNS resolution, for every connection,
+// so redirects and changed DNS answers are covered.
+func (g *Guard) Dialer(timeout time.Duration) *net.Dialer {
+	return &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); !g.Allowed(ip) {
+				return fmt.Errorf("%w: %s", ErrBlockedDestination, host)
+			}
+			return nil
+		},
+	}
+}
+
+// Transport returns an HTTP transport whose connections go through Dialer.
+// It ignores proxy settings, since a proxy would connect on its behalf.
+func (g *Guard) Transport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = g.Dialer(30 * time.Second).DialContext
+	return transport
+}
+
+// Client returns an HTTP client using Transport with an overall timeout,
+// or none if timeout is 0
+func (g *Guard) Client(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: g.Transport(), Timeout: timeout}
+}
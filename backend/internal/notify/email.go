@@ -0,0 +1,62 @@
+package notify
+
+import (
+	"fmt"
+	"net/smtp"
+	"strings"
+)
+
+// EmailSender sends HTML email through an SMTP server
+type EmailSender struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+}
+
+// NewEmailSender creates an SMTP sender. An empty host disables sending.
+func NewEmailSender(host string, port int, username, password, from string) *EmailSender {
+	return &EmailSender{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+// Enabled returns true if an SMTP host and sender address are configured
+func (e *EmailSender) Enabled() bool {
+	return e != nil && e.host != "" && e.from != ""
+}
+
+// SendHTML sends an HTML email to the recipients
+func (e *EmailSender) SendHTML(to []string, subject, html string) error {
+	if !e.Enabled() {
+		return fmt.Errorf("email sender is not configured")
+	}
+	if len(to) == 0 {
+		return fmt.Errorf("no recipients")
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", e.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/html; charset=\"UTF-8\"\r\n\r\n")
+	msg.WriteString(html)
+
+	var auth smtp.Auth
+	if e.username != "" {
+		auth = smtp.PlainAuth("", e.username, e.password, e.host)
+	}
+
+	addr := fmt.Sprintf("%s:%d", e.host, e.port)
+	if err := smtp.SendMail(addr, auth, e.from, to, []byte(msg.String())); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
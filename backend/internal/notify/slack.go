@@ -0,0 +1,193 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/deadletter"
+	"github.com/ai-support-assistant/backend/internal/lifecycle"
+	"github.com/sirupsen/logrus"
+)
+
+// Event types used for per-type rate limiting
+const (
+	EventNegativeFeedback = "negative_feedback"
+	EventIngestionFailed  = "ingestion_failed"
+)
+
+// SlackNotifier posts alerts to a Slack incoming webhook
+type SlackNotifier struct {
+	webhookURL string
+	interval   time.Duration
+	client     *http.Client
+	lifecycle  *lifecycle.Manager
+
+	mu     sync.Mutex
+	limits map[string]*eventLimit
+}
+
+// eventLimit tracks when an event type was last sent and how many were suppressed since
+type eventLimit struct {
+	lastSent   time.Time
+	suppressed int
+}
+
+// NewSlackNotifier creates a Slack notifier. An empty webhook URL disables sending.
+func NewSlackNotifier(webhookURL string, interval time.Duration, lc *lifecycle.Manager) *SlackNotifier {
+	return &SlackNotifier{
+		webhookURL: webhookURL,
+		interval:   interval,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		lifecycle: lc,
+		limits:    make(map[string]*eventLimit),
+	}
+}
+
+// Enabled returns true if a webhook URL is configured
+func (n *SlackNotifier) Enabled() bool {
+	return n != nil && n.webhookURL != ""
+}
+
+// NotifyNegativeFeedback sends an alert for a thumbs-down on an answer
+func (n *SlackNotifier) NotifyNegativeFeedback(queryID uint, query, response, comment string) {
+	text := fmt.Sprintf(":thumbsdown: *Negative feedback* on query #%d\n*Query:* %s\n*Response:* %s",
+		queryID, excerpt(query, 300), excerpt(response, 500))
+	if comment != "" {
+		text += fmt.Sprintf("\n*Comment:* %s", excerpt(comment, 500))
+	}
+	n.Notify(EventNegativeFeedback, text)
+}
+
+// NotifyIngestionFailed sends an alert for a document that failed to ingest
+func (n *SlackNotifier) NotifyIngestionFailed(docID uint, fileName, reason string) {
+	text := fmt.Sprintf(":warning: *Document ingestion failed* for #%d `%s`\n*Reason:* %s",
+		docID, fileName, excerpt(reason, 500))
+	n.Notify(EventIngestionFailed, text)
+}
+
+// Notify sends a message asynchronously, subject to per-type rate limiting.
+// Suppressed events are counted and reported with the next message of the same type.
+func (n *SlackNotifier) Notify(eventType, text string) {
+	if !n.Enabled() {
+		return
+	}
+
+	suppressed, ok := n.allow(eventType)
+	if !ok {
+		return
+	}
+
+	if suppressed > 0 {
+		text += fmt.Sprintf("\n_(%d similar event(s) suppressed since last alert)_", suppressed)
+	}
+
+	n.lifecycle.Go("slack_notification", logrus.Fields{"event": eventType}, func(ctx context.Context) {
+		n.deliver(ctx, eventType, text)
+	})
+}
+
+// slackPayload is the dead-letter payload of a failed notification
+type slackPayload struct {
+	Event string `json:"event"`
+	Text  string `json:"text"`
+}
+
+// deliver sends a message, dead-lettering it if Slack can't be reached
+func (n *SlackNotifier) deliver(ctx context.Context, eventType, text string) {
+	if err := n.send(ctx, eventType, text); err != nil {
+		deadletter.Record(ctx, deadletter.OperationSlackNotification, slackPayload{Event: eventType, Text: text}, 1, err)
+	}
+}
+
+// Replay resends a dead-lettered notification in the background, bypassing
+// the per-type rate limit
+func (n *SlackNotifier) Replay(ctx context.Context, payload json.RawMessage) error {
+	var p slackPayload
+	if err := json.Unmarshal(payload, &p); err != nil || p.Text == "" {
+		return fmt.Errorf("%w: invalid payload", deadletter.ErrNotReplayable)
+	}
+	if !n.Enabled() {
+		return fmt.Errorf("%w: Slack notifications are disabled", deadletter.ErrNotReplayable)
+	}
+
+	started := n.lifecycle.Go("slack_notification", logrus.Fields{"event": p.Event}, func(ctx context.Context) {
+		n.deliver(ctx, p.Event, p.Text)
+	})
+	if !started {
+		return errors.New("server is shutting down")
+	}
+	return nil
+}
+
+// allow reports whether an event may be sent now and how many were suppressed before it
+func (n *SlackNotifier) allow(eventType string) (int, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	limit, ok := n.limits[eventType]
+	if !ok {
+		limit = &eventLimit{}
+		n.limits[eventType] = limit
+	}
+
+	now := time.Now()
+	if !limit.lastSent.IsZero() && now.Sub(limit.lastSent) < n.interval {
+		limit.suppressed++
+		return 0, false
+	}
+
+	suppressed := limit.suppressed
+	limit.lastSent = now
+	limit.suppressed = 0
+	return suppressed, true
+}
+
+// send posts the message to Slack. Failures are logged and returned; a
+// message that couldn't be built is not worth retrying and returns nil.
+func (n *SlackNotifier) send(ctx context.Context, eventType, text string) error {
+	payload, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		logrus.WithError(err).Error("Failed to marshal Slack payload")
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", n.webhookURL, bytes.NewBuffer(payload))
+	if err != nil {
+		logrus.WithError(err).Error("Failed to create Slack request")
+		return nil
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		logrus.WithError(err).WithField("event", eventType).Warn("Failed to send Slack notification")
+		return fmt.Errorf("failed to send Slack notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		logrus.WithFields(logrus.Fields{
+			"event":  eventType,
+			"status": resp.StatusCode,
+		}).Warn("Slack webhook returned error")
+		return fmt.Errorf("slack webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// excerpt truncates text to max runes
+func excerpt(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max]) + "..."
+}
@@ -0,0 +1,254 @@
+// Package objectstore opens objects in S3, GCS or behind presigned HTTPS URLs
+// as streams, so they can be ingested without passing through the API.
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// Providers
+const (
+	ProviderS3    = "s3"
+	ProviderGCS   = "gcs"
+	ProviderHTTPS = "https"
+)
+
+// ErrUnreachable is returned when the object can't be fetched: the URL has
+// expired, access is denied, it doesn't exist or the host can't be reached
+var ErrUnreachable = errors.New("source unreachable")
+
+// Source locates an object either by bucket and key or by a (presigned) URL
+type Source struct {
+	Provider string
+	Bucket   string
+	Key      string
+	URL      string
+}
+
+// Object is an opened object; the caller must close Body
+type Object struct {
+	Body        io.ReadCloser
+	Size        int64 // -1 when unknown
+	ContentType string
+}
+
+// SourceError describes a non-OK response from the object store. Its message
+// never includes the URL, which may carry a signature.
+type SourceError struct {
+	StatusCode int
+	Expired    bool
+}
+
+func (e *SourceError) Error() string {
+	if e.Expired {
+		return "presigned URL has expired"
+	}
+	switch e.StatusCode {
+	case http.StatusForbidden, http.StatusUnauthorized:
+		return fmt.Sprintf("access denied (status %d)", e.StatusCode)
+	case http.StatusNotFound:
+		return "object not found"
+	default:
+		return fmt.Sprintf("object store returned status %d", e.StatusCode)
+	}
+}
+
+func (e *SourceError) Unwrap() error {
+	return ErrUnreachable
+}
+
+// DialFunc opens connections; see net.Dialer.DialContext
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// Client fetches objects, signing bucket and key requests with named credentials
+type Client struct {
+	http        *http.Client // for the configured S3 endpoint
+	public      *http.Client // for URLs and public cloud hosts
+	credentials map[string]Credentials
+	s3Region    string
+	s3Endpoint  string
+}
+
+// NewClient creates a client. An empty s3Endpoint uses AWS; otherwise
+// requests go path-style to the endpoint, e.g. for S3-compatible stores.
+// Requests anywhere but the configured endpoint, which operators chose, are
+// made through dial, so it can refuse addresses objects may not come from;
+// they don't use a proxy, which would connect on their behalf.
+func NewClient(credentials map[string]Credentials, s3Region, s3Endpoint string, dial DialFunc) *Client {
+	return &Client{
+		// No overall timeout: large objects stream for as long as the caller's context allows
+		http: &http.Client{
+			Transport: &http.Transport{
+				Proxy:                 http.ProxyFromEnvironment,
+				ResponseHeaderTimeout: 30 * time.Second,
+				TLSHandshakeTimeout:   10 * time.Second,
+			},
+		},
+		public: &http.Client{
+			Transport: &http.Transport{
+				DialContext:           dial,
+				ResponseHeaderTimeout: 30 * time.Second,
+				TLSHandshakeTimeout:   10 * time.Second,
+			},
+		},
+		credentials: credentials,
+		s3Region:    s3Region,
+		s3Endpoint:  strings.TrimRight(s3Endpoint, "/"),
+	}
+}
+
+// HasCredentials reports whether a named credential set is configured
+func (c *Client) HasCredentials(name string) bool {
+	_, ok := c.credentials[name]
+	return ok
+}
+
+// Open starts fetching an object. Bucket and key sources are signed with the
+// named credentials, or fetched anonymously when none are given.
+func (c *Client) Open(ctx context.Context, src Source, credentials string) (*Object, error) {
+	target, err := c.objectURL(src)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if credentials != "" && src.URL == "" {
+		creds, ok := c.credentials[credentials]
+		if !ok {
+			return nil, fmt.Errorf("unknown credentials %q", credentials)
+		}
+		region := c.s3Region
+		if src.Provider == ProviderGCS {
+			// GCS accepts AWS signatures made with HMAC keys; the region is ignored
+			region = "auto"
+		}
+		signV4(req, creds, region, time.Now().UTC())
+	}
+
+	client := c.public
+	if src.URL == "" && src.Provider == ProviderS3 && c.s3Endpoint != "" {
+		client = c.http
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// url.Error would repeat the URL, signature included
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrUnreachable, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, &SourceError{StatusCode: resp.StatusCode, Expired: isExpired(resp.StatusCode, string(body))}
+	}
+
+	return &Object{
+		Body:        resp.Body,
+		Size:        resp.ContentLength,
+		ContentType: resp.Header.Get("Content-Type"),
+	}, nil
+}
+
+// objectURL returns where to fetch the object
+func (c *Client) objectURL(src Source) (*url.URL, error) {
+	if src.URL != "" {
+		u, err := url.Parse(src.URL)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return nil, errors.New("url must be an absolute https URL")
+		}
+		return u, nil
+	}
+
+	if src.Bucket == "" || src.Key == "" {
+		return nil, errors.New("bucket and key are required without a url")
+	}
+
+	var base string
+	objectPath := "/" + src.Key
+	switch src.Provider {
+	case ProviderS3:
+		if c.s3Endpoint != "" {
+			base = c.s3Endpoint
+			objectPath = "/" + src.Bucket + objectPath
+		} else {
+			base = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", src.Bucket, c.s3Region)
+		}
+	case ProviderGCS:
+		base = "https://storage.googleapis.com"
+		objectPath = "/" + src.Bucket + objectPath
+	default:
+		return nil, fmt.Errorf("provider %s requires a url", src.Provider)
+	}
+
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint: %w", err)
+	}
+	u.Path = objectPath
+	u.RawPath = escapePath(objectPath)
+	return u, nil
+}
+
+// Name returns the object's file name: the last segment of its key or URL path
+func (src Source) Name() string {
+	key := src.Key
+	if src.URL != "" {
+		if u, err := url.Parse(src.URL); err == nil {
+			key = u.Path
+		}
+	}
+	name := path.Base(key)
+	if name == "." || name == "/" {
+		return ""
+	}
+	return name
+}
+
+// Location describes the object for the record without any query string,
+// so presigned signatures are never stored
+func (src Source) Location() string {
+	if src.URL != "" {
+		u, err := url.Parse(src.URL)
+		if err != nil {
+			return ""
+		}
+		u.RawQuery = ""
+		u.Fragment = ""
+		u.User = nil
+		return u.String()
+	}
+
+	scheme := "s3"
+	if src.Provider == ProviderGCS {
+		scheme = "gs"
+	}
+	return scheme + "://" + src.Bucket + "/" + src.Key
+}
+
+// isExpired recognizes S3 and GCS responses to expired presigned URLs
+func isExpired(status int, body string) bool {
+	if status != http.StatusForbidden && status != http.StatusBadRequest {
+		return false
+	}
+	body = strings.ToLower(body)
+	return strings.Contains(body, "request has expired") || strings.Contains(body, "expiredtoken")
+}
@@ -0,0 +1,47 @@
+package postprocess
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Source identifies a document a response was generated from
+type Source struct {
+	FileName      string
+	VectorStoreID string
+}
+
+// Response is a generated answer being cleaned up before it is stored,
+// cached and returned
+type Response struct {
+	Text    string
+	Sources []Source
+}
+
+// Step is one post-processing stage
+type Step interface {
+	Name() string
+	Process(ctx context.Context, resp *Response) error
+}
+
+// Pipeline runs steps in order. A failing step is logged and skipped so a
+// bad rewrite never loses the answer.
+type Pipeline struct {
+	steps []Step
+}
+
+func NewPipeline(steps ...Step) *Pipeline {
+	return &Pipeline{steps: steps}
+}
+
+// Process runs every step over the response
+func (p *Pipeline) Process(ctx context.Context, resp *Response) {
+	for _, step := range p.steps {
+		text := resp.Text
+		if err := step.Process(ctx, resp); err != nil {
+			logrus.WithError(err).WithField("step", step.Name()).Warn("Response post-processing step failed")
+			resp.Text = text
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package postprocess
+
+import (
+	"context"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// SanitizeHTML removes script, style and iframe elements along with their
+// content. Unterminated tags are dropped, leaving their content as plain text.
+type SanitizeHTML struct{}
+
+var (
+	unsafeElementPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?is)<script\b[^>]*>.*?</script\s*>`),
+		regexp.MustCompile(`(?is)<style\b[^>]*>.*?</style\s*>`),
+		regexp.MustCompile(`(?is)<iframe\b[^>]*>.*?</iframe\s*>`),
+	}
+	unsafeTagPattern = regexp.MustCompile(`(?i)</?(script|style|iframe)\b[^>]*>?`)
+)
+
+func (SanitizeHTML) Name() string { return "sanitize_html" }
+
+func (SanitizeHTML) Process(ctx context.Context, resp *Response) error {
+	text := resp.Text
+	for _, pattern := range unsafeElementPatterns {
+		text = pattern.ReplaceAllString(text, "")
+	}
+	// Whatever survives is an unbalanced tag; dropping the tag alone leaves its content inert
+	resp.Text = unsafeTagPattern.ReplaceAllString(text, "")
+	return nil
+}
+
+// NormalizeMarkdown closes unterminated code fences and collapses runs of
+// more than two blank lines outside code blocks
+type NormalizeMarkdown struct{}
+
+func (NormalizeMarkdown) Name() string { return "normalize_markdown" }
+
+func (NormalizeMarkdown) Process(ctx context.Context, resp *Response) error {
+	lines := strings.Split(resp.Text, "\n")
+	out := make([]string, 0, len(lines))
+	inFence := false
+	blank := 0
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+		}
+
+		if !inFence && trimmed == "" {
+			blank++
+			if blank > 2 {
+				continue
+			}
+		} else {
+			blank = 0
+		}
+		out = append(out, line)
+	}
+
+	text := strings.Join(out, "\n")
+	if inFence {
+		if !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		text += "```"
+	}
+
+	resp.Text = text
+	return nil
+}
+
+// DocumentLinks rewrites file:// references into links to the document API.
+// Resolve maps referenced file names to document IDs, using the response's
+// sources to disambiguate; references it can't resolve are unlinked since
+// they would point at the RAG service's filesystem.
+type DocumentLinks struct {
+	Resolve func(ctx context.Context, fileNames []string, sources []Source) (map[string]uint, error)
+}
+
+var (
+	markdownFileLinkPattern = regexp.MustCompile(`\[([^\]]*)\]\(\s*<?(file://[^)\s>]*)>?\s*\)`)
+	bareFileLinkPattern     = regexp.MustCompile(`file://[^\s)\]>"'<]+`)
+)
+
+func (DocumentLinks) Name() string { return "document_links" }
+
+func (d DocumentLinks) Process(ctx context.Context, resp *Response) error {
+	refs := bareFileLinkPattern.FindAllString(resp.Text, -1)
+	if len(refs) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, ref := range refs {
+		ref = strings.TrimRight(ref, trailingPunctuation)
+		if name := fileName(ref); name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	ids, err := d.Resolve(ctx, names, resp.Sources)
+	if err != nil {
+		return err
+	}
+
+	text := markdownFileLinkPattern.ReplaceAllStringFunc(resp.Text, func(match string) string {
+		parts := markdownFileLinkPattern.FindStringSubmatch(match)
+		label, ref := parts[1], parts[2]
+		if id, ok := ids[fileName(ref)]; ok {
+			return "[" + label + "](" + documentURL(id) + ")"
+		}
+		if label == "" {
+			return fileName(ref)
+		}
+		return label
+	})
+
+	resp.Text = bareFileLinkPattern.ReplaceAllStringFunc(text, func(match string) string {
+		ref := strings.TrimRight(match, trailingPunctuation)
+		suffix := match[len(ref):]
+		name := fileName(ref)
+		if id, ok := ids[name]; ok {
+			return "[" + name + "](" + documentURL(id) + ")" + suffix
+		}
+		return name + suffix
+	})
+	return nil
+}
+
+// trailingPunctuation ends a sentence rather than a bare file reference
+const trailingPunctuation = ".,;:!?"
+
+// fileName returns the base name of a file:// reference
+func fileName(ref string) string {
+	name := path.Base(strings.TrimPrefix(ref, "file://"))
+	if name == "." || name == "/" {
+		return ""
+	}
+	return name
+}
+
+// documentURL returns the API path of a document
+func documentURL(id uint) string {
+	return "/api/docs/" + strconv.FormatUint(uint64(id), 10)
+}
+
+// Truncate shortens responses longer than MaxLength characters at a word
+// boundary and appends Marker. Run it before NormalizeMarkdown so a code
+// fence cut in half is closed again.
+type Truncate struct {
+	MaxLength int
+	Marker    string
+}
+
+func (Truncate) Name() string { return "truncate" }
+
+func (t Truncate) Process(ctx context.Context, resp *Response) error {
+	if t.MaxLength <= 0 || utf8.RuneCountInString(resp.Text) <= t.MaxLength {
+		return nil
+	}
+
+	runes := []rune(resp.Text)
+	cut := string(runes[:t.MaxLength])
+	if i := strings.LastIndexAny(cut, " \n\t"); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+
+	resp.Text = strings.TrimRight(cut, " \n\t") + t.Marker
+	return nil
+}
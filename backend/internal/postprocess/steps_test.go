@@ -0,0 +1,150 @@
+package postprocess
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// process runs step over text and returns the result
+func process(t *testing.T, step Step, text string) string {
+	t.Helper()
+	resp := &Response{Text: text}
+	if err := step.Process(context.Background(), resp); err != nil {
+		t.Fatalf("%s: %v", step.Name(), err)
+	}
+	return resp.Text
+}
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"script", `Hi<script>alert(1)</script> there`, "Hi there"},
+		{"style with attributes", `<style type="text/css">body{}</style>Text`, "Text"},
+		{"iframe across lines", "a<IFRAME src=\"x\">\nframe\n</iframe >b", "ab"},
+		{"unterminated script", `Hi <script>alert(1)`, "Hi alert(1)"},
+		{"stray closing tag", `Hi</script> there`, "Hi there"},
+		{"tag without closing bracket", `Hi <script`, "Hi "},
+		{"safe markup kept", `<b>bold</b> and <a href="/x">link</a>`, `<b>bold</b> and <a href="/x">link</a>`},
+		{"similar tag names kept", `<scripts>list</scripts>`, `<scripts>list</scripts>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := process(t, SanitizeHTML{}, tt.in); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"balanced", "text\n```\ncode\n```", "text\n```\ncode\n```"},
+		{"unterminated fence", "text\n```go\ncode", "text\n```go\ncode\n```"},
+		{"unterminated fence ending in newline", "```\ncode\n", "```\ncode\n```"},
+		{"second fence unterminated", "```\na\n```\n```\nb", "```\na\n```\n```\nb\n```"},
+		{"blank lines collapsed", "a\n\n\n\n\nb", "a\n\n\nb"},
+		{"whitespace lines are blank", "a\n \n\t\n  \nb", "a\n \n\t\nb"},
+		{"blank lines kept in code", "```\na\n\n\n\n\nb\n```", "```\na\n\n\n\n\nb\n```"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := process(t, NormalizeMarkdown{}, tt.in); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDocumentLinks(t *testing.T) {
+	var resolved []string
+	links := DocumentLinks{Resolve: func(ctx context.Context, fileNames []string, sources []Source) (map[string]uint, error) {
+		resolved = fileNames
+		return map[string]uint{"guide.pdf": 7}, nil
+	}}
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"markdown link", "See [the guide](file:///data/docs/guide.pdf).", "See [the guide](/api/docs/7)."},
+		{"angle-bracketed link", "See [guide](<file:///data/guide.pdf>)", "See [guide](/api/docs/7)"},
+		{"bare reference", "See file:///data/guide.pdf.", "See [guide.pdf](/api/docs/7)."},
+		{"unknown markdown link unlinked", "See [notes](file:///tmp/notes.txt)", "See notes"},
+		{"unknown empty label", "See [](file:///tmp/notes.txt)", "See notes.txt"},
+		{"unknown bare reference", "From file:///tmp/notes.txt, we know", "From notes.txt, we know"},
+		{"no references", "Nothing to rewrite", "Nothing to rewrite"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := process(t, links, tt.in); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Each file is resolved once, without its trailing punctuation
+	process(t, links, "file:///a/guide.pdf, then file:///b/guide.pdf and file:///c/faq.md.")
+	if strings.Join(resolved, ",") != "guide.pdf,faq.md" {
+		t.Errorf("resolved %v, want [guide.pdf faq.md]", resolved)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name string
+		max  int
+		in   string
+		want string
+	}{
+		{"short enough", 20, "short answer", "short answer"},
+		{"exactly at limit", 5, "abcde", "abcde"},
+		{"cut at word boundary", 12, "one two three four", "one two…"},
+		{"no usable boundary", 5, "abcdefghij", "abcde…"},
+		{"counts runes not bytes", 3, "日本語テキスト", "日本語…"},
+		{"disabled", 0, "one two three", "one two three"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := process(t, Truncate{MaxLength: tt.max, Marker: "…"}, tt.in); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Truncating inside a code block and then normalizing closes the fence again
+func TestTruncateThenNormalize(t *testing.T) {
+	resp := &Response{Text: "Run this:\n```\nmake build && make test && make deploy\n```"}
+	NewPipeline(Truncate{MaxLength: 30, Marker: " …"}, NormalizeMarkdown{}).Process(context.Background(), resp)
+	if !strings.HasSuffix(resp.Text, "\n```") || strings.Count(resp.Text, "```") != 2 {
+		t.Errorf("got %q, want the code fence closed", resp.Text)
+	}
+}
+
+type failingStep struct{}
+
+func (failingStep) Name() string { return "failing" }
+
+func (failingStep) Process(ctx context.Context, resp *Response) error {
+	resp.Text = "half-rewritten"
+	return errors.New("resolver unavailable")
+}
+
+func TestPipelineSkipsFailingStep(t *testing.T) {
+	resp := &Response{Text: "Hi<script>x</script>\n```\ncode"}
+	NewPipeline(SanitizeHTML{}, failingStep{}, NormalizeMarkdown{}).Process(context.Background(), resp)
+	if want := "Hi\n```\ncode\n```"; resp.Text != want {
+		t.Errorf("got %q, want %q", resp.Text, want)
+	}
+}
@@ -0,0 +1,321 @@
+package ragclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Client calls auxiliary endpoints on the Python RAG service
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient creates a RAG service client
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL: baseURL,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// Chunk identifies a retrieved context chunk by a hash of its text
+type Chunk struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+}
+
+// BadRetrievalReport tells the RAG service which chunks produced a poorly rated answer
+type BadRetrievalReport struct {
+	QueryID uint    `json:"query_id"`
+	Query   string  `json:"query"`
+	Chunks  []Chunk `json:"chunks"`
+	Comment string  `json:"comment,omitempty"`
+}
+
+// ChunkID returns the stable identifier for a chunk's text
+func ChunkID(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// ReportBadRetrieval posts a bad retrieval report so the RAG service can
+// down-weight or re-chunk the offending chunks
+func (c *Client) ReportBadRetrieval(ctx context.Context, report BadRetrievalReport) error {
+	url := fmt.Sprintf("%s/rag/retrieval-feedback", c.baseURL)
+
+	jsonData, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send report: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("RAG service returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+// FeedbackClassification asks the RAG service to assign a comment to one of themes
+type FeedbackClassification struct {
+	Comment string   `json:"comment"`
+	Query   string   `json:"query,omitempty"`
+	Themes  []string `json:"themes"`
+}
+
+// ClassifyFeedback returns the theme the RAG service assigns to a feedback comment
+func (c *Client) ClassifyFeedback(ctx context.Context, classification FeedbackClassification) (string, error) {
+	url := fmt.Sprintf("%s/rag/classify-feedback", c.baseURL)
+
+	jsonData, err := json.Marshal(classification)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal classification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to classify feedback: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("RAG service returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Theme string `json:"theme"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode classification: %w", err)
+	}
+
+	return result.Theme, nil
+}
+
+// GroundingCheck asks the RAG service whether an answer is supported by the
+// context it was generated from
+type GroundingCheck struct {
+	Query   string   `json:"query"`
+	Answer  string   `json:"answer"`
+	Context []string `json:"context"`
+}
+
+// Grounding is the RAG service's verdict on an answer: a score from 0 (no
+// claim supported) to 1 (every claim supported) and the sentences the
+// context doesn't support
+type Grounding struct {
+	Score       float64  `json:"grounding_score"`
+	Unsupported []string `json:"unsupported_sentences"`
+}
+
+// VerifyGrounding checks how well an answer is grounded in its context
+func (c *Client) VerifyGrounding(ctx context.Context, check GroundingCheck) (*Grounding, error) {
+	url := fmt.Sprintf("%s/rag/verify", c.baseURL)
+
+	jsonData, err := json.Marshal(check)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal grounding check: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify grounding: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("RAG service returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result Grounding
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode grounding: %w", err)
+	}
+	if result.Score < 0 || result.Score > 1 {
+		return nil, fmt.Errorf("grounding score %v out of range", result.Score)
+	}
+
+	return &result, nil
+}
+
+// Translation asks the RAG service to translate an answer into Target, an
+// ISO 639-1 code; Source is the detected language of the text, if known
+type Translation struct {
+	Text   string `json:"text"`
+	Source string `json:"source_language,omitempty"`
+	Target string `json:"target_language"`
+}
+
+// Translate returns the text translated into the target language
+func (c *Client) Translate(ctx context.Context, translation Translation) (string, error) {
+	url := fmt.Sprintf("%s/rag/translate", c.baseURL)
+
+	jsonData, err := json.Marshal(translation)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal translation: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to translate: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("RAG service returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode translation: %w", err)
+	}
+	if result.Text == "" {
+		return "", fmt.Errorf("empty translation")
+	}
+
+	return result.Text, nil
+}
+
+// Embed returns an embedding vector per text, in order
+func (c *Client) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	url := fmt.Sprintf("%s/rag/embed", c.baseURL)
+
+	jsonData, err := json.Marshal(map[string][]string{"texts": texts})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal embedding request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed texts: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("RAG service returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Embeddings [][]float64 `json:"embeddings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode embeddings: %w", err)
+	}
+	if len(result.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("got %d embeddings for %d texts", len(result.Embeddings), len(texts))
+	}
+
+	return result.Embeddings, nil
+}
+
+// RetrievalDebugRequest asks the RAG service what it knows about the
+// retrieval behind an answer: the query and the chunks it was given
+type RetrievalDebugRequest struct {
+	Query  string  `json:"query"`
+	Chunks []Chunk `json:"chunks"`
+}
+
+// ChunkDebug is the RAG service's view of a retrieved chunk: the document it
+// came from and how it scored; fields it can't provide are left out
+type ChunkDebug struct {
+	ID       string                 `json:"id"`
+	DocID    string                 `json:"doc_id,omitempty"`
+	Source   string                 `json:"source,omitempty"`
+	Score    *float64               `json:"score,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// RetrievalDebug is the retrieval-side debug information for an answer.
+// Details are whatever else the RAG service reports, such as its retriever
+// settings.
+type RetrievalDebug struct {
+	Chunks  []ChunkDebug           `json:"chunks"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// DebugRetrieval returns the RAG service's debug information for the chunks
+// an answer was generated from
+func (c *Client) DebugRetrieval(ctx context.Context, debugReq RetrievalDebugRequest) (*RetrievalDebug, error) {
+	url := fmt.Sprintf("%s/rag/debug", c.baseURL)
+
+	jsonData, err := json.Marshal(debugReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal debug request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get retrieval debug info: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("RAG service returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result RetrievalDebug
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode retrieval debug info: %w", err)
+	}
+
+	return &result, nil
+}
@@ -0,0 +1,424 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: internal/ragclient/grpc/rag.proto
+
+// The RAG service contract over gRPC. Messages mirror the JSON bodies of
+// /rag/query and /rag/ingest so either transport can serve the orchestrator.
+//
+// Regenerate the Go stubs from the backend directory with:
+//
+//   protoc --go_out=. --go_opt=module=github.com/ai-support-assistant/backend \
+//     --go-grpc_out=. --go-grpc_opt=module=github.com/ai-support-assistant/backend \
+//     internal/ragclient/grpc/rag.proto
+
+package ragpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	RAGService_Query_FullMethodName          = "/rag.v1.RAGService/Query"
+	RAGService_QueryStream_FullMethodName    = "/rag.v1.RAGService/QueryStream"
+	RAGService_Ingest_FullMethodName         = "/rag.v1.RAGService/Ingest"
+	RAGService_IngestProgress_FullMethodName = "/rag.v1.RAGService/IngestProgress"
+	RAGService_DeleteDocument_FullMethodName = "/rag.v1.RAGService/DeleteDocument"
+	RAGService_UpdateDocument_FullMethodName = "/rag.v1.RAGService/UpdateDocument"
+	RAGService_Generation_FullMethodName     = "/rag.v1.RAGService/Generation"
+)
+
+// RAGServiceClient is the client API for RAGService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type RAGServiceClient interface {
+	// Query answers a question in one response
+	Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryResponse, error)
+	// QueryStream answers a question as generated tokens, ending with a
+	// message carrying the complete response
+	QueryStream(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (RAGService_QueryStreamClient, error)
+	// Ingest receives a document's metadata followed by its content in chunks
+	Ingest(ctx context.Context, opts ...grpc.CallOption) (RAGService_IngestClient, error)
+	// IngestProgress reports how far an ingestion started with a job ID has
+	// got. Services that don't track progress may leave it unimplemented.
+	IngestProgress(ctx context.Context, in *IngestProgressRequest, opts ...grpc.CallOption) (*IngestProgressResponse, error)
+	// DeleteDocument removes an ingested document's chunks from the vector store
+	DeleteDocument(ctx context.Context, in *DeleteDocumentRequest, opts ...grpc.CallOption) (*DeleteDocumentResponse, error)
+	// UpdateDocument replaces metadata fields on an ingested document's chunks
+	UpdateDocument(ctx context.Context, in *UpdateDocumentRequest, opts ...grpc.CallOption) (*UpdateDocumentResponse, error)
+	// Generation returns what has been generated for a query sent with a
+	// generation ID, NotFound when the service doesn't know or no longer keeps
+	// it. Services that don't keep generations may leave it unimplemented.
+	Generation(ctx context.Context, in *GenerationRequest, opts ...grpc.CallOption) (*GenerationResponse, error)
+}
+
+type rAGServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRAGServiceClient(cc grpc.ClientConnInterface) RAGServiceClient {
+	return &rAGServiceClient{cc}
+}
+
+func (c *rAGServiceClient) Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryResponse, error) {
+	out := new(QueryResponse)
+	err := c.cc.Invoke(ctx, RAGService_Query_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rAGServiceClient) QueryStream(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (RAGService_QueryStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &RAGService_ServiceDesc.Streams[0], RAGService_QueryStream_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &rAGServiceQueryStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RAGService_QueryStreamClient interface {
+	Recv() (*QueryChunk, error)
+	grpc.ClientStream
+}
+
+type rAGServiceQueryStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *rAGServiceQueryStreamClient) Recv() (*QueryChunk, error) {
+	m := new(QueryChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *rAGServiceClient) Ingest(ctx context.Context, opts ...grpc.CallOption) (RAGService_IngestClient, error) {
+	stream, err := c.cc.NewStream(ctx, &RAGService_ServiceDesc.Streams[1], RAGService_Ingest_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &rAGServiceIngestClient{stream}
+	return x, nil
+}
+
+type RAGService_IngestClient interface {
+	Send(*IngestRequest) error
+	CloseAndRecv() (*IngestResponse, error)
+	grpc.ClientStream
+}
+
+type rAGServiceIngestClient struct {
+	grpc.ClientStream
+}
+
+func (x *rAGServiceIngestClient) Send(m *IngestRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *rAGServiceIngestClient) CloseAndRecv() (*IngestResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(IngestResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *rAGServiceClient) IngestProgress(ctx context.Context, in *IngestProgressRequest, opts ...grpc.CallOption) (*IngestProgressResponse, error) {
+	out := new(IngestProgressResponse)
+	err := c.cc.Invoke(ctx, RAGService_IngestProgress_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rAGServiceClient) DeleteDocument(ctx context.Context, in *DeleteDocumentRequest, opts ...grpc.CallOption) (*DeleteDocumentResponse, error) {
+	out := new(DeleteDocumentResponse)
+	err := c.cc.Invoke(ctx, RAGService_DeleteDocument_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rAGServiceClient) UpdateDocument(ctx context.Context, in *UpdateDocumentRequest, opts ...grpc.CallOption) (*UpdateDocumentResponse, error) {
+	out := new(UpdateDocumentResponse)
+	err := c.cc.Invoke(ctx, RAGService_UpdateDocument_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rAGServiceClient) Generation(ctx context.Context, in *GenerationRequest, opts ...grpc.CallOption) (*GenerationResponse, error) {
+	out := new(GenerationResponse)
+	err := c.cc.Invoke(ctx, RAGService_Generation_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RAGServiceServer is the server API for RAGService service.
+// All implementations must embed UnimplementedRAGServiceServer
+// for forward compatibility
+type RAGServiceServer interface {
+	// Query answers a question in one response
+	Query(context.Context, *QueryRequest) (*QueryResponse, error)
+	// QueryStream answers a question as generated tokens, ending with a
+	// message carrying the complete response
+	QueryStream(*QueryRequest, RAGService_QueryStreamServer) error
+	// Ingest receives a document's metadata followed by its content in chunks
+	Ingest(RAGService_IngestServer) error
+	// IngestProgress reports how far an ingestion started with a job ID has
+	// got. Services that don't track progress may leave it unimplemented.
+	IngestProgress(context.Context, *IngestProgressRequest) (*IngestProgressResponse, error)
+	// DeleteDocument removes an ingested document's chunks from the vector store
+	DeleteDocument(context.Context, *DeleteDocumentRequest) (*DeleteDocumentResponse, error)
+	// UpdateDocument replaces metadata fields on an ingested document's chunks
+	UpdateDocument(context.Context, *UpdateDocumentRequest) (*UpdateDocumentResponse, error)
+	// Generation returns what has been generated for a query sent with a
+	// generation ID, NotFound when the service doesn't know or no longer keeps
+	// it. Services that don't keep generations may leave it unimplemented.
+	Generation(context.Context, *GenerationRequest) (*GenerationResponse, error)
+	mustEmbedUnimplementedRAGServiceServer()
+}
+
+// UnimplementedRAGServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedRAGServiceServer struct {
+}
+
+func (UnimplementedRAGServiceServer) Query(context.Context, *QueryRequest) (*QueryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Query not implemented")
+}
+func (UnimplementedRAGServiceServer) QueryStream(*QueryRequest, RAGService_QueryStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method QueryStream not implemented")
+}
+func (UnimplementedRAGServiceServer) Ingest(RAGService_IngestServer) error {
+	return status.Errorf(codes.Unimplemented, "method Ingest not implemented")
+}
+func (UnimplementedRAGServiceServer) IngestProgress(context.Context, *IngestProgressRequest) (*IngestProgressResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method IngestProgress not implemented")
+}
+func (UnimplementedRAGServiceServer) DeleteDocument(context.Context, *DeleteDocumentRequest) (*DeleteDocumentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteDocument not implemented")
+}
+func (UnimplementedRAGServiceServer) UpdateDocument(context.Context, *UpdateDocumentRequest) (*UpdateDocumentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateDocument not implemented")
+}
+func (UnimplementedRAGServiceServer) Generation(context.Context, *GenerationRequest) (*GenerationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Generation not implemented")
+}
+func (UnimplementedRAGServiceServer) mustEmbedUnimplementedRAGServiceServer() {}
+
+// UnsafeRAGServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RAGServiceServer will
+// result in compilation errors.
+type UnsafeRAGServiceServer interface {
+	mustEmbedUnimplementedRAGServiceServer()
+}
+
+func RegisterRAGServiceServer(s grpc.ServiceRegistrar, srv RAGServiceServer) {
+	s.RegisterService(&RAGService_ServiceDesc, srv)
+}
+
+func _RAGService_Query_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RAGServiceServer).Query(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RAGService_Query_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RAGServiceServer).Query(ctx, req.(*QueryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RAGService_QueryStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(QueryRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RAGServiceServer).QueryStream(m, &rAGServiceQueryStreamServer{stream})
+}
+
+type RAGService_QueryStreamServer interface {
+	Send(*QueryChunk) error
+	grpc.ServerStream
+}
+
+type rAGServiceQueryStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *rAGServiceQueryStreamServer) Send(m *QueryChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _RAGService_Ingest_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(RAGServiceServer).Ingest(&rAGServiceIngestServer{stream})
+}
+
+type RAGService_IngestServer interface {
+	SendAndClose(*IngestResponse) error
+	Recv() (*IngestRequest, error)
+	grpc.ServerStream
+}
+
+type rAGServiceIngestServer struct {
+	grpc.ServerStream
+}
+
+func (x *rAGServiceIngestServer) SendAndClose(m *IngestResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *rAGServiceIngestServer) Recv() (*IngestRequest, error) {
+	m := new(IngestRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _RAGService_IngestProgress_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IngestProgressRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RAGServiceServer).IngestProgress(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RAGService_IngestProgress_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RAGServiceServer).IngestProgress(ctx, req.(*IngestProgressRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RAGService_DeleteDocument_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteDocumentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RAGServiceServer).DeleteDocument(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RAGService_DeleteDocument_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RAGServiceServer).DeleteDocument(ctx, req.(*DeleteDocumentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RAGService_UpdateDocument_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateDocumentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RAGServiceServer).UpdateDocument(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RAGService_UpdateDocument_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RAGServiceServer).UpdateDocument(ctx, req.(*UpdateDocumentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RAGService_Generation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GenerationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RAGServiceServer).Generation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RAGService_Generation_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RAGServiceServer).Generation(ctx, req.(*GenerationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// RAGService_ServiceDesc is the grpc.ServiceDesc for RAGService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RAGService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "rag.v1.RAGService",
+	HandlerType: (*RAGServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Query",
+			Handler:    _RAGService_Query_Handler,
+		},
+		{
+			MethodName: "IngestProgress",
+			Handler:    _RAGService_IngestProgress_Handler,
+		},
+		{
+			MethodName: "DeleteDocument",
+			Handler:    _RAGService_DeleteDocument_Handler,
+		},
+		{
+			MethodName: "UpdateDocument",
+			Handler:    _RAGService_UpdateDocument_Handler,
+		},
+		{
+			MethodName: "Generation",
+			Handler:    _RAGService_Generation_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "QueryStream",
+			Handler:       _RAGService_QueryStream_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Ingest",
+			Handler:       _RAGService_Ingest_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "internal/ragclient/grpc/rag.proto",
+}
@@ -0,0 +1,130 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamdChunkSize is how much content is sent per INSTREAM chunk
+const clamdChunkSize = 64 * 1024
+
+// maxClamdReply caps the reply read from clamd
+const maxClamdReply = 4096
+
+// ClamAV scans content with a clamd daemon over its TCP protocol
+type ClamAV struct {
+	addr    string
+	timeout time.Duration
+	dialer  net.Dialer
+}
+
+// NewClamAV creates a scanner for the clamd daemon at addr (host:port);
+// each scan, including sending the content, must finish within timeout
+func NewClamAV(addr string, timeout time.Duration) *ClamAV {
+	return &ClamAV{addr: addr, timeout: timeout}
+}
+
+// Scan streams the content to clamd with the INSTREAM command and reports
+// whether a signature matched. The engine version is looked up after the
+// scan; failing to get it doesn't fail the scan.
+func (c *ClamAV) Scan(ctx context.Context, content io.Reader) (*Result, error) {
+	reply, err := c.command(ctx, "INSTREAM", content)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := parseScanReply(reply)
+	if err != nil {
+		return nil, err
+	}
+	if version, err := c.command(ctx, "VERSION", nil); err == nil {
+		result.Engine = version
+	}
+	return result, nil
+}
+
+// command sends a clamd command on a new connection, followed by the
+// content in length-prefixed chunks if there is any, and returns the reply
+func (c *ClamAV) command(ctx context.Context, name string, content io.Reader) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	conn, err := c.dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	// The z prefix makes clamd expect and send null-terminated lines
+	if _, err := conn.Write([]byte("z" + name + "\x00")); err != nil {
+		return "", fmt.Errorf("%w: failed to send %s: %v", ErrUnavailable, name, err)
+	}
+	if content != nil {
+		if err := writeChunks(conn, content); err != nil {
+			return "", err
+		}
+	}
+
+	reply, err := bufio.NewReader(io.LimitReader(conn, maxClamdReply)).ReadString(0)
+	if err != nil && !(err == io.EOF && reply != "") {
+		return "", fmt.Errorf("%w: failed to read %s reply: %v", ErrUnavailable, name, err)
+	}
+	return strings.TrimSpace(strings.TrimSuffix(reply, "\x00")), nil
+}
+
+// writeChunks sends content as INSTREAM chunks, each prefixed with its length
+// as a 4-byte big-endian integer, ending with a zero-length chunk
+func writeChunks(w io.Writer, content io.Reader) error {
+	buf := make([]byte, clamdChunkSize)
+	var size [4]byte
+	for {
+		n, readErr := content.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size[:], uint32(n))
+			if _, err := w.Write(size[:]); err != nil {
+				return fmt.Errorf("%w: failed to send content: %v", ErrUnavailable, err)
+			}
+			if _, err := w.Write(buf[:n]); err != nil {
+				return fmt.Errorf("%w: failed to send content: %v", ErrUnavailable, err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return fmt.Errorf("failed to read content: %w", readErr)
+		}
+	}
+
+	binary.BigEndian.PutUint32(size[:], 0)
+	if _, err := w.Write(size[:]); err != nil {
+		return fmt.Errorf("%w: failed to send content: %v", ErrUnavailable, err)
+	}
+	return nil
+}
+
+// parseScanReply reads an INSTREAM reply: "stream: OK", "stream: <signature>
+// FOUND" or "<reason> ERROR", e.g. when the stream exceeds clamd's
+// StreamMaxLength
+func parseScanReply(reply string) (*Result, error) {
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return &Result{Verdict: VerdictClean}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return &Result{Verdict: VerdictInfected, Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	case strings.HasSuffix(reply, " ERROR"):
+		return nil, fmt.Errorf("%w: clamd: %s", ErrUnavailable, reply)
+	default:
+		return nil, fmt.Errorf("%w: unexpected clamd reply %q", ErrUnavailable, reply)
+	}
+}
@@ -0,0 +1,177 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// eicar is the standard antivirus test string
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeClamd answers INSTREAM with FOUND when the streamed content contains
+// the EICAR string and OK otherwise, and VERSION with a fixed engine
+func fakeClamd(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveClamd(conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func serveClamd(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	command, err := r.ReadString(0)
+	if err != nil {
+		return
+	}
+
+	switch strings.TrimSuffix(command, "\x00") {
+	case "zVERSION":
+		conn.Write([]byte("ClamAV 1.2.0/27000\x00"))
+	case "zINSTREAM":
+		var content bytes.Buffer
+		for {
+			var size [4]byte
+			if _, err := io.ReadFull(r, size[:]); err != nil {
+				return
+			}
+			n := binary.BigEndian.Uint32(size[:])
+			if n == 0 {
+				break
+			}
+			if _, err := io.CopyN(&content, r, int64(n)); err != nil {
+				return
+			}
+		}
+		if strings.Contains(content.String(), eicar) {
+			conn.Write([]byte("stream: Eicar-Signature FOUND\x00"))
+		} else {
+			conn.Write([]byte("stream: OK\x00"))
+		}
+	}
+}
+
+func TestClamAVScan(t *testing.T) {
+	scanner := NewClamAV(fakeClamd(t), 5*time.Second)
+
+	tests := []struct {
+		name      string
+		content   string
+		verdict   string
+		signature string
+	}{
+		{"clean", "How do I reset my password?", VerdictClean, ""},
+		{"infected", "prefix " + eicar, VerdictInfected, "Eicar-Signature"},
+		// Larger than one chunk, so the content is streamed in pieces
+		{"multiple chunks", strings.Repeat("a", 3*clamdChunkSize+17), VerdictClean, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := scanner.Scan(context.Background(), strings.NewReader(tt.content))
+			if err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			if result.Verdict != tt.verdict || result.Signature != tt.signature {
+				t.Errorf("Scan = %+v, want verdict %s signature %q", result, tt.verdict, tt.signature)
+			}
+			if result.Engine != "ClamAV 1.2.0/27000" {
+				t.Errorf("Engine = %q", result.Engine)
+			}
+		})
+	}
+}
+
+func TestClamAVUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	_, err = NewClamAV(addr, time.Second).Scan(context.Background(), strings.NewReader("content"))
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Scan error = %v, want ErrUnavailable", err)
+	}
+}
+
+func TestParseScanReply(t *testing.T) {
+	tests := []struct {
+		reply       string
+		verdict     string
+		signature   string
+		unavailable bool
+	}{
+		{"stream: OK", VerdictClean, "", false},
+		{"stream: Win.Test.EICAR_HDB-1 FOUND", VerdictInfected, "Win.Test.EICAR_HDB-1", false},
+		{"INSTREAM size limit exceeded. ERROR", "", "", true},
+		{"garbage", "", "", true},
+	}
+	for _, tt := range tests {
+		result, err := parseScanReply(tt.reply)
+		if tt.unavailable {
+			if !errors.Is(err, ErrUnavailable) {
+				t.Errorf("parseScanReply(%q) error = %v, want ErrUnavailable", tt.reply, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseScanReply(%q): %v", tt.reply, err)
+			continue
+		}
+		if result.Verdict != tt.verdict || result.Signature != tt.signature {
+			t.Errorf("parseScanReply(%q) = %+v", tt.reply, result)
+		}
+	}
+}
+
+func TestWriteChunks(t *testing.T) {
+	var buf bytes.Buffer
+	content := strings.Repeat("x", clamdChunkSize+10)
+	if err := writeChunks(&buf, strings.NewReader(content)); err != nil {
+		t.Fatalf("writeChunks: %v", err)
+	}
+
+	var got bytes.Buffer
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(&buf, size[:]); err != nil {
+			t.Fatalf("missing terminating chunk: %v", err)
+		}
+		n := binary.BigEndian.Uint32(size[:])
+		if n == 0 {
+			break
+		}
+		if n > clamdChunkSize {
+			t.Fatalf("chunk of %d bytes exceeds %d", n, clamdChunkSize)
+		}
+		io.CopyN(&got, &buf, int64(n))
+	}
+	if got.String() != content {
+		t.Errorf("reassembled %d bytes, want %d", got.Len(), len(content))
+	}
+	if buf.Len() != 0 {
+		t.Errorf("%d bytes after the terminating chunk", buf.Len())
+	}
+}
@@ -0,0 +1,38 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// Scan verdicts
+const (
+	VerdictClean    = "clean"
+	VerdictInfected = "infected"
+	VerdictSkipped  = "skipped" // no scanner is configured, or it was unavailable and scanning fails open
+)
+
+// ErrUnavailable is returned when the scanner can't be reached or can't scan
+// the content, e.g. because it is larger than the scanner accepts
+var ErrUnavailable = errors.New("scanner unavailable")
+
+// Result is the outcome of scanning content
+type Result struct {
+	Verdict   string
+	Signature string // what was found, when infected
+	Engine    string // scanner engine and signature database version, if known
+}
+
+// Scanner checks content for malware before it is accepted
+type Scanner interface {
+	Scan(ctx context.Context, content io.Reader) (*Result, error)
+}
+
+// Noop accepts everything unscanned; it is used when no scanner is configured
+type Noop struct{}
+
+// Scan returns a skipped verdict without reading the content
+func (Noop) Scan(ctx context.Context, content io.Reader) (*Result, error) {
+	return &Result{Verdict: VerdictSkipped}, nil
+}
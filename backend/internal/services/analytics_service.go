@@ -2,49 +2,85 @@ package services
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
 	"time"
 
+	"github.com/ai-support-assistant/backend/internal/config"
 	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/langdetect"
 	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/ragclient"
+	"gorm.io/gorm"
 )
 
-type AnalyticsService struct{}
+type AnalyticsService struct {
+	cfg       *config.Config
+	ragClient *ragclient.Client
 
-func NewAnalyticsService() *AnalyticsService {
-	return &AnalyticsService{}
+	// classifying is held while a batch of feedback comments is classified
+	classifying sync.Mutex
+
+	// now is the clock trends end at
+	now func() time.Time
+}
+
+func NewAnalyticsService(cfg *config.Config, ragClient *ragclient.Client) *AnalyticsService {
+	return &AnalyticsService{cfg: cfg, ragClient: ragClient, now: time.Now}
 }
 
-// GetAnalytics returns aggregated analytics data
-func (s *AnalyticsService) GetAnalytics(ctx context.Context) (*models.Analytics, error) {
+// GetAnalytics returns aggregated analytics data, over a segment's queries
+// and their feedback and sessions when segment is set
+func (s *AnalyticsService) GetAnalytics(ctx context.Context, segment *models.AnalyticsSegment) (*models.Analytics, error) {
 	analytics := &models.Analytics{}
 
+	queries := func() *gorm.DB {
+		return segmentQueries(segment)
+	}
+	feedbacks := func() *gorm.DB {
+		scope := db.DB.Model(&models.Feedback{})
+		if segment != nil {
+			scope = scope.Where("query_id IN (?)", queries().Select("chat_queries.id"))
+		}
+		return scope
+	}
+	sessions := func() *gorm.DB {
+		scope := db.DB.Model(&models.Session{})
+		if segment != nil {
+			scope = scope.Where("session_id IN (?)", queries().Select("chat_queries.session_id"))
+		}
+		return scope
+	}
+
 	// Total queries
-	db.DB.Model(&models.ChatQuery{}).Count(&analytics.TotalQueries)
+	queries().Count(&analytics.TotalQueries)
 
 	// Total feedback
-	db.DB.Model(&models.Feedback{}).Count(&analytics.TotalFeedback)
+	feedbacks().Count(&analytics.TotalFeedback)
 
 	// Positive/Negative feedback
-	db.DB.Model(&models.Feedback{}).Where("score = ?", 1).Count(&analytics.PositiveFeedback)
-	db.DB.Model(&models.Feedback{}).Where("score = ?", -1).Count(&analytics.NegativeFeedback)
+	feedbacks().Where("score = ?", 1).Count(&analytics.PositiveFeedback)
+	feedbacks().Where("score = ?", -1).Count(&analytics.NegativeFeedback)
 
 	// Average latency
 	var avgLatency float64
-	db.DB.Model(&models.ChatQuery{}).Select("AVG(latency_ms)").Scan(&avgLatency)
+	queries().Select("AVG(latency_ms)").Scan(&avgLatency)
 	analytics.AverageLatencyMs = avgLatency
 
-	// Cache hit rate
-	var totalQueries int64
+	// Cache hit rate, excluding queries that bypassed the cache
+	var cacheableQueries int64
 	var cacheHits int64
-	db.DB.Model(&models.ChatQuery{}).Count(&totalQueries)
-	db.DB.Model(&models.ChatQuery{}).Where("cache_hit = ?", true).Count(&cacheHits)
-	if totalQueries > 0 {
-		analytics.CacheHitRate = float64(cacheHits) / float64(totalQueries) * 100
+	queries().Where("cache_bypassed = ?", false).Count(&cacheableQueries)
+	queries().Where("cache_hit = ?", true).Count(&cacheHits)
+	if cacheableQueries > 0 {
+		analytics.CacheHitRate = float64(cacheHits) / float64(cacheableQueries) * 100
 	}
 
 	// Total tokens used
 	var totalTokens int64
-	db.DB.Model(&models.ChatQuery{}).Select("SUM(tokens_used)").Scan(&totalTokens)
+	queries().Select("SUM(tokens_used)").Scan(&totalTokens)
 	analytics.TotalTokensUsed = totalTokens
 
 	// Total documents
@@ -52,53 +88,486 @@ func (s *AnalyticsService) GetAnalytics(ctx context.Context) (*models.Analytics,
 
 	// Active sessions (last 24 hours)
 	yesterday := time.Now().Add(-24 * time.Hour)
-	db.DB.Model(&models.ChatQuery{}).Where("created_at > ?", yesterday).Distinct("session_id").Count(&analytics.ActiveSessions)
+	queries().Where("created_at > ?", yesterday).Distinct("session_id").Count(&analytics.ActiveSessions)
+
+	// Unique visitors by server-side fingerprint, which clients can't inflate by
+	// churning session IDs. A visitor seen on both sides of a salt rotation
+	// counts twice.
+	queries().Where("created_at > ? AND visitor_id <> ''", yesterday).Distinct("visitor_id").Count(&analytics.UniqueVisitors)
+
+	// Canned vs LLM answers
+	queries().Where("model = ?", CannedModel).Count(&analytics.CannedAnswers)
+	queries().Where("model NOT IN ?", []string{CannedModel, ModerationModel}).Count(&analytics.LLMAnswers)
+
+	// Partial answers, as a share of LLM answers
+	queries().Where("partial = ?", true).Count(&analytics.PartialResponses)
+	if analytics.LLMAnswers > 0 {
+		analytics.PartialResponseRate = float64(analytics.PartialResponses) / float64(analytics.LLMAnswers) * 100
+	}
+
+	// Deflection rate: share of closed sessions the assistant resolved
+	var resolvedSessions int64
+	sessions().Where("outcome IN ?", closedSessionOutcomes).Count(&analytics.ClosedSessions)
+	sessions().Where("outcome = ?", SessionOutcomeResolved).Count(&resolvedSessions)
+	if analytics.ClosedSessions > 0 {
+		analytics.DeflectionRate = float64(resolvedSessions) / float64(analytics.ClosedSessions) * 100
+	}
 
 	return analytics, nil
 }
 
-// GetTopQueries returns the most frequent queries
-func (s *AnalyticsService) GetTopQueries(ctx context.Context, limit int) ([]map[string]interface{}, error) {
-	var results []map[string]interface{}
+// GetLanguageBreakdown returns query volume per detected language over the last days,
+// and the percentage of answers checked against a response language that had to be
+// translated. Queries recorded before detection was added are reported as undetermined.
+func (s *AnalyticsService) GetLanguageBreakdown(ctx context.Context, days int) ([]models.LanguageCount, float64, error) {
+	since := time.Now().AddDate(0, 0, -days)
 
-	rows, err := db.DB.Model(&models.ChatQuery{}).
-		Select("query, COUNT(*) as count").
-		Group("query").
-		Order("count DESC").
+	var rows []struct {
+		Language   string
+		Count      int64
+		Checked    int64
+		Translated int64
+	}
+	err := analyticsQueries().
+		Select("language, COUNT(*) AS count, "+
+			"SUM(CASE WHEN response_language <> '' THEN 1 ELSE 0 END) AS checked, "+
+			"SUM(CASE WHEN translated THEN 1 ELSE 0 END) AS translated").
+		Where("created_at >= ?", since).
+		Group("language").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get language breakdown: %w", err)
+	}
+
+	// Merge unlabeled rows into undetermined
+	counts := make(map[string]*models.LanguageCount)
+	checked := make(map[string]int64)
+	for _, row := range rows {
+		language := row.Language
+		if language == "" {
+			language = langdetect.Undetermined
+		}
+		if counts[language] == nil {
+			counts[language] = &models.LanguageCount{Language: language}
+		}
+		counts[language].Count += row.Count
+		counts[language].Translated += row.Translated
+		checked[language] += row.Checked
+	}
+
+	results := make([]models.LanguageCount, 0, len(counts))
+	for _, count := range counts {
+		results = append(results, *count)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Count != results[j].Count {
+			return results[i].Count > results[j].Count
+		}
+		return results[i].Language < results[j].Language
+	})
+
+	var total, totalChecked, totalTranslated int64
+	for _, result := range results {
+		total += result.Count
+		totalChecked += checked[result.Language]
+		totalTranslated += result.Translated
+	}
+	for i := range results {
+		if total > 0 {
+			results[i].Percentage = float64(results[i].Count) / float64(total) * 100
+		}
+		if n := checked[results[i].Language]; n > 0 {
+			results[i].TranslationRate = float64(results[i].Translated) / float64(n) * 100
+		}
+	}
+
+	var translationRate float64
+	if totalChecked > 0 {
+		translationRate = float64(totalTranslated) / float64(totalChecked) * 100
+	}
+
+	return results, translationRate, nil
+}
+
+// GetPromptVersionStats returns query volume and feedback positive rate per prompt
+// template version over the last days, for comparing prompt changes. Queries answered
+// without a template (canned answers, the RAG service default) are grouped under an empty name.
+func (s *AnalyticsService) GetPromptVersionStats(ctx context.Context, days int) ([]models.PromptVersionStats, error) {
+	since := time.Now().AddDate(0, 0, -days)
+
+	var stats []models.PromptVersionStats
+	err := analyticsQueries().
+		Select(`chat_queries.prompt_template AS prompt_template,
+			chat_queries.prompt_version AS prompt_version,
+			COUNT(DISTINCT chat_queries.id) AS query_count,
+			COUNT(feedbacks.id) AS feedback_count,
+			COALESCE(SUM(CASE WHEN feedbacks.score = 1 THEN 1 ELSE 0 END), 0) AS positive_count`).
+		Joins("LEFT JOIN feedbacks ON feedbacks.query_id = chat_queries.id").
+		Where("chat_queries.created_at >= ?", since).
+		Group("chat_queries.prompt_template, chat_queries.prompt_version").
+		Order("chat_queries.prompt_template ASC, chat_queries.prompt_version DESC").
+		Scan(&stats).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prompt version stats: %w", err)
+	}
+
+	for i := range stats {
+		if stats[i].FeedbackCount > 0 {
+			stats[i].PositiveRate = float64(stats[i].PositiveCount) / float64(stats[i].FeedbackCount) * 100
+		}
+	}
+
+	return stats, nil
+}
+
+// GetPipelineStats returns query volume, latency and feedback positive rate
+// per query pipeline over the last days, comparing answers the RAG service
+// generated with those the LLM gave directly
+func (s *AnalyticsService) GetPipelineStats(ctx context.Context, days int) ([]models.PipelineStats, error) {
+	since := time.Now().AddDate(0, 0, -days)
+
+	var stats []models.PipelineStats
+	err := analyticsQueries().
+		Select(`chat_queries.pipeline AS pipeline,
+			COUNT(DISTINCT chat_queries.id) AS query_count,
+			COALESCE(AVG(chat_queries.latency_ms), 0) AS avg_latency_ms,
+			COUNT(feedbacks.id) AS feedback_count,
+			COALESCE(SUM(CASE WHEN feedbacks.score = 1 THEN 1 ELSE 0 END), 0) AS positive_count`).
+		Joins("LEFT JOIN feedbacks ON feedbacks.query_id = chat_queries.id").
+		Where("chat_queries.created_at >= ?", since).
+		Group("chat_queries.pipeline").
+		Order("chat_queries.pipeline ASC").
+		Scan(&stats).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pipeline stats: %w", err)
+	}
+
+	for i := range stats {
+		if stats[i].FeedbackCount > 0 {
+			stats[i].PositiveRate = float64(stats[i].PositiveCount) / float64(stats[i].FeedbackCount) * 100
+		}
+	}
+
+	return stats, nil
+}
+
+// GetRegenerationStats returns how many answers were regenerated over the
+// last days and how the regenerations were rated, in particular how often a
+// regeneration of a thumbed-down answer was rated positively
+func (s *AnalyticsService) GetRegenerationStats(ctx context.Context, days int) (*models.RegenerationStats, error) {
+	since := time.Now().AddDate(0, 0, -days)
+
+	const (
+		parentNegative = "EXISTS (SELECT 1 FROM feedbacks WHERE feedbacks.query_id = chat_queries.parent_query_id AND feedbacks.score = -1)"
+		rated          = "EXISTS (SELECT 1 FROM feedbacks WHERE feedbacks.query_id = chat_queries.id)"
+		positive       = "EXISTS (SELECT 1 FROM feedbacks WHERE feedbacks.query_id = chat_queries.id AND feedbacks.score = 1)"
+	)
+
+	stats := models.RegenerationStats{Days: days}
+	err := analyticsQueries().WithContext(ctx).
+		Select(`COUNT(*) AS regenerations,
+			COUNT(DISTINCT chat_queries.parent_query_id) AS queries_regenerated,
+			COALESCE(SUM(CASE WHEN `+parentNegative+` THEN 1 ELSE 0 END), 0) AS after_negative,
+			COALESCE(SUM(CASE WHEN `+rated+` THEN 1 ELSE 0 END), 0) AS rated_count,
+			COALESCE(SUM(CASE WHEN `+positive+` THEN 1 ELSE 0 END), 0) AS positive_count,
+			COALESCE(SUM(CASE WHEN `+parentNegative+` AND `+rated+` THEN 1 ELSE 0 END), 0) AS rated_after_negative,
+			COALESCE(SUM(CASE WHEN `+parentNegative+` AND `+positive+` THEN 1 ELSE 0 END), 0) AS flipped_to_positive`).
+		Where("chat_queries.parent_query_id IS NOT NULL AND chat_queries.created_at >= ?", since).
+		Scan(&stats).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get regeneration stats: %w", err)
+	}
+
+	if stats.RatedCount > 0 {
+		stats.PositiveRate = float64(stats.PositiveCount) / float64(stats.RatedCount) * 100
+	}
+	if stats.RatedAfterNegative > 0 {
+		stats.FlipRate = float64(stats.FlippedToPositive) / float64(stats.RatedAfterNegative) * 100
+	}
+
+	return &stats, nil
+}
+
+// GetGroundingStats returns the distribution of grounding scores of answers
+// verified over the last days
+func (s *AnalyticsService) GetGroundingStats(ctx context.Context, days int) (*models.GroundingStats, error) {
+	since := time.Now().AddDate(0, 0, -days)
+	verified := func() *gorm.DB {
+		return analyticsQueries().WithContext(ctx).
+			Where("chat_queries.grounding_score IS NOT NULL AND chat_queries.created_at >= ?", since)
+	}
+
+	stats := models.GroundingStats{Days: days}
+	err := verified().
+		Select(`COUNT(*) AS verified,
+			COALESCE(AVG(chat_queries.grounding_score), 0) AS average_score,
+			COALESCE(AVG(chat_queries.unsupported_count), 0) AS average_unsupported,
+			COALESCE(SUM(CASE WHEN chat_queries.grounding_score < ? THEN 1 ELSE 0 END), 0) AS low_score_count`, s.cfg.VerificationMinScore).
+		Scan(&stats).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get grounding stats: %w", err)
+	}
+
+	counts, err := groundingScoreCounts(verified())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get grounding score distribution: %w", err)
+	}
+
+	stats.Buckets = make([]models.GroundingBucket, groundingBuckets)
+	for i := range stats.Buckets {
+		stats.Buckets[i] = models.GroundingBucket{Min: float64(i) / groundingBuckets, Max: float64(i+1) / groundingBuckets}
+	}
+	for bucket, count := range counts {
+		if bucket >= 0 && bucket < len(stats.Buckets) {
+			stats.Buckets[bucket].Count = count
+		}
+	}
+
+	if stats.Verified > 0 {
+		stats.LowScoreRate = float64(stats.LowScoreCount) / float64(stats.Verified) * 100
+	}
+
+	return &stats, nil
+}
+
+// GetPageStats returns query counts and negative feedback rates per page the widget
+// was used on over the last days, busiest pages first
+func (s *AnalyticsService) GetPageStats(ctx context.Context, days, limit int) ([]models.PageStats, error) {
+	since := time.Now().AddDate(0, 0, -days)
+	pageURL := "chat_queries.metadata->>'page_url'"
+
+	var stats []models.PageStats
+	err := analyticsQueries().
+		Select(pageURL+` AS page_url,
+			COUNT(DISTINCT chat_queries.id) AS query_count,
+			COUNT(feedbacks.id) AS feedback_count,
+			COALESCE(SUM(CASE WHEN feedbacks.score = -1 THEN 1 ELSE 0 END), 0) AS negative_count`).
+		Joins("LEFT JOIN feedbacks ON feedbacks.query_id = chat_queries.id").
+		Where("chat_queries.created_at >= ?", since).
+		Where(pageURL + " IS NOT NULL AND " + pageURL + " <> ''").
+		Group(pageURL).
+		Order("query_count DESC").
 		Limit(limit).
-		Rows()
+		Scan(&stats).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get page stats: %w", err)
+	}
 
+	for i := range stats {
+		if stats[i].FeedbackCount > 0 {
+			stats[i].NegativeRate = float64(stats[i].NegativeCount) / float64(stats[i].FeedbackCount) * 100
+		}
+	}
+
+	return stats, nil
+}
+
+// GetTopQueries returns the most frequent queries
+func (s *AnalyticsService) GetTopQueries(ctx context.Context, limit int, segment *models.AnalyticsSegment) ([]map[string]interface{}, error) {
+	counts, err := topQueries(segmentQueries(segment), limit)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var query string
-		var count int64
-		if err := rows.Scan(&query, &count); err != nil {
-			continue
-		}
+	results := make([]map[string]interface{}, 0, len(counts))
+	for _, count := range counts {
 		results = append(results, map[string]interface{}{
-			"query": query,
-			"count": count,
+			"query": count.Query,
+			"count": count.Count,
 		})
 	}
 
 	return results, nil
 }
 
-// GetQueryTrends returns query trends over time
-func (s *AnalyticsService) GetQueryTrends(ctx context.Context, days int) ([]map[string]interface{}, error) {
-	var results []map[string]interface{}
+// analyticsQueries selects the queries analytics are computed over, leaving
+// out synthetic ones such as cache warm-up
+func analyticsQueries() *gorm.DB {
+	return db.DB.Model(&models.ChatQuery{}).Where("chat_queries.synthetic = ?", false)
+}
+
+// queryGroupKey groups identical queries by hash, since encrypted query text
+// can't be grouped. Rows without a hash predate it and are still plaintext.
+const queryGroupKey = "COALESCE(NULLIF(chat_queries.query_hash, ''), chat_queries.query)"
+
+// topQueries returns the most frequent queries in scope, which must select
+// from or join chat_queries. Each group is labelled with the text of one of
+// its queries, loaded through the model so it is decrypted.
+func topQueries(scope *gorm.DB, limit int) ([]models.QueryCount, error) {
+	var groups []struct {
+		SampleID uint
+		Count    int64
+	}
+	err := scope.
+		Select("MIN(chat_queries.id) AS sample_id, COUNT(*) AS count").
+		Group(queryGroupKey).
+		Order("count DESC").
+		Limit(limit).
+		Scan(&groups).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]uint, len(groups))
+	for i, group := range groups {
+		ids[i] = group.SampleID
+	}
 
-	startDate := time.Now().AddDate(0, 0, -days)
+	var samples []models.ChatQuery
+	if err := db.DB.Select("id", "query").Where("id IN ?", ids).Find(&samples).Error; err != nil {
+		return nil, err
+	}
+	text := make(map[uint]string, len(samples))
+	for _, sample := range samples {
+		text[sample.ID] = sample.Query
+	}
+
+	counts := make([]models.QueryCount, len(groups))
+	for i, group := range groups {
+		counts[i] = models.QueryCount{Query: text[group.SampleID], Count: group.Count}
+	}
+	return counts, nil
+}
 
-	rows, err := db.DB.Model(&models.ChatQuery{}).
-		Select("DATE(created_at) as date, COUNT(*) as count").
-		Where("created_at > ?", startDate).
-		Group("DATE(created_at)").
-		Order("date ASC").
+// GetLatencyStats returns latency percentiles over the last N days,
+// broken down by cache hits and RAG calls
+func (s *AnalyticsService) GetLatencyStats(ctx context.Context, days int) (*models.LatencyStats, error) {
+	since := time.Now().AddDate(0, 0, -days)
+	stats := &models.LatencyStats{WindowDays: days}
+
+	cacheHit, ragCall := true, false
+	var err error
+
+	if stats.Overall, err = latencyPercentiles(since, nil); err != nil {
+		return nil, fmt.Errorf("failed to compute overall latency: %w", err)
+	}
+	if stats.CacheHit, err = latencyPercentiles(since, &cacheHit); err != nil {
+		return nil, fmt.Errorf("failed to compute cache hit latency: %w", err)
+	}
+	if stats.RAG, err = latencyPercentiles(since, &ragCall); err != nil {
+		return nil, fmt.Errorf("failed to compute RAG latency: %w", err)
+	}
+
+	// Queries whose RAG service didn't report a phase are left out of its stats
+	phases := []struct {
+		column string
+		dest   *models.LatencyPercentiles
+	}{
+		{"retrieval_ms", &stats.Phases.Retrieval},
+		{"generation_ms", &stats.Phases.Generation},
+		{"time_to_first_token_ms", &stats.Phases.TimeToFirstToken},
+	}
+	for _, phase := range phases {
+		query := analyticsQueries().Where("created_at > ?", since).Where(phase.column + " IS NOT NULL")
+		if *phase.dest, err = columnPercentiles(query, phase.column); err != nil {
+			return nil, fmt.Errorf("failed to compute %s latency: %w", phase.column, err)
+		}
+	}
+
+	return stats, nil
+}
+
+// Trend granularities supported by GetQueryTrends
+const (
+	GranularityHour = "hour"
+	GranularityDay  = "day"
+	GranularityWeek = "week"
+)
+
+// IsValidGranularity returns true if the trend granularity is supported
+func IsValidGranularity(granularity string) bool {
+	return granularity == GranularityHour || granularity == GranularityDay || granularity == GranularityWeek
+}
+
+// GetQueryTrends returns query volume and latency trends over the last N days.
+// Buckets are aligned to the given location and every bucket in the range is
+// present, with zero counts for periods without queries.
+func (s *AnalyticsService) GetQueryTrends(ctx context.Context, days int, granularity string, loc *time.Location, segment *models.AnalyticsSegment) ([]models.QueryTrend, error) {
+	end := s.now().In(loc)
+	start := truncateToBucket(end.AddDate(0, 0, -days), granularity)
+
+	var buckets map[string]models.QueryTrend
+	var err error
+	if isPostgres() {
+		buckets, err = queryTrendsPostgres(segmentQueries(segment), start, granularity, loc)
+	} else {
+		buckets, err = queryTrendsFallback(segmentQueries(segment), start, granularity, loc)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return fillTrendGaps(buckets, start, end, granularity), nil
+}
+
+// GetOutcomeTrends returns the sessions closed per day over the last N days by
+// outcome, with days aligned to the given location. Sessions that were reopened
+// count only once they close again.
+func (s *AnalyticsService) GetOutcomeTrends(ctx context.Context, days int, loc *time.Location) ([]models.OutcomeTrend, error) {
+	end := s.now().In(loc)
+	start := truncateToBucket(end.AddDate(0, 0, -days), GranularityDay)
+
+	var rows []struct {
+		Outcome   string
+		OutcomeAt time.Time
+	}
+	err := db.DB.WithContext(ctx).Model(&models.Session{}).
+		Select("outcome, outcome_at").
+		Where("outcome IN ? AND outcome_at >= ?", closedSessionOutcomes, start).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session outcomes: %w", err)
+	}
+
+	buckets := make(map[string]*models.OutcomeTrend)
+	for _, row := range rows {
+		label := bucketLabel(truncateToBucket(row.OutcomeAt.In(loc), GranularityDay), GranularityDay)
+		trend, ok := buckets[label]
+		if !ok {
+			trend = &models.OutcomeTrend{Date: label}
+			buckets[label] = trend
+		}
+		switch row.Outcome {
+		case SessionOutcomeResolved:
+			trend.Resolved++
+		case SessionOutcomeEscalated:
+			trend.Escalated++
+		case SessionOutcomeAbandoned:
+			trend.Abandoned++
+		}
+	}
+
+	results := []models.OutcomeTrend{}
+	for t := start; !t.After(end); t = nextBucket(t, GranularityDay) {
+		label := bucketLabel(t, GranularityDay)
+		trend := models.OutcomeTrend{Date: label}
+		if bucket, ok := buckets[label]; ok {
+			trend = *bucket
+		}
+		if closed := trend.Resolved + trend.Escalated + trend.Abandoned; closed > 0 {
+			trend.DeflectionRate = float64(trend.Resolved) / float64(closed) * 100
+		}
+		results = append(results, trend)
+	}
+
+	return results, nil
+}
+
+// queryTrendsPostgres aggregates trends in the database, bucketing by local time
+func queryTrendsPostgres(queries *gorm.DB, start time.Time, granularity string, loc *time.Location) (map[string]models.QueryTrend, error) {
+	buckets := make(map[string]models.QueryTrend)
+
+	// Scan the bucket as a formatted string so it doesn't depend on driver date handling
+	rows, err := queries.
+		Select("to_char(date_trunc(?, created_at AT TIME ZONE ?), ?) as bucket, COUNT(*) as count, "+
+			"COALESCE(AVG(latency_ms), 0) as avg_latency, "+
+			"COALESCE(PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY latency_ms), 0) as p95_latency",
+			granularity, loc.String(), postgresBucketFormat(granularity)).
+		Where("created_at >= ?", start).
+		Group("bucket").
 		Rows()
 
 	if err != nil {
@@ -107,16 +576,226 @@ func (s *AnalyticsService) GetQueryTrends(ctx context.Context, days int) ([]map[
 	defer rows.Close()
 
 	for rows.Next() {
-		var date time.Time
-		var count int64
-		if err := rows.Scan(&date, &count); err != nil {
+		var trend models.QueryTrend
+		if err := rows.Scan(&trend.Date, &trend.Count, &trend.AvgLatencyMs, &trend.P95LatencyMs); err != nil {
+			return nil, fmt.Errorf("failed to scan trend row: %w", err)
+		}
+		buckets[trend.Date] = trend
+	}
+
+	return buckets, rows.Err()
+}
+
+// queryTrendsFallback computes trends in memory for dialects without PERCENTILE_CONT
+func queryTrendsFallback(queries *gorm.DB, start time.Time, granularity string, loc *time.Location) (map[string]models.QueryTrend, error) {
+	var rows []struct {
+		CreatedAt time.Time
+		LatencyMs int
+	}
+
+	if err := queries.
+		Select("created_at, latency_ms").
+		Where("created_at >= ?", start).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	latencies := make(map[string][]float64)
+	for _, row := range rows {
+		label := bucketLabel(truncateToBucket(row.CreatedAt.In(loc), granularity), granularity)
+		latencies[label] = append(latencies[label], float64(row.LatencyMs))
+	}
+
+	buckets := make(map[string]models.QueryTrend, len(latencies))
+	for label, values := range latencies {
+		sort.Float64s(values)
+		buckets[label] = models.QueryTrend{
+			Date:         label,
+			Count:        int64(len(values)),
+			AvgLatencyMs: mean(values),
+			P95LatencyMs: percentile(values, 0.95),
+		}
+	}
+
+	return buckets, nil
+}
+
+// fillTrendGaps returns one trend per bucket between start and end, in order
+func fillTrendGaps(buckets map[string]models.QueryTrend, start, end time.Time, granularity string) []models.QueryTrend {
+	results := []models.QueryTrend{}
+	seen := make(map[string]bool)
+
+	for t := start; !t.After(end); t = nextBucket(t, granularity) {
+		label := bucketLabel(t, granularity)
+		// Repeated wall-clock hours at a DST fall-back are grouped into one bucket
+		if seen[label] {
 			continue
 		}
-		results = append(results, map[string]interface{}{
-			"date":  date.Format("2006-01-02"),
-			"count": count,
-		})
+		seen[label] = true
+
+		trend, ok := buckets[label]
+		if !ok {
+			trend = models.QueryTrend{Date: label}
+		}
+		results = append(results, trend)
 	}
 
-	return results, nil
+	return results
+}
+
+// truncateToBucket returns the start of the bucket containing t, in t's location
+func truncateToBucket(t time.Time, granularity string) time.Time {
+	switch granularity {
+	case GranularityHour:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	case GranularityWeek:
+		// Weeks start on Monday, matching Postgres date_trunc('week')
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+}
+
+// nextBucket returns the start of the bucket following t.
+// Days and weeks use calendar arithmetic so DST transitions don't shift boundaries.
+func nextBucket(t time.Time, granularity string) time.Time {
+	switch granularity {
+	case GranularityHour:
+		return t.Add(time.Hour)
+	case GranularityWeek:
+		return t.AddDate(0, 0, 7)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+// bucketLabel formats a bucket start as its trend label
+func bucketLabel(t time.Time, granularity string) string {
+	if granularity == GranularityHour {
+		return t.Format("2006-01-02T15:00")
+	}
+	return t.Format("2006-01-02")
+}
+
+// postgresBucketFormat returns the to_char format matching bucketLabel
+func postgresBucketFormat(granularity string) string {
+	if granularity == GranularityHour {
+		return `YYYY-MM-DD"T"HH24:00`
+	}
+	return "YYYY-MM-DD"
+}
+
+// latencyPercentiles computes p50/p90/p99 latency since a time, optionally filtered by cache hit
+func latencyPercentiles(since time.Time, cacheHit *bool) (models.LatencyPercentiles, error) {
+	query := analyticsQueries().Where("created_at > ?", since)
+	if cacheHit != nil {
+		query = query.Where("cache_hit = ?", *cacheHit)
+	}
+	return columnPercentiles(query, "latency_ms")
+}
+
+// columnPercentiles computes the average and p50/p90/p99 of a millisecond
+// column over the rows a query selects
+func columnPercentiles(query *gorm.DB, column string) (models.LatencyPercentiles, error) {
+	var result models.LatencyPercentiles
+
+	if isPostgres() {
+		err := query.Select(fmt.Sprintf("COUNT(*), "+
+			"COALESCE(AVG(%[1]s), 0), "+
+			"COALESCE(PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY %[1]s), 0), "+
+			"COALESCE(PERCENTILE_CONT(0.9) WITHIN GROUP (ORDER BY %[1]s), 0), "+
+			"COALESCE(PERCENTILE_CONT(0.99) WITHIN GROUP (ORDER BY %[1]s), 0)", column)).
+			Row().
+			Scan(&result.Count, &result.Avg, &result.P50, &result.P90, &result.P99)
+		return result, err
+	}
+
+	// Fallback: load the latencies and interpolate like PERCENTILE_CONT
+	var latencies []float64
+	if err := query.Order(column+" ASC").Pluck(column, &latencies).Error; err != nil {
+		return result, err
+	}
+
+	result.Count = int64(len(latencies))
+	result.Avg = mean(latencies)
+	result.P50 = percentile(latencies, 0.5)
+	result.P90 = percentile(latencies, 0.9)
+	result.P99 = percentile(latencies, 0.99)
+	return result, nil
+}
+
+// percentile returns the linearly interpolated percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := p * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return sorted[lower]
+	}
+
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// mean returns the arithmetic mean of values
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// groundingBuckets is the number of equal-width buckets grounding scores
+// are counted in
+const groundingBuckets = 10
+
+// groundingScoreCounts counts the queries' grounding scores per bucket
+func groundingScoreCounts(query *gorm.DB) (map[int]int64, error) {
+	counts := make(map[int]int64)
+
+	if isPostgres() {
+		var rows []struct {
+			Bucket int
+			Count  int64
+		}
+		err := query.
+			Select("LEAST(FLOOR(chat_queries.grounding_score * 10), 9)::int AS bucket, COUNT(*) AS count").
+			Group("bucket").
+			Scan(&rows).Error
+		for _, row := range rows {
+			counts[row.Bucket] = row.Count
+		}
+		return counts, err
+	}
+
+	// Fallback: load the scores and bucket them here
+	var scores []float64
+	if err := query.Pluck("chat_queries.grounding_score", &scores).Error; err != nil {
+		return nil, err
+	}
+	for _, score := range scores {
+		counts[groundingBucket(score)]++
+	}
+	return counts, nil
+}
+
+// groundingBucket returns the bucket of a grounding score; a perfect score
+// falls in the last bucket
+func groundingBucket(score float64) int {
+	return min(int(math.Floor(score*groundingBuckets)), groundingBuckets-1)
+}
+
+// isPostgres returns true if the database dialect is Postgres
+func isPostgres() bool {
+	return db.DB.Dialector.Name() == "postgres"
 }
@@ -0,0 +1,360 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/models"
+)
+
+// trendLabels returns the dates of trends, in order
+func trendLabels(trends []models.QueryTrend) []string {
+	labels := make([]string, len(trends))
+	for i, trend := range trends {
+		labels[i] = trend.Date
+	}
+	return labels
+}
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s unavailable: %v", name, err)
+	}
+	return loc
+}
+
+func TestFillTrendGaps(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+
+	tests := []struct {
+		name        string
+		granularity string
+		start, end  time.Time
+		want        []string
+	}{
+		{
+			"days",
+			GranularityDay,
+			time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 5, 4, 13, 0, 0, 0, time.UTC),
+			[]string{"2024-05-01", "2024-05-02", "2024-05-03", "2024-05-04"},
+		},
+		{
+			// 02:00 doesn't exist on the day clocks go forward
+			"hours across spring forward",
+			GranularityHour,
+			time.Date(2024, 3, 10, 0, 0, 0, 0, newYork),
+			time.Date(2024, 3, 10, 4, 30, 0, 0, newYork),
+			[]string{"2024-03-10T00:00", "2024-03-10T01:00", "2024-03-10T03:00", "2024-03-10T04:00"},
+		},
+		{
+			// 01:00 happens twice on the day clocks go back, and is one bucket
+			"hours across fall back",
+			GranularityHour,
+			time.Date(2024, 11, 3, 0, 0, 0, 0, newYork),
+			time.Date(2024, 11, 3, 3, 0, 0, 0, newYork),
+			[]string{"2024-11-03T00:00", "2024-11-03T01:00", "2024-11-03T02:00", "2024-11-03T03:00"},
+		},
+		{
+			// Days stay at local midnight on either side of the change
+			"days across spring forward",
+			GranularityDay,
+			time.Date(2024, 3, 9, 0, 0, 0, 0, newYork),
+			time.Date(2024, 3, 11, 23, 0, 0, 0, newYork),
+			[]string{"2024-03-09", "2024-03-10", "2024-03-11"},
+		},
+		{
+			"weeks",
+			GranularityWeek,
+			time.Date(2024, 10, 28, 0, 0, 0, 0, newYork),
+			time.Date(2024, 11, 12, 0, 0, 0, 0, newYork),
+			[]string{"2024-10-28", "2024-11-04", "2024-11-11"},
+		},
+		{
+			"end before start",
+			GranularityDay,
+			time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+			[]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := trendLabels(fillTrendGaps(nil, tt.start, tt.end, tt.granularity))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fillTrendGaps = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFillTrendGapsKeepsCounts(t *testing.T) {
+	buckets := map[string]models.QueryTrend{
+		"2024-05-02": {Date: "2024-05-02", Count: 7, AvgLatencyMs: 120, P95LatencyMs: 300},
+	}
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC)
+
+	got := fillTrendGaps(buckets, start, end, GranularityDay)
+	want := []models.QueryTrend{
+		{Date: "2024-05-01"},
+		buckets["2024-05-02"],
+		{Date: "2024-05-03"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fillTrendGaps = %+v, want %+v", got, want)
+	}
+}
+
+func TestTruncateToBucket(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+	at := time.Date(2024, 11, 3, 1, 30, 0, 0, newYork) // a Sunday
+
+	tests := []struct {
+		granularity string
+		want        time.Time
+	}{
+		{GranularityHour, time.Date(2024, 11, 3, 1, 0, 0, 0, newYork)},
+		{GranularityDay, time.Date(2024, 11, 3, 0, 0, 0, 0, newYork)},
+		// Weeks start on Monday, like Postgres date_trunc('week')
+		{GranularityWeek, time.Date(2024, 10, 28, 0, 0, 0, 0, newYork)},
+	}
+	for _, tt := range tests {
+		if got := truncateToBucket(at, tt.granularity); !got.Equal(tt.want) {
+			t.Errorf("truncateToBucket(%s) = %v, want %v", tt.granularity, got, tt.want)
+		}
+	}
+}
+
+// trendDB answers trend queries from seeded rows created at the given
+// times with the given latencies. The Postgres aggregate is grouped by the
+// local wall-clock time in the requested zone, as date_trunc on created_at
+// AT TIME ZONE does; the fallback gets the rows themselves. Both honor the
+// start bound.
+func trendDB(rows map[time.Time]int) fakeQueryFunc {
+	return func(query string, args []driver.NamedValue) (*fakeRows, error) {
+		var start time.Time
+		for _, arg := range args {
+			if t, ok := arg.Value.(time.Time); ok {
+				start = t
+			}
+		}
+
+		if strings.HasPrefix(query, "SELECT created_at, latency_ms") {
+			result := &fakeRows{columns: []string{"created_at", "latency_ms"}}
+			for at, latency := range rows {
+				if !at.Before(start) {
+					result.values = append(result.values, []driver.Value{at, int64(latency)})
+				}
+			}
+			return result, nil
+		}
+
+		granularity, zone := args[0].Value.(string), args[1].Value.(string)
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			return nil, err
+		}
+		latencies := map[string][]int{}
+		for at, latency := range rows {
+			if at.Before(start) {
+				continue
+			}
+			local := at.In(loc)
+			label := local.Format("2006-01-02")
+			switch granularity {
+			case GranularityHour:
+				label = local.Format("2006-01-02T15:00")
+			case GranularityWeek:
+				label = local.AddDate(0, 0, -((int(local.Weekday()) + 6) % 7)).Format("2006-01-02")
+			}
+			latencies[label] = append(latencies[label], latency)
+		}
+		result := &fakeRows{columns: []string{"bucket", "count", "avg_latency", "p95_latency"}}
+		for label, values := range latencies {
+			sum := 0
+			for _, latency := range values {
+				sum += latency
+			}
+			avg := float64(sum) / float64(len(values))
+			result.values = append(result.values, []driver.Value{label, int64(len(values)), avg, avg})
+		}
+		return result, nil
+	}
+}
+
+func TestQueryTrendsSeeded(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+	utc := func(value string) time.Time {
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatalf("parse %s: %v", value, err)
+		}
+		return at
+	}
+
+	tests := []struct {
+		name        string
+		granularity string
+		loc         *time.Location
+		now         time.Time
+		days        int
+		rows        map[time.Time]int
+		// buckets is the number of trends; counts holds the non-zero ones
+		buckets int
+		counts  map[string]int64
+		avg     map[string]float64
+	}{
+		{
+			// 02:00 never happens; the row from the day before the range is left out
+			name:        "hours across spring forward",
+			granularity: GranularityHour,
+			loc:         newYork,
+			now:         utc("2024-03-10T09:00:00Z"),
+			days:        1,
+			rows: map[time.Time]int{
+				utc("2024-03-09T09:00:00Z"): 100,
+				utc("2024-03-10T06:30:00Z"): 100,
+				utc("2024-03-10T07:30:00Z"): 200,
+				utc("2024-03-10T07:45:00Z"): 400,
+			},
+			buckets: 24,
+			counts:  map[string]int64{"2024-03-10T01:00": 1, "2024-03-10T03:00": 2},
+			avg:     map[string]float64{"2024-03-10T03:00": 300},
+		},
+		{
+			// Both 01:30s, an hour apart, share the repeated 01:00 bucket
+			name:        "hours across fall back",
+			granularity: GranularityHour,
+			loc:         newYork,
+			now:         utc("2024-11-03T08:00:00Z"),
+			days:        1,
+			rows: map[time.Time]int{
+				utc("2024-11-03T05:30:00Z"): 100,
+				utc("2024-11-03T06:30:00Z"): 300,
+				utc("2024-11-03T08:00:00Z"): 50,
+			},
+			buckets: 25,
+			counts:  map[string]int64{"2024-11-03T01:00": 2, "2024-11-03T03:00": 1},
+			avg:     map[string]float64{"2024-11-03T01:00": 200},
+		},
+		{
+			// Late evenings fall on the local day, not the UTC one
+			name:        "days across spring forward",
+			granularity: GranularityDay,
+			loc:         newYork,
+			now:         utc("2024-03-11T16:00:00Z"),
+			days:        3,
+			rows: map[time.Time]int{
+				utc("2024-03-10T04:30:00Z"): 100,
+				utc("2024-03-10T05:30:00Z"): 100,
+				utc("2024-03-11T03:30:00Z"): 100,
+			},
+			buckets: 4,
+			counts:  map[string]int64{"2024-03-09": 1, "2024-03-10": 2},
+		},
+		{
+			name:        "days across spring forward in UTC",
+			granularity: GranularityDay,
+			loc:         time.UTC,
+			now:         utc("2024-03-11T16:00:00Z"),
+			days:        3,
+			rows: map[time.Time]int{
+				utc("2024-03-10T04:30:00Z"): 100,
+				utc("2024-03-10T05:30:00Z"): 100,
+				utc("2024-03-11T03:30:00Z"): 100,
+			},
+			buckets: 4,
+			counts:  map[string]int64{"2024-03-10": 2, "2024-03-11": 1},
+		},
+		{
+			// Sunday night before the week after fall back stays in the old week
+			name:        "weeks across fall back",
+			granularity: GranularityWeek,
+			loc:         newYork,
+			now:         utc("2024-11-12T17:00:00Z"),
+			days:        14,
+			rows: map[time.Time]int{
+				utc("2024-11-04T04:30:00Z"): 100,
+				utc("2024-11-04T05:30:00Z"): 100,
+			},
+			buckets: 3,
+			counts:  map[string]int64{"2024-10-28": 1, "2024-11-04": 1},
+		},
+	}
+	for _, tt := range tests {
+		check := func(t *testing.T, trends []models.QueryTrend) {
+			t.Helper()
+			if len(trends) != tt.buckets {
+				t.Errorf("got %d buckets %v, want %d", len(trends), trendLabels(trends), tt.buckets)
+			}
+			seen := map[string]bool{}
+			for _, trend := range trends {
+				if seen[trend.Date] {
+					t.Errorf("bucket %s repeated", trend.Date)
+				}
+				seen[trend.Date] = true
+				if trend.Count != tt.counts[trend.Date] {
+					t.Errorf("bucket %s counts %d, want %d", trend.Date, trend.Count, tt.counts[trend.Date])
+				}
+				if avg, ok := tt.avg[trend.Date]; ok && trend.AvgLatencyMs != avg {
+					t.Errorf("bucket %s averages %gms, want %gms", trend.Date, trend.AvgLatencyMs, avg)
+				}
+			}
+			for label := range tt.counts {
+				if !seen[label] {
+					t.Errorf("bucket %s missing from %v", label, trendLabels(trends))
+				}
+			}
+		}
+
+		t.Run(tt.name+"/postgres", func(t *testing.T) {
+			useFakeDB(t, trendDB(tt.rows))
+			service := NewAnalyticsService(&config.Config{}, nil)
+			service.now = func() time.Time { return tt.now }
+
+			trends, err := service.GetQueryTrends(context.Background(), tt.days, tt.granularity, tt.loc, nil)
+			if err != nil {
+				t.Fatalf("GetQueryTrends: %v", err)
+			}
+			check(t, trends)
+		})
+		t.Run(tt.name+"/fallback", func(t *testing.T) {
+			useFakeDB(t, trendDB(tt.rows))
+			end := tt.now.In(tt.loc)
+			start := truncateToBucket(end.AddDate(0, 0, -tt.days), tt.granularity)
+
+			buckets, err := queryTrendsFallback(analyticsQueries(), start, tt.granularity, tt.loc)
+			if err != nil {
+				t.Fatalf("queryTrendsFallback: %v", err)
+			}
+			check(t, fillTrendGaps(buckets, start, end, tt.granularity))
+		})
+	}
+}
+
+func TestGroundingBucket(t *testing.T) {
+	tests := []struct {
+		score float64
+		want  int
+	}{
+		{0, 0},
+		{0.05, 0},
+		{0.1, 1},
+		{0.55, 5},
+		{0.99, 9},
+		{1, 9}, // a perfect score shares the top bucket
+	}
+	for _, tt := range tests {
+		if got := groundingBucket(tt.score); got != tt.want {
+			t.Errorf("groundingBucket(%v) = %d, want %d", tt.score, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/audit"
+	"github.com/ai-support-assistant/backend/internal/cache"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Annotation statuses
+const (
+	AnnotationDraft    = "draft"
+	AnnotationApproved = "approved"
+)
+
+// correctionTag marks the chunks of correction documents in the RAG service
+const correctionTag = "correction"
+
+type AnnotationService struct {
+	documents     *DocumentService
+	cannedAnswers *CannedAnswerService
+}
+
+func NewAnnotationService(documents *DocumentService, cannedAnswers *CannedAnswerService) *AnnotationService {
+	return &AnnotationService{documents: documents, cannedAnswers: cannedAnswers}
+}
+
+// CreateAnnotation saves a draft correction of a query's answer
+func (s *AnnotationService) CreateAnnotation(ctx context.Context, req models.AnnotationRequest) (*models.Annotation, error) {
+	correctedAnswer := strings.TrimSpace(req.CorrectedAnswer)
+	if correctedAnswer == "" {
+		return nil, validationError("corrected_answer must not be empty")
+	}
+
+	var query models.ChatQuery
+	if err := db.DB.WithContext(ctx).Select("id", "query").First(&query, req.QueryID).Error; err != nil {
+		return nil, notFoundError("query", err)
+	}
+
+	annotation := models.Annotation{
+		QueryID:         query.ID,
+		Question:        query.Query,
+		CorrectedAnswer: correctedAnswer,
+		Author:          audit.ActorFrom(ctx).UserID,
+		Status:          AnnotationDraft,
+	}
+
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&annotation).Error; err != nil {
+			return fmt.Errorf("failed to save annotation: %w", err)
+		}
+		return audit.Record(ctx, tx, "annotation.create", "annotation", strconv.FormatUint(uint64(annotation.ID), 10), nil, annotationAuditState(annotation))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &annotation, nil
+}
+
+// GetAnnotations returns the most recent annotations with their original
+// queries and responses, optionally filtered by status and query
+func (s *AnnotationService) GetAnnotations(ctx context.Context, status string, queryID uint, limit int) ([]models.Annotation, error) {
+	if status != "" && status != AnnotationDraft && status != AnnotationApproved {
+		return nil, fmt.Errorf("%w: status must be draft or approved", ErrInvalidRequest)
+	}
+
+	query := db.DB.WithContext(ctx).Preload("Query").Order("created_at DESC").Limit(limit)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if queryID != 0 {
+		query = query.Where("query_id = ?", queryID)
+	}
+
+	var annotations []models.Annotation
+	if err := query.Find(&annotations).Error; err != nil {
+		return nil, fmt.Errorf("failed to get annotations: %w", err)
+	}
+	return annotations, nil
+}
+
+// ApproveAnnotation approves a correction: it is ingested as a correction
+// document in the background, the original answer is evicted from the
+// response cache and exact repeats of the question get the correction.
+// Approving an approved annotation leaves it unchanged.
+func (s *AnnotationService) ApproveAnnotation(ctx context.Context, id uint) (*models.Annotation, error) {
+	var annotation models.Annotation
+	if err := db.DB.WithContext(ctx).Preload("Query").First(&annotation, id).Error; err != nil {
+		return nil, notFoundError("annotation", err)
+	}
+	if annotation.Status == AnnotationApproved {
+		return &annotation, nil
+	}
+
+	before := annotationAuditState(annotation)
+	now := time.Now().UTC()
+	annotation.Status = AnnotationApproved
+	annotation.ApprovedBy = audit.ActorFrom(ctx).UserID
+	annotation.ApprovedAt = &now
+
+	doc := models.Document{
+		FileName:      fmt.Sprintf("correction-%d.txt", annotation.ID),
+		FileType:      "text/plain",
+		Status:        "processing",
+		UploadedBy:    annotation.ApprovedBy,
+		DocumentKey:   fmt.Sprintf("correction-%d", annotation.ID),
+		Version:       1,
+		Visibility:    models.VisibilityPublic,
+		IngestOptions: defaultIngestOptions(s.documents.cfg),
+	}
+	content := correctionDocument(annotation.Question, annotation.CorrectedAnswer)
+	doc.FileSize = int64(len(content))
+
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&doc).Error; err != nil {
+			return fmt.Errorf("failed to save correction document: %w", err)
+		}
+		annotation.DocumentID = &doc.ID
+
+		err := tx.Model(&annotation).Updates(map[string]interface{}{
+			"status":      annotation.Status,
+			"approved_by": annotation.ApprovedBy,
+			"approved_at": now,
+			"document_id": doc.ID,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to approve annotation: %w", err)
+		}
+		return audit.Record(ctx, tx, "annotation.approve", "annotation", strconv.FormatUint(uint64(annotation.ID), 10), before, annotationAuditState(annotation))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.cannedAnswers.invalidate()
+	s.evictOriginalAnswer(ctx, annotation.Query)
+
+	fields := map[string]string{
+		"visibility":    doc.Visibility,
+		"tag":           correctionTag,
+		"annotation_id": strconv.FormatUint(uint64(annotation.ID), 10),
+	}
+	addIngestOptionFields(fields, doc.IngestOptions)
+	started := s.documents.lifecycle.Go("ingest_correction", logrus.Fields{
+		"annotation_id": annotation.ID,
+		"doc_id":        doc.ID,
+	}, func(ctx context.Context) {
+		s.documents.ingestText(ctx, doc.ID, doc.FileName, doc.UploadedBy, content, fields)
+	})
+	if !started {
+		s.documents.updateDocumentStatus(doc.ID, "failed")
+		logrus.WithField("annotation_id", annotation.ID).Warn("Server shutting down, correction document not ingested")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"annotation_id": annotation.ID,
+		"query_id":      annotation.QueryID,
+		"approved_by":   annotation.ApprovedBy,
+	}).Info("Annotation approved")
+
+	return &annotation, nil
+}
+
+// evictOriginalAnswer drops the corrected answer from the response cache so
+// the next identical query doesn't get it again
+func (s *AnnotationService) evictOriginalAnswer(ctx context.Context, query models.ChatQuery) {
+	if cache.Client == nil || query.CacheKey == "" {
+		return
+	}
+	if err := cache.Delete(ctx, query.CacheKey); err != nil {
+		logrus.WithError(err).WithField("query_id", query.ID).Warn("Failed to evict corrected answer from cache")
+	}
+}
+
+// correctionDocument is the text ingested for an approved correction
+func correctionDocument(question, answer string) string {
+	return fmt.Sprintf("Question: %s\n\nAnswer: %s\n", question, answer)
+}
+
+// annotationAuditState is the part of an annotation recorded in audit
+// entries; the question and answer are left out as they may hold personal data
+func annotationAuditState(annotation models.Annotation) map[string]interface{} {
+	return map[string]interface{}{
+		"query_id":    annotation.QueryID,
+		"status":      annotation.Status,
+		"author":      annotation.Author,
+		"approved_by": annotation.ApprovedBy,
+		"document_id": annotation.DocumentID,
+	}
+}
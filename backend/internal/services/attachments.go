@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// attachmentTypes are the detected content types accepted as query attachments
+var attachmentTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/webp": true,
+}
+
+// RAGAttachment is an image forwarded to the RAG service with a query
+type RAGAttachment struct {
+	FileName    string
+	ContentType string
+	Data        []byte
+}
+
+// validateAttachments checks a query's attachments against the configured
+// limits and sets their content types from the detected type; declared types
+// aren't trusted
+func validateAttachments(cfg *config.Config, attachments []models.QueryAttachment) error {
+	if len(attachments) == 0 {
+		return nil
+	}
+	if cfg.AttachmentMaxCount == 0 {
+		return validationError("attachments are disabled")
+	}
+	if len(attachments) > cfg.AttachmentMaxCount {
+		return validationError("at most %d attachments are allowed", cfg.AttachmentMaxCount)
+	}
+	// Only the HTTP transport reaches the multimodal endpoint
+	if cfg.RAGTransport == RAGTransportGRPC {
+		return validationError("attachments are not supported by the gRPC RAG transport")
+	}
+
+	for i := range attachments {
+		attachment := &attachments[i]
+		if len(attachment.FileName) > 255 {
+			return validationError("attachment %d file name must be at most 255 characters", i)
+		}
+		if len(attachment.Data) == 0 {
+			return validationError("attachment %d is empty", i)
+		}
+		if int64(len(attachment.Data)) > cfg.AttachmentMaxBytes {
+			return validationError("attachment %d is larger than %d bytes", i, cfg.AttachmentMaxBytes)
+		}
+
+		contentType := http.DetectContentType(attachment.Data)
+		if !attachmentTypes[contentType] {
+			return validationError("attachment %d is %s; only png, jpeg and webp images are accepted", i, contentType)
+		}
+		attachment.ContentType = contentType
+	}
+	return nil
+}
+
+// attachmentDigest returns the total size of a query's attachments and a
+// digest of their contents in order, empty without attachments
+func attachmentDigest(attachments []models.QueryAttachment) (int64, string) {
+	if len(attachments) == 0 {
+		return 0, ""
+	}
+
+	digest := sha256.New()
+	var total int64
+	for _, attachment := range attachments {
+		sum := sha256.Sum256(attachment.Data)
+		digest.Write(sum[:])
+		total += int64(len(attachment.Data))
+	}
+	return total, hex.EncodeToString(digest.Sum(nil))
+}
+
+// ragAttachments converts a query's attachments for the RAG service
+func ragAttachments(attachments []models.QueryAttachment) []RAGAttachment {
+	if len(attachments) == 0 {
+		return nil
+	}
+
+	converted := make([]RAGAttachment, len(attachments))
+	for i, attachment := range attachments {
+		converted[i] = RAGAttachment{
+			FileName:    attachment.FileName,
+			ContentType: attachment.ContentType,
+			Data:        attachment.Data,
+		}
+	}
+	return converted
+}
+
+// retainAttachments stores a query's images when ATTACHMENT_RETENTION is
+// set. A failure is logged; the query has already been answered.
+func (s *QueryService) retainAttachments(queryID uint, attachments []models.QueryAttachment) {
+	if !s.cfg.AttachmentRetention || queryID == 0 || len(attachments) == 0 {
+		return
+	}
+
+	records := make([]models.QueryAttachmentRecord, len(attachments))
+	for i, attachment := range attachments {
+		sum := sha256.Sum256(attachment.Data)
+		records[i] = models.QueryAttachmentRecord{
+			QueryID:     queryID,
+			Position:    i,
+			FileName:    attachment.FileName,
+			ContentType: attachment.ContentType,
+			Size:        int64(len(attachment.Data)),
+			SHA256:      hex.EncodeToString(sum[:]),
+			Data:        base64.StdEncoding.EncodeToString(attachment.Data),
+		}
+	}
+	if err := db.DB.Create(&records).Error; err != nil {
+		logrus.WithError(err).WithField("query_id", queryID).Error("Failed to retain query attachments")
+	}
+}
+
+// retainedAttachments loads the images kept for a query, in order. A query
+// sent with images that weren't kept can't be asked again.
+func retainedAttachments(ctx context.Context, query models.ChatQuery) ([]models.QueryAttachment, error) {
+	if query.AttachmentCount == 0 {
+		return nil, nil
+	}
+
+	var records []models.QueryAttachmentRecord
+	if err := db.DB.WithContext(ctx).Where("query_id = ?", query.ID).Order("position").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to get query attachments: %w", err)
+	}
+	if len(records) != query.AttachmentCount {
+		return nil, validationError("query %d was sent with images that were not retained", query.ID)
+	}
+
+	attachments := make([]models.QueryAttachment, len(records))
+	for i, record := range records {
+		data, err := base64.StdEncoding.DecodeString(record.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode query attachment %d: %w", record.ID, err)
+		}
+		attachments[i] = models.QueryAttachment{FileName: record.FileName, Data: data}
+	}
+	return attachments, nil
+}
+
+// MaxAttachmentBytes is the most image data a query may carry
+func (s *QueryService) MaxAttachmentBytes() int64 {
+	return int64(s.cfg.AttachmentMaxCount) * s.cfg.AttachmentMaxBytes
+}
+
+// AttachmentRateLimitWeight is how many requests a query with attachments
+// counts as against the rate limit
+func (s *QueryService) AttachmentRateLimitWeight() int {
+	return s.cfg.AttachmentRateLimitWeight
+}
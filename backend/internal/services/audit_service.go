@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+)
+
+// AuditFilter narrows an audit log query. Zero values match everything.
+type AuditFilter struct {
+	ActorID      string
+	Action       string
+	ResourceType string
+	ResourceID   string
+	From         time.Time
+	To           time.Time
+	Limit        int
+	Offset       int
+}
+
+// AuditService reads the admin audit trail. Entries are written by the
+// services that perform each action, in the same transaction.
+type AuditService struct{}
+
+func NewAuditService() *AuditService {
+	return &AuditService{}
+}
+
+// GetAuditLogs returns matching entries, newest first, and the total number of matches
+func (s *AuditService) GetAuditLogs(ctx context.Context, filter AuditFilter) ([]models.AuditLog, int64, error) {
+	query := db.DB.WithContext(ctx).Model(&models.AuditLog{})
+	if filter.ActorID != "" {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.ResourceType != "" {
+		query = query.Where("resource_type = ?", filter.ResourceType)
+	}
+	if filter.ResourceID != "" {
+		query = query.Where("resource_id = ?", filter.ResourceID)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit logs: %w", err)
+	}
+
+	var entries []models.AuditLog
+	err := query.Order("created_at DESC, id DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&entries).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get audit logs: %w", err)
+	}
+
+	return entries, total, nil
+}
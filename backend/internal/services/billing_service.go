@@ -0,0 +1,376 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// usageDateLayout is the layout of usage record dates
+const usageDateLayout = "2006-01-02"
+
+// Usage is reported for at most maxUsageRangeDays at a time, and for the last
+// defaultUsageRangeDays when no range is given
+const (
+	maxUsageRangeDays     = 366
+	defaultUsageRangeDays = 30
+)
+
+// usageChangeSlack widens the lookup of queries changed since the last
+// aggregation, so queries saved by transactions still open when it ran are
+// picked up by the next one
+const usageChangeSlack = 5 * time.Minute
+
+// usageLockKey is the Postgres advisory lock serializing recomputations of a
+// day's usage across instances
+const usageLockKey = 0x7573616765
+
+// usageCSVHeader is the header row of usage exports
+var usageCSVHeader = []string{"date", "user_id", "query_count", "cache_hits", "tokens_in", "tokens_out", "tokens_used", "estimated_cost"}
+
+// BillingService maintains daily usage records per user for invoicing. Each
+// run recomputes the days with queries saved or changed since the previous
+// run, which picks up queries saved late, such as async jobs, and sessions
+// claimed by a user afterwards.
+type BillingService struct {
+	interval        time.Duration
+	backfillDays    int
+	costPer1KTokens float64
+}
+
+func NewBillingService(cfg *config.Config) *BillingService {
+	return &BillingService{
+		interval:        time.Duration(cfg.BillingAggregationIntervalS) * time.Second,
+		backfillDays:    cfg.BillingBackfillDays,
+		costPer1KTokens: cfg.CostPer1KTokens,
+	}
+}
+
+// Start aggregates usage right away and then every interval, until ctx is
+// cancelled
+func (s *BillingService) Start(ctx context.Context) {
+	if s.interval <= 0 {
+		logrus.Info("BILLING_AGGREGATION_INTERVAL is 0, usage aggregation disabled")
+		return
+	}
+
+	go func() {
+		s.aggregate(ctx)
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.aggregate(ctx)
+			}
+		}
+	}()
+}
+
+// GetUsage returns the daily usage records of a period, oldest first, with
+// their total
+func (s *BillingService) GetUsage(ctx context.Context, filter models.UsageFilter) (*models.UsageReport, error) {
+	filter, err := normalizeUsageFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []models.UsageRecord
+	query := usageRecordScope(db.DB.WithContext(ctx), filter).Order("date ASC, user_id ASC")
+	if err := query.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to get usage records: %w", err)
+	}
+
+	report := &models.UsageReport{
+		UserID: filter.UserID,
+		From:   filter.From,
+		To:     filter.To,
+		Days:   records,
+	}
+	for _, record := range records {
+		addUsage(&report.Total, record.UsageCounts)
+	}
+	report.Total.EstimatedCost = s.cost(report.Total.TokensUsed)
+	return report, nil
+}
+
+// VerifyUsage recomputes a period's usage from its chat queries and reports
+// the records that differ
+func (s *BillingService) VerifyUsage(ctx context.Context, filter models.UsageFilter) (*models.UsageVerification, error) {
+	filter, err := normalizeUsageFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	from, to := usageRange(filter)
+
+	var recorded []models.UsageRecord
+	if err := usageRecordScope(db.DB.WithContext(ctx), filter).Find(&recorded).Error; err != nil {
+		return nil, fmt.Errorf("failed to get usage records: %w", err)
+	}
+	actual, err := s.usageFromQueries(db.DB.WithContext(ctx), from, to, filter.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	verification := &models.UsageVerification{
+		UserID:         filter.UserID,
+		From:           filter.From,
+		To:             filter.To,
+		CheckedRecords: len(recorded),
+		Drift:          []models.UsageDrift{},
+	}
+
+	type usageKey struct{ userID, date string }
+	recordedByKey := make(map[usageKey]models.UsageCounts, len(recorded))
+	for _, record := range recorded {
+		recordedByKey[usageKey{record.UserID, record.Date}] = record.UsageCounts
+		if verification.LastAggregatedAt == nil || record.AggregatedAt.After(*verification.LastAggregatedAt) {
+			aggregatedAt := record.AggregatedAt
+			verification.LastAggregatedAt = &aggregatedAt
+		}
+	}
+	for _, record := range actual {
+		key := usageKey{record.UserID, record.Date}
+		stored, ok := recordedByKey[key]
+		delete(recordedByKey, key)
+		if !ok || !sameUsage(stored, record.UsageCounts) {
+			verification.Drift = append(verification.Drift, models.UsageDrift{
+				UserID:   record.UserID,
+				Date:     record.Date,
+				Recorded: stored,
+				Actual:   record.UsageCounts,
+			})
+		}
+	}
+	// Records left had no queries, e.g. after a purge
+	for key, stored := range recordedByKey {
+		verification.Drift = append(verification.Drift, models.UsageDrift{
+			UserID:   key.userID,
+			Date:     key.date,
+			Recorded: stored,
+		})
+	}
+
+	sort.Slice(verification.Drift, func(i, j int) bool {
+		a, b := verification.Drift[i], verification.Drift[j]
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		return a.UserID < b.UserID
+	})
+	verification.InSync = len(verification.Drift) == 0
+	return verification, nil
+}
+
+// WriteUsageCSV writes usage records as CSV, one row per user and day
+func WriteUsageCSV(w io.Writer, records []models.UsageRecord) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(usageCSVHeader); err != nil {
+		return err
+	}
+	for _, record := range records {
+		err := writer.Write([]string{
+			record.Date,
+			record.UserID,
+			strconv.FormatInt(record.QueryCount, 10),
+			strconv.FormatInt(record.CacheHits, 10),
+			strconv.FormatInt(record.TokensIn, 10),
+			strconv.FormatInt(record.TokensOut, 10),
+			strconv.FormatInt(record.TokensUsed, 10),
+			strconv.FormatFloat(record.EstimatedCost, 'f', 6, 64),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// aggregate recomputes the usage of every day with queries saved or changed
+// since the last run; the first run backfills the configured days
+func (s *BillingService) aggregate(ctx context.Context) {
+	started := time.Now().UTC()
+
+	var lastRun *time.Time
+	if err := db.DB.WithContext(ctx).Model(&models.UsageRecord{}).Select("MAX(aggregated_at)").Scan(&lastRun).Error; err != nil {
+		logrus.WithError(err).Warn("Failed to get last usage aggregation")
+		return
+	}
+
+	var dates []string
+	if lastRun == nil {
+		today := started.Truncate(24 * time.Hour)
+		for day := today.AddDate(0, 0, -s.backfillDays+1); !day.After(today); day = day.AddDate(0, 0, 1) {
+			dates = append(dates, day.Format(usageDateLayout))
+		}
+	} else {
+		err := db.DB.WithContext(ctx).Model(&models.ChatQuery{}).
+			Where("updated_at >= ?", lastRun.Add(-usageChangeSlack)).
+			Distinct("to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD')").
+			Pluck("to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD')", &dates).Error
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to find days with changed queries")
+			return
+		}
+	}
+
+	for _, date := range dates {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := s.recomputeDay(ctx, date, started); err != nil {
+			logrus.WithError(err).WithField("date", date).Error("Failed to aggregate usage")
+			return
+		}
+	}
+
+	if len(dates) > 0 {
+		logrus.WithFields(logrus.Fields{
+			"days":     len(dates),
+			"duration": time.Since(started),
+		}).Info("Usage aggregated")
+	}
+}
+
+// recomputeDay replaces a day's usage records with sums over its queries
+func (s *BillingService) recomputeDay(ctx context.Context, date string, aggregatedAt time.Time) error {
+	day, err := time.Parse(usageDateLayout, date)
+	if err != nil {
+		return fmt.Errorf("invalid usage date %q: %w", date, err)
+	}
+
+	return db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", usageLockKey).Error; err != nil {
+			return fmt.Errorf("failed to lock usage records: %w", err)
+		}
+
+		records, err := s.usageFromQueries(tx, day, day.AddDate(0, 0, 1), "")
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("date = ?", date).Delete(&models.UsageRecord{}).Error; err != nil {
+			return fmt.Errorf("failed to delete usage records: %w", err)
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		for i := range records {
+			records[i].AggregatedAt = aggregatedAt
+		}
+		if err := tx.Create(&records).Error; err != nil {
+			return fmt.Errorf("failed to save usage records: %w", err)
+		}
+		return nil
+	})
+}
+
+// usageFromQueries sums the queries created in [from, to) per user and UTC
+// day. Synthetic queries, such as cache warm-up, aren't billed.
+func (s *BillingService) usageFromQueries(tx *gorm.DB, from, to time.Time, userID string) ([]models.UsageRecord, error) {
+	query := tx.Model(&models.ChatQuery{}).
+		Select(`COALESCE(user_id, '') AS user_id,
+			to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD') AS date,
+			COUNT(*) AS query_count,
+			COUNT(*) FILTER (WHERE cache_hit) AS cache_hits,
+			COALESCE(SUM(tokens_in), 0) AS tokens_in,
+			COALESCE(SUM(tokens_out), 0) AS tokens_out,
+			COALESCE(SUM(tokens_used), 0) AS tokens_used`).
+		Where("synthetic = ? AND created_at >= ? AND created_at < ?", false, from, to).
+		Group("1, 2").
+		Order("2, 1")
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var records []models.UsageRecord
+	if err := query.Scan(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to sum query usage: %w", err)
+	}
+	for i := range records {
+		records[i].EstimatedCost = s.cost(records[i].TokensUsed)
+	}
+	return records, nil
+}
+
+// cost estimates the cost of tokens
+func (s *BillingService) cost(tokens int64) float64 {
+	return float64(tokens) / 1000 * s.costPer1KTokens
+}
+
+// normalizeUsageFilter checks a filter's dates, defaulting to the last
+// defaultUsageRangeDays days
+func normalizeUsageFilter(filter models.UsageFilter) (models.UsageFilter, error) {
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	if filter.To != "" {
+		parsed, err := time.Parse(usageDateLayout, filter.To)
+		if err != nil {
+			return filter, validationError("to must be a YYYY-MM-DD date")
+		}
+		to = parsed
+	}
+
+	from := to.AddDate(0, 0, -defaultUsageRangeDays+1)
+	if filter.From != "" {
+		parsed, err := time.Parse(usageDateLayout, filter.From)
+		if err != nil {
+			return filter, validationError("from must be a YYYY-MM-DD date")
+		}
+		from = parsed
+	}
+
+	if from.After(to) {
+		return filter, validationError("from must not be after to")
+	}
+	if to.Sub(from) >= maxUsageRangeDays*24*time.Hour {
+		return filter, validationError("the range must be at most %d days", maxUsageRangeDays)
+	}
+
+	filter.From = from.Format(usageDateLayout)
+	filter.To = to.Format(usageDateLayout)
+	return filter, nil
+}
+
+// usageRange is the time range a normalized filter's dates cover
+func usageRange(filter models.UsageFilter) (time.Time, time.Time) {
+	from, _ := time.Parse(usageDateLayout, filter.From)
+	to, _ := time.Parse(usageDateLayout, filter.To)
+	return from, to.AddDate(0, 0, 1)
+}
+
+// usageRecordScope restricts a usage record lookup to a normalized filter
+func usageRecordScope(query *gorm.DB, filter models.UsageFilter) *gorm.DB {
+	query = query.Where("date >= ? AND date <= ?", filter.From, filter.To)
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	return query
+}
+
+// addUsage adds usage counts to a total
+func addUsage(total *models.UsageCounts, counts models.UsageCounts) {
+	total.QueryCount += counts.QueryCount
+	total.CacheHits += counts.CacheHits
+	total.TokensIn += counts.TokensIn
+	total.TokensOut += counts.TokensOut
+	total.TokensUsed += counts.TokensUsed
+}
+
+// sameUsage reports whether two usage counts match; costs follow from tokens
+func sameUsage(a, b models.UsageCounts) bool {
+	return a.QueryCount == b.QueryCount && a.CacheHits == b.CacheHits &&
+		a.TokensIn == b.TokensIn && a.TokensOut == b.TokensOut && a.TokensUsed == b.TokensUsed
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/cache"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// popularityKey is the Redis key counting how often a normalized query is asked
+func popularityKey(hash string) string {
+	return "query:popularity:" + hash
+}
+
+// popularityExpiryHalfLives is how many half-lives an untouched popularity
+// counter is kept; by then it has decayed below 1% of its value
+const popularityExpiryHalfLives = 7
+
+// minHitsTolerance absorbs the decay between asks moments apart, which
+// leaves a count a hair under the number of times the query was asked
+const minHitsTolerance = 0.01
+
+// cacheTTL is how long an answer is cached: fresh for Fresh and kept, to be
+// served stale while it is refreshed, for Lifetime. Skip is set for a query
+// asked too rarely to be worth caching.
+type cacheTTL struct {
+	Fresh      time.Duration
+	Lifetime   time.Duration
+	Skip       bool
+	Adaptive   bool
+	Popularity float64
+}
+
+// debug describes the TTL for debug responses
+func (t cacheTTL) debug() *models.CacheTTLDecision {
+	return &models.CacheTTLDecision{
+		FreshTTLS:  int(t.Fresh.Seconds()),
+		LifetimeS:  int(t.Lifetime.Seconds()),
+		Adaptive:   t.Adaptive,
+		Popularity: t.Popularity,
+		Skipped:    t.Skip,
+	}
+}
+
+// cacheTTLFor decides how long the answer to a query is cached. Queries in
+// the cache_adaptive_ttl_percent rollout, picked by hashing the query so a
+// query is always treated the same way, are cached by popularity: each ask
+// is counted, and the count decays so queries that stop being asked cool
+// down. The rest, and any query whose count can't be read, are cached for
+// the cache_ttl setting. Synthetic queries, such as cache warm-up, read the
+// count without adding to it.
+func (s *QueryService) cacheTTLFor(ctx context.Context, req models.QueryRequest) cacheTTL {
+	fresh := s.settings.CacheTTL()
+	lifetime := max(time.Duration(s.cfg.CacheStaleTTL)*time.Second, fresh)
+	ttl := cacheTTL{Fresh: fresh, Lifetime: lifetime}
+
+	hash := queryHash(req.Query)
+	if cache.Client == nil || bucket("cache_ttl:"+hash, 100) >= uint64(s.settings.CacheAdaptiveTTLPercent()) {
+		return ttl
+	}
+
+	halfLife := time.Duration(s.cfg.CachePopularityHalfLifeS) * time.Second
+	now := time.Now()
+	var popularity float64
+	var err error
+	if req.Synthetic {
+		popularity, err = cache.DecayedCounter(ctx, popularityKey(hash), now, halfLife)
+	} else {
+		popularity, err = cache.DecayedIncrement(ctx, popularityKey(hash), now, halfLife, popularityExpiryHalfLives*halfLife)
+	}
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to count query popularity, caching for the default TTL")
+		return ttl
+	}
+
+	ttl.Adaptive = true
+	ttl.Popularity = popularity
+	ttl.Skip = s.cfg.CacheMinHits > 0 && popularity < float64(s.cfg.CacheMinHits)-minHitsTolerance
+	ttl.Fresh = popularityTTL(popularity,
+		time.Duration(s.cfg.CacheTTLMin)*time.Second,
+		time.Duration(s.cfg.CacheTTLMax)*time.Second,
+		s.cfg.CacheTTLHotHits)
+	// The stale window scales with the fresh TTL, so rare queries don't
+	// linger for the full CACHE_STALE_TTL
+	ttl.Lifetime = time.Duration(float64(ttl.Fresh) * float64(lifetime) / float64(fresh))
+	return ttl
+}
+
+// popularityTTL maps how often a query is asked to a fresh TTL, from
+// minTTL for a query asked once up to maxTTL for one asked hotHits times.
+// The TTL grows geometrically, so each doubling of popularity extends it
+// by the same factor.
+func popularityTTL(popularity float64, minTTL, maxTTL time.Duration, hotHits int) time.Duration {
+	if popularity <= 1 {
+		return minTTL
+	}
+	if popularity >= float64(hotHits) {
+		return maxTTL
+	}
+
+	share := math.Log(popularity) / math.Log(float64(hotHits))
+	ttl := float64(minTTL) * math.Pow(float64(maxTTL)/float64(minTTL), share)
+	return time.Duration(ttl).Round(time.Second)
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/models"
+)
+
+const day = 24 * time.Hour
+
+// newCacheTTLService builds a query service caching adaptively for
+// rolloutPercent of queries: from 5 minutes for a query asked once up to a
+// day for one asked 50 times, with popularity halving daily. Queries asked
+// fewer than twice aren't cached.
+func newCacheTTLService(rolloutPercent int) *QueryService {
+	cfg := &config.Config{
+		CacheTTL:                 3600,
+		CacheStaleTTL:            7200,
+		CacheAdaptiveTTLPercent:  rolloutPercent,
+		CacheTTLMin:              300,
+		CacheTTLMax:              86400,
+		CacheTTLHotHits:          50,
+		CacheMinHits:             2,
+		CachePopularityHalfLifeS: 86400,
+	}
+	return &QueryService{cfg: cfg, settings: NewSettingsService(cfg)}
+}
+
+func TestCacheTTLHotAndColdQueries(t *testing.T) {
+	redis := useFakeRedis(t)
+	s := newCacheTTLService(100)
+	ctx := context.Background()
+
+	hot := models.QueryRequest{Query: "Where is my order?"}
+	var ttl cacheTTL
+	for i := 0; i < 60; i++ {
+		ttl = s.cacheTTLFor(ctx, hot)
+	}
+	if !ttl.Adaptive || ttl.Skip || ttl.Fresh != day || ttl.Lifetime != 2*day {
+		t.Errorf("hot query TTL = %+v, want cached fresh for a day and kept for two", ttl)
+	}
+	if ttl.Popularity < 59 || ttl.Popularity > 60 {
+		t.Errorf("hot query popularity = %v, want about 60", ttl.Popularity)
+	}
+
+	cold := s.cacheTTLFor(ctx, models.QueryRequest{Query: "Can I pay for a refund in Bitcoin?"})
+	if !cold.Adaptive || !cold.Skip || cold.Fresh != 5*time.Minute || cold.Lifetime != 10*time.Minute {
+		t.Errorf("cold query TTL = %+v, want it skipped at the minimum TTL", cold)
+	}
+
+	// A second ask takes it over the hit threshold
+	warm := s.cacheTTLFor(ctx, models.QueryRequest{Query: "Can I pay for a refund in Bitcoin?"})
+	if warm.Skip || warm.Fresh <= cold.Fresh || warm.Fresh >= day {
+		t.Errorf("query asked twice TTL = %+v, want it cached between the bounds", warm)
+	}
+
+	if redis.ttl(popularityKey(queryHash(hot.Query))) <= 0 {
+		t.Error("popularity counter has no expiry")
+	}
+}
+
+func TestCacheTTLPopularityDecays(t *testing.T) {
+	redis := useFakeRedis(t)
+	s := newCacheTTLService(100)
+	req := models.QueryRequest{Query: "Where is my order?"}
+
+	// Asked 64 times, but last two days ago: two half-lives have passed
+	at := time.Now().Add(-2 * day).UnixMilli()
+	redis.setHash(popularityKey(queryHash(req.Query)), map[string]string{"score": "64", "at": strconv.FormatInt(at, 10)})
+
+	ttl := s.cacheTTLFor(context.Background(), req)
+	if ttl.Popularity < 16.9 || ttl.Popularity > 17.1 {
+		t.Errorf("popularity = %v, want 64 decayed to 16 plus this ask", ttl.Popularity)
+	}
+	if ttl.Fresh >= day {
+		t.Errorf("fresh TTL = %v, want a cooled query below the maximum", ttl.Fresh)
+	}
+}
+
+func TestCacheTTLSyntheticQueriesDontCount(t *testing.T) {
+	useFakeRedis(t)
+	s := newCacheTTLService(100)
+	req := models.QueryRequest{Query: "Where is my order?", Synthetic: true}
+
+	for i := 0; i < 5; i++ {
+		if ttl := s.cacheTTLFor(context.Background(), req); ttl.Popularity != 0 {
+			t.Fatalf("warm-up ask %d: popularity = %v, want it uncounted", i+1, ttl.Popularity)
+		}
+	}
+}
+
+func TestCacheTTLOutsideRollout(t *testing.T) {
+	useFakeRedis(t)
+	ttl := newCacheTTLService(0).cacheTTLFor(context.Background(), models.QueryRequest{Query: "Where is my order?"})
+	if ttl.Adaptive || ttl.Skip || ttl.Fresh != time.Hour || ttl.Lifetime != 2*time.Hour {
+		t.Errorf("TTL = %+v, want the cache_ttl setting", ttl)
+	}
+}
+
+func TestCacheTTLRolloutIsStablePerQuery(t *testing.T) {
+	useFakeRedis(t)
+	s := newCacheTTLService(30)
+	ctx := context.Background()
+
+	adaptive := 0
+	const queries = 1000
+	for i := 0; i < queries; i++ {
+		req := models.QueryRequest{Query: fmt.Sprintf("question %d", i)}
+		first := s.cacheTTLFor(ctx, req).Adaptive
+		if again := s.cacheTTLFor(ctx, req).Adaptive; again != first {
+			t.Fatalf("%q moved in and out of the rollout", req.Query)
+		}
+		if first {
+			adaptive++
+		}
+	}
+	if adaptive < 250 || adaptive > 350 {
+		t.Errorf("%d of %d queries cached adaptively, want about 30%%", adaptive, queries)
+	}
+}
+
+func TestPopularityTTL(t *testing.T) {
+	minTTL, maxTTL := 5*time.Minute, day
+	tests := []struct {
+		popularity float64
+		want       time.Duration
+	}{
+		{0, minTTL},
+		{1, minTTL},
+		{50, maxTTL},
+		{500, maxTTL},
+		// Halfway up the log scale is the geometric mean of the bounds
+		{7.0710678, 5091 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := popularityTTL(tt.popularity, minTTL, maxTTL, 50); got != tt.want {
+			t.Errorf("popularityTTL(%v) = %v, want %v", tt.popularity, got, tt.want)
+		}
+	}
+
+	previous := minTTL
+	for popularity := 2.0; popularity < 50; popularity *= 1.5 {
+		got := popularityTTL(popularity, minTTL, maxTTL, 50)
+		if got <= previous {
+			t.Errorf("popularityTTL(%v) = %v, not above %v for less popular queries", popularity, got, previous)
+		}
+		previous = got
+	}
+}
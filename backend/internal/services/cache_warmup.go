@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// Cache warm-up run statuses
+const (
+	WarmupRunning   = "running"
+	WarmupCompleted = "completed"
+)
+
+// Cache warm-up triggers
+const (
+	WarmupTriggerStartup = "startup"
+	WarmupTriggerAdmin   = "admin"
+)
+
+// warmupWindow is how far back warm-up looks for popular queries
+const warmupWindow = 7 * 24 * time.Hour
+
+// warmupSessionID is the session warm-up queries are asked in. It never gets
+// a session row, so every warm-up query opens a conversation and its answer
+// is cached under the key shared across sessions.
+const warmupSessionID = "system:cache-warmup"
+
+// StartWarmup starts warming the cache with the most asked queries of the
+// last week in the background and returns the new run. Only one run goes at
+// a time, and none starts while the RAG service is unavailable.
+func (s *QueryService) StartWarmup(trigger string) (*models.CacheWarmupResult, error) {
+	if !s.health.RAGAvailable() {
+		return nil, &DegradedError{RetryAfter: s.health.ProbeInterval()}
+	}
+
+	s.warmupMu.Lock()
+	defer s.warmupMu.Unlock()
+
+	if s.warmup != nil && s.warmup.Status == WarmupRunning {
+		return nil, fmt.Errorf("%w: a cache warm-up is already running", ErrInvalidRequest)
+	}
+
+	run := &models.CacheWarmupResult{
+		Status:    WarmupRunning,
+		Trigger:   trigger,
+		StartedAt: time.Now().UTC(),
+	}
+	started := s.lifecycle.Go("cache_warmup", logrus.Fields{"trigger": trigger}, func(ctx context.Context) {
+		s.warmCache(ctx, run)
+	})
+	if !started {
+		return nil, fmt.Errorf("%w: shutting down", ErrOverloaded)
+	}
+
+	s.warmup = run
+	result := *run
+	return &result, nil
+}
+
+// LastWarmup returns the latest cache warm-up run, or nil if none has run
+func (s *QueryService) LastWarmup() *models.CacheWarmupResult {
+	s.warmupMu.Lock()
+	defer s.warmupMu.Unlock()
+
+	if s.warmup == nil {
+		return nil
+	}
+	result := *s.warmup
+	return &result
+}
+
+// warmCache runs the popular queries through the query pipeline as synthetic
+// queries, a few at a time, until they are done or the time budget runs out
+func (s *QueryService) warmCache(ctx context.Context, run *models.CacheWarmupResult) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(s.cfg.CacheWarmupBudgetS)*time.Second)
+	defer cancel()
+	defer s.finishWarmup(run)
+
+	since := time.Now().Add(-warmupWindow)
+	queries, err := topQueries(analyticsQueries().Where("chat_queries.created_at >= ?", since), s.cfg.CacheWarmupQueries)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get top queries for cache warm-up")
+		s.updateWarmup(func() { run.Error = "failed to get top queries" })
+		return
+	}
+	s.updateWarmup(func() { run.Queries = len(queries) })
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < s.cfg.CacheWarmupConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for query := range jobs {
+				s.warmQuery(ctx, run, query)
+			}
+		}()
+	}
+	for _, query := range queries {
+		jobs <- query.Query
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// warmQuery asks one query and counts the outcome. Once the budget is spent,
+// the RAG service is unavailable or shutdown has begun, queries are counted as
+// remaining.
+func (s *QueryService) warmQuery(ctx context.Context, run *models.CacheWarmupResult, query string) {
+	if ctx.Err() != nil || !s.health.RAGAvailable() || s.lifecycle.Stopping() {
+		s.updateWarmup(func() { run.Remaining++ })
+		return
+	}
+
+	resp, err := s.ProcessQuery(ctx, models.QueryRequest{
+		Query:     query,
+		SessionID: warmupSessionID,
+		Synthetic: true,
+	})
+
+	s.updateWarmup(func() {
+		switch {
+		case err != nil && (ctx.Err() != nil || errors.Is(err, ErrDegraded)):
+			run.Remaining++
+		case err != nil:
+			logrus.WithError(err).Warn("Cache warm-up query failed")
+			run.Failed++
+		case resp.CacheHit:
+			run.AlreadyCached++
+		case resp.CacheBypassed, resp.Moderated, resp.Model == CannedModel:
+			run.Skipped++
+		default:
+			run.Warmed++
+		}
+	})
+}
+
+// updateWarmup applies an update to a warm-up run under the lock
+func (s *QueryService) updateWarmup(update func()) {
+	s.warmupMu.Lock()
+	defer s.warmupMu.Unlock()
+	update()
+}
+
+// finishWarmup marks a warm-up run completed and logs its outcome
+func (s *QueryService) finishWarmup(run *models.CacheWarmupResult) {
+	s.warmupMu.Lock()
+	defer s.warmupMu.Unlock()
+
+	now := time.Now().UTC()
+	run.Status = WarmupCompleted
+	run.FinishedAt = &now
+
+	logrus.WithFields(logrus.Fields{
+		"trigger":        run.Trigger,
+		"queries":        run.Queries,
+		"warmed":         run.Warmed,
+		"already_cached": run.AlreadyCached,
+		"skipped":        run.Skipped,
+		"failed":         run.Failed,
+		"remaining":      run.Remaining,
+		"duration_ms":    now.Sub(run.StartedAt).Milliseconds(),
+	}).Info("Cache warm-up finished")
+}
@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/audit"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Model names recorded on ChatQuery rows not answered by the LLM
+const (
+	CannedModel     = "canned"
+	ModerationModel = "moderation"
+)
+
+// Canned answer match types
+const (
+	MatchExact   = "exact"
+	MatchKeyword = "keyword"
+	MatchRegex   = "regex"
+)
+
+// cannedAnswerRefresh bounds how stale the in-memory matcher can get across instances
+const cannedAnswerRefresh = 30 * time.Second
+
+// compiledAnswer is a canned answer prepared for matching
+type compiledAnswer struct {
+	answer   models.CannedAnswer
+	keywords []string
+	regex    *regexp.Regexp
+}
+
+type CannedAnswerService struct {
+	mu       sync.RWMutex
+	answers  []compiledAnswer
+	loadedAt time.Time
+}
+
+func NewCannedAnswerService() *CannedAnswerService {
+	return &CannedAnswerService{}
+}
+
+// ValidateCannedAnswer checks that a canned answer request can be matched
+func ValidateCannedAnswer(req models.CannedAnswerRequest) error {
+	switch matchTypeOrDefault(req.MatchType) {
+	case MatchRegex:
+		if _, err := regexp.Compile(req.Pattern); err != nil {
+			return validationError("invalid regex pattern: %v", err)
+		}
+	case MatchKeyword:
+		if len(splitKeywords(req.Pattern)) == 0 {
+			return validationError("keyword pattern must contain at least one keyword")
+		}
+	default:
+		if normalizeQuery(req.Pattern) == "" {
+			return validationError("pattern must not be empty")
+		}
+	}
+	return nil
+}
+
+// Match returns the highest-priority enabled canned answer matching the query, or nil
+func (s *CannedAnswerService) Match(ctx context.Context, query string) *models.CannedAnswer {
+	answers, err := s.load()
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to load canned answers")
+		return nil
+	}
+
+	normalized := normalizeQuery(query)
+	for i := range answers {
+		if answers[i].matches(normalized) {
+			answer := answers[i].answer
+			return &answer
+		}
+	}
+
+	return nil
+}
+
+// CreateCannedAnswer saves a new canned answer
+func (s *CannedAnswerService) CreateCannedAnswer(ctx context.Context, req models.CannedAnswerRequest) (*models.CannedAnswer, error) {
+	if err := ValidateCannedAnswer(req); err != nil {
+		return nil, err
+	}
+
+	answer := models.CannedAnswer{Enabled: true}
+	applyCannedAnswerRequest(&answer, req)
+
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&answer).Error; err != nil {
+			return fmt.Errorf("failed to save canned answer: %w", err)
+		}
+		return audit.Record(ctx, tx, "canned_answer.create", "canned_answer", strconv.FormatUint(uint64(answer.ID), 10), nil, answer)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidate()
+	return &answer, nil
+}
+
+// GetCannedAnswers returns all canned answers in match order
+func (s *CannedAnswerService) GetCannedAnswers(ctx context.Context) ([]models.CannedAnswer, error) {
+	var answers []models.CannedAnswer
+
+	if err := db.DB.Order("priority DESC, id ASC").Find(&answers).Error; err != nil {
+		return nil, fmt.Errorf("failed to get canned answers: %w", err)
+	}
+
+	return answers, nil
+}
+
+// GetCannedAnswerByID returns a canned answer by ID
+func (s *CannedAnswerService) GetCannedAnswerByID(ctx context.Context, id uint) (*models.CannedAnswer, error) {
+	var answer models.CannedAnswer
+
+	if err := db.DB.First(&answer, id).Error; err != nil {
+		return nil, notFoundError("canned answer", err)
+	}
+
+	return &answer, nil
+}
+
+// UpdateCannedAnswer updates a canned answer
+func (s *CannedAnswerService) UpdateCannedAnswer(ctx context.Context, id uint, req models.CannedAnswerRequest) (*models.CannedAnswer, error) {
+	if err := ValidateCannedAnswer(req); err != nil {
+		return nil, err
+	}
+
+	answer, err := s.GetCannedAnswerByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *answer
+	applyCannedAnswerRequest(answer, req)
+
+	err = db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(answer).Error; err != nil {
+			return fmt.Errorf("failed to update canned answer: %w", err)
+		}
+		return audit.Record(ctx, tx, "canned_answer.update", "canned_answer", strconv.FormatUint(uint64(id), 10), before, answer)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidate()
+	return answer, nil
+}
+
+// DeleteCannedAnswer deletes a canned answer
+func (s *CannedAnswerService) DeleteCannedAnswer(ctx context.Context, id uint) error {
+	answer, err := s.GetCannedAnswerByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.CannedAnswer{}, id).Error; err != nil {
+			return fmt.Errorf("failed to delete canned answer: %w", err)
+		}
+		return audit.Record(ctx, tx, "canned_answer.delete", "canned_answer", strconv.FormatUint(uint64(id), 10), answer, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.invalidate()
+	return nil
+}
+
+// load returns the compiled enabled answers followed by approved annotations,
+// refreshing from the database when stale
+func (s *CannedAnswerService) load() ([]compiledAnswer, error) {
+	s.mu.RLock()
+	if !s.loadedAt.IsZero() && time.Since(s.loadedAt) < cannedAnswerRefresh {
+		answers := s.answers
+		s.mu.RUnlock()
+		return answers, nil
+	}
+	s.mu.RUnlock()
+
+	var rows []models.CannedAnswer
+	if err := db.DB.Where("enabled = ?", true).Order("priority DESC, id ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	answers := make([]compiledAnswer, 0, len(rows))
+	for _, row := range rows {
+		compiled := compiledAnswer{answer: row}
+		switch matchTypeOrDefault(row.MatchType) {
+		case MatchRegex:
+			re, err := regexp.Compile(row.Pattern)
+			if err != nil {
+				logrus.WithError(err).WithField("canned_answer_id", row.ID).Warn("Skipping canned answer with invalid regex")
+				continue
+			}
+			compiled.regex = re
+		case MatchKeyword:
+			compiled.keywords = splitKeywords(row.Pattern)
+		}
+		answers = append(answers, compiled)
+	}
+
+	// Approved corrections answer exact repeats of their question, after
+	// every canned answer and newest first
+	var annotations []models.Annotation
+	if err := db.DB.Select("id", "question", "corrected_answer").Where("status = ?", AnnotationApproved).Order("approved_at DESC, id DESC").Find(&annotations).Error; err != nil {
+		return nil, err
+	}
+	for _, annotation := range annotations {
+		id := annotation.ID
+		answers = append(answers, compiledAnswer{answer: models.CannedAnswer{
+			Pattern:      normalizeQuery(annotation.Question),
+			MatchType:    MatchExact,
+			Answer:       annotation.CorrectedAnswer,
+			Enabled:      true,
+			AnnotationID: &id,
+		}})
+	}
+
+	s.mu.Lock()
+	s.answers = answers
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+
+	return answers, nil
+}
+
+// invalidate forces the next Match to reload from the database
+func (s *CannedAnswerService) invalidate() {
+	s.mu.Lock()
+	s.loadedAt = time.Time{}
+	s.mu.Unlock()
+}
+
+// matches reports whether a normalized query matches the canned answer
+func (a *compiledAnswer) matches(normalized string) bool {
+	switch {
+	case a.regex != nil:
+		return a.regex.MatchString(normalized)
+	case a.keywords != nil:
+		for _, keyword := range a.keywords {
+			if !strings.Contains(normalized, keyword) {
+				return false
+			}
+		}
+		return true
+	default:
+		return normalizeQuery(a.answer.Pattern) == normalized
+	}
+}
+
+// applyCannedAnswerRequest copies request fields onto a canned answer
+func applyCannedAnswerRequest(answer *models.CannedAnswer, req models.CannedAnswerRequest) {
+	answer.MatchType = matchTypeOrDefault(req.MatchType)
+	answer.Pattern = req.Pattern
+	if answer.MatchType == MatchExact {
+		answer.Pattern = normalizeQuery(req.Pattern)
+	}
+	answer.Answer = req.Answer
+	answer.Priority = req.Priority
+	if req.Enabled != nil {
+		answer.Enabled = *req.Enabled
+	}
+}
+
+// matchTypeOrDefault returns the match type, defaulting to exact
+func matchTypeOrDefault(matchType string) string {
+	if matchType == "" {
+		return MatchExact
+	}
+	return matchType
+}
+
+// splitKeywords splits a comma-separated keyword pattern into normalized keywords
+func splitKeywords(pattern string) []string {
+	var keywords []string
+	for _, keyword := range strings.Split(pattern, ",") {
+		if keyword = normalizeQuery(keyword); keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+	return keywords
+}
+
+// normalizeQuery lowercases a query, collapses whitespace and strips trailing punctuation
+func normalizeQuery(query string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	return strings.TrimRight(normalized, "?!.,;: ")
+}
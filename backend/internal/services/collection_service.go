@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+)
+
+// collectionNamePattern restricts collection names to lowercase slugs
+var collectionNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,99}$`)
+
+type CollectionService struct{}
+
+func NewCollectionService() *CollectionService {
+	return &CollectionService{}
+}
+
+// NormalizeCollectionName lowercases and trims a collection name
+func NormalizeCollectionName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// GetCollections returns all collections with their document and chunk counts
+func (s *CollectionService) GetCollections(ctx context.Context) ([]models.CollectionSummary, error) {
+	var collections []models.CollectionSummary
+
+	err := db.DB.Model(&models.Collection{}).
+		Select("collections.id, collections.name, collections.description, COUNT(documents.id) AS document_count, COALESCE(SUM(documents.chunk_count), 0) AS chunk_count").
+		Joins("LEFT JOIN documents ON documents.collection_id = collections.id AND documents.status <> ?", "superseded").
+		Group("collections.id, collections.name, collections.description").
+		Order("collections.name ASC").
+		Scan(&collections).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collections: %w", err)
+	}
+
+	return collections, nil
+}
+
+// resolveCollection returns the collection with the given name, creating it if needed
+func resolveCollection(name string) (*models.Collection, error) {
+	name = NormalizeCollectionName(name)
+	if !collectionNamePattern.MatchString(name) {
+		return nil, validationError("invalid collection name %q: use lowercase letters, digits, '-' and '_'", name)
+	}
+
+	collection := models.Collection{Name: name}
+	if err := db.DB.Where("name = ?", name).FirstOrCreate(&collection).Error; err != nil {
+		return nil, fmt.Errorf("failed to resolve collection: %w", err)
+	}
+
+	return &collection, nil
+}
+
+// validateCollections normalizes collection names and checks that they all exist.
+// Unknown names are reported as an invalid request.
+func validateCollections(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	seen := make(map[string]bool, len(names))
+	normalized := make([]string, 0, len(names))
+	for _, name := range names {
+		name = NormalizeCollectionName(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		normalized = append(normalized, name)
+	}
+	sort.Strings(normalized)
+
+	var existing []string
+	if err := db.DB.Model(&models.Collection{}).Where("name IN ?", normalized).Pluck("name", &existing).Error; err != nil {
+		return nil, fmt.Errorf("failed to check collections: %w", err)
+	}
+
+	found := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		found[name] = true
+	}
+
+	var unknown []string
+	for _, name := range normalized {
+		if !found[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("%w: unknown collections: %s", ErrInvalidRequest, strings.Join(unknown, ", "))
+	}
+
+	return normalized, nil
+}
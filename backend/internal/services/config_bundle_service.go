@@ -0,0 +1,750 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/audit"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ConfigBundleVersion is the config bundle format written by exports and the
+// only one accepted by imports
+const ConfigBundleVersion = 1
+
+// Resource types in a config bundle, as named in import conflict options
+const (
+	BundleCannedAnswers   = "canned_answers"
+	BundlePromptTemplates = "prompt_templates"
+	BundleWidgetConfigs   = "widget_configs"
+	BundleSettings        = "settings"
+)
+
+// Import conflict resolutions
+const (
+	ConflictSkip      = "skip"
+	ConflictOverwrite = "overwrite"
+)
+
+// Import change actions
+const (
+	ImportCreate = "create"
+	ImportUpdate = "update"
+	ImportDelete = "delete"
+	ImportSkip   = "skip"
+)
+
+// importChange is a planned import change and how to apply it
+type importChange struct {
+	models.ConfigImportChange
+	apply func(ctx context.Context, tx *gorm.DB) error
+}
+
+// importPlan is everything an import would change
+type importPlan struct {
+	changes   []importChange
+	unchanged int
+	touched   map[string]bool // resource types with changes to apply
+}
+
+// add records a change, keeping diffs only for updates and skipped conflicts
+func (p *importPlan) add(resourceType, key, action string, before, after interface{}, apply func(ctx context.Context, tx *gorm.DB) error) error {
+	change := importChange{
+		ConfigImportChange: models.ConfigImportChange{ResourceType: resourceType, Key: key, Action: action},
+		apply:              apply,
+	}
+	if action == ImportUpdate || action == ImportSkip {
+		diff, err := audit.Diff(before, after)
+		if err != nil {
+			return fmt.Errorf("failed to diff %s %s: %w", resourceType, key, err)
+		}
+		change.Changes = diff
+	}
+	if action != ImportSkip {
+		p.touched[resourceType] = true
+	}
+	p.changes = append(p.changes, change)
+	return nil
+}
+
+// ConfigBundleService exports admin configuration as a bundle and imports
+// bundles from other environments
+type ConfigBundleService struct {
+	cannedAnswers *CannedAnswerService
+	prompts       *PromptService
+	widgets       *WidgetService
+	settings      *SettingsService
+}
+
+func NewConfigBundleService(cannedAnswers *CannedAnswerService, prompts *PromptService, widgets *WidgetService, settings *SettingsService) *ConfigBundleService {
+	return &ConfigBundleService{
+		cannedAnswers: cannedAnswers,
+		prompts:       prompts,
+		widgets:       widgets,
+		settings:      settings,
+	}
+}
+
+// Export returns the canned answers, prompt templates, widget configs and
+// setting overrides as a bundle. Resources are sorted by their keys, so
+// exports of the same configuration are identical apart from ExportedAt.
+func (s *ConfigBundleService) Export(ctx context.Context) (*models.ConfigBundle, error) {
+	tx := db.DB.WithContext(ctx)
+	bundle := &models.ConfigBundle{
+		Version:         ConfigBundleVersion,
+		ExportedAt:      time.Now().UTC(),
+		CannedAnswers:   []models.CannedAnswerRequest{},
+		PromptTemplates: []models.BundlePromptTemplate{},
+		WidgetConfigs:   []models.WidgetConfigRequest{},
+		Settings:        map[string]string{},
+	}
+
+	var answers []models.CannedAnswer
+	if err := tx.Order("id ASC").Find(&answers).Error; err != nil {
+		return nil, fmt.Errorf("failed to get canned answers: %w", err)
+	}
+	for _, answer := range answers {
+		bundle.CannedAnswers = append(bundle.CannedAnswers, cannedAnswerEntry(answer))
+	}
+	sort.SliceStable(bundle.CannedAnswers, func(i, j int) bool {
+		return cannedAnswerKey(bundle.CannedAnswers[i]) < cannedAnswerKey(bundle.CannedAnswers[j])
+	})
+
+	var templates []models.PromptTemplate
+	if err := tx.Order("name ASC, version ASC").Find(&templates).Error; err != nil {
+		return nil, fmt.Errorf("failed to get prompt templates: %w", err)
+	}
+	for _, template := range templates {
+		bundle.PromptTemplates = append(bundle.PromptTemplates, promptTemplateEntry(template))
+	}
+
+	var widgets []models.WidgetConfig
+	if err := tx.Order("origin ASC").Find(&widgets).Error; err != nil {
+		return nil, fmt.Errorf("failed to get widget configs: %w", err)
+	}
+	for _, widget := range widgets {
+		bundle.WidgetConfigs = append(bundle.WidgetConfigs, widgetConfigEntry(widget))
+	}
+
+	var settings []models.Setting
+	if err := tx.Find(&settings).Error; err != nil {
+		return nil, fmt.Errorf("failed to get settings: %w", err)
+	}
+	for _, setting := range settings {
+		bundle.Settings[setting.Key] = setting.Value
+	}
+
+	return bundle, nil
+}
+
+// Import validates a bundle and applies it in one transaction, recording
+// each change in the audit log. A dry run returns the changes without
+// applying them. Existing resources that differ from the bundle are kept or
+// overwritten per the request's conflict options.
+func (s *ConfigBundleService) Import(ctx context.Context, req models.ConfigImportRequest, dryRun bool) (*models.ConfigImportResult, error) {
+	conflicts, err := s.validateBundle(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan *importPlan
+	err = db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if plan, err = planImport(tx, req.Bundle, conflicts, req.Prune); err != nil {
+			return err
+		}
+		if dryRun {
+			return nil
+		}
+
+		for _, change := range plan.changes {
+			if change.apply == nil {
+				continue
+			}
+			if err := change.apply(ctx, tx); err != nil {
+				return err
+			}
+		}
+
+		summary := map[string]interface{}{
+			"bundle_version": req.Bundle.Version,
+			"exported_at":    req.Bundle.ExportedAt,
+			"changes":        len(plan.changes),
+			"unchanged":      plan.unchanged,
+		}
+		return audit.Record(ctx, tx, "config.import", "config_bundle", strconv.Itoa(req.Bundle.Version), nil, summary)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.ConfigImportResult{
+		DryRun:    dryRun,
+		Changes:   make([]models.ConfigImportChange, 0, len(plan.changes)),
+		Unchanged: plan.unchanged,
+	}
+	for _, change := range plan.changes {
+		result.Changes = append(result.Changes, change.ConfigImportChange)
+		switch change.Action {
+		case ImportCreate:
+			result.Created++
+		case ImportUpdate:
+			result.Updated++
+		case ImportDelete:
+			result.Deleted++
+		case ImportSkip:
+			result.Skipped++
+		}
+	}
+
+	if !dryRun {
+		s.invalidate(ctx, plan.touched)
+		logrus.WithFields(logrus.Fields{
+			"created":   result.Created,
+			"updated":   result.Updated,
+			"deleted":   result.Deleted,
+			"skipped":   result.Skipped,
+			"unchanged": result.Unchanged,
+		}).Info("Imported config bundle")
+	}
+
+	return result, nil
+}
+
+// invalidate drops the cached state of every resource type the import changed
+func (s *ConfigBundleService) invalidate(ctx context.Context, touched map[string]bool) {
+	if touched[BundleCannedAnswers] {
+		s.cannedAnswers.invalidate()
+	}
+	if touched[BundlePromptTemplates] {
+		s.prompts.invalidate()
+	}
+	if touched[BundleWidgetConfigs] {
+		s.widgets.invalidate(ctx)
+	}
+	if touched[BundleSettings] {
+		s.settings.invalidate(ctx)
+	}
+}
+
+// validateBundle checks the bundle version, every resource and the conflict
+// options, returning the resolution for each resource type
+func (s *ConfigBundleService) validateBundle(req models.ConfigImportRequest) (map[string]string, error) {
+	bundle := req.Bundle
+	if bundle.Version != ConfigBundleVersion {
+		return nil, validationError("unsupported bundle version %d; expected %d", bundle.Version, ConfigBundleVersion)
+	}
+
+	conflicts := map[string]string{
+		BundleCannedAnswers:   ConflictSkip,
+		BundlePromptTemplates: ConflictSkip,
+		BundleWidgetConfigs:   ConflictSkip,
+		BundleSettings:        ConflictSkip,
+	}
+	for resourceType, resolution := range req.Conflicts {
+		if _, ok := conflicts[resourceType]; !ok {
+			return nil, validationError("unknown resource type %q in conflicts", resourceType)
+		}
+		if resolution != ConflictSkip && resolution != ConflictOverwrite {
+			return nil, validationError("conflict resolution for %s must be skip or overwrite", resourceType)
+		}
+		conflicts[resourceType] = resolution
+	}
+
+	seen := map[string]bool{}
+	for i, answer := range bundle.CannedAnswers {
+		if err := ValidateCannedAnswer(answer); err != nil {
+			return nil, fmt.Errorf("canned_answers[%d]: %w", i, err)
+		}
+		key := cannedAnswerKey(normalizeCannedAnswerEntry(answer))
+		if seen[key] {
+			return nil, validationError("canned_answers[%d]: duplicate canned answer %q", i, key)
+		}
+		seen[key] = true
+	}
+
+	active := 0
+	for i, template := range bundle.PromptTemplates {
+		key := promptTemplateKey(template.Name, template.Version)
+		if seen[key] {
+			return nil, validationError("prompt_templates[%d]: duplicate prompt template %q", i, key)
+		}
+		seen[key] = true
+		if template.Active {
+			active++
+		}
+	}
+	if active > 1 {
+		return nil, validationError("at most one prompt template can be active")
+	}
+
+	for i, widget := range bundle.WidgetConfigs {
+		if err := ValidateWidgetConfig(widget); err != nil {
+			return nil, fmt.Errorf("widget_configs[%d]: %w", i, err)
+		}
+		key := "widget:" + NormalizeOrigin(widget.Origin)
+		if seen[key] {
+			return nil, validationError("widget_configs[%d]: duplicate widget config for origin %q", i, NormalizeOrigin(widget.Origin))
+		}
+		seen[key] = true
+	}
+
+	scratch := s.settings.defaults()
+	for key, value := range bundle.Settings {
+		if err := applySetting(scratch, key, value); err != nil {
+			return nil, validationError("invalid %s: %v", key, err)
+		}
+	}
+
+	return conflicts, nil
+}
+
+// planImport compares the bundle with the stored configuration
+func planImport(tx *gorm.DB, bundle models.ConfigBundle, conflicts map[string]string, prune bool) (*importPlan, error) {
+	plan := &importPlan{touched: map[string]bool{}}
+
+	steps := []func(*gorm.DB, *importPlan, models.ConfigBundle, string, bool) error{
+		planCannedAnswers,
+		planPromptTemplates,
+		planWidgetConfigs,
+		planSettings,
+	}
+	types := []string{BundleCannedAnswers, BundlePromptTemplates, BundleWidgetConfigs, BundleSettings}
+	for i, step := range steps {
+		if err := step(tx, plan, bundle, conflicts[types[i]], prune); err != nil {
+			return nil, err
+		}
+	}
+
+	return plan, nil
+}
+
+// resolve returns the action for a bundle resource given whether it exists
+// and differs from the stored one; "" means unchanged
+func resolve(exists, differs bool, resolution string) string {
+	switch {
+	case !exists:
+		return ImportCreate
+	case !differs:
+		return ""
+	case resolution == ConflictOverwrite:
+		return ImportUpdate
+	default:
+		return ImportSkip
+	}
+}
+
+func planCannedAnswers(tx *gorm.DB, plan *importPlan, bundle models.ConfigBundle, resolution string, prune bool) error {
+	var rows []models.CannedAnswer
+	if err := tx.Order("id ASC").Find(&rows).Error; err != nil {
+		return fmt.Errorf("failed to get canned answers: %w", err)
+	}
+	existing := map[string]models.CannedAnswer{}
+	var duplicates []models.CannedAnswer
+	for _, row := range rows {
+		key := cannedAnswerKey(cannedAnswerEntry(row))
+		if _, ok := existing[key]; ok {
+			duplicates = append(duplicates, row)
+			continue
+		}
+		existing[key] = row
+	}
+
+	incoming := make([]models.CannedAnswerRequest, 0, len(bundle.CannedAnswers))
+	for _, entry := range bundle.CannedAnswers {
+		incoming = append(incoming, normalizeCannedAnswerEntry(entry))
+	}
+	sort.SliceStable(incoming, func(i, j int) bool { return cannedAnswerKey(incoming[i]) < cannedAnswerKey(incoming[j]) })
+
+	inBundle := map[string]bool{}
+	for _, entry := range incoming {
+		entry := entry
+		key := cannedAnswerKey(entry)
+		inBundle[key] = true
+
+		row, exists := existing[key]
+		before := cannedAnswerEntry(row)
+		action := resolve(exists, exists && !sameEntry(before, entry), resolution)
+
+		var apply func(context.Context, *gorm.DB) error
+		switch action {
+		case "":
+			plan.unchanged++
+			continue
+		case ImportCreate:
+			apply = func(ctx context.Context, tx *gorm.DB) error {
+				answer := models.CannedAnswer{Enabled: true}
+				applyCannedAnswerRequest(&answer, entry)
+				if err := createWithFlag(tx, &answer, "enabled", answer.Enabled); err != nil {
+					return fmt.Errorf("failed to save canned answer: %w", err)
+				}
+				return audit.Record(ctx, tx, "canned_answer.create", "canned_answer", strconv.FormatUint(uint64(answer.ID), 10), nil, answer)
+			}
+		case ImportUpdate:
+			apply = func(ctx context.Context, tx *gorm.DB) error {
+				answer := row
+				applyCannedAnswerRequest(&answer, entry)
+				if err := tx.Save(&answer).Error; err != nil {
+					return fmt.Errorf("failed to update canned answer: %w", err)
+				}
+				return audit.Record(ctx, tx, "canned_answer.update", "canned_answer", strconv.FormatUint(uint64(row.ID), 10), row, answer)
+			}
+		}
+		if err := plan.add(BundleCannedAnswers, key, action, before, entry, apply); err != nil {
+			return err
+		}
+	}
+
+	if !prune {
+		return nil
+	}
+	var stale []models.CannedAnswer
+	for key, row := range existing {
+		if !inBundle[key] {
+			stale = append(stale, row)
+		}
+	}
+	stale = append(stale, duplicates...)
+	sort.Slice(stale, func(i, j int) bool { return stale[i].ID < stale[j].ID })
+	for _, row := range stale {
+		row := row
+		err := plan.add(BundleCannedAnswers, cannedAnswerKey(cannedAnswerEntry(row)), ImportDelete, nil, nil, func(ctx context.Context, tx *gorm.DB) error {
+			if err := tx.Delete(&models.CannedAnswer{}, row.ID).Error; err != nil {
+				return fmt.Errorf("failed to delete canned answer: %w", err)
+			}
+			return audit.Record(ctx, tx, "canned_answer.delete", "canned_answer", strconv.FormatUint(uint64(row.ID), 10), row, nil)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func planPromptTemplates(tx *gorm.DB, plan *importPlan, bundle models.ConfigBundle, resolution string, prune bool) error {
+	var rows []models.PromptTemplate
+	if err := tx.Order("name ASC, version ASC").Find(&rows).Error; err != nil {
+		return fmt.Errorf("failed to get prompt templates: %w", err)
+	}
+	existing := map[string]models.PromptTemplate{}
+	for _, row := range rows {
+		existing[promptTemplateKey(row.Name, row.Version)] = row
+	}
+
+	incoming := append([]models.BundlePromptTemplate(nil), bundle.PromptTemplates...)
+	sort.SliceStable(incoming, func(i, j int) bool {
+		return promptTemplateKey(incoming[i].Name, incoming[i].Version) < promptTemplateKey(incoming[j].Name, incoming[j].Version)
+	})
+
+	// An applied activation deactivates every other template
+	activating := ""
+	inBundle := map[string]bool{}
+	for _, entry := range incoming {
+		entry := entry
+		key := promptTemplateKey(entry.Name, entry.Version)
+		inBundle[key] = true
+
+		row, exists := existing[key]
+		before := promptTemplateEntry(row)
+		action := resolve(exists, exists && !sameEntry(before, entry), resolution)
+
+		var apply func(context.Context, *gorm.DB) error
+		switch action {
+		case "":
+			plan.unchanged++
+			continue
+		case ImportCreate:
+			apply = func(ctx context.Context, tx *gorm.DB) error {
+				template := models.PromptTemplate{Name: entry.Name, Version: entry.Version, Body: entry.Body, Active: entry.Active}
+				if err := tx.Create(&template).Error; err != nil {
+					return fmt.Errorf("failed to save prompt template: %w", err)
+				}
+				return audit.Record(ctx, tx, "prompt_template.create", "prompt_template", strconv.FormatUint(uint64(template.ID), 10), nil, template)
+			}
+		case ImportUpdate:
+			apply = func(ctx context.Context, tx *gorm.DB) error {
+				template := row
+				template.Body = entry.Body
+				template.Active = entry.Active
+				if err := tx.Save(&template).Error; err != nil {
+					return fmt.Errorf("failed to update prompt template: %w", err)
+				}
+				return audit.Record(ctx, tx, "prompt_template.update", "prompt_template", strconv.FormatUint(uint64(row.ID), 10), row, template)
+			}
+		}
+		if entry.Active && action != ImportSkip {
+			activating = key
+		}
+		if err := plan.add(BundlePromptTemplates, key, action, before, entry, apply); err != nil {
+			return err
+		}
+	}
+
+	for _, row := range rows {
+		row := row
+		key := promptTemplateKey(row.Name, row.Version)
+		switch {
+		case prune && !inBundle[key]:
+			err := plan.add(BundlePromptTemplates, key, ImportDelete, nil, nil, func(ctx context.Context, tx *gorm.DB) error {
+				if err := tx.Delete(&models.PromptTemplate{}, row.ID).Error; err != nil {
+					return fmt.Errorf("failed to delete prompt template: %w", err)
+				}
+				return audit.Record(ctx, tx, "prompt_template.delete", "prompt_template", strconv.FormatUint(uint64(row.ID), 10), row, nil)
+			})
+			if err != nil {
+				return err
+			}
+		case row.Active && activating != "" && !inBundle[key]:
+			deactivated := promptTemplateEntry(row)
+			deactivated.Active = false
+			err := plan.add(BundlePromptTemplates, key, ImportUpdate, promptTemplateEntry(row), deactivated, func(ctx context.Context, tx *gorm.DB) error {
+				if err := tx.Model(&models.PromptTemplate{}).Where("id = ?", row.ID).Update("active", false).Error; err != nil {
+					return fmt.Errorf("failed to deactivate prompt template: %w", err)
+				}
+				after := row
+				after.Active = false
+				return audit.Record(ctx, tx, "prompt_template.update", "prompt_template", strconv.FormatUint(uint64(row.ID), 10), row, after)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func planWidgetConfigs(tx *gorm.DB, plan *importPlan, bundle models.ConfigBundle, resolution string, prune bool) error {
+	var rows []models.WidgetConfig
+	if err := tx.Order("origin ASC").Find(&rows).Error; err != nil {
+		return fmt.Errorf("failed to get widget configs: %w", err)
+	}
+	existing := map[string]models.WidgetConfig{}
+	for _, row := range rows {
+		existing[row.Origin] = row
+	}
+
+	incoming := make([]models.WidgetConfigRequest, 0, len(bundle.WidgetConfigs))
+	for _, entry := range bundle.WidgetConfigs {
+		config := models.WidgetConfig{Enabled: true, RateLimitTier: "standard"}
+		applyWidgetConfigRequest(&config, entry)
+		incoming = append(incoming, widgetConfigEntry(config))
+	}
+	sort.SliceStable(incoming, func(i, j int) bool { return incoming[i].Origin < incoming[j].Origin })
+
+	inBundle := map[string]bool{}
+	for _, entry := range incoming {
+		entry := entry
+		inBundle[entry.Origin] = true
+
+		row, exists := existing[entry.Origin]
+		before := widgetConfigEntry(row)
+		action := resolve(exists, exists && !sameEntry(before, entry), resolution)
+
+		var apply func(context.Context, *gorm.DB) error
+		switch action {
+		case "":
+			plan.unchanged++
+			continue
+		case ImportCreate:
+			apply = func(ctx context.Context, tx *gorm.DB) error {
+				config := models.WidgetConfig{Enabled: true, RateLimitTier: "standard"}
+				applyWidgetConfigRequest(&config, entry)
+				if err := createWithFlag(tx, &config, "enabled", config.Enabled); err != nil {
+					return fmt.Errorf("failed to save widget config: %w", err)
+				}
+				return audit.Record(ctx, tx, "widget_config.create", "widget_config", strconv.FormatUint(uint64(config.ID), 10), nil, config)
+			}
+		case ImportUpdate:
+			apply = func(ctx context.Context, tx *gorm.DB) error {
+				config := row
+				applyWidgetConfigRequest(&config, entry)
+				if err := tx.Save(&config).Error; err != nil {
+					return fmt.Errorf("failed to update widget config: %w", err)
+				}
+				return audit.Record(ctx, tx, "widget_config.update", "widget_config", strconv.FormatUint(uint64(row.ID), 10), row, config)
+			}
+		}
+		if err := plan.add(BundleWidgetConfigs, entry.Origin, action, before, entry, apply); err != nil {
+			return err
+		}
+	}
+
+	if !prune {
+		return nil
+	}
+	for _, row := range rows {
+		row := row
+		if inBundle[row.Origin] {
+			continue
+		}
+		err := plan.add(BundleWidgetConfigs, row.Origin, ImportDelete, nil, nil, func(ctx context.Context, tx *gorm.DB) error {
+			if err := tx.Delete(&models.WidgetConfig{}, row.ID).Error; err != nil {
+				return fmt.Errorf("failed to delete widget config: %w", err)
+			}
+			return audit.Record(ctx, tx, "widget_config.delete", "widget_config", strconv.FormatUint(uint64(row.ID), 10), row, nil)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func planSettings(tx *gorm.DB, plan *importPlan, bundle models.ConfigBundle, resolution string, prune bool) error {
+	var rows []models.Setting
+	if err := tx.Find(&rows).Error; err != nil {
+		return fmt.Errorf("failed to get settings: %w", err)
+	}
+	existing := map[string]string{}
+	for _, row := range rows {
+		existing[row.Key] = row.Value
+	}
+
+	keys := make([]string, 0, len(bundle.Settings))
+	for key := range bundle.Settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		key := key
+		value := strings.TrimSpace(bundle.Settings[key])
+		current, exists := existing[key]
+		before := map[string]string{"value": current}
+		after := map[string]string{"value": value}
+
+		action := resolve(exists, exists && current != value, resolution)
+		if action == "" {
+			plan.unchanged++
+			continue
+		}
+		var apply func(context.Context, *gorm.DB) error
+		if action != ImportSkip {
+			apply = func(ctx context.Context, tx *gorm.DB) error {
+				err := tx.Clauses(clause.OnConflict{
+					Columns:   []clause.Column{{Name: "key"}},
+					DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+				}).Create(&models.Setting{Key: key, Value: value}).Error
+				if err != nil {
+					return fmt.Errorf("failed to save setting: %w", err)
+				}
+				return audit.Record(ctx, tx, "setting.update", "setting", key, before, after)
+			}
+		}
+		if err := plan.add(BundleSettings, key, action, before, after, apply); err != nil {
+			return err
+		}
+	}
+
+	if !prune {
+		return nil
+	}
+	stale := make([]string, 0, len(existing))
+	for key := range existing {
+		if _, ok := bundle.Settings[key]; !ok {
+			stale = append(stale, key)
+		}
+	}
+	sort.Strings(stale)
+	for _, key := range stale {
+		key := key
+		before := map[string]string{"value": existing[key]}
+		err := plan.add(BundleSettings, key, ImportDelete, nil, nil, func(ctx context.Context, tx *gorm.DB) error {
+			if err := tx.Delete(&models.Setting{}, "key = ?", key).Error; err != nil {
+				return fmt.Errorf("failed to delete setting: %w", err)
+			}
+			return audit.Record(ctx, tx, "setting.delete", "setting", key, before, nil)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createWithFlag creates a record and then stores a boolean column whose
+// default is true, since GORM leaves false (the zero value) out of inserts
+func createWithFlag(tx *gorm.DB, record interface{}, column string, value bool) error {
+	if err := tx.Create(record).Error; err != nil {
+		return err
+	}
+	if value {
+		return nil
+	}
+	return tx.Model(record).Update(column, false).Error
+}
+
+// sameEntry compares two bundle entries by their JSON fields
+func sameEntry(a, b interface{}) bool {
+	diff, err := audit.Diff(a, b)
+	return err == nil && len(diff) == 0
+}
+
+// cannedAnswerEntry converts a stored canned answer into its bundle form
+func cannedAnswerEntry(answer models.CannedAnswer) models.CannedAnswerRequest {
+	enabled := answer.Enabled
+	return models.CannedAnswerRequest{
+		Pattern:   answer.Pattern,
+		MatchType: answer.MatchType,
+		Answer:    answer.Answer,
+		Enabled:   &enabled,
+		Priority:  answer.Priority,
+	}
+}
+
+// normalizeCannedAnswerEntry stores a bundle entry's fields the way a
+// created canned answer would, so it compares equal to its stored form
+func normalizeCannedAnswerEntry(entry models.CannedAnswerRequest) models.CannedAnswerRequest {
+	answer := models.CannedAnswer{Enabled: true}
+	applyCannedAnswerRequest(&answer, entry)
+	return cannedAnswerEntry(answer)
+}
+
+// cannedAnswerKey identifies a canned answer across environments
+func cannedAnswerKey(entry models.CannedAnswerRequest) string {
+	return matchTypeOrDefault(entry.MatchType) + ":" + entry.Pattern
+}
+
+// promptTemplateEntry converts a stored prompt template into its bundle form
+func promptTemplateEntry(template models.PromptTemplate) models.BundlePromptTemplate {
+	return models.BundlePromptTemplate{
+		PromptTemplateRequest: models.PromptTemplateRequest{
+			Name:    template.Name,
+			Version: template.Version,
+			Body:    template.Body,
+		},
+		Active: template.Active,
+	}
+}
+
+// promptTemplateKey identifies a prompt template across environments
+func promptTemplateKey(name string, version int) string {
+	return fmt.Sprintf("%s:%d", name, version)
+}
+
+// widgetConfigEntry converts a stored widget config into its bundle form
+func widgetConfigEntry(config models.WidgetConfig) models.WidgetConfigRequest {
+	enabled := config.Enabled
+	entry := models.WidgetConfigRequest{
+		Origin:             config.Origin,
+		WelcomeMessage:     config.WelcomeMessage,
+		ThemeColor:         config.ThemeColor,
+		SuggestedQuestions: []string{},
+		RateLimitTier:      config.RateLimitTier,
+		Enabled:            &enabled,
+	}
+	if config.SuggestedQuestions != "" {
+		if err := json.Unmarshal([]byte(config.SuggestedQuestions), &entry.SuggestedQuestions); err != nil {
+			logrus.WithError(err).WithField("widget_config_id", config.ID).Warn("Invalid suggested questions in widget config")
+		}
+	}
+	return entry
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/models"
+)
+
+// testConfigBundle exercises every resource type, including the fields
+// whose zero values have non-zero column defaults
+func testConfigBundle() models.ConfigBundle {
+	enabled, disabled := true, false
+	return models.ConfigBundle{
+		Version: ConfigBundleVersion,
+		CannedAnswers: []models.CannedAnswerRequest{
+			{Pattern: "What are your opening hours?", Answer: "9 to 5, Monday to Friday.", Priority: 2},
+			{Pattern: "refund, vat", MatchType: MatchKeyword, Answer: "See the VAT refund guide.", Enabled: &disabled},
+		},
+		PromptTemplates: []models.BundlePromptTemplate{
+			{PromptTemplateRequest: models.PromptTemplateRequest{Name: "support", Version: 1, Body: "Answer briefly."}},
+			{PromptTemplateRequest: models.PromptTemplateRequest{Name: "support", Version: 2, Body: "Answer briefly and cite sources."}, Active: true},
+		},
+		WidgetConfigs: []models.WidgetConfigRequest{
+			{Origin: "https://shop.example.com", WelcomeMessage: "Hi!", ThemeColor: "#0055ff", SuggestedQuestions: []string{"Where is my order?"}, RateLimitTier: "elevated", Enabled: &enabled},
+			{Origin: DefaultWidgetOrigin, Enabled: &disabled},
+		},
+		Settings: map[string]string{
+			SettingModerationMode:        "log-only",
+			SettingCacheTTL:              "30m",
+			SettingFeedbackSamplePercent: "25",
+		},
+	}
+}
+
+// useFreshConfigDB starts an empty in-memory database and returns a bundle
+// service over it
+func useFreshConfigDB(t *testing.T) (*ConfigBundleService, *memoryTables) {
+	t.Helper()
+	tables := newMemoryTables()
+	useFakeDB(t, tables.answer)
+	return NewConfigBundleService(NewCannedAnswerService(), NewPromptService(), NewWidgetService(), NewSettingsService(&config.Config{})), tables
+}
+
+// exportWithoutTimestamp exports the bundle, clearing ExportedAt so exports
+// can be compared
+func exportWithoutTimestamp(t *testing.T, s *ConfigBundleService) models.ConfigBundle {
+	t.Helper()
+	bundle, err := s.Export(context.Background())
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	bundle.ExportedAt = time.Time{}
+	return *bundle
+}
+
+func TestConfigBundleRoundTrip(t *testing.T) {
+	ctx := context.Background()
+
+	source, _ := useFreshConfigDB(t)
+	if _, err := source.Import(ctx, models.ConfigImportRequest{Bundle: testConfigBundle()}, false); err != nil {
+		t.Fatalf("Import into source: %v", err)
+	}
+	exported := exportWithoutTimestamp(t, source)
+
+	if len(exported.CannedAnswers) != 2 || len(exported.PromptTemplates) != 2 || len(exported.WidgetConfigs) != 2 || len(exported.Settings) != 3 {
+		t.Fatalf("export = %+v, want every imported resource", exported)
+	}
+	if *exported.CannedAnswers[1].Enabled || exported.CannedAnswers[0].Pattern != "what are your opening hours" {
+		t.Errorf("canned answers = %+v, want the disabled flag kept and exact patterns normalized", exported.CannedAnswers)
+	}
+
+	// Export, import into a fresh database, export again
+	target, tables := useFreshConfigDB(t)
+	result, err := target.Import(ctx, models.ConfigImportRequest{Bundle: exported}, false)
+	if err != nil {
+		t.Fatalf("Import into target: %v", err)
+	}
+	if result.Created != 9 || result.Updated+result.Skipped+result.Deleted+result.Unchanged != 0 {
+		t.Errorf("result = %+v, want 9 creates", result)
+	}
+	if again := exportWithoutTimestamp(t, target); !reflect.DeepEqual(again, exported) {
+		t.Errorf("round trip changed the bundle:\n got  %+v\n want %+v", again, exported)
+	}
+
+	// One audit entry per change plus the import itself
+	if audited := len(tables.rows("audit_logs")); audited != 10 {
+		t.Errorf("audit entries = %d, want 10", audited)
+	}
+
+	// Importing the same bundle again changes nothing
+	result, err = target.Import(ctx, models.ConfigImportRequest{Bundle: exported}, false)
+	if err != nil {
+		t.Fatalf("second Import: %v", err)
+	}
+	if result.Unchanged != 9 || len(result.Changes) != 0 {
+		t.Errorf("second import = %+v, want everything unchanged", result)
+	}
+}
+
+func TestConfigBundleDryRun(t *testing.T) {
+	s, tables := useFreshConfigDB(t)
+
+	result, err := s.Import(context.Background(), models.ConfigImportRequest{Bundle: testConfigBundle()}, true)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if !result.DryRun || result.Created != 9 {
+		t.Errorf("result = %+v, want 9 creates planned", result)
+	}
+	for _, table := range []string{"canned_answers", "prompt_templates", "widget_configs", "settings", "audit_logs"} {
+		if rows := tables.rows(table); len(rows) != 0 {
+			t.Errorf("dry run wrote %d rows to %s", len(rows), table)
+		}
+	}
+}
+
+func TestConfigBundleConflicts(t *testing.T) {
+	ctx := context.Background()
+	s, _ := useFreshConfigDB(t)
+	if _, err := s.Import(ctx, models.ConfigImportRequest{Bundle: testConfigBundle()}, false); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	changed := testConfigBundle()
+	changed.CannedAnswers[0].Answer = "8 to 6 now."
+	changed.Settings[SettingModerationMode] = "enforce"
+
+	result, err := s.Import(ctx, models.ConfigImportRequest{Bundle: changed}, false)
+	if err != nil {
+		t.Fatalf("Import with skip: %v", err)
+	}
+	if result.Skipped != 2 || result.Updated != 0 {
+		t.Errorf("skip result = %+v, want both conflicts skipped", result)
+	}
+	if got := exportWithoutTimestamp(t, s); got.CannedAnswers[0].Answer != "9 to 5, Monday to Friday." || got.Settings[SettingModerationMode] != "log-only" {
+		t.Errorf("skipped conflicts were applied: %q, %q", got.CannedAnswers[0].Answer, got.Settings[SettingModerationMode])
+	}
+
+	result, err = s.Import(ctx, models.ConfigImportRequest{Bundle: changed, Conflicts: map[string]string{
+		BundleCannedAnswers: ConflictOverwrite,
+		BundleSettings:      ConflictOverwrite,
+	}}, false)
+	if err != nil {
+		t.Fatalf("Import with overwrite: %v", err)
+	}
+	if result.Updated != 2 {
+		t.Errorf("overwrite result = %+v, want both conflicts updated", result)
+	}
+	got := exportWithoutTimestamp(t, s)
+	if got.CannedAnswers[0].Answer != "8 to 6 now." || got.Settings[SettingModerationMode] != "enforce" {
+		t.Errorf("overwrite not applied: %+v", got)
+	}
+}
+
+func TestConfigBundleValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*models.ConfigImportRequest)
+	}{
+		{"unsupported version", func(r *models.ConfigImportRequest) { r.Bundle.Version = ConfigBundleVersion + 1 }},
+		{"unknown conflict type", func(r *models.ConfigImportRequest) { r.Conflicts = map[string]string{"personas": ConflictSkip} }},
+		{"invalid resolution", func(r *models.ConfigImportRequest) { r.Conflicts = map[string]string{BundleSettings: "merge"} }},
+		{"duplicate canned answer", func(r *models.ConfigImportRequest) {
+			r.Bundle.CannedAnswers = append(r.Bundle.CannedAnswers, models.CannedAnswerRequest{Pattern: "what are your  OPENING hours", Answer: "x"})
+		}},
+		{"two active templates", func(r *models.ConfigImportRequest) { r.Bundle.PromptTemplates[0].Active = true }},
+		{"invalid setting", func(r *models.ConfigImportRequest) { r.Bundle.Settings[SettingModerationMode] = "sometimes" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, tables := useFreshConfigDB(t)
+			req := models.ConfigImportRequest{Bundle: testConfigBundle()}
+			tt.modify(&req)
+
+			if _, err := s.Import(context.Background(), req, false); !errors.Is(err, ErrValidation) {
+				t.Errorf("error = %v, want ErrValidation", err)
+			}
+			if rows := tables.rows("canned_answers"); len(rows) != 0 {
+				t.Error("an invalid bundle was applied")
+			}
+		})
+	}
+}
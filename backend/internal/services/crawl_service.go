@@ -0,0 +1,414 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/crawler"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/lifecycle"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/netguard"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// sitemapURLLimit bounds how many entries are read from a sitemap before
+// patterns and the page cap are applied
+const sitemapURLLimit = 50000
+
+// CrawlService ingests web pages as documents, either a single URL or every
+// page listed in a sitemap
+type CrawlService struct {
+	cfg       *config.Config
+	documents *DocumentService
+	lifecycle *lifecycle.Manager
+	guard     *netguard.Guard
+}
+
+func NewCrawlService(cfg *config.Config, documents *DocumentService, lc *lifecycle.Manager) *CrawlService {
+	return &CrawlService{cfg: cfg, documents: documents, lifecycle: lc, guard: outboundGuard(cfg)}
+}
+
+// crawlFilter holds the compiled include and exclude patterns of a crawl
+type crawlFilter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// matches reports whether a URL path passes the include and exclude patterns
+func (f crawlFilter) matches(path string) bool {
+	for _, pattern := range f.exclude {
+		if pattern.MatchString(path) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, pattern := range f.include {
+		if pattern.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// StartCrawl validates a crawl request, records the job and starts crawling
+// in the background. Include and exclude patterns only apply to sitemap entries.
+func (s *CrawlService) StartCrawl(ctx context.Context, req models.CrawlRequest, createdBy string) (*models.CrawlJob, error) {
+	if s.lifecycle.Stopping() {
+		return nil, fmt.Errorf("%w: server is shutting down", ErrOverloaded)
+	}
+
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidRequest)
+	}
+	// Every page and redirect is checked again when connecting
+	if err := checkDestination(ctx, s.guard, "url", req.URL); err != nil {
+		return nil, err
+	}
+
+	include, err := compileCrawlPatterns("include", req.IncludePatterns)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := compileCrawlPatterns("exclude", req.ExcludePatterns)
+	if err != nil {
+		return nil, err
+	}
+
+	maxPages := req.MaxPages
+	if maxPages == 0 {
+		maxPages = s.cfg.CrawlMaxPages
+	}
+	if maxPages > s.cfg.CrawlMaxPages {
+		return nil, validationError("max_pages cannot exceed %d", s.cfg.CrawlMaxPages)
+	}
+
+	sitemap := crawler.IsSitemapURL(req.URL)
+	if req.Sitemap != nil {
+		sitemap = *req.Sitemap
+	}
+
+	job := &models.CrawlJob{
+		URL:             u.String(),
+		Sitemap:         sitemap,
+		IncludePatterns: req.IncludePatterns,
+		ExcludePatterns: req.ExcludePatterns,
+		MaxPages:        maxPages,
+		Status:          JobPending,
+		CreatedBy:       createdBy,
+	}
+
+	var collection *models.Collection
+	if req.Collection != "" {
+		collection, err = resolveCollection(req.Collection)
+		if err != nil {
+			return nil, err
+		}
+		job.Collection = collection.Name
+	}
+
+	if err := db.DB.Create(job).Error; err != nil {
+		return nil, fmt.Errorf("failed to create crawl job: %w", err)
+	}
+
+	filter := crawlFilter{include: include, exclude: exclude}
+	started := s.lifecycle.Go("crawl", logrus.Fields{
+		"crawl_job_id": job.ID,
+		"url":          job.URL,
+	}, func(ctx context.Context) {
+		s.run(ctx, *job, filter, collection)
+	})
+	if !started {
+		s.finish(job.ID, JobFailed, "server shutting down")
+		return nil, fmt.Errorf("%w: server is shutting down", ErrOverloaded)
+	}
+
+	return job, nil
+}
+
+// GetCrawlJob returns a crawl job with its progress counters
+func (s *CrawlService) GetCrawlJob(ctx context.Context, id uint) (*models.CrawlJob, error) {
+	var job models.CrawlJob
+	if err := db.DB.First(&job, id).Error; err != nil {
+		return nil, notFoundError("crawl job", err)
+	}
+
+	return &job, nil
+}
+
+// run crawls the job's pages with a bounded worker pool, always leaving the
+// job completed or failed. At shutdown, pages not yet started are dropped.
+func (s *CrawlService) run(ctx context.Context, job models.CrawlJob, filter crawlFilter, collection *models.Collection) {
+	defer func() {
+		if r := recover(); r != nil {
+			logrus.WithField("crawl_job_id", job.ID).Errorf("Crawl panicked: %v", r)
+			s.finish(job.ID, JobFailed, fmt.Sprintf("internal error: %v", r))
+		}
+	}()
+
+	db.DB.Model(&models.CrawlJob{}).Where("id = ?", job.ID).Update("status", JobRunning)
+
+	fetcher := crawler.NewFetcher(s.cfg.CrawlUserAgent, time.Duration(s.cfg.CrawlTimeoutS)*time.Second, s.cfg.CrawlMaxPageBytes, s.guard.Transport())
+
+	urls := []string{job.URL}
+	if job.Sitemap {
+		var err error
+		urls, err = s.sitemapURLs(ctx, fetcher, job, filter)
+		if err != nil {
+			logrus.WithError(err).WithField("crawl_job_id", job.ID).Warn("Failed to read sitemap")
+			s.finish(job.ID, JobFailed, err.Error())
+			return
+		}
+	}
+
+	db.DB.Model(&models.CrawlJob{}).Where("id = ?", job.ID).Update("total", len(urls))
+
+	workers := s.cfg.CrawlConcurrency
+	if workers <= 0 {
+		workers = 1
+	}
+	delay := time.Duration(s.cfg.CrawlDelayMs) * time.Millisecond
+	stop := s.lifecycle.Context().Done()
+
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pageURL := range queue {
+				s.count(job.ID, s.crawlPage(ctx, fetcher, job, pageURL, collection))
+
+				select {
+				case <-stop:
+				case <-time.After(delay):
+				}
+			}
+		}()
+	}
+
+	interrupted := false
+feed:
+	for _, pageURL := range urls {
+		select {
+		case <-stop:
+			interrupted = true
+			break feed
+		case queue <- pageURL:
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if interrupted {
+		logrus.WithField("crawl_job_id", job.ID).Warn("Crawl interrupted by shutdown")
+		s.finish(job.ID, JobFailed, "server shutting down")
+		return
+	}
+
+	s.finish(job.ID, JobCompleted, "")
+	logrus.WithFields(logrus.Fields{
+		"crawl_job_id": job.ID,
+		"pages":        len(urls),
+	}).Info("Crawl completed")
+}
+
+// sitemapURLs lists the sitemap's pages that pass the filter, deduplicated
+// and capped at the job's page limit
+func (s *CrawlService) sitemapURLs(ctx context.Context, fetcher *crawler.Fetcher, job models.CrawlJob, filter crawlFilter) ([]string, error) {
+	entries, err := fetcher.Sitemap(ctx, job.URL, sitemapURLLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var urls []string
+	for _, entry := range entries {
+		u, err := url.Parse(entry)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		u.Fragment = ""
+
+		key := u.String()
+		if seen[key] || !filter.matches(u.Path) {
+			continue
+		}
+		seen[key] = true
+
+		urls = append(urls, key)
+		if len(urls) >= job.MaxPages {
+			break
+		}
+	}
+
+	return urls, nil
+}
+
+// crawlPage fetches one page and ingests its text, returning the counter
+// column it should be recorded under
+func (s *CrawlService) crawlPage(ctx context.Context, fetcher *crawler.Fetcher, job models.CrawlJob, pageURL string, collection *models.Collection) string {
+	page, err := fetcher.Fetch(ctx, pageURL)
+	switch {
+	case errors.Is(err, crawler.ErrDisallowed), errors.Is(err, crawler.ErrUnsupportedContent):
+		logrus.WithError(err).WithField("url", pageURL).Debug("Skipped page")
+		return "skipped"
+	case err != nil:
+		logrus.WithError(err).WithField("url", pageURL).Warn("Failed to fetch page")
+		return "failed"
+	case page.Text == "":
+		return "skipped"
+	}
+
+	doc, previousVectorStoreID, err := s.upsertPageDocument(job, pageURL, page, collection)
+	if err != nil {
+		logrus.WithError(err).WithField("url", pageURL).Error("Failed to save crawled document")
+		return "failed"
+	}
+
+	// replaces lets the RAG service drop the chunks of the superseded version
+	fields := map[string]string{"source_url": pageURL}
+	if collection != nil {
+		fields["collection"] = collection.Name
+	}
+	if previousVectorStoreID != "" {
+		fields["replaces"] = previousVectorStoreID
+	}
+	addIngestOptionFields(fields, doc.IngestOptions)
+
+	if err := s.documents.ingestText(ctx, doc.ID, crawlFileName(pageURL), job.CreatedBy, page.Text, fields); err != nil {
+		return "failed"
+	}
+	return "fetched"
+}
+
+// upsertPageDocument creates the document for a crawled page, or resets the
+// existing document for the same URL so a re-crawl supersedes it. It returns
+// the vector store ID of the superseded version, if any.
+func (s *CrawlService) upsertPageDocument(job models.CrawlJob, pageURL string, page *crawler.Page, collection *models.Collection) (*models.Document, string, error) {
+	fileName := page.Title
+	if fileName == "" {
+		fileName = pageURL
+	}
+	if len(fileName) > 500 {
+		fileName = fileName[:500]
+	}
+
+	var collectionID *uint
+	if collection != nil {
+		collectionID = &collection.ID
+	}
+
+	var doc models.Document
+	err := db.DB.Where("source_url = ?", pageURL).Order("id DESC").First(&doc).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		doc = models.Document{
+			FileName:     fileName,
+			FileType:     "text/html",
+			FileSize:     int64(len(page.Text)),
+			Status:       "processing",
+			UploadedBy:   job.CreatedBy,
+			CollectionID: collectionID,
+			SourceURL:    pageURL,
+			CrawlJobID:   &job.ID,
+
+			IngestOptions: defaultIngestOptions(s.cfg),
+		}
+		if err := db.DB.Create(&doc).Error; err != nil {
+			return nil, "", err
+		}
+		return &doc, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	previousVectorStoreID := doc.VectorStoreID
+	err = db.DB.Model(&doc).Updates(map[string]interface{}{
+		"file_name":        fileName,
+		"file_size":        int64(len(page.Text)),
+		"status":           "processing",
+		"chunk_count":      0,
+		"vector_store_id":  "",
+		"uploaded_by":      job.CreatedBy,
+		"collection_id":    collectionID,
+		"crawl_job_id":     job.ID,
+		"error_code":       "",
+		"error_message":    "",
+		"progress_percent": nil,
+		"chunks_processed": 0,
+		"chunks_total":     0,
+	}).Error
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &doc, previousVectorStoreID, nil
+}
+
+// count increments one of the job's page counters
+func (s *CrawlService) count(jobID uint, column string) {
+	err := db.DB.Model(&models.CrawlJob{}).Where("id = ?", jobID).
+		UpdateColumn(column, gorm.Expr(column+" + 1")).Error
+	if err != nil {
+		logrus.WithError(err).WithField("crawl_job_id", jobID).Warn("Failed to update crawl progress")
+	}
+}
+
+// finish records the final state of a crawl job
+func (s *CrawlService) finish(id uint, status, errMessage string) {
+	now := time.Now().UTC()
+	err := db.DB.Model(&models.CrawlJob{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":       status,
+		"error":        errMessage,
+		"completed_at": &now,
+	}).Error
+	if err != nil {
+		logrus.WithError(err).WithField("crawl_job_id", id).Error("Failed to update crawl job")
+	}
+}
+
+// compileCrawlPatterns compiles crawl path patterns, reporting the first invalid one
+func compileCrawlPatterns(kind string, patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid %s pattern %q: %v", ErrInvalidRequest, kind, pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// crawlFileNameUnsafe matches characters replaced in crawled page file names
+var crawlFileNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// crawlFileName derives a plain-text file name for a page so the RAG service
+// ingests the extracted text rather than trying to parse HTML
+func crawlFileName(pageURL string) string {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return "page.txt"
+	}
+
+	name := strings.Trim(crawlFileNameUnsafe.ReplaceAllString(u.Host+u.Path, "_"), "_")
+	if len(name) > 200 {
+		name = name[:200]
+	}
+	if name == "" {
+		name = "page"
+	}
+	return name + ".txt"
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/cache"
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	dashboardCacheKey       = "dashboard:summary"
+	dashboardSectionTimeout = 10 * time.Second
+	dashboardTrendDays      = 7
+	dashboardFailedDocs     = 5
+)
+
+// dashboardRateLimits lists the rate limit policies shown on the dashboard
+var dashboardRateLimits = []string{"default", "query", "upload", "read"}
+
+// DashboardService assembles the admin dashboard summary from the other services
+type DashboardService struct {
+	cfg       *config.Config
+	analytics *AnalyticsService
+	feedback  *FeedbackService
+	documents *DocumentService
+	settings  *SettingsService
+	health    *HealthService
+}
+
+func NewDashboardService(cfg *config.Config, analytics *AnalyticsService, feedback *FeedbackService, documents *DocumentService, settings *SettingsService, health *HealthService) *DashboardService {
+	return &DashboardService{
+		cfg:       cfg,
+		analytics: analytics,
+		feedback:  feedback,
+		documents: documents,
+		settings:  settings,
+		health:    health,
+	}
+}
+
+// GetSummary returns the dashboard summary, served from a short-lived cache when possible.
+// Sections are gathered concurrently and a failing section doesn't fail the summary.
+func (s *DashboardService) GetSummary(ctx context.Context) (*models.DashboardSummary, error) {
+	var cached models.DashboardSummary
+	err := cache.Get(ctx, dashboardCacheKey, &cached)
+	if err == nil {
+		return &cached, nil
+	} else if err != redis.Nil && cache.Client != nil {
+		logrus.WithError(err).Warn("Failed to get dashboard summary from cache")
+	}
+
+	summary := &models.DashboardSummary{
+		GeneratedAt: time.Now().UTC(),
+		Errors:      make(map[string]string),
+	}
+
+	var mu sync.Mutex
+	var g errgroup.Group
+
+	// section runs fn with its own timeout, recording any error or panic against name
+	section := func(name string, fn func(ctx context.Context) error) {
+		g.Go(func() (err error) {
+			sectionCtx, cancel := context.WithTimeout(ctx, dashboardSectionTimeout)
+			defer cancel()
+
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("internal error: %v", r)
+				}
+				if err != nil {
+					logrus.WithError(err).WithField("section", name).Warn("Dashboard section failed")
+					mu.Lock()
+					summary.Errors[name] = err.Error()
+					mu.Unlock()
+				}
+			}()
+
+			return fn(sectionCtx)
+		})
+	}
+
+	section("analytics", func(ctx context.Context) error {
+		analytics, err := s.analytics.GetAnalytics(ctx, nil)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		summary.Analytics = analytics
+		mu.Unlock()
+		return nil
+	})
+
+	section("feedback_stats", func(ctx context.Context) error {
+		stats, err := s.feedback.GetFeedbackStats(ctx, true, nil)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		summary.FeedbackStats = stats
+		mu.Unlock()
+		return nil
+	})
+
+	section("trends", func(ctx context.Context) error {
+		trends, err := s.analytics.GetQueryTrends(ctx, dashboardTrendDays, GranularityDay, time.UTC, nil)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		summary.Trends = trends
+		mu.Unlock()
+		return nil
+	})
+
+	section("failed_documents", func(ctx context.Context) error {
+		documents, err := s.documents.GetFailedDocuments(ctx, dashboardFailedDocs)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		summary.FailedDocuments = documents
+		mu.Unlock()
+		return nil
+	})
+
+	section("health", func(ctx context.Context) error {
+		health := s.health.Check(ctx)
+		mu.Lock()
+		summary.Health = health
+		mu.Unlock()
+		return nil
+	})
+
+	// Rate limits come from the in-memory settings snapshot and can't fail
+	summary.RateLimits = make(map[string]models.RateLimitState, len(dashboardRateLimits))
+	for _, name := range dashboardRateLimits {
+		limit := s.settings.RateLimit(name)
+		summary.RateLimits[name] = models.RateLimitState{
+			Requests:      limit.Requests,
+			WindowSeconds: limit.WindowS,
+		}
+	}
+
+	// Section errors are recorded in the summary, never returned
+	g.Wait()
+
+	if len(summary.Errors) == 0 {
+		summary.Errors = nil
+	}
+
+	// Partial summaries aren't cached so a transient failure clears on the next load
+	if summary.Errors == nil && s.cfg.DashboardCacheTTLS > 0 {
+		if err := cache.Set(ctx, dashboardCacheKey, summary, time.Duration(s.cfg.DashboardCacheTTLS)*time.Second); err != nil && cache.Client != nil {
+			logrus.WithError(err).Warn("Failed to cache dashboard summary")
+		}
+	}
+
+	return summary, nil
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/audit"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/deadletter"
+	"github.com/ai-support-assistant/backend/internal/middleware"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// deadLetterRefreshInterval is how often the backlog gauge is recounted, so
+// dead letters written by other instances show up
+const deadLetterRefreshInterval = time.Minute
+
+// DeadLetterFilter narrows a dead letter listing. Zero values match everything.
+type DeadLetterFilter struct {
+	Operation string
+	Status    string
+	Limit     int
+	Offset    int
+}
+
+// DeadLetterService lists operations that failed for good and replays them
+// through the replayer registered for their operation type
+type DeadLetterService struct {
+	replayers map[string]deadletter.Replayer
+}
+
+func NewDeadLetterService(replayers map[string]deadletter.Replayer) *DeadLetterService {
+	return &DeadLetterService{replayers: replayers}
+}
+
+// Start keeps the backlog gauge up to date until ctx is cancelled
+func (s *DeadLetterService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(deadLetterRefreshInterval)
+		defer ticker.Stop()
+
+		for {
+			s.refreshBacklog(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// refreshBacklog exports the number of pending dead letters per operation
+func (s *DeadLetterService) refreshBacklog(ctx context.Context) {
+	var rows []struct {
+		Operation string
+		Count     int64
+	}
+	err := db.DB.WithContext(ctx).Model(&models.DeadLetter{}).
+		Select("operation, COUNT(*) AS count").
+		Where("status = ?", deadletter.StatusPending).
+		Group("operation").
+		Scan(&rows).Error
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to count dead letters")
+		return
+	}
+
+	counts := make(map[string]int64, len(s.replayers)+len(rows))
+	for operation := range s.replayers {
+		counts[operation] = 0
+	}
+	for _, row := range rows {
+		counts[row.Operation] = row.Count
+	}
+	middleware.SetDeadLetterBacklog(counts)
+}
+
+// GetDeadLetters returns dead letters, newest first, and the total matching
+func (s *DeadLetterService) GetDeadLetters(ctx context.Context, filter DeadLetterFilter) ([]models.DeadLetter, int64, error) {
+	if filter.Status != "" && filter.Status != deadletter.StatusPending && filter.Status != deadletter.StatusReplayed {
+		return nil, 0, fmt.Errorf("%w: status must be pending or replayed", ErrInvalidRequest)
+	}
+
+	query := db.DB.WithContext(ctx).Model(&models.DeadLetter{})
+	if filter.Operation != "" {
+		query = query.Where("operation = ?", filter.Operation)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count dead letters: %w", err)
+	}
+
+	letters := []models.DeadLetter{}
+	err := query.Order("created_at DESC, id DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&letters).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get dead letters: %w", err)
+	}
+
+	return letters, total, nil
+}
+
+// Replay re-enqueues a pending dead letter's operation with its attempts
+// reset and marks it replayed. If the operation fails again it is
+// dead-lettered anew. Unknown operation types, truncated payloads and
+// operations whose resources are gone are validation errors.
+func (s *DeadLetterService) Replay(ctx context.Context, id uint) (*models.DeadLetter, error) {
+	var letter models.DeadLetter
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&letter, id).Error; err != nil {
+			return notFoundError("dead letter", err)
+		}
+		before := letter
+
+		replay, ok := s.replayers[letter.Operation]
+		if !ok {
+			return validationError("unknown operation type %q", letter.Operation)
+		}
+		if letter.Truncated {
+			return validationError("dead letter %d was truncated and can't be replayed", letter.ID)
+		}
+
+		// Claim the letter so concurrent replays don't run it twice
+		now := time.Now().UTC()
+		result := tx.Model(&models.DeadLetter{}).
+			Where("id = ? AND status = ?", letter.ID, deadletter.StatusPending).
+			Updates(map[string]interface{}{"status": deadletter.StatusReplayed, "replayed_at": now})
+		if result.Error != nil {
+			return fmt.Errorf("failed to update dead letter: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: dead letter %d was already replayed", ErrInvalidRequest, letter.ID)
+		}
+		letter.Status = deadletter.StatusReplayed
+		letter.ReplayedAt = &now
+
+		if err := replay(ctx, json.RawMessage(letter.Payload)); err != nil {
+			if errors.Is(err, deadletter.ErrNotReplayable) {
+				return validationError("%v", err)
+			}
+			return fmt.Errorf("failed to replay %s: %w", letter.Operation, err)
+		}
+
+		return audit.Record(ctx, tx, "dead_letter.replay", "dead_letter", strconv.FormatUint(uint64(letter.ID), 10), before, letter)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"dead_letter_id": letter.ID,
+		"operation":      letter.Operation,
+	}).Info("Dead letter replayed")
+
+	s.refreshBacklog(ctx)
+	return &letter, nil
+}
+
+// replayLookupError reports a record a replayed operation refers to that
+// can't be loaded; a deleted record makes the operation unreplayable
+func replayLookupError(what string, id uint, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: %s %d no longer exists", deadletter.ErrNotReplayable, what, id)
+	}
+	return fmt.Errorf("failed to get %s: %w", what, err)
+}
@@ -0,0 +1,338 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ai-support-assistant/backend/internal/cache"
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+)
+
+// didYouMeanKey is the Redis key holding the vocabulary shared by all instances
+const didYouMeanKey = "did_you_mean:vocabulary"
+
+// didYouMeanLockKey lets one instance rebuild the vocabulary per refresh interval
+const didYouMeanLockKey = "did_you_mean:refresh"
+
+// maxDidYouMean is the most suggestions returned with an answer
+const maxDidYouMean = 3
+
+// didYouMeanCandidates caps the entries sharing the most trigrams with a
+// query that are ranked by edit distance
+const didYouMeanCandidates = 20
+
+// minDidYouMeanOverlap is the trigram overlap, as a Dice coefficient, an
+// entry needs to be a candidate
+const minDidYouMeanOverlap = 0.3
+
+// maxDidYouMeanRunes caps the length of the queries compared, bounding the
+// edit distance computation
+const maxDidYouMeanRunes = 200
+
+// vocabularyEntry is a frequent past query, as shown to users and normalized
+type vocabularyEntry struct {
+	Query      string `json:"query"`
+	Normalized string `json:"normalized"`
+	Count      int64  `json:"count"`
+}
+
+// didYouMeanIndex maps each trigram to the vocabulary entries containing it
+type didYouMeanIndex struct {
+	entries  []vocabularyEntry
+	sizes    []int // distinct trigrams per entry
+	postings map[string][]int32
+}
+
+// DidYouMeanService suggests frequent past queries close to a query that
+// retrieved nothing, which is most often a typo. The vocabulary is rebuilt
+// from recent queries by one instance per refresh interval and shared
+// through Redis; each instance searches an in-process trigram index of it.
+type DidYouMeanService struct {
+	cfg   *config.Config
+	flags *FeatureFlagService
+
+	index atomic.Pointer[didYouMeanIndex]
+}
+
+func NewDidYouMeanService(cfg *config.Config, flags *FeatureFlagService) *DidYouMeanService {
+	return &DidYouMeanService{cfg: cfg, flags: flags}
+}
+
+// Start loads the vocabulary and refreshes it every
+// DID_YOU_MEAN_REFRESH_INTERVAL until ctx is cancelled
+func (s *DidYouMeanService) Start(ctx context.Context) {
+	if !s.cfg.DidYouMeanEnabled {
+		logrus.Info("Did you mean suggestions disabled")
+		return
+	}
+
+	go func() {
+		s.refresh(ctx)
+
+		ticker := time.NewTicker(time.Duration(s.cfg.DidYouMeanRefreshS) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.refresh(ctx)
+			}
+		}
+	}()
+}
+
+// refresh replaces the index with the current vocabulary; on failure the
+// previous index stays in effect
+func (s *DidYouMeanService) refresh(ctx context.Context) {
+	entries, err := s.sharedVocabulary(ctx)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to refresh did you mean vocabulary")
+		return
+	}
+
+	s.index.Store(buildDidYouMeanIndex(entries))
+	logrus.WithField("entries", len(entries)).Debug("Did you mean vocabulary refreshed")
+}
+
+// sharedVocabulary returns the vocabulary stored in Redis, rebuilding it
+// when this instance claims the refresh or Redis doesn't have it
+func (s *DidYouMeanService) sharedVocabulary(ctx context.Context) ([]vocabularyEntry, error) {
+	interval := time.Duration(s.cfg.DidYouMeanRefreshS) * time.Second
+	if cache.Client == nil {
+		return s.buildVocabulary(ctx)
+	}
+
+	claimed, err := cache.SetNX(ctx, didYouMeanLockKey, 1, interval)
+	if err == nil && !claimed {
+		var entries []vocabularyEntry
+		err := cache.Get(ctx, didYouMeanKey, &entries)
+		if err == nil {
+			return entries, nil
+		}
+		if err != redis.Nil {
+			return nil, fmt.Errorf("failed to get vocabulary: %w", err)
+		}
+		// The instance that claimed the refresh hasn't stored it yet
+	}
+
+	entries, err := s.buildVocabulary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := cache.Set(ctx, didYouMeanKey, entries, 2*interval); err != nil {
+		logrus.WithError(err).Debug("Failed to share did you mean vocabulary")
+	}
+	return entries, nil
+}
+
+// buildVocabulary returns the most frequent queries of the window, most
+// frequent first. A query is left out if any time it was asked the answer
+// was moderated, poorly grounded or rated down, or if it retrieved nothing
+// itself.
+func (s *DidYouMeanService) buildVocabulary(ctx context.Context) ([]vocabularyEntry, error) {
+	since := time.Now().AddDate(0, 0, -s.cfg.DidYouMeanWindowDays)
+
+	negative := db.DB.Model(&models.ChatQuery{}).
+		Select(queryGroupKey).
+		Where("chat_queries.created_at > ?", since).
+		Where("(chat_queries.moderation_flag OR chat_queries.grounding_score < ? OR EXISTS (SELECT 1 FROM feedbacks WHERE feedbacks.query_id = chat_queries.id AND feedbacks.score = ?))",
+			s.cfg.VerificationMinScore, -1)
+
+	// The latest query of each group stands for it, as the knowledge base may
+	// have changed since the earlier ones
+	var groups []struct {
+		SampleID uint
+		Count    int64
+	}
+	err := analyticsQueries().WithContext(ctx).
+		Where("chat_queries.created_at > ?", since).
+		Where(queryGroupKey+" NOT IN (?)", negative).
+		Select("MAX(chat_queries.id) AS sample_id, COUNT(*) AS count").
+		Group(queryGroupKey).
+		Having("COUNT(DISTINCT chat_queries.session_id) >= ?", s.cfg.DidYouMeanMinSessions).
+		Order("count DESC").
+		Limit(s.cfg.DidYouMeanVocabulary).
+		Scan(&groups).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count queries: %w", err)
+	}
+	if len(groups) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]uint, len(groups))
+	for i, group := range groups {
+		ids[i] = group.SampleID
+	}
+
+	var samples []models.ChatQuery
+	if err := db.DB.WithContext(ctx).Select("id", "query", "context").Where("id IN ?", ids).Find(&samples).Error; err != nil {
+		return nil, fmt.Errorf("failed to load queries: %w", err)
+	}
+	byID := make(map[uint]models.ChatQuery, len(samples))
+	for _, sample := range samples {
+		byID[sample.ID] = sample
+	}
+
+	entries := make([]vocabularyEntry, 0, len(groups))
+	for _, group := range groups {
+		sample, ok := byID[group.SampleID]
+		if !ok || sample.Context == "" || sample.Context == formatContext(nil) {
+			continue
+		}
+		normalized := normalizeQuery(sample.Query)
+		if normalized == "" || utf8.RuneCountInString(normalized) > maxDidYouMeanRunes {
+			continue
+		}
+		entries = append(entries, vocabularyEntry{Query: sample.Query, Normalized: normalized, Count: group.Count})
+	}
+	return entries, nil
+}
+
+// buildDidYouMeanIndex indexes the vocabulary by trigram
+func buildDidYouMeanIndex(entries []vocabularyEntry) *didYouMeanIndex {
+	index := &didYouMeanIndex{
+		entries:  entries,
+		sizes:    make([]int, len(entries)),
+		postings: make(map[string][]int32),
+	}
+	for i, entry := range entries {
+		grams := queryTrigrams(entry.Normalized)
+		index.sizes[i] = len(grams)
+		for gram := range grams {
+			index.postings[gram] = append(index.postings[gram], int32(i))
+		}
+	}
+	return index
+}
+
+// Suggest returns up to three past queries close to a query, closest
+// first, or nil if there are none or suggestions are switched off
+func (s *DidYouMeanService) Suggest(query string) []string {
+	if !s.cfg.DidYouMeanEnabled {
+		return nil
+	}
+	if enabled, _ := s.flags.Feature(FeatureDidYouMean); !enabled {
+		return nil
+	}
+	index := s.index.Load()
+	if index == nil || len(index.entries) == 0 {
+		return nil
+	}
+
+	normalized := normalizeQuery(query)
+	if normalized == "" || utf8.RuneCountInString(normalized) > maxDidYouMeanRunes {
+		return nil
+	}
+	return index.nearest(normalized, s.cfg.DidYouMeanMinSimilarity)
+}
+
+// nearest picks the entries sharing the most trigrams with a normalized
+// query and returns those within minSimilarity of it by edit distance
+func (x *didYouMeanIndex) nearest(normalized string, minSimilarity float64) []string {
+	grams := queryTrigrams(normalized)
+	shared := make([]uint16, len(x.entries))
+	for gram := range grams {
+		for _, i := range x.postings[gram] {
+			shared[i]++
+		}
+	}
+
+	type candidate struct {
+		entry int
+		score float64
+	}
+	var candidates []candidate
+	for i, n := range shared {
+		if n == 0 {
+			continue
+		}
+		dice := 2 * float64(n) / float64(len(grams)+x.sizes[i])
+		if dice >= minDidYouMeanOverlap {
+			candidates = append(candidates, candidate{entry: i, score: dice})
+		}
+	}
+	sort.Slice(candidates, func(a, b int) bool {
+		return candidates[a].score > candidates[b].score
+	})
+	if len(candidates) > didYouMeanCandidates {
+		candidates = candidates[:didYouMeanCandidates]
+	}
+
+	matches := candidates[:0]
+	for _, c := range candidates {
+		entry := x.entries[c.entry]
+		if entry.Normalized == normalized {
+			continue
+		}
+		if similarity := editSimilarity(normalized, entry.Normalized); similarity >= minSimilarity {
+			matches = append(matches, candidate{entry: c.entry, score: similarity})
+		}
+	}
+	sort.SliceStable(matches, func(a, b int) bool {
+		if matches[a].score != matches[b].score {
+			return matches[a].score > matches[b].score
+		}
+		return x.entries[matches[a].entry].Count > x.entries[matches[b].entry].Count
+	})
+
+	var suggestions []string
+	for _, match := range matches {
+		if len(suggestions) == maxDidYouMean {
+			break
+		}
+		suggestions = append(suggestions, x.entries[match.entry].Query)
+	}
+	return suggestions
+}
+
+// queryTrigrams returns the distinct character trigrams of a normalized
+// query, padded with spaces so short words still have some
+func queryTrigrams(normalized string) map[string]struct{} {
+	runes := []rune("  " + normalized + " ")
+	grams := make(map[string]struct{}, len(runes))
+	for i := 0; i+3 <= len(runes); i++ {
+		grams[string(runes[i:i+3])] = struct{}{}
+	}
+	return grams
+}
+
+// editSimilarity is 1 minus the Levenshtein distance between two strings
+// over the length of the longer one
+func editSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return 1 - float64(previous[len(rb)])/float64(longest)
+}
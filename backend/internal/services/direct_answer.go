@@ -0,0 +1,266 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/llm"
+	"github.com/ai-support-assistant/backend/internal/middleware"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// How the pipeline answering a query was chosen
+const (
+	routeRule       = "rule"
+	routeClassifier = "classifier"
+	routeDefault    = "default"
+	routeFallback   = "fallback" // the direct call failed and the RAG service answered
+)
+
+// classifierPrompt asks the model to label a query for the intent gate
+const classifierPrompt = `You route messages sent to a customer support assistant. Reply "knowledge" if answering the message needs the company's documentation, products, policies or the customer's account, or "general" if it can be answered from general knowledge or is a writing task such as drafting an email. Reply with that one word only.`
+
+// DirectAnswerService is the intent gate in front of the RAG service:
+// general questions, which need no retrieval, are answered by the LLM
+// directly. Queries are labelled by the regex rules in the config and, with
+// DIRECT_CLASSIFIER set, by the model for queries no rule matches. Direct
+// answers are off without an OpenAI key or while the direct_answers flag is
+// switched off.
+type DirectAnswerService struct {
+	cfg    *config.Config
+	flags  *FeatureFlagService
+	client *llm.Client // nil unless OPENAI_API_KEY is set
+
+	directPatterns []*regexp.Regexp
+	ragPatterns    []*regexp.Regexp
+}
+
+func NewDirectAnswerService(cfg *config.Config, flags *FeatureFlagService) *DirectAnswerService {
+	s := &DirectAnswerService{
+		cfg:            cfg,
+		flags:          flags,
+		directPatterns: compilePatterns(cfg.DirectQueryPatterns),
+		ragPatterns:    compilePatterns(cfg.RAGQueryPatterns),
+	}
+	if cfg.OpenAIKey != "" {
+		s.client = llm.NewClient(cfg.OpenAIBaseURL, cfg.OpenAIKey, cfg.OpenAIModel,
+			time.Duration(cfg.OpenAITimeoutS)*time.Second, cfg.OpenAIMaxRetries)
+	}
+	return s
+}
+
+// enabled reports whether any query may be answered directly
+func (s *DirectAnswerService) enabled() bool {
+	if s.client == nil || (len(s.directPatterns) == 0 && !s.cfg.DirectClassifier) {
+		return false
+	}
+	enabled, _ := s.flags.Feature(FeatureDirect)
+	return enabled
+}
+
+// Route labels a query and returns the pipeline that should answer it,
+// with how it was chosen. Rules sending queries to the RAG service are
+// checked first; a query no rule matches goes to the RAG service unless the
+// classifier labels it general. A failed classification counts as
+// knowledge base, which can answer anything.
+func (s *DirectAnswerService) Route(ctx context.Context, query string) (string, string) {
+	if !s.enabled() {
+		return models.PipelineRAG, routeDefault
+	}
+
+	normalized := normalizeQuery(query)
+	for _, pattern := range s.ragPatterns {
+		if pattern.MatchString(normalized) {
+			return models.PipelineRAG, routeRule
+		}
+	}
+	for _, pattern := range s.directPatterns {
+		if pattern.MatchString(normalized) {
+			return models.PipelineDirect, routeRule
+		}
+	}
+	if !s.cfg.DirectClassifier {
+		return models.PipelineRAG, routeDefault
+	}
+
+	general, err := s.classify(ctx, query)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to classify query, answering from the knowledge base")
+		return models.PipelineRAG, routeDefault
+	}
+	if general {
+		return models.PipelineDirect, routeClassifier
+	}
+	return models.PipelineRAG, routeClassifier
+}
+
+// classify asks the model whether a query is a general question
+func (s *DirectAnswerService) classify(ctx context.Context, query string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(s.cfg.DirectClassifierTimeoutMs)*time.Millisecond)
+	defer cancel()
+
+	temperature := 0.0
+	resp, err := s.client.Complete(ctx, llm.Request{
+		Messages: []llm.Message{
+			{Role: llm.RoleSystem, Content: classifierPrompt},
+			{Role: llm.RoleUser, Content: query},
+		},
+		Temperature: &temperature,
+		MaxTokens:   3,
+	})
+	if err != nil {
+		middleware.RecordLLMRequest("classify", "error")
+		return false, err
+	}
+	middleware.RecordLLMRequest("classify", "success")
+
+	label := strings.ToLower(strings.TrimSpace(resp.Content))
+	switch {
+	case strings.HasPrefix(label, "general"):
+		return true, nil
+	case strings.HasPrefix(label, "knowledge"):
+		return false, nil
+	}
+	return false, fmt.Errorf("unexpected classification %q", label)
+}
+
+// Answer has the model answer a query without retrieval, drawing on the
+// conversation's history, in the persona and response language the RAG
+// service would have used. The answer is shaped like a RAG answer with no
+// context. The model is always OPENAI_MODEL: routing rules and variants
+// pick models for the RAG service, which may not be OpenAI's.
+func (s *DirectAnswerService) Answer(ctx context.Context, req RAGQueryRequest) (*RAGQueryResponse, error) {
+	if s.client == nil {
+		return nil, errors.New("direct answers need OPENAI_API_KEY")
+	}
+
+	messages := []llm.Message{{Role: llm.RoleSystem, Content: s.systemPrompt(req)}}
+	messages = append(messages, s.history(ctx, req)...)
+	messages = append(messages, llm.Message{Role: llm.RoleUser, Content: req.Query})
+
+	start := time.Now()
+	resp, err := s.client.Complete(ctx, llm.Request{
+		Messages:    messages,
+		Temperature: req.Temperature,
+	})
+	if err != nil {
+		middleware.RecordLLMRequest("answer", "error")
+		return nil, err
+	}
+	middleware.RecordLLMRequest("answer", "success")
+	generationMs := int(time.Since(start).Milliseconds())
+
+	return &RAGQueryResponse{
+		Response:         resp.Content,
+		Context:          []string{},
+		Model:            resp.Model,
+		TokensUsed:       resp.TotalTokens,
+		PromptTokens:     resp.PromptTokens,
+		CompletionTokens: resp.CompletionTokens,
+		PhaseTimings:     models.PhaseTimings{GenerationMs: &generationMs},
+	}, nil
+}
+
+// systemPrompt is DIRECT_SYSTEM_PROMPT with the persona's instructions and
+// the language the answer must be in
+func (s *DirectAnswerService) systemPrompt(req RAGQueryRequest) string {
+	parts := []string{s.cfg.DirectSystemPrompt}
+	if persona := req.Persona; persona != nil {
+		if persona.Prompt != "" {
+			parts = append(parts, persona.Prompt)
+		}
+		if persona.Tone != "" {
+			parts = append(parts, "Answer in a "+persona.Tone+" tone.")
+		}
+		if persona.Signature != "" {
+			parts = append(parts, "End the answer with this signature: "+persona.Signature)
+		}
+	}
+	if req.ResponseLanguage != "" {
+		parts = append(parts, fmt.Sprintf("Answer in the language with ISO 639-1 code %q.", req.ResponseLanguage))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// history returns the conversation segment's latest turns as messages,
+// oldest first, up to PROMPT_HISTORY_TURNS or the turns left after the
+// prompt was trimmed to fit. A failed lookup answers without history.
+func (s *DirectAnswerService) history(ctx context.Context, req RAGQueryRequest) []llm.Message {
+	limit := s.cfg.PromptHistoryTurns
+	if req.HistoryTurns != nil {
+		limit = min(limit, *req.HistoryTurns)
+	}
+	if limit == 0 || req.ContextReset {
+		return nil
+	}
+
+	var turns []models.ChatQuery
+	err := db.DB.WithContext(ctx).
+		Select("query", "response").
+		Where("session_id = ? AND segment = ?", req.SessionID, req.Segment).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&turns).Error
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to load session history for direct answer")
+		return nil
+	}
+
+	messages := make([]llm.Message, 0, 2*len(turns))
+	for i := len(turns) - 1; i >= 0; i-- {
+		messages = append(messages,
+			llm.Message{Role: llm.RoleUser, Content: turns[i].Query},
+			llm.Message{Role: llm.RoleAssistant, Content: turns[i].Response},
+		)
+	}
+	return messages
+}
+
+// generateDirect has the LLM answer a general question without retrieval.
+// The prompt is budgeted like one for the RAG service, less the retrieved
+// chunks; the answer then goes through the same moderation, caching and
+// post-processing as a RAG answer. It reports whether the query was
+// answered, or failed for good: if the call fails while the RAG service can
+// answer instead, the query falls back to it.
+func (s *QueryService) generateDirect(ctx context.Context, qc *QueryContext, method string) (bool, error) {
+	directReq := directRequest(qc.RAGRequest)
+
+	estimated, err := s.budgetPrompt(ctx, &directReq, qc.Opening)
+	if err != nil {
+		qc.EstimatedTokens = estimated
+		return true, err
+	}
+
+	resp, err := s.direct.Answer(ctx, directReq)
+	if err != nil {
+		if ctx.Err() == nil && s.health.RAGAvailable() {
+			logrus.WithError(err).Warn("Failed to answer directly, falling back to the RAG service")
+			middleware.RecordQueryPipeline(models.PipelineRAG, routeFallback)
+			return false, nil
+		}
+		return true, fmt.Errorf("failed to answer directly: %w", transportError(ctx, err))
+	}
+	s.postProcess(ctx, resp)
+	middleware.RecordQueryPipeline(models.PipelineDirect, method)
+	middleware.RecordPromptEstimate(resp.Model, estimated, resp.TokensUsed)
+
+	qc.Pipeline = models.PipelineDirect
+	qc.EstimatedTokens = estimated
+	qc.RAGResponse = resp
+	return true, nil
+}
+
+// directRequest is a RAG request as answered directly: nothing is retrieved
+// and the model is always OPENAI_MODEL
+func directRequest(req RAGQueryRequest) RAGQueryRequest {
+	req.TopK = 0
+	req.Model = ""
+	return req
+}
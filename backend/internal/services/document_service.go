@@ -1,134 +1,278 @@
 package services
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
-	"net/http"
+	"strings"
 	"time"
 
 	"github.com/ai-support-assistant/backend/internal/config"
 	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/lifecycle"
 	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/netguard"
+	"github.com/ai-support-assistant/backend/internal/notify"
+	"github.com/ai-support-assistant/backend/internal/objectstore"
+	"github.com/ai-support-assistant/backend/internal/scanner"
+	"github.com/ai-support-assistant/backend/internal/webhook"
 	"github.com/sirupsen/logrus"
 )
 
-type DocumentService struct {
-	cfg *config.Config
-}
+// Error codes recorded on documents that failed to ingest
+const (
+	IngestErrorFailed            = "ingestion_failed"
+	IngestErrorSourceUnreachable = "source_unreachable"
+	IngestErrorSourceTooLarge    = "source_too_large"
+	IngestErrorUnsupportedType   = "unsupported_content_type"
+	IngestErrorMalwareDetected   = "malware_detected"
+	IngestErrorScanUnavailable   = "scan_unavailable"
+)
 
-func NewDocumentService(cfg *config.Config) *DocumentService {
-	return &DocumentService{cfg: cfg}
-}
+// maxDocumentErrorLength matches the error_message column
+const maxDocumentErrorLength = 500
 
-// UploadDocument handles document upload and sends to RAG service
-func (s *DocumentService) UploadDocument(ctx context.Context, file multipart.File, header *multipart.FileHeader, uploadedBy string) (*models.DocumentUploadResponse, error) {
-	// Save document metadata to database
-	doc := models.Document{
-		FileName:   header.Filename,
-		FileType:   header.Header.Get("Content-Type"),
-		FileSize:   header.Size,
-		Status:     "processing",
-		UploadedBy: uploadedBy,
-	}
+type DocumentService struct {
+	cfg        *config.Config
+	notifier   *notify.SlackNotifier
+	dispatcher *webhook.Dispatcher
+	transport  RAGTransport
+	health     *HealthService
+	lifecycle  *lifecycle.Manager
 
-	if err := db.DB.Create(&doc).Error; err != nil {
-		return nil, fmt.Errorf("failed to save document: %w", err)
-	}
+	// objects fetches documents ingested straight from cloud storage
+	objects *objectstore.Client
 
-	// Send to RAG service for ingestion
-	go s.ingestDocument(doc.ID, file, header)
+	// scanner checks uploads for malware before they are accepted
+	scanner scanner.Scanner
 
-	return &models.DocumentUploadResponse{
-		DocumentID: doc.ID,
-		FileName:   header.Filename,
-		Status:     "processing",
-		Message:    "Document uploaded successfully and is being processed",
-	}, nil
+	// guard refuses object URLs on the server's own network
+	guard *netguard.Guard
 }
 
-// ingestDocument sends document to RAG service for ingestion
-func (s *DocumentService) ingestDocument(docID uint, file multipart.File, header *multipart.FileHeader) {
-	ctx := context.Background()
+func NewDocumentService(cfg *config.Config, notifier *notify.SlackNotifier, dispatcher *webhook.Dispatcher, transport RAGTransport, health *HealthService, lc *lifecycle.Manager) *DocumentService {
+	// Credentials were validated when the config was loaded
+	credentials, _ := objectstore.ParseCredentials(cfg.ObjectStoreCredentials)
+	guard := outboundGuard(cfg)
 
-	// Reset file pointer
-	file.Seek(0, 0)
+	return &DocumentService{
+		cfg:        cfg,
+		notifier:   notifier,
+		dispatcher: dispatcher,
+		transport:  transport,
+		health:     health,
+		lifecycle:  lc,
+		objects:    objectstore.NewClient(credentials, cfg.ObjectStoreS3Region, cfg.ObjectStoreS3Endpoint, guard.Dialer(30*time.Second).DialContext),
+		scanner:    newUploadScanner(cfg),
+		guard:      guard,
+	}
+}
 
-	// Create multipart form
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
+// UploadDocument handles document upload and sends to RAG service.
+// A non-empty collection name places the document in that collection, creating it if needed.
+// The document key, derived from the file name when empty, makes the upload a
+// new version of the collection's document with the same key. Visibility is
+// public or internal and, like ingestion options left out, defaults to the
+// previous version's. The text of plain-text uploads is kept for previews and,
+// while the RAG service is down, the upload is queued instead of failing.
+// Uploads are scanned for malware first; infected files are rejected.
+func (s *DocumentService) UploadDocument(ctx context.Context, file multipart.File, header *multipart.FileHeader, uploadedBy, collectionName, documentKey, visibility string, options models.IngestOptionsRequest) (*models.DocumentUploadResponse, error) {
+	if s.lifecycle.Stopping() {
+		return nil, fmt.Errorf("%w: server is shutting down", ErrOverloaded)
+	}
 
-	part, err := writer.CreateFormFile("file", header.Filename)
+	scan, err := s.scanUpload(ctx, file, header, uploadedBy)
 	if err != nil {
-		s.updateDocumentStatus(docID, "failed")
-		logrus.WithError(err).Error("Failed to create form file")
-		return
+		return nil, err
+	}
+
+	// Save document metadata to database
+	doc := models.Document{
+		FileName:    header.Filename,
+		FileType:    header.Header.Get("Content-Type"),
+		FileSize:    header.Size,
+		Status:      "processing",
+		UploadedBy:  uploadedBy,
+		ScanVerdict: scan.Verdict,
+		ScanEngine:  scan.Engine,
 	}
 
-	if _, err := io.Copy(part, file); err != nil {
-		s.updateDocumentStatus(docID, "failed")
-		logrus.WithError(err).Error("Failed to copy file")
-		return
+	if collectionName != "" {
+		collection, err := resolveCollection(collectionName)
+		if err != nil {
+			return nil, err
+		}
+		doc.CollectionID = &collection.ID
+		collectionName = collection.Name
 	}
 
-	writer.Close()
+	if err := assignVersion(&doc, documentKey); err != nil {
+		return nil, err
+	}
+	if err := assignVisibility(&doc, visibility); err != nil {
+		return nil, err
+	}
+	if err := s.assignIngestOptions(&doc, options); err != nil {
+		return nil, err
+	}
 
-	// Make request to RAG service
-	url := fmt.Sprintf("%s/rag/ingest", s.cfg.RAGServiceURL)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	text, err := extractText(file, header.Filename, s.cfg.DocumentTextMaxBytes)
 	if err != nil {
-		s.updateDocumentStatus(docID, "failed")
-		logrus.WithError(err).Error("Failed to create request")
-		return
+		return nil, err
 	}
+	assignExtractedText(&doc, text)
 
-	req.Header.Set("Content-Type", writer.FormDataContentType())
+	queued := canQueue(&doc) && !s.health.RAGAvailable()
+	if queued {
+		doc.Status = "queued"
+	}
 
-	client := &http.Client{
-		Timeout: 300 * time.Second, // 5 minutes for large files
+	if err := db.DB.Create(&doc).Error; err != nil {
+		return nil, fmt.Errorf("failed to save document: %w", err)
 	}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		s.updateDocumentStatus(docID, "failed")
-		logrus.WithError(err).Error("Failed to call RAG service")
-		return
+	if queued {
+		logrus.WithField("doc_id", doc.ID).Warn("RAG service unavailable, document queued for ingestion")
+		return &models.DocumentUploadResponse{
+			DocumentID:  doc.ID,
+			FileName:    header.Filename,
+			DocumentKey: doc.DocumentKey,
+			Version:     doc.Version,
+			Visibility:  doc.Visibility,
+			Status:      "queued",
+			Message:     "Document uploaded and will be processed once the RAG service is available",
+			ScanVerdict: doc.ScanVerdict,
+
+			IngestOptions: doc.IngestOptions,
+		}, nil
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		s.updateDocumentStatus(docID, "failed")
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		logrus.WithField("status", resp.StatusCode).WithField("body", string(bodyBytes)).Error("RAG service returned error")
-		return
+	// Send to RAG service for ingestion
+	started := s.lifecycle.Go("ingest_document", logrus.Fields{
+		"doc_id":    doc.ID,
+		"file_name": header.Filename,
+	}, func(ctx context.Context) {
+		// Reset file pointer
+		file.Seek(0, 0)
+
+		fields := map[string]string{"visibility": doc.Visibility}
+		if collectionName != "" {
+			fields["collection"] = collectionName
+		}
+		addIngestOptionFields(fields, doc.IngestOptions)
+		s.ingestDocument(ctx, doc.ID, header.Filename, file, fields)
+	})
+	if !started {
+		s.updateDocumentStatus(doc.ID, "failed")
+		return nil, fmt.Errorf("%w: server is shutting down", ErrOverloaded)
 	}
 
-	// Parse response
-	var ingestResp struct {
-		ChunkCount    int    `json:"chunk_count"`
-		VectorStoreID string `json:"vector_store_id"`
+	return &models.DocumentUploadResponse{
+		DocumentID:  doc.ID,
+		FileName:    header.Filename,
+		DocumentKey: doc.DocumentKey,
+		Version:     doc.Version,
+		Visibility:  doc.Visibility,
+		Status:      "processing",
+		Message:     "Document uploaded successfully and is being processed",
+		ScanVerdict: doc.ScanVerdict,
+
+		IngestOptions: doc.IngestOptions,
+	}, nil
+}
+
+// ingestDocument sends document content to the RAG service for ingestion and
+// records the outcome. Fields are passed alongside the content, e.g. the
+// collection that chunks are tagged with so retrieval can be scoped. Uploads
+// whose text was kept are queued if the RAG service is unavailable.
+func (s *DocumentService) ingestDocument(ctx context.Context, docID uint, fileName string, content io.Reader, fields map[string]string) error {
+	ingestResp, err := s.ingestWithProgress(ctx, docID, RAGIngestRequest{
+		FileName: fileName,
+		Content:  content,
+		Fields:   fields,
+	})
+	if err != nil {
+		if s.queueIngestion(docID, err) {
+			return err
+		}
+		return s.failIngestion(docID, fileName, IngestErrorFailed, err, "Failed to ingest document")
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&ingestResp); err != nil {
-		s.updateDocumentStatus(docID, "failed")
-		logrus.WithError(err).Error("Failed to decode response")
-		return
+	s.completeIngestion(ctx, docID, fileName, ingestResp)
+	return nil
+}
+
+// ingestText scans text fetched or written on the server, such as a crawled
+// page or an approved correction, and ingests it like ingestDocument. The
+// verdict is recorded on the document; text the scan rejects fails it.
+func (s *DocumentService) ingestText(ctx context.Context, docID uint, fileName, uploadedBy, text string, fields map[string]string) error {
+	content := strings.NewReader(text)
+	scan, err := s.scanContent(ctx, content, fileName, int64(len(text)), uploadedBy)
+	if err != nil {
+		code := IngestErrorScanUnavailable
+		if errors.Is(err, ErrMalwareDetected) {
+			code = IngestErrorMalwareDetected
+		}
+		return s.failIngestion(docID, fileName, code, err, "Document rejected by the malware scan")
 	}
+	db.DB.Model(&models.Document{}).Where("id = ?", docID).Updates(map[string]interface{}{
+		"scan_verdict": scan.Verdict,
+		"scan_engine":  scan.Engine,
+	})
+
+	return s.ingestDocument(ctx, docID, fileName, content, fields)
+}
 
-	// Update document status
+// completeIngestion records a successful ingestion, supersedes older versions
+// of the document and notifies subscribers
+func (s *DocumentService) completeIngestion(ctx context.Context, docID uint, fileName string, ingestResp *RAGIngestResponse) {
 	db.DB.Model(&models.Document{}).Where("id = ?", docID).Updates(map[string]interface{}{
-		"status":          "completed",
-		"chunk_count":     ingestResp.ChunkCount,
-		"vector_store_id": ingestResp.VectorStoreID,
+		"status":           "completed",
+		"chunk_count":      ingestResp.ChunkCount,
+		"vector_store_id":  ingestResp.VectorStoreID,
+		"progress_percent": 100,
+		"chunks_processed": ingestResp.ChunkCount,
+		"chunks_total":     ingestResp.ChunkCount,
 	})
 
 	logrus.WithFields(logrus.Fields{
 		"doc_id":      docID,
 		"chunk_count": ingestResp.ChunkCount,
 	}).Info("Document ingested successfully")
+
+	s.supersedeVersions(ctx, docID)
+
+	s.dispatcher.Dispatch(webhook.EventDocumentCompleted, map[string]interface{}{
+		"document_id":     docID,
+		"file_name":       fileName,
+		"chunk_count":     ingestResp.ChunkCount,
+		"vector_store_id": ingestResp.VectorStoreID,
+	})
+}
+
+// failIngestion marks a document as failed with an error code, logs the cause
+// and alerts the support team. It returns the wrapped cause.
+func (s *DocumentService) failIngestion(docID uint, fileName, code string, err error, message string) error {
+	errorMessage := fmt.Sprintf("%s: %v", message, err)
+	if len(errorMessage) > maxDocumentErrorLength {
+		errorMessage = errorMessage[:maxDocumentErrorLength]
+	}
+	db.DB.Model(&models.Document{}).Where("id = ?", docID).Updates(map[string]interface{}{
+		"status":        "failed",
+		"error_code":    code,
+		"error_message": errorMessage,
+	})
+
+	logrus.WithError(err).WithFields(logrus.Fields{"doc_id": docID, "error_code": code}).Error(message)
+	s.notifier.NotifyIngestionFailed(docID, fileName, fmt.Sprintf("%s: %v", message, err))
+	s.dispatcher.Dispatch(webhook.EventDocumentFailed, map[string]interface{}{
+		"document_id": docID,
+		"file_name":   fileName,
+		"error":       fmt.Sprintf("%s: %v", message, err),
+	})
+	return fmt.Errorf("%s: %w", message, err)
 }
 
 // updateDocumentStatus updates document status
@@ -136,24 +280,83 @@ func (s *DocumentService) updateDocumentStatus(docID uint, status string) {
 	db.DB.Model(&models.Document{}).Where("id = ?", docID).Update("status", status)
 }
 
-// GetDocuments returns list of documents
-func (s *DocumentService) GetDocuments(ctx context.Context, limit int, offset int) ([]models.Document, error) {
+// GetDocuments returns list of documents, optionally filtered by collection name
+// and visibility. Superseded versions are left out unless includeSuperseded is set.
+func (s *DocumentService) GetDocuments(ctx context.Context, limit int, offset int, collection, visibility string, includeSuperseded bool) ([]models.Document, error) {
 	var documents []models.Document
 
-	if err := db.DB.Order("created_at DESC").Limit(limit).Offset(offset).Find(&documents).Error; err != nil {
+	if visibility != "" && !validVisibility(visibility) {
+		return nil, fmt.Errorf("%w: visibility must be public or internal", ErrInvalidRequest)
+	}
+
+	query := db.DB.Preload("Collection").Order("documents.created_at DESC").Limit(limit).Offset(offset)
+	if !includeSuperseded {
+		query = query.Where("documents.status <> ?", "superseded")
+	}
+	if collection != "" {
+		query = query.Joins("JOIN collections ON collections.id = documents.collection_id").
+			Where("collections.name = ?", NormalizeCollectionName(collection))
+	}
+	if visibility != "" {
+		query = query.Where("documents.visibility = ?", visibility)
+	}
+
+	if err := query.Find(&documents).Error; err != nil {
 		return nil, fmt.Errorf("failed to get documents: %w", err)
 	}
 
 	return documents, nil
 }
 
+// GetFailedDocuments returns the most recent documents that failed to ingest
+func (s *DocumentService) GetFailedDocuments(ctx context.Context, limit int) ([]models.Document, error) {
+	var documents []models.Document
+
+	if err := db.DB.Preload("Collection").Where("status = ?", "failed").Order("updated_at DESC").Limit(limit).Find(&documents).Error; err != nil {
+		return nil, fmt.Errorf("failed to get failed documents: %w", err)
+	}
+
+	return documents, nil
+}
+
 // GetDocumentByID returns a document by ID
 func (s *DocumentService) GetDocumentByID(ctx context.Context, id uint) (*models.Document, error) {
 	var document models.Document
 
-	if err := db.DB.First(&document, id).Error; err != nil {
-		return nil, fmt.Errorf("document not found: %w", err)
+	if err := db.DB.Preload("Collection").First(&document, id).Error; err != nil {
+		return nil, notFoundError("document", err)
 	}
 
 	return &document, nil
 }
+
+// UpdateDocument changes a document's collection or visibility
+func (s *DocumentService) UpdateDocument(ctx context.Context, id uint, req models.DocumentUpdateRequest) (*models.Document, error) {
+	document, err := s.GetDocumentByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Collection != nil {
+		var collectionID *uint
+		if *req.Collection != "" {
+			collection, err := resolveCollection(*req.Collection)
+			if err != nil {
+				return nil, err
+			}
+			collectionID = &collection.ID
+		}
+
+		if err := db.DB.Model(document).Update("collection_id", collectionID).Error; err != nil {
+			return nil, fmt.Errorf("failed to update document: %w", err)
+		}
+	}
+
+	if req.Visibility != nil && *req.Visibility != document.Visibility {
+		if err := s.changeVisibility(ctx, document, *req.Visibility); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.GetDocumentByID(ctx, id)
+}
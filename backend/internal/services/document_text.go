@@ -0,0 +1,345 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/deadletter"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// textQueueBatchSize caps the queued documents ingested per queue pass
+const textQueueBatchSize = 20
+
+// plainTextTypes are the formats whose text is extracted at upload, by extension
+var plainTextTypes = map[string]string{
+	".txt":  "text/plain",
+	".md":   "text/markdown",
+	".csv":  "text/csv",
+	".json": "application/json",
+}
+
+// Byte order marks recognized at the start of plain-text uploads
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// extractedText is the text read from a plain-text upload
+type extractedText struct {
+	Content   string
+	Truncated bool
+}
+
+// extractText reads the text of a plain-text upload, keeping at most
+// maxBytes of it. Other formats return nil. A UTF-8 byte order mark is
+// dropped and UTF-16 with a byte order mark is decoded; anything else must be
+// UTF-8, and content with NUL bytes or invalid UTF-8 is rejected as binary.
+func extractText(r io.Reader, fileName string, maxBytes int64) (*extractedText, error) {
+	if _, ok := plainTextTypes[strings.ToLower(filepath.Ext(fileName))]; !ok {
+		return nil, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	truncated := int64(len(data)) > maxBytes
+	if truncated {
+		data = data[:maxBytes]
+	}
+
+	text, err := decodeText(data, truncated)
+	if err != nil {
+		return nil, validationError("%s is not a text file: %v", fileName, err)
+	}
+	return &extractedText{Content: text, Truncated: truncated}, nil
+}
+
+// decodeText decodes plain text to UTF-8. Truncated text may end partway
+// through a character, which is dropped.
+func decodeText(data []byte, truncated bool) (string, error) {
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		data = data[len(bomUTF8):]
+	case bytes.HasPrefix(data, bomUTF16LE):
+		return decodeUTF16(data[len(bomUTF16LE):], binary.LittleEndian, truncated)
+	case bytes.HasPrefix(data, bomUTF16BE):
+		return decodeUTF16(data[len(bomUTF16BE):], binary.BigEndian, truncated)
+	}
+
+	if truncated {
+		data = trimPartialRune(data)
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return "", errors.New("contains NUL bytes")
+	}
+	if !utf8.Valid(data) {
+		return "", errors.New("not valid UTF-8")
+	}
+	return string(data), nil
+}
+
+// decodeUTF16 decodes UTF-16 text without its byte order mark
+func decodeUTF16(data []byte, order binary.ByteOrder, truncated bool) (string, error) {
+	if len(data)%2 != 0 {
+		if !truncated {
+			return "", errors.New("odd length for UTF-16")
+		}
+		data = data[:len(data)-1]
+	}
+
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[2*i:])
+	}
+	if truncated && len(units) > 0 && utf16.IsSurrogate(rune(units[len(units)-1])) {
+		units = units[:len(units)-1]
+	}
+
+	runes := utf16.Decode(units)
+	for _, r := range runes {
+		if r == 0 {
+			return "", errors.New("contains NUL characters")
+		}
+	}
+	return string(runes), nil
+}
+
+// trimPartialRune drops an incomplete UTF-8 sequence from the end of data
+func trimPartialRune(data []byte) []byte {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return data[:i]
+			}
+			break
+		}
+	}
+	return data
+}
+
+// assignExtractedText records the text of a plain-text upload on its document
+func assignExtractedText(doc *models.Document, text *extractedText) {
+	if text == nil {
+		return
+	}
+
+	doc.TextExtracted = true
+	doc.TextTruncated = text.Truncated
+	doc.ExtractedText = text.Content
+
+	mediaType := strings.SplitN(doc.FileType, ";", 2)[0]
+	if mediaType == "" || mediaType == "application/octet-stream" {
+		doc.FileType = plainTextTypes[strings.ToLower(filepath.Ext(doc.FileName))]
+	}
+}
+
+// canQueue reports whether a document can wait for the RAG service: only
+// uploads whose whole text was kept can be ingested later
+func canQueue(doc *models.Document) bool {
+	return doc.TextExtracted && !doc.TextTruncated
+}
+
+// retryableIngestError reports whether an ingestion failed because the RAG
+// service was unreachable or overloaded, rather than rejecting the document
+func retryableIngestError(err error) bool {
+	return errors.Is(err, ErrRAGUnavailable) || errors.Is(err, ErrTimeout) ||
+		errors.Is(err, ErrUpstreamRateLimited) || errors.Is(err, ErrDegraded)
+}
+
+// queueIngestion puts a processing document whose ingestion failed with a
+// retryable error back in the queue, if its text was kept. It reports
+// whether the document was queued.
+func (s *DocumentService) queueIngestion(docID uint, cause error) bool {
+	if !retryableIngestError(cause) {
+		return false
+	}
+	return s.requeue(docID, cause)
+}
+
+// requeue moves a processing document whose text was kept back to the queue
+func (s *DocumentService) requeue(docID uint, cause error) bool {
+	result := db.DB.Model(&models.Document{}).
+		Where("id = ? AND status = ? AND text_extracted AND NOT text_truncated", docID, "processing").
+		Updates(map[string]interface{}{
+			"status":           "queued",
+			"progress_percent": nil,
+			"chunks_processed": 0,
+			"chunks_total":     0,
+		})
+	if result.Error != nil {
+		logrus.WithError(result.Error).WithField("doc_id", docID).Error("Failed to queue document")
+		return false
+	}
+	if result.RowsAffected == 0 {
+		return false
+	}
+
+	logrus.WithError(cause).WithField("doc_id", docID).Warn("Document queued until the RAG service is available")
+	return true
+}
+
+// StartQueue ingests queued documents every DOCUMENT_QUEUE_INTERVAL while the
+// RAG service is available, until ctx is cancelled
+func (s *DocumentService) StartQueue(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(time.Duration(s.cfg.DocumentQueueIntervalS) * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if s.health.RAGAvailable() && !s.lifecycle.Stopping() {
+				s.drainQueue(ctx)
+			}
+		}
+	}()
+}
+
+// drainQueue ingests a batch of queued documents, oldest first. A retryable
+// failure puts the document back and ends the pass; other failures are
+// dead-lettered.
+func (s *DocumentService) drainQueue(ctx context.Context) {
+	var ids []uint
+	err := db.DB.WithContext(ctx).Model(&models.Document{}).
+		Where("status = ?", "queued").
+		Order("id").
+		Limit(textQueueBatchSize).
+		Pluck("id", &ids).Error
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to list queued documents")
+		return
+	}
+
+	for _, id := range ids {
+		// Claim the document so other instances don't ingest it too
+		result := db.DB.WithContext(ctx).Model(&models.Document{}).
+			Where("id = ? AND status = ?", id, "queued").
+			Update("status", "processing")
+		if result.Error != nil {
+			logrus.WithError(result.Error).WithField("doc_id", id).Warn("Failed to claim queued document")
+			return
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		if !s.ingestQueued(ctx, id) {
+			return
+		}
+	}
+}
+
+// ingestQueued sends a claimed document's kept text to the RAG service. It
+// returns false if the RAG service is unavailable again.
+func (s *DocumentService) ingestQueued(ctx context.Context, docID uint) bool {
+	var doc models.Document
+	if err := db.DB.WithContext(ctx).Preload("Collection").First(&doc, docID).Error; err != nil {
+		logrus.WithError(err).WithField("doc_id", docID).Warn("Failed to load queued document")
+		return true
+	}
+
+	fields := map[string]string{"visibility": doc.Visibility}
+	if doc.Collection != nil {
+		fields["collection"] = doc.Collection.Name
+	}
+	addIngestOptionFields(fields, doc.IngestOptions)
+
+	ingestResp, err := s.ingestWithProgress(ctx, doc.ID, RAGIngestRequest{
+		FileName: doc.FileName,
+		Content:  strings.NewReader(doc.ExtractedText),
+		Fields:   fields,
+	})
+	if err != nil {
+		if ctx.Err() != nil || retryableIngestError(err) {
+			s.requeue(doc.ID, err)
+			return false
+		}
+		err = s.failIngestion(doc.ID, doc.FileName, IngestErrorFailed, err, "Failed to ingest queued document")
+		deadletter.Record(ctx, deadletter.OperationTextIngest, textIngestPayload{DocumentID: doc.ID}, 1, err)
+		return true
+	}
+
+	s.completeIngestion(ctx, doc.ID, doc.FileName, ingestResp)
+	return true
+}
+
+// textIngestPayload is the dead-letter payload of a failed queued ingestion
+type textIngestPayload struct {
+	DocumentID uint `json:"document_id"`
+}
+
+// ReplayTextIngest queues a dead-lettered document again; it must still be
+// marked failed and have its whole text kept
+func (s *DocumentService) ReplayTextIngest(ctx context.Context, payload json.RawMessage) error {
+	var p textIngestPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return fmt.Errorf("%w: invalid payload: %v", deadletter.ErrNotReplayable, err)
+	}
+
+	var doc models.Document
+	if err := db.DB.WithContext(ctx).First(&doc, p.DocumentID).Error; err != nil {
+		return replayLookupError("document", p.DocumentID, err)
+	}
+	if doc.Status != "failed" {
+		return fmt.Errorf("%w: document %d is %s", deadletter.ErrNotReplayable, doc.ID, doc.Status)
+	}
+	if !canQueue(&doc) {
+		return fmt.Errorf("%w: document %d has no kept text", deadletter.ErrNotReplayable, doc.ID)
+	}
+
+	err := db.DB.WithContext(ctx).Model(&doc).Updates(map[string]interface{}{
+		"status":           "queued",
+		"error_code":       "",
+		"error_message":    "",
+		"progress_percent": nil,
+		"chunks_processed": 0,
+		"chunks_total":     0,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to reset document: %w", err)
+	}
+	return nil
+}
+
+// GetDocumentPreview returns the text extracted from a plain-text upload.
+// Internal documents are only previewed for the agent audience; to anyone
+// else they don't exist.
+func (s *DocumentService) GetDocumentPreview(ctx context.Context, id uint, audience string) (*models.DocumentPreview, error) {
+	var document models.Document
+	if err := db.DB.WithContext(ctx).First(&document, id).Error; err != nil {
+		return nil, notFoundError("document", err)
+	}
+	if document.Visibility == models.VisibilityInternal && audience != models.AudienceAgent {
+		return nil, fmt.Errorf("document %w", ErrNotFound)
+	}
+	if !document.TextExtracted {
+		return nil, fmt.Errorf("document preview %w: only txt, md, csv and json uploads have one", ErrNotFound)
+	}
+
+	return &models.DocumentPreview{
+		DocumentID: document.ID,
+		FileName:   document.FileName,
+		FileType:   document.FileType,
+		Status:     document.Status,
+		Text:       document.ExtractedText,
+		Truncated:  document.TextTruncated,
+	}, nil
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+)
+
+// previewDocuments answers document lookups with a public upload (ID 1) and
+// an internal one (ID 2), both with extracted text
+func previewDocuments(query string, args []driver.NamedValue) (*fakeRows, error) {
+	if !strings.Contains(query, `FROM "documents"`) {
+		return nil, nil
+	}
+	visibility := map[int64]string{1: models.VisibilityPublic, 2: models.VisibilityInternal}[args[0].Value.(int64)]
+	if visibility == "" {
+		return nil, nil
+	}
+	return &fakeRows{
+		columns: []string{"id", "file_name", "file_type", "status", "visibility", "text_extracted", "extracted_text"},
+		values:  [][]driver.Value{{args[0].Value, "returns.md", "md", "completed", visibility, true, "Refunds take 5 days."}},
+	}, nil
+}
+
+func TestGetDocumentPreviewVisibility(t *testing.T) {
+	useFakeDB(t, previewDocuments)
+	s := &DocumentService{}
+
+	tests := []struct {
+		name     string
+		id       uint
+		audience string
+		visible  bool
+	}{
+		{"public to customers", 1, models.AudienceCustomer, true},
+		{"public to agents", 1, models.AudienceAgent, true},
+		{"internal hidden from customers", 2, models.AudienceCustomer, false},
+		{"internal hidden without an audience", 2, "", false},
+		{"internal to agents", 2, models.AudienceAgent, true},
+		{"missing", 3, models.AudienceAgent, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preview, err := s.GetDocumentPreview(context.Background(), tt.id, tt.audience)
+			if !tt.visible {
+				if !errors.Is(err, ErrNotFound) {
+					t.Errorf("error = %v, want ErrNotFound", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetDocumentPreview: %v", err)
+			}
+			if preview.Text != "Refunds take 5 days." {
+				t.Errorf("preview text = %q", preview.Text)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// maxDocumentKeyLength matches the document_key column
+const maxDocumentKeyLength = 200
+
+var (
+	// versionSuffixPattern matches version or copy markers at the end of a
+	// file name, e.g. "-v2", "_v1.3" or " (1)"
+	versionSuffixPattern = regexp.MustCompile(`[\s._-]*(v\d+(\.\d+)*|\(\d+\))$`)
+
+	documentKeySeparatorPattern = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// NormalizeDocumentKey lowercases a key and collapses everything other than
+// letters and digits to single dashes
+func NormalizeDocumentKey(key string) string {
+	key = strings.Trim(documentKeySeparatorPattern.ReplaceAllString(strings.ToLower(key), "-"), "-")
+	if len(key) > maxDocumentKeyLength {
+		key = strings.TrimRight(key[:maxDocumentKeyLength], "-")
+	}
+	return key
+}
+
+// documentKeyFromFileName derives a key from a file name without its
+// extension and version marker, so "pricing-v2.pdf" is a version of "pricing.pdf"
+func documentKeyFromFileName(fileName string) string {
+	name := strings.ToLower(strings.TrimSpace(fileName))
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	if stripped := versionSuffixPattern.ReplaceAllString(name, ""); stripped != "" {
+		name = stripped
+	}
+	return NormalizeDocumentKey(name)
+}
+
+// inCollection scopes a document query to a collection, or to documents
+// outside any collection
+func inCollection(query *gorm.DB, collectionID *uint) *gorm.DB {
+	if collectionID == nil {
+		return query.Where("collection_id IS NULL")
+	}
+	return query.Where("collection_id = ?", *collectionID)
+}
+
+// assignVersion sets a new document's key, normalized or derived from its
+// file name, and the next version under that key
+func assignVersion(doc *models.Document, documentKey string) error {
+	if documentKey != "" {
+		doc.DocumentKey = NormalizeDocumentKey(documentKey)
+		if doc.DocumentKey == "" {
+			return validationError("invalid document key %q: use letters and digits", documentKey)
+		}
+	} else {
+		doc.DocumentKey = documentKeyFromFileName(doc.FileName)
+	}
+
+	if doc.DocumentKey == "" {
+		return nil
+	}
+	version, err := nextDocumentVersion(doc.DocumentKey, doc.CollectionID)
+	if err != nil {
+		return err
+	}
+	doc.Version = version
+	return nil
+}
+
+// nextDocumentVersion returns the version a new upload with the key gets: one
+// past the latest version that didn't fail
+func nextDocumentVersion(key string, collectionID *uint) (int, error) {
+	var latest int
+	err := inCollection(db.DB.Model(&models.Document{}), collectionID).
+		Where("document_key = ? AND status <> ?", key, "failed").
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&latest).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to get document version: %w", err)
+	}
+	return latest + 1, nil
+}
+
+// supersedeVersions runs once a versioned document has ingested: completed
+// older versions are marked superseded and their vectors removed, only now so
+// retrieval never has a gap. If a newer version completed first, this one is
+// superseded instead.
+func (s *DocumentService) supersedeVersions(ctx context.Context, docID uint) {
+	var doc models.Document
+	if err := db.DB.First(&doc, docID).Error; err != nil {
+		logrus.WithError(err).WithField("doc_id", docID).Error("Failed to load document for supersession")
+		return
+	}
+	if doc.DocumentKey == "" {
+		return
+	}
+
+	siblings := func() *gorm.DB {
+		return inCollection(db.DB.Model(&models.Document{}), doc.CollectionID).
+			Where("document_key = ? AND status = ? AND id <> ?", doc.DocumentKey, "completed", doc.ID)
+	}
+
+	var newer models.Document
+	err := siblings().Where("version > ?", doc.Version).Order("version DESC").First(&newer).Error
+	if err == nil {
+		s.supersede(ctx, []models.Document{doc}, newer.ID)
+		return
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithError(err).WithField("doc_id", doc.ID).Error("Failed to look up newer document versions")
+		return
+	}
+
+	var older []models.Document
+	if err := siblings().Where("version < ?", doc.Version).Find(&older).Error; err != nil {
+		logrus.WithError(err).WithField("doc_id", doc.ID).Error("Failed to look up older document versions")
+		return
+	}
+	s.supersede(ctx, older, doc.ID)
+}
+
+// supersede marks documents as superseded by another and asks the RAG service
+// to drop their chunks. A failed removal is logged; the document stays hidden.
+func (s *DocumentService) supersede(ctx context.Context, documents []models.Document, supersededBy uint) {
+	for _, document := range documents {
+		logger := logrus.WithFields(logrus.Fields{
+			"doc_id":        document.ID,
+			"document_key":  document.DocumentKey,
+			"version":       document.Version,
+			"superseded_by": supersededBy,
+		})
+
+		err := db.DB.Model(&models.Document{}).Where("id = ?", document.ID).Updates(map[string]interface{}{
+			"status":           "superseded",
+			"superseded_by_id": supersededBy,
+		}).Error
+		if err != nil {
+			logger.WithError(err).Error("Failed to mark document as superseded")
+			continue
+		}
+		logger.Info("Document superseded by a newer version")
+
+		if document.VectorStoreID == "" {
+			continue
+		}
+		if err := s.transport.DeleteDocument(ctx, document.VectorStoreID); err != nil {
+			logger.WithError(err).WithField("vector_store_id", document.VectorStoreID).Error("Failed to remove vectors of superseded document")
+		}
+	}
+}
+
+// GetDocumentVersions returns every version of a document's logical
+// document, newest first
+func (s *DocumentService) GetDocumentVersions(ctx context.Context, id uint) ([]models.Document, error) {
+	document, err := s.GetDocumentByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if document.DocumentKey == "" {
+		return []models.Document{*document}, nil
+	}
+
+	var versions []models.Document
+	err = inCollection(db.DB.Preload("Collection"), document.CollectionID).
+		Where("document_key = ?", document.DocumentKey).
+		Order("version DESC, id DESC").
+		Find(&versions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document versions: %w", err)
+	}
+
+	return versions, nil
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ai-support-assistant/backend/internal/cache"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// validVisibility reports whether v is a document visibility
+func validVisibility(v string) bool {
+	return v == models.VisibilityPublic || v == models.VisibilityInternal
+}
+
+// assignVisibility sets a new document's visibility. Without one, a new
+// version keeps its previous version's visibility, so re-uploading an
+// internal runbook doesn't publish it; other documents are public.
+func assignVisibility(doc *models.Document, visibility string) error {
+	if visibility != "" {
+		if !validVisibility(visibility) {
+			return validationError("invalid visibility %q: use public or internal", visibility)
+		}
+		doc.Visibility = visibility
+		return nil
+	}
+
+	doc.Visibility = models.VisibilityPublic
+	if doc.DocumentKey == "" {
+		return nil
+	}
+
+	var previous models.Document
+	err := inCollection(db.DB.Select("visibility"), doc.CollectionID).
+		Where("document_key = ?", doc.DocumentKey).
+		Order("version DESC, id DESC").
+		First(&previous).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get previous document visibility: %w", err)
+	}
+	if previous.Visibility != "" {
+		doc.Visibility = previous.Visibility
+	}
+	return nil
+}
+
+// changeVisibility retags an ingested document's chunks in the RAG service
+// and then records the new visibility. Documents still being ingested are
+// refused, since their chunks are tagged with the visibility they started with.
+func (s *DocumentService) changeVisibility(ctx context.Context, document *models.Document, visibility string) error {
+	if document.Status == "processing" {
+		return fmt.Errorf("%w: visibility can't change while the document is being ingested", ErrInvalidRequest)
+	}
+
+	if document.VectorStoreID != "" {
+		if err := s.transport.UpdateDocument(ctx, document.VectorStoreID, map[string]string{"visibility": visibility}); err != nil {
+			return fmt.Errorf("failed to update document visibility in the RAG service: %w", err)
+		}
+	}
+
+	if err := db.DB.Model(document).Update("visibility", visibility).Error; err != nil {
+		return fmt.Errorf("failed to update document: %w", err)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"doc_id":     document.ID,
+		"visibility": visibility,
+	}).Info("Document visibility changed")
+
+	// Cached answers don't record the documents they drew on, so any of them
+	// may quote a document that is now internal
+	if visibility == models.VisibilityInternal {
+		evictQueryCache(ctx)
+	}
+	return nil
+}
+
+// evictQueryCache drops every cached query answer. Failures are logged; the
+// entries then expire with their TTL.
+func evictQueryCache(ctx context.Context) {
+	if cache.Client == nil {
+		return
+	}
+
+	keys, err := cache.ScanKeys(ctx, queryCachePattern)
+	if err == nil {
+		err = cache.DeleteKeys(ctx, keys)
+	}
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to evict cached answers")
+		return
+	}
+	logrus.WithField("keys", len(keys)).Info("Evicted cached answers")
+}
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ai-support-assistant/backend/internal/crypto"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// queryHash returns the digest used to group identical queries without
+// reading their (possibly encrypted) text
+func queryHash(query string) string {
+	return crypto.Active().Hash(normalizeQuery(query))
+}
+
+// encryptedQueryRow is a chat_queries row read without the encrypted serializer
+type encryptedQueryRow struct {
+	ID        uint
+	Query     string
+	QueryHash string
+	Response  string
+	Context   string
+}
+
+// encryptedFeedbackRow is a feedbacks row read without the encrypted serializer
+type encryptedFeedbackRow struct {
+	ID      uint
+	Comment string
+}
+
+// EncryptExistingRows encrypts conversation content written as plaintext or
+// under a previous key, and recomputes query hashes, in batches of batchSize
+// rows. Without an encryption key it only backfills query hashes. It returns
+// the number of chat query and feedback rows updated.
+func EncryptExistingRows(ctx context.Context, batchSize int) (int, int, error) {
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	c := crypto.Active()
+
+	queries, err := migrateChatQueries(ctx, c, batchSize)
+	if err != nil {
+		return queries, 0, err
+	}
+
+	if c == nil {
+		return queries, 0, nil
+	}
+
+	feedback, err := migrateFeedbackComments(ctx, c, batchSize)
+	return queries, feedback, err
+}
+
+// migrateChatQueries encrypts chat query content and recomputes query hashes
+func migrateChatQueries(ctx context.Context, c *crypto.Cipher, batchSize int) (int, error) {
+	updated := 0
+	var lastID uint
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return updated, err
+		}
+
+		var rows []encryptedQueryRow
+		err := db.DB.Table("chat_queries").
+			Select("id, query, query_hash, response, context").
+			Where("id > ?", lastID).
+			Order("id ASC").
+			Limit(batchSize).
+			Scan(&rows).Error
+		if err != nil {
+			return updated, fmt.Errorf("failed to read chat queries: %w", err)
+		}
+		if len(rows) == 0 {
+			return updated, nil
+		}
+
+		err = db.DB.Transaction(func(tx *gorm.DB) error {
+			for _, row := range rows {
+				updates := map[string]interface{}{}
+
+				query, err := reencrypt(c, row.Query, "query", updates)
+				if err != nil {
+					return fmt.Errorf("chat query %d: %w", row.ID, err)
+				}
+				if _, err := reencrypt(c, row.Response, "response", updates); err != nil {
+					return fmt.Errorf("chat query %d: %w", row.ID, err)
+				}
+				if _, err := reencrypt(c, row.Context, "context", updates); err != nil {
+					return fmt.Errorf("chat query %d: %w", row.ID, err)
+				}
+
+				if hash := queryHash(query); hash != row.QueryHash {
+					updates["query_hash"] = hash
+				}
+
+				// The search vector holds plaintext terms; encrypted rows aren't searchable
+				if _, encrypted := updates["query"]; encrypted {
+					updates["search_vector"] = nil
+				}
+
+				if len(updates) == 0 {
+					continue
+				}
+				if err := tx.Table("chat_queries").Where("id = ?", row.ID).UpdateColumns(updates).Error; err != nil {
+					return fmt.Errorf("failed to update chat query %d: %w", row.ID, err)
+				}
+				updated++
+			}
+			return nil
+		})
+		if err != nil {
+			return updated, err
+		}
+
+		lastID = rows[len(rows)-1].ID
+		logrus.WithFields(logrus.Fields{
+			"last_id": lastID,
+			"updated": updated,
+		}).Info("Encrypted chat query batch")
+	}
+}
+
+// migrateFeedbackComments encrypts feedback comments
+func migrateFeedbackComments(ctx context.Context, c *crypto.Cipher, batchSize int) (int, error) {
+	updated := 0
+	var lastID uint
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return updated, err
+		}
+
+		var rows []encryptedFeedbackRow
+		err := db.DB.Table("feedbacks").
+			Select("id, comment").
+			Where("id > ?", lastID).
+			Order("id ASC").
+			Limit(batchSize).
+			Scan(&rows).Error
+		if err != nil {
+			return updated, fmt.Errorf("failed to read feedback: %w", err)
+		}
+		if len(rows) == 0 {
+			return updated, nil
+		}
+
+		err = db.DB.Transaction(func(tx *gorm.DB) error {
+			for _, row := range rows {
+				updates := map[string]interface{}{}
+				if _, err := reencrypt(c, row.Comment, "comment", updates); err != nil {
+					return fmt.Errorf("feedback %d: %w", row.ID, err)
+				}
+
+				if len(updates) == 0 {
+					continue
+				}
+				if err := tx.Table("feedbacks").Where("id = ?", row.ID).UpdateColumns(updates).Error; err != nil {
+					return fmt.Errorf("failed to update feedback %d: %w", row.ID, err)
+				}
+				updated++
+			}
+			return nil
+		})
+		if err != nil {
+			return updated, err
+		}
+
+		lastID = rows[len(rows)-1].ID
+		logrus.WithFields(logrus.Fields{
+			"last_id": lastID,
+			"updated": updated,
+		}).Info("Encrypted feedback batch")
+	}
+}
+
+// reencrypt decrypts a stored value and, when it is plaintext or under an old
+// key, records its encryption under the primary key in updates. It returns
+// the plaintext.
+func reencrypt(c *crypto.Cipher, stored, column string, updates map[string]interface{}) (string, error) {
+	if c == nil {
+		if crypto.IsEncrypted(stored) {
+			return "", fmt.Errorf("%s is encrypted but ENCRYPTION_KEY is not set", column)
+		}
+		return stored, nil
+	}
+
+	plaintext, err := c.Decrypt(stored)
+	if err != nil {
+		return "", err
+	}
+
+	if c.NeedsReencryption(stored) {
+		encrypted, err := c.Encrypt(plaintext)
+		if err != nil {
+			return "", err
+		}
+		updates[column] = encrypted
+	}
+
+	return plaintext, nil
+}
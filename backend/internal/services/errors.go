@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Sentinel errors returned by services; handlers map them to HTTP statuses
+var (
+	ErrRAGUnavailable      = errors.New("rag service unavailable")
+	ErrRAGBadRequest       = errors.New("rag service rejected request")
+	ErrTimeout             = errors.New("operation timed out")
+	ErrNotFound            = errors.New("not found")
+	ErrValidation          = errors.New("validation failed")
+	ErrInvalidRequest      = errors.New("invalid request")
+	ErrForbidden           = errors.New("forbidden")
+	ErrOverloaded          = errors.New("service overloaded")
+	ErrDegraded            = errors.New("service degraded")
+	ErrBusy                = errors.New("system busy")
+	ErrUpstreamRateLimited = errors.New("rag service rate limited")
+	ErrLimitExceeded       = errors.New("limit exceeded")
+	ErrPromptTooLong       = errors.New("prompt too long")
+	ErrConflict            = errors.New("conflict")
+	ErrQuotaExceeded       = errors.New("quota exceeded")
+	ErrMalwareDetected     = errors.New("malware detected")
+	ErrScanUnavailable     = errors.New("malware scanner unavailable")
+	ErrNotYetVisible       = errors.New("not yet visible")
+)
+
+// DegradedError is returned instead of calling the RAG service while it is marked unavailable
+type DegradedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *DegradedError) Error() string {
+	return fmt.Sprintf("rag service unavailable, retry after %s", e.RetryAfter)
+}
+
+func (e *DegradedError) Unwrap() error {
+	return ErrDegraded
+}
+
+// GenerationTimeoutError is returned when a RAG call times out while its
+// answer may still be generating; asking again with the generation ID picks
+// the generation up instead of starting over
+type GenerationTimeoutError struct {
+	GenerationID string
+	RetryAfter   time.Duration
+	Err          error
+}
+
+func (e *GenerationTimeoutError) Error() string {
+	return fmt.Sprintf("%v (generation %s)", e.Err, e.GenerationID)
+}
+
+func (e *GenerationTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// BusyError is returned when a request is shed because too many RAG calls are
+// already running or queued
+type BusyError struct {
+	RetryAfter time.Duration
+	QueueDepth int
+}
+
+func (e *BusyError) Error() string {
+	return fmt.Sprintf("rag capacity exhausted with %d queued, retry after %s", e.QueueDepth, e.RetryAfter)
+}
+
+func (e *BusyError) Unwrap() error {
+	return ErrBusy
+}
+
+// UpstreamRateLimitError is returned when the RAG service keeps answering
+// 429 and retrying would overrun the request deadline
+type UpstreamRateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *UpstreamRateLimitError) Error() string {
+	return fmt.Sprintf("rag service rate limited, retry after %s", e.RetryAfter)
+}
+
+func (e *UpstreamRateLimitError) Unwrap() error {
+	return ErrUpstreamRateLimited
+}
+
+// PromptTooLongError is returned instead of calling the RAG service when the
+// estimated prompt exceeds the model's context window
+type PromptTooLongError struct {
+	Estimated int
+	Limit     int
+	// TrimChars is roughly how many characters of the query to remove
+	TrimChars int
+}
+
+func (e *PromptTooLongError) Error() string {
+	return fmt.Sprintf("prompt estimated at %d tokens exceeds the %d token limit", e.Estimated, e.Limit)
+}
+
+func (e *PromptTooLongError) Unwrap() error {
+	return ErrPromptTooLong
+}
+
+// Excess returns how many tokens over the limit the prompt is
+func (e *PromptTooLongError) Excess() int {
+	return e.Estimated - e.Limit
+}
+
+// RAGError describes a non-OK response from the RAG service
+type RAGError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // from a 429's Retry-After, when given
+}
+
+func (e *RAGError) Error() string {
+	return fmt.Sprintf("RAG service returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// Unwrap classifies the response as rate limited (429), a bad request (other
+// 4xx) or unavailability (5xx)
+func (e *RAGError) Unwrap() error {
+	if e.StatusCode == http.StatusTooManyRequests {
+		return ErrUpstreamRateLimited
+	}
+	if e.StatusCode >= 400 && e.StatusCode < 500 {
+		return ErrRAGBadRequest
+	}
+	return ErrRAGUnavailable
+}
+
+// validationError wraps a validation message with ErrValidation
+func validationError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrValidation, fmt.Sprintf(format, args...))
+}
+
+// notFoundError wraps a lookup error with ErrNotFound when the record doesn't exist
+func notFoundError(what string, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%s %w", what, ErrNotFound)
+	}
+	return fmt.Errorf("failed to get %s: %w", what, err)
+}
+
+// transportError classifies an HTTP client error as a timeout, cancellation or unavailability
+func transportError(ctx context.Context, err error) error {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return ctx.Err()
+	}
+
+	var timeout interface{ Timeout() bool }
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &timeout) && timeout.Timeout()) {
+		return fmt.Errorf("%w: %v", ErrTimeout, err)
+	}
+
+	return fmt.Errorf("%w: %v", ErrRAGUnavailable, err)
+}
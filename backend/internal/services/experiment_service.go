@@ -0,0 +1,401 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// experimentRefresh bounds how stale the in-memory experiment list can get across instances
+const experimentRefresh = 30 * time.Second
+
+// ExperimentAssignment is the experiment variant a session is routed to
+type ExperimentAssignment struct {
+	ExperimentID   uint
+	ExperimentName string
+	Variant        models.ExperimentVariant
+
+	// Prompt overrides the active prompt template; nil keeps it
+	Prompt *models.PromptTemplate
+}
+
+// CacheNamespace identifies the assignment in response cache keys
+func (a *ExperimentAssignment) CacheNamespace() string {
+	if a == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d:%s", a.ExperimentID, a.Variant.Name)
+}
+
+// loadedExperiment is an enabled experiment with its variant prompts resolved
+type loadedExperiment struct {
+	experiment  models.Experiment
+	prompts     map[uint]*models.PromptTemplate // by variant ID
+	totalWeight int
+}
+
+type ExperimentService struct {
+	cfg     *config.Config
+	prompts *PromptService
+
+	mu          sync.RWMutex
+	experiments []loadedExperiment
+	loadedAt    time.Time
+}
+
+func NewExperimentService(cfg *config.Config, prompts *PromptService) *ExperimentService {
+	return &ExperimentService{cfg: cfg, prompts: prompts}
+}
+
+// Assign returns the variant for a session, or nil when no running experiment enrolls it.
+// Assignment hashes the session ID, so a session keeps its variant across requests as
+// long as the experiment's traffic share and variants are unchanged. When several
+// experiments are running, the oldest one enrolling the session wins.
+func (s *ExperimentService) Assign(ctx context.Context, sessionID string) *ExperimentAssignment {
+	experiments, err := s.load(ctx)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to load experiments")
+		return nil
+	}
+
+	now := time.Now()
+	for i := range experiments {
+		loaded := &experiments[i]
+		exp := loaded.experiment
+
+		if exp.StartsAt != nil && now.Before(*exp.StartsAt) {
+			continue
+		}
+		if exp.EndsAt != nil && !now.Before(*exp.EndsAt) {
+			continue
+		}
+		if loaded.totalWeight == 0 {
+			continue
+		}
+
+		// Enrollment and variant choice use independent hashes so the traffic
+		// share doesn't skew the variant split
+		if bucket(exp.Name+":enroll:"+sessionID, 100) >= uint64(exp.TrafficPercent) {
+			continue
+		}
+
+		pick := bucket(exp.Name+":variant:"+sessionID, uint64(loaded.totalWeight))
+		for _, variant := range exp.Variants {
+			if pick < uint64(variant.Weight) {
+				return &ExperimentAssignment{
+					ExperimentID:   exp.ID,
+					ExperimentName: exp.Name,
+					Variant:        variant,
+					Prompt:         loaded.prompts[variant.ID],
+				}
+			}
+			pick -= uint64(variant.Weight)
+		}
+	}
+
+	return nil
+}
+
+// CreateExperiment saves a new experiment with its variants
+func (s *ExperimentService) CreateExperiment(ctx context.Context, req models.ExperimentRequest) (*models.Experiment, error) {
+	if err := s.validate(ctx, req, 0); err != nil {
+		return nil, err
+	}
+
+	experiment := models.Experiment{Enabled: true, TrafficPercent: 100}
+	applyExperimentRequest(&experiment, req)
+
+	if err := db.DB.Create(&experiment).Error; err != nil {
+		return nil, fmt.Errorf("failed to save experiment: %w", err)
+	}
+
+	s.invalidate()
+	return &experiment, nil
+}
+
+// GetExperiments returns all experiments with their variants
+func (s *ExperimentService) GetExperiments(ctx context.Context) ([]models.Experiment, error) {
+	var experiments []models.Experiment
+
+	if err := db.DB.Preload("Variants").Order("id ASC").Find(&experiments).Error; err != nil {
+		return nil, fmt.Errorf("failed to get experiments: %w", err)
+	}
+
+	return experiments, nil
+}
+
+// GetExperimentByID returns an experiment with its variants
+func (s *ExperimentService) GetExperimentByID(ctx context.Context, id uint) (*models.Experiment, error) {
+	var experiment models.Experiment
+
+	if err := db.DB.Preload("Variants").First(&experiment, id).Error; err != nil {
+		return nil, notFoundError("experiment", err)
+	}
+
+	return &experiment, nil
+}
+
+// UpdateExperiment updates an experiment, replacing its variants. Changing the
+// traffic share or variants reassigns some sessions.
+func (s *ExperimentService) UpdateExperiment(ctx context.Context, id uint, req models.ExperimentRequest) (*models.Experiment, error) {
+	if err := s.validate(ctx, req, id); err != nil {
+		return nil, err
+	}
+
+	experiment, err := s.GetExperimentByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	applyExperimentRequest(experiment, req)
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("experiment_id = ?", id).Delete(&models.ExperimentVariant{}).Error; err != nil {
+			return err
+		}
+		return tx.Save(experiment).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update experiment: %w", err)
+	}
+
+	s.invalidate()
+	return experiment, nil
+}
+
+// DeleteExperiment deletes an experiment and its variants. Queries keep their recorded variant.
+func (s *ExperimentService) DeleteExperiment(ctx context.Context, id uint) error {
+	if _, err := s.GetExperimentByID(ctx, id); err != nil {
+		return err
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("experiment_id = ?", id).Delete(&models.ExperimentVariant{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Experiment{}, id).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete experiment: %w", err)
+	}
+
+	s.invalidate()
+	return nil
+}
+
+// GetResults aggregates query count, feedback rate, latency and token cost per variant
+func (s *ExperimentService) GetResults(ctx context.Context, id uint) (*models.ExperimentResults, error) {
+	experiment, err := s.GetExperimentByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Variant       string
+		QueryCount    int64
+		FeedbackCount int64
+		PositiveCount int64
+		AvgLatencyMs  float64
+		TokensUsed    int64
+	}
+
+	// Feedback is aggregated per query first so a query's tokens and latency
+	// are counted once however much feedback it received
+	feedback := db.DB.Model(&models.Feedback{}).
+		Select("query_id, COUNT(*) AS feedback_count, SUM(CASE WHEN score = 1 THEN 1 ELSE 0 END) AS positive_count").
+		Group("query_id")
+
+	err = db.DB.Model(&models.ChatQuery{}).
+		Select(`chat_queries.experiment_variant AS variant,
+			COUNT(*) AS query_count,
+			COALESCE(SUM(f.feedback_count), 0) AS feedback_count,
+			COALESCE(SUM(f.positive_count), 0) AS positive_count,
+			COALESCE(AVG(chat_queries.latency_ms), 0) AS avg_latency_ms,
+			COALESCE(SUM(chat_queries.tokens_used), 0) AS tokens_used`).
+		Joins("LEFT JOIN (?) AS f ON f.query_id = chat_queries.id", feedback).
+		Where("chat_queries.experiment_id = ?", id).
+		Group("chat_queries.experiment_variant").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get experiment results: %w", err)
+	}
+
+	byVariant := make(map[string]int, len(rows))
+	for i, row := range rows {
+		byVariant[row.Variant] = i
+	}
+
+	// Every current variant is listed, plus any removed variants that still have queries
+	results := &models.ExperimentResults{ExperimentID: experiment.ID, Name: experiment.Name}
+	seen := make(map[string]bool)
+	addResult := func(name string, costPer1K float64) {
+		result := models.ExperimentVariantResult{Variant: name}
+		if i, ok := byVariant[name]; ok {
+			row := rows[i]
+			result.QueryCount = row.QueryCount
+			result.FeedbackCount = row.FeedbackCount
+			result.PositiveCount = row.PositiveCount
+			result.AvgLatencyMs = row.AvgLatencyMs
+			result.TokensUsed = row.TokensUsed
+		}
+		if result.FeedbackCount > 0 {
+			result.PositiveRate = float64(result.PositiveCount) / float64(result.FeedbackCount) * 100
+		}
+		if costPer1K == 0 {
+			costPer1K = s.cfg.CostPer1KTokens
+		}
+		result.EstimatedCost = float64(result.TokensUsed) / 1000 * costPer1K
+		results.Variants = append(results.Variants, result)
+		seen[name] = true
+	}
+
+	for _, variant := range experiment.Variants {
+		addResult(variant.Name, variant.CostPer1KTokens)
+	}
+	for _, row := range rows {
+		if !seen[row.Variant] {
+			addResult(row.Variant, 0)
+		}
+	}
+
+	return results, nil
+}
+
+// validate checks an experiment request beyond what binding validates.
+// excludeID is the experiment being updated, or 0 when creating.
+func (s *ExperimentService) validate(ctx context.Context, req models.ExperimentRequest, excludeID uint) error {
+	var count int64
+	query := db.DB.Model(&models.Experiment{}).Where("name = ?", req.Name)
+	if excludeID != 0 {
+		query = query.Where("id <> ?", excludeID)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check experiment: %w", err)
+	}
+	if count > 0 {
+		return validationError("experiment %q already exists", req.Name)
+	}
+
+	if req.StartsAt != nil && req.EndsAt != nil && !req.EndsAt.After(*req.StartsAt) {
+		return validationError("ends_at must be after starts_at")
+	}
+
+	names := make(map[string]bool, len(req.Variants))
+	for _, variant := range req.Variants {
+		if names[variant.Name] {
+			return validationError("duplicate variant name %q", variant.Name)
+		}
+		names[variant.Name] = true
+
+		if variant.PromptTemplate == "" {
+			continue
+		}
+		if variant.PromptVersion == 0 {
+			return validationError("variant %q must set prompt_version with prompt_template", variant.Name)
+		}
+		if _, err := s.prompts.GetPromptTemplateByVersion(ctx, variant.PromptTemplate, variant.PromptVersion); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return validationError("variant %q: prompt template %q version %d does not exist", variant.Name, variant.PromptTemplate, variant.PromptVersion)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// load returns the enabled experiments, refreshing from the database when stale
+func (s *ExperimentService) load(ctx context.Context) ([]loadedExperiment, error) {
+	s.mu.RLock()
+	if !s.loadedAt.IsZero() && time.Since(s.loadedAt) < experimentRefresh {
+		experiments := s.experiments
+		s.mu.RUnlock()
+		return experiments, nil
+	}
+	s.mu.RUnlock()
+
+	var rows []models.Experiment
+	err := db.DB.Preload("Variants", func(tx *gorm.DB) *gorm.DB {
+		return tx.Order("id ASC")
+	}).Where("enabled = ? AND traffic_percent > 0", true).Order("id ASC").Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	experiments := make([]loadedExperiment, 0, len(rows))
+	for _, row := range rows {
+		loaded := loadedExperiment{experiment: row, prompts: make(map[uint]*models.PromptTemplate)}
+		for _, variant := range row.Variants {
+			loaded.totalWeight += variant.Weight
+			if variant.PromptTemplate == "" {
+				continue
+			}
+			prompt, err := s.prompts.GetPromptTemplateByVersion(ctx, variant.PromptTemplate, variant.PromptVersion)
+			if err != nil {
+				logrus.WithError(err).WithFields(logrus.Fields{
+					"experiment": row.Name,
+					"variant":    variant.Name,
+				}).Warn("Experiment variant prompt not found, using the active prompt")
+				continue
+			}
+			loaded.prompts[variant.ID] = prompt
+		}
+		experiments = append(experiments, loaded)
+	}
+
+	s.mu.Lock()
+	s.experiments = experiments
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+
+	return experiments, nil
+}
+
+// invalidate forces the next Assign to reload from the database
+func (s *ExperimentService) invalidate() {
+	s.mu.Lock()
+	s.loadedAt = time.Time{}
+	s.mu.Unlock()
+}
+
+// applyExperimentRequest copies request fields onto an experiment, replacing its variants
+func applyExperimentRequest(experiment *models.Experiment, req models.ExperimentRequest) {
+	experiment.Name = req.Name
+	experiment.Description = req.Description
+	if req.TrafficPercent != nil {
+		experiment.TrafficPercent = *req.TrafficPercent
+	}
+	if req.Enabled != nil {
+		experiment.Enabled = *req.Enabled
+	}
+	experiment.StartsAt = req.StartsAt
+	experiment.EndsAt = req.EndsAt
+
+	experiment.Variants = make([]models.ExperimentVariant, 0, len(req.Variants))
+	for _, variant := range req.Variants {
+		experiment.Variants = append(experiment.Variants, models.ExperimentVariant{
+			Name:            variant.Name,
+			Weight:          variant.Weight,
+			Model:           variant.Model,
+			PromptTemplate:  variant.PromptTemplate,
+			PromptVersion:   variant.PromptVersion,
+			CostPer1KTokens: variant.CostPer1KTokens,
+		})
+	}
+}
+
+// bucket hashes key into [0, n)
+func bucket(key string, n uint64) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64() % n
+}
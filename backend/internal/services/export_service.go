@@ -0,0 +1,193 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// Transcript export formats
+const (
+	ExportJSON     = "json"
+	ExportMarkdown = "markdown"
+	ExportText     = "txt"
+)
+
+// IsValidExportFormat returns true if the transcript format is supported
+func IsValidExportFormat(format string) bool {
+	return format == ExportJSON || format == ExportMarkdown || format == ExportText
+}
+
+type ExportService struct {
+	cfg *config.Config
+}
+
+func NewExportService(cfg *config.Config) *ExportService {
+	return &ExportService{cfg: cfg}
+}
+
+// GetSessionTranscript loads a session's queries for export, checking ownership
+// when auth is enabled and enforcing the maximum export size. A non-nil
+// segment limits the export to that conversation segment.
+func (s *ExportService) GetSessionTranscript(ctx context.Context, sessionID, requesterID string, segment *int) ([]models.ChatQuery, error) {
+	scope := func(tx *gorm.DB) *gorm.DB {
+		tx = tx.Where("session_id = ?", sessionID)
+		if segment != nil {
+			tx = tx.Where("segment = ?", *segment)
+		}
+		return tx
+	}
+
+	var count int64
+	if err := db.DB.Model(&models.ChatQuery{}).Scopes(scope).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to count session queries: %w", err)
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("session %w", ErrNotFound)
+	}
+	if s.cfg.ExportMaxQueries > 0 && count > int64(s.cfg.ExportMaxQueries) {
+		return nil, validationError("session has %d messages, exceeding the export limit of %d", count, s.cfg.ExportMaxQueries)
+	}
+
+	if s.cfg.AuthEnabled {
+		if requesterID == "" {
+			return nil, fmt.Errorf("%w: authentication required", ErrForbidden)
+		}
+		var foreign int64
+		if err := db.DB.Model(&models.ChatQuery{}).Where("session_id = ? AND user_id <> ?", sessionID, requesterID).Count(&foreign).Error; err != nil {
+			return nil, fmt.Errorf("failed to check session owner: %w", err)
+		}
+		if foreign > 0 {
+			return nil, fmt.Errorf("%w: session belongs to another user", ErrForbidden)
+		}
+	}
+
+	var queries []models.ChatQuery
+	if err := db.DB.Scopes(scope).Order("created_at ASC").Find(&queries).Error; err != nil {
+		return nil, fmt.Errorf("failed to get session queries: %w", err)
+	}
+
+	return queries, nil
+}
+
+// WriteTranscript renders queries in the given format. Retrieved context is
+// redacted unless includeSources is set.
+func WriteTranscript(w io.Writer, format string, queries []models.ChatQuery, includeSources bool) error {
+	buf := bufio.NewWriter(w)
+
+	var err error
+	switch format {
+	case ExportJSON:
+		err = writeJSONTranscript(buf, queries, includeSources)
+	case ExportText:
+		err = writeTextTranscript(buf, queries, includeSources, false)
+	default:
+		err = writeTextTranscript(buf, queries, includeSources, true)
+	}
+	if err != nil {
+		return err
+	}
+
+	return buf.Flush()
+}
+
+// writeJSONTranscript writes the raw rows as a JSON array, one row at a time
+func writeJSONTranscript(w io.Writer, queries []models.ChatQuery, includeSources bool) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+
+	for i, query := range queries {
+		if !includeSources {
+			query.Context = ""
+		}
+		if i > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		data, err := json.Marshal(query)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w, "]")
+	return err
+}
+
+// writeTextTranscript writes User/Assistant turns as markdown or plain text
+func writeTextTranscript(w io.Writer, queries []models.ChatQuery, includeSources, markdown bool) error {
+	if len(queries) > 0 {
+		title := fmt.Sprintf("Conversation %s", queries[0].SessionID)
+		if markdown {
+			title = "# " + title
+		}
+		if _, err := fmt.Fprintf(w, "%s\n\n", title); err != nil {
+			return err
+		}
+	}
+
+	for i, query := range queries {
+		// Mark where a new conversation segment started after an idle pause
+		if i > 0 && query.Segment != queries[i-1].Segment {
+			heading := "--- New conversation ---"
+			if markdown {
+				heading = "## New conversation"
+			}
+			if _, err := fmt.Fprintf(w, "%s\n\n", heading); err != nil {
+				return err
+			}
+		}
+
+		timestamp := query.CreatedAt.UTC().Format(time.RFC3339)
+		userLabel, assistantLabel := "User:", "Assistant:"
+		if markdown {
+			userLabel, assistantLabel = "**User:**", "**Assistant:**"
+		}
+
+		if _, err := fmt.Fprintf(w, "[%s] %s %s\n\n%s %s\n\n", timestamp, userLabel, query.Query, assistantLabel, query.Response); err != nil {
+			return err
+		}
+
+		if includeSources {
+			var sources []string
+			if err := json.Unmarshal([]byte(query.Context), &sources); err == nil && len(sources) > 0 {
+				heading := "Sources:"
+				if markdown {
+					heading = "_Sources:_"
+				}
+				if _, err := fmt.Fprintf(w, "%s\n", heading); err != nil {
+					return err
+				}
+				for _, source := range sources {
+					if _, err := fmt.Fprintf(w, "- %s\n", source); err != nil {
+						return err
+					}
+				}
+				if _, err := io.WriteString(w, "\n"); err != nil {
+					return err
+				}
+			}
+		}
+
+		if markdown {
+			if _, err := io.WriteString(w, "---\n\n"); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,5 @@
+package services
+
+// NewPipelineTestService lets tests in services_test, which can import the
+// handlers built on this package, run queries through a faked pipeline
+var NewPipelineTestService = newPipelineTestService
@@ -0,0 +1,573 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/cache"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/go-redis/redis/v8"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeRows is the result of a statement run against the fake database
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+// fakeQueryFunc answers a statement; a nil result is an empty one
+type fakeQueryFunc func(query string, args []driver.NamedValue) (*fakeRows, error)
+
+// fakeDB is a database/sql driver answering statements with a fakeQueryFunc
+// and recording them, so gorm code can run without a database
+type fakeDB struct {
+	mu         sync.Mutex
+	answer     fakeQueryFunc
+	statements []string
+}
+
+var (
+	fakeDBOnce   sync.Once
+	fakeDBDriver = &fakeDB{}
+)
+
+// useFakeDB points db.DB at a Postgres-dialect connection answered by
+// answer until the test ends
+func useFakeDB(t *testing.T, answer fakeQueryFunc) *fakeDB {
+	t.Helper()
+	fakeDBOnce.Do(func() { sql.Register("services-fake", fakeDBDriver) })
+	fakeDBDriver.mu.Lock()
+	fakeDBDriver.answer, fakeDBDriver.statements = answer, nil
+	fakeDBDriver.mu.Unlock()
+
+	conn, err := sql.Open("services-fake", "")
+	if err != nil {
+		t.Fatalf("failed to open fake database: %v", err)
+	}
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open gorm: %v", err)
+	}
+
+	previous := db.DB
+	db.DB = gormDB
+	t.Cleanup(func() {
+		db.DB = previous
+		conn.Close()
+	})
+	return fakeDBDriver
+}
+
+// log returns the statements run so far, one per line
+func (f *fakeDB) log() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return strings.Join(f.statements, "\n")
+}
+
+func (f *fakeDB) run(query string, args []driver.NamedValue) (*fakeRows, error) {
+	f.mu.Lock()
+	f.statements = append(f.statements, query)
+	answer := f.answer
+	f.mu.Unlock()
+
+	if answer == nil {
+		return nil, nil
+	}
+	return answer(query, args)
+}
+
+func (f *fakeDB) Open(string) (driver.Conn, error) { return fakeConn{f}, nil }
+
+type fakeConn struct{ f *fakeDB }
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+func (c fakeConn) Close() error              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+func (c fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if _, err := c.f.run(query, args); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+func (c fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := c.f.run(query, args)
+	if err != nil {
+		return nil, err
+	}
+	if rows == nil {
+		rows = &fakeRows{}
+	}
+	return &fakeRowsCursor{rows: rows}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRowsCursor struct {
+	rows *fakeRows
+	next int
+}
+
+func (r *fakeRowsCursor) Columns() []string { return r.rows.columns }
+func (r *fakeRowsCursor) Close() error      { return nil }
+func (r *fakeRowsCursor) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows.values) {
+		return io.EOF
+	}
+	copy(dest, r.rows.values[r.next])
+	r.next++
+	return nil
+}
+
+// fakeRedis is an in-memory Redis speaking enough of the protocol for the
+// string and hash commands the cache package uses, and running its decaying
+// counter script natively
+type fakeRedis struct {
+	mu      sync.Mutex
+	values  map[string]string
+	hashes  map[string]map[string]string
+	expires map[string]time.Time
+}
+
+// useFakeRedis points cache.Client at an in-memory Redis until the test ends
+func useFakeRedis(t *testing.T) *fakeRedis {
+	t.Helper()
+	f := &fakeRedis{values: make(map[string]string), hashes: make(map[string]map[string]string), expires: make(map[string]time.Time)}
+	client := redis.NewClient(&redis.Options{
+		Dialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			server, conn := net.Pipe()
+			go f.serve(server)
+			return conn, nil
+		},
+	})
+
+	previous := cache.Client
+	cache.Client = client
+	t.Cleanup(func() {
+		cache.Client = previous
+		client.Close()
+	})
+	return f
+}
+
+// has reports whether key is set and unexpired
+func (f *fakeRedis) has(key string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.lookup(key)
+	return ok
+}
+
+// set stores a raw value without expiry
+func (f *fakeRedis) set(key, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.values[key] = value
+	delete(f.expires, key)
+}
+
+// ttl returns the time left on key, or zero without an expiry
+func (f *fakeRedis) ttl(key string) time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if expiry, ok := f.expires[key]; ok {
+		return time.Until(expiry)
+	}
+	return 0
+}
+
+// setHash stores a hash without expiry
+func (f *fakeRedis) setHash(key string, fields map[string]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.hashes[key] = fields
+	delete(f.expires, key)
+}
+
+func (f *fakeRedis) lookup(key string) (string, bool) {
+	f.expire(key)
+	value, ok := f.values[key]
+	return value, ok
+}
+
+func (f *fakeRedis) lookupHash(key string) map[string]string {
+	f.expire(key)
+	return f.hashes[key]
+}
+
+// expire drops key if its expiry has passed
+func (f *fakeRedis) expire(key string) {
+	if expiry, ok := f.expires[key]; ok && !time.Now().Before(expiry) {
+		delete(f.values, key)
+		delete(f.hashes, key)
+		delete(f.expires, key)
+	}
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		if _, err := io.WriteString(conn, f.execute(args)); err != nil {
+			return
+		}
+	}
+}
+
+// readCommand reads one command sent as an array of bulk strings
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:size])
+	}
+	return args, nil
+}
+
+func (f *fakeRedis) execute(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "GET":
+		if value, ok := f.lookup(args[1]); ok {
+			return bulkReply(value)
+		}
+		return "$-1\r\n"
+	case "SET":
+		key, value := args[1], args[2]
+		var ttl time.Duration
+		nx := false
+		for i := 3; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "EX":
+				seconds, _ := strconv.Atoi(args[i+1])
+				ttl = time.Duration(seconds) * time.Second
+				i++
+			case "PX":
+				ms, _ := strconv.Atoi(args[i+1])
+				ttl = time.Duration(ms) * time.Millisecond
+				i++
+			case "NX":
+				nx = true
+			}
+		}
+		if _, exists := f.lookup(key); nx && exists {
+			return "$-1\r\n"
+		}
+		f.values[key] = value
+		delete(f.expires, key)
+		if ttl > 0 {
+			f.expires[key] = time.Now().Add(ttl)
+		}
+		return "+OK\r\n"
+	case "DEL", "EXISTS":
+		count := 0
+		for _, key := range args[1:] {
+			if _, ok := f.lookup(key); ok {
+				count++
+				if strings.EqualFold(args[0], "DEL") {
+					delete(f.values, key)
+					delete(f.expires, key)
+				}
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", count)
+	case "INCR", "INCRBY":
+		by := 1
+		if len(args) > 2 {
+			by, _ = strconv.Atoi(args[2])
+		}
+		value, _ := f.lookup(args[1])
+		n, _ := strconv.Atoi(value)
+		n += by
+		f.values[args[1]] = strconv.Itoa(n)
+		return fmt.Sprintf(":%d\r\n", n)
+	case "EXPIRE":
+		if _, ok := f.lookup(args[1]); !ok {
+			return ":0\r\n"
+		}
+		seconds, _ := strconv.Atoi(args[2])
+		f.expires[args[1]] = time.Now().Add(time.Duration(seconds) * time.Second)
+		return ":1\r\n"
+	case "HMGET":
+		fields := f.lookupHash(args[1])
+		reply := fmt.Sprintf("*%d\r\n", len(args)-2)
+		for _, field := range args[2:] {
+			if value, ok := fields[field]; ok {
+				reply += bulkReply(value)
+			} else {
+				reply += "$-1\r\n"
+			}
+		}
+		return reply
+	case "HSET":
+		fields := f.lookupHash(args[1])
+		if fields == nil {
+			fields = make(map[string]string)
+			f.hashes[args[1]] = fields
+		}
+		for i := 2; i+1 < len(args); i += 2 {
+			fields[args[i]] = args[i+1]
+		}
+		return fmt.Sprintf(":%d\r\n", (len(args)-2)/2)
+	case "PEXPIRE":
+		if _, ok := f.lookup(args[1]); !ok && f.lookupHash(args[1]) == nil {
+			return ":0\r\n"
+		}
+		ms, _ := strconv.Atoi(args[2])
+		f.expires[args[1]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		return ":1\r\n"
+	case "EVALSHA":
+		// Makes the client send the script itself
+		return "-NOSCRIPT No matching script\r\n"
+	case "EVAL":
+		if strings.Contains(args[1], "score = score + 1") {
+			return f.decayedIncrement(args[3], args[4:])
+		}
+		return "-ERR unknown script\r\n"
+	case "TTL", "PTTL":
+		if _, ok := f.lookup(args[1]); !ok {
+			return ":-2\r\n"
+		}
+		expiry, ok := f.expires[args[1]]
+		if !ok {
+			return ":-1\r\n"
+		}
+		if strings.EqualFold(args[0], "TTL") {
+			return fmt.Sprintf(":%d\r\n", int64(time.Until(expiry).Seconds()))
+		}
+		return fmt.Sprintf(":%d\r\n", time.Until(expiry).Milliseconds())
+	}
+	return "-ERR unknown command '" + args[0] + "'\r\n"
+}
+
+// decayedIncrement does what the cache package's decaying counter script
+// does in Redis
+func (f *fakeRedis) decayedIncrement(key string, args []string) string {
+	now, _ := strconv.ParseFloat(args[0], 64)
+	halfLife, _ := strconv.ParseFloat(args[1], 64)
+	ttl, _ := strconv.Atoi(args[2])
+
+	fields := f.lookupHash(key)
+	score, _ := strconv.ParseFloat(fields["score"], 64)
+	at, err := strconv.ParseFloat(fields["at"], 64)
+	if err != nil {
+		at = now
+	}
+	if now > at {
+		score *= math.Pow(0.5, (now-at)/halfLife)
+	}
+	score++
+
+	value := strconv.FormatFloat(score, 'g', -1, 64)
+	f.hashes[key] = map[string]string{"score": value, "at": strconv.FormatFloat(math.Max(now, at), 'f', 0, 64)}
+	f.expires[key] = time.Now().Add(time.Duration(ttl) * time.Millisecond)
+	return bulkReply(value)
+}
+
+func bulkReply(value string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+}
+
+// memoryTables is a fakeQueryFunc storing rows per table, for tests that
+// write through gorm and read back. It understands the INSERT, UPDATE by
+// ID and SELECT * ... ORDER BY statements gorm generates; WHERE clauses on
+// SELECTs are ignored. IDs are assigned for tables with an id column.
+type memoryTables struct {
+	mu     sync.Mutex
+	tables map[string][]map[string]driver.Value
+	nextID int64
+}
+
+func newMemoryTables() *memoryTables {
+	return &memoryTables{tables: make(map[string][]map[string]driver.Value)}
+}
+
+var (
+	insertPattern      = regexp.MustCompile(`^INSERT INTO "(\w+)" \(([^)]*)\) VALUES (.*?)(?: ON CONFLICT \("(\w+)"\).*?)?(?: RETURNING (.*))?$`)
+	updatePattern      = regexp.MustCompile(`^UPDATE "(\w+)" SET (.*) WHERE .*"?id"? = \$(\d+)$`)
+	selectPattern      = regexp.MustCompile(`^SELECT \* FROM "(\w+)"(?:.*? ORDER BY (.*?))?(?: LIMIT \d+)?$`)
+	assignmentPattern  = regexp.MustCompile(`"(\w+)"=\$(\d+)`)
+	placeholderPattern = regexp.MustCompile(`\$(\d+)`)
+)
+
+// rows returns a copy of a table's rows
+func (m *memoryTables) rows(table string) []map[string]driver.Value {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]map[string]driver.Value(nil), m.tables[table]...)
+}
+
+func (m *memoryTables) answer(query string, args []driver.NamedValue) (*fakeRows, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	arg := func(n string) driver.Value {
+		i, _ := strconv.Atoi(n)
+		return args[i-1].Value
+	}
+
+	if match := insertPattern.FindStringSubmatch(query); match != nil {
+		table, conflictColumn, returning := match[1], match[4], match[5]
+		columns := strings.Split(strings.ReplaceAll(match[2], `"`, ""), ",")
+		placeholders := placeholderPattern.FindAllStringSubmatch(match[3], -1)
+
+		result := &fakeRows{columns: []string{"id"}}
+		for start := 0; start+len(columns) <= len(placeholders); start += len(columns) {
+			row := make(map[string]driver.Value, len(columns)+1)
+			for i, column := range columns {
+				row[column] = arg(placeholders[start+i][1])
+			}
+			if conflictColumn != "" && m.replace(table, conflictColumn, row) {
+				continue
+			}
+			if strings.Contains(returning, `"id"`) {
+				m.nextID++
+				row["id"] = m.nextID
+				result.values = append(result.values, []driver.Value{m.nextID})
+			}
+			m.tables[table] = append(m.tables[table], row)
+		}
+		return result, nil
+	}
+
+	if match := updatePattern.FindStringSubmatch(query); match != nil {
+		id := arg(match[3])
+		for _, row := range m.tables[match[1]] {
+			if row["id"] == id {
+				for _, assignment := range assignmentPattern.FindAllStringSubmatch(match[2], -1) {
+					row[assignment[1]] = arg(assignment[2])
+				}
+			}
+		}
+		return nil, nil
+	}
+
+	if match := selectPattern.FindStringSubmatch(query); match != nil {
+		rows := append([]map[string]driver.Value(nil), m.tables[match[1]]...)
+		if match[2] != "" {
+			sortRows(rows, match[2])
+		}
+		result := &fakeRows{}
+		seen := map[string]bool{}
+		for _, row := range rows {
+			for column := range row {
+				if !seen[column] {
+					seen[column] = true
+					result.columns = append(result.columns, column)
+				}
+			}
+		}
+		for _, row := range rows {
+			values := make([]driver.Value, len(result.columns))
+			for i, column := range result.columns {
+				values[i] = row[column]
+			}
+			result.values = append(result.values, values)
+		}
+		return result, nil
+	}
+
+	if query == "BEGIN" || query == "COMMIT" || query == "ROLLBACK" {
+		return nil, nil
+	}
+	return nil, fmt.Errorf("memoryTables: unsupported statement %q", query)
+}
+
+// replace overwrites the row whose column matches, reporting whether there was one
+func (m *memoryTables) replace(table, column string, row map[string]driver.Value) bool {
+	for i, existing := range m.tables[table] {
+		if existing[column] == row[column] {
+			if id, ok := existing["id"]; ok {
+				row["id"] = id
+			}
+			m.tables[table][i] = row
+			return true
+		}
+	}
+	return false
+}
+
+// sortRows orders rows by an ORDER BY list of columns, each ASC or DESC
+func sortRows(rows []map[string]driver.Value, orderBy string) {
+	terms := strings.Split(orderBy, ",")
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, term := range terms {
+			fields := strings.Fields(term)
+			column := strings.Trim(fields[0], `"`)
+			c := compareValues(rows[i][column], rows[j][column])
+			if len(fields) > 1 && strings.EqualFold(fields[1], "DESC") {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+}
+
+func compareValues(a, b driver.Value) int {
+	switch a := a.(type) {
+	case int64:
+		b, _ := b.(int64)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	case string:
+		b, _ := b.(string)
+		return strings.Compare(a, b)
+	}
+	return 0
+}
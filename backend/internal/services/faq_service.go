@@ -0,0 +1,344 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/audit"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultFAQCategory is the category of entries created without one
+const DefaultFAQCategory = "general"
+
+// faqOrder is the stable order of FAQ entries
+const faqOrder = "category ASC, position ASC, id ASC"
+
+type FAQService struct {
+	cannedAnswers *CannedAnswerService
+}
+
+func NewFAQService(cannedAnswers *CannedAnswerService) *FAQService {
+	return &FAQService{cannedAnswers: cannedAnswers}
+}
+
+// CreateEntry saves a FAQ entry at the end of its category
+func (s *FAQService) CreateEntry(ctx context.Context, req models.FAQEntryRequest) (*models.FAQEntry, error) {
+	entry := models.FAQEntry{SourceQueryIDs: models.UintList{}}
+	if err := applyFAQEntryRequest(&entry, req); err != nil {
+		return nil, err
+	}
+	if err := s.create(ctx, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// CreateEntryFromQuery drafts an unpublished FAQ entry from a user question
+// and the answer it was given
+func (s *FAQService) CreateEntryFromQuery(ctx context.Context, queryID uint) (*models.FAQEntry, error) {
+	var query models.ChatQuery
+	if err := db.DB.WithContext(ctx).Select("id", "query", "response").First(&query, queryID).Error; err != nil {
+		return nil, notFoundError("query", err)
+	}
+
+	entry := models.FAQEntry{
+		Question:       strings.TrimSpace(query.Query),
+		Answer:         strings.TrimSpace(query.Response),
+		Category:       DefaultFAQCategory,
+		SourceQueryIDs: models.UintList{query.ID},
+	}
+	if err := s.create(ctx, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// GetEntries returns every FAQ entry, published or not, in FAQ order
+func (s *FAQService) GetEntries(ctx context.Context) ([]models.FAQEntry, error) {
+	var entries []models.FAQEntry
+	if err := db.DB.WithContext(ctx).Order(faqOrder).Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to get FAQ entries: %w", err)
+	}
+	return entries, nil
+}
+
+// GetEntry returns a FAQ entry by ID
+func (s *FAQService) GetEntry(ctx context.Context, id uint) (*models.FAQEntry, error) {
+	var entry models.FAQEntry
+	if err := db.DB.WithContext(ctx).First(&entry, id).Error; err != nil {
+		return nil, notFoundError("FAQ entry", err)
+	}
+	return &entry, nil
+}
+
+// GetPublished returns the published FAQ grouped by category, categories in
+// alphabetical order and entries in their position
+func (s *FAQService) GetPublished(ctx context.Context) ([]models.FAQCategory, error) {
+	var entries []models.FAQEntry
+	err := db.DB.WithContext(ctx).Select("id", "question", "answer", "category").
+		Where("published = ?", true).Order(faqOrder).Find(&entries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get FAQ: %w", err)
+	}
+
+	categories := []models.FAQCategory{}
+	for _, entry := range entries {
+		if n := len(categories); n == 0 || categories[n-1].Category != entry.Category {
+			categories = append(categories, models.FAQCategory{Category: entry.Category})
+		}
+		last := &categories[len(categories)-1]
+		last.Entries = append(last.Entries, models.FAQItem{
+			ID:       entry.ID,
+			Question: entry.Question,
+			Answer:   entry.Answer,
+		})
+	}
+	return categories, nil
+}
+
+// UpdateEntry updates a FAQ entry. An entry moved to another category goes
+// to the end of it; publishing and unpublishing register and remove its
+// canned answer.
+func (s *FAQService) UpdateEntry(ctx context.Context, id uint, req models.FAQEntryRequest) (*models.FAQEntry, error) {
+	var entry models.FAQEntry
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&entry, id).Error; err != nil {
+			return notFoundError("FAQ entry", err)
+		}
+
+		before := entry
+		if err := applyFAQEntryRequest(&entry, req); err != nil {
+			return err
+		}
+		if entry.Category != before.Category {
+			position, err := nextFAQPosition(tx, entry.Category)
+			if err != nil {
+				return err
+			}
+			entry.Position = position
+		}
+		if err := syncFAQCannedAnswer(tx, &entry); err != nil {
+			return err
+		}
+
+		if err := tx.Save(&entry).Error; err != nil {
+			return fmt.Errorf("failed to update FAQ entry: %w", err)
+		}
+		return audit.Record(ctx, tx, "faq_entry.update", "faq_entry", strconv.FormatUint(uint64(id), 10), before, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.cannedAnswers.invalidate()
+	return &entry, nil
+}
+
+// DeleteEntry deletes a FAQ entry with its canned answer
+func (s *FAQService) DeleteEntry(ctx context.Context, id uint) error {
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var entry models.FAQEntry
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&entry, id).Error; err != nil {
+			return notFoundError("FAQ entry", err)
+		}
+
+		if entry.CannedAnswerID != nil {
+			if err := tx.Delete(&models.CannedAnswer{}, *entry.CannedAnswerID).Error; err != nil {
+				return fmt.Errorf("failed to delete FAQ canned answer: %w", err)
+			}
+		}
+		if err := tx.Delete(&models.FAQEntry{}, id).Error; err != nil {
+			return fmt.Errorf("failed to delete FAQ entry: %w", err)
+		}
+		return audit.Record(ctx, tx, "faq_entry.delete", "faq_entry", strconv.FormatUint(uint64(id), 10), entry, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.cannedAnswers.invalidate()
+	return nil
+}
+
+// Reorder sets the order of a category's entries. The IDs must list every
+// entry of the category exactly once, so no entry is left at a stale position.
+func (s *FAQService) Reorder(ctx context.Context, req models.FAQReorderRequest) ([]models.FAQEntry, error) {
+	category := faqCategoryOrDefault(req.Category)
+
+	var ordered []models.FAQEntry
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var entries []models.FAQEntry
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("category = ?", category).Order("position ASC, id ASC").Find(&entries).Error
+		if err != nil {
+			return fmt.Errorf("failed to get FAQ entries: %w", err)
+		}
+
+		byID := make(map[uint]*models.FAQEntry, len(entries))
+		before := make([]uint, len(entries))
+		for i := range entries {
+			byID[entries[i].ID] = &entries[i]
+			before[i] = entries[i].ID
+		}
+		if len(req.IDs) != len(entries) {
+			return validationError("ids must list all %d entries of category %q", len(entries), category)
+		}
+		seen := make(map[uint]bool, len(req.IDs))
+		for _, id := range req.IDs {
+			if byID[id] == nil {
+				return validationError("FAQ entry %d is not in category %q", id, category)
+			}
+			if seen[id] {
+				return validationError("FAQ entry %d is listed more than once", id)
+			}
+			seen[id] = true
+		}
+
+		for position, id := range req.IDs {
+			entry := byID[id]
+			if entry.Position == position {
+				continue
+			}
+			entry.Position = position
+			if err := tx.Model(entry).Update("position", position).Error; err != nil {
+				return fmt.Errorf("failed to reorder FAQ entries: %w", err)
+			}
+		}
+		for _, id := range req.IDs {
+			ordered = append(ordered, *byID[id])
+		}
+		return audit.Record(ctx, tx, "faq_entry.reorder", "faq_category", category,
+			map[string]interface{}{"ids": before}, map[string]interface{}{"ids": req.IDs})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ordered, nil
+}
+
+// create saves a new entry at the end of its category, registering its
+// canned answer if it is published as one
+func (s *FAQService) create(ctx context.Context, entry *models.FAQEntry) error {
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		position, err := nextFAQPosition(tx, entry.Category)
+		if err != nil {
+			return err
+		}
+		entry.Position = position
+		if err := syncFAQCannedAnswer(tx, entry); err != nil {
+			return err
+		}
+
+		if err := tx.Create(entry).Error; err != nil {
+			return fmt.Errorf("failed to save FAQ entry: %w", err)
+		}
+		return audit.Record(ctx, tx, "faq_entry.create", "faq_entry", strconv.FormatUint(uint64(entry.ID), 10), nil, entry)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.cannedAnswers.invalidate()
+	return nil
+}
+
+// syncFAQCannedAnswer makes an entry's canned answer match it: one answering
+// exact repeats of its question while it is published as a canned answer,
+// none otherwise. It also stamps when the entry was published.
+func syncFAQCannedAnswer(tx *gorm.DB, entry *models.FAQEntry) error {
+	if entry.Published && entry.PublishedAt == nil {
+		now := time.Now().UTC()
+		entry.PublishedAt = &now
+	} else if !entry.Published {
+		entry.PublishedAt = nil
+	}
+
+	if !entry.Published || !entry.AsCannedAnswer {
+		if entry.CannedAnswerID == nil {
+			return nil
+		}
+		if err := tx.Delete(&models.CannedAnswer{}, *entry.CannedAnswerID).Error; err != nil {
+			return fmt.Errorf("failed to remove FAQ canned answer: %w", err)
+		}
+		entry.CannedAnswerID = nil
+		return nil
+	}
+
+	answer := models.CannedAnswer{
+		Pattern:   normalizeQuery(entry.Question),
+		MatchType: MatchExact,
+		Answer:    entry.Answer,
+		Enabled:   true,
+	}
+	if answer.Pattern == "" {
+		return validationError("question must not be empty to be used as a canned answer")
+	}
+	if entry.CannedAnswerID != nil {
+		// The canned answer may have been deleted from under the entry, in
+		// which case it is registered again
+		result := tx.Model(&models.CannedAnswer{}).Where("id = ?", *entry.CannedAnswerID).Updates(map[string]interface{}{
+			"pattern":    answer.Pattern,
+			"match_type": answer.MatchType,
+			"answer":     answer.Answer,
+			"enabled":    true,
+		})
+		if result.Error != nil {
+			return fmt.Errorf("failed to update FAQ canned answer: %w", result.Error)
+		}
+		if result.RowsAffected > 0 {
+			return nil
+		}
+	}
+	if err := tx.Create(&answer).Error; err != nil {
+		return fmt.Errorf("failed to register FAQ canned answer: %w", err)
+	}
+	entry.CannedAnswerID = &answer.ID
+	return nil
+}
+
+// nextFAQPosition returns the position after the last entry of a category
+func nextFAQPosition(tx *gorm.DB, category string) (int, error) {
+	var last *int
+	if err := tx.Model(&models.FAQEntry{}).Where("category = ?", category).Select("MAX(position)").Scan(&last).Error; err != nil {
+		return 0, fmt.Errorf("failed to position FAQ entry: %w", err)
+	}
+	if last == nil {
+		return 0, nil
+	}
+	return *last + 1, nil
+}
+
+// applyFAQEntryRequest copies request fields onto a FAQ entry
+func applyFAQEntryRequest(entry *models.FAQEntry, req models.FAQEntryRequest) error {
+	entry.Question = strings.TrimSpace(req.Question)
+	entry.Answer = strings.TrimSpace(req.Answer)
+	if entry.Question == "" || entry.Answer == "" {
+		return validationError("question and answer must not be empty")
+	}
+	entry.Category = faqCategoryOrDefault(req.Category)
+	if req.SourceQueryIDs != nil {
+		entry.SourceQueryIDs = models.UintList(req.SourceQueryIDs)
+	}
+	if req.Published != nil {
+		entry.Published = *req.Published
+	}
+	if req.AsCannedAnswer != nil {
+		entry.AsCannedAnswer = *req.AsCannedAnswer
+	}
+	return nil
+}
+
+// faqCategoryOrDefault trims a category, defaulting to DefaultFAQCategory
+func faqCategoryOrDefault(category string) string {
+	if category = strings.TrimSpace(category); category == "" {
+		return DefaultFAQCategory
+	}
+	return category
+}
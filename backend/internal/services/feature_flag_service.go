@@ -0,0 +1,313 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/audit"
+	"github.com/ai-support-assistant/backend/internal/cache"
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Features that can be switched off during incidents
+const (
+	FeatureQuery      = "query"
+	FeatureUploads    = "uploads"
+	FeatureStreaming  = "streaming"
+	FeatureDidYouMean = "did_you_mean"
+	FeatureDirect     = "direct_answers"
+)
+
+// featureDescriptions lists every feature flag and what it gates
+var featureDescriptions = map[string]string{
+	FeatureQuery:      "Answering questions: POST /api/query, streamed answers, async query jobs and regeneration",
+	FeatureUploads:    "Adding documents: uploads, URL crawls and object storage ingestion",
+	FeatureStreaming:  "Server-sent event streams, such as streamed answers and document ingestion progress",
+	FeatureDidYouMean: "\"Did you mean\" suggestions on answers to queries that retrieved nothing",
+	FeatureDirect:     "Answering general questions straight from the LLM, skipping retrieval",
+}
+
+// maxFeatureFlagMessageLength matches the message column
+const maxFeatureFlagMessageLength = 500
+
+// featureFlagsKey is the Redis hash holding each flag as a JSON field
+const featureFlagsKey = "feature_flags"
+
+// featureFlagsChannel is the Redis pub/sub channel used to reload flags on all instances
+const featureFlagsChannel = "feature_flags:invalidate"
+
+// FeatureFlagService answers whether features are enabled from an in-process
+// snapshot, so checks on the hot path never reach Redis. The snapshot is
+// reloaded from Redis, falling back to the database, every refresh interval
+// and whenever a flag is flipped.
+type FeatureFlagService struct {
+	refreshInterval time.Duration
+
+	// flags holds only the flags that have been set; a missing flag is enabled
+	flags atomic.Pointer[map[string]models.FeatureFlag]
+}
+
+func NewFeatureFlagService(cfg *config.Config) *FeatureFlagService {
+	s := &FeatureFlagService{
+		refreshInterval: time.Duration(cfg.FeatureFlagRefreshS) * time.Second,
+	}
+	s.flags.Store(&map[string]models.FeatureFlag{})
+	return s
+}
+
+// Start loads the flags and keeps them fresh until ctx is cancelled
+func (s *FeatureFlagService) Start(ctx context.Context) {
+	if err := s.Reload(ctx); err != nil {
+		logrus.WithError(err).Warn("Failed to load feature flags, all features enabled")
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.refreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.Reload(ctx); err != nil {
+					logrus.WithError(err).Warn("Failed to refresh feature flags")
+				}
+			}
+		}
+	}()
+
+	if cache.Client != nil {
+		go s.subscribe(ctx)
+	}
+}
+
+// subscribe reloads the flags whenever another instance flips one
+func (s *FeatureFlagService) subscribe(ctx context.Context) {
+	pubsub, err := cache.Subscribe(ctx, featureFlagsChannel)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to subscribe to feature flag changes, relying on periodic refresh")
+		return
+	}
+	defer pubsub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-pubsub.Channel():
+			if !ok {
+				return
+			}
+			if err := s.Reload(ctx); err != nil {
+				logrus.WithError(err).Warn("Failed to reload feature flags")
+			}
+		}
+	}
+}
+
+// Reload replaces the snapshot with the flags in Redis, or in the database
+// when Redis is unavailable or has lost them. On failure the previous
+// snapshot stays in effect.
+func (s *FeatureFlagService) Reload(ctx context.Context) error {
+	flags, err := loadFlagsFromRedis(ctx)
+	if err != nil || flags == nil {
+		if err != nil && cache.Client != nil {
+			logrus.WithError(err).Debug("Failed to load feature flags from Redis, using the database")
+		}
+		if flags, err = loadFlagsFromDB(ctx); err != nil {
+			return err
+		}
+	}
+
+	previous := *s.flags.Load()
+	for name, flag := range flags {
+		if old, ok := previous[name]; !ok || old.Enabled != flag.Enabled {
+			logrus.WithFields(logrus.Fields{
+				"flag":    name,
+				"enabled": flag.Enabled,
+			}).Info("Feature flag changed")
+		}
+	}
+	s.flags.Store(&flags)
+	return nil
+}
+
+// loadFlagsFromRedis returns the flags stored in Redis, or nil if there are
+// none, e.g. after Redis was flushed
+func loadFlagsFromRedis(ctx context.Context) (map[string]models.FeatureFlag, error) {
+	if cache.Client == nil {
+		return nil, nil
+	}
+
+	fields, err := cache.HashGetAll(ctx, featureFlagsKey)
+	if err != nil || len(fields) == 0 {
+		return nil, err
+	}
+
+	flags := make(map[string]models.FeatureFlag, len(fields))
+	for name, value := range fields {
+		var flag models.FeatureFlag
+		if err := json.Unmarshal([]byte(value), &flag); err != nil {
+			return nil, fmt.Errorf("invalid feature flag %q in Redis: %w", name, err)
+		}
+		flags[name] = flag
+	}
+	return flags, nil
+}
+
+// loadFlagsFromDB returns the flags stored in the database and, if Redis is
+// available, copies them there so other instances find them
+func loadFlagsFromDB(ctx context.Context) (map[string]models.FeatureFlag, error) {
+	var rows []models.FeatureFlag
+	if err := db.DB.WithContext(ctx).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to load feature flags: %w", err)
+	}
+
+	flags := make(map[string]models.FeatureFlag, len(rows))
+	for _, flag := range rows {
+		flags[flag.Name] = flag
+		if cache.Client != nil {
+			if err := cache.HashSet(ctx, featureFlagsKey, flag.Name, flag); err != nil {
+				logrus.WithError(err).WithField("flag", flag.Name).Debug("Failed to copy feature flag to Redis")
+			}
+		}
+	}
+	return flags, nil
+}
+
+// Feature reports whether a feature is enabled and, if not, the operator's
+// message for clients. It only reads the in-process snapshot.
+func (s *FeatureFlagService) Feature(name string) (bool, string) {
+	flag, ok := (*s.flags.Load())[name]
+	if !ok {
+		return true, ""
+	}
+	return flag.Enabled, flag.Message
+}
+
+// GetFlags returns the state of every feature flag
+func (s *FeatureFlagService) GetFlags() []models.FeatureFlagState {
+	flags := *s.flags.Load()
+
+	names := make([]string, 0, len(featureDescriptions))
+	for name := range featureDescriptions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	states := make([]models.FeatureFlagState, 0, len(names))
+	for _, name := range names {
+		flag, ok := flags[name]
+		if !ok {
+			flag = models.FeatureFlag{Name: name, Enabled: true}
+		}
+		states = append(states, models.FeatureFlagState{FeatureFlag: flag, Description: featureDescriptions[name]})
+	}
+	return states
+}
+
+// UpdateFlags flips feature flags, recording each flip in the audit log, and
+// reloads the flags on all instances
+func (s *FeatureFlagService) UpdateFlags(ctx context.Context, updates map[string]models.FeatureFlagUpdate) ([]models.FeatureFlagState, error) {
+	if len(updates) == 0 {
+		return nil, validationError("no flags provided")
+	}
+
+	flags := make([]models.FeatureFlag, 0, len(updates))
+	for name, update := range updates {
+		if _, ok := featureDescriptions[name]; !ok {
+			return nil, validationError("unknown feature flag %q", name)
+		}
+		if update.Enabled == nil {
+			return nil, validationError("%s: enabled is required", name)
+		}
+		if len(update.Message) > maxFeatureFlagMessageLength {
+			return nil, validationError("%s: message must be at most %d characters", name, maxFeatureFlagMessageLength)
+		}
+		flags = append(flags, models.FeatureFlag{
+			Name:      name,
+			Enabled:   *update.Enabled,
+			Message:   strings.TrimSpace(update.Message),
+			UpdatedBy: audit.ActorFrom(ctx).UserID,
+		})
+	}
+
+	previous := *s.flags.Load()
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"enabled", "message", "updated_by", "updated_at"}),
+		}).Create(&flags).Error
+		if err != nil {
+			return fmt.Errorf("failed to save feature flags: %w", err)
+		}
+
+		for _, flag := range flags {
+			before, ok := previous[flag.Name]
+			if !ok {
+				before = models.FeatureFlag{Name: flag.Name, Enabled: true}
+			}
+			if err := audit.Record(ctx, tx, "feature_flag.update", "feature_flag", flag.Name, flagAuditState(before), flagAuditState(flag)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, flag := range flags {
+		logrus.WithFields(logrus.Fields{
+			"flag":       flag.Name,
+			"enabled":    flag.Enabled,
+			"updated_by": flag.UpdatedBy,
+		}).Warn("Feature flag flipped")
+	}
+
+	s.invalidate(ctx, flags)
+	return s.GetFlags(), nil
+}
+
+// flagAuditState is the part of a flag recorded in audit entries
+func flagAuditState(flag models.FeatureFlag) map[string]interface{} {
+	return map[string]interface{}{"enabled": flag.Enabled, "message": flag.Message}
+}
+
+// invalidate writes flipped flags to Redis, reloads the local snapshot and
+// notifies other instances. If Redis can't be updated, its copy is dropped so
+// instances fall back to the database instead of reading a stale flag.
+func (s *FeatureFlagService) invalidate(ctx context.Context, flags []models.FeatureFlag) {
+	if cache.Client != nil {
+		for _, flag := range flags {
+			if err := cache.HashSet(ctx, featureFlagsKey, flag.Name, flag); err != nil {
+				logrus.WithError(err).WithField("flag", flag.Name).Warn("Failed to store feature flag in Redis")
+				if err := cache.Delete(ctx, featureFlagsKey); err != nil {
+					logrus.WithError(err).Error("Failed to drop feature flags from Redis; instances may serve a stale flag")
+				}
+				break
+			}
+		}
+	}
+
+	if err := s.Reload(ctx); err != nil {
+		logrus.WithError(err).Warn("Failed to reload feature flags")
+	}
+
+	if cache.Client != nil {
+		if err := cache.Publish(ctx, featureFlagsChannel, "reload"); err != nil {
+			logrus.WithError(err).Warn("Failed to publish feature flag change")
+		}
+	}
+}
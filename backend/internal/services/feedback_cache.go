@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/cache"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/middleware"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// noCacheKey is the Redis key marking a normalized query as not cacheable
+func noCacheKey(hash string) string {
+	return "query:nocache:" + hash
+}
+
+// evictCachedAnswer drops the cached answer a thumbs-down was given on so the
+// next asker gets a fresh generation, and stops caching the query altogether
+// once it keeps getting negative feedback. Failures are only logged.
+func (s *FeedbackService) evictCachedAnswer(ctx context.Context, query models.ChatQuery) {
+	if cache.Client == nil {
+		return
+	}
+
+	logger := logrus.WithField("query_id", query.ID)
+
+	if query.CacheKey != "" {
+		if err := cache.Delete(ctx, query.CacheKey); err != nil {
+			logger.WithError(err).Warn("Failed to evict cached answer after negative feedback")
+		} else {
+			middleware.RecordCacheFeedbackEviction("query", "evicted")
+			logger.WithField("cache_key", query.CacheKey).Info("Evicted cached answer after negative feedback")
+		}
+	}
+
+	threshold := s.cfg.CacheNoCacheAfterNegative
+	ttl := time.Duration(s.cfg.CacheNoCacheTTLS) * time.Second
+	if threshold <= 0 || ttl <= 0 || query.QueryHash == "" {
+		return
+	}
+
+	var negatives int64
+	err := db.DB.WithContext(ctx).Model(&models.Feedback{}).
+		Joins("JOIN chat_queries ON chat_queries.id = feedbacks.query_id").
+		Where("feedbacks.score = ? AND chat_queries.query_hash = ? AND feedbacks.created_at > ?", -1, query.QueryHash, time.Now().UTC().Add(-ttl)).
+		Count(&negatives).Error
+	if err != nil {
+		logger.WithError(err).Warn("Failed to count negative feedback for query")
+		return
+	}
+	if negatives < int64(threshold) {
+		return
+	}
+
+	if err := cache.Set(ctx, noCacheKey(query.QueryHash), negatives, ttl); err != nil {
+		logger.WithError(err).Warn("Failed to stop caching query after negative feedback")
+		return
+	}
+	middleware.RecordCacheFeedbackEviction("query", "suppressed")
+	logger.WithField("negative_feedback", negatives).Info("Stopped caching query after repeated negative feedback")
+}
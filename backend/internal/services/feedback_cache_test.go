@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/activity"
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/lifecycle"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/notify"
+)
+
+const (
+	ratedCacheKey  = "query:abc123"
+	ratedQueryHash = "hash-1"
+)
+
+// ratedQueryDB answers the statements of submitting feedback on a cached
+// answer, with negatives thumbs-down counted for its query
+func ratedQueryDB(negatives int64) fakeQueryFunc {
+	return func(query string, args []driver.NamedValue) (*fakeRows, error) {
+		switch {
+		case strings.Contains(query, `FROM "chat_queries"`):
+			return &fakeRows{
+				columns: []string{"id", "session_id", "query", "response", "cache_key", "query_hash"},
+				values:  [][]driver.Value{{int64(42), "s1", "How do I reset my password?", "Use the link.", ratedCacheKey, ratedQueryHash}},
+			}, nil
+		case strings.Contains(query, "count("):
+			return &fakeRows{columns: []string{"count"}, values: [][]driver.Value{{negatives}}}, nil
+		case strings.HasPrefix(query, `INSERT INTO "feedbacks"`):
+			return &fakeRows{columns: []string{"id"}, values: [][]driver.Value{{int64(7)}}}, nil
+		}
+		return nil, nil
+	}
+}
+
+func newTestFeedbackService(t *testing.T, noCacheAfter int) *FeedbackService {
+	t.Helper()
+	lc := lifecycle.NewManager()
+	cfg := &config.Config{CacheNoCacheAfterNegative: noCacheAfter, CacheNoCacheTTLS: 3600}
+	return NewFeedbackService(cfg, notify.NewSlackNotifier("", time.Minute, lc), nil, activity.NewBus(), nil, lc)
+}
+
+func TestNegativeFeedbackEvictsCachedAnswer(t *testing.T) {
+	redis := useFakeRedis(t)
+	useFakeDB(t, ratedQueryDB(1))
+	redis.set(ratedCacheKey, `{"response":"Use the link."}`)
+
+	err := newTestFeedbackService(t, 3).SubmitFeedback(context.Background(), models.FeedbackRequest{QueryID: 42, SessionID: "s1", Score: -1})
+	if err != nil {
+		t.Fatalf("SubmitFeedback: %v", err)
+	}
+	if redis.has(ratedCacheKey) {
+		t.Error("cached answer still served after negative feedback")
+	}
+	if redis.has(noCacheKey(ratedQueryHash)) {
+		t.Error("query stopped being cached below the negative feedback threshold")
+	}
+}
+
+func TestPositiveFeedbackKeepsCachedAnswer(t *testing.T) {
+	redis := useFakeRedis(t)
+	useFakeDB(t, ratedQueryDB(0))
+	redis.set(ratedCacheKey, `{"response":"Use the link."}`)
+
+	err := newTestFeedbackService(t, 3).SubmitFeedback(context.Background(), models.FeedbackRequest{QueryID: 42, SessionID: "s1", Score: 1})
+	if err != nil {
+		t.Fatalf("SubmitFeedback: %v", err)
+	}
+	if !redis.has(ratedCacheKey) {
+		t.Error("cached answer evicted after positive feedback")
+	}
+}
+
+func TestRepeatedNegativeFeedbackStopsCaching(t *testing.T) {
+	tests := []struct {
+		name         string
+		noCacheAfter int
+		negatives    int64
+		suppressed   bool
+	}{
+		{"below threshold", 3, 2, false},
+		{"at threshold", 3, 3, true},
+		{"disabled", 0, 10, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redis := useFakeRedis(t)
+			useFakeDB(t, ratedQueryDB(tt.negatives))
+
+			newTestFeedbackService(t, tt.noCacheAfter).evictCachedAnswer(context.Background(), models.ChatQuery{
+				ID:        42,
+				CacheKey:  ratedCacheKey,
+				QueryHash: ratedQueryHash,
+			})
+			if got := redis.has(noCacheKey(ratedQueryHash)); got != tt.suppressed {
+				t.Errorf("query marked not cacheable = %t, want %t", got, tt.suppressed)
+			}
+			if tt.suppressed && redis.ttl(noCacheKey(ratedQueryHash)) <= 0 {
+				t.Error("not cacheable marker has no expiry")
+			}
+		})
+	}
+}
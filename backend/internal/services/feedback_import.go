@@ -0,0 +1,350 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Feedback import formats
+const (
+	FeedbackImportCSV  = "csv"
+	FeedbackImportJSON = "json"
+)
+
+// feedbackImportBatchSize is how many rows are validated and inserted together
+const feedbackImportBatchSize = 500
+
+// maxExternalIDLength matches the external_id column
+const maxExternalIDLength = 255
+
+// importRow is a parsed import row; problem is set when it couldn't be parsed
+type importRow struct {
+	models.FeedbackImportRow
+	number  int
+	problem string
+}
+
+// ImportFeedback imports survey responses as feedback from a CSV file with a
+// header row or a JSON array, reading it as it goes. The rows are applied in
+// one transaction; a row that fails validation or was already imported is
+// reported and left out rather than failing the import. Imported feedback
+// only feeds analytics: nobody is alerted and no cached answer is evicted.
+func (s *FeedbackService) ImportFeedback(ctx context.Context, r io.Reader, format string) (*models.FeedbackImportResult, error) {
+	var next func() (importRow, error)
+	var err error
+	switch format {
+	case FeedbackImportCSV:
+		next, err = csvImportRows(r)
+	case FeedbackImportJSON:
+		next, err = jsonImportRows(r)
+	default:
+		return nil, validationError("unsupported import format %q; use csv or json", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.FeedbackImportResult{Rows: []models.FeedbackImportRowResult{}}
+	err = db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// External IDs imported earlier in this file
+		imported := make(map[string]bool)
+		batch := make([]importRow, 0, feedbackImportBatchSize)
+
+		for number := 1; ; number++ {
+			row, err := next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if number > s.cfg.FeedbackImportMaxRows {
+				return validationError("at most %d rows can be imported at once", s.cfg.FeedbackImportMaxRows)
+			}
+
+			row.number = number
+			batch = append(batch, row)
+			if len(batch) == feedbackImportBatchSize {
+				if err := importBatch(tx, batch, imported, result); err != nil {
+					return err
+				}
+				batch = batch[:0]
+			}
+		}
+		return importBatch(tx, batch, imported, result)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"imported": result.Imported,
+		"skipped":  result.Skipped,
+		"errors":   result.Errors,
+	}).Info("Feedback imported")
+
+	return result, nil
+}
+
+// importBatch validates a batch of rows against the database and inserts the
+// valid ones, adding a result for each row
+func importBatch(tx *gorm.DB, batch []importRow, imported map[string]bool, result *models.FeedbackImportResult) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	var queryIDs []uint
+	var externalIDs []string
+	for _, row := range batch {
+		if row.problem == "" {
+			queryIDs = append(queryIDs, row.QueryID)
+			externalIDs = append(externalIDs, row.ExternalID)
+		}
+	}
+
+	sessions := make(map[uint]string)
+	if len(queryIDs) > 0 {
+		var queries []models.ChatQuery
+		if err := tx.Select("id", "session_id").Where("id IN ?", queryIDs).Find(&queries).Error; err != nil {
+			return fmt.Errorf("failed to look up queries: %w", err)
+		}
+		for _, query := range queries {
+			sessions[query.ID] = query.SessionID
+		}
+	}
+
+	existing := make(map[string]bool)
+	if len(externalIDs) > 0 {
+		var found []string
+		if err := tx.Model(&models.Feedback{}).Where("external_id IN ?", externalIDs).Pluck("external_id", &found).Error; err != nil {
+			return fmt.Errorf("failed to look up imported feedback: %w", err)
+		}
+		for _, id := range found {
+			existing[id] = true
+		}
+	}
+
+	now := time.Now().UTC()
+	var feedbacks []models.Feedback
+	var positions []int
+	for _, row := range batch {
+		rowResult := models.FeedbackImportRowResult{
+			Row:        row.number,
+			QueryID:    row.QueryID,
+			ExternalID: row.ExternalID,
+			Status:     models.FeedbackImportError,
+		}
+		sessionID, queryFound := sessions[row.QueryID]
+
+		switch {
+		case row.problem != "":
+			rowResult.Reason = row.problem
+		case row.ExternalID == "":
+			rowResult.Reason = "external_id is required"
+		case len(row.ExternalID) > maxExternalIDLength:
+			rowResult.Reason = fmt.Sprintf("external_id must be at most %d characters", maxExternalIDLength)
+		case existing[row.ExternalID] || imported[row.ExternalID]:
+			rowResult.Status = models.FeedbackImportSkipped
+			rowResult.Reason = "external_id was already imported"
+		case row.Score != 1 && row.Score != -1:
+			rowResult.Reason = "score must be 1 or -1"
+		case !queryFound:
+			rowResult.Reason = fmt.Sprintf("query %d not found", row.QueryID)
+		case row.SubmittedAt != nil && row.SubmittedAt.After(now):
+			rowResult.Reason = "submitted_at must not be in the future"
+		default:
+			rowResult.Status = models.FeedbackImportImported
+			rowResult.Reason = ""
+			imported[row.ExternalID] = true
+
+			externalID := row.ExternalID
+			feedback := models.Feedback{
+				QueryID:    row.QueryID,
+				SessionID:  sessionID,
+				Score:      row.Score,
+				Comment:    row.Comment,
+				Source:     models.FeedbackSourceImport,
+				ExternalID: &externalID,
+			}
+			if row.SubmittedAt != nil {
+				feedback.CreatedAt = row.SubmittedAt.UTC()
+				feedback.UpdatedAt = feedback.CreatedAt
+			}
+			feedbacks = append(feedbacks, feedback)
+			positions = append(positions, len(result.Rows))
+		}
+
+		switch rowResult.Status {
+		case models.FeedbackImportImported:
+			result.Imported++
+		case models.FeedbackImportSkipped:
+			result.Skipped++
+		default:
+			result.Errors++
+		}
+		result.Rows = append(result.Rows, rowResult)
+	}
+
+	if len(feedbacks) == 0 {
+		return nil
+	}
+	if err := tx.Create(&feedbacks).Error; err != nil {
+		return fmt.Errorf("failed to save imported feedback: %w", err)
+	}
+	for i, feedback := range feedbacks {
+		result.Rows[positions[i]].FeedbackID = feedback.ID
+	}
+	return nil
+}
+
+// MaxImportBytes is the largest feedback import file accepted
+func (s *FeedbackService) MaxImportBytes() int64 {
+	return s.cfg.FeedbackImportMaxBytes
+}
+
+// csvImportRows reads import rows from CSV whose header row names the
+// columns; query_id, score and external_id are required
+func csvImportRows(r io.Reader) (func() (importRow, error), error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, validationError("the import is empty")
+	}
+	if err != nil {
+		return nil, csvImportError(err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, required := range []string{"query_id", "score", "external_id"} {
+		if _, ok := columns[required]; !ok {
+			return nil, validationError("the CSV header must have a %s column", required)
+		}
+	}
+
+	return func() (importRow, error) {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return importRow{}, io.EOF
+		}
+		if err != nil {
+			return importRow{}, csvImportError(err)
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		row := importRow{}
+		row.ExternalID = field("external_id")
+		row.Comment = field("comment")
+
+		queryID, err := strconv.ParseUint(field("query_id"), 10, 32)
+		if err != nil {
+			row.problem = "query_id must be a positive integer"
+			return row, nil
+		}
+		row.QueryID = uint(queryID)
+
+		if row.Score, err = strconv.Atoi(field("score")); err != nil {
+			row.problem = "score must be an integer"
+			return row, nil
+		}
+
+		if value := field("submitted_at"); value != "" {
+			submittedAt, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				row.problem = "submitted_at must be an RFC 3339 timestamp"
+				return row, nil
+			}
+			row.SubmittedAt = &submittedAt
+		}
+		return row, nil
+	}, nil
+}
+
+// csvImportError reports malformed CSV, which ends the import, keeping read
+// errors such as an oversized body wrapped
+func csvImportError(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return validationError("invalid CSV on line %d: %v", parseErr.Line, parseErr.Err)
+	}
+	return fmt.Errorf("failed to read import: %w", err)
+}
+
+// jsonImportRows reads import rows from a JSON array one element at a time.
+// An element of the wrong shape is reported on its row; malformed JSON ends
+// the import.
+func jsonImportRows(r io.Reader) (func() (importRow, error), error) {
+	decoder := json.NewDecoder(r)
+
+	token, err := decoder.Token()
+	if err == io.EOF {
+		return nil, validationError("the import is empty")
+	}
+	if err != nil {
+		return nil, jsonImportError(err)
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return nil, validationError("the import must be a JSON array")
+	}
+
+	done := false
+	return func() (importRow, error) {
+		if done || !decoder.More() {
+			if !done {
+				done = true
+				if _, err := decoder.Token(); err != nil {
+					return importRow{}, jsonImportError(err)
+				}
+			}
+			return importRow{}, io.EOF
+		}
+
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return importRow{}, jsonImportError(err)
+		}
+
+		row := importRow{}
+		if err := json.Unmarshal(raw, &row.FeedbackImportRow); err != nil {
+			row.problem = fmt.Sprintf("invalid row: %v", err)
+		}
+		row.ExternalID = strings.TrimSpace(row.ExternalID)
+		return row, nil
+	}, nil
+}
+
+// jsonImportError reports malformed JSON, which ends the import, keeping read
+// errors such as an oversized body wrapped
+func jsonImportError(err error) error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return validationError("invalid JSON at offset %d: %v", syntaxErr.Offset, err)
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return validationError("the JSON array is incomplete")
+	}
+	return fmt.Errorf("failed to read import: %w", err)
+}
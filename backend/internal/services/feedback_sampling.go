@@ -0,0 +1,31 @@
+package services
+
+import (
+	"strconv"
+
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// requestFeedback decides whether the user is asked to rate an answer and
+// records the decision on its query. A sample of answers, sized by the
+// feedback_sample_percent setting, is picked by hashing the query ID so the
+// decision is stable; always overrides sampling, e.g. for low-confidence
+// answers and regenerations. Synthetic and unsaved queries are never rated.
+func (s *QueryService) requestFeedback(chatQuery *models.ChatQuery, always bool) bool {
+	if chatQuery.ID == 0 || chatQuery.Synthetic {
+		return false
+	}
+
+	sampled := bucket("feedback:"+strconv.FormatUint(uint64(chatQuery.ID), 10), 100) < uint64(s.settings.FeedbackSamplePercent())
+	if !always && !sampled {
+		return false
+	}
+
+	if err := db.DB.Model(chatQuery).Update("feedback_requested", true).Error; err != nil {
+		logrus.WithError(err).WithField("query_id", chatQuery.ID).Warn("Failed to record feedback request")
+	}
+	chatQuery.FeedbackRequested = true
+	return true
+}
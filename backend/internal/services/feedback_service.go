@@ -2,34 +2,78 @@ package services
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/ai-support-assistant/backend/internal/activity"
+	"github.com/ai-support-assistant/backend/internal/config"
 	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/deadletter"
+	"github.com/ai-support-assistant/backend/internal/lifecycle"
+	"github.com/ai-support-assistant/backend/internal/middleware"
 	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/notify"
+	"github.com/ai-support-assistant/backend/internal/ragclient"
+	"github.com/ai-support-assistant/backend/internal/webhook"
 	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
-type FeedbackService struct{}
+// Outcomes of retrying the lookup of a rated query
+const (
+	FeedbackLookupFound         = "found"
+	FeedbackLookupNotYetVisible = "not_yet_visible"
+	FeedbackLookupNotFound      = "not_found"
+)
+
+// feedbackLookupBackoff are the delays between lookups of a rated query that
+// isn't found, for feedback sent before the query's insert is visible
+var feedbackLookupBackoff = []time.Duration{
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+}
+
+// Bad retrieval reports are retried with exponential backoff
+const (
+	retrievalReportAttempts = 4
+	retrievalReportBackoff  = 2 * time.Second
+)
 
-func NewFeedbackService() *FeedbackService {
-	return &FeedbackService{}
+type FeedbackService struct {
+	cfg        *config.Config
+	notifier   *notify.SlackNotifier
+	dispatcher *webhook.Dispatcher
+	feed       *activity.Bus
+	ragClient  *ragclient.Client
+	lifecycle  *lifecycle.Manager
+}
+
+func NewFeedbackService(cfg *config.Config, notifier *notify.SlackNotifier, dispatcher *webhook.Dispatcher, feed *activity.Bus, ragClient *ragclient.Client, lc *lifecycle.Manager) *FeedbackService {
+	return &FeedbackService{cfg: cfg, notifier: notifier, dispatcher: dispatcher, feed: feed, ragClient: ragClient, lifecycle: lc}
 }
 
 // SubmitFeedback saves user feedback
 func (s *FeedbackService) SubmitFeedback(ctx context.Context, req models.FeedbackRequest) error {
-	// Verify query exists
-	var query models.ChatQuery
-	if err := db.DB.First(&query, req.QueryID).Error; err != nil {
-		return fmt.Errorf("query not found: %w", err)
+	query, err := findRatedQuery(ctx, req.QueryID)
+	if err != nil {
+		return err
 	}
 
 	// Create feedback
 	feedback := models.Feedback{
 		QueryID:   req.QueryID,
 		SessionID: req.SessionID,
+		UserID:    query.UserID,
 		Score:     req.Score,
 		Comment:   req.Comment,
 		Tags:      req.Tags,
+		Source:    models.FeedbackSourceUser,
 	}
 
 	if err := db.DB.Create(&feedback).Error; err != nil {
@@ -42,32 +86,275 @@ func (s *FeedbackService) SubmitFeedback(ctx context.Context, req models.Feedbac
 		"session_id": req.SessionID,
 	}).Info("Feedback submitted")
 
+	s.dispatcher.Dispatch(webhook.EventFeedbackCreated, feedback)
+	s.feed.Publish(activity.FeedbackEvent(feedback, query))
+
+	// Alert the support team, stop serving the answer from cache and flag the
+	// retrieved chunks on negative feedback
+	if req.Score == -1 {
+		s.evictCachedAnswer(ctx, query)
+		s.notifier.NotifyNegativeFeedback(query.ID, query.Query, query.Response, req.Comment)
+		s.lifecycle.Go("report_bad_retrieval", logrus.Fields{
+			"feedback_id": feedback.ID,
+			"query_id":    query.ID,
+		}, func(ctx context.Context) {
+			s.reportBadRetrieval(ctx, feedback, query)
+		})
+	}
+
 	return nil
 }
 
-// GetFeedbackStats returns feedback statistics
-func (s *FeedbackService) GetFeedbackStats(ctx context.Context) (map[string]interface{}, error) {
+// findRatedQuery looks up the query feedback is given on. A query not found
+// is looked up again with backoff in case its insert isn't visible yet. If it
+// still isn't found, an ID past the highest saved query may be one still
+// being saved, reported as ErrNotYetVisible; otherwise the query doesn't
+// exist, reported as ErrNotFound.
+func findRatedQuery(ctx context.Context, id uint) (models.ChatQuery, error) {
+	var query models.ChatQuery
+	for attempt := 0; ; attempt++ {
+		err := db.DB.WithContext(ctx).First(&query, id).Error
+		if err == nil {
+			if attempt > 0 {
+				middleware.RecordFeedbackLookupRetry(FeedbackLookupFound)
+			}
+			return query, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return query, notFoundError("query", err)
+		}
+		if attempt == len(feedbackLookupBackoff) {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return query, ctx.Err()
+		case <-time.After(feedbackLookupBackoff[attempt]):
+		}
+	}
+
+	// IDs are assigned in order, so a query past the highest visible one may
+	// still be committing
+	var highest uint
+	if err := db.DB.WithContext(ctx).Model(&models.ChatQuery{}).Select("COALESCE(MAX(id), 0)").Scan(&highest).Error; err != nil {
+		return query, fmt.Errorf("failed to get query: %w", err)
+	}
+	if id > highest {
+		middleware.RecordFeedbackLookupRetry(FeedbackLookupNotYetVisible)
+		return query, fmt.Errorf("query %d %w", id, ErrNotYetVisible)
+	}
+	middleware.RecordFeedbackLookupRetry(FeedbackLookupNotFound)
+	return query, fmt.Errorf("query %w", ErrNotFound)
+}
+
+// GetFeedbackStats returns feedback statistics, leaving out imported survey
+// responses unless includeImported is set; with a segment, only feedback on
+// the segment's queries counts
+func (s *FeedbackService) GetFeedbackStats(ctx context.Context, includeImported bool, segment *models.AnalyticsSegment) (map[string]interface{}, error) {
 	var totalFeedback int64
 	var positiveFeedback int64
 	var negativeFeedback int64
 
-	db.DB.Model(&models.Feedback{}).Count(&totalFeedback)
-	db.DB.Model(&models.Feedback{}).Where("score = ?", 1).Count(&positiveFeedback)
-	db.DB.Model(&models.Feedback{}).Where("score = ?", -1).Count(&negativeFeedback)
+	feedbacks := func() *gorm.DB {
+		scope := db.DB.Model(&models.Feedback{})
+		if !includeImported {
+			scope = scope.Where("source <> ?", models.FeedbackSourceImport)
+		}
+		if segment != nil {
+			scope = scope.Where("query_id IN (?)", segmentQueries(segment).Select("chat_queries.id"))
+		}
+		return scope
+	}
+	feedbacks().Count(&totalFeedback)
+	feedbacks().Where("score = ?", 1).Count(&positiveFeedback)
+	feedbacks().Where("score = ?", -1).Count(&negativeFeedback)
 
 	positiveRate := 0.0
 	if totalFeedback > 0 {
 		positiveRate = float64(positiveFeedback) / float64(totalFeedback) * 100
 	}
 
+	// Response rate: share of answers users were asked to rate that got a
+	// rating, since not every answer asks
+	var feedbackRequested, requestedRated int64
+	db.DB.Model(&models.ChatQuery{}).Scopes(segmentScope(segment)).Where("feedback_requested = ?", true).Count(&feedbackRequested)
+	rated := "EXISTS (SELECT 1 FROM feedbacks WHERE feedbacks.query_id = chat_queries.id)"
+	if !includeImported {
+		rated = "EXISTS (SELECT 1 FROM feedbacks WHERE feedbacks.query_id = chat_queries.id AND feedbacks.source <> 'import')"
+	}
+	db.DB.Model(&models.ChatQuery{}).Scopes(segmentScope(segment)).
+		Where("feedback_requested = ? AND "+rated, true).
+		Count(&requestedRated)
+
+	responseRate := 0.0
+	if feedbackRequested > 0 {
+		responseRate = float64(requestedRated) / float64(feedbackRequested) * 100
+	}
+
 	return map[string]interface{}{
-		"total_feedback":    totalFeedback,
-		"positive_feedback": positiveFeedback,
-		"negative_feedback": negativeFeedback,
-		"positive_rate":     positiveRate,
+		"total_feedback":     totalFeedback,
+		"positive_feedback":  positiveFeedback,
+		"negative_feedback":  negativeFeedback,
+		"positive_rate":      positiveRate,
+		"feedback_requested": feedbackRequested,
+		"response_rate":      responseRate,
 	}, nil
 }
 
+// reportBadRetrieval sends the chunks behind a poorly rated answer to the RAG
+// service, recording each attempt. It runs in the background and only logs failures.
+func (s *FeedbackService) reportBadRetrieval(ctx context.Context, feedback models.Feedback, query models.ChatQuery) {
+	report, chunkIDs, ok := badRetrievalReport(feedback, query)
+	if !ok {
+		return
+	}
+
+	record := models.RetrievalFeedback{
+		FeedbackID: feedback.ID,
+		QueryID:    query.ID,
+		Query:      query.Query,
+		ChunkIDs:   strings.Join(chunkIDs, ","),
+		Status:     "pending",
+	}
+	if err := db.DB.Create(&record).Error; err != nil {
+		logrus.WithError(err).Error("Failed to record retrieval feedback")
+		return
+	}
+
+	s.deliverRetrievalReport(ctx, record, report)
+}
+
+// badRetrievalReport builds the report of the chunks retrieved for a query,
+// returning false if the query retrieved none
+func badRetrievalReport(feedback models.Feedback, query models.ChatQuery) (ragclient.BadRetrievalReport, []string, bool) {
+	var contexts []string
+	if err := json.Unmarshal([]byte(query.Context), &contexts); err != nil || len(contexts) == 0 {
+		return ragclient.BadRetrievalReport{}, nil, false
+	}
+
+	chunks := make([]ragclient.Chunk, len(contexts))
+	chunkIDs := make([]string, len(contexts))
+	for i, text := range contexts {
+		chunks[i] = ragclient.Chunk{ID: ragclient.ChunkID(text), Text: text}
+		chunkIDs[i] = chunks[i].ID
+	}
+
+	return ragclient.BadRetrievalReport{
+		QueryID: query.ID,
+		Query:   query.Query,
+		Chunks:  chunks,
+		Comment: feedback.Comment,
+	}, chunkIDs, true
+}
+
+// deliverRetrievalReport sends a report with exponential backoff, recording
+// each attempt on its record and dead-lettering it once attempts run out
+func (s *FeedbackService) deliverRetrievalReport(ctx context.Context, record models.RetrievalFeedback, report ragclient.BadRetrievalReport) {
+	var err error
+	for attempt := 1; attempt <= retrievalReportAttempts; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+		err = s.ragClient.ReportBadRetrieval(attemptCtx, report)
+		cancel()
+
+		updates := map[string]interface{}{"attempts": attempt, "error": ""}
+		if err == nil {
+			updates["status"] = "delivered"
+			db.DB.Model(&record).Updates(updates)
+			return
+		}
+
+		updates["error"] = err.Error()
+		if attempt == retrievalReportAttempts {
+			updates["status"] = "failed"
+		}
+		db.DB.Model(&record).Updates(updates)
+
+		if attempt < retrievalReportAttempts {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retrievalReportBackoff * time.Duration(1<<uint(attempt-1))):
+			}
+		}
+	}
+
+	logrus.WithError(err).WithFields(logrus.Fields{
+		"query_id":    record.QueryID,
+		"feedback_id": record.FeedbackID,
+	}).Warn("Failed to report bad retrieval, giving up")
+	deadletter.Record(ctx, deadletter.OperationRetrievalReport, retrievalReportPayload{RetrievalFeedbackID: record.ID}, retrievalReportAttempts, err)
+}
+
+// retrievalReportPayload is the dead-letter payload of a failed report; the
+// report is rebuilt from the feedback and query on replay
+type retrievalReportPayload struct {
+	RetrievalFeedbackID uint `json:"retrieval_feedback_id"`
+}
+
+// ReplayRetrievalReport resends a dead-lettered bad retrieval report in the
+// background, starting over at the first attempt
+func (s *FeedbackService) ReplayRetrievalReport(ctx context.Context, payload json.RawMessage) error {
+	var p retrievalReportPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return fmt.Errorf("%w: invalid payload: %v", deadletter.ErrNotReplayable, err)
+	}
+
+	var record models.RetrievalFeedback
+	if err := db.DB.WithContext(ctx).First(&record, p.RetrievalFeedbackID).Error; err != nil {
+		return replayLookupError("retrieval feedback", p.RetrievalFeedbackID, err)
+	}
+	var feedback models.Feedback
+	if err := db.DB.WithContext(ctx).First(&feedback, record.FeedbackID).Error; err != nil {
+		return replayLookupError("feedback", record.FeedbackID, err)
+	}
+	var query models.ChatQuery
+	if err := db.DB.WithContext(ctx).First(&query, record.QueryID).Error; err != nil {
+		return replayLookupError("query", record.QueryID, err)
+	}
+
+	report, _, ok := badRetrievalReport(feedback, query)
+	if !ok {
+		return fmt.Errorf("%w: query %d no longer has retrieved context", deadletter.ErrNotReplayable, query.ID)
+	}
+
+	err := db.DB.WithContext(ctx).Model(&record).Updates(map[string]interface{}{
+		"status":   "pending",
+		"attempts": 0,
+		"error":    "",
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to reset retrieval feedback: %w", err)
+	}
+
+	started := s.lifecycle.Go("report_bad_retrieval", logrus.Fields{
+		"feedback_id": feedback.ID,
+		"query_id":    query.ID,
+	}, func(ctx context.Context) {
+		s.deliverRetrievalReport(ctx, record, report)
+	})
+	if !started {
+		return fmt.Errorf("%w: server is shutting down", ErrOverloaded)
+	}
+	return nil
+}
+
+// GetRetrievalFeedback returns recent bad retrieval reports, optionally filtered by status
+func (s *FeedbackService) GetRetrievalFeedback(ctx context.Context, status string, limit int) ([]models.RetrievalFeedback, error) {
+	var records []models.RetrievalFeedback
+
+	query := db.DB.Order("created_at DESC").Limit(limit)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to get retrieval feedback: %w", err)
+	}
+
+	return records, nil
+}
+
 // GetRecentFeedback returns recent feedback with queries
 func (s *FeedbackService) GetRecentFeedback(ctx context.Context, limit int) ([]models.Feedback, error) {
 	var feedbacks []models.Feedback
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+)
+
+// laggyQueries is a chat_queries table whose inserts only become visible to
+// reads some time after they return, like a lagging replica
+type laggyQueries struct {
+	mu        sync.Mutex
+	nextID    uint
+	visibleAt map[uint]time.Time
+}
+
+func newLaggyQueries() *laggyQueries {
+	return &laggyQueries{visibleAt: make(map[uint]time.Time)}
+}
+
+// insert saves a query visible after lag, returning its ID
+func (l *laggyQueries) insert(lag time.Duration) uint {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.nextID++
+	l.visibleAt[l.nextID] = time.Now().Add(lag)
+	return l.nextID
+}
+
+func (l *laggyQueries) visible(id uint) bool {
+	at, ok := l.visibleAt[id]
+	return ok && !time.Now().Before(at)
+}
+
+func (l *laggyQueries) answer(query string, args []driver.NamedValue) (*fakeRows, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch {
+	case strings.Contains(query, "MAX(id)"):
+		var highest uint
+		for id := range l.visibleAt {
+			if l.visible(id) && id > highest {
+				highest = id
+			}
+		}
+		return &fakeRows{columns: []string{"max"}, values: [][]driver.Value{{int64(highest)}}}, nil
+	case strings.Contains(query, `FROM "chat_queries"`):
+		id := uint(args[0].Value.(int64))
+		if !l.visible(id) {
+			return nil, nil
+		}
+		return &fakeRows{
+			columns: []string{"id", "session_id", "query", "response"},
+			values:  [][]driver.Value{{int64(id), "s1", "Where is my order?", "On its way."}},
+		}, nil
+	case strings.HasPrefix(query, `INSERT INTO "feedbacks"`):
+		return &fakeRows{columns: []string{"id"}, values: [][]driver.Value{{int64(1)}}}, nil
+	}
+	return nil, nil
+}
+
+// useShortFeedbackBackoff speeds up lookups of queries that never appear
+func useShortFeedbackBackoff(t *testing.T) {
+	previous := feedbackLookupBackoff
+	feedbackLookupBackoff = []time.Duration{time.Millisecond, time.Millisecond}
+	t.Cleanup(func() { feedbackLookupBackoff = previous })
+}
+
+func TestFindRatedQueryWaitsForInsert(t *testing.T) {
+	queries := newLaggyQueries()
+	useFakeDB(t, queries.answer)
+	id := queries.insert(30 * time.Millisecond)
+
+	query, err := findRatedQuery(context.Background(), id)
+	if err != nil {
+		t.Fatalf("findRatedQuery: %v", err)
+	}
+	if query.ID != id {
+		t.Errorf("found query %d, want %d", query.ID, id)
+	}
+}
+
+func TestFindRatedQueryMissing(t *testing.T) {
+	useShortFeedbackBackoff(t)
+	queries := newLaggyQueries()
+	useFakeDB(t, queries.answer)
+	queries.insert(0)
+	queries.insert(0)
+	pending := queries.insert(time.Hour)
+
+	// Below the highest visible ID, so it will never appear
+	if _, err := findRatedQuery(context.Background(), 1); err != nil {
+		t.Fatalf("findRatedQuery on a visible query: %v", err)
+	}
+	queries.mu.Lock()
+	delete(queries.visibleAt, 1)
+	queries.mu.Unlock()
+	if _, err := findRatedQuery(context.Background(), 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleted query: error = %v, want ErrNotFound", err)
+	}
+
+	// Past the highest visible ID, so it may still be committing
+	if _, err := findRatedQuery(context.Background(), pending); !errors.Is(err, ErrNotYetVisible) {
+		t.Errorf("pending query: error = %v, want ErrNotYetVisible", err)
+	}
+}
+
+func TestFindRatedQueryStopsWhenCancelled(t *testing.T) {
+	useFakeDB(t, newLaggyQueries().answer)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := findRatedQuery(ctx, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+}
+
+// Feedback sent the moment a query's response returns must be accepted even
+// while its insert isn't yet visible to reads
+func TestFeedbackImmediatelyAfterQuery(t *testing.T) {
+	queries := newLaggyQueries()
+	useFakeDB(t, queries.answer)
+	s := newTestFeedbackService(t, 0)
+
+	const iterations = 300
+	var wg sync.WaitGroup
+	failures := make(chan error, iterations)
+	for i := 0; i < iterations; i++ {
+		wg.Add(1)
+		go func(lag time.Duration) {
+			defer wg.Done()
+			id := queries.insert(lag)
+			err := s.SubmitFeedback(context.Background(), models.FeedbackRequest{QueryID: id, SessionID: "s1", Score: 1})
+			if err != nil {
+				failures <- err
+			}
+		}(time.Duration(rand.Intn(50)) * time.Millisecond)
+	}
+	wg.Wait()
+	close(failures)
+
+	count := 0
+	for err := range failures {
+		if count == 0 {
+			t.Errorf("first failure: %v", err)
+		}
+		count++
+	}
+	if count > 0 {
+		t.Errorf("%d of %d feedback submissions failed, want none", count, iterations)
+	}
+}
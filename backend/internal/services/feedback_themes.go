@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/ragclient"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+	"gorm.io/gorm"
+)
+
+// Feedback comment themes
+const (
+	FeedbackThemeWrongAnswer = "wrong_answer"
+	FeedbackThemeMissingInfo = "missing_info"
+	FeedbackThemeTone        = "tone"
+	FeedbackThemeLatency     = "latency"
+	FeedbackThemeOther       = "other"
+)
+
+// feedbackThemes lists the themes offered to the classifier
+var feedbackThemes = []string{
+	FeedbackThemeWrongAnswer,
+	FeedbackThemeMissingInfo,
+	FeedbackThemeTone,
+	FeedbackThemeLatency,
+	FeedbackThemeOther,
+}
+
+// feedbackClassifyTimeout bounds a single comment classification
+const feedbackClassifyTimeout = 20 * time.Second
+
+// feedbackThemeReserve is the part of a request's deadline kept for counting
+// results after classification stops
+const feedbackThemeReserve = 2 * time.Second
+
+// GetFeedbackThemes classifies pending negative feedback comments created in
+// [from, to), up to the configured budget, and returns comment counts and
+// examples per theme. Comments left unclassified because the budget ran out
+// or the classifier failed are reported as pending and picked up next time.
+func (s *AnalyticsService) GetFeedbackThemes(ctx context.Context, from, to time.Time) (*models.FeedbackThemeReport, error) {
+	s.classifyFeedback(ctx, from, to)
+
+	report := &models.FeedbackThemeReport{From: from, To: to, Themes: []models.FeedbackThemeStat{}}
+
+	var counts []struct {
+		Theme string
+		Count int64
+	}
+	err := negativeComments(db.DB.WithContext(ctx), from, to).
+		Select("theme, COUNT(*) AS count").
+		Group("theme").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count feedback themes: %w", err)
+	}
+
+	byTheme := make(map[string]int64, len(feedbackThemes))
+	for _, row := range counts {
+		report.Total += row.Count
+		if row.Theme == "" {
+			report.PendingClassification = row.Count
+			continue
+		}
+		byTheme[row.Theme] += row.Count
+	}
+
+	for _, theme := range feedbackThemes {
+		stat := models.FeedbackThemeStat{Theme: theme, Count: byTheme[theme], Examples: []models.FeedbackThemeExample{}}
+		if stat.Count > 0 && s.cfg.FeedbackThemeExamples > 0 {
+			// Query through the model so encrypted comments are decrypted
+			var examples []models.Feedback
+			err := negativeComments(db.DB.WithContext(ctx), from, to).
+				Where("theme = ?", theme).
+				Order("created_at DESC").
+				Limit(s.cfg.FeedbackThemeExamples).
+				Find(&examples).Error
+			if err != nil {
+				return nil, fmt.Errorf("failed to load %s examples: %w", theme, err)
+			}
+			for _, fb := range examples {
+				stat.Examples = append(stat.Examples, models.FeedbackThemeExample{
+					FeedbackID: fb.ID,
+					QueryID:    fb.QueryID,
+					Comment:    fb.Comment,
+					CreatedAt:  fb.CreatedAt,
+				})
+			}
+		}
+		report.Themes = append(report.Themes, stat)
+	}
+
+	sort.SliceStable(report.Themes, func(i, j int) bool {
+		return report.Themes[i].Count > report.Themes[j].Count
+	})
+
+	return report, nil
+}
+
+// negativeComments scopes a query to thumbs-down feedback with a comment in [from, to)
+func negativeComments(tx *gorm.DB, from, to time.Time) *gorm.DB {
+	return tx.Model(&models.Feedback{}).
+		Where("score = ? AND comment <> ''", -1).
+		Where("created_at >= ? AND created_at < ?", from, to)
+}
+
+// classifyFeedback classifies one budget of unclassified comments. Requests
+// arriving while a batch is running skip classification rather than spend
+// the budget twice on the same comments.
+func (s *AnalyticsService) classifyFeedback(ctx context.Context, from, to time.Time) {
+	if s.ragClient == nil || s.cfg.FeedbackThemeBudget <= 0 {
+		return
+	}
+	if !s.classifying.TryLock() {
+		return
+	}
+	defer s.classifying.Unlock()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline.Add(-feedbackThemeReserve))
+		defer cancel()
+	}
+
+	var pending []models.Feedback
+	err := negativeComments(db.DB.WithContext(ctx), from, to).
+		Preload("Query").
+		Where("theme = ''").
+		Order("id ASC").
+		Limit(s.cfg.FeedbackThemeBudget).
+		Find(&pending).Error
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to load feedback pending classification")
+		return
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	concurrency := s.cfg.FeedbackThemeConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	var classified, failed int64
+	var g errgroup.Group
+	g.SetLimit(concurrency)
+	for _, fb := range pending {
+		fb := fb
+		g.Go(func() error {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err := s.classifyComment(ctx, fb); err != nil {
+				if ctx.Err() != nil {
+					// Out of time; the comment stays pending
+					return nil
+				}
+				atomic.AddInt64(&failed, 1)
+				logrus.WithError(err).WithField("feedback_id", fb.ID).Warn("Failed to classify feedback comment")
+				return nil
+			}
+			atomic.AddInt64(&classified, 1)
+			return nil
+		})
+	}
+	g.Wait()
+
+	logrus.WithFields(logrus.Fields{
+		"classified": classified,
+		"failed":     failed,
+	}).Info("Classified feedback comments")
+}
+
+// classifyComment asks the RAG service for a comment's theme and stores it.
+// Themes outside the known set are recorded as other.
+func (s *AnalyticsService) classifyComment(ctx context.Context, fb models.Feedback) error {
+	classifyCtx, cancel := context.WithTimeout(ctx, feedbackClassifyTimeout)
+	defer cancel()
+
+	theme, err := s.ragClient.ClassifyFeedback(classifyCtx, ragclient.FeedbackClassification{
+		Comment: fb.Comment,
+		Query:   fb.Query.Query,
+		Themes:  feedbackThemes,
+	})
+	if err != nil {
+		return err
+	}
+	if !isFeedbackTheme(theme) {
+		theme = FeedbackThemeOther
+	}
+
+	// Only the first classification of a comment is kept
+	return db.DB.WithContext(ctx).Model(&models.Feedback{}).
+		Where("id = ? AND theme = ''", fb.ID).
+		UpdateColumns(map[string]interface{}{
+			"theme":         theme,
+			"classified_at": time.Now().UTC(),
+		}).Error
+}
+
+// isFeedbackTheme reports whether theme is one of the known themes
+func isFeedbackTheme(theme string) bool {
+	for _, known := range feedbackThemes {
+		if theme == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/cache"
+	"github.com/ai-support-assistant/backend/internal/middleware"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// generationTTL is how long a generation ID can be used to pick up its answer
+	generationTTL = 10 * time.Minute
+
+	// generationPollInterval is how often a retried query checks on the
+	// generation it picked up
+	generationPollInterval = 500 * time.Millisecond
+
+	// generationFetchTimeout bounds fetching the partial answer of a query
+	// that has already timed out
+	generationFetchTimeout = 2 * time.Second
+
+	// generationRetryAfter is how long a client should give a generation
+	// still running upstream before asking again
+	generationRetryAfter = 5 * time.Second
+)
+
+// generationIDPattern matches the generation IDs handed out to clients
+var generationIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// newGenerationID returns a random generation ID
+func newGenerationID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// generationKey is the cache key recording which question a generation ID
+// was issued for
+func generationKey(generationID string) string {
+	return "generation:" + generationID
+}
+
+// claimGeneration returns the generation ID to send with a query, and
+// whether it names a generation already started upstream. A client's ID is
+// only honored for the question it was issued for, as identified by the
+// in-flight key, so it can't be used to read other answers; otherwise a new
+// ID is issued. Without Redis, IDs can't be checked and are never honored.
+func (s *QueryService) claimGeneration(ctx context.Context, requested string, req RAGQueryRequest) (string, bool) {
+	owner := inflightKey(req)
+
+	if requested != "" && cache.Client != nil {
+		var issuedFor string
+		err := cache.Get(ctx, generationKey(requested), &issuedFor)
+		if err == nil && issuedFor == owner {
+			return requested, true
+		}
+		if err != nil && err != redis.Nil {
+			logrus.WithError(err).Debug("Failed to look up generation ID")
+		}
+	}
+
+	generationID := newGenerationID()
+	if cache.Client != nil {
+		if err := cache.Set(ctx, generationKey(generationID), owner, generationTTL); err != nil {
+			logrus.WithError(err).Debug("Failed to record generation ID")
+		}
+	}
+	return generationID, false
+}
+
+// awaitGeneration waits for a generation started by an earlier request to
+// finish, up to the coalescing timeout. It returns nil if the RAG service
+// doesn't have the generation, so the query is asked afresh.
+func (s *QueryService) awaitGeneration(ctx context.Context, generationID string) (*RAGQueryResponse, error) {
+	waitCtx, cancel := context.WithTimeout(ctx, time.Duration(s.cfg.QueryCoalesceTimeoutS)*time.Second)
+	defer cancel()
+
+	ticker := time.NewTicker(generationPollInterval)
+	defer ticker.Stop()
+
+	for {
+		generation, err := s.transport.Generation(waitCtx, generationID)
+		if err != nil && waitCtx.Err() == nil {
+			if !errors.Is(err, ErrGenerationUnsupported) {
+				logrus.WithError(err).WithField("generation_id", generationID).Warn("Failed to check on generation, asking afresh")
+			}
+			return nil, nil
+		}
+		if err == nil {
+			if generation == nil {
+				return nil, nil
+			}
+			if generation.Done {
+				middleware.RecordGenerationRecovery("attached")
+				resp := generation.RAGQueryResponse
+				resp.Endpoint = RAGEndpointPrimary
+				s.postProcess(ctx, &resp)
+				return &resp, nil
+			}
+		}
+
+		select {
+		case <-waitCtx.Done():
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return nil, ctx.Err()
+			}
+			return nil, generationTimeout(waitCtx.Err(), generationID)
+		case <-ticker.C:
+		}
+	}
+}
+
+// recoverTimeout handles a RAG call that failed. If it timed out while its
+// answer was generating, what was generated so far is served, flagged as
+// partial, unless the client disallowed partial answers; the answer may
+// also have completed in the meantime. Otherwise the error is returned,
+// with the generation ID only while the generation can still be picked up.
+// The request is pointed at the generation that timed out, which is another
+// request's when the call was shared.
+func (s *QueryService) recoverTimeout(ctx context.Context, ragReq *RAGQueryRequest, allowPartial *bool, err error) (*RAGQueryResponse, bool, error) {
+	var timedOut *GenerationTimeoutError
+	if !errors.As(err, &timedOut) {
+		return nil, false, err
+	}
+	ragReq.GenerationID = timedOut.GenerationID
+
+	if allowPartial != nil && !*allowPartial {
+		middleware.RecordGenerationRecovery("disallowed")
+		return nil, false, err
+	}
+
+	fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), generationFetchTimeout)
+	defer cancel()
+
+	generation, fetchErr := s.transport.Generation(fetchCtx, timedOut.GenerationID)
+	if fetchErr != nil && !errors.Is(fetchErr, ErrGenerationUnsupported) {
+		logrus.WithError(fetchErr).WithField("generation_id", timedOut.GenerationID).Warn("Failed to fetch partial answer")
+	}
+	if errors.Is(fetchErr, ErrGenerationUnsupported) || (fetchErr == nil && generation == nil) {
+		// Nothing upstream to pick up
+		middleware.RecordGenerationRecovery("unavailable")
+		return nil, false, timedOut.Err
+	}
+	if fetchErr != nil || strings.TrimSpace(generation.Response) == "" {
+		middleware.RecordGenerationRecovery("unavailable")
+		return nil, false, err
+	}
+
+	resp := generation.RAGQueryResponse
+	resp.Endpoint = RAGEndpointPrimary
+	s.postProcess(fetchCtx, &resp)
+
+	if generation.Done {
+		middleware.RecordGenerationRecovery("completed")
+		return &resp, false, nil
+	}
+
+	middleware.RecordGenerationRecovery("partial_served")
+	logrus.WithFields(logrus.Fields{
+		"generation_id": timedOut.GenerationID,
+		"length":        len(resp.Response),
+	}).Warn("RAG call timed out, serving partial answer")
+	return &resp, true, nil
+}
+
+// generationTimeout tags a timeout with the generation that timed out, so
+// it can be recovered; other errors are returned as they are
+func generationTimeout(err error, generationID string) error {
+	if generationID == "" || !(errors.Is(err, ErrTimeout) || errors.Is(err, context.DeadlineExceeded)) {
+		return err
+	}
+	var timedOut *GenerationTimeoutError
+	if errors.As(err, &timedOut) {
+		return err
+	}
+	return &GenerationTimeoutError{GenerationID: generationID, RetryAfter: generationRetryAfter, Err: err}
+}
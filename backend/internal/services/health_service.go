@@ -0,0 +1,277 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/cache"
+	"github.com/ai-support-assistant/backend/internal/chaos"
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/middleware"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// Service modes
+const (
+	ModeNormal   = "normal"
+	ModeDegraded = "degraded"
+)
+
+// RAG endpoints, as tagged on the queries they serve
+const (
+	RAGEndpointPrimary  = "primary"
+	RAGEndpointFallback = "fallback"
+)
+
+type HealthService struct {
+	cfg      *config.Config
+	limiter  *RAGLimiter
+	upstream *upstreamWindow
+
+	// Each RAG endpoint has its own breaker, driven by the background prober;
+	// fallback is nil unless RAG_SERVICE_FALLBACK_URL is set
+	primary  *endpointHealth
+	fallback *endpointHealth
+
+	// chaos injects db faults into the database check; nil unless CHAOS_MODE is on
+	chaos *chaos.Injector
+}
+
+// endpointHealth is the breaker state of one RAG endpoint
+type endpointHealth struct {
+	name      string
+	transport RAGTransport
+
+	mu       sync.RWMutex
+	degraded bool
+	failures int
+}
+
+func NewHealthService(cfg *config.Config, transport, fallback RAGTransport, limiter *RAGLimiter, injector *chaos.Injector) *HealthService {
+	s := &HealthService{
+		cfg:      cfg,
+		chaos:    injector,
+		limiter:  limiter,
+		upstream: newUpstreamWindow(time.Duration(cfg.RAGRateLimitWindowS) * time.Second),
+		primary:  &endpointHealth{name: RAGEndpointPrimary, transport: transport},
+	}
+	if fallback != nil {
+		s.fallback = &endpointHealth{name: RAGEndpointFallback, transport: fallback}
+	}
+	return s
+}
+
+// Start probes the RAG endpoints in the background until ctx is cancelled,
+// opening an endpoint's breaker after consecutive failures and closing it on
+// the first success
+func (s *HealthService) Start(ctx context.Context) {
+	interval := s.ProbeInterval()
+	if interval <= 0 || s.cfg.RAGProbeFailureThreshold <= 0 {
+		logrus.Info("RAG prober disabled")
+		return
+	}
+
+	middleware.SetRAGDegraded(false)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.probe(ctx)
+			}
+		}
+	}()
+
+	logrus.WithFields(logrus.Fields{
+		"interval":          interval,
+		"failure_threshold": s.cfg.RAGProbeFailureThreshold,
+		"fallback":          s.fallback != nil,
+	}).Info("RAG prober started")
+}
+
+// probe checks each RAG endpoint once, updating its breaker and the degraded
+// mode gauge
+func (s *HealthService) probe(ctx context.Context) {
+	for _, endpoint := range s.endpoints() {
+		status := checkRAGEndpoint(ctx, endpoint.transport)
+		if ctx.Err() != nil {
+			return
+		}
+		endpoint.recordProbe(status, s.cfg.RAGProbeFailureThreshold)
+	}
+	middleware.SetRAGDegraded(!s.RAGAvailable())
+}
+
+// endpoints returns the configured RAG endpoints, primary first
+func (s *HealthService) endpoints() []*endpointHealth {
+	if s.fallback == nil {
+		return []*endpointHealth{s.primary}
+	}
+	return []*endpointHealth{s.primary, s.fallback}
+}
+
+// RAGAvailable returns false while the service is in degraded mode, with
+// every RAG endpoint's breaker open
+func (s *HealthService) RAGAvailable() bool {
+	return s.PrimaryAvailable() || s.FallbackAvailable()
+}
+
+// PrimaryAvailable returns false while the primary RAG endpoint's breaker is open
+func (s *HealthService) PrimaryAvailable() bool {
+	return s.primary.available()
+}
+
+// FallbackAvailable returns true if a fallback RAG endpoint is configured and
+// its breaker is closed
+func (s *HealthService) FallbackAvailable() bool {
+	return s.fallback != nil && s.fallback.available()
+}
+
+// Mode returns the current service mode
+func (s *HealthService) Mode() string {
+	if s.RAGAvailable() {
+		return ModeNormal
+	}
+	return ModeDegraded
+}
+
+// Breakers returns the state of each RAG endpoint's circuit breaker: open
+// while the endpoint is marked unavailable, closed otherwise, with the
+// consecutive probe failures behind it
+func (s *HealthService) Breakers() []models.CircuitBreakerState {
+	names := map[string]string{
+		RAGEndpointPrimary:  "rag_service",
+		RAGEndpointFallback: "rag_service_fallback",
+	}
+
+	var breakers []models.CircuitBreakerState
+	for _, endpoint := range s.endpoints() {
+		endpoint.mu.RLock()
+		state := models.BreakerClosed
+		if endpoint.degraded {
+			state = models.BreakerOpen
+		}
+		breakers = append(breakers, models.CircuitBreakerState{
+			Name:                names[endpoint.name],
+			State:               state,
+			ConsecutiveFailures: endpoint.failures,
+			FailureThreshold:    s.cfg.RAGProbeFailureThreshold,
+		})
+		endpoint.mu.RUnlock()
+	}
+	return breakers
+}
+
+// ProbeInterval is how often the RAG service is probed, and so how soon to retry when degraded
+func (s *HealthService) ProbeInterval() time.Duration {
+	return time.Duration(s.cfg.RAGProbeIntervalS) * time.Second
+}
+
+// available returns false while the endpoint's breaker is open
+func (e *endpointHealth) available() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return !e.degraded
+}
+
+// recordProbe updates the breaker from a probe result, logging each transition once
+func (e *endpointHealth) recordProbe(status string, threshold int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if status == "healthy" {
+		e.failures = 0
+		if e.degraded {
+			e.degraded = false
+			logrus.WithField("endpoint", e.name).Warn("RAG endpoint recovered, closing its breaker")
+		}
+		return
+	}
+
+	e.failures++
+	if !e.degraded && e.failures >= threshold {
+		e.degraded = true
+		logrus.WithFields(logrus.Fields{
+			"endpoint": e.name,
+			"failures": e.failures,
+			"status":   status,
+		}).Warn("RAG endpoint unavailable, opening its breaker")
+	}
+}
+
+// recordUpstreamCall counts a RAG call attempt for the rate limit window
+func (s *HealthService) recordUpstreamCall(rateLimited bool) {
+	s.upstream.record(time.Now(), rateLimited)
+}
+
+// Check returns a snapshot of the health of every dependency
+func (s *HealthService) Check(ctx context.Context) *models.HealthResponse {
+	response := &models.HealthResponse{
+		Status:    "healthy",
+		Timestamp: time.Now().UTC(),
+		Version:   "1.0.0",
+		Mode:      s.Mode(),
+		RAGQueue:  s.limiter.Status(),
+
+		RAGRateLimits: s.upstream.status(time.Now()),
+	}
+
+	// Check database
+	if err := s.checkDatabase(ctx); err != nil {
+		response.Database = fmt.Sprintf("unhealthy: %v", err)
+		response.Status = "degraded"
+	} else {
+		response.Database = "healthy"
+	}
+
+	// Check Redis
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if err := cache.HealthCheck(ctx); err != nil {
+		response.Redis = fmt.Sprintf("unhealthy: %v", err)
+		response.Status = "degraded"
+	} else {
+		response.Redis = "healthy"
+	}
+
+	// Check each RAG endpoint
+	response.RAGService = checkRAGEndpoint(ctx, s.primary.transport)
+	if response.RAGService != "healthy" {
+		response.Status = "degraded"
+	}
+	if s.fallback != nil {
+		response.RAGServiceFallback = checkRAGEndpoint(ctx, s.fallback.transport)
+		if response.RAGServiceFallback != "healthy" {
+			response.Status = "degraded"
+		}
+	}
+
+	return response
+}
+
+// checkDatabase checks the database, after any injected db fault
+func (s *HealthService) checkDatabase(ctx context.Context) error {
+	if err := s.chaos.Inject(ctx, chaos.TargetDB); err != nil {
+		return err
+	}
+	return db.HealthCheck()
+}
+
+// checkRAGEndpoint checks if a RAG endpoint is healthy
+func checkRAGEndpoint(ctx context.Context, transport RAGTransport) string {
+	if err := transport.Check(ctx); err != nil {
+		return fmt.Sprintf("unhealthy: %v", err)
+	}
+
+	return "healthy"
+}
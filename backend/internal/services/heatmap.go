@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/cache"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// heatmapCacheTTL is how long a computed heatmap is served from cache; it
+// informs staffing, so an hour of lag doesn't matter
+const heatmapCacheTTL = time.Hour
+
+// GetQueryHeatmap returns query volume by local day of week and hour over the
+// last days, with negative feedback counted the same way if includeFeedback
+// is set
+func (s *AnalyticsService) GetQueryHeatmap(ctx context.Context, days int, loc *time.Location, includeFeedback bool) (*models.QueryHeatmap, error) {
+	key := cache.GenerateCacheKey("heatmap", strconv.Itoa(days), loc.String(), strconv.FormatBool(includeFeedback))
+
+	var cached models.QueryHeatmap
+	err := cache.Get(ctx, key, &cached)
+	if err == nil {
+		return &cached, nil
+	} else if err != redis.Nil && cache.Client != nil {
+		logrus.WithError(err).Warn("Failed to get query heatmap from cache")
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+	heatmap := &models.QueryHeatmap{
+		Days:        days,
+		TZ:          loc.String(),
+		GeneratedAt: time.Now().UTC(),
+	}
+
+	scope := analyticsQueries().WithContext(ctx).Where("chat_queries.created_at >= ?", since)
+	if heatmap.Queries, err = weekHourCounts(scope, "chat_queries.created_at", loc); err != nil {
+		return nil, fmt.Errorf("failed to count queries by hour: %w", err)
+	}
+
+	if includeFeedback {
+		scope := db.DB.WithContext(ctx).Model(&models.Feedback{}).Where("feedbacks.score = ? AND feedbacks.created_at >= ?", -1, since)
+		counts, err := weekHourCounts(scope, "feedbacks.created_at", loc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count negative feedback by hour: %w", err)
+		}
+		heatmap.NegativeFeedback = &counts
+	}
+
+	if err := cache.Set(ctx, key, heatmap, heatmapCacheTTL); err != nil && cache.Client != nil {
+		logrus.WithError(err).Warn("Failed to cache query heatmap")
+	}
+
+	return heatmap, nil
+}
+
+// weekHourCounts counts the rows in scope by the local day of week and hour
+// of a timestamp column, in a single grouped query
+func weekHourCounts(scope *gorm.DB, column string, loc *time.Location) (models.WeekHourCounts, error) {
+	var counts models.WeekHourCounts
+
+	selectSQL, args := weekHourSelect(column, loc)
+	var rows []struct {
+		Dow   int
+		Hour  int
+		Count int64
+	}
+	if err := scope.Select(selectSQL+", COUNT(*) AS count", args...).Group("dow, hour").Scan(&rows).Error; err != nil {
+		return counts, err
+	}
+
+	for _, row := range rows {
+		if row.Dow >= 0 && row.Dow < 7 && row.Hour >= 0 && row.Hour < 24 {
+			counts[row.Dow][row.Hour] += row.Count
+		}
+	}
+	return counts, nil
+}
+
+// weekHourSelect returns the dialect's SQL selecting a timestamp column's
+// local day of week (0 is Sunday) as dow and hour as hour, with its
+// arguments. SQLite, used in development, has no time zone database, so it
+// shifts by the zone's current UTC offset and ignores DST changes within
+// the window.
+func weekHourSelect(column string, loc *time.Location) (string, []interface{}) {
+	if isPostgres() {
+		sql := fmt.Sprintf("CAST(EXTRACT(DOW FROM %[1]s AT TIME ZONE ?) AS INTEGER) AS dow, "+
+			"CAST(EXTRACT(HOUR FROM %[1]s AT TIME ZONE ?) AS INTEGER) AS hour", column)
+		return sql, []interface{}{loc.String(), loc.String()}
+	}
+
+	_, offset := time.Now().In(loc).Zone()
+	shift := fmt.Sprintf("%+d minutes", offset/60)
+	sql := fmt.Sprintf("CAST(strftime('%%w', %[1]s, ?) AS INTEGER) AS dow, "+
+		"CAST(strftime('%%H', %[1]s, ?) AS INTEGER) AS hour", column)
+	return sql, []interface{}{shift, shift}
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/cache"
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/middleware"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// idempotencyPurgeInterval is how often expired idempotency records are deleted
+const idempotencyPurgeInterval = time.Hour
+
+// IdempotencyService stores responses to Idempotency-Key requests in Redis,
+// with the database as a durable fallback, and claims keys so concurrent
+// duplicates run once
+type IdempotencyService struct {
+	window  time.Duration
+	lockTTL time.Duration
+
+	// claimed holds keys claimed by this instance; Redis extends the claim
+	// across instances when configured
+	mu      sync.Mutex
+	claimed map[string]bool
+}
+
+func NewIdempotencyService(cfg *config.Config) *IdempotencyService {
+	return &IdempotencyService{
+		window:  time.Duration(cfg.IdempotencyWindowS) * time.Second,
+		lockTTL: time.Duration(cfg.IdempotencyLockS) * time.Second,
+		claimed: make(map[string]bool),
+	}
+}
+
+// Start periodically deletes expired records until ctx is cancelled
+func (s *IdempotencyService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(idempotencyPurgeInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				result := db.DB.WithContext(ctx).Where("expires_at <= ?", time.Now().UTC()).Delete(&models.IdempotencyRecord{})
+				if result.Error != nil {
+					logrus.WithError(result.Error).Warn("Failed to purge expired idempotency records")
+				} else if result.RowsAffected > 0 {
+					logrus.WithField("deleted", result.RowsAffected).Info("Purged expired idempotency records")
+				}
+			}
+		}
+	}()
+}
+
+// Lookup returns the saved response for key, or nil if there is none
+func (s *IdempotencyService) Lookup(ctx context.Context, key string) (*middleware.StoredResponse, error) {
+	if cache.Client != nil {
+		var resp middleware.StoredResponse
+		err := cache.Get(ctx, idempotencyCacheKey(key), &resp)
+		if err == nil {
+			return &resp, nil
+		} else if err != redis.Nil {
+			logrus.WithError(err).Warn("Failed to get idempotent response from cache")
+		}
+	}
+
+	var record models.IdempotencyRecord
+	err := db.DB.WithContext(ctx).
+		Where("key = ? AND expires_at > ?", key, time.Now().UTC()).
+		First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load idempotency record: %w", err)
+	}
+
+	return &middleware.StoredResponse{
+		Endpoint:    record.Endpoint,
+		RequestHash: record.RequestHash,
+		StatusCode:  record.StatusCode,
+		ContentType: record.ContentType,
+		Body:        []byte(record.Body),
+	}, nil
+}
+
+// Acquire claims key, returning false while this or another instance holds it
+func (s *IdempotencyService) Acquire(ctx context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	if s.claimed[key] {
+		s.mu.Unlock()
+		return false, nil
+	}
+	s.claimed[key] = true
+	s.mu.Unlock()
+
+	if cache.Client == nil {
+		return true, nil
+	}
+
+	ok, err := cache.SetNX(ctx, idempotencyLockKey(key), 1, s.lockTTL)
+	if err != nil {
+		// Still serialized within this instance
+		logrus.WithError(err).Warn("Failed to claim idempotency key in Redis")
+		return true, nil
+	}
+	if !ok {
+		s.release(key)
+		return false, nil
+	}
+	return true, nil
+}
+
+// Release gives up a claim taken with Acquire
+func (s *IdempotencyService) Release(ctx context.Context, key string) {
+	if cache.Client != nil {
+		if err := cache.Delete(ctx, idempotencyLockKey(key)); err != nil {
+			logrus.WithError(err).Warn("Failed to release idempotency key")
+		}
+	}
+	s.release(key)
+}
+
+func (s *IdempotencyService) release(key string) {
+	s.mu.Lock()
+	delete(s.claimed, key)
+	s.mu.Unlock()
+}
+
+// Save stores the response for the idempotency window. It fails only if
+// neither Redis nor the database accepted it.
+func (s *IdempotencyService) Save(ctx context.Context, key string, resp *middleware.StoredResponse) error {
+	cached := false
+	if cache.Client != nil {
+		if err := cache.Set(ctx, idempotencyCacheKey(key), resp, s.window); err != nil {
+			logrus.WithError(err).Warn("Failed to cache idempotent response")
+		} else {
+			cached = true
+		}
+	}
+
+	record := models.IdempotencyRecord{
+		Key:         key,
+		Endpoint:    resp.Endpoint,
+		RequestHash: resp.RequestHash,
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.ContentType,
+		Body:        string(resp.Body),
+		ExpiresAt:   time.Now().UTC().Add(s.window),
+	}
+	err := db.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"endpoint", "request_hash", "status_code", "content_type", "body", "expires_at"}),
+	}).Create(&record).Error
+	if err != nil {
+		if cached {
+			logrus.WithError(err).Warn("Failed to persist idempotency record")
+			return nil
+		}
+		return fmt.Errorf("failed to persist idempotency record: %w", err)
+	}
+
+	return nil
+}
+
+func idempotencyCacheKey(key string) string {
+	return "idempotency:" + key
+}
+
+func idempotencyLockKey(key string) string {
+	return "idempotency:lock:" + key
+}
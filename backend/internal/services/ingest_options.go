@@ -0,0 +1,98 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// Chunk sizes a document may request, in characters
+const (
+	minChunkSize = 100
+	maxChunkSize = 8000
+)
+
+// ingestLanguagePattern accepts language codes such as en, deu or pt-BR
+var ingestLanguagePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})?$`)
+
+// defaultIngestOptions returns the configured ingestion options
+func defaultIngestOptions(cfg *config.Config) models.IngestOptions {
+	return models.IngestOptions{
+		ChunkSize:    cfg.DefaultChunkSize,
+		ChunkOverlap: cfg.DefaultChunkOverlap,
+		OCR:          cfg.DefaultOCR,
+	}
+}
+
+// assignIngestOptions sets a new document's ingestion options from the
+// request. Options left out default to the previous version's, so a new
+// version is chunked like the one it replaces, or to the configured
+// defaults. It must run after assignVersion.
+func (s *DocumentService) assignIngestOptions(doc *models.Document, req models.IngestOptionsRequest) error {
+	options := defaultIngestOptions(s.cfg)
+
+	if doc.DocumentKey != "" {
+		var previous models.Document
+		err := inCollection(db.DB.Select("chunk_size", "chunk_overlap", "ocr", "language"), doc.CollectionID).
+			Where("document_key = ?", doc.DocumentKey).
+			Order("version DESC, id DESC").
+			First(&previous).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to get previous version's ingestion options: %w", err)
+		}
+		if err == nil && previous.IngestOptions.ChunkSize > 0 {
+			options = previous.IngestOptions
+		}
+	}
+
+	if req.ChunkSize != nil {
+		if *req.ChunkSize < minChunkSize || *req.ChunkSize > maxChunkSize {
+			return validationError("chunk_size must be between %d and %d", minChunkSize, maxChunkSize)
+		}
+		options.ChunkSize = *req.ChunkSize
+	}
+	if req.ChunkOverlap != nil {
+		if *req.ChunkOverlap < 0 {
+			return validationError("chunk_overlap must not be negative")
+		}
+		options.ChunkOverlap = *req.ChunkOverlap
+	}
+	if options.ChunkOverlap >= options.ChunkSize {
+		return validationError("chunk_overlap (%d) must be less than chunk_size (%d)", options.ChunkOverlap, options.ChunkSize)
+	}
+	if req.OCR != nil {
+		options.OCR = *req.OCR
+	}
+	if req.Language != nil {
+		language := strings.TrimSpace(*req.Language)
+		if language != "" && !ingestLanguagePattern.MatchString(language) {
+			return validationError("invalid language %q: use a language code such as en or pt-BR", language)
+		}
+		options.Language = language
+	}
+
+	doc.IngestOptions = options
+	return nil
+}
+
+// addIngestOptionFields adds a document's ingestion options to the fields
+// sent to the RAG service. Documents without recorded options send none and
+// are ingested with the RAG service defaults.
+func addIngestOptionFields(fields map[string]string, options models.IngestOptions) {
+	if options.ChunkSize <= 0 {
+		return
+	}
+	fields["chunk_size"] = strconv.Itoa(options.ChunkSize)
+	fields["chunk_overlap"] = strconv.Itoa(options.ChunkOverlap)
+	fields["ocr"] = strconv.FormatBool(options.OCR)
+	if options.Language != "" {
+		fields["language"] = options.Language
+	}
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// ingestProgressInterval is how often a running ingestion's progress is polled
+const ingestProgressInterval = 2 * time.Second
+
+// IngestionDone reports whether a document status is final, so its progress
+// will no longer change
+func IngestionDone(status string) bool {
+	return status == "completed" || status == "failed" || status == "superseded"
+}
+
+// ingestWithProgress sends a document to the RAG service under a job ID and,
+// while the ingestion runs, polls the job's progress and records it on the
+// document. The poller stops when the ingestion returns, so it never outlives
+// the call.
+func (s *DocumentService) ingestWithProgress(ctx context.Context, docID uint, req RAGIngestRequest) (*RAGIngestResponse, error) {
+	req.JobID = fmt.Sprintf("doc-%d-%d", docID, time.Now().UnixNano())
+
+	pollCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.pollIngestProgress(pollCtx, docID, req.JobID)
+	}()
+
+	resp, err := s.transport.Ingest(ctx, req)
+	cancel()
+	<-done
+	return resp, err
+}
+
+// pollIngestProgress records the job's progress until ctx is done. Progress
+// stays indeterminate if the RAG service doesn't report it.
+func (s *DocumentService) pollIngestProgress(ctx context.Context, docID uint, jobID string) {
+	ticker := time.NewTicker(ingestProgressInterval)
+	defer ticker.Stop()
+
+	last := -1
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		progress, err := s.transport.IngestProgress(ctx, jobID)
+		if errors.Is(err, ErrIngestProgressUnsupported) {
+			logrus.WithField("doc_id", docID).Debug("RAG service does not report ingestion progress")
+			return
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				logrus.WithError(err).WithField("doc_id", docID).Debug("Failed to poll ingestion progress")
+			}
+			continue
+		}
+		if progress == nil || progress.ChunksTotal <= 0 {
+			continue
+		}
+
+		// 100% is only recorded once the ingestion has completed
+		percent := progress.ChunksProcessed * 100 / progress.ChunksTotal
+		if percent > 99 {
+			percent = 99
+		}
+		if percent < 0 {
+			percent = 0
+		}
+		if percent == last {
+			continue
+		}
+		last = percent
+
+		// Only a document still processing takes progress, so a late poll
+		// can't overwrite the final state
+		db.DB.Model(&models.Document{}).Where("id = ? AND status = ?", docID, "processing").Updates(map[string]interface{}{
+			"progress_percent": percent,
+			"chunks_processed": progress.ChunksProcessed,
+			"chunks_total":     progress.ChunksTotal,
+		})
+	}
+}
+
+// GetDocumentStatus returns a document's ingestion status and progress
+func (s *DocumentService) GetDocumentStatus(ctx context.Context, id uint) (*models.DocumentStatus, error) {
+	var document models.Document
+	if err := db.DB.WithContext(ctx).First(&document, id).Error; err != nil {
+		return nil, notFoundError("document", err)
+	}
+
+	return &models.DocumentStatus{
+		DocumentID:      document.ID,
+		Status:          document.Status,
+		ProgressPercent: document.ProgressPercent,
+		ChunksProcessed: document.ChunksProcessed,
+		ChunksTotal:     document.ChunksTotal,
+		ChunkCount:      document.ChunkCount,
+		BytesRead:       document.BytesRead,
+		ErrorCode:       document.ErrorCode,
+		ErrorMessage:    document.ErrorMessage,
+		UpdatedAt:       document.UpdatedAt,
+	}, nil
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"math"
+	"slices"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+)
+
+// Knowledge gap clustering methods
+const (
+	GapMethodEmbedding = "embedding"
+	GapMethodTrigram   = "trigram"
+)
+
+// minGapTrigramOverlap is the trigram overlap, as a Dice coefficient, a
+// question needs with a cluster's representative to join it when the RAG
+// service can't embed questions
+const minGapTrigramOverlap = 0.6
+
+// Per-cluster caps on what is kept of its questions
+const (
+	maxGapExampleSessions = 5
+	maxGapPages           = 5
+	maxGapQueryHashes     = 100
+)
+
+// gapQuestion is an unanswered question to cluster
+type gapQuestion struct {
+	query      models.ChatQuery
+	normalized string
+	hash       string
+	negative   bool      // rated thumbs down
+	vector     []float64 // embedding of the normalized question, for the embedding method
+}
+
+// gapCluster is a cluster being built, with what questions are compared
+// against: the trigrams or embedding of its representative
+type gapCluster struct {
+	model  *models.KnowledgeGapCluster
+	grams  map[string]struct{}
+	vector []float64
+	dirty  bool // changed since it was last saved
+}
+
+// gapClusterer groups questions in a single pass: each joins the cluster
+// whose representative it is most similar to, or starts a new one. Since
+// clusters only depend on what was added before, a report can be resumed by
+// reloading its clusters.
+type gapClusterer struct {
+	method        string
+	minSimilarity float64 // cosine similarity, for the embedding method
+	clusters      []*gapCluster
+	byHash        map[string]*gapCluster
+	postings      map[string][]int // trigram to the clusters whose representative has it
+}
+
+// newGapClusterer resumes clustering with a report's saved clusters. For the
+// embedding method, vectors are the embeddings of their representatives.
+func newGapClusterer(method string, minSimilarity float64, saved []models.KnowledgeGapCluster, vectors [][]float64) *gapClusterer {
+	c := &gapClusterer{
+		method:        method,
+		minSimilarity: minSimilarity,
+		byHash:        make(map[string]*gapCluster),
+		postings:      make(map[string][]int),
+	}
+	for i := range saved {
+		cluster := &gapCluster{model: &saved[i]}
+		if vectors != nil {
+			cluster.vector = vectors[i]
+		}
+		c.index(cluster)
+	}
+	return c
+}
+
+// index adds a cluster to the lookups
+func (c *gapClusterer) index(cluster *gapCluster) {
+	position := len(c.clusters)
+	c.clusters = append(c.clusters, cluster)
+	for _, hash := range cluster.model.QueryHashes {
+		c.byHash[hash] = cluster
+	}
+	if c.method == GapMethodTrigram {
+		cluster.grams = queryTrigrams(cluster.model.Normalized)
+		for gram := range cluster.grams {
+			c.postings[gram] = append(c.postings[gram], position)
+		}
+	}
+}
+
+// add puts a question in its cluster, starting one if none is close enough
+func (c *gapClusterer) add(reportID uint, question gapQuestion) {
+	cluster := c.byHash[question.hash]
+	if cluster == nil {
+		cluster = c.nearest(question)
+	}
+	if cluster == nil {
+		cluster = &gapCluster{
+			model: &models.KnowledgeGapCluster{
+				ReportID:        reportID,
+				Representative:  question.query.Query,
+				Normalized:      question.normalized,
+				ExampleSessions: models.StringList{},
+				Status:          KnowledgeGapOpen,
+			},
+			vector: question.vector,
+		}
+		c.index(cluster)
+	}
+
+	model := cluster.model
+	model.Frequency++
+	if question.query.LowConfidence {
+		model.LowConfidenceCount++
+	}
+	if question.query.NoContext {
+		model.NoContextCount++
+	}
+	if question.negative {
+		model.NegativeFeedbackCount++
+	}
+	if len(model.ExampleSessions) < maxGapExampleSessions && !slices.Contains(model.ExampleSessions, question.query.SessionID) {
+		model.ExampleSessions = append(model.ExampleSessions, question.query.SessionID)
+	}
+	if metadata := question.query.Metadata; metadata != nil && metadata.PageURL != "" &&
+		len(model.Pages) < maxGapPages && !slices.Contains(model.Pages, metadata.PageURL) {
+		model.Pages = append(model.Pages, metadata.PageURL)
+	}
+	if c.byHash[question.hash] == nil && len(model.QueryHashes) < maxGapQueryHashes {
+		model.QueryHashes = append(model.QueryHashes, question.hash)
+		c.byHash[question.hash] = cluster
+	}
+	cluster.dirty = true
+}
+
+// nearest returns the cluster most similar to a question, if any is similar
+// enough to join
+func (c *gapClusterer) nearest(question gapQuestion) *gapCluster {
+	if c.method == GapMethodEmbedding {
+		var best *gapCluster
+		bestScore := c.minSimilarity
+		for _, cluster := range c.clusters {
+			if score := cosineSimilarity(question.vector, cluster.vector); score >= bestScore {
+				best, bestScore = cluster, score
+			}
+		}
+		return best
+	}
+
+	grams := queryTrigrams(question.normalized)
+	shared := make(map[int]int)
+	for gram := range grams {
+		for _, position := range c.postings[gram] {
+			shared[position]++
+		}
+	}
+	var best *gapCluster
+	bestScore := minGapTrigramOverlap
+	for position, n := range shared {
+		cluster := c.clusters[position]
+		dice := 2 * float64(n) / float64(len(grams)+len(cluster.grams))
+		if dice > bestScore || (dice == bestScore && (best == nil || cluster.model.Frequency > best.model.Frequency)) {
+			best, bestScore = cluster, dice
+		}
+	}
+	return best
+}
+
+// dirty returns the clusters changed since they were last saved
+func (c *gapClusterer) dirty() []*gapCluster {
+	var changed []*gapCluster
+	for _, cluster := range c.clusters {
+		if cluster.dirty {
+			changed = append(changed, cluster)
+		}
+	}
+	return changed
+}
+
+// cosineSimilarity of two vectors; 0 if they differ in length or either is zero
+func cosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,669 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/audit"
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/lifecycle"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/ragclient"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Knowledge gap cluster statuses
+const (
+	KnowledgeGapOpen     = "open"
+	KnowledgeGapResolved = "resolved"
+)
+
+// knowledgeGapBatchSize is the number of questions clustered per batch, and
+// embedded per call to the RAG service
+const knowledgeGapBatchSize = 100
+
+// knowledgeGapResolveReports is how many earlier reports a completing report
+// checks for resolved clusters
+const knowledgeGapResolveReports = 10
+
+// A pending or running report whose heartbeat is older than
+// knowledgeGapStaleAfter was abandoned by its instance and is resumed by
+// whichever instance claims it first. Instances look for abandoned and due
+// reports every knowledgeGapCheckInterval.
+const (
+	knowledgeGapStaleAfter    = 2 * time.Minute
+	knowledgeGapCheckInterval = time.Minute
+)
+
+// knowledgeGapLockKey is the Postgres advisory lock serializing report
+// creation, so only one report is generated at a time
+const knowledgeGapLockKey = 0x6b6761707321
+
+// errKnowledgeGapTakenOver stops a report another instance has advanced
+var errKnowledgeGapTakenOver = errors.New("knowledge gap report taken over by another instance")
+
+// KnowledgeGapService generates reports of the questions the docs couldn't
+// answer, clustered so the most frequent gaps come first. Generation is
+// capped in questions and time, runs in batches that survive restarts, and
+// resolves clusters of earlier reports whose questions now get confident
+// answers.
+type KnowledgeGapService struct {
+	interval      time.Duration
+	window        time.Duration
+	maxQueries    int
+	maxClusters   int
+	budget        time.Duration
+	minSimilarity float64
+
+	ragClient *ragclient.Client
+	lifecycle *lifecycle.Manager
+}
+
+func NewKnowledgeGapService(cfg *config.Config, ragClient *ragclient.Client, lc *lifecycle.Manager) *KnowledgeGapService {
+	return &KnowledgeGapService{
+		interval:      time.Duration(cfg.KnowledgeGapIntervalH) * time.Hour,
+		window:        time.Duration(cfg.KnowledgeGapWindowDays) * 24 * time.Hour,
+		maxQueries:    cfg.KnowledgeGapMaxQueries,
+		maxClusters:   cfg.KnowledgeGapMaxClusters,
+		budget:        time.Duration(cfg.KnowledgeGapBudgetS) * time.Second,
+		minSimilarity: cfg.KnowledgeGapMinSimilarity,
+		ragClient:     ragClient,
+		lifecycle:     lc,
+	}
+}
+
+// Start resumes reports interrupted by a restart and generates scheduled
+// reports when due, until ctx is cancelled
+func (s *KnowledgeGapService) Start(ctx context.Context) {
+	go func() {
+		s.resumeAbandoned()
+		s.scheduleDue(ctx)
+
+		ticker := time.NewTicker(knowledgeGapCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.resumeAbandoned()
+				s.scheduleDue(ctx)
+			}
+		}
+	}()
+}
+
+// Generate starts a report over the last window of questions. It is
+// rejected while another report is being generated.
+func (s *KnowledgeGapService) Generate(ctx context.Context) (*models.KnowledgeGapReport, error) {
+	if s.lifecycle.Stopping() {
+		return nil, fmt.Errorf("%w: server is shutting down", ErrOverloaded)
+	}
+
+	report, err := s.create(ctx, ReportManual)
+	if err != nil {
+		return nil, err
+	}
+
+	s.launch(report.ID)
+	return report, nil
+}
+
+// GetReports returns all reports, newest first, without their clusters
+func (s *KnowledgeGapService) GetReports(ctx context.Context) ([]models.KnowledgeGapReport, error) {
+	var reports []models.KnowledgeGapReport
+
+	if err := db.DB.WithContext(ctx).Order("id DESC").Find(&reports).Error; err != nil {
+		return nil, fmt.Errorf("failed to get knowledge gap reports: %w", err)
+	}
+
+	return reports, nil
+}
+
+// GetReport returns a report with its clusters, most frequent first
+func (s *KnowledgeGapService) GetReport(ctx context.Context, id uint) (*models.KnowledgeGapReport, error) {
+	var report models.KnowledgeGapReport
+	if err := db.DB.WithContext(ctx).First(&report, id).Error; err != nil {
+		return nil, notFoundError("knowledge gap report", err)
+	}
+
+	err := db.DB.WithContext(ctx).Where("report_id = ?", id).
+		Order("frequency DESC, id ASC").
+		Find(&report.Clusters).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get knowledge gap clusters: %w", err)
+	}
+
+	return &report, nil
+}
+
+// scheduleDue starts a scheduled report when the interval has passed since
+// the latest one
+func (s *KnowledgeGapService) scheduleDue(ctx context.Context) {
+	if s.interval <= 0 || s.lifecycle.Stopping() {
+		return
+	}
+
+	report, err := s.create(ctx, ReportScheduled)
+	if errors.Is(err, ErrConflict) {
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to schedule knowledge gap report")
+		return
+	}
+
+	logrus.WithField("knowledge_gap_report_id", report.ID).Info("Generating scheduled knowledge gap report")
+	s.launch(report.ID)
+}
+
+// create records a pending report. Scheduled reports are only created once
+// the interval has passed since the latest report; ErrConflict is returned
+// when a report is being generated or a scheduled one isn't due.
+func (s *KnowledgeGapService) create(ctx context.Context, trigger string) (*models.KnowledgeGapReport, error) {
+	now := time.Now().UTC()
+	report := models.KnowledgeGapReport{
+		Trigger:     trigger,
+		Status:      JobPending,
+		WindowStart: now.Add(-s.window),
+		WindowEnd:   now,
+		CreatedBy:   audit.ActorFrom(ctx).UserID,
+		HeartbeatAt: &now,
+	}
+
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", knowledgeGapLockKey).Error; err != nil {
+			return fmt.Errorf("failed to lock knowledge gap reports: %w", err)
+		}
+
+		var active models.KnowledgeGapReport
+		err := tx.Where("status IN ?", []string{JobPending, JobRunning}).First(&active).Error
+		if err == nil {
+			return fmt.Errorf("%w: knowledge gap report %d is still %s", ErrConflict, active.ID, active.Status)
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to get active knowledge gap reports: %w", err)
+		}
+
+		if trigger == ReportScheduled {
+			var recent int64
+			if err := tx.Model(&models.KnowledgeGapReport{}).Where("created_at > ?", now.Add(-s.interval)).Count(&recent).Error; err != nil {
+				return fmt.Errorf("failed to get recent knowledge gap reports: %w", err)
+			}
+			if recent > 0 {
+				return fmt.Errorf("%w: knowledge gap report not due", ErrConflict)
+			}
+		}
+
+		if err := tx.Create(&report).Error; err != nil {
+			return fmt.Errorf("failed to save knowledge gap report: %w", err)
+		}
+		return audit.Record(ctx, tx, "knowledge_gap.generate", "knowledge_gap_report", strconv.FormatUint(uint64(report.ID), 10), nil, report)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
+
+// launch generates a report in the background
+func (s *KnowledgeGapService) launch(id uint) {
+	started := s.lifecycle.Go("knowledge_gap_report", logrus.Fields{"knowledge_gap_report_id": id}, func(ctx context.Context) {
+		s.run(ctx, id)
+	})
+	if !started {
+		s.release(id)
+	}
+}
+
+// resumeAbandoned claims and resumes pending or running reports whose
+// instance stopped making progress
+func (s *KnowledgeGapService) resumeAbandoned() {
+	stale := time.Now().UTC().Add(-knowledgeGapStaleAfter)
+
+	var reports []models.KnowledgeGapReport
+	err := db.DB.Select("id").
+		Where("status IN ?", []string{JobPending, JobRunning}).
+		Where("heartbeat_at IS NULL OR heartbeat_at < ?", stale).
+		Find(&reports).Error
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to look for interrupted knowledge gap reports")
+		return
+	}
+
+	for _, report := range reports {
+		// Only one instance claims each report
+		result := db.DB.Model(&models.KnowledgeGapReport{}).
+			Where("id = ? AND (heartbeat_at IS NULL OR heartbeat_at < ?)", report.ID, stale).
+			Update("heartbeat_at", time.Now().UTC())
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+
+		logrus.WithField("knowledge_gap_report_id", report.ID).Info("Resuming interrupted knowledge gap report")
+		s.launch(report.ID)
+	}
+}
+
+// run clusters the report's questions batch by batch, then ranks the
+// clusters and resolves those of earlier reports. Running out of the time
+// budget pauses the report after its last saved batch, for the next check to
+// resume; a run that couldn't save a single batch in its budget fails the
+// report instead, since resuming would only repeat it. At shutdown it stops
+// between batches and releases the report, so the next instance to start
+// resumes it.
+func (s *KnowledgeGapService) run(ctx context.Context, id uint) {
+	defer func() {
+		if r := recover(); r != nil {
+			logrus.WithField("knowledge_gap_report_id", id).Errorf("Knowledge gap report panicked: %v", r)
+			s.finish(id, JobFailed, fmt.Sprintf("internal error: %v", r))
+		}
+	}()
+
+	var report models.KnowledgeGapReport
+	if err := db.DB.WithContext(ctx).First(&report, id).Error; err != nil {
+		logrus.WithError(err).WithField("knowledge_gap_report_id", id).Error("Failed to load knowledge gap report")
+		return
+	}
+	db.DB.Model(&models.KnowledgeGapReport{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status": JobRunning,
+		"runs":   gorm.Expr("runs + 1"),
+	})
+
+	ctx, cancel := context.WithTimeout(ctx, s.budget)
+	defer cancel()
+
+	clusterer, err := s.resumeClusterer(ctx, &report)
+	if err != nil {
+		logrus.WithError(err).WithField("knowledge_gap_report_id", id).Error("Failed to resume knowledge gap report")
+		s.finish(id, JobFailed, err.Error())
+		return
+	}
+
+	checkpoint := report.LastQueryID
+	stop := s.lifecycle.Context().Done()
+	for {
+		select {
+		case <-stop:
+			s.release(id)
+			return
+		default:
+		}
+
+		done, err := s.clusterBatch(ctx, &report, &clusterer)
+		if errors.Is(err, errKnowledgeGapTakenOver) {
+			logrus.WithField("knowledge_gap_report_id", id).Warn("Knowledge gap report taken over by another instance")
+			return
+		}
+		if err == nil && done {
+			break
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			if report.LastQueryID == checkpoint {
+				logrus.WithField("knowledge_gap_report_id", id).Error("Knowledge gap report clustered no batch within its time budget")
+				s.finish(id, JobFailed, "no batch of questions could be clustered within KNOWLEDGE_GAP_BUDGET")
+				return
+			}
+			s.pause(id, report.LastQueryID)
+			return
+		}
+		if err != nil {
+			logrus.WithError(err).WithField("knowledge_gap_report_id", id).Error("Knowledge gap batch failed")
+			s.finish(id, JobFailed, err.Error())
+			return
+		}
+	}
+
+	s.finish(id, JobCompleted, "")
+}
+
+// resumeClusterer loads the clusters a report built before it was
+// interrupted. Their representatives are embedded again for the embedding
+// method. Before the first batch it returns nil; the batch picks the method.
+func (s *KnowledgeGapService) resumeClusterer(ctx context.Context, report *models.KnowledgeGapReport) (*gapClusterer, error) {
+	if report.Method == "" {
+		return nil, nil
+	}
+
+	var saved []models.KnowledgeGapCluster
+	if err := db.DB.WithContext(ctx).Where("report_id = ?", report.ID).Order("id ASC").Find(&saved).Error; err != nil {
+		return nil, fmt.Errorf("failed to get knowledge gap clusters: %w", err)
+	}
+
+	var vectors [][]float64
+	if report.Method == GapMethodEmbedding && len(saved) > 0 {
+		texts := make([]string, len(saved))
+		for i, cluster := range saved {
+			texts[i] = cluster.Normalized
+		}
+		var err error
+		if vectors, err = s.embed(ctx, texts); err != nil {
+			return nil, err
+		}
+	}
+
+	return newGapClusterer(report.Method, s.minSimilarity, saved, vectors), nil
+}
+
+// clusterBatch clusters the next batch of unanswered questions, saving the
+// clusters it changed and advancing the report in the same transaction. It
+// reports whether the report has no questions left to cluster.
+func (s *KnowledgeGapService) clusterBatch(ctx context.Context, report *models.KnowledgeGapReport, clusterer **gapClusterer) (bool, error) {
+	remaining := int64(s.maxQueries) - report.QueryCount
+	if remaining <= 0 {
+		var more int64
+		err := s.candidates(ctx, report).Count(&more).Error
+		if err != nil {
+			return false, fmt.Errorf("failed to count questions: %w", err)
+		}
+		if more > 0 {
+			db.DB.Model(&models.KnowledgeGapReport{}).Where("id = ?", report.ID).Update("truncated", true)
+		}
+		return true, nil
+	}
+
+	limit := knowledgeGapBatchSize
+	if remaining < int64(limit) {
+		limit = int(remaining)
+	}
+
+	var queries []models.ChatQuery
+	if err := s.candidates(ctx, report).Order("id ASC").Limit(limit).Find(&queries).Error; err != nil {
+		return false, fmt.Errorf("failed to read questions: %w", err)
+	}
+	if len(queries) == 0 {
+		return true, nil
+	}
+
+	ids := make([]uint, len(queries))
+	for i, query := range queries {
+		ids[i] = query.ID
+	}
+	var negativeIDs []uint
+	err := db.DB.WithContext(ctx).Model(&models.Feedback{}).
+		Where("query_id IN ? AND score = ?", ids, -1).
+		Distinct().Pluck("query_id", &negativeIDs).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to read feedback: %w", err)
+	}
+	negative := make(map[uint]bool, len(negativeIDs))
+	for _, queryID := range negativeIDs {
+		negative[queryID] = true
+	}
+
+	questions := make([]gapQuestion, len(queries))
+	texts := make([]string, len(queries))
+	for i, query := range queries {
+		normalized := normalizeQuery(query.Query)
+		questions[i] = gapQuestion{
+			query:      query,
+			normalized: normalized,
+			hash:       queryHash(query.Query),
+			negative:   negative[query.ID],
+		}
+		texts[i] = normalized
+	}
+
+	// The first batch picks the method for the whole report: embeddings when
+	// the RAG service can compute them, trigrams otherwise
+	method := report.Method
+	if method == "" || method == GapMethodEmbedding {
+		vectors, err := s.embed(ctx, texts)
+		switch {
+		case err == nil:
+			method = GapMethodEmbedding
+			for i := range questions {
+				questions[i].vector = vectors[i]
+			}
+		case method == "" && ctx.Err() == nil:
+			logrus.WithError(err).WithField("knowledge_gap_report_id", report.ID).Warn("Failed to embed questions, clustering by trigrams")
+			method = GapMethodTrigram
+		default:
+			return false, err
+		}
+	}
+	if *clusterer == nil {
+		*clusterer = newGapClusterer(method, s.minSimilarity, nil, nil)
+	}
+
+	for _, question := range questions {
+		(*clusterer).add(report.ID, question)
+	}
+
+	lastID := ids[len(ids)-1]
+	changed := (*clusterer).dirty()
+	err = db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, cluster := range changed {
+			if err := tx.Save(cluster.model).Error; err != nil {
+				return fmt.Errorf("failed to save knowledge gap cluster: %w", err)
+			}
+		}
+
+		// The report only advances from where this batch started, so a batch
+		// raced by an instance that resumed the report rolls back
+		result := tx.Model(&models.KnowledgeGapReport{}).
+			Where("id = ? AND last_query_id = ?", report.ID, report.LastQueryID).
+			Updates(map[string]interface{}{
+				"method":        method,
+				"query_count":   gorm.Expr("query_count + ?", len(queries)),
+				"last_query_id": lastID,
+				"heartbeat_at":  time.Now().UTC(),
+			})
+		if result.Error != nil {
+			return fmt.Errorf("failed to update knowledge gap report progress: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errKnowledgeGapTakenOver
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	for _, cluster := range changed {
+		cluster.dirty = false
+	}
+	report.Method = method
+	report.QueryCount += int64(len(queries))
+	report.LastQueryID = lastID
+
+	logrus.WithFields(logrus.Fields{
+		"knowledge_gap_report_id": report.ID,
+		"last_id":                 lastID,
+		"questions":               len(queries),
+		"clusters":                len((*clusterer).clusters),
+	}).Debug("Clustered knowledge gap batch")
+
+	return len(queries) < limit, nil
+}
+
+// candidates selects the report's unanswered questions not yet clustered
+func (s *KnowledgeGapService) candidates(ctx context.Context, report *models.KnowledgeGapReport) *gorm.DB {
+	return unansweredScope(db.DB.WithContext(ctx).Model(&models.ChatQuery{})).
+		Where("created_at >= ? AND created_at < ?", report.WindowStart, report.WindowEnd).
+		Where("id > ?", report.LastQueryID)
+}
+
+// embed embeds texts with the RAG service, in batches
+func (s *KnowledgeGapService) embed(ctx context.Context, texts []string) ([][]float64, error) {
+	vectors := make([][]float64, 0, len(texts))
+	for start := 0; start < len(texts); start += knowledgeGapBatchSize {
+		end := min(start+knowledgeGapBatchSize, len(texts))
+		batch, err := s.ragClient.Embed(ctx, texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+// rank numbers a report's clusters by frequency and deletes those beyond
+// the cap, returning how many were kept
+func (s *KnowledgeGapService) rank(tx *gorm.DB, reportID uint) (int, error) {
+	var ids []uint
+	err := tx.Model(&models.KnowledgeGapCluster{}).Where("report_id = ?", reportID).
+		Order("frequency DESC, id ASC").
+		Pluck("id", &ids).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to get knowledge gap clusters: %w", err)
+	}
+
+	kept := min(len(ids), s.maxClusters)
+	if kept < len(ids) {
+		if err := tx.Where("id IN ?", ids[kept:]).Delete(&models.KnowledgeGapCluster{}).Error; err != nil {
+			return 0, fmt.Errorf("failed to delete knowledge gap clusters: %w", err)
+		}
+	}
+	for i, id := range ids[:kept] {
+		if err := tx.Model(&models.KnowledgeGapCluster{}).Where("id = ?", id).Update("rank", i+1).Error; err != nil {
+			return 0, fmt.Errorf("failed to rank knowledge gap clusters: %w", err)
+		}
+	}
+	return kept, nil
+}
+
+// resolve marks open clusters of earlier reports resolved when their
+// questions were asked again after those reports, up to the end of this
+// one's window, and none of them went unanswered. It returns how many were
+// resolved.
+func (s *KnowledgeGapService) resolve(tx *gorm.DB, report models.KnowledgeGapReport) (int, error) {
+	var earlier []models.KnowledgeGapReport
+	err := tx.Where("id < ? AND status = ?", report.ID, JobCompleted).
+		Order("id DESC").
+		Limit(knowledgeGapResolveReports).
+		Find(&earlier).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to get earlier knowledge gap reports: %w", err)
+	}
+
+	now := time.Now().UTC()
+	resolved := 0
+	for _, previous := range earlier {
+		var clusters []models.KnowledgeGapCluster
+		if err := tx.Where("report_id = ? AND status = ?", previous.ID, KnowledgeGapOpen).Find(&clusters).Error; err != nil {
+			return 0, fmt.Errorf("failed to get knowledge gap clusters: %w", err)
+		}
+
+		for _, cluster := range clusters {
+			if len(cluster.QueryHashes) == 0 {
+				continue
+			}
+			asked := func() *gorm.DB {
+				return tx.Model(&models.ChatQuery{}).
+					Where("synthetic = ? AND moderation_flag = ?", false, false).
+					Where("query_hash IN ?", []string(cluster.QueryHashes)).
+					Where("created_at >= ? AND created_at < ?", previous.WindowEnd, report.WindowEnd)
+			}
+
+			var total, unanswered int64
+			if err := asked().Count(&total).Error; err != nil {
+				return 0, fmt.Errorf("failed to count answered questions: %w", err)
+			}
+			if total == 0 {
+				continue
+			}
+			if err := unansweredScope(asked()).Count(&unanswered).Error; err != nil {
+				return 0, fmt.Errorf("failed to count unanswered questions: %w", err)
+			}
+			if unanswered > 0 {
+				continue
+			}
+
+			err := tx.Model(&models.KnowledgeGapCluster{}).Where("id = ?", cluster.ID).Updates(map[string]interface{}{
+				"status":                KnowledgeGapResolved,
+				"resolved_at":           now,
+				"resolved_by_report_id": report.ID,
+			}).Error
+			if err != nil {
+				return 0, fmt.Errorf("failed to resolve knowledge gap cluster: %w", err)
+			}
+			resolved++
+		}
+	}
+	return resolved, nil
+}
+
+// release hands a report interrupted by shutdown to the next instance to
+// start, instead of waiting for its heartbeat to go stale
+func (s *KnowledgeGapService) release(id uint) {
+	logrus.WithField("knowledge_gap_report_id", id).Warn("Knowledge gap report interrupted by shutdown, will resume on restart")
+	if err := db.DB.Model(&models.KnowledgeGapReport{}).Where("id = ?", id).Update("heartbeat_at", nil).Error; err != nil {
+		logrus.WithError(err).WithField("knowledge_gap_report_id", id).Error("Failed to release knowledge gap report")
+	}
+}
+
+// pause hands back a report that ran out of time for this run; the next
+// check for abandoned reports resumes it after the last saved batch
+func (s *KnowledgeGapService) pause(id, lastQueryID uint) {
+	logrus.WithFields(logrus.Fields{
+		"knowledge_gap_report_id": id,
+		"last_id":                 lastQueryID,
+	}).Info("Knowledge gap report ran out of time, pausing to resume after the last clustered question")
+	err := db.DB.Model(&models.KnowledgeGapReport{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":       JobPending,
+		"heartbeat_at": nil,
+	}).Error
+	if err != nil {
+		logrus.WithError(err).WithField("knowledge_gap_report_id", id).Error("Failed to pause knowledge gap report")
+	}
+}
+
+// finish records the final state of a report. A completed report has its
+// clusters ranked and resolves clusters of earlier reports.
+func (s *KnowledgeGapService) finish(id uint, status, errMessage string) {
+	var report models.KnowledgeGapReport
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&report, id).Error; err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{
+			"status":       status,
+			"error":        errMessage,
+			"completed_at": time.Now().UTC(),
+		}
+		if status == JobCompleted {
+			kept, err := s.rank(tx, id)
+			if err != nil {
+				return err
+			}
+			resolved, err := s.resolve(tx, report)
+			if err != nil {
+				return err
+			}
+			updates["cluster_count"] = kept
+			updates["resolved_count"] = resolved
+			report.ClusterCount, report.ResolvedCount = kept, resolved
+		}
+		return tx.Model(&models.KnowledgeGapReport{}).Where("id = ?", id).Updates(updates).Error
+	})
+	if err != nil {
+		logrus.WithError(err).WithField("knowledge_gap_report_id", id).Error("Failed to update knowledge gap report")
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"knowledge_gap_report_id": id,
+		"status":                  status,
+		"questions":               report.QueryCount,
+		"clusters":                report.ClusterCount,
+		"resolved":                report.ResolvedCount,
+	}).Info("Knowledge gap report finished")
+}
+
+// unansweredScope restricts a chat query lookup to questions the docs
+// couldn't answer: answers replaced for low confidence, answers without
+// retrieved context and thumbs-down answers. Moderated and synthetic queries
+// are left out.
+func unansweredScope(query *gorm.DB) *gorm.DB {
+	return query.
+		Where("chat_queries.synthetic = ? AND chat_queries.moderation_flag = ?", false, false).
+		Where("(chat_queries.low_confidence OR chat_queries.no_context OR EXISTS (SELECT 1 FROM feedbacks WHERE feedbacks.query_id = chat_queries.id AND feedbacks.score = ?))", -1)
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/audit"
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/middleware"
+	"github.com/ai-support-assistant/backend/internal/scanner"
+	"github.com/sirupsen/logrus"
+)
+
+// newUploadScanner returns the clamd scanner when CLAMAV_HOST is set, and
+// one that accepts everything unscanned otherwise
+func newUploadScanner(cfg *config.Config) scanner.Scanner {
+	if cfg.ClamAVHost == "" {
+		return scanner.Noop{}
+	}
+	addr := net.JoinHostPort(cfg.ClamAVHost, strconv.Itoa(cfg.ClamAVPort))
+	return scanner.NewClamAV(addr, time.Duration(cfg.MalwareScanTimeoutS)*time.Second)
+}
+
+// scanUpload scans an upload for malware before anything else is done with
+// it, leaving the file rewound; see scanContent
+func (s *DocumentService) scanUpload(ctx context.Context, file multipart.File, header *multipart.FileHeader, uploadedBy string) (*scanner.Result, error) {
+	return s.scanContent(ctx, file, header.Filename, header.Size, uploadedBy)
+}
+
+// scanContent scans content of size bytes for malware before it is forwarded
+// anywhere, leaving it rewound. Every ingestion path goes through it:
+// uploads, fetched objects, crawled pages and approved corrections; queued
+// and re-ingested documents were scanned as uploads. Infected content is
+// rejected and recorded in the audit log. Content the scanner can't check,
+// because it's unavailable or the content is over MALWARE_SCAN_MAX_BYTES, is
+// rejected unless MALWARE_SCAN_FAIL_OPEN is set, when it is accepted with a
+// skipped verdict.
+func (s *DocumentService) scanContent(ctx context.Context, content io.ReadSeeker, fileName string, size int64, uploadedBy string) (*scanner.Result, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"file_name":   fileName,
+		"file_size":   size,
+		"uploaded_by": uploadedBy,
+	})
+
+	var result *scanner.Result
+	var err error
+	if size > s.cfg.MalwareScanMaxBytes {
+		err = fmt.Errorf("%w: file is larger than the %d bytes that can be scanned", scanner.ErrUnavailable, s.cfg.MalwareScanMaxBytes)
+	} else {
+		result, err = s.scanner.Scan(ctx, io.LimitReader(content, s.cfg.MalwareScanMaxBytes))
+		if _, seekErr := content.Seek(0, io.SeekStart); seekErr != nil && err == nil {
+			return nil, fmt.Errorf("failed to rewind content: %w", seekErr)
+		}
+	}
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return nil, ctx.Err()
+		}
+		middleware.RecordMalwareScan("unavailable")
+		if !s.cfg.MalwareScanFailOpen {
+			return nil, fmt.Errorf("%w: %v", ErrScanUnavailable, err)
+		}
+		logger.WithError(err).Warn("Content accepted without a malware scan")
+		return &scanner.Result{Verdict: scanner.VerdictSkipped}, nil
+	}
+
+	middleware.RecordMalwareScan(result.Verdict)
+	if result.Verdict != scanner.VerdictInfected {
+		return result, nil
+	}
+
+	logger.WithFields(logrus.Fields{
+		"signature": result.Signature,
+		"engine":    result.Engine,
+	}).Error("Malware detected in document")
+	if err := audit.Record(ctx, db.DB.WithContext(ctx), "document.malware_detected", "document", "", nil, map[string]interface{}{
+		"file_name":   fileName,
+		"file_size":   size,
+		"uploaded_by": uploadedBy,
+		"signature":   result.Signature,
+		"engine":      result.Engine,
+	}); err != nil {
+		logger.WithError(err).Error("Failed to audit malware detection")
+	}
+	return nil, fmt.Errorf("%w: %s", ErrMalwareDetected, result.Signature)
+}
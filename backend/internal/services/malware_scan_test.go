@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/scanner"
+)
+
+// fakeScanner reads the content it is given and returns a fixed outcome
+type fakeScanner struct {
+	result *scanner.Result
+	err    error
+	read   string
+}
+
+func (f *fakeScanner) Scan(ctx context.Context, content io.Reader) (*scanner.Result, error) {
+	b, _ := io.ReadAll(content)
+	f.read = string(b)
+	return f.result, f.err
+}
+
+func TestScanContent(t *testing.T) {
+	clean := &scanner.Result{Verdict: scanner.VerdictClean, Engine: "test"}
+	unavailable := scanner.ErrUnavailable
+
+	tests := []struct {
+		name     string
+		content  string
+		maxBytes int64
+		failOpen bool
+		scan     *fakeScanner
+		verdict  string
+		wantErr  error
+	}{
+		{"clean", "hello", 100, false, &fakeScanner{result: clean}, scanner.VerdictClean, nil},
+		{"unavailable fails closed", "hello", 100, false, &fakeScanner{err: unavailable}, "", ErrScanUnavailable},
+		{"unavailable fails open", "hello", 100, true, &fakeScanner{err: unavailable}, scanner.VerdictSkipped, nil},
+		{"too large fails closed", "hello", 3, false, &fakeScanner{result: clean}, "", ErrScanUnavailable},
+		{"too large fails open", "hello", 3, true, &fakeScanner{result: clean}, scanner.VerdictSkipped, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &DocumentService{
+				cfg:     &config.Config{MalwareScanMaxBytes: tt.maxBytes, MalwareScanFailOpen: tt.failOpen},
+				scanner: tt.scan,
+			}
+			content := strings.NewReader(tt.content)
+
+			result, err := s.scanContent(context.Background(), content, "page.txt", int64(len(tt.content)), "tester")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("scanContent error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("scanContent: %v", err)
+			}
+			if result.Verdict != tt.verdict {
+				t.Errorf("verdict = %s, want %s", result.Verdict, tt.verdict)
+			}
+
+			// The content is rewound for whatever forwards it next
+			rest, _ := io.ReadAll(content)
+			if string(rest) != tt.content {
+				t.Errorf("content after scan = %q, want %q", rest, tt.content)
+			}
+		})
+	}
+}
+
+func TestScanContentSendsWholeContent(t *testing.T) {
+	scan := &fakeScanner{result: &scanner.Result{Verdict: scanner.VerdictClean}}
+	s := &DocumentService{cfg: &config.Config{MalwareScanMaxBytes: 1 << 20}, scanner: scan}
+
+	text := strings.Repeat("crawled page text ", 1000)
+	if _, err := s.scanContent(context.Background(), strings.NewReader(text), "page.txt", int64(len(text)), "tester"); err != nil {
+		t.Fatalf("scanContent: %v", err)
+	}
+	if scan.read != text {
+		t.Errorf("scanner read %d bytes, want %d", len(scan.read), len(text))
+	}
+}
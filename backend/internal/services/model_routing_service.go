@@ -0,0 +1,347 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ai-support-assistant/backend/internal/audit"
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/langdetect"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// modelRoutingRefresh bounds how stale the in-memory rules can get across instances
+const modelRoutingRefresh = 30 * time.Second
+
+// compiledRoutingRule is a routing rule prepared for matching
+type compiledRoutingRule struct {
+	rule  models.ModelRoutingRule
+	regex *regexp.Regexp
+}
+
+// routingInput is a query being routed. estimate returns its estimated
+// prompt tokens and is only called if a rule has a token condition.
+type routingInput struct {
+	query       string
+	language    string
+	collections []string
+	estimate    func() int
+}
+
+// ModelRoutingService picks the model answering a query from the routing
+// rules, so the client doesn't have to
+type ModelRoutingService struct {
+	cfg *config.Config
+
+	// calibration scales dry-run estimates for the default model's tokenizer
+	calibration float64
+
+	mu       sync.RWMutex
+	rules    []compiledRoutingRule
+	loadedAt time.Time
+}
+
+func NewModelRoutingService(cfg *config.Config) *ModelRoutingService {
+	// Validated when the config was loaded
+	factors, _ := config.ParseModelFactors(cfg.TokenCalibration)
+
+	return &ModelRoutingService{cfg: cfg, calibration: factors[cfg.OpenAIModel]}
+}
+
+// ValidateRoutingRule checks that a routing rule request can be matched and
+// targets an allowed model
+func (s *ModelRoutingService) ValidateRoutingRule(req models.ModelRoutingRuleRequest) error {
+	if strings.TrimSpace(req.TargetModel) == "" {
+		return validationError("target_model must not be empty")
+	}
+	if !s.modelAllowed(strings.TrimSpace(req.TargetModel)) {
+		return validationError("target_model %q is not in ALLOWED_MODELS", req.TargetModel)
+	}
+	if req.Pattern != "" {
+		if _, err := regexp.Compile(req.Pattern); err != nil {
+			return validationError("invalid regex pattern: %v", err)
+		}
+	}
+	if req.MaxTokens > 0 && req.MinTokens > req.MaxTokens {
+		return validationError("min_tokens must not exceed max_tokens")
+	}
+	if req.MaxLength > 0 && req.MinLength > req.MaxLength {
+		return validationError("min_length must not exceed max_length")
+	}
+	return nil
+}
+
+// modelAllowed reports whether queries may be routed to a model
+func (s *ModelRoutingService) modelAllowed(model string) bool {
+	return len(s.cfg.AllowedModels) == 0 || model == s.cfg.OpenAIModel || slices.Contains(s.cfg.AllowedModels, model)
+}
+
+// Route returns the model for a query: the target of the first enabled rule
+// it matches, or the default model. Rules that fail to load route every
+// query to the default model.
+func (s *ModelRoutingService) Route(in routingInput) models.RoutingDecision {
+	decision := models.RoutingDecision{
+		Model:       s.cfg.OpenAIModel,
+		QueryLength: utf8.RuneCountInString(strings.TrimSpace(in.query)),
+		Language:    in.language,
+	}
+
+	rules, err := s.load()
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to load model routing rules")
+		return decision
+	}
+
+	// The estimate may look up the conversation, so it is made at most once
+	// and only for rules that need it
+	estimated := -1
+	estimate := func() int {
+		if estimated < 0 {
+			estimated = in.estimate()
+		}
+		return estimated
+	}
+
+	normalized := normalizeQuery(in.query)
+	for i := range rules {
+		if rules[i].matches(in, normalized, decision.QueryLength, estimate) {
+			rule := rules[i].rule
+			decision.Model = rule.TargetModel
+			decision.Rule = &rule
+			break
+		}
+	}
+
+	if estimated >= 0 {
+		decision.EstimatedTokens = estimated
+	}
+	return decision
+}
+
+// TestRoute returns the decision Route would make for a sample query, without
+// conversation history unless the request supplies an estimate
+func (s *ModelRoutingService) TestRoute(ctx context.Context, req models.RoutingTestRequest) models.RoutingDecision {
+	language := strings.TrimSpace(req.Language)
+	if language == "" {
+		language = langdetect.Detect(req.Query)
+	}
+
+	in := routingInput{
+		query:       req.Query,
+		language:    language,
+		collections: req.Collections,
+		estimate: func() int {
+			if req.EstimatedTokens != nil {
+				return *req.EstimatedTokens
+			}
+			return basePromptTokens(s.cfg, req.Query, defaultTopK, s.calibration)
+		},
+	}
+
+	decision := s.Route(in)
+	decision.EstimatedTokens = in.estimate()
+	return decision
+}
+
+// CreateRoutingRule saves a new routing rule
+func (s *ModelRoutingService) CreateRoutingRule(ctx context.Context, req models.ModelRoutingRuleRequest) (*models.ModelRoutingRule, error) {
+	if err := s.ValidateRoutingRule(req); err != nil {
+		return nil, err
+	}
+
+	rule := models.ModelRoutingRule{Enabled: true}
+	applyRoutingRuleRequest(&rule, req)
+
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&rule).Error; err != nil {
+			return fmt.Errorf("failed to save routing rule: %w", err)
+		}
+		return audit.Record(ctx, tx, "routing_rule.create", "routing_rule", strconv.FormatUint(uint64(rule.ID), 10), nil, rule)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidate()
+	return &rule, nil
+}
+
+// GetRoutingRules returns all routing rules in evaluation order
+func (s *ModelRoutingService) GetRoutingRules(ctx context.Context) ([]models.ModelRoutingRule, error) {
+	var rules []models.ModelRoutingRule
+
+	if err := db.DB.WithContext(ctx).Order("position ASC, id ASC").Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("failed to get routing rules: %w", err)
+	}
+
+	return rules, nil
+}
+
+// GetRoutingRuleByID returns a routing rule by ID
+func (s *ModelRoutingService) GetRoutingRuleByID(ctx context.Context, id uint) (*models.ModelRoutingRule, error) {
+	var rule models.ModelRoutingRule
+
+	if err := db.DB.WithContext(ctx).First(&rule, id).Error; err != nil {
+		return nil, notFoundError("routing rule", err)
+	}
+
+	return &rule, nil
+}
+
+// UpdateRoutingRule updates a routing rule
+func (s *ModelRoutingService) UpdateRoutingRule(ctx context.Context, id uint, req models.ModelRoutingRuleRequest) (*models.ModelRoutingRule, error) {
+	if err := s.ValidateRoutingRule(req); err != nil {
+		return nil, err
+	}
+
+	rule, err := s.GetRoutingRuleByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *rule
+	applyRoutingRuleRequest(rule, req)
+
+	err = db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(rule).Error; err != nil {
+			return fmt.Errorf("failed to update routing rule: %w", err)
+		}
+		return audit.Record(ctx, tx, "routing_rule.update", "routing_rule", strconv.FormatUint(uint64(id), 10), before, rule)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidate()
+	return rule, nil
+}
+
+// DeleteRoutingRule deletes a routing rule
+func (s *ModelRoutingService) DeleteRoutingRule(ctx context.Context, id uint) error {
+	rule, err := s.GetRoutingRuleByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.ModelRoutingRule{}, id).Error; err != nil {
+			return fmt.Errorf("failed to delete routing rule: %w", err)
+		}
+		return audit.Record(ctx, tx, "routing_rule.delete", "routing_rule", strconv.FormatUint(uint64(id), 10), rule, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.invalidate()
+	return nil
+}
+
+// load returns the compiled enabled rules in evaluation order, refreshing
+// from the database when stale
+func (s *ModelRoutingService) load() ([]compiledRoutingRule, error) {
+	s.mu.RLock()
+	if !s.loadedAt.IsZero() && time.Since(s.loadedAt) < modelRoutingRefresh {
+		rules := s.rules
+		s.mu.RUnlock()
+		return rules, nil
+	}
+	s.mu.RUnlock()
+
+	var stored []models.ModelRoutingRule
+	if err := db.DB.Where("enabled = ?", true).Order("position ASC, id ASC").Find(&stored).Error; err != nil {
+		return nil, err
+	}
+
+	rules := make([]compiledRoutingRule, 0, len(stored))
+	for _, rule := range stored {
+		compiled := compiledRoutingRule{rule: rule}
+		if rule.Pattern != "" {
+			regex, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				logrus.WithError(err).WithField("routing_rule_id", rule.ID).Warn("Skipping routing rule with invalid pattern")
+				continue
+			}
+			compiled.regex = regex
+		}
+		// ALLOWED_MODELS may have changed since the rule was saved
+		if !s.modelAllowed(rule.TargetModel) {
+			logrus.WithFields(logrus.Fields{
+				"routing_rule_id": rule.ID,
+				"model":           rule.TargetModel,
+			}).Warn("Skipping routing rule targeting a model not in ALLOWED_MODELS")
+			continue
+		}
+		rules = append(rules, compiled)
+	}
+
+	s.mu.Lock()
+	s.rules = rules
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+
+	return rules, nil
+}
+
+// invalidate forces the next Route to reload from the database
+func (s *ModelRoutingService) invalidate() {
+	s.mu.Lock()
+	s.loadedAt = time.Time{}
+	s.mu.Unlock()
+}
+
+// matches reports whether a query meets all of the rule's conditions
+func (r *compiledRoutingRule) matches(in routingInput, normalized string, length int, estimate func() int) bool {
+	rule := r.rule
+
+	if rule.MinLength > 0 && length < rule.MinLength {
+		return false
+	}
+	if rule.MaxLength > 0 && length > rule.MaxLength {
+		return false
+	}
+	if rule.Language != "" && !strings.EqualFold(rule.Language, in.language) {
+		return false
+	}
+	if rule.Collection != "" && !slices.Contains(in.collections, rule.Collection) {
+		return false
+	}
+	if r.regex != nil && !r.regex.MatchString(normalized) {
+		return false
+	}
+	// Checked last, as estimating may take a database lookup
+	if rule.MinTokens > 0 && estimate() < rule.MinTokens {
+		return false
+	}
+	if rule.MaxTokens > 0 && estimate() > rule.MaxTokens {
+		return false
+	}
+	return true
+}
+
+// applyRoutingRuleRequest copies request fields onto a routing rule
+func applyRoutingRuleRequest(rule *models.ModelRoutingRule, req models.ModelRoutingRuleRequest) {
+	rule.Name = strings.TrimSpace(req.Name)
+	rule.Position = req.Position
+	rule.MinTokens = req.MinTokens
+	rule.MaxTokens = req.MaxTokens
+	rule.MinLength = req.MinLength
+	rule.MaxLength = req.MaxLength
+	rule.Language = strings.TrimSpace(req.Language)
+	rule.Collection = strings.TrimSpace(req.Collection)
+	rule.Pattern = req.Pattern
+	rule.TargetModel = strings.TrimSpace(req.TargetModel)
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+}
@@ -0,0 +1,420 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/deadletter"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/objectstore"
+	"github.com/sirupsen/logrus"
+)
+
+// progressInterval is how often the bytes read from an object are recorded
+const progressInterval = time.Second
+
+// maxSourceURLLength matches the source_url column
+const maxSourceURLLength = 2048
+
+// errObjectTooLarge stops an object stream once it exceeds the size cap
+var errObjectTooLarge = errors.New("object exceeds the size limit")
+
+// documentExtensionTypes resolves generic content types of common document
+// formats, which the system MIME table may not know
+var documentExtensionTypes = map[string]string{
+	".pdf":  "application/pdf",
+	".txt":  "text/plain",
+	".md":   "text/markdown",
+	".html": "text/html",
+	".htm":  "text/html",
+	".csv":  "text/csv",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+}
+
+// IngestObject validates a request to ingest an object from cloud storage,
+// records the document and streams the object to the RAG service in the
+// background. Progress and failures are recorded on the document.
+func (s *DocumentService) IngestObject(ctx context.Context, req models.ObjectIngestRequest, uploadedBy string) (*models.DocumentUploadResponse, error) {
+	if s.lifecycle.Stopping() {
+		return nil, fmt.Errorf("%w: server is shutting down", ErrOverloaded)
+	}
+
+	src := objectstore.Source{
+		Provider: req.Provider,
+		Bucket:   req.Bucket,
+		Key:      strings.TrimPrefix(req.Key, "/"),
+		URL:      req.URL,
+	}
+	if err := s.validateObjectSource(ctx, src, req.Credentials); err != nil {
+		return nil, err
+	}
+
+	fileName := req.FileName
+	if fileName == "" {
+		fileName = src.Name()
+	}
+	if fileName == "" {
+		return nil, validationError("file_name is required when the key or URL doesn't end in a file name")
+	}
+
+	location := src.Location()
+	if len(location) > maxSourceURLLength {
+		location = location[:maxSourceURLLength]
+	}
+
+	doc := models.Document{
+		FileName:   fileName,
+		Status:     "processing",
+		UploadedBy: uploadedBy,
+		SourceURL:  location,
+	}
+
+	collectionName := ""
+	if req.Collection != "" {
+		collection, err := resolveCollection(req.Collection)
+		if err != nil {
+			return nil, err
+		}
+		doc.CollectionID = &collection.ID
+		collectionName = collection.Name
+	}
+
+	if err := assignVersion(&doc, req.DocumentKey); err != nil {
+		return nil, err
+	}
+	if err := assignVisibility(&doc, req.Visibility); err != nil {
+		return nil, err
+	}
+	if err := s.assignIngestOptions(&doc, req.IngestOptionsRequest); err != nil {
+		return nil, err
+	}
+
+	if err := db.DB.Create(&doc).Error; err != nil {
+		return nil, fmt.Errorf("failed to save document: %w", err)
+	}
+
+	started := s.lifecycle.Go("ingest_object", logrus.Fields{
+		"doc_id":   doc.ID,
+		"provider": src.Provider,
+		"source":   location,
+	}, func(ctx context.Context) {
+		s.ingestObject(ctx, doc, src, req.Credentials, collectionName)
+	})
+	if !started {
+		s.updateDocumentStatus(doc.ID, "failed")
+		return nil, fmt.Errorf("%w: server is shutting down", ErrOverloaded)
+	}
+
+	return &models.DocumentUploadResponse{
+		DocumentID:  doc.ID,
+		FileName:    doc.FileName,
+		DocumentKey: doc.DocumentKey,
+		Version:     doc.Version,
+		Visibility:  doc.Visibility,
+		Status:      "processing",
+		Message:     "Object accepted and is being ingested",
+
+		IngestOptions: doc.IngestOptions,
+	}, nil
+}
+
+// validateObjectSource checks that a source is located either by URL or by
+// bucket and key, that a URL points at a public address and that named
+// credentials exist
+func (s *DocumentService) validateObjectSource(ctx context.Context, src objectstore.Source, credentials string) error {
+	switch {
+	case src.URL != "" && (src.Bucket != "" || src.Key != ""):
+		return fmt.Errorf("%w: give either url or bucket and key, not both", ErrInvalidRequest)
+	case src.URL != "":
+		if !strings.HasPrefix(src.URL, "https://") {
+			return fmt.Errorf("%w: url must be an https URL", ErrInvalidRequest)
+		}
+		if credentials != "" {
+			return fmt.Errorf("%w: credentials only apply to bucket and key sources", ErrInvalidRequest)
+		}
+		// The connection, and any redirect, is checked again when fetching
+		if err := checkDestination(ctx, s.guard, "url", src.URL); err != nil {
+			return err
+		}
+	case src.Provider == objectstore.ProviderHTTPS:
+		return fmt.Errorf("%w: provider https requires url", ErrInvalidRequest)
+	case src.Bucket == "" || src.Key == "":
+		return fmt.Errorf("%w: bucket and key are required without url", ErrInvalidRequest)
+	}
+
+	if credentials != "" && !s.objects.HasCredentials(credentials) {
+		return validationError("unknown credentials %q", credentials)
+	}
+	return nil
+}
+
+// ingestObject fetches an object to a temporary file, capped in size and
+// checked for an allowed content type, and sends it to the RAG service once
+// it passes the malware scan
+func (s *DocumentService) ingestObject(ctx context.Context, doc models.Document, src objectstore.Source, credentials, collectionName string) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(s.cfg.ObjectIngestTimeoutS)*time.Second)
+	defer cancel()
+
+	object, err := s.objects.Open(ctx, src, credentials)
+	if err != nil {
+		err = s.failIngestion(doc.ID, doc.FileName, IngestErrorSourceUnreachable, err, "Failed to fetch object")
+		deadletter.Record(ctx, deadletter.OperationObjectIngest, objectIngestPayloadFor(doc, src, credentials, collectionName), 1, err)
+		return
+	}
+	defer object.Body.Close()
+
+	maxBytes := s.cfg.ObjectIngestMaxBytes
+	if object.Size > maxBytes {
+		s.failIngestion(doc.ID, doc.FileName, IngestErrorSourceTooLarge,
+			fmt.Errorf("object is %d bytes, the limit is %d", object.Size, maxBytes), "Object too large to ingest")
+		return
+	}
+
+	contentType := objectContentType(object.ContentType, doc.FileName)
+	if !s.allowedObjectType(contentType) {
+		s.failIngestion(doc.ID, doc.FileName, IngestErrorUnsupportedType,
+			fmt.Errorf("content type %q is not allowed", contentType), "Unsupported object content type")
+		return
+	}
+
+	updates := map[string]interface{}{"file_type": contentType}
+	if object.Size >= 0 {
+		updates["file_size"] = object.Size
+	}
+	db.DB.Model(&models.Document{}).Where("id = ?", doc.ID).Updates(updates)
+
+	progress := &progressReader{
+		r:     object.Body,
+		limit: maxBytes,
+		report: func(read int64) {
+			db.DB.Model(&models.Document{}).Where("id = ?", doc.ID).Update("bytes_read", read)
+		},
+	}
+
+	// The object is spooled to a temporary file so it can be scanned for
+	// malware before anything is forwarded to the RAG service
+	spool, err := os.CreateTemp("", "object-ingest-*")
+	if err != nil {
+		s.failIngestion(doc.ID, doc.FileName, IngestErrorFailed, err, "Failed to buffer object")
+		return
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	_, copyErr := io.Copy(spool, progress)
+	read, readErr := progress.state()
+	progress.report(read)
+	if readErr != nil || copyErr != nil {
+		code, cause := IngestErrorSourceUnreachable, readErr
+		switch {
+		case errors.Is(readErr, errObjectTooLarge):
+			code = IngestErrorSourceTooLarge
+		case readErr == nil:
+			// Writing the spool failed
+			code, cause = IngestErrorFailed, copyErr
+		case ctx.Err() != nil:
+			code = IngestErrorFailed
+		}
+		err = s.failIngestion(doc.ID, doc.FileName, code, cause, "Failed to fetch object")
+		if code != IngestErrorSourceTooLarge {
+			deadletter.Record(ctx, deadletter.OperationObjectIngest, objectIngestPayloadFor(doc, src, credentials, collectionName), 1, err)
+		}
+		return
+	}
+	if object.Size < 0 {
+		db.DB.Model(&models.Document{}).Where("id = ?", doc.ID).Update("file_size", read)
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		s.failIngestion(doc.ID, doc.FileName, IngestErrorFailed, err, "Failed to buffer object")
+		return
+	}
+
+	scan, err := s.scanContent(ctx, spool, doc.FileName, read, doc.UploadedBy)
+	if err != nil {
+		code := IngestErrorScanUnavailable
+		if errors.Is(err, ErrMalwareDetected) {
+			code = IngestErrorMalwareDetected
+		}
+		s.failIngestion(doc.ID, doc.FileName, code, err, "Object rejected by the malware scan")
+		return
+	}
+	db.DB.Model(&models.Document{}).Where("id = ?", doc.ID).Updates(map[string]interface{}{
+		"scan_verdict": scan.Verdict,
+		"scan_engine":  scan.Engine,
+	})
+
+	fields := map[string]string{"source_url": doc.SourceURL, "visibility": doc.Visibility}
+	if collectionName != "" {
+		fields["collection"] = collectionName
+	}
+	addIngestOptionFields(fields, doc.IngestOptions)
+
+	ingestResp, err := s.ingestWithProgress(ctx, doc.ID, RAGIngestRequest{
+		FileName: doc.FileName,
+		Content:  spool,
+		Fields:   fields,
+	})
+	if err != nil {
+		err = s.failIngestion(doc.ID, doc.FileName, IngestErrorFailed, err, "Failed to ingest object")
+		deadletter.Record(ctx, deadletter.OperationObjectIngest, objectIngestPayloadFor(doc, src, credentials, collectionName), 1, err)
+		return
+	}
+
+	s.completeIngestion(ctx, doc.ID, doc.FileName, ingestResp)
+}
+
+// objectIngestPayload is the dead-letter payload of a failed object
+// ingestion. Credentials are the name of a configured credential set.
+type objectIngestPayload struct {
+	DocumentID  uint   `json:"document_id"`
+	Provider    string `json:"provider,omitempty"`
+	Bucket      string `json:"bucket,omitempty"`
+	Key         string `json:"key,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Credentials string `json:"credentials,omitempty"`
+	Collection  string `json:"collection,omitempty"`
+}
+
+func objectIngestPayloadFor(doc models.Document, src objectstore.Source, credentials, collectionName string) objectIngestPayload {
+	return objectIngestPayload{
+		DocumentID:  doc.ID,
+		Provider:    src.Provider,
+		Bucket:      src.Bucket,
+		Key:         src.Key,
+		URL:         src.URL,
+		Credentials: credentials,
+		Collection:  collectionName,
+	}
+}
+
+// ReplayObjectIngest ingests a dead-lettered object again into the same
+// document, which must still be marked failed. Objects whose URL carried a
+// signature were stored with it redacted and can't be fetched again.
+func (s *DocumentService) ReplayObjectIngest(ctx context.Context, payload json.RawMessage) error {
+	var p objectIngestPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return fmt.Errorf("%w: invalid payload: %v", deadletter.ErrNotReplayable, err)
+	}
+
+	var doc models.Document
+	if err := db.DB.WithContext(ctx).First(&doc, p.DocumentID).Error; err != nil {
+		return replayLookupError("document", p.DocumentID, err)
+	}
+	if doc.Status != "failed" {
+		return fmt.Errorf("%w: document %d is %s", deadletter.ErrNotReplayable, doc.ID, doc.Status)
+	}
+	if p.Credentials != "" && !s.objects.HasCredentials(p.Credentials) {
+		return fmt.Errorf("%w: credentials %q are no longer configured", deadletter.ErrNotReplayable, p.Credentials)
+	}
+
+	err := db.DB.WithContext(ctx).Model(&doc).Updates(map[string]interface{}{
+		"status":           "processing",
+		"error_code":       "",
+		"error_message":    "",
+		"bytes_read":       0,
+		"progress_percent": nil,
+		"chunks_processed": 0,
+		"chunks_total":     0,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to reset document: %w", err)
+	}
+	doc.Status = "processing"
+
+	src := objectstore.Source{Provider: p.Provider, Bucket: p.Bucket, Key: p.Key, URL: p.URL}
+	started := s.lifecycle.Go("ingest_object", logrus.Fields{
+		"doc_id":   doc.ID,
+		"provider": src.Provider,
+		"source":   doc.SourceURL,
+	}, func(ctx context.Context) {
+		s.ingestObject(ctx, doc, src, p.Credentials, p.Collection)
+	})
+	if !started {
+		s.updateDocumentStatus(doc.ID, "failed")
+		return fmt.Errorf("%w: server is shutting down", ErrOverloaded)
+	}
+	return nil
+}
+
+// allowedObjectType reports whether a content type may be ingested
+func (s *DocumentService) allowedObjectType(contentType string) bool {
+	for _, allowed := range s.cfg.ObjectIngestAllowedTypes {
+		if strings.EqualFold(contentType, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// objectContentType returns the media type of an object, falling back to the
+// file extension when the store only reports a generic binary type
+func objectContentType(header, fileName string) string {
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err == nil && mediaType != "application/octet-stream" && mediaType != "binary/octet-stream" {
+		return mediaType
+	}
+
+	ext := strings.ToLower(filepath.Ext(fileName))
+	if contentType, ok := documentExtensionTypes[ext]; ok {
+		return contentType
+	}
+	if mediaType, _, err := mime.ParseMediaType(mime.TypeByExtension(ext)); err == nil {
+		return mediaType
+	}
+	return "application/octet-stream"
+}
+
+// progressReader counts bytes read from an object, reporting the count at
+// most once per progressInterval, and fails once the limit is exceeded. It
+// keeps the first read error so it can be told apart from RAG failures.
+// Transports may read from another goroutine, so state is guarded.
+type progressReader struct {
+	r      io.Reader
+	limit  int64
+	report func(read int64)
+
+	mu       sync.Mutex
+	read     int64
+	reported time.Time
+	err      error
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.read += int64(n)
+
+	if p.read > p.limit {
+		p.err = errObjectTooLarge
+		return n, p.err
+	}
+	if err != nil && err != io.EOF && p.err == nil {
+		p.err = err
+	}
+
+	if time.Since(p.reported) >= progressInterval {
+		p.reported = time.Now()
+		p.report(p.read)
+	}
+	return n, err
+}
+
+// state returns the bytes read so far and the first read error
+func (p *progressReader) state() (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.read, p.err
+}
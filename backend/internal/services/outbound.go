@@ -0,0 +1,28 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/netguard"
+)
+
+// outboundGuard builds the guard for requests to caller-supplied URLs; the
+// allowed CIDRs were validated when the config loaded
+func outboundGuard(cfg *config.Config) *netguard.Guard {
+	guard, _ := netguard.New(cfg.OutboundAllowedCIDRs)
+	return guard
+}
+
+// checkDestination rejects a caller-supplied URL that resolves to a
+// loopback, private or link-local address, naming the field that held it
+func checkDestination(ctx context.Context, guard *netguard.Guard, field, raw string) error {
+	if err := guard.CheckURL(ctx, raw); err != nil {
+		if errors.Is(err, netguard.ErrBlockedDestination) {
+			return validationError("%s must point at a public address", field)
+		}
+		return validationError("%s: %v", field, err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/langdetect"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/moderation"
+)
+
+// PreviewPersona answers a sample question in a persona as the opening query
+// of a customer conversation would be answered, with the active prompt,
+// model routing, moderation, verification and response language. Nothing is
+// stored: no query is logged, no answer cached and no quota counted.
+func (s *QueryService) PreviewPersona(ctx context.Context, personaID uint, query string) (*models.PersonaPreview, error) {
+	persona, err := s.personas.GetPersonaByID(ctx, personaID)
+	if err != nil {
+		return nil, err
+	}
+	startTime := time.Now()
+
+	// Each preview gets a fresh session so no history is drawn on
+	req := models.QueryRequest{
+		Query:        query,
+		SessionID:    "persona-preview-" + newGenerationID(),
+		Audience:     models.AudienceCustomer,
+		ContextReset: true,
+	}
+	language := langdetect.Detect(req.Query)
+	ragReq := s.ragRequest(req, language, s.prompts.Active(ctx), nil, persona)
+
+	preview := &models.PersonaPreview{
+		PersonaID:      persona.ID,
+		PersonaVersion: persona.Version,
+		Query:          query,
+		Language:       language,
+	}
+
+	enforce := s.settings.ModerationMode() == moderation.ModeEnforce
+	if flagged, _ := s.moderate(ctx, "query", req.Query); flagged && enforce {
+		preview.Response = s.settings.ModerationRefusalMessage()
+		preview.Context = []string{}
+		preview.Model = ModerationModel
+		preview.Moderated = true
+		preview.Latency = int(time.Since(startTime).Milliseconds())
+		return preview, nil
+	}
+
+	if !s.health.RAGAvailable() {
+		return nil, &DegradedError{RetryAfter: s.health.ProbeInterval()}
+	}
+	s.routeModel(ctx, &ragReq, true)
+	if _, err := s.budgetPrompt(ctx, &ragReq, true); err != nil {
+		return nil, err
+	}
+
+	ragResp, err := s.callRAGService(ctx, ragReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call RAG service: %w", err)
+	}
+
+	if flagged, _ := s.moderate(ctx, "response", ragResp.Response); flagged && enforce {
+		ragResp.Response = s.settings.ModerationRefusalMessage()
+		ragResp.Context = []string{}
+		preview.Moderated = true
+	}
+
+	var verdict grounding
+	if !preview.Moderated {
+		verdict = s.verifyGrounding(ctx, req.Query, ragResp)
+		if verdict.lowConfidence {
+			ragResp.Response = s.cfg.LowConfidenceMessage
+		} else {
+			preview.Translated = s.enforceResponseLanguage(ctx, ragReq.ResponseLanguage, ragResp)
+		}
+	}
+
+	preview.Response = ragResp.Response
+	preview.Context = ragResp.Context
+	preview.Model = ragResp.Model
+	preview.TokensUsed = ragResp.TokensUsed
+	preview.GroundingScore = verdict.score
+	preview.LowConfidence = verdict.lowConfidence
+	preview.Latency = int(time.Since(startTime).Milliseconds())
+	return preview, nil
+}
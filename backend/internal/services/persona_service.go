@@ -0,0 +1,268 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ai-support-assistant/backend/internal/audit"
+	"github.com/ai-support-assistant/backend/internal/cache"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// maxPersonaPromptRunes caps the system prompt snippet, which is sent with
+// every query answered in the persona
+const maxPersonaPromptRunes = 2000
+
+// personaCacheTTL bounds how long a persona is cached by ID
+const personaCacheTTL = 5 * time.Minute
+
+// personaDenylist matches text that tries to override the system prompt
+// rather than shape the voice of answers
+var personaDenylist = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override)\b.{0,40}\b(instructions?|rules|prompts?|guidelines)\b`),
+	regexp.MustCompile(`(?i)\b(reveal|print|repeat|show|output)\b.{0,40}\b(system|hidden|original)\s+(prompt|instructions?)\b`),
+	regexp.MustCompile(`(?i)\byou\s+are\s+no\s+longer\b`),
+	regexp.MustCompile(`(?i)\b(developer|jailbreak|dan)\s+mode\b`),
+	regexp.MustCompile(`(?i)\bdo\s+anything\s+now\b`),
+	regexp.MustCompile(`(?i)</?\s*(system|assistant|user)\s*>`),
+	regexp.MustCompile(`(?i)^\s*(system|assistant)\s*:`),
+}
+
+// PersonaService manages the personas widget configs give answers in
+type PersonaService struct {
+	widgets *WidgetService
+}
+
+func NewPersonaService(widgets *WidgetService) *PersonaService {
+	return &PersonaService{widgets: widgets}
+}
+
+// ValidatePersona checks a persona request stays within the size limit and
+// none of its text tries to override the system prompt
+func ValidatePersona(req models.PersonaRequest) error {
+	if strings.TrimSpace(req.Name) == "" {
+		return validationError("name must not be blank")
+	}
+	if utf8.RuneCountInString(req.Prompt) > maxPersonaPromptRunes {
+		return validationError("prompt must be at most %d characters", maxPersonaPromptRunes)
+	}
+
+	fields := []struct{ name, text string }{
+		{"prompt", req.Prompt},
+		{"tone", req.Tone},
+		{"signature", req.Signature},
+	}
+	for _, field := range fields {
+		for _, line := range strings.Split(field.text, "\n") {
+			for _, pattern := range personaDenylist {
+				if pattern.MatchString(line) {
+					return validationError("%s contains text that tries to override the system prompt", field.name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validate checks a persona request beyond what binding validates.
+// excludeID is the persona being updated, or 0 when creating.
+func (s *PersonaService) validate(ctx context.Context, req models.PersonaRequest, excludeID uint) error {
+	if err := ValidatePersona(req); err != nil {
+		return err
+	}
+
+	var count int64
+	query := db.DB.WithContext(ctx).Model(&models.Persona{}).Where("name = ?", strings.TrimSpace(req.Name))
+	if excludeID != 0 {
+		query = query.Where("id <> ?", excludeID)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check persona: %w", err)
+	}
+	if count > 0 {
+		return validationError("persona %q already exists", strings.TrimSpace(req.Name))
+	}
+	return nil
+}
+
+// CreatePersona saves a new persona at version 1
+func (s *PersonaService) CreatePersona(ctx context.Context, req models.PersonaRequest) (*models.Persona, error) {
+	if err := s.validate(ctx, req, 0); err != nil {
+		return nil, err
+	}
+
+	persona := models.Persona{Version: 1}
+	applyPersonaRequest(&persona, req)
+
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&persona).Error; err != nil {
+			return fmt.Errorf("failed to save persona: %w", err)
+		}
+		return audit.Record(ctx, tx, "persona.create", "persona", strconv.FormatUint(uint64(persona.ID), 10), nil, persona)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &persona, nil
+}
+
+// GetPersonas returns all personas by name
+func (s *PersonaService) GetPersonas(ctx context.Context) ([]models.Persona, error) {
+	var personas []models.Persona
+
+	if err := db.DB.WithContext(ctx).Order("name ASC").Find(&personas).Error; err != nil {
+		return nil, fmt.Errorf("failed to get personas: %w", err)
+	}
+
+	return personas, nil
+}
+
+// GetPersonaByID returns a persona by ID
+func (s *PersonaService) GetPersonaByID(ctx context.Context, id uint) (*models.Persona, error) {
+	var persona models.Persona
+
+	if err := db.DB.WithContext(ctx).First(&persona, id).Error; err != nil {
+		return nil, notFoundError("persona", err)
+	}
+
+	return &persona, nil
+}
+
+// UpdatePersona updates a persona, bumping its version when anything
+// answers depend on changed
+func (s *PersonaService) UpdatePersona(ctx context.Context, id uint, req models.PersonaRequest) (*models.Persona, error) {
+	if err := s.validate(ctx, req, id); err != nil {
+		return nil, err
+	}
+
+	persona, err := s.GetPersonaByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *persona
+	applyPersonaRequest(persona, req)
+	if persona.Prompt != before.Prompt || persona.Tone != before.Tone || persona.Signature != before.Signature {
+		persona.Version++
+	}
+
+	err = db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(persona).Error; err != nil {
+			return fmt.Errorf("failed to update persona: %w", err)
+		}
+		return audit.Record(ctx, tx, "persona.update", "persona", strconv.FormatUint(uint64(id), 10), before, persona)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidate(ctx, id)
+	return persona, nil
+}
+
+// DeletePersona deletes a persona; widget configs using it go back to
+// answering without one
+func (s *PersonaService) DeletePersona(ctx context.Context, id uint) error {
+	persona, err := s.GetPersonaByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.WidgetConfig{}).Where("persona_id = ?", id).Update("persona_id", nil).Error; err != nil {
+			return fmt.Errorf("failed to detach persona from widget configs: %w", err)
+		}
+		if err := tx.Delete(&models.Persona{}, id).Error; err != nil {
+			return fmt.Errorf("failed to delete persona: %w", err)
+		}
+		return audit.Record(ctx, tx, "persona.delete", "persona", strconv.FormatUint(uint64(id), 10), persona, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.invalidate(ctx, id)
+	s.widgets.invalidate(ctx)
+	return nil
+}
+
+// ForQuery returns the persona a query is answered in: the one named by the
+// request, or else the one of the widget config resolved for its origin.
+// Regenerations only keep the original's persona. A persona that can't be
+// loaded is logged and left out.
+func (s *PersonaService) ForQuery(ctx context.Context, req models.QueryRequest) *models.Persona {
+	id := req.PersonaID
+	if id == nil && req.ParentQueryID == nil {
+		id = s.widgets.PersonaID(ctx, req.Origin)
+	}
+	if id == nil {
+		return nil
+	}
+
+	cacheKey := personaCacheKey(*id)
+	var persona models.Persona
+	if err := cache.Get(ctx, cacheKey, &persona); err == nil {
+		return &persona
+	}
+
+	if err := db.DB.WithContext(ctx).First(&persona, *id).Error; err != nil {
+		logrus.WithError(err).WithField("persona_id", *id).Warn("Failed to load persona")
+		return nil
+	}
+	if err := cache.Set(ctx, cacheKey, persona, personaCacheTTL); err != nil {
+		logrus.WithError(err).Debug("Failed to cache persona")
+	}
+	return &persona
+}
+
+// invalidate drops a persona's cached copy
+func (s *PersonaService) invalidate(ctx context.Context, id uint) {
+	if cache.Client == nil {
+		return
+	}
+	if err := cache.Delete(ctx, personaCacheKey(id)); err != nil {
+		logrus.WithError(err).WithField("persona_id", id).Warn("Failed to invalidate cached persona")
+	}
+}
+
+// personaCacheKey is the Redis key caching a persona
+func personaCacheKey(id uint) string {
+	return "persona:" + strconv.FormatUint(uint64(id), 10)
+}
+
+// applyPersonaRequest copies a request's fields onto a persona
+func applyPersonaRequest(persona *models.Persona, req models.PersonaRequest) {
+	persona.Name = strings.TrimSpace(req.Name)
+	persona.Prompt = strings.TrimSpace(req.Prompt)
+	persona.Tone = strings.TrimSpace(req.Tone)
+	persona.Signature = strings.TrimSpace(req.Signature)
+}
+
+// ragPersona is the persona as sent to the RAG service
+func ragPersona(persona *models.Persona) *RAGPersona {
+	if persona == nil {
+		return nil
+	}
+	return &RAGPersona{
+		Prompt:    persona.Prompt,
+		Tone:      persona.Tone,
+		Signature: persona.Signature,
+	}
+}
+
+// personaKey distinguishes personas in coalescing keys
+func personaKey(persona *RAGPersona) string {
+	if persona == nil {
+		return ""
+	}
+	return persona.Prompt + "\x00" + persona.Tone + "\x00" + persona.Signature
+}
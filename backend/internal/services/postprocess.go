@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/postprocess"
+)
+
+// newResponsePipeline builds the response post-processing steps enabled in config
+func newResponsePipeline(cfg *config.Config) *postprocess.Pipeline {
+	var steps []postprocess.Step
+	if cfg.PostprocessSanitizeHTML {
+		steps = append(steps, postprocess.SanitizeHTML{})
+	}
+	if cfg.PostprocessRewriteLinks {
+		steps = append(steps, postprocess.DocumentLinks{Resolve: resolveDocumentLinks})
+	}
+	if cfg.ResponseMaxLength > 0 {
+		steps = append(steps, postprocess.Truncate{MaxLength: cfg.ResponseMaxLength, Marker: cfg.ResponseTruncationMarker})
+	}
+	if cfg.PostprocessNormalizeMarkdown {
+		steps = append(steps, postprocess.NormalizeMarkdown{})
+	}
+	return postprocess.NewPipeline(steps...)
+}
+
+// postProcess cleans up a RAG response in place before it is stored or cached
+func (s *QueryService) postProcess(ctx context.Context, resp *RAGQueryResponse) {
+	processed := postprocess.Response{Text: resp.Response}
+	for _, source := range resp.Sources {
+		processed.Sources = append(processed.Sources, postprocess.Source{
+			FileName:      source.Source,
+			VectorStoreID: source.DocID,
+		})
+	}
+
+	s.pipeline.Process(ctx, &processed)
+	resp.Response = processed.Text
+}
+
+// resolveDocumentLinks maps referenced file names to ingested documents. When
+// several documents share a name, one the answer was retrieved from wins,
+// then the most recent.
+func resolveDocumentLinks(ctx context.Context, fileNames []string, sources []postprocess.Source) (map[string]uint, error) {
+	var documents []models.Document
+	err := db.DB.WithContext(ctx).
+		Select("id", "file_name", "vector_store_id").
+		Where("file_name IN ? AND status = ?", fileNames, "completed").
+		Order("id DESC").
+		Find(&documents).Error
+	if err != nil {
+		return nil, err
+	}
+
+	retrieved := make(map[string]bool, len(sources))
+	for _, source := range sources {
+		if source.VectorStoreID != "" {
+			retrieved[source.VectorStoreID] = true
+		}
+	}
+
+	ids := make(map[string]uint, len(fileNames))
+	for _, document := range documents {
+		if _, ok := ids[document.FileName]; !ok || retrieved[document.VectorStoreID] {
+			ids[document.FileName] = document.ID
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"math"
+
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/middleware"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/tokens"
+	"github.com/sirupsen/logrus"
+)
+
+// estimatedCharsPerToken converts an excess of tokens into characters to trim
+const estimatedCharsPerToken = 4
+
+// budgetPrompt estimates the tokens of the prompt the RAG service will build
+// for ragReq and checks them against the model's context window. A prompt
+// over the limit loses its oldest history turns if AUTO_TRIM_HISTORY is set,
+// capping ragReq's history; otherwise, or if it still doesn't fit, a
+// PromptTooLongError is returned. The estimate is returned either way.
+func (s *QueryService) budgetPrompt(ctx context.Context, ragReq *RAGQueryRequest, opening bool) (int, error) {
+	model := ragReq.Model
+	if model == "" {
+		model = s.cfg.OpenAIModel
+	}
+	limit, ok := s.contextLimits[model]
+	if !ok {
+		limit = s.cfg.ModelContextLimit
+	}
+	factor := s.tokenCalibration[model]
+
+	total, turns := s.estimatePrompt(ctx, *ragReq, opening, factor)
+
+	if limit == 0 || total <= limit {
+		return total, nil
+	}
+
+	if s.cfg.AutoTrimHistory {
+		kept := len(turns)
+		for kept > 0 && total > limit {
+			kept--
+			total -= turns[kept]
+		}
+		if total <= limit {
+			ragReq.HistoryTurns = &kept
+			middleware.RecordPromptOverBudget("trimmed")
+			logrus.WithFields(logrus.Fields{
+				"session_id":    ragReq.SessionID,
+				"history_turns": kept,
+				"dropped_turns": len(turns) - kept,
+				"estimated":     total,
+				"limit":         limit,
+			}).Info("Trimmed conversation history to fit the context window")
+			return total, nil
+		}
+	}
+
+	middleware.RecordPromptOverBudget("rejected")
+	excess := total - limit
+	trimChars := estimatedCharsPerToken * excess
+	if factor > 0 {
+		trimChars = int(math.Ceil(float64(trimChars) / factor))
+	}
+	return total, &PromptTooLongError{Estimated: total, Limit: limit, TrimChars: trimChars}
+}
+
+// estimatePrompt estimates the tokens of the prompt for ragReq, returning
+// the total and the history turns it includes, newest first
+func (s *QueryService) estimatePrompt(ctx context.Context, ragReq RAGQueryRequest, opening bool, factor float64) (int, []int) {
+	total := basePromptTokens(s.cfg, ragReq.Query, ragReq.TopK, factor)
+	if ragReq.Persona != nil {
+		total += tokens.Calibrated(tokens.Estimate(ragReq.Persona.Prompt+" "+ragReq.Persona.Tone), factor)
+	}
+
+	// A query opening a conversation has no history
+	var turns []int
+	if !opening && !ragReq.ContextReset {
+		turns = s.historyTokens(ctx, ragReq.SessionID, ragReq.Segment, factor)
+	}
+	for _, turn := range turns {
+		total += turn
+	}
+	return total, turns
+}
+
+// basePromptTokens estimates the tokens of a prompt without conversation
+// history: the query, topK retrieved chunks and the reserve for the template
+// and answer
+func basePromptTokens(cfg *config.Config, query string, topK int, factor float64) int {
+	return tokens.Calibrated(tokens.Estimate(query), factor) + topK*cfg.PromptChunkTokens + cfg.PromptReserveTokens
+}
+
+// historyTokens estimates the turns of a conversation segment the RAG service
+// may include in the prompt, newest first. A failed lookup counts no history
+// rather than failing the query.
+func (s *QueryService) historyTokens(ctx context.Context, sessionID string, segment int, factor float64) []int {
+	if s.cfg.PromptHistoryTurns == 0 {
+		return nil
+	}
+
+	var history []models.ChatQuery
+	err := db.DB.WithContext(ctx).
+		Select("query", "response").
+		Where("session_id = ? AND segment = ?", sessionID, segment).
+		Order("created_at DESC").
+		Limit(s.cfg.PromptHistoryTurns).
+		Find(&history).Error
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to load session history for prompt estimate")
+		return nil
+	}
+
+	turns := make([]int, len(history))
+	for i, turn := range history {
+		turns[i] = tokens.Calibrated(tokens.Estimate(turn.Query)+tokens.Estimate(turn.Response), factor)
+	}
+	return turns
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// promptRefresh bounds how long other instances keep using a previously active prompt
+const promptRefresh = 30 * time.Second
+
+type PromptService struct {
+	mu       sync.RWMutex
+	active   *models.PromptTemplate
+	loadedAt time.Time
+}
+
+func NewPromptService() *PromptService {
+	return &PromptService{}
+}
+
+// Active returns the active prompt template, or nil when the RAG service default is in use
+func (s *PromptService) Active(ctx context.Context) *models.PromptTemplate {
+	s.mu.RLock()
+	if !s.loadedAt.IsZero() && time.Since(s.loadedAt) < promptRefresh {
+		active := s.active
+		s.mu.RUnlock()
+		return active
+	}
+	s.mu.RUnlock()
+
+	var template models.PromptTemplate
+	err := db.DB.Where("active = ?", true).Order("updated_at DESC").First(&template).Error
+
+	var active *models.PromptTemplate
+	switch {
+	case err == nil:
+		active = &template
+	case errors.Is(err, gorm.ErrRecordNotFound):
+	default:
+		// Keep using the last known prompt rather than silently dropping it
+		logrus.WithError(err).Warn("Failed to load active prompt template")
+		s.mu.RLock()
+		active = s.active
+		s.mu.RUnlock()
+		return active
+	}
+
+	s.mu.Lock()
+	s.active = active
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+
+	return active
+}
+
+// CreatePromptTemplate saves a new, inactive prompt template
+func (s *PromptService) CreatePromptTemplate(ctx context.Context, req models.PromptTemplateRequest) (*models.PromptTemplate, error) {
+	template := models.PromptTemplate{
+		Name:    req.Name,
+		Version: req.Version,
+		Body:    req.Body,
+	}
+
+	if err := s.ensureUnique(req.Name, req.Version, 0); err != nil {
+		return nil, err
+	}
+
+	if err := db.DB.Create(&template).Error; err != nil {
+		return nil, fmt.Errorf("failed to save prompt template: %w", err)
+	}
+
+	return &template, nil
+}
+
+// GetPromptTemplates returns all prompt templates, newest versions first
+func (s *PromptService) GetPromptTemplates(ctx context.Context) ([]models.PromptTemplate, error) {
+	var templates []models.PromptTemplate
+
+	if err := db.DB.Order("name ASC, version DESC").Find(&templates).Error; err != nil {
+		return nil, fmt.Errorf("failed to get prompt templates: %w", err)
+	}
+
+	return templates, nil
+}
+
+// GetPromptTemplateByID returns a prompt template by ID
+func (s *PromptService) GetPromptTemplateByID(ctx context.Context, id uint) (*models.PromptTemplate, error) {
+	var template models.PromptTemplate
+
+	if err := db.DB.First(&template, id).Error; err != nil {
+		return nil, notFoundError("prompt template", err)
+	}
+
+	return &template, nil
+}
+
+// GetPromptTemplateByVersion returns a prompt template by name and version
+func (s *PromptService) GetPromptTemplateByVersion(ctx context.Context, name string, version int) (*models.PromptTemplate, error) {
+	var template models.PromptTemplate
+
+	if err := db.DB.Where("name = ? AND version = ?", name, version).First(&template).Error; err != nil {
+		return nil, notFoundError("prompt template", err)
+	}
+
+	return &template, nil
+}
+
+// UpdatePromptTemplate updates a prompt template. Editing the active template
+// changes the cache namespace, so cached answers are regenerated.
+func (s *PromptService) UpdatePromptTemplate(ctx context.Context, id uint, req models.PromptTemplateRequest) (*models.PromptTemplate, error) {
+	template, err := s.GetPromptTemplateByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.ensureUnique(req.Name, req.Version, id); err != nil {
+		return nil, err
+	}
+
+	template.Name = req.Name
+	template.Version = req.Version
+	template.Body = req.Body
+
+	if err := db.DB.Save(template).Error; err != nil {
+		return nil, fmt.Errorf("failed to update prompt template: %w", err)
+	}
+
+	if template.Active {
+		s.invalidate()
+	}
+	return template, nil
+}
+
+// DeletePromptTemplate deletes an inactive prompt template
+func (s *PromptService) DeletePromptTemplate(ctx context.Context, id uint) error {
+	template, err := s.GetPromptTemplateByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if template.Active {
+		return validationError("cannot delete the active prompt template; activate another version first")
+	}
+
+	if err := db.DB.Delete(&models.PromptTemplate{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete prompt template: %w", err)
+	}
+
+	return nil
+}
+
+// ActivatePromptTemplate makes a template the only active one. New queries use it
+// immediately on this instance and within promptRefresh on others.
+func (s *PromptService) ActivatePromptTemplate(ctx context.Context, id uint) (*models.PromptTemplate, error) {
+	template, err := s.GetPromptTemplateByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.PromptTemplate{}).Where("active = ? AND id <> ?", true, id).Update("active", false).Error; err != nil {
+			return err
+		}
+		return tx.Model(template).Update("active", true).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to activate prompt template: %w", err)
+	}
+	template.Active = true
+
+	s.invalidate()
+
+	logrus.WithFields(logrus.Fields{
+		"prompt_template": template.Name,
+		"prompt_version":  template.Version,
+	}).Info("Activated prompt template")
+
+	return template, nil
+}
+
+// ensureUnique rejects a name and version already used by another template
+func (s *PromptService) ensureUnique(name string, version int, excludeID uint) error {
+	var count int64
+	query := db.DB.Model(&models.PromptTemplate{}).Where("name = ? AND version = ?", name, version)
+	if excludeID != 0 {
+		query = query.Where("id <> ?", excludeID)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check prompt template: %w", err)
+	}
+	if count > 0 {
+		return validationError("prompt template %q version %d already exists", name, version)
+	}
+	return nil
+}
+
+// invalidate forces the next Active call to reload from the database
+func (s *PromptService) invalidate() {
+	s.mu.Lock()
+	s.loadedAt = time.Time{}
+	s.mu.Unlock()
+}
+
+// promptCacheNamespace identifies a prompt in response cache keys, so answers generated
+// under a previous prompt, or a previous body of the same version, are never served
+func promptCacheNamespace(template *models.PromptTemplate) string {
+	if template == nil {
+		return "default"
+	}
+	return fmt.Sprintf("%s:%d:%d", template.Name, template.Version, template.UpdatedAt.UnixNano())
+}
@@ -0,0 +1,527 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/audit"
+	"github.com/ai-support-assistant/backend/internal/cache"
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/lifecycle"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// PurgeDryRun is the status of a purge job recorded by a dry run and not yet
+// confirmed; confirmed jobs use the query job statuses
+const PurgeDryRun = "dry_run"
+
+// A dry run must be confirmed within purgeDryRunTTL, before its counts go stale
+const purgeDryRunTTL = time.Hour
+
+// A pending or running purge whose heartbeat is older than purgeStaleAfter
+// was abandoned by its instance and is resumed by whichever instance claims
+// it first; instances look for them every purgeResumeInterval
+const (
+	purgeStaleAfter     = 2 * time.Minute
+	purgeResumeInterval = time.Minute
+)
+
+// purgeLockKey is the Postgres advisory lock serializing purge confirmations,
+// so two overlapping purges can't both pass the overlap check
+const purgeLockKey = 0x7075726765
+
+// errPurgeTakenOver stops a purge whose job another instance has advanced
+var errPurgeTakenOver = errors.New("purge taken over by another instance")
+
+// purgeRow is a chat query selected for deletion
+type purgeRow struct {
+	ID       uint
+	CacheKey string
+}
+
+// PurgeService deletes chat history matching a filter in throttled batches.
+// Purges are recorded as jobs so their progress can be polled and an
+// interrupted purge resumes where it stopped.
+type PurgeService struct {
+	batchSize int
+	delay     time.Duration
+	lifecycle *lifecycle.Manager
+}
+
+func NewPurgeService(cfg *config.Config, lc *lifecycle.Manager) *PurgeService {
+	return &PurgeService{
+		batchSize: cfg.PurgeBatchSize,
+		delay:     time.Duration(cfg.PurgeBatchDelayMs) * time.Millisecond,
+		lifecycle: lc,
+	}
+}
+
+// Start resumes purges interrupted by a restart, and keeps looking for purges
+// abandoned by other instances, until ctx is cancelled
+func (s *PurgeService) Start(ctx context.Context) {
+	go func() {
+		s.resumeAbandoned()
+
+		ticker := time.NewTicker(purgeResumeInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.resumeAbandoned()
+			}
+		}
+	}()
+}
+
+// Purge handles a purge request: a dry run records the rows the filter
+// matches, and confirming a dry run by ID starts deleting them
+func (s *PurgeService) Purge(ctx context.Context, req models.PurgeRequest) (*models.PurgeJob, error) {
+	if req.DryRunID != nil {
+		return s.startPurge(ctx, *req.DryRunID)
+	}
+	if !req.DryRun {
+		return nil, validationError("a purge must start with a dry_run; confirm it by passing its id as dry_run_id")
+	}
+	return s.dryRun(ctx, req)
+}
+
+// GetPurgeJob returns a purge job with its progress counters
+func (s *PurgeService) GetPurgeJob(ctx context.Context, id uint) (*models.PurgeJob, error) {
+	var job models.PurgeJob
+	if err := db.DB.WithContext(ctx).First(&job, id).Error; err != nil {
+		return nil, notFoundError("purge job", err)
+	}
+
+	return &job, nil
+}
+
+// dryRun validates a filter and records the rows it matches
+func (s *PurgeService) dryRun(ctx context.Context, req models.PurgeRequest) (*models.PurgeJob, error) {
+	job := models.PurgeJob{
+		UserID:    strings.TrimSpace(req.UserID),
+		After:     req.After,
+		Before:    req.Before,
+		Status:    PurgeDryRun,
+		CreatedBy: audit.ActorFrom(ctx).UserID,
+	}
+
+	seen := make(map[string]bool, len(req.SessionIDs))
+	for _, sessionID := range req.SessionIDs {
+		sessionID = strings.TrimSpace(sessionID)
+		if sessionID != "" && !seen[sessionID] {
+			seen[sessionID] = true
+			job.SessionIDs = append(job.SessionIDs, sessionID)
+		}
+	}
+
+	if len(job.SessionIDs) == 0 && job.UserID == "" && job.After == nil && job.Before == nil {
+		return nil, validationError("at least one of session_ids, user_id, after or before is required")
+	}
+	if job.After != nil && job.Before != nil && !job.After.Before(*job.Before) {
+		return nil, validationError("after must be earlier than before")
+	}
+
+	tx := db.DB.WithContext(ctx)
+	matched := func() *gorm.DB { return purgeScope(tx.Model(&models.ChatQuery{}), job) }
+	ids := matched().Select("id")
+
+	if err := matched().Count(&job.MatchedQueries).Error; err != nil {
+		return nil, fmt.Errorf("failed to count queries: %w", err)
+	}
+	if err := tx.Model(&models.Feedback{}).Where("query_id IN (?)", ids).Count(&job.MatchedFeedback).Error; err != nil {
+		return nil, fmt.Errorf("failed to count feedback: %w", err)
+	}
+	for _, related := range []interface{}{&models.Annotation{}, &models.RetrievalFeedback{}, &models.QueryJob{}, &models.QueryAttachmentRecord{}} {
+		var count int64
+		if err := tx.Model(related).Where("query_id IN (?)", ids).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("failed to count related rows: %w", err)
+		}
+		job.MatchedRelated += count
+	}
+	if err := matched().Where("cache_key <> ''").Distinct("cache_key").Count(&job.MatchedCacheKeys).Error; err != nil {
+		return nil, fmt.Errorf("failed to count cache keys: %w", err)
+	}
+
+	if err := tx.Create(&job).Error; err != nil {
+		return nil, fmt.Errorf("failed to save purge dry run: %w", err)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"purge_job_id": job.ID,
+		"queries":      job.MatchedQueries,
+		"feedback":     job.MatchedFeedback,
+	}).Info("Purge dry run recorded")
+
+	return &job, nil
+}
+
+// startPurge confirms a dry run and starts deleting the rows its filter
+// matches. It is rejected while another purge that may touch the same rows
+// is pending or running.
+func (s *PurgeService) startPurge(ctx context.Context, id uint) (*models.PurgeJob, error) {
+	if s.lifecycle.Stopping() {
+		return nil, fmt.Errorf("%w: server is shutting down", ErrOverloaded)
+	}
+
+	var job models.PurgeJob
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", purgeLockKey).Error; err != nil {
+			return fmt.Errorf("failed to lock purge jobs: %w", err)
+		}
+
+		if err := tx.First(&job, id).Error; err != nil {
+			return notFoundError("purge job", err)
+		}
+		if job.Status != PurgeDryRun {
+			return fmt.Errorf("%w: purge job %d is %s, not a dry run", ErrConflict, id, job.Status)
+		}
+		if time.Since(job.CreatedAt) > purgeDryRunTTL {
+			return validationError("dry run %d is older than %s; run it again", id, purgeDryRunTTL)
+		}
+
+		var active []models.PurgeJob
+		if err := tx.Where("status IN ?", []string{JobPending, JobRunning}).Find(&active).Error; err != nil {
+			return fmt.Errorf("failed to get active purge jobs: %w", err)
+		}
+		for _, other := range active {
+			if purgeFiltersOverlap(job, other) {
+				return fmt.Errorf("%w: purge job %d may touch the same rows and is still %s", ErrConflict, other.ID, other.Status)
+			}
+		}
+
+		now := time.Now().UTC()
+		before := purgeAuditState(job)
+		job.Status = JobPending
+		job.StartedBy = audit.ActorFrom(ctx).UserID
+		job.StartedAt = &now
+		job.HeartbeatAt = &now
+		err := tx.Model(&job).Updates(map[string]interface{}{
+			"status":       job.Status,
+			"started_by":   job.StartedBy,
+			"started_at":   now,
+			"heartbeat_at": now,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to start purge: %w", err)
+		}
+		return audit.Record(ctx, tx, "purge.start", "purge_job", strconv.FormatUint(uint64(id), 10), before, purgeAuditState(job))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// A purge that can't start because of shutdown stays pending and
+	// resumes after the restart
+	s.launch(job.ID)
+	return &job, nil
+}
+
+// launch runs a purge in the background
+func (s *PurgeService) launch(id uint) {
+	started := s.lifecycle.Go("purge", logrus.Fields{"purge_job_id": id}, func(ctx context.Context) {
+		s.run(ctx, id)
+	})
+	if !started {
+		s.release(id)
+	}
+}
+
+// resumeAbandoned claims and resumes pending or running purges whose
+// instance stopped making progress
+func (s *PurgeService) resumeAbandoned() {
+	stale := time.Now().UTC().Add(-purgeStaleAfter)
+
+	var jobs []models.PurgeJob
+	err := db.DB.Select("id").
+		Where("status IN ?", []string{JobPending, JobRunning}).
+		Where("heartbeat_at IS NULL OR heartbeat_at < ?", stale).
+		Find(&jobs).Error
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to look for interrupted purge jobs")
+		return
+	}
+
+	for _, job := range jobs {
+		// Only one instance claims each job
+		result := db.DB.Model(&models.PurgeJob{}).
+			Where("id = ? AND (heartbeat_at IS NULL OR heartbeat_at < ?)", job.ID, stale).
+			Update("heartbeat_at", time.Now().UTC())
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+
+		logrus.WithField("purge_job_id", job.ID).Info("Resuming interrupted purge")
+		s.launch(job.ID)
+	}
+}
+
+// run deletes the job's rows batch by batch. At shutdown it stops between
+// batches and releases the job, so the next instance to start resumes it.
+func (s *PurgeService) run(ctx context.Context, id uint) {
+	defer func() {
+		if r := recover(); r != nil {
+			logrus.WithField("purge_job_id", id).Errorf("Purge panicked: %v", r)
+			s.finish(id, JobFailed, fmt.Sprintf("internal error: %v", r))
+		}
+	}()
+
+	job, err := s.GetPurgeJob(ctx, id)
+	if err != nil {
+		logrus.WithError(err).WithField("purge_job_id", id).Error("Failed to load purge job")
+		return
+	}
+	db.DB.Model(&models.PurgeJob{}).Where("id = ?", id).Update("status", JobRunning)
+
+	stop := s.lifecycle.Context().Done()
+	for {
+		select {
+		case <-stop:
+			s.release(id)
+			return
+		default:
+		}
+
+		done, err := s.purgeBatch(ctx, job)
+		if errors.Is(err, errPurgeTakenOver) {
+			logrus.WithField("purge_job_id", id).Warn("Purge taken over by another instance")
+			return
+		}
+		if err != nil {
+			logrus.WithError(err).WithField("purge_job_id", id).Error("Purge batch failed")
+			s.finish(id, JobFailed, err.Error())
+			return
+		}
+		if done {
+			s.finish(id, JobCompleted, "")
+			return
+		}
+
+		select {
+		case <-stop:
+			s.release(id)
+			return
+		case <-time.After(s.delay):
+		}
+	}
+}
+
+// purgeBatch deletes the next batch of matching queries with their feedback
+// and related rows, advancing the job in the same transaction, then evicts
+// their cached answers. It reports whether no matching queries were left.
+func (s *PurgeService) purgeBatch(ctx context.Context, job *models.PurgeJob) (bool, error) {
+	var rows []purgeRow
+	err := purgeScope(db.DB.WithContext(ctx).Model(&models.ChatQuery{}), *job).
+		Select("id", "cache_key").
+		Where("id > ?", job.LastQueryID).
+		Order("id ASC").
+		Limit(s.batchSize).
+		Scan(&rows).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to read queries: %w", err)
+	}
+	if len(rows) == 0 {
+		return true, nil
+	}
+
+	ids := make([]uint, len(rows))
+	keys := make([]string, 0, len(rows))
+	seen := make(map[string]bool)
+	for i, row := range rows {
+		ids[i] = row.ID
+		if row.CacheKey != "" && !seen[row.CacheKey] {
+			seen[row.CacheKey] = true
+			keys = append(keys, row.CacheKey)
+		}
+	}
+	lastID := ids[len(ids)-1]
+
+	var queries, feedback, related int64
+	err = db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&models.RetrievalFeedback{}, &models.Annotation{}, &models.QueryJob{}, &models.QueryAttachmentRecord{}} {
+			result := tx.Where("query_id IN ?", ids).Delete(model)
+			if result.Error != nil {
+				return fmt.Errorf("failed to delete related rows: %w", result.Error)
+			}
+			related += result.RowsAffected
+		}
+
+		result := tx.Where("query_id IN ?", ids).Delete(&models.Feedback{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete feedback: %w", result.Error)
+		}
+		feedback = result.RowsAffected
+
+		result = tx.Where("id IN ?", ids).Delete(&models.ChatQuery{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete queries: %w", result.Error)
+		}
+		queries = result.RowsAffected
+
+		// The job only advances from where this batch started, so a batch
+		// raced by an instance that resumed the job rolls back
+		result = tx.Model(&models.PurgeJob{}).
+			Where("id = ? AND last_query_id = ?", job.ID, job.LastQueryID).
+			Updates(map[string]interface{}{
+				"deleted_queries":  gorm.Expr("deleted_queries + ?", queries),
+				"deleted_feedback": gorm.Expr("deleted_feedback + ?", feedback),
+				"deleted_related":  gorm.Expr("deleted_related + ?", related),
+				"last_query_id":    lastID,
+				"heartbeat_at":     time.Now().UTC(),
+			})
+		if result.Error != nil {
+			return fmt.Errorf("failed to update purge progress: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errPurgeTakenOver
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	job.LastQueryID = lastID
+
+	// Cached answers left behind by a crash here expire with their TTL
+	if cache.Client != nil && len(keys) > 0 {
+		if err := cache.DeleteKeys(ctx, keys); err != nil {
+			logrus.WithError(err).WithField("purge_job_id", job.ID).Warn("Failed to evict purged answers from cache")
+		} else {
+			db.DB.Model(&models.PurgeJob{}).Where("id = ?", job.ID).
+				UpdateColumn("evicted_cache_keys", gorm.Expr("evicted_cache_keys + ?", len(keys)))
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"purge_job_id": job.ID,
+		"last_id":      lastID,
+		"queries":      queries,
+		"feedback":     feedback,
+	}).Info("Purged chat history batch")
+
+	return len(rows) < s.batchSize, nil
+}
+
+// release hands a purge interrupted by shutdown to the next instance to
+// start, instead of waiting for its heartbeat to go stale
+func (s *PurgeService) release(id uint) {
+	logrus.WithField("purge_job_id", id).Warn("Purge interrupted by shutdown, will resume on restart")
+	if err := db.DB.Model(&models.PurgeJob{}).Where("id = ?", id).Update("heartbeat_at", nil).Error; err != nil {
+		logrus.WithError(err).WithField("purge_job_id", id).Error("Failed to release purge job")
+	}
+}
+
+// finish records the final state of a purge job with an audit entry of its
+// filter and counts, attributed to whoever started it
+func (s *PurgeService) finish(id uint, status, errMessage string) {
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var job models.PurgeJob
+		if err := tx.First(&job, id).Error; err != nil {
+			return err
+		}
+
+		before := purgeAuditState(job)
+		now := time.Now().UTC()
+		job.Status = status
+		job.Error = errMessage
+		job.CompletedAt = &now
+		err := tx.Model(&job).Updates(map[string]interface{}{
+			"status":       status,
+			"error":        errMessage,
+			"completed_at": now,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		ctx := audit.WithActor(context.Background(), audit.Actor{UserID: job.StartedBy})
+		return audit.Record(ctx, tx, "purge."+status, "purge_job", strconv.FormatUint(uint64(id), 10), before, purgeAuditState(job))
+	})
+	if err != nil {
+		logrus.WithError(err).WithField("purge_job_id", id).Error("Failed to update purge job")
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"purge_job_id": id,
+		"status":       status,
+	}).Info("Purge finished")
+}
+
+// purgeScope restricts a chat query lookup to the job's filter
+func purgeScope(query *gorm.DB, job models.PurgeJob) *gorm.DB {
+	if len(job.SessionIDs) > 0 {
+		query = query.Where("session_id IN ?", []string(job.SessionIDs))
+	}
+	if job.UserID != "" {
+		query = query.Where("user_id = ?", job.UserID)
+	}
+	if job.After != nil {
+		query = query.Where("created_at >= ?", *job.After)
+	}
+	if job.Before != nil {
+		query = query.Where("created_at < ?", *job.Before)
+	}
+	return query
+}
+
+// purgeFiltersOverlap reports whether two purge filters may match the same
+// queries. Only filters that provably can't, by user, session or time range,
+// are disjoint.
+func purgeFiltersOverlap(a, b models.PurgeJob) bool {
+	if a.UserID != "" && b.UserID != "" && a.UserID != b.UserID {
+		return false
+	}
+	if len(a.SessionIDs) > 0 && len(b.SessionIDs) > 0 {
+		shared := false
+		sessions := make(map[string]bool, len(a.SessionIDs))
+		for _, sessionID := range a.SessionIDs {
+			sessions[sessionID] = true
+		}
+		for _, sessionID := range b.SessionIDs {
+			if sessions[sessionID] {
+				shared = true
+				break
+			}
+		}
+		if !shared {
+			return false
+		}
+	}
+	// Ranges are [after, before)
+	if a.Before != nil && b.After != nil && !a.Before.After(*b.After) {
+		return false
+	}
+	if b.Before != nil && a.After != nil && !b.Before.After(*a.After) {
+		return false
+	}
+	return true
+}
+
+// purgeAuditState is the part of a purge job recorded in audit entries: its
+// filter, status and counts
+func purgeAuditState(job models.PurgeJob) map[string]interface{} {
+	return map[string]interface{}{
+		"session_ids":        job.SessionIDs,
+		"user_id":            job.UserID,
+		"after":              job.After,
+		"before":             job.Before,
+		"status":             job.Status,
+		"matched_queries":    job.MatchedQueries,
+		"matched_feedback":   job.MatchedFeedback,
+		"matched_related":    job.MatchedRelated,
+		"matched_cache_keys": job.MatchedCacheKeys,
+		"deleted_queries":    job.DeletedQueries,
+		"deleted_feedback":   job.DeletedFeedback,
+		"deleted_related":    job.DeletedRelated,
+		"evicted_cache_keys": job.EvictedCacheKeys,
+	}
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/activity"
+	"github.com/ai-support-assistant/backend/internal/cache"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/middleware"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// cachedQuery is a cached query response with the time it stops being fresh.
+// Entries written before stale-while-revalidate decode with a zero FreshUntil
+// and are treated as stale.
+type cachedQuery struct {
+	models.QueryResponse
+	FreshUntil time.Time `json:"fresh_until"`
+}
+
+// fresh returns true if the entry is still within its fresh TTL
+func (c *cachedQuery) fresh() bool {
+	return time.Now().Before(c.FreshUntil)
+}
+
+// cacheResponse stores a response fresh for its TTL and kept around for the
+// longer stale lifetime, unless its query is too rarely asked to be cached
+func (s *QueryService) cacheResponse(ctx context.Context, key string, response *models.QueryResponse, ttl cacheTTL) error {
+	entry := cachedQuery{
+		QueryResponse: *response,
+		FreshUntil:    time.Now().Add(ttl.Fresh),
+	}
+	if ttl.Skip {
+		size := 0
+		if data, err := json.Marshal(entry); err == nil {
+			size = len(data)
+		}
+		middleware.RecordCacheWriteSkipped("query", size)
+		return nil
+	}
+
+	if err := cache.Set(ctx, key, entry, ttl.Lifetime); err != nil {
+		return err
+	}
+	middleware.RecordCacheWrite("query", ttl.Fresh)
+	return nil
+}
+
+// refreshInBackground refreshes a stale entry against the RAG service. Only one
+// refresh per key runs per refresh window, across instances, and concurrent
+// refreshes are bounded; hits beyond that keep being served stale.
+func (s *QueryService) refreshInBackground(ctx context.Context, key string, req RAGQueryRequest, stale models.QueryResponse, ttl cacheTTL) {
+	// Refreshing can't succeed while degraded; keep serving the stale entry.
+	// Direct answers don't need the RAG service.
+	if stale.Pipeline != models.PipelineDirect && !s.health.RAGAvailable() {
+		return
+	}
+
+	lockKey := key + ":refresh"
+	window := time.Duration(s.cfg.CacheRefreshWindowS) * time.Second
+
+	acquired, err := cache.SetNX(ctx, lockKey, true, window)
+	if err != nil || !acquired {
+		return
+	}
+
+	select {
+	case s.refreshSlots <- struct{}{}:
+	default:
+		// Let a later hit try again rather than waiting out the window
+		middleware.RecordCacheRefresh("query", "skipped")
+		cache.Delete(ctx, lockKey)
+		return
+	}
+
+	started := s.lifecycle.Go("cache_refresh", logrus.Fields{"cache_key": key}, func(ctx context.Context) {
+		defer func() { <-s.refreshSlots }()
+		s.refreshCachedResponse(ctx, key, req, stale, ttl)
+	})
+	if !started {
+		<-s.refreshSlots
+	}
+}
+
+// refreshCachedResponse regenerates a cached answer and replaces the stale
+// entry, for the TTL chosen on the hit that triggered the refresh
+func (s *QueryService) refreshCachedResponse(ctx context.Context, key string, req RAGQueryRequest, stale models.QueryResponse, ttl cacheTTL) {
+	startTime := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(s.cfg.QueryCoalesceTimeoutS)*time.Second)
+	defer cancel()
+
+	logger := logrus.WithField("cache_key", key)
+
+	// A canned answer added since the entry was cached takes over on the next request
+	if canned := s.cannedAnswers.Match(ctx, req.Query); canned != nil {
+		cache.Delete(ctx, key)
+		middleware.RecordCacheRefresh("query", "evicted")
+		return
+	}
+
+	ragResp, err := s.refreshCall(ctx, req, stale.Pipeline)
+	if err != nil {
+		middleware.RecordCacheRefresh("query", "failed")
+		logger.WithError(err).Warn("Failed to refresh stale cache entry")
+		return
+	}
+
+	// Flagged answers are never cached; keep serving the stale one until it expires
+	if flagged, _ := s.moderate(ctx, "response", ragResp.Response); flagged {
+		middleware.RecordCacheRefresh("query", "flagged")
+		return
+	}
+
+	// Nor are answers that fail verification; direct answers have no
+	// context to verify against
+	var verdict grounding
+	if stale.Pipeline != models.PipelineDirect {
+		verdict = s.verifyGrounding(ctx, req.Query, ragResp)
+	}
+	if verdict.lowConfidence {
+		middleware.RecordCacheRefresh("query", "low_confidence")
+		return
+	}
+	translated := s.enforceResponseLanguage(ctx, req.ResponseLanguage, ragResp)
+
+	refreshed := stale
+	refreshed.GroundingScore = verdict.score
+	refreshed.Response = ragResp.Response
+	refreshed.Translated = translated
+	refreshed.Context = ragResp.Context
+	refreshed.Model = ragResp.Model
+	refreshed.PhaseTimings = ragResp.PhaseTimings
+	if req.IncludeSuggestions {
+		refreshed.Suggestions = ragResp.Suggestions
+	}
+	refreshed.Latency = int(time.Since(startTime).Milliseconds())
+	refreshed.CacheHit = false
+	refreshed.Stale = false
+	refreshed.GeneratedAt = nil
+	refreshed.Timestamp = time.Now().UTC()
+
+	if err := s.cacheResponse(ctx, key, &refreshed, ttl); err != nil {
+		middleware.RecordCacheRefresh("query", "failed")
+		logger.WithError(err).Warn("Failed to store refreshed cache entry")
+		return
+	}
+
+	middleware.RecordCacheRefresh("query", "refreshed")
+	logger.Debug("Refreshed stale cache entry")
+}
+
+// refreshCall regenerates a cached answer the way it was first generated:
+// by the RAG service, or directly by the LLM for a general question
+func (s *QueryService) refreshCall(ctx context.Context, req RAGQueryRequest, pipeline string) (*RAGQueryResponse, error) {
+	if pipeline != models.PipelineDirect {
+		return s.coalescedRAGCall(ctx, req)
+	}
+
+	resp, err := s.direct.Answer(ctx, directRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	s.postProcess(ctx, resp)
+	return resp, nil
+}
+
+// recordCacheHit saves a query answered from the cache as the session's
+// own, so the response's query ID, feedback and history belong to it rather
+// than to the query that first generated the answer, possibly in another
+// session
+func (s *QueryService) recordCacheHit(ctx context.Context, qc *QueryContext, response *models.QueryResponse) {
+	req := qc.Request
+	chatQuery := models.ChatQuery{
+		SessionID:  req.SessionID,
+		UserID:     req.UserID,
+		VisitorID:  req.VisitorID,
+		Plan:       s.quotas.Plan(req.TokenPlan).Name,
+		Query:      req.Query,
+		QueryHash:  queryHash(req.Query),
+		Response:   response.Response,
+		Context:    formatContext(response.Context),
+		Model:      response.Model,
+		Language:   qc.Language,
+		TokensUsed: 0,
+		LatencyMs:  response.Latency,
+		CacheHit:   true,
+
+		Pipeline:       response.Pipeline,
+		GroundingScore: response.GroundingScore,
+		LowConfidence:  response.LowConfidence,
+		Translated:     response.Translated,
+		CacheKey:       qc.CacheKey,
+		RequestID:      middleware.RequestIDFrom(ctx),
+		Metadata:       req.Metadata,
+		Segment:        req.Segment,
+		Synthetic:      req.Synthetic,
+	}
+
+	if err := db.DB.Create(&chatQuery).Error; err != nil {
+		logrus.WithError(err).Error("Failed to save query to database")
+	} else if !req.Synthetic {
+		s.feed.Publish(activity.QueryEvent(chatQuery))
+	}
+	response.QueryID = chatQuery.ID
+	response.FeedbackRequested = s.requestFeedback(&chatQuery, false)
+	if !req.Synthetic {
+		recordSessionActivity(req.SessionID, req.Segment)
+	}
+}
+
+// RefreshSlots returns the number of background refreshes running and the limit
+func (s *QueryService) RefreshSlots() (int, int) {
+	return len(s.refreshSlots), cap(s.refreshSlots)
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/activity"
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/lifecycle"
+	"github.com/ai-support-assistant/backend/internal/models"
+)
+
+// pipelineDB answers the statements ProcessQuery runs: inserts get IDs,
+// canned answers come from cannedAnswers and other reads are empty
+type pipelineDB struct {
+	mu            sync.Mutex
+	nextID        int64
+	cannedAnswers [][]driver.Value
+	inserts       []string
+}
+
+func (p *pipelineDB) answer(query string, args []driver.NamedValue) (*fakeRows, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch {
+	case strings.HasPrefix(query, "INSERT INTO "):
+		p.inserts = append(p.inserts, strings.Fields(query)[2])
+		p.nextID++
+		return &fakeRows{columns: []string{"id"}, values: [][]driver.Value{{p.nextID}}}, nil
+	case strings.Contains(query, `FROM "canned_answers"`):
+		return &fakeRows{columns: []string{"id", "pattern", "match_type", "answer", "enabled", "priority"}, values: p.cannedAnswers}, nil
+	}
+	return nil, nil
+}
+
+// fakeRAGService answers /rag/query with a fixed answer, counting calls;
+// its health check fails while it is down
+type fakeRAGService struct {
+	answer string
+	calls  int32
+	down   atomic.Bool
+}
+
+func (f *fakeRAGService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/health" {
+		if f.down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	atomic.AddInt32(&f.calls, 1)
+	json.NewEncoder(w).Encode(RAGQueryResponse{
+		Response: f.answer,
+		Context:  []string{"Passwords are reset from the sign-in page."},
+		Sources:  []RAGSource{{Source: "account.md", DocID: "d1"}},
+		Model:    "m1",
+	})
+}
+
+// newPipelineTestService builds a query service as the orchestrator does,
+// calling rag for answers, with the database and Redis faked
+func newPipelineTestService(t *testing.T, rag http.Handler, configure func(cfg *config.Config)) (*QueryService, *pipelineDB) {
+	t.Helper()
+	server := httptest.NewServer(rag)
+	t.Cleanup(server.Close)
+
+	cfg, _ := config.Check()
+	cfg.RAGServiceURL = server.URL
+	if configure != nil {
+		configure(cfg)
+	}
+
+	database := &pipelineDB{}
+	useFakeDB(t, database.answer)
+	useFakeRedis(t)
+
+	transport := newHTTPRAGTransport(cfg.RAGServiceURL)
+	health := NewHealthService(cfg, transport, nil, nil, nil)
+	settings := NewSettingsService(cfg)
+	featureFlags := NewFeatureFlagService(cfg)
+	canned := NewCannedAnswerService()
+	prompts := NewPromptService()
+	lc := lifecycle.NewManager()
+	t.Cleanup(func() { lc.Shutdown(time.Second) })
+	s := NewQueryService(cfg, settings, nil, activity.NewBus(), canned, prompts, NewExperimentService(cfg, prompts), NewModelRoutingService(cfg),
+		health, transport, nil, NewRAGLimiter(cfg), NewQuotaService(cfg), NewDidYouMeanService(cfg, featureFlags),
+		NewPersonaService(NewWidgetService()), NewDirectAnswerService(cfg, featureFlags), lc)
+	return s, database
+}
+
+// ask sends query in a new session, failing the test on an error
+func ask(t *testing.T, s *QueryService, sessionID, query string) *models.QueryResponse {
+	t.Helper()
+	resp, err := s.ProcessQuery(context.Background(), models.QueryRequest{Query: query, SessionID: sessionID})
+	if err != nil {
+		t.Fatalf("ProcessQuery(%q): %v", query, err)
+	}
+	return resp
+}
+
+func TestProcessQueryAnswersFromRAG(t *testing.T) {
+	rag := &fakeRAGService{answer: "Use the reset link on the sign-in page."}
+	s, database := newPipelineTestService(t, rag, nil)
+
+	resp := ask(t, s, "s1", "How do I reset my password?")
+	if resp.Response != rag.answer || resp.Model != "m1" || resp.Pipeline != models.PipelineRAG {
+		t.Errorf("response = %q from %s via %s, want the RAG answer", resp.Response, resp.Model, resp.Pipeline)
+	}
+	if resp.CacheHit || resp.Moderated || resp.QueryID == 0 {
+		t.Errorf("response = %+v, want a saved, unmoderated miss", resp)
+	}
+	if calls := atomic.LoadInt32(&rag.calls); calls != 1 {
+		t.Errorf("RAG service called %d times, want 1", calls)
+	}
+	if len(database.inserts) == 0 || database.inserts[0] != `"chat_queries"` {
+		t.Errorf("inserts = %v, want the query saved first", database.inserts)
+	}
+}
+
+func TestProcessQueryCacheHit(t *testing.T) {
+	rag := &fakeRAGService{answer: "Use the reset link on the sign-in page."}
+	s, _ := newPipelineTestService(t, rag, nil)
+
+	first := ask(t, s, "s1", "How do I reset my password?")
+	second := ask(t, s, "s2", "How do I reset my password?")
+	if !second.CacheHit || second.Response != first.Response {
+		t.Errorf("second ask = %q, cache hit %t; want the first answer from the cache", second.Response, second.CacheHit)
+	}
+	if second.SessionID != "s2" {
+		t.Errorf("cached answer in session %q, want the asker's", second.SessionID)
+	}
+	if calls := atomic.LoadInt32(&rag.calls); calls != 1 {
+		t.Errorf("RAG service called %d times, want only for the first ask", calls)
+	}
+}
+
+func TestProcessQueryCannedAnswer(t *testing.T) {
+	rag := &fakeRAGService{answer: "Use the reset link on the sign-in page."}
+	s, database := newPipelineTestService(t, rag, nil)
+	database.cannedAnswers = [][]driver.Value{{int64(1), "how do i reset my password", "exact", "Reset it from Settings > Security.", true, int64(0)}}
+
+	resp := ask(t, s, "s1", "How do I reset my password?")
+	if resp.Response != "Reset it from Settings > Security." || resp.Model != CannedModel {
+		t.Errorf("response = %q from %s, want the canned answer", resp.Response, resp.Model)
+	}
+	if calls := atomic.LoadInt32(&rag.calls); calls != 0 {
+		t.Errorf("RAG service called %d times for a canned answer", calls)
+	}
+}
+
+func TestProcessQueryModerationBlock(t *testing.T) {
+	moderationServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"results":[{"flagged":true,"categories":{"harassment":true,"violence":false}}]}`))
+	}))
+	t.Cleanup(moderationServer.Close)
+
+	rag := &fakeRAGService{answer: "Use the reset link on the sign-in page."}
+	s, _ := newPipelineTestService(t, rag, func(cfg *config.Config) {
+		cfg.ModerationMode = "enforce"
+		cfg.ModerationURL = moderationServer.URL
+	})
+
+	resp := ask(t, s, "s1", "You are useless")
+	if !resp.Moderated || resp.Response != s.settings.ModerationRefusalMessage() || resp.Model != ModerationModel {
+		t.Errorf("response = %q from %s, moderated %t; want the refusal", resp.Response, resp.Model, resp.Moderated)
+	}
+	if calls := atomic.LoadInt32(&rag.calls); calls != 0 {
+		t.Errorf("RAG service called %d times for a blocked query", calls)
+	}
+
+	// Blocked queries aren't cached, so asking again is moderated again
+	if again := ask(t, s, "s2", "You are useless"); again.CacheHit || !again.Moderated {
+		t.Errorf("second ask = %+v, want it moderated afresh", again)
+	}
+}
+
+func TestProcessQueryDegraded(t *testing.T) {
+	rag := &fakeRAGService{answer: "Use the reset link on the sign-in page."}
+	s, _ := newPipelineTestService(t, rag, nil)
+
+	rag.down.Store(true)
+	for i := 0; i < s.cfg.RAGProbeFailureThreshold; i++ {
+		s.health.probe(context.Background())
+	}
+
+	_, err := s.ProcessQuery(context.Background(), models.QueryRequest{Query: "How do I reset my password?", SessionID: "s1"})
+	var degraded *DegradedError
+	if !errors.As(err, &degraded) || degraded.RetryAfter != s.health.ProbeInterval() {
+		t.Fatalf("error = %v, want a DegradedError retried after the probe interval", err)
+	}
+	if calls := atomic.LoadInt32(&rag.calls); calls != 0 {
+		t.Errorf("RAG service called %d times while degraded", calls)
+	}
+
+	// Once the service recovers, queries reach it again
+	rag.down.Store(false)
+	s.health.probe(context.Background())
+	if resp := ask(t, s, "s1", "How do I reset my password?"); resp.Response != rag.answer {
+		t.Errorf("response after recovery = %q, want the RAG answer", resp.Response)
+	}
+}
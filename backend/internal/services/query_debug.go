@@ -0,0 +1,371 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/audit"
+	"github.com/ai-support-assistant/backend/internal/cache"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/logging"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/ragclient"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Query debug bundle formats
+const (
+	DebugFormatJSON     = "json"
+	DebugFormatMarkdown = "markdown"
+)
+
+// IsValidDebugFormat returns true if the debug bundle format is supported
+func IsValidDebugFormat(format string) bool {
+	return format == DebugFormatJSON || format == DebugFormatMarkdown
+}
+
+// retrievalDebugTimeout bounds the call asking the RAG service about a
+// query's retrieval
+const retrievalDebugTimeout = 5 * time.Second
+
+// QueryDebugService assembles everything known about one query into a
+// bundle engineers can debug a reported bad answer from offline
+type QueryDebugService struct {
+	ragClient *ragclient.Client
+	scrubber  *logging.ScrubHook
+}
+
+func NewQueryDebugService(ragClient *ragclient.Client, scrubber *logging.ScrubHook) *QueryDebugService {
+	return &QueryDebugService{ragClient: ragClient, scrubber: scrubber}
+}
+
+// GetBundle assembles a query's debug bundle from the database, the response
+// cache and the RAG service, which is asked for what it knows about the
+// retrieval; if it can't answer, the bundle says why instead. Text is
+// redacted per the log scrubbing config. Each access is audited, since the
+// bundle exposes conversation content.
+func (s *QueryDebugService) GetBundle(ctx context.Context, id uint, format string) (*models.QueryDebugBundle, error) {
+	var query models.ChatQuery
+	if err := db.DB.WithContext(ctx).First(&query, id).Error; err != nil {
+		return nil, notFoundError("query", err)
+	}
+
+	bundle := &models.QueryDebugBundle{
+		Query:       query,
+		Chunks:      debugChunks(query.Context),
+		Cache:       debugCache(ctx, query),
+		Feedback:    []models.QueryDebugFeedback{},
+		GeneratedAt: time.Now().UTC(),
+	}
+	s.addRetrievalDebug(ctx, bundle)
+
+	if query.PromptTemplate != "" {
+		var prompt models.PromptTemplate
+		err := db.DB.WithContext(ctx).
+			Where("name = ? AND version = ?", query.PromptTemplate, query.PromptVersion).
+			First(&prompt).Error
+		// A template deleted since the query was answered is left out
+		if err == nil {
+			bundle.Prompt = &prompt
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to get prompt template: %w", err)
+		}
+	}
+
+	var feedback []models.Feedback
+	if err := db.DB.WithContext(ctx).Where("query_id = ?", id).Order("created_at ASC").Find(&feedback).Error; err != nil {
+		return nil, fmt.Errorf("failed to get feedback: %w", err)
+	}
+	for _, f := range feedback {
+		bundle.Feedback = append(bundle.Feedback, models.QueryDebugFeedback{
+			ID:        f.ID,
+			Score:     f.Score,
+			Comment:   f.Comment,
+			Tags:      f.Tags,
+			Theme:     f.Theme,
+			Source:    f.Source,
+			CreatedAt: f.CreatedAt,
+		})
+	}
+
+	s.redact(bundle)
+
+	idStr := strconv.FormatUint(uint64(id), 10)
+	if err := audit.Record(ctx, db.DB.WithContext(ctx), "query.debug_export", "chat_query", idStr, nil, map[string]string{"format": format}); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// debugChunks parses the stored context into chunks. Context stored before
+// it was a JSON array is kept as a single chunk.
+func debugChunks(context string) []models.QueryDebugChunk {
+	var texts []string
+	if err := json.Unmarshal([]byte(context), &texts); err != nil && context != "" {
+		texts = []string{context}
+	}
+
+	chunks := make([]models.QueryDebugChunk, len(texts))
+	for i, text := range texts {
+		chunks[i] = models.QueryDebugChunk{ID: ragclient.ChunkID(text), Text: text}
+	}
+	return chunks
+}
+
+// debugCache reports whether the query's answer is still cached
+func debugCache(ctx context.Context, query models.ChatQuery) models.QueryDebugCache {
+	status := models.QueryDebugCache{
+		Key:      query.CacheKey,
+		Hit:      query.CacheHit,
+		Bypassed: query.CacheBypassed,
+	}
+	if query.CacheKey == "" {
+		return status
+	}
+
+	ttl, err := cache.TTL(ctx, query.CacheKey)
+	if err != nil {
+		logrus.WithError(err).Debug("Failed to check cache entry for debug bundle")
+		status.Unavailable = true
+		return status
+	}
+	// Redis reports a negative TTL for missing keys
+	if ttl > 0 {
+		status.Cached = true
+		status.TTLSeconds = int(ttl.Seconds())
+	}
+	return status
+}
+
+// addRetrievalDebug asks the RAG service about the chunks the answer was
+// generated from, adding their scores and source documents to the bundle
+func (s *QueryDebugService) addRetrievalDebug(ctx context.Context, bundle *models.QueryDebugBundle) {
+	if len(bundle.Chunks) == 0 {
+		return
+	}
+
+	chunks := make([]ragclient.Chunk, len(bundle.Chunks))
+	for i, chunk := range bundle.Chunks {
+		chunks[i] = ragclient.Chunk{ID: chunk.ID, Text: chunk.Text}
+	}
+
+	debugCtx, cancel := context.WithTimeout(ctx, retrievalDebugTimeout)
+	defer cancel()
+	debug, err := s.ragClient.DebugRetrieval(debugCtx, ragclient.RetrievalDebugRequest{
+		Query:  bundle.Query.Query,
+		Chunks: chunks,
+	})
+	if err != nil {
+		logrus.WithError(err).WithField("query_id", bundle.Query.ID).Warn("Failed to get retrieval debug info")
+		bundle.RetrievalError = err.Error()
+		return
+	}
+	bundle.Retrieval = debug.Details
+
+	byID := make(map[string]ragclient.ChunkDebug, len(debug.Chunks))
+	var docIDs []string
+	for _, chunk := range debug.Chunks {
+		byID[chunk.ID] = chunk
+		if chunk.DocID != "" {
+			docIDs = append(docIDs, chunk.DocID)
+		}
+	}
+
+	documents := make(map[string]*models.QueryDebugDocument)
+	if len(docIDs) > 0 {
+		var found []models.Document
+		if err := db.DB.WithContext(ctx).Where("vector_store_id IN ?", docIDs).Find(&found).Error; err != nil {
+			logrus.WithError(err).Warn("Failed to get source documents for debug bundle")
+		}
+		for _, doc := range found {
+			documents[doc.VectorStoreID] = &models.QueryDebugDocument{
+				ID:            doc.ID,
+				FileName:      doc.FileName,
+				FileType:      doc.FileType,
+				VectorStoreID: doc.VectorStoreID,
+				Version:       doc.Version,
+				Status:        doc.Status,
+				Visibility:    doc.Visibility,
+				CollectionID:  doc.CollectionID,
+				SourceURL:     doc.SourceURL,
+				CreatedAt:     doc.CreatedAt,
+			}
+		}
+	}
+
+	for i := range bundle.Chunks {
+		chunk := &bundle.Chunks[i]
+		info, ok := byID[chunk.ID]
+		if !ok {
+			continue
+		}
+		chunk.Score = info.Score
+		chunk.Source = info.Source
+		chunk.Metadata = info.Metadata
+		chunk.Document = documents[info.DocID]
+	}
+}
+
+// redact scrubs credentials and personal data from the bundle's
+// conversation text
+func (s *QueryDebugService) redact(bundle *models.QueryDebugBundle) {
+	query := &bundle.Query
+	query.Query = s.scrubber.Scrub(query.Query)
+	query.Response = s.scrubber.Scrub(query.Response)
+	query.Context = s.scrubber.Scrub(query.Context)
+	if query.Metadata != nil {
+		metadata := *query.Metadata
+		metadata.PageURL = s.scrubber.Scrub(metadata.PageURL)
+		metadata.Referrer = s.scrubber.Scrub(metadata.Referrer)
+		query.Metadata = &metadata
+	}
+	for i := range bundle.Chunks {
+		bundle.Chunks[i].Text = s.scrubber.Scrub(bundle.Chunks[i].Text)
+	}
+	for i := range bundle.Feedback {
+		bundle.Feedback[i].Comment = s.scrubber.Scrub(bundle.Feedback[i].Comment)
+	}
+}
+
+// WriteDebugBundle renders a debug bundle as readable markdown
+func WriteDebugBundle(w io.Writer, bundle *models.QueryDebugBundle) error {
+	query := bundle.Query
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Query %d\n\n", query.ID)
+	fmt.Fprintf(&b, "- Asked: %s\n", query.CreatedAt.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "- Session: %s (segment %d)\n", query.SessionID, query.Segment)
+	if query.UserID != "" {
+		fmt.Fprintf(&b, "- User: %s\n", query.UserID)
+	}
+	if query.RequestID != "" {
+		fmt.Fprintf(&b, "- Request ID: `%s`\n", query.RequestID)
+	}
+	fmt.Fprintf(&b, "- Bundle generated: %s\n\n", bundle.GeneratedAt.Format(time.RFC3339))
+
+	fmt.Fprintf(&b, "## Question\n\n%s\n\n## Answer\n\n%s\n\n", query.Query, query.Response)
+
+	b.WriteString("## Generation\n\n")
+	fmt.Fprintf(&b, "- Pipeline: %s\n", query.Pipeline)
+	fmt.Fprintf(&b, "- Model: %s\n", query.Model)
+	if query.RAGEndpoint != "" {
+		fmt.Fprintf(&b, "- RAG endpoint: %s\n", query.RAGEndpoint)
+	}
+	if query.RoutingRuleID != nil {
+		fmt.Fprintf(&b, "- Routing rule: %d\n", *query.RoutingRuleID)
+	}
+	if query.ExperimentID != nil {
+		fmt.Fprintf(&b, "- Experiment: %d, variant %s\n", *query.ExperimentID, query.ExperimentVariant)
+	}
+	if query.PersonaID != nil {
+		fmt.Fprintf(&b, "- Persona: %d, version %d\n", *query.PersonaID, query.PersonaVersion)
+	}
+	if query.Language != "" {
+		fmt.Fprintf(&b, "- Language: %s\n", query.Language)
+	}
+	if query.ResponseLanguage != "" {
+		fmt.Fprintf(&b, "- Response language: %s (translated: %t)\n", query.ResponseLanguage, query.Translated)
+	}
+	fmt.Fprintf(&b, "- Tokens: %d estimated, %d in, %d out, %d total\n", query.EstimatedTokens, query.TokensIn, query.TokensOut, query.TokensUsed)
+	fmt.Fprintf(&b, "- Partial: %t\n\n", query.Partial)
+
+	b.WriteString("## Timings\n\n")
+	fmt.Fprintf(&b, "- Latency: %d ms\n", query.LatencyMs)
+	writeTiming(&b, "Retrieval", query.RetrievalMs)
+	writeTiming(&b, "Generation", query.GenerationMs)
+	writeTiming(&b, "Time to first token", query.TimeToFirstTokenMs)
+	b.WriteString("\n")
+
+	b.WriteString("## Prompt\n\n")
+	if bundle.Prompt != nil {
+		fmt.Fprintf(&b, "Template %s, version %d:\n\n```\n%s\n```\n\n", bundle.Prompt.Name, bundle.Prompt.Version, bundle.Prompt.Body)
+	} else if query.PromptTemplate != "" {
+		fmt.Fprintf(&b, "Template %s, version %d (no longer stored)\n\n", query.PromptTemplate, query.PromptVersion)
+	} else {
+		b.WriteString("No template recorded; the RAG service's default prompt was used.\n\n")
+	}
+
+	b.WriteString("## Cache\n\n")
+	fmt.Fprintf(&b, "- Hit: %t\n- Bypassed: %t\n", bundle.Cache.Hit, bundle.Cache.Bypassed)
+	switch {
+	case bundle.Cache.Key == "":
+		b.WriteString("- Not cached\n\n")
+	case bundle.Cache.Unavailable:
+		fmt.Fprintf(&b, "- Key: `%s` (the cache couldn't be checked)\n\n", bundle.Cache.Key)
+	case bundle.Cache.Cached:
+		fmt.Fprintf(&b, "- Key: `%s`, cached for another %d s\n\n", bundle.Cache.Key, bundle.Cache.TTLSeconds)
+	default:
+		fmt.Fprintf(&b, "- Key: `%s`, expired or evicted\n\n", bundle.Cache.Key)
+	}
+
+	b.WriteString("## Moderation and verification\n\n")
+	fmt.Fprintf(&b, "- Flagged: %t", query.ModerationFlag)
+	if query.ModerationCategories != "" {
+		fmt.Fprintf(&b, " (%s)", query.ModerationCategories)
+	}
+	b.WriteString("\n")
+	if query.GroundingScore != nil {
+		fmt.Fprintf(&b, "- Grounding score: %.2f, %d unsupported sentences\n", *query.GroundingScore, query.UnsupportedCount)
+	} else {
+		b.WriteString("- Grounding: not verified\n")
+	}
+	fmt.Fprintf(&b, "- Low confidence: %t\n- No context: %t\n\n", query.LowConfidence, query.NoContext)
+
+	fmt.Fprintf(&b, "## Context (%d chunks)\n\n", len(bundle.Chunks))
+	if bundle.RetrievalError != "" {
+		fmt.Fprintf(&b, "_The RAG service couldn't provide retrieval details: %s_\n\n", bundle.RetrievalError)
+	}
+	for i, chunk := range bundle.Chunks {
+		fmt.Fprintf(&b, "### %d. `%s`", i+1, chunk.ID)
+		if chunk.Score != nil {
+			fmt.Fprintf(&b, " (score %.3f)", *chunk.Score)
+		}
+		b.WriteString("\n\n")
+		if doc := chunk.Document; doc != nil {
+			fmt.Fprintf(&b, "Document %d: %s, version %d, %s, %s\n\n", doc.ID, doc.FileName, doc.Version, doc.Visibility, doc.Status)
+		} else if chunk.Source != "" {
+			fmt.Fprintf(&b, "Source: %s\n\n", chunk.Source)
+		}
+		fmt.Fprintf(&b, "> %s\n\n", strings.ReplaceAll(chunk.Text, "\n", "\n> "))
+	}
+	if len(bundle.Retrieval) > 0 {
+		details, err := json.MarshalIndent(bundle.Retrieval, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to render retrieval details: %w", err)
+		}
+		fmt.Fprintf(&b, "Retrieval details:\n\n```json\n%s\n```\n\n", details)
+	}
+
+	fmt.Fprintf(&b, "## Feedback (%d)\n\n", len(bundle.Feedback))
+	for _, f := range bundle.Feedback {
+		rating := "👍"
+		if f.Score < 0 {
+			rating = "👎"
+		}
+		fmt.Fprintf(&b, "- %s %s (%s)", f.CreatedAt.UTC().Format(time.RFC3339), rating, f.Source)
+		if f.Theme != "" {
+			fmt.Fprintf(&b, " [%s]", f.Theme)
+		}
+		if f.Comment != "" {
+			fmt.Fprintf(&b, ": %s", f.Comment)
+		}
+		b.WriteString("\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeTiming writes a phase timing, if it was reported
+func writeTiming(b *strings.Builder, label string, ms *int) {
+	if ms != nil {
+		fmt.Fprintf(b, "- %s: %d ms\n", label, *ms)
+	}
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/lifecycle"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// Query job statuses
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
+// queryJob is a queued async query
+type queryJob struct {
+	id  string
+	req models.QueryRequest
+}
+
+// QueryJobService processes queries in a bounded background worker pool so
+// long RAG calls aren't cut off by the server write timeout
+type QueryJobService struct {
+	queryService *QueryService
+	queue        chan queryJob
+	workers      int
+	timeout      time.Duration
+	ttl          time.Duration
+	lifecycle    *lifecycle.Manager
+}
+
+func NewQueryJobService(cfg *config.Config, queryService *QueryService) *QueryJobService {
+	workers := cfg.QueryJobWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	return &QueryJobService{
+		queryService: queryService,
+		queue:        make(chan queryJob, cfg.QueryJobQueueSize),
+		workers:      workers,
+		timeout:      time.Duration(cfg.QueryJobTimeoutS) * time.Second,
+		ttl:          time.Duration(cfg.QueryJobTTLS) * time.Second,
+	}
+}
+
+// Start launches the workers and the garbage collector. At shutdown, workers
+// finish the job they are running and jobs still queued are marked failed.
+func (s *QueryJobService) Start(lc *lifecycle.Manager) {
+	s.lifecycle = lc
+	for i := 0; i < s.workers; i++ {
+		lc.Go("query_job_worker", logrus.Fields{"worker": i}, s.worker)
+	}
+
+	if s.ttl > 0 {
+		go s.collectGarbage(lc.Context())
+	}
+
+	logrus.WithField("workers", s.workers).Info("Query job workers started")
+}
+
+// QueueDepth returns the number of queued jobs and the queue capacity
+func (s *QueryJobService) QueueDepth() (int, int) {
+	return len(s.queue), cap(s.queue)
+}
+
+// Submit records a pending job and queues it for processing
+func (s *QueryJobService) Submit(ctx context.Context, req models.QueryRequest) (*models.QueryJob, error) {
+	if s.lifecycle.Stopping() {
+		return nil, fmt.Errorf("%w: server is shutting down", ErrOverloaded)
+	}
+
+	if err := s.queryService.ValidateRequest(ctx, &req); err != nil {
+		return nil, err
+	}
+
+	// Refuse up front rather than fail the job once it runs
+	if err := s.queryService.quotas.Check(ctx, QuotaSubject(req.TokenUserID, req.VisitorID), req.TokenUserID, req.TokenPlan); err != nil {
+		return nil, err
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate job ID: %w", err)
+	}
+
+	job := &models.QueryJob{
+		ID:        id,
+		SessionID: req.SessionID,
+		Status:    JobPending,
+	}
+	if err := db.DB.Create(job).Error; err != nil {
+		return nil, fmt.Errorf("failed to create query job: %w", err)
+	}
+
+	select {
+	case s.queue <- queryJob{id: id, req: req}:
+	default:
+		s.finish(id, JobFailed, nil, "job queue is full")
+		return nil, fmt.Errorf("%w: query job queue is full", ErrOverloaded)
+	}
+
+	return job, nil
+}
+
+// GetJob returns a job and, once completed, its query response
+func (s *QueryJobService) GetJob(ctx context.Context, id string) (*models.QueryJob, error) {
+	var job models.QueryJob
+	if err := db.DB.Where("id = ?", id).First(&job).Error; err != nil {
+		return nil, notFoundError("query job", err)
+	}
+
+	if job.Status == JobCompleted && job.Result != "" {
+		var response models.QueryResponse
+		if err := json.Unmarshal([]byte(job.Result), &response); err != nil {
+			return nil, fmt.Errorf("failed to decode job result: %w", err)
+		}
+		job.Response = &response
+	}
+
+	return &job, nil
+}
+
+// worker processes queued jobs until shutdown begins
+func (s *QueryJobService) worker(ctx context.Context) {
+	stop := s.lifecycle.Context().Done()
+	for {
+		select {
+		case <-stop:
+			s.abandonQueued()
+			return
+		case job := <-s.queue:
+			s.run(ctx, job)
+		}
+	}
+}
+
+// abandonQueued fails jobs still queued at shutdown so clients stop polling
+func (s *QueryJobService) abandonQueued() {
+	for {
+		select {
+		case job := <-s.queue:
+			logrus.WithField("job_id", job.id).Warn("Abandoned queued query job at shutdown")
+			s.finish(job.id, JobFailed, nil, "server shutting down")
+		default:
+			return
+		}
+	}
+}
+
+// run processes one job, always leaving it completed or failed
+func (s *QueryJobService) run(ctx context.Context, job queryJob) {
+	defer func() {
+		if r := recover(); r != nil {
+			logrus.WithField("job_id", job.id).Errorf("Query job panicked: %v", r)
+			s.finish(job.id, JobFailed, nil, fmt.Sprintf("internal error: %v", r))
+		}
+	}()
+
+	db.DB.Model(&models.QueryJob{}).Where("id = ?", job.id).Update("status", JobRunning)
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	response, err := s.queryService.ProcessQuery(ctx, job.req)
+	if err != nil {
+		logrus.WithError(err).WithField("job_id", job.id).Warn("Query job failed")
+		s.finish(job.id, JobFailed, nil, err.Error())
+		return
+	}
+
+	s.finish(job.id, JobCompleted, response, "")
+}
+
+// finish records the final state of a job
+func (s *QueryJobService) finish(id, status string, response *models.QueryResponse, errMessage string) {
+	now := time.Now().UTC()
+	updates := map[string]interface{}{
+		"status":       status,
+		"error":        errMessage,
+		"completed_at": &now,
+	}
+
+	if response != nil {
+		result, err := json.Marshal(response)
+		if err != nil {
+			updates["status"] = JobFailed
+			updates["error"] = fmt.Sprintf("failed to encode result: %v", err)
+		} else {
+			updates["result"] = string(result)
+			updates["query_id"] = response.QueryID
+		}
+	}
+
+	if err := db.DB.Model(&models.QueryJob{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		logrus.WithError(err).WithField("job_id", id).Error("Failed to update query job")
+	}
+}
+
+// collectGarbage deletes jobs older than the TTL, including any left pending by a restart
+func (s *QueryJobService) collectGarbage(ctx context.Context) {
+	interval := s.ttl / 4
+	if interval > time.Hour {
+		interval = time.Hour
+	}
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cutoff := time.Now().Add(-s.ttl)
+			result := db.DB.Where("created_at < ?", cutoff).Delete(&models.QueryJob{})
+			if result.Error != nil {
+				logrus.WithError(result.Error).Warn("Failed to delete expired query jobs")
+			} else if result.RowsAffected > 0 {
+				logrus.WithField("count", result.RowsAffected).Info("Deleted expired query jobs")
+			}
+		}
+	}
+}
+
+// newJobID returns a random job identifier
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
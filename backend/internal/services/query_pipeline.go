@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/middleware"
+	"github.com/ai-support-assistant/backend/internal/models"
+)
+
+// Query pipeline stages, in the order they run
+const (
+	StageValidate         = "validate"
+	StageQuota            = "quota"
+	StageSession          = "session"
+	StagePrepare          = "prepare"
+	StageCacheLookup      = "cache_lookup"
+	StageCannedAnswer     = "canned_answer"
+	StageModerateQuery    = "moderate_query"
+	StageGenerate         = "generate"
+	StageModerateResponse = "moderate_response"
+	StageVerify           = "verify"
+	StageResponseLanguage = "response_language"
+	StagePersist          = "persist"
+	StageRespond          = "respond"
+	StageCacheStore       = "cache_store"
+	StageDispatch         = "dispatch"
+)
+
+// QueryStage is a step of the query pipeline. A stage reads and adds to the
+// query context; returning an error fails the query, and setting Done ends
+// it with the context's Response.
+type QueryStage interface {
+	Name() string
+	Process(ctx context.Context, qc *QueryContext) error
+}
+
+// QueryContext carries a query through the pipeline: the request, what the
+// stages have worked out so far and, once answered, the response
+type QueryContext struct {
+	Request   models.QueryRequest
+	StartTime time.Time
+
+	// Set by the quota and session stages. Opening marks a query opening a
+	// conversation, whose answer has no history to draw on.
+	QuotaSubject string
+	Opening      bool
+
+	// Set by the prepare stage
+	Language   string
+	Prompt     *models.PromptTemplate
+	Assignment *ExperimentAssignment
+	Persona    *models.Persona
+	RAGRequest RAGQueryRequest
+	CacheKey   string
+
+	// BypassReason is why the cache was skipped, if it was; otherwise
+	// CacheTTL is how long the answer is cached
+	BypassReason string
+	CacheTTL     cacheTTL
+
+	// Moderation outcome; Enforce is set when flagged text is refused
+	Enforce    bool
+	Flagged    bool
+	Categories []string
+
+	// The answer and how it was generated. CalledRAG is set once the RAG
+	// service was asked; a refused query never reaches it. Pipeline is
+	// direct when the LLM answered a general question instead.
+	RAGResponse     *RAGQueryResponse
+	CalledRAG       bool
+	Pipeline        string
+	EstimatedTokens int
+	RoutingRuleID   *uint
+	Partial         bool
+	Verdict         grounding
+	Translated      bool
+
+	// The stored query, whether its answer may be cached and whether the
+	// caller is asked for feedback on it
+	ChatQuery         models.ChatQuery
+	Cacheable         bool
+	FeedbackRequested bool
+
+	// OnToken receives the answer's tokens as they are generated; it is only
+	// set for queries streamed with ProcessQueryStream
+	OnToken func(token string) error
+
+	// Response is the answer returned to the caller; Done is set by a stage
+	// that answered the query, such as a cache hit, so no later stage runs
+	Response *models.QueryResponse
+	Done     bool
+
+	// StageTimings records how long each stage that ran took, in milliseconds
+	StageTimings map[string]int
+}
+
+// refused reports whether the query or its answer was flagged and replaced
+// by the refusal message
+func (qc *QueryContext) refused() bool {
+	return qc.Flagged && qc.Enforce
+}
+
+// generated reports whether the answer was generated, by the RAG service or
+// directly by the LLM
+func (qc *QueryContext) generated() bool {
+	return qc.CalledRAG || qc.Pipeline == models.PipelineDirect
+}
+
+// streaming reports whether the answer's tokens are passed on as they are
+// generated. They aren't while moderation is enforced, since the answer must
+// be moderated before any of it is shown.
+func (qc *QueryContext) streaming() bool {
+	return qc.OnToken != nil && !qc.Enforce
+}
+
+// queryStage adapts a function to a QueryStage
+type queryStage struct {
+	name    string
+	process func(ctx context.Context, qc *QueryContext) error
+}
+
+func (st queryStage) Name() string {
+	return st.name
+}
+
+func (st queryStage) Process(ctx context.Context, qc *QueryContext) error {
+	return st.process(ctx, qc)
+}
+
+// queryStages assembles the query pipeline. Stages for features switched
+// off in the config are left out.
+func (s *QueryService) queryStages() []QueryStage {
+	stages := []QueryStage{
+		queryStage{StageValidate, s.validateStage},
+		queryStage{StageQuota, s.quotaStage},
+		queryStage{StageSession, s.sessionStage},
+		queryStage{StagePrepare, s.prepareStage},
+		queryStage{StageCacheLookup, s.cacheLookupStage},
+		queryStage{StageCannedAnswer, s.cannedAnswerStage},
+		queryStage{StageModerateQuery, s.moderateQueryStage},
+		queryStage{StageGenerate, s.generateStage},
+		queryStage{StageModerateResponse, s.moderateResponseStage},
+	}
+	if s.cfg.VerificationMode != VerificationOff {
+		stages = append(stages, queryStage{StageVerify, s.verifyStage})
+	}
+	if s.cfg.ResponseLanguageMode != ResponseLanguageAuto {
+		stages = append(stages, queryStage{StageResponseLanguage, s.responseLanguageStage})
+	}
+	return append(stages,
+		queryStage{StagePersist, s.persistStage},
+		queryStage{StageRespond, s.respondStage},
+		queryStage{StageCacheStore, s.cacheStoreStage},
+		queryStage{StageDispatch, s.dispatchStage},
+	)
+}
+
+// runStages runs the pipeline's stages in order until one fails or answers
+// the query, timing each
+func runStages(ctx context.Context, stages []QueryStage, qc *QueryContext) error {
+	qc.StageTimings = make(map[string]int, len(stages))
+	for _, stage := range stages {
+		started := time.Now()
+		err := stage.Process(ctx, qc)
+		elapsed := time.Since(started)
+
+		middleware.RecordQueryStage(stage.Name(), elapsed)
+		qc.StageTimings[stage.Name()] = int(elapsed.Milliseconds())
+
+		if err != nil {
+			return err
+		}
+		if qc.Done {
+			return nil
+		}
+	}
+	return nil
+}
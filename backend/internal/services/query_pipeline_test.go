@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/models"
+)
+
+// recordingStages builds stages that append their names to ran as they run
+func recordingStages(ran *[]string, names ...string) []QueryStage {
+	stages := make([]QueryStage, len(names))
+	for i, name := range names {
+		name := name
+		stages[i] = queryStage{name, func(ctx context.Context, qc *QueryContext) error {
+			*ran = append(*ran, name)
+			return nil
+		}}
+	}
+	return stages
+}
+
+func TestRunStagesInOrder(t *testing.T) {
+	var ran []string
+	stages := recordingStages(&ran, "first", "second", "third")
+	stages[1] = queryStage{"second", func(ctx context.Context, qc *QueryContext) error {
+		ran = append(ran, "second")
+		time.Sleep(5 * time.Millisecond)
+		qc.Response = &models.QueryResponse{Response: "answered"}
+		return nil
+	}}
+
+	qc := &QueryContext{}
+	if err := runStages(context.Background(), stages, qc); err != nil {
+		t.Fatalf("runStages: %v", err)
+	}
+	if !reflect.DeepEqual(ran, []string{"first", "second", "third"}) {
+		t.Errorf("ran %v, want every stage in order", ran)
+	}
+	if qc.Response == nil || qc.Response.Response != "answered" {
+		t.Errorf("response = %+v, want the one a stage set", qc.Response)
+	}
+	if len(qc.StageTimings) != 3 || qc.StageTimings["second"] < 5 {
+		t.Errorf("timings = %v, want one per stage", qc.StageTimings)
+	}
+}
+
+func TestRunStagesStopsWhenDone(t *testing.T) {
+	var ran []string
+	stages := recordingStages(&ran, StageValidate, StageCacheLookup, StageGenerate, StagePersist)
+	stages[1] = queryStage{StageCacheLookup, func(ctx context.Context, qc *QueryContext) error {
+		ran = append(ran, StageCacheLookup)
+		qc.Response, qc.Done = &models.QueryResponse{CacheHit: true}, true
+		return nil
+	}}
+
+	qc := &QueryContext{}
+	if err := runStages(context.Background(), stages, qc); err != nil {
+		t.Fatalf("runStages: %v", err)
+	}
+	if !reflect.DeepEqual(ran, []string{StageValidate, StageCacheLookup}) {
+		t.Errorf("ran %v, want the cache hit to end the pipeline", ran)
+	}
+	if _, timed := qc.StageTimings[StageGenerate]; timed || len(qc.StageTimings) != 2 {
+		t.Errorf("timings = %v, want only the stages that ran", qc.StageTimings)
+	}
+}
+
+func TestRunStagesStopsOnError(t *testing.T) {
+	var ran []string
+	stages := recordingStages(&ran, StageValidate, StageQuota, StageSession)
+	refused := errors.New("quota exceeded")
+	stages[1] = queryStage{StageQuota, func(ctx context.Context, qc *QueryContext) error {
+		return refused
+	}}
+
+	qc := &QueryContext{}
+	if err := runStages(context.Background(), stages, qc); !errors.Is(err, refused) {
+		t.Fatalf("error = %v, want the stage's error", err)
+	}
+	if !reflect.DeepEqual(ran, []string{StageValidate}) {
+		t.Errorf("ran %v, want nothing after the failing stage", ran)
+	}
+	if _, timed := qc.StageTimings[StageQuota]; !timed {
+		t.Error("failing stage wasn't timed")
+	}
+}
+
+func TestQueryStagesFollowConfig(t *testing.T) {
+	names := func(cfg *config.Config) []string {
+		var got []string
+		for _, stage := range (&QueryService{cfg: cfg}).queryStages() {
+			got = append(got, stage.Name())
+		}
+		return got
+	}
+	core := []string{StageValidate, StageQuota, StageSession, StagePrepare, StageCacheLookup, StageCannedAnswer,
+		StageModerateQuery, StageGenerate, StageModerateResponse}
+	tail := []string{StagePersist, StageRespond, StageCacheStore, StageDispatch}
+
+	tests := []struct {
+		name string
+		cfg  *config.Config
+		want []string
+	}{
+		{"optional stages off", &config.Config{VerificationMode: VerificationOff, ResponseLanguageMode: ResponseLanguageAuto},
+			append(append([]string{}, core...), tail...)},
+		{"verification on", &config.Config{VerificationMode: VerificationLog, ResponseLanguageMode: ResponseLanguageAuto},
+			append(append(append([]string{}, core...), StageVerify), tail...)},
+		{"response language on", &config.Config{VerificationMode: VerificationOff, ResponseLanguageMode: ResponseLanguageMatchUser},
+			append(append(append([]string{}, core...), StageResponseLanguage), tail...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := names(tt.cfg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("stages = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// claimedSession answers session lookups with a session claimed by u1
+func claimedSession(query string, args []driver.NamedValue) (*fakeRows, error) {
+	if !strings.Contains(query, `FROM "sessions"`) {
+		return nil, nil
+	}
+	return &fakeRows{
+		columns: []string{"segment", "last_activity_at", "user_id"},
+		values:  [][]driver.Value{{int64(0), time.Now(), "u1"}},
+	}, nil
+}
+
+func TestSessionStageClaimedSession(t *testing.T) {
+	useFakeDB(t, claimedSession)
+	s := &QueryService{cfg: &config.Config{SessionIdleMinutes: 30}}
+
+	tests := []struct {
+		name        string
+		tokenUserID string
+		bodyUserID  string
+		allowed     bool
+	}{
+		{"owner", "u1", "", true},
+		{"owner naming someone else in the body", "u1", "u2", true},
+		{"anonymous caller", "", "", false},
+		{"anonymous caller claiming to be the owner", "", "u1", false},
+		{"another user", "u2", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qc := &QueryContext{Request: models.QueryRequest{SessionID: "s1", TokenUserID: tt.tokenUserID, UserID: tt.bodyUserID}}
+			err := s.sessionStage(context.Background(), qc)
+			if !tt.allowed {
+				if !errors.Is(err, ErrForbidden) {
+					t.Errorf("error = %v, want ErrForbidden", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("sessionStage: %v", err)
+			}
+			if qc.Request.UserID != "u1" {
+				t.Errorf("query recorded for %q, want the owner", qc.Request.UserID)
+			}
+		})
+	}
+}
+
+func TestSessionStageUnclaimedSession(t *testing.T) {
+	useFakeDB(t, nil)
+	s := &QueryService{cfg: &config.Config{SessionIdleMinutes: 30}}
+
+	qc := &QueryContext{Request: models.QueryRequest{SessionID: "s1", UserID: "visitor-7"}}
+	if err := s.sessionStage(context.Background(), qc); err != nil {
+		t.Fatalf("sessionStage: %v", err)
+	}
+	if qc.Request.UserID != "visitor-7" || !qc.Opening {
+		t.Errorf("request = %+v, opening %t; want a new session keeping its user ID", qc.Request, qc.Opening)
+	}
+}
@@ -1,29 +1,138 @@
 package services
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/ai-support-assistant/backend/internal/activity"
 	"github.com/ai-support-assistant/backend/internal/cache"
 	"github.com/ai-support-assistant/backend/internal/config"
 	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/langdetect"
+	"github.com/ai-support-assistant/backend/internal/lifecycle"
 	"github.com/ai-support-assistant/backend/internal/middleware"
 	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/moderation"
+	"github.com/ai-support-assistant/backend/internal/postprocess"
+	"github.com/ai-support-assistant/backend/internal/ragclient"
+	"github.com/ai-support-assistant/backend/internal/webhook"
 	"github.com/go-redis/redis/v8"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+)
+
+// maxUserAgentLength caps the stored user agent
+const maxUserAgentLength = 512
+
+// defaultTopK is the number of chunks retrieved for a query
+const defaultTopK = 5
+
+// localePattern matches BCP 47 style locales such as "en", "en-US" or "pt_BR"
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}([-_][A-Za-z0-9]{2,8})*$`)
+
+// Reasons reported when a query bypasses the response cache
+const (
+	CacheBypassRequest    = "no_cache_requested"
+	CacheBypassHeader     = "cache_control_header"
+	CacheBypassPattern    = "bypass_pattern"
+	CacheBypassFeedback   = "negative_feedback"
+	CacheBypassRegenerate = "regenerate"
+	CacheBypassAttachment = "attachments"
 )
 
 type QueryService struct {
-	cfg *config.Config
+	cfg           *config.Config
+	settings      *SettingsService
+	dispatcher    *webhook.Dispatcher
+	feed          *activity.Bus
+	moderator     *moderation.Client
+	verifier      *ragclient.Client
+	cannedAnswers *CannedAnswerService
+	prompts       *PromptService
+	experiments   *ExperimentService
+	routing       *ModelRoutingService
+	health        *HealthService
+	transport     RAGTransport
+	fallback      RAGTransport // nil unless RAG_SERVICE_FALLBACK_URL is set
+	limiter       *RAGLimiter
+	quotas        *QuotaService
+	didYouMean    *DidYouMeanService
+	personas      *PersonaService
+	direct        *DirectAnswerService
+	lifecycle     *lifecycle.Manager
+
+	bypassPatterns []*regexp.Regexp
+
+	// inflight coalesces concurrent RAG calls for the same normalized query;
+	// generations maps each in-flight key to the generation ID of its call
+	inflight    singleflight.Group
+	generations sync.Map
+
+	// refreshSlots bounds concurrent background refreshes of stale cache entries
+	refreshSlots chan struct{}
+
+	// pipeline cleans up RAG responses before they are stored or cached
+	pipeline *postprocess.Pipeline
+
+	// stages are the steps ProcessQuery runs a query through
+	stages []QueryStage
+
+	// Per-model context windows and token estimate calibration factors
+	contextLimits    map[string]int
+	tokenCalibration map[string]float64
+
+	// warmup is the latest cache warm-up run, guarded by warmupMu
+	warmupMu sync.Mutex
+	warmup   *models.CacheWarmupResult
 }
 
-func NewQueryService(cfg *config.Config) *QueryService {
-	return &QueryService{cfg: cfg}
+func NewQueryService(cfg *config.Config, settings *SettingsService, dispatcher *webhook.Dispatcher, feed *activity.Bus, cannedAnswers *CannedAnswerService, prompts *PromptService, experiments *ExperimentService, routing *ModelRoutingService, health *HealthService, transport, fallback RAGTransport, limiter *RAGLimiter, quotas *QuotaService, didYouMean *DidYouMeanService, personas *PersonaService, direct *DirectAnswerService, lc *lifecycle.Manager) *QueryService {
+	refreshConcurrency := cfg.CacheRefreshConcurrency
+	if refreshConcurrency <= 0 {
+		refreshConcurrency = 1
+	}
+
+	// Both were validated when the config was loaded
+	contextLimits, _ := config.ParseModelLimits(cfg.ModelContextLimits)
+	tokenCalibration, _ := config.ParseModelFactors(cfg.TokenCalibration)
+
+	s := &QueryService{
+		cfg:           cfg,
+		settings:      settings,
+		dispatcher:    dispatcher,
+		feed:          feed,
+		moderator:     moderation.NewClient(cfg.ModerationURL, cfg.OpenAIKey),
+		verifier:      ragclient.NewClient(cfg.RAGServiceURL),
+		cannedAnswers: cannedAnswers,
+		prompts:       prompts,
+		experiments:   experiments,
+		routing:       routing,
+		health:        health,
+		transport:     transport,
+		fallback:      fallback,
+		limiter:       limiter,
+		quotas:        quotas,
+		didYouMean:    didYouMean,
+		personas:      personas,
+		direct:        direct,
+		lifecycle:     lc,
+
+		bypassPatterns: compilePatterns(cfg.CacheBypassPatterns),
+		refreshSlots:   make(chan struct{}, refreshConcurrency),
+		pipeline:       newResponsePipeline(cfg),
+
+		contextLimits:    contextLimits,
+		tokenCalibration: tokenCalibration,
+	}
+	s.stages = s.queryStages()
+	return s
 }
 
 // RAGQueryRequest represents the request to RAG service
@@ -31,134 +140,1089 @@ type RAGQueryRequest struct {
 	Query     string `json:"query"`
 	SessionID string `json:"session_id"`
 	TopK      int    `json:"top_k"`
+	Language  string `json:"language,omitempty"`
+
+	Collections []string `json:"collections,omitempty"`
+
+	// Audience is customer or agent; retrieval for customers leaves out
+	// internal documents
+	Audience string `json:"audience,omitempty"`
+
+	// Segment numbers the session's conversations; history from earlier
+	// segments must not be used as context. ContextReset marks the first
+	// query of a new segment.
+	Segment      int  `json:"segment"`
+	ContextReset bool `json:"context_reset,omitempty"`
+
+	// HistoryTurns caps how many of the segment's latest turns may be used
+	// as history; set when older turns were trimmed to fit the context window
+	HistoryTurns *int `json:"history_turns,omitempty"`
+
+	// Page context from the client so answers can refer to where the user is
+	PageURL string `json:"page_url,omitempty"`
+	Locale  string `json:"locale,omitempty"`
+
+	// ResponseLanguage is the ISO 639-1 code of the language the answer must
+	// be in, per RESPONSE_LANGUAGE_MODE
+	ResponseLanguage string `json:"response_language,omitempty"`
+
+	// Persona shapes the voice of the answer for the widget config's origin
+	Persona *RAGPersona `json:"persona,omitempty"`
+
+	// Model overrides the RAG service's default model for experiment variants
+	// and regenerations
+	Model string `json:"model,omitempty"`
+
+	// Temperature overrides the RAG service's sampling temperature when
+	// regenerating an answer
+	Temperature *float64 `json:"temperature,omitempty"`
+
+	// Follow-up question candidates; RAG services without support ignore these
+	IncludeSuggestions bool `json:"include_suggestions,omitempty"`
+	MaxSuggestions     int  `json:"max_suggestions,omitempty"`
+
+	// The active prompt template; the body is only sent when PROMPT_SEND_BODY is set
+	PromptTemplate string `json:"prompt_template,omitempty"`
+	PromptVersion  int    `json:"prompt_version,omitempty"`
+	PromptBody     string `json:"prompt_body,omitempty"`
+
+	// GenerationID identifies the answer's generation, so what was generated
+	// can be fetched if the call times out; RAG services without support
+	// ignore it
+	GenerationID string `json:"generation_id,omitempty"`
+
+	// Attachments are images sent with the query; a request with them goes
+	// to the multimodal endpoint as multipart. AttachmentHash keeps queries
+	// with different images from sharing a call.
+	Attachments    []RAGAttachment `json:"-"`
+	AttachmentHash string          `json:"-"`
+}
+
+// RAGPersona is the persona an answer is given in: instructions added to the
+// system prompt, a tone descriptor and a line to sign answers with
+type RAGPersona struct {
+	Prompt    string `json:"prompt,omitempty"`
+	Tone      string `json:"tone,omitempty"`
+	Signature string `json:"signature,omitempty"`
 }
 
 // RAGQueryResponse represents the response from RAG service
 type RAGQueryResponse struct {
-	Response   string   `json:"response"`
-	Context    []string `json:"context"`
-	Model      string   `json:"model"`
-	TokensUsed int      `json:"tokens_used"`
+	Response    string      `json:"response"`
+	Context     []string    `json:"context"`
+	Sources     []RAGSource `json:"sources,omitempty"`
+	Suggestions []string    `json:"suggestions,omitempty"`
+	Model       string      `json:"model"`
+	TokensUsed  int         `json:"tokens_used"`
+
+	// Prompt and completion tokens, when the RAG service reports them
+	PromptTokens     int `json:"prompt_tokens,omitempty"`
+	CompletionTokens int `json:"completion_tokens,omitempty"`
+
+	// Endpoint is the RAG endpoint that answered, primary or fallback; it is
+	// set here rather than by the RAG service
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Phase timings; older RAG services omit them
+	models.PhaseTimings
 }
 
-// ProcessQuery processes a user query
+// RAGSource is the metadata of a retrieved context chunk, when the RAG service provides it
+type RAGSource struct {
+	Source string `json:"source"` // file name of the ingested document
+	DocID  string `json:"doc_id"` // vector store ID returned at ingestion
+}
+
+// ValidateRequest checks a query request beyond what binding validates,
+// normalizing its collection filter
+func (s *QueryService) ValidateRequest(ctx context.Context, req *models.QueryRequest) error {
+	collections, err := validateCollections(req.Collections)
+	if err != nil {
+		return err
+	}
+	if req.GenerationID != "" && !generationIDPattern.MatchString(req.GenerationID) {
+		return validationError("invalid generation_id %q", req.GenerationID)
+	}
+	req.Collections = collections
+
+	metadata, err := normalizeMetadata(req.Metadata)
+	if err != nil {
+		return err
+	}
+	req.Metadata = metadata
+
+	audience, err := resolveAudience(req.Audience, req.TokenAudience)
+	if err != nil {
+		return err
+	}
+	req.Audience = audience
+
+	return validateAttachments(s.cfg, req.Attachments)
+}
+
+// resolveAudience defaults a query's audience to its token's, or customer,
+// and only lets tokens with the agent audience ask as agents
+func resolveAudience(requested, token string) (string, error) {
+	if token != models.AudienceAgent {
+		token = models.AudienceCustomer
+	}
+	switch requested {
+	case "":
+		return token, nil
+	case models.AudienceCustomer:
+		return requested, nil
+	case models.AudienceAgent:
+		if token != models.AudienceAgent {
+			return "", fmt.Errorf("%w: the agent audience requires an agent token", ErrForbidden)
+		}
+		return requested, nil
+	default:
+		return "", validationError("invalid audience %q", requested)
+	}
+}
+
+// normalizeMetadata validates client metadata and strips query strings and fragments
+// from URLs, which can carry tokens and would fragment page analytics. Returns nil
+// when nothing is left.
+func normalizeMetadata(metadata *models.QueryMetadata) (*models.QueryMetadata, error) {
+	if metadata == nil {
+		return nil, nil
+	}
+
+	normalized := *metadata
+
+	var err error
+	if normalized.PageURL, err = normalizeMetadataURL("page_url", normalized.PageURL); err != nil {
+		return nil, err
+	}
+	if normalized.Referrer, err = normalizeMetadataURL("referrer", normalized.Referrer); err != nil {
+		return nil, err
+	}
+
+	if normalized.Locale != "" {
+		if !localePattern.MatchString(normalized.Locale) {
+			return nil, fmt.Errorf("%w: invalid locale %q", ErrInvalidRequest, normalized.Locale)
+		}
+		normalized.Locale = strings.ReplaceAll(normalized.Locale, "_", "-")
+	}
+
+	if runes := []rune(normalized.UserAgent); len(runes) > maxUserAgentLength {
+		normalized.UserAgent = string(runes[:maxUserAgentLength])
+	}
+
+	if normalized == (models.QueryMetadata{}) {
+		return nil, nil
+	}
+	return &normalized, nil
+}
+
+// normalizeMetadataURL requires an http(s) URL and drops its query string and fragment
+func normalizeMetadataURL(field, raw string) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", fmt.Errorf("%w: %s must be an http or https URL", ErrInvalidRequest, field)
+	}
+
+	parsed.RawQuery = ""
+	parsed.ForceQuery = false
+	parsed.Fragment = ""
+	parsed.User = nil
+	return parsed.String(), nil
+}
+
+// ProcessQuery processes a user query through the query pipeline
 func (s *QueryService) ProcessQuery(ctx context.Context, req models.QueryRequest) (*models.QueryResponse, error) {
-	startTime := time.Now()
+	return s.processQuery(ctx, &QueryContext{Request: req, StartTime: time.Now()})
+}
+
+// processQuery runs a query through every stage and finishes its response
+func (s *QueryService) processQuery(ctx context.Context, qc *QueryContext) (*models.QueryResponse, error) {
+	if err := runStages(ctx, s.stages, qc); err != nil {
+		return nil, err
+	}
+
+	// The first answer stored for a session carries the token needed to
+	// claim it. Like the timings below it is added once every stage has run,
+	// so it is never cached or sent to webhooks.
+	if qc.Opening && !qc.Request.Synthetic {
+		qc.Response.ClaimToken = issueClaimToken(ctx, qc.Request.SessionID)
+	}
+
+	// Timings are added once every stage has run, so they are never cached
+	// or sent to webhooks
+	if qc.Request.Debug && qc.Request.TokenAudience == models.AudienceAgent {
+		qc.Response.StageTimings = qc.StageTimings
+		if qc.BypassReason == "" {
+			qc.Response.CacheTTL = qc.CacheTTL.debug()
+		}
+	}
+	return qc.Response, nil
+}
+
+// validateStage checks and normalizes the request
+func (s *QueryService) validateStage(ctx context.Context, qc *QueryContext) error {
+	return s.ValidateRequest(ctx, &qc.Request)
+}
+
+// quotaStage refuses callers that used up their plan's daily queries;
+// internal queries don't count
+func (s *QueryService) quotaStage(ctx context.Context, qc *QueryContext) error {
+	req := &qc.Request
+	if !req.Synthetic {
+		qc.QuotaSubject = QuotaSubject(req.TokenUserID, req.VisitorID)
+	}
+	return s.quotas.Check(ctx, qc.QuotaSubject, req.TokenUserID, req.TokenPlan)
+}
+
+// sessionStage starts a fresh conversation after a long pause. A claimed
+// session only takes queries from its owner, which are recorded as theirs.
+func (s *QueryService) sessionStage(ctx context.Context, qc *QueryContext) error {
+	req := &qc.Request
+	var owner string
+	req.Segment, req.ContextReset, qc.Opening, owner = sessionSegment(ctx, req.SessionID, time.Duration(s.cfg.SessionIdleMinutes)*time.Minute)
+	if owner != "" {
+		if req.TokenUserID != owner {
+			return fmt.Errorf("%w: session belongs to another user", ErrForbidden)
+		}
+		req.UserID = owner
+	}
+	if req.ContextReset {
+		logrus.WithFields(logrus.Fields{
+			"session_id": req.SessionID,
+			"segment":    req.Segment,
+		}).Info("Session idle, starting a new conversation segment")
+	}
+	return nil
+}
+
+// prepareStage works out the language, prompt, experiment variant and
+// persona of the query, and from them the RAG request and cache key
+func (s *QueryService) prepareStage(ctx context.Context, qc *QueryContext) error {
+	req := qc.Request
+
+	// Detect the language so retrieval can adapt and answers aren't shared across languages
+	qc.Language = langdetect.Detect(req.Query)
+
+	// The log hook reduces the query to a hash unless debug logging is on
+	logrus.WithFields(logrus.Fields{
+		"session_id": req.SessionID,
+		"language":   qc.Language,
+		"query":      req.Query,
+	}).Info("Processing query")
+
+	// Route the session to its experiment variant, if any; the variant's prompt
+	// replaces the active one. Synthetic queries always use the active prompt.
+	qc.Prompt = s.prompts.Active(ctx)
+	if !req.Synthetic {
+		qc.Assignment = s.experiments.Assign(ctx, req.SessionID)
+	}
+	if qc.Assignment != nil && qc.Assignment.Prompt != nil {
+		qc.Prompt = qc.Assignment.Prompt
+	}
+	qc.Persona = s.personas.ForQuery(ctx, req)
+	qc.RAGRequest = s.ragRequest(req, qc.Language, qc.Prompt, qc.Assignment, qc.Persona)
+	ragReq := qc.RAGRequest
+
+	// Generate cache key; answers generated under a different prompt or variant must not be
+	// served, nor answers drawing on internal documents to customers, nor answers from
+	// an earlier conversation segment. A query opening a conversation has no
+	// history to draw on, so its answer is shared across sessions, and
+	// queries differing only in case or punctuation share answers.
+	sessionKey, segmentKey := req.SessionID, strconv.Itoa(req.Segment)
+	if qc.Opening {
+		sessionKey, segmentKey = "", ""
+	}
+	keyParts := []string{normalizeQuery(req.Query), sessionKey, qc.Language, strings.Join(req.Collections, ","),
+		promptCacheNamespace(qc.Prompt), qc.Assignment.CacheNamespace(), ragReq.PageURL, ragReq.Locale, req.Audience,
+		segmentKey}
+	if ragReq.IncludeSuggestions {
+		keyParts = append(keyParts, "suggestions")
+	}
+	if ragReq.ResponseLanguage != "" {
+		keyParts = append(keyParts, "response_language="+ragReq.ResponseLanguage)
+	}
+	if qc.Persona != nil {
+		keyParts = append(keyParts, fmt.Sprintf("persona=%d:%d", qc.Persona.ID, qc.Persona.Version))
+	}
+	qc.CacheKey = cache.GenerateCacheKey("query", keyParts...)
+	return nil
+}
 
-	// Generate cache key
-	cacheKey := cache.GenerateCacheKey("query", req.Query, req.SessionID)
+// cacheLookupStage answers from the cache unless the request must not be
+// served from it
+func (s *QueryService) cacheLookupStage(ctx context.Context, qc *QueryContext) error {
+	req := qc.Request
+	qc.BypassReason = s.cacheBypassReason(ctx, req)
+	if qc.BypassReason != "" {
+		middleware.RecordCacheBypass("query", qc.BypassReason)
+		logrus.WithField("reason", qc.BypassReason).Debug("Bypassing cache for query")
+		return nil
+	}
+	qc.CacheTTL = s.cacheTTLFor(ctx, req)
 
-	// Check cache
-	var cachedResponse models.QueryResponse
-	err := cache.Get(ctx, cacheKey, &cachedResponse)
+	var cached cachedQuery
+	err := cache.Get(ctx, qc.CacheKey, &cached)
+	// Direct answers stop being served once direct answers are switched off
+	if err == nil && cached.Pipeline == models.PipelineDirect && !s.direct.enabled() {
+		err = redis.Nil
+	}
 	if err == nil {
-		// Cache hit
-		middleware.RecordCacheHit("query")
-		logrus.WithField("cache_key", cacheKey).Info("Cache hit for query")
+		cachedResponse := cached.QueryResponse
+		cachedResponse.SessionID = req.SessionID
 		cachedResponse.CacheHit = true
-		cachedResponse.Latency = int(time.Since(startTime).Milliseconds())
-		return &cachedResponse, nil
+		cachedResponse.Latency = int(time.Since(qc.StartTime).Milliseconds())
+		cachedResponse.PhaseTimings = models.PhaseTimings{}
+		cachedResponse.ContextReset = req.ContextReset
+
+		// Suggestions are cached unfiltered since the session's history keeps growing
+		cachedResponse.Suggestions = s.filterSuggestions(ctx, req.SessionID, req.Query, cached.Suggestions)
+
+		s.quotas.Record(ctx, qc.QuotaSubject, true)
+		s.recordCacheHit(ctx, qc, &cachedResponse)
+		qc.Response, qc.Done = &cachedResponse, true
+
+		if cached.fresh() {
+			middleware.RecordCacheHit("query")
+			logrus.WithField("cache_key", qc.CacheKey).Info("Cache hit for query")
+			return nil
+		}
+
+		// Past its fresh TTL: answer immediately and refresh in the background
+		middleware.RecordCacheStaleHit("query")
+		logrus.WithField("cache_key", qc.CacheKey).Info("Stale cache hit for query")
+		generatedAt := cached.Timestamp
+		cachedResponse.Stale = true
+		cachedResponse.GeneratedAt = &generatedAt
+		s.refreshInBackground(ctx, qc.CacheKey, qc.RAGRequest, cached.QueryResponse, qc.CacheTTL)
+		return nil
 	} else if err != redis.Nil {
 		logrus.WithError(err).Warn("Failed to get from cache")
 	}
+	middleware.RecordCacheMiss("query")
+	return nil
+}
 
-	// Call RAG service
-	ragReq := RAGQueryRequest{
-		Query:     req.Query,
-		SessionID: req.SessionID,
-		TopK:      5,
+// cannedAnswerStage answers with a pinned canned answer, bypassing the RAG
+// pipeline entirely; a regeneration wants a different answer than the pinned
+// one, and a query with images may be about something the pinned answer
+// doesn't cover
+func (s *QueryService) cannedAnswerStage(ctx context.Context, qc *QueryContext) error {
+	req := qc.Request
+	if req.ParentQueryID != nil || len(req.Attachments) > 0 {
+		return nil
+	}
+	if canned := s.cannedAnswers.Match(ctx, req.Query); canned != nil {
+		s.quotas.Record(ctx, qc.QuotaSubject, false)
+		qc.Response, qc.Done = s.answerCanned(req, canned, qc.Language, qc.StartTime), true
+	}
+	return nil
+}
+
+// moderateQueryStage moderates the user query before it reaches the RAG
+// service, answering a flagged one with the refusal message when
+// moderation is enforced
+func (s *QueryService) moderateQueryStage(ctx context.Context, qc *QueryContext) error {
+	qc.Enforce = s.settings.ModerationMode() == moderation.ModeEnforce
+	qc.Flagged, qc.Categories = s.moderate(ctx, "query", qc.Request.Query)
+	if qc.refused() {
+		qc.RAGResponse = &RAGQueryResponse{
+			Response: s.settings.ModerationRefusalMessage(),
+			Context:  []string{},
+			Model:    ModerationModel,
+		}
 	}
+	return nil
+}
+
+// generateStage has the RAG service answer the query, or the LLM if it is
+// a general question
+func (s *QueryService) generateStage(ctx context.Context, qc *QueryContext) error {
+	if qc.refused() {
+		return nil
+	}
+
+	// General questions skip retrieval when direct answers are on; images
+	// need the RAG service's multimodal endpoint
+	pipeline, method := models.PipelineRAG, routeDefault
+	if len(qc.Request.Attachments) == 0 {
+		pipeline, method = s.direct.Route(ctx, qc.Request.Query)
+	}
+	if pipeline == models.PipelineDirect {
+		if answered, err := s.generateDirect(ctx, qc, method); answered {
+			return err
+		}
+	} else {
+		middleware.RecordQueryPipeline(pipeline, method)
+	}
+	qc.Pipeline = models.PipelineRAG
+
+	// While degraded, fail fast instead of waiting out the RAG timeout
+	if !s.health.RAGAvailable() {
+		return &DegradedError{RetryAfter: s.health.ProbeInterval()}
+	}
+
+	ragReq := &qc.RAGRequest
+	qc.RoutingRuleID = s.routeModel(ctx, ragReq, qc.Opening)
 
-	ragResp, err := s.callRAGService(ctx, ragReq)
+	// Reject prompts that won't fit the model's context window rather
+	// than wait for the RAG service to fail on them
+	var err error
+	qc.EstimatedTokens, err = s.budgetPrompt(ctx, ragReq, qc.Opening)
 	if err != nil {
-		return nil, fmt.Errorf("failed to call RAG service: %w", err)
+		return err
 	}
 
+	// Call RAG service, or pick up the generation a timed-out request
+	// for the same question started
+	qc.CalledRAG = true
+	var ragResp *RAGQueryResponse
+	var attach bool
+	ragReq.GenerationID, attach = s.claimGeneration(ctx, qc.Request.GenerationID, *ragReq)
+	if attach {
+		ragResp, err = s.awaitGeneration(ctx, ragReq.GenerationID)
+	}
+	if ragResp == nil && err == nil {
+		if qc.streaming() {
+			ragResp, err = s.streamedRAGCall(ctx, *ragReq, qc.OnToken)
+		} else {
+			ragResp, err = s.coalescedRAGCall(ctx, *ragReq)
+		}
+	}
+	if err != nil {
+		ragResp, qc.Partial, err = s.recoverTimeout(ctx, ragReq, qc.Request.AllowPartial, err)
+		if err != nil {
+			return fmt.Errorf("failed to call RAG service: %w", err)
+		}
+	}
+	middleware.RecordPromptEstimate(ragResp.Model, qc.EstimatedTokens, ragResp.TokensUsed)
+	qc.RAGResponse = ragResp
+	return nil
+}
+
+// moderateResponseStage moderates the generated response before it is
+// returned
+func (s *QueryService) moderateResponseStage(ctx context.Context, qc *QueryContext) error {
+	if !qc.generated() {
+		return nil
+	}
+	if responseFlagged, responseCategories := s.moderate(ctx, "response", qc.RAGResponse.Response); responseFlagged {
+		qc.Flagged = true
+		qc.Categories = append(qc.Categories, responseCategories...)
+		if qc.Enforce {
+			qc.RAGResponse.Response = s.settings.ModerationRefusalMessage()
+			qc.RAGResponse.Context = []string{}
+		}
+	}
+	return nil
+}
+
+// verifyStage checks the answer is supported by the context it was
+// generated from; a partial answer is incomplete and can't be judged
+func (s *QueryService) verifyStage(ctx context.Context, qc *QueryContext) error {
+	if !qc.CalledRAG || qc.refused() || qc.Request.SkipVerification || qc.Partial {
+		return nil
+	}
+	qc.Verdict = s.verifyGrounding(ctx, qc.Request.Query, qc.RAGResponse)
+	if qc.Verdict.lowConfidence {
+		qc.RAGResponse.Response = s.cfg.LowConfidenceMessage
+	}
+	return nil
+}
+
+// responseLanguageStage translates an answer that came back in another
+// language than asked for; refusals and replacements are already in the
+// configured wording
+func (s *QueryService) responseLanguageStage(ctx context.Context, qc *QueryContext) error {
+	if !qc.generated() || qc.refused() || qc.Verdict.lowConfidence {
+		return nil
+	}
+	qc.Translated = s.enforceResponseLanguage(ctx, qc.RAGRequest.ResponseLanguage, qc.RAGResponse)
+	return nil
+}
+
+// persistStage saves the query, counts it against the caller's quota,
+// decides whether to ask for feedback and records the session's activity.
+// The insert commits before the answer is returned, so feedback sent as soon
+// as the answer arrives finds the query.
+func (s *QueryService) persistStage(ctx context.Context, qc *QueryContext) error {
+	req, ragReq, ragResp, verdict := qc.Request, qc.RAGRequest, qc.RAGResponse, qc.Verdict
+
 	// Calculate latency
-	latencyMs := int(time.Since(startTime).Milliseconds())
+	latencyMs := int(time.Since(qc.StartTime).Milliseconds())
+	attachmentBytes, _ := attachmentDigest(req.Attachments)
+	tokensIn, tokensOut := tokenSplit(ragResp, qc.EstimatedTokens)
 
 	// Save to database
-	chatQuery := models.ChatQuery{
+	qc.ChatQuery = models.ChatQuery{
 		SessionID:  req.SessionID,
 		UserID:     req.UserID,
+		VisitorID:  req.VisitorID,
+		Plan:       s.quotas.Plan(req.TokenPlan).Name,
 		Query:      req.Query,
+		QueryHash:  queryHash(req.Query),
 		Response:   ragResp.Response,
 		Context:    formatContext(ragResp.Context),
 		Model:      ragResp.Model,
+		Language:   qc.Language,
 		TokensUsed: ragResp.TokensUsed,
+		TokensIn:   tokensIn,
+		TokensOut:  tokensOut,
 		LatencyMs:  latencyMs,
 		CacheHit:   false,
+
+		EstimatedTokens:      qc.EstimatedTokens,
+		RAGEndpoint:          ragResp.Endpoint,
+		Pipeline:             models.PipelineRAG,
+		RoutingRuleID:        qc.RoutingRuleID,
+		GroundingScore:       verdict.score,
+		UnsupportedCount:     verdict.unsupported,
+		LowConfidence:        verdict.lowConfidence,
+		NoContext:            qc.CalledRAG && len(ragResp.Context) == 0 && !qc.refused() && !qc.Partial,
+		Partial:              qc.Partial,
+		Translated:           qc.Translated,
+		AttachmentCount:      len(req.Attachments),
+		AttachmentBytes:      attachmentBytes,
+		AttachmentHash:       ragReq.AttachmentHash,
+		CacheBypassed:        qc.BypassReason != "",
+		RequestID:            middleware.RequestIDFrom(ctx),
+		ModerationFlag:       qc.Flagged,
+		ModerationCategories: strings.Join(qc.Categories, ","),
+		Metadata:             req.Metadata,
+		Segment:              req.Segment,
+		Synthetic:            req.Synthetic,
+		ParentQueryID:        req.ParentQueryID,
+		PhaseTimings:         ragResp.PhaseTimings,
 	}
+	chatQuery := &qc.ChatQuery
 
-	if err := db.DB.Create(&chatQuery).Error; err != nil {
+	// Answers replaced for low confidence aren't cached, so asking again can
+	// do better, and neither are partial answers or unverified answers while
+	// verification is enforced
+	qc.Cacheable = !qc.Flagged && qc.BypassReason == "" && !verdict.lowConfidence && !qc.Partial &&
+		!(req.SkipVerification && s.cfg.VerificationMode == VerificationEnforce)
+
+	// Remember where the answer is cached so negative feedback can evict it
+	if qc.Cacheable {
+		chatQuery.CacheKey = qc.CacheKey
+	}
+
+	if qc.Pipeline == models.PipelineDirect {
+		chatQuery.Pipeline = models.PipelineDirect
+	}
+	if qc.generated() {
+		chatQuery.ResponseLanguage = ragReq.ResponseLanguage
+		if qc.Persona != nil {
+			chatQuery.PersonaID = &qc.Persona.ID
+			chatQuery.PersonaVersion = qc.Persona.Version
+		}
+	}
+	// Direct answers use neither the prompt template nor the variant
+	if qc.CalledRAG {
+		chatQuery.PromptTemplate = ragReq.PromptTemplate
+		chatQuery.PromptVersion = ragReq.PromptVersion
+		if qc.Assignment != nil {
+			chatQuery.ExperimentID = &qc.Assignment.ExperimentID
+			chatQuery.ExperimentVariant = qc.Assignment.Variant.Name
+		}
+	}
+
+	if err := db.DB.Create(chatQuery).Error; err != nil {
 		logrus.WithError(err).Error("Failed to save query to database")
 		// Don't return error, continue with response
+	} else if !req.Synthetic {
+		s.feed.Publish(activity.QueryEvent(*chatQuery))
 	}
+	s.retainAttachments(chatQuery.ID, req.Attachments)
+	s.quotas.Record(ctx, qc.QuotaSubject, false)
+	qc.FeedbackRequested = s.requestFeedback(chatQuery, verdict.lowConfidence || req.ParentQueryID != nil)
+	if !req.Synthetic {
+		recordSessionActivity(req.SessionID, req.Segment)
+	}
+	return nil
+}
+
+// respondStage builds the response from the saved query
+func (s *QueryService) respondStage(ctx context.Context, qc *QueryContext) error {
+	req, ragReq, ragResp, verdict := qc.Request, qc.RAGRequest, qc.RAGResponse, qc.Verdict
+	refused := qc.refused()
 
-	// Prepare response
 	response := &models.QueryResponse{
-		QueryID:   chatQuery.ID,
+		QueryID:   qc.ChatQuery.ID,
 		SessionID: req.SessionID,
 		Query:     req.Query,
 		Response:  ragResp.Response,
 		Context:   ragResp.Context,
 		Model:     ragResp.Model,
-		Latency:   latencyMs,
+		Language:  qc.Language,
+		Latency:   qc.ChatQuery.LatencyMs,
 		CacheHit:  false,
+		Moderated: refused,
 		Timestamp: time.Now().UTC(),
+
+		GroundingScore: verdict.score,
+		LowConfidence:  verdict.lowConfidence,
+		Translated:     qc.Translated,
+		Pipeline:       qc.ChatQuery.Pipeline,
+
+		FeedbackRequested: qc.FeedbackRequested,
+
+		CacheBypassed:     qc.BypassReason != "",
+		CacheBypassReason: qc.BypassReason,
+		ContextReset:      req.ContextReset,
+		ParentQueryID:     req.ParentQueryID,
+		PhaseTimings:      ragResp.PhaseTimings,
+	}
+	if ragReq.IncludeSuggestions && !refused {
+		response.Suggestions = ragResp.Suggestions
+	}
+	if qc.Partial {
+		response.Partial = true
+		response.PartialNote = s.cfg.PartialResponseNote
+		response.GenerationID = ragReq.GenerationID
+	}
+	if qc.CalledRAG && len(ragResp.Context) == 0 && !refused && !qc.Partial {
+		response.DidYouMean = s.didYouMean.Suggest(req.Query)
 	}
+	qc.Response = response
+	return nil
+}
 
-	// Cache the response
-	cacheTTL := time.Duration(s.cfg.CacheTTL) * time.Second
-	if err := cache.Set(ctx, cacheKey, response, cacheTTL); err != nil {
-		logrus.WithError(err).Warn("Failed to cache response")
+// cacheStoreStage caches the response; flagged and bypassed responses are
+// never cached
+func (s *QueryService) cacheStoreStage(ctx context.Context, qc *QueryContext) error {
+	if qc.Cacheable {
+		if err := s.cacheResponse(ctx, qc.CacheKey, qc.Response, qc.CacheTTL); err != nil {
+			logrus.WithError(err).Warn("Failed to cache response")
+		}
 	}
+	return nil
+}
 
-	return response, nil
+// dispatchStage filters the suggestions for the session and notifies
+// webhooks of the answered query
+func (s *QueryService) dispatchStage(ctx context.Context, qc *QueryContext) error {
+	req := qc.Request
+	qc.Response.Suggestions = s.filterSuggestions(ctx, req.SessionID, req.Query, qc.Response.Suggestions)
+
+	if !req.Synthetic {
+		s.dispatcher.Dispatch(webhook.EventQueryCompleted, qc.Response)
+	}
+	return nil
 }
 
-// callRAGService makes HTTP request to RAG service
-func (s *QueryService) callRAGService(ctx context.Context, req RAGQueryRequest) (*RAGQueryResponse, error) {
-	startTime := time.Now()
-	defer func() {
-		middleware.RecordRAGDuration(time.Since(startTime))
-	}()
+// routeModel sends the query to the model picked by the routing rules,
+// unless an experiment variant or regeneration already picked one, and
+// returns the rule that picked it
+func (s *QueryService) routeModel(ctx context.Context, ragReq *RAGQueryRequest, opening bool) *uint {
+	if ragReq.Model != "" {
+		return nil
+	}
+
+	decision := s.routing.Route(routingInput{
+		query:       ragReq.Query,
+		language:    ragReq.Language,
+		collections: ragReq.Collections,
+		estimate: func() int {
+			total, _ := s.estimatePrompt(ctx, *ragReq, opening, s.tokenCalibration[s.cfg.OpenAIModel])
+			return total
+		},
+	})
+	if decision.Rule == nil {
+		middleware.RecordModelRouting(decision.Model, "default")
+		return nil
+	}
+
+	ragReq.Model = decision.Model
+	middleware.RecordModelRouting(decision.Model, "rule")
+	logrus.WithFields(logrus.Fields{
+		"session_id":      ragReq.SessionID,
+		"routing_rule_id": decision.Rule.ID,
+		"model":           decision.Model,
+	}).Debug("Routed query to model")
+	return &decision.Rule.ID
+}
+
+// ragRequest builds the RAG service request for a query under the given prompt
+// template, experiment variant and persona
+func (s *QueryService) ragRequest(req models.QueryRequest, language string, prompt *models.PromptTemplate, assignment *ExperimentAssignment, persona *models.Persona) RAGQueryRequest {
+	ragReq := RAGQueryRequest{
+		Query:     req.Query,
+		SessionID: req.SessionID,
+		TopK:      defaultTopK,
+		Language:  language,
+
+		Collections: req.Collections,
+		Audience:    req.Audience,
+
+		Segment:      req.Segment,
+		ContextReset: req.ContextReset,
+
+		ResponseLanguage: s.responseLanguage(language),
+		Persona:          ragPersona(persona),
+	}
+
+	if prompt != nil {
+		ragReq.PromptTemplate = prompt.Name
+		ragReq.PromptVersion = prompt.Version
+		if s.cfg.PromptSendBody {
+			ragReq.PromptBody = prompt.Body
+		}
+	}
+
+	if assignment != nil {
+		ragReq.Model = assignment.Variant.Model
+	}
+
+	if req.ParentQueryID != nil {
+		s.perturbForRegeneration(&ragReq, req.RegenerateAttempt)
+	}
+
+	if req.Metadata != nil {
+		ragReq.PageURL = req.Metadata.PageURL
+		ragReq.Locale = req.Metadata.Locale
+	}
+
+	if req.IncludeSuggestions && s.cfg.SuggestionsMax > 0 {
+		ragReq.IncludeSuggestions = true
+		ragReq.MaxSuggestions = s.cfg.SuggestionsMax
+	}
+
+	if len(req.Attachments) > 0 {
+		ragReq.Attachments = ragAttachments(req.Attachments)
+		_, ragReq.AttachmentHash = attachmentDigest(req.Attachments)
+	}
+
+	return ragReq
+}
+
+// cacheBypassReason returns why the cache must be skipped for a request, or "" to use it
+func (s *QueryService) cacheBypassReason(ctx context.Context, req models.QueryRequest) string {
+	switch {
+	case len(req.Attachments) > 0:
+		// The cache key can't safely represent image content
+		return CacheBypassAttachment
+	case req.ParentQueryID != nil:
+		return CacheBypassRegenerate
+	case req.NoCache:
+		return CacheBypassRequest
+	case req.NoCacheHeader:
+		return CacheBypassHeader
+	}
+
+	normalized := normalizeQuery(req.Query)
+	for _, pattern := range s.bypassPatterns {
+		if pattern.MatchString(normalized) {
+			return CacheBypassPattern
+		}
+	}
+
+	if cache.Client != nil {
+		suppressed, err := cache.Exists(ctx, noCacheKey(queryHash(req.Query)))
+		if err != nil {
+			logrus.WithError(err).Debug("Failed to check cache suppression for query")
+		} else if suppressed {
+			return CacheBypassFeedback
+		}
+	}
+
+	return ""
+}
+
+// answerCanned records and returns a canned answer for the query
+func (s *QueryService) answerCanned(req models.QueryRequest, canned *models.CannedAnswer, language string, startTime time.Time) *models.QueryResponse {
+	latencyMs := int(time.Since(startTime).Milliseconds())
+
+	chatQuery := models.ChatQuery{
+		SessionID:  req.SessionID,
+		UserID:     req.UserID,
+		VisitorID:  req.VisitorID,
+		Plan:       s.quotas.Plan(req.TokenPlan).Name,
+		Query:      req.Query,
+		QueryHash:  queryHash(req.Query),
+		Response:   canned.Answer,
+		Context:    formatContext(nil),
+		Model:      CannedModel,
+		Language:   language,
+		TokensUsed: 0,
+		LatencyMs:  latencyMs,
+		CacheHit:   false,
+		Metadata:   req.Metadata,
+		Segment:    req.Segment,
+		Synthetic:  req.Synthetic,
+	}
+
+	if err := db.DB.Create(&chatQuery).Error; err != nil {
+		logrus.WithError(err).Error("Failed to save query to database")
+	} else if !req.Synthetic {
+		s.feed.Publish(activity.QueryEvent(chatQuery))
+	}
+	feedbackRequested := s.requestFeedback(&chatQuery, false)
+	if !req.Synthetic {
+		recordSessionActivity(req.SessionID, req.Segment)
+	}
+
+	if canned.AnnotationID != nil {
+		logrus.WithFields(logrus.Fields{
+			"annotation_id": *canned.AnnotationID,
+			"query_id":      chatQuery.ID,
+		}).Info("Answered query with approved annotation")
+	} else {
+		logrus.WithFields(logrus.Fields{
+			"canned_answer_id": canned.ID,
+			"query_id":         chatQuery.ID,
+		}).Info("Answered query with canned answer")
+	}
+
+	response := &models.QueryResponse{
+		QueryID:   chatQuery.ID,
+		SessionID: req.SessionID,
+		Query:     req.Query,
+		Response:  canned.Answer,
+		Model:     CannedModel,
+		Language:  language,
+		Latency:   latencyMs,
+		CacheHit:  false,
+		Timestamp: time.Now().UTC(),
 
-	url := fmt.Sprintf("%s/rag/query", s.cfg.RAGServiceURL)
+		FeedbackRequested: feedbackRequested,
+		ContextReset:      req.ContextReset,
+	}
 
-	jsonData, err := json.Marshal(req)
+	if !req.Synthetic {
+		s.dispatcher.Dispatch(webhook.EventQueryCompleted, response)
+	}
+	return response
+}
+
+// moderate checks text against the moderation endpoint and records any flags.
+// Moderation errors are logged and treated as not flagged.
+func (s *QueryService) moderate(ctx context.Context, stage, text string) (bool, []string) {
+	mode := s.settings.ModerationMode()
+	if mode == moderation.ModeOff {
+		return false, nil
+	}
+
+	result, err := s.moderator.Check(ctx, text)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		logrus.WithError(err).WithField("stage", stage).Warn("Moderation check failed, allowing content")
+		return false, nil
+	}
+
+	if !result.Flagged {
+		return false, nil
+	}
+
+	if len(result.Categories) == 0 {
+		middleware.RecordModerationFlag(stage, "unspecified")
 	}
+	for _, category := range result.Categories {
+		middleware.RecordModerationFlag(stage, category)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"stage":      stage,
+		"categories": result.Categories,
+		"mode":       mode,
+	}).Warn("Content flagged by moderation")
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	return true, result.Categories
+}
+
+// coalescedRAGCall calls the RAG service, sharing a single in-flight call between
+// concurrent callers asking the same normalized question. Each caller gets its own copy.
+// A timeout is returned as a GenerationTimeoutError naming the generation
+// of the shared call.
+func (s *QueryService) coalescedRAGCall(ctx context.Context, req RAGQueryRequest) (*RAGQueryResponse, error) {
+	timeout := time.Duration(s.cfg.QueryCoalesceTimeoutS) * time.Second
+	key := inflightKey(req)
+
+	// The shared call keeps the first caller's deadline, so a request timeout
+	// cancels the upstream call, but not its cancellation: it must survive the
+	// first caller disconnecting
+	callTimeout := timeout
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < callTimeout {
+		callTimeout = time.Until(deadline)
+	}
+
+	ch := s.inflight.DoChan(key, func() (interface{}, error) {
+		callCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), callTimeout)
+		defer cancel()
+
+		s.generations.Store(key, req.GenerationID)
+		defer s.generations.Delete(key)
+
+		resp, err := s.distributedRAGCall(callCtx, key, req)
+		if err != nil {
+			return nil, generationTimeout(err, req.GenerationID)
+		}
+		s.postProcess(callCtx, resp)
+		return resp, nil
+	})
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case result := <-ch:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		if result.Shared {
+			middleware.RecordCoalescedQuery()
+		}
+		return copyRAGResponse(result.Val.(*RAGQueryResponse)), nil
+	case <-ctx.Done():
+		return nil, generationTimeout(ctx.Err(), s.inflightGeneration(key, req))
+	case <-timer.C:
+		return nil, generationTimeout(fmt.Errorf("%w: waiting for in-flight RAG call", ErrTimeout), s.inflightGeneration(key, req))
+	}
+}
+
+// inflightKey identifies the RAG calls that can share an answer
+func inflightKey(req RAGQueryRequest) string {
+	return cache.GenerateCacheKey("inflight", normalizeQuery(req.Query), strings.Join(req.Collections, ","), req.Audience, fmt.Sprintf("%s:%d", req.PromptTemplate, req.PromptVersion), req.Model, fmt.Sprint(req.IncludeSuggestions), strconv.Itoa(req.TopK), temperatureKey(req.Temperature), req.AttachmentHash, req.ResponseLanguage, personaKey(req.Persona))
+}
+
+// inflightGeneration returns the generation ID of the in-flight call a
+// caller is waiting on, which is another caller's when the call is shared
+func (s *QueryService) inflightGeneration(key string, req RAGQueryRequest) string {
+	if generationID, ok := s.generations.Load(key); ok {
+		return generationID.(string)
+	}
+	return req.GenerationID
+}
+
+// distributedRAGCall optionally coordinates through a Redis lock so only one instance
+// calls the RAG service for a query; the others poll for its published result
+func (s *QueryService) distributedRAGCall(ctx context.Context, key string, req RAGQueryRequest) (*RAGQueryResponse, error) {
+	if !s.cfg.QueryCoalesceDistributed || cache.Client == nil {
+		return s.callRAGService(ctx, req)
+	}
+
+	// The lock holds the generation ID, so a wait that times out names the
+	// generation it waited on
+	lockKey := key + ":lock"
+	token := req.GenerationID
+	if token == "" {
+		token = fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	timeout := time.Duration(s.cfg.QueryCoalesceTimeoutS) * time.Second
+
+	acquired, err := cache.SetNX(ctx, lockKey, token, timeout)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		logrus.WithError(err).Debug("Failed to acquire in-flight lock, calling RAG service directly")
+		return s.callRAGService(ctx, req)
 	}
 
-	httpReq.Header.Set("Content-Type", "application/json")
+	if acquired {
+		resp, err := s.callRAGService(ctx, req)
+		if err == nil {
+			if err := cache.Set(ctx, key+":result:"+token, resp, 30*time.Second); err != nil {
+				logrus.WithError(err).Debug("Failed to publish in-flight result")
+			}
+		}
+		cache.Delete(ctx, lockKey)
+		return resp, err
+	}
 
-	client := &http.Client{
-		Timeout: 60 * time.Second,
+	// Another instance is answering; wait for its result
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+
+	var leaderToken string
+	for {
+		select {
+		case <-ctx.Done():
+			err := fmt.Errorf("%w: waiting for in-flight RAG call on another instance", ErrTimeout)
+			if generationIDPattern.MatchString(leaderToken) {
+				return nil, generationTimeout(err, leaderToken)
+			}
+			return nil, err
+		case <-ticker.C:
+		}
+
+		lockErr := cache.Get(ctx, lockKey, &leaderToken)
+		if lockErr != nil && lockErr != redis.Nil {
+			return s.callRAGService(ctx, req)
+		}
+
+		if leaderToken != "" {
+			var resp RAGQueryResponse
+			if err := cache.Get(ctx, key+":result:"+leaderToken, &resp); err == nil {
+				return &resp, nil
+			}
+		}
+
+		// The lock is gone without a published result: the leader failed
+		if lockErr == redis.Nil {
+			break
+		}
 	}
 
-	resp, err := client.Do(httpReq)
+	// Leader finished or died without us seeing its result; answer ourselves
+	return s.callRAGService(ctx, req)
+}
+
+// copyRAGResponse returns a copy so callers can modify their response independently
+func copyRAGResponse(resp *RAGQueryResponse) *RAGQueryResponse {
+	clone := *resp
+	clone.Context = append([]string(nil), resp.Context...)
+	clone.Sources = append([]RAGSource(nil), resp.Sources...)
+	clone.Suggestions = append([]string(nil), resp.Suggestions...)
+	return &clone
+}
+
+// callRAGService calls the RAG service over the configured transport, once
+// the limiter grants a slot, retrying upstream rate limits and failing over
+// to the fallback endpoint while holding it. Cached and canned answers never
+// get here.
+func (s *QueryService) callRAGService(ctx context.Context, req RAGQueryRequest) (*RAGQueryResponse, error) {
+	release, err := s.limiter.Acquire(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to call RAG service: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
+	defer release()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("RAG service returned status %d: %s", resp.StatusCode, string(body))
+	startTime := time.Now()
+	defer func() {
+		middleware.RecordRAGDuration(ctx, time.Since(startTime))
+	}()
+
+	resp, err := s.queryWithFailover(ctx, req)
+	if err != nil {
+		return nil, err
 	}
+	middleware.RecordRAGPhases(resp.RetrievalMs, resp.GenerationMs, resp.TimeToFirstTokenMs)
+	return resp, nil
+}
 
-	var ragResp RAGQueryResponse
-	if err := json.NewDecoder(resp.Body).Decode(&ragResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+// compilePatterns compiles regex patterns, skipping invalid ones
+func compilePatterns(patterns []string) []*regexp.Regexp {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			logrus.WithError(err).WithField("pattern", pattern).Warn("Ignoring invalid regex pattern")
+			continue
+		}
+		compiled = append(compiled, re)
 	}
+	return compiled
+}
 
-	return &ragResp, nil
+// tokenSplit returns the prompt and completion tokens of an answer. When the
+// RAG service only reports the total, the prompt estimate made before the
+// call stands in for the prompt tokens, so the two still add up to the total.
+func tokenSplit(resp *RAGQueryResponse, estimated int) (int, int) {
+	if resp.PromptTokens > 0 || resp.CompletionTokens > 0 {
+		return resp.PromptTokens, resp.CompletionTokens
+	}
+	in := min(max(estimated, 0), resp.TokensUsed)
+	return in, resp.TokensUsed - in
 }
 
 // formatContext converts context array to JSON string
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+)
+
+// tokenSinkKey carries a streamed query's tokenSink in the context of its
+// RAG call, down through the limiter, failover and rate limit retries
+type tokenSinkKey struct{}
+
+// tokenSink passes the tokens of one RAG call on to the caller, noting
+// whether any were sent
+type tokenSink struct {
+	onToken func(string) error
+	sent    atomic.Bool
+}
+
+func (s *tokenSink) send(token string) error {
+	if token == "" {
+		return nil
+	}
+	s.sent.Store(true)
+	return s.onToken(token)
+}
+
+// ProcessQueryStream processes a query like ProcessQuery, passing the tokens
+// of an answer generated by the RAG service to onToken as they arrive. Cached,
+// canned and direct answers, and any answer while moderation is enforced, come
+// back whole without tokens. The response is the final answer after
+// moderation, verification, post-processing and translation, and replaces
+// the streamed text.
+func (s *QueryService) ProcessQueryStream(ctx context.Context, req models.QueryRequest, onToken func(token string) error) (*models.QueryResponse, error) {
+	return s.processQuery(ctx, &QueryContext{Request: req, StartTime: time.Now(), OnToken: onToken})
+}
+
+// streamedRAGCall calls the RAG service for a single caller, passing the
+// answer's tokens to onToken. A stream belongs to its caller, so unlike
+// coalescedRAGCall it is never shared with others asking the same question.
+func (s *QueryService) streamedRAGCall(ctx context.Context, req RAGQueryRequest, onToken func(string) error) (*RAGQueryResponse, error) {
+	sink := &tokenSink{onToken: onToken}
+	resp, err := s.callRAGService(context.WithValue(ctx, tokenSinkKey{}, sink), req)
+	if err != nil {
+		return nil, generationTimeout(err, req.GenerationID)
+	}
+	s.postProcess(ctx, resp)
+	return resp, nil
+}
+
+// queryTransport asks transport for an answer, streaming it to the call's
+// tokenSink if it has one
+func queryTransport(ctx context.Context, transport RAGTransport, req RAGQueryRequest) (*RAGQueryResponse, error) {
+	sink, ok := ctx.Value(tokenSinkKey{}).(*tokenSink)
+	if !ok {
+		return transport.Query(ctx, req)
+	}
+	return transport.QueryStream(ctx, req, sink.send)
+}
+
+// streamStarted reports whether the call's tokenSink has sent any tokens,
+// after which the call can't be retried elsewhere without repeating them
+func streamStarted(ctx context.Context) bool {
+	sink, ok := ctx.Value(tokenSinkKey{}).(*tokenSink)
+	return ok && sink.sent.Load()
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/postprocess"
+)
+
+// newStreamTestService calls the primary gRPC scenario and fails over to the
+// fallback one
+func newStreamTestService(t *testing.T, primary, fallback *ragScenario) *QueryService {
+	t.Helper()
+	cfg := &config.Config{RAGRateLimitWindowS: 60}
+	primaryTransport := newTestGRPCTransport(t, &grpcScenarioServer{scenario: primary})
+	fallbackTransport := newTestGRPCTransport(t, &grpcScenarioServer{scenario: fallback})
+	return &QueryService{
+		cfg:       cfg,
+		health:    NewHealthService(cfg, primaryTransport, fallbackTransport, nil, nil),
+		transport: primaryTransport,
+		fallback:  fallbackTransport,
+		pipeline:  postprocess.NewPipeline(),
+	}
+}
+
+func TestStreamedRAGCall(t *testing.T) {
+	answer := &RAGQueryResponse{Response: "Use the reset link.", Context: []string{}, Model: "m1"}
+	fallbackAnswer := &RAGQueryResponse{Response: "Reset it from settings.", Context: []string{}, Model: "m2"}
+	tokens := []string{"Use the ", "reset link."}
+
+	tests := []struct {
+		name       string
+		fail       string
+		wantTokens string
+		wantAnswer string
+		wantErr    error
+	}{
+		{"primary answers", "", "Use the reset link.", "Use the reset link.", nil},
+		// Nothing was streamed yet, so the fallback can answer
+		{"primary down", "unavailable", "Reset it from settings.", "Reset it from settings.", nil},
+		// Retrying would repeat the tokens already sent
+		{"primary breaks mid-stream", "broken", "Use the reset link.", "", ErrRAGUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := &ragScenario{answer: answer, tokens: tokens, fail: tt.fail}
+			fallback := &ragScenario{answer: fallbackAnswer, tokens: []string{"Reset it ", "from settings."}}
+			s := newStreamTestService(t, primary, fallback)
+
+			var streamed strings.Builder
+			resp, err := s.streamedRAGCall(context.Background(), RAGQueryRequest{Query: "How do I reset my password?"}, func(token string) error {
+				streamed.WriteString(token)
+				return nil
+			})
+			if streamed.String() != tt.wantTokens {
+				t.Errorf("streamed %q, want %q", streamed.String(), tt.wantTokens)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) || fallback.lastRequest() != nil {
+					t.Errorf("error = %v, fallback asked = %t; want %v without failover", err, fallback.lastRequest() != nil, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("streamedRAGCall: %v", err)
+			}
+			if resp.Response != tt.wantAnswer {
+				t.Errorf("response = %q, want %q", resp.Response, tt.wantAnswer)
+			}
+		})
+	}
+}
+
+func TestQueryTransportWithoutSink(t *testing.T) {
+	scenario := &ragScenario{answer: &RAGQueryResponse{Response: "Use the reset link.", Context: []string{}}, tokens: []string{"Use the ", "reset link."}}
+	transport := newTestGRPCTransport(t, &grpcScenarioServer{scenario: scenario})
+
+	resp, err := queryTransport(context.Background(), transport, RAGQueryRequest{Query: "hi"})
+	if err != nil || resp.Response != "Use the reset link." {
+		t.Errorf("queryTransport = %+v, %v; want the unary answer", resp, err)
+	}
+	if streamStarted(context.Background()) {
+		t.Error("a call without a sink reports a started stream")
+	}
+}
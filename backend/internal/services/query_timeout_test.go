@@ -0,0 +1,94 @@
+package services_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/handlers"
+	"github.com/ai-support-assistant/backend/internal/middleware"
+	"github.com/ai-support-assistant/backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// slowRAGService answers queries mentioning "slow" only once they are
+// cancelled, reporting the cancellation, and others at once. It keeps no
+// generations to recover a partial answer from.
+type slowRAGService struct {
+	cancelled chan time.Time
+}
+
+func (s *slowRAGService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/health":
+		w.WriteHeader(http.StatusOK)
+		return
+	case "/rag/query":
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	var req services.RAGQueryRequest
+	json.NewDecoder(r.Body).Decode(&req)
+	if strings.Contains(req.Query, "slow") {
+		<-r.Context().Done()
+		s.cancelled <- time.Now()
+		return
+	}
+	json.NewEncoder(w).Encode(services.RAGQueryResponse{Response: "Right away.", Context: []string{"ctx"}, Model: "m1"})
+}
+
+func TestQueryTimeoutCancelsRAGCall(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rag := &slowRAGService{cancelled: make(chan time.Time, 1)}
+	queryService, _ := services.NewPipelineTestService(t, rag, nil)
+
+	router := gin.New()
+	router.POST("/api/query", middleware.Timeout(100*time.Millisecond), handlers.NewQueryHandler(queryService, nil, nil).HandleQuery)
+	ask := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/query", strings.NewReader(`{"query":"`+query+`","session_id":"s1"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// A query in time opens the connections later queries reuse
+	if w := ask("How do I reset my password?"); w.Code != http.StatusOK {
+		t.Fatalf("fast query = %d %s, want 200", w.Code, w.Body.String())
+	}
+	baseline := runtime.NumGoroutine()
+
+	start := time.Now()
+	w := ask("Why is this so slow?")
+	if w.Code != http.StatusGatewayTimeout || !strings.Contains(w.Body.String(), `"timeout"`) {
+		t.Errorf("slow query = %d %s, want a 504 timeout", w.Code, w.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("slow query took %v, want it cut off at the deadline", elapsed)
+	}
+
+	// The deadline reached the RAG call, which was cancelled with it
+	select {
+	case at := <-rag.cancelled:
+		if waited := at.Sub(start); waited > 2*time.Second {
+			t.Errorf("RAG call cancelled after %v, want it at the deadline", waited)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("RAG call never cancelled")
+	}
+
+	// Nothing started for the slow query outlives it
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > baseline {
+		buf := make([]byte, 1<<20)
+		t.Errorf("%d goroutines after the timeout, %d before:\n%s", n, baseline, buf[:runtime.Stack(buf, true)])
+	}
+}
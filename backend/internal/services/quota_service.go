@@ -0,0 +1,272 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/audit"
+	"github.com/ai-support-assistant/backend/internal/cache"
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// quotaCounterTTL keeps a day's counter past the end of the day so a usage
+// lookup right after midnight doesn't race its expiry
+const quotaCounterTTL = 48 * time.Hour
+
+// QuotaExceededError is returned instead of answering a query once the
+// caller has used up its plan's daily queries
+type QuotaExceededError struct {
+	Usage models.QuotaUsage
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("plan %s allows %d queries a day, %d used", e.Usage.Plan, e.Usage.Limit, e.Usage.Used)
+}
+
+func (e *QuotaExceededError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// QuotaSubject identifies who a quota is counted against: the token's user,
+// or the visitor fingerprint for anonymous callers. Empty when neither is
+// known, in which case no quota applies.
+func QuotaSubject(userID, visitorID string) string {
+	switch {
+	case userID != "":
+		return "user:" + userID
+	case visitorID != "":
+		return "visitor:" + visitorID
+	default:
+		return ""
+	}
+}
+
+// QuotaService enforces plan tiers: daily query quotas counted in Redis, and
+// per-plan upload and streaming limits. Admins may temporarily override a
+// user's daily quota.
+type QuotaService struct {
+	cfg   *config.Config
+	plans map[string]config.Plan
+}
+
+func NewQuotaService(cfg *config.Config) *QuotaService {
+	// Validated when the config was loaded
+	plans, _ := config.ParsePlans(cfg.Plans)
+
+	return &QuotaService{cfg: cfg, plans: plans}
+}
+
+// Enabled reports whether plan tiers are configured
+func (s *QuotaService) Enabled() bool {
+	return len(s.plans) > 0
+}
+
+// Plan returns the plan a token names, or the default plan. Without plans
+// configured every caller is unlimited.
+func (s *QuotaService) Plan(name string) config.Plan {
+	if plan, ok := s.plans[name]; ok {
+		return plan
+	}
+	if plan, ok := s.plans[s.cfg.DefaultPlan]; ok {
+		return plan
+	}
+	return config.Plan{Name: "unlimited", Streaming: true}
+}
+
+// Check returns a QuotaExceededError if the subject has used up its daily
+// queries. Quotas fail open when Redis is unavailable.
+func (s *QuotaService) Check(ctx context.Context, subject, userID, planName string) error {
+	if !s.Enabled() || subject == "" {
+		return nil
+	}
+
+	usage, err := s.usage(ctx, subject, userID, planName)
+	if err != nil {
+		logrus.WithError(err).WithField("subject", subject).Warn("Failed to check quota, allowing query")
+		return nil
+	}
+	if usage.Remaining != nil && *usage.Remaining <= 0 {
+		return &QuotaExceededError{Usage: *usage}
+	}
+	return nil
+}
+
+// Record counts a query against the subject's daily quota
+func (s *QuotaService) Record(ctx context.Context, subject string, cached bool) {
+	if !s.Enabled() || subject == "" || (cached && !s.cfg.QuotaCountCached) {
+		return
+	}
+
+	key := quotaKey(subject, time.Now())
+	count, err := cache.Increment(ctx, key)
+	if err != nil {
+		logrus.WithError(err).WithField("subject", subject).Warn("Failed to count query against quota")
+		return
+	}
+	if count == 1 {
+		cache.Expire(ctx, key, quotaCounterTTL)
+	}
+}
+
+// GetUsage reports the caller's plan, its daily quota usage and the tokens
+// the user's queries used today
+func (s *QuotaService) GetUsage(ctx context.Context, userID, visitorID, planName string) (*models.QuotaUsage, error) {
+	usage, err := s.usage(ctx, QuotaSubject(userID, visitorID), userID, planName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quota usage: %w", err)
+	}
+
+	if userID != "" {
+		start := time.Now().UTC().Truncate(24 * time.Hour)
+		err := db.DB.WithContext(ctx).Model(&models.ChatQuery{}).
+			Where("user_id = ? AND created_at >= ?", userID, start).
+			Select("COALESCE(SUM(tokens_used), 0)").
+			Scan(&usage.TokensUsed).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to sum tokens used: %w", err)
+		}
+	}
+
+	return usage, nil
+}
+
+// usage reports the subject's plan and quota usage today, applying any
+// active override for the user
+func (s *QuotaService) usage(ctx context.Context, subject, userID, planName string) (*models.QuotaUsage, error) {
+	now := time.Now().UTC()
+	plan := s.Plan(planName)
+	usage := &models.QuotaUsage{
+		Plan:           plan.Name,
+		Limit:          plan.DailyQueries,
+		ResetAt:        now.Truncate(24 * time.Hour).Add(24 * time.Hour),
+		MaxUploadBytes: plan.MaxUploadBytes,
+		Streaming:      plan.Streaming,
+	}
+	if !s.Enabled() {
+		return usage, nil
+	}
+
+	if userID != "" {
+		override, err := activeOverride(ctx, userID, now)
+		if err != nil {
+			return nil, err
+		}
+		if override != nil {
+			usage.Override = override
+			usage.Limit = override.DailyQueries
+		}
+	}
+
+	if subject != "" {
+		var used int64
+		err := cache.Get(ctx, quotaKey(subject, now), &used)
+		if err != nil && err != redis.Nil {
+			return nil, fmt.Errorf("failed to get quota counter: %w", err)
+		}
+		usage.Used = used
+	}
+
+	if usage.Limit > 0 {
+		remaining := int64(usage.Limit) - usage.Used
+		if remaining < 0 {
+			remaining = 0
+		}
+		usage.Remaining = &remaining
+	}
+	return usage, nil
+}
+
+// activeOverride returns the user's unexpired quota override, if any
+func activeOverride(ctx context.Context, userID string, now time.Time) (*models.QuotaOverride, error) {
+	var override models.QuotaOverride
+	err := db.DB.WithContext(ctx).Where("user_id = ? AND expires_at > ?", userID, now).First(&override).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quota override: %w", err)
+	}
+	return &override, nil
+}
+
+// quotaKey is the Redis counter of a subject's queries on the UTC day of t
+func quotaKey(subject string, t time.Time) string {
+	return fmt.Sprintf("quota:%s:%s", subject, t.UTC().Format("2006-01-02"))
+}
+
+// GetOverrides returns the unexpired quota overrides, soonest to expire first
+func (s *QuotaService) GetOverrides(ctx context.Context) ([]models.QuotaOverride, error) {
+	overrides := []models.QuotaOverride{}
+	err := db.DB.WithContext(ctx).Where("expires_at > ?", time.Now().UTC()).Order("expires_at").Find(&overrides).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quota overrides: %w", err)
+	}
+	return overrides, nil
+}
+
+// SetOverride replaces a user's daily query limit until the override expires
+func (s *QuotaService) SetOverride(ctx context.Context, userID string, req models.QuotaOverrideRequest) (*models.QuotaOverride, error) {
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return nil, validationError("user_id must not be empty")
+	}
+	if !req.ExpiresAt.After(time.Now()) {
+		return nil, validationError("expires_at must be in the future")
+	}
+
+	var override models.QuotaOverride
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before interface{}
+		err := tx.Where("user_id = ?", userID).First(&override).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			override = models.QuotaOverride{UserID: userID}
+		case err != nil:
+			return fmt.Errorf("failed to get quota override: %w", err)
+		default:
+			before = override
+		}
+
+		override.DailyQueries = *req.DailyQueries
+		override.ExpiresAt = req.ExpiresAt.UTC()
+		override.Reason = strings.TrimSpace(req.Reason)
+		override.CreatedBy = audit.ActorFrom(ctx).UserID
+		if err := tx.Save(&override).Error; err != nil {
+			return fmt.Errorf("failed to save quota override: %w", err)
+		}
+		return audit.Record(ctx, tx, "quota_override.set", "quota_override", userID, before, override)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"user_id":       userID,
+		"daily_queries": override.DailyQueries,
+		"expires_at":    override.ExpiresAt,
+	}).Info("Quota override set")
+
+	return &override, nil
+}
+
+// DeleteOverride returns a user to their plan's daily query limit
+func (s *QuotaService) DeleteOverride(ctx context.Context, userID string) error {
+	return db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var override models.QuotaOverride
+		if err := tx.Where("user_id = ?", userID).First(&override).Error; err != nil {
+			return notFoundError("quota override", err)
+		}
+		if err := tx.Delete(&override).Error; err != nil {
+			return fmt.Errorf("failed to delete quota override: %w", err)
+		}
+		return audit.Record(ctx, tx, "quota_override.delete", "quota_override", userID, override, nil)
+	})
+}
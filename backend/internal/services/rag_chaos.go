@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+
+	"github.com/ai-support-assistant/backend/internal/chaos"
+)
+
+// chaosRAGTransport injects rag faults ahead of each call to the RAG service.
+// Injected failures are classified like real ones, so they open breakers and
+// trigger failover the same way.
+type chaosRAGTransport struct {
+	RAGTransport
+	injector *chaos.Injector
+}
+
+// NewChaosRAGTransport wraps a transport with the chaos injector. It returns
+// the transport unchanged when chaos mode is off or there is no transport.
+func NewChaosRAGTransport(transport RAGTransport, injector *chaos.Injector) RAGTransport {
+	if transport == nil || injector == nil {
+		return transport
+	}
+	return &chaosRAGTransport{RAGTransport: transport, injector: injector}
+}
+
+func (t *chaosRAGTransport) inject(ctx context.Context) error {
+	if err := t.injector.Inject(ctx, chaos.TargetRAG); err != nil {
+		return transportError(ctx, err)
+	}
+	return nil
+}
+
+func (t *chaosRAGTransport) Query(ctx context.Context, req RAGQueryRequest) (*RAGQueryResponse, error) {
+	if err := t.inject(ctx); err != nil {
+		return nil, err
+	}
+	return t.RAGTransport.Query(ctx, req)
+}
+
+func (t *chaosRAGTransport) QueryStream(ctx context.Context, req RAGQueryRequest, onToken func(string) error) (*RAGQueryResponse, error) {
+	if err := t.inject(ctx); err != nil {
+		return nil, err
+	}
+	return t.RAGTransport.QueryStream(ctx, req, onToken)
+}
+
+func (t *chaosRAGTransport) Ingest(ctx context.Context, doc RAGIngestRequest) (*RAGIngestResponse, error) {
+	if err := t.inject(ctx); err != nil {
+		return nil, err
+	}
+	return t.RAGTransport.Ingest(ctx, doc)
+}
+
+func (t *chaosRAGTransport) IngestProgress(ctx context.Context, jobID string) (*RAGIngestProgress, error) {
+	if err := t.inject(ctx); err != nil {
+		return nil, err
+	}
+	return t.RAGTransport.IngestProgress(ctx, jobID)
+}
+
+func (t *chaosRAGTransport) DeleteDocument(ctx context.Context, vectorStoreID string) error {
+	if err := t.inject(ctx); err != nil {
+		return err
+	}
+	return t.RAGTransport.DeleteDocument(ctx, vectorStoreID)
+}
+
+func (t *chaosRAGTransport) UpdateDocument(ctx context.Context, vectorStoreID string, fields map[string]string) error {
+	if err := t.inject(ctx); err != nil {
+		return err
+	}
+	return t.RAGTransport.UpdateDocument(ctx, vectorStoreID, fields)
+}
+
+func (t *chaosRAGTransport) Generation(ctx context.Context, generationID string) (*RAGGeneration, error) {
+	if err := t.inject(ctx); err != nil {
+		return nil, err
+	}
+	return t.RAGTransport.Generation(ctx, generationID)
+}
+
+func (t *chaosRAGTransport) Check(ctx context.Context) error {
+	if err := t.inject(ctx); err != nil {
+		return err
+	}
+	return t.RAGTransport.Check(ctx)
+}
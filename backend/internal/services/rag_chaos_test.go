@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ai-support-assistant/backend/internal/chaos"
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/models"
+)
+
+// healthyRAGServer answers the RAG service's health check
+func healthyRAGServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// An injected RAG error fault fails probes like an outage would, opening the
+// breaker and putting the service in degraded mode until it is removed
+func TestChaosRAGErrorFaultOpensBreaker(t *testing.T) {
+	tests := []struct {
+		name     string
+		fallback bool
+		mode     string
+	}{
+		{"no fallback", false, ModeDegraded},
+		{"healthy fallback", true, ModeNormal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeDB(t, nil)
+			useFakeRedis(t)
+			injector, err := chaos.NewInjector("staging")
+			if err != nil {
+				t.Fatalf("NewInjector: %v", err)
+			}
+			primary := NewChaosRAGTransport(newHTTPRAGTransport(healthyRAGServer(t).URL), injector)
+			var fallback RAGTransport
+			if tt.fallback {
+				fallback = newHTTPRAGTransport(healthyRAGServer(t).URL)
+			}
+			cfg := &config.Config{RAGProbeIntervalS: 10, RAGProbeFailureThreshold: 3}
+			s := NewHealthService(cfg, primary, fallback, nil, injector)
+			ctx := context.Background()
+
+			fault, err := injector.Add(chaos.FaultRequest{Target: chaos.TargetRAG, Type: chaos.TypeError, Probability: 1, DurationS: 60})
+			if err != nil {
+				t.Fatalf("Add: %v", err)
+			}
+			if _, err := primary.Query(ctx, RAGQueryRequest{Query: "reset password"}); !errors.Is(err, ErrRAGUnavailable) || !strings.Contains(err.Error(), chaos.ErrInjected.Error()) {
+				t.Errorf("query error = %v, want the injected fault as ErrRAGUnavailable", err)
+			}
+
+			for i := 1; i < cfg.RAGProbeFailureThreshold; i++ {
+				s.probe(ctx)
+			}
+			if !s.PrimaryAvailable() {
+				t.Fatal("breaker opened before the failure threshold")
+			}
+			s.probe(ctx)
+			if s.PrimaryAvailable() {
+				t.Fatal("breaker still closed after the failure threshold")
+			}
+			breaker := s.Breakers()[0]
+			if breaker.State != models.BreakerOpen || breaker.ConsecutiveFailures != cfg.RAGProbeFailureThreshold {
+				t.Errorf("breaker = %+v, want it open after %d failures", breaker, cfg.RAGProbeFailureThreshold)
+			}
+			if s.Mode() != tt.mode {
+				t.Errorf("mode = %q, want %q", s.Mode(), tt.mode)
+			}
+
+			health := s.Check(ctx)
+			if health.Status != "degraded" || health.Mode != tt.mode || !strings.HasPrefix(health.RAGService, "unhealthy") {
+				t.Errorf("health = %s, mode %s, rag %q; want degraded with the RAG service unhealthy", health.Status, health.Mode, health.RAGService)
+			}
+			if health.Database != "healthy" || health.Redis != "healthy" {
+				t.Errorf("health = database %q, redis %q; want only the RAG service failing", health.Database, health.Redis)
+			}
+
+			// The first probe after the fault is removed closes the breaker
+			injector.Remove(fault.ID)
+			s.probe(ctx)
+			if !s.PrimaryAvailable() || s.Mode() != ModeNormal {
+				t.Errorf("after removing the fault: primary available = %t, mode %q", s.PrimaryAvailable(), s.Mode())
+			}
+			if health := s.Check(ctx); health.Status != "healthy" {
+				t.Errorf("health after removing the fault = %s, want healthy", health.Status)
+			}
+		})
+	}
+}
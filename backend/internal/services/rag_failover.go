@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ai-support-assistant/backend/internal/middleware"
+	"github.com/sirupsen/logrus"
+)
+
+// queryWithFailover answers a query from the primary RAG endpoint, or from
+// the fallback while the primary's breaker is open. A primary call that fails
+// with a connection error, timeout or 5xx is retried once against the
+// fallback if the request deadline hasn't passed and it hadn't started
+// streaming; other failures, such as a 4xx, are returned as they are.
+func (s *QueryService) queryWithFailover(ctx context.Context, req RAGQueryRequest) (*RAGQueryResponse, error) {
+	if s.fallback == nil {
+		return s.queryEndpoint(ctx, s.transport, RAGEndpointPrimary, req)
+	}
+
+	if !s.health.PrimaryAvailable() && s.health.FallbackAvailable() {
+		middleware.RecordRAGFailover("breaker_open")
+		return s.queryEndpoint(ctx, s.fallback, RAGEndpointFallback, req)
+	}
+
+	resp, err := s.queryEndpoint(ctx, s.transport, RAGEndpointPrimary, req)
+	if err == nil || !shouldFailover(err) || ctx.Err() != nil || streamStarted(ctx) || !s.health.FallbackAvailable() {
+		return resp, err
+	}
+
+	middleware.RecordRAGFailover("error")
+	logrus.WithError(err).WithField("session_id", req.SessionID).Warn("Primary RAG endpoint failed, retrying against the fallback")
+
+	resp, fallbackErr := s.queryEndpoint(ctx, s.fallback, RAGEndpointFallback, req)
+	if fallbackErr != nil {
+		logrus.WithError(fallbackErr).Warn("Fallback RAG endpoint failed too")
+		return nil, fallbackErr
+	}
+	return resp, nil
+}
+
+// queryEndpoint calls one RAG endpoint and tags the response with it
+func (s *QueryService) queryEndpoint(ctx context.Context, transport RAGTransport, endpoint string, req RAGQueryRequest) (*RAGQueryResponse, error) {
+	resp, err := s.queryWithRateLimitRetry(ctx, transport, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Endpoint = endpoint
+	return resp, nil
+}
+
+// shouldFailover reports whether a failed call may succeed against another
+// deployment: connection errors, timeouts and 5xx responses. Bad requests and
+// rate limits would fail the same way.
+func shouldFailover(err error) bool {
+	return errors.Is(err, ErrRAGUnavailable) || errors.Is(err, ErrTimeout)
+}
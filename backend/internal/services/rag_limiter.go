@@ -0,0 +1,186 @@
+package services
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/middleware"
+	"github.com/ai-support-assistant/backend/internal/models"
+)
+
+// RAGLimiter bounds the number of concurrent RAG generations. Callers past the
+// limit wait in FIFO order; a caller is shed with a BusyError, instead of
+// queueing, when the queue is full or its estimated wait exceeds the budget.
+type RAGLimiter struct {
+	limit    int
+	maxQueue int
+	maxWait  time.Duration
+
+	mu       sync.Mutex
+	inFlight int
+	queue    list.List // of *ragWaiter
+	shed     int64
+
+	// avgLatency is a moving average of how long a slot is held, used to
+	// estimate how long a new waiter would queue
+	avgLatency time.Duration
+}
+
+// ragWaiter is a queued caller; ready is closed once a slot is handed to it
+type ragWaiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+func NewRAGLimiter(cfg *config.Config) *RAGLimiter {
+	return &RAGLimiter{
+		limit:    cfg.RAGMaxInFlight,
+		maxQueue: cfg.RAGQueueMaxLength,
+		maxWait:  time.Duration(cfg.RAGQueueMaxWaitS) * time.Second,
+	}
+}
+
+// Acquire waits for a slot, returning a function that frees it once the RAG
+// call is done
+func (l *RAGLimiter) Acquire(ctx context.Context) (func(), error) {
+	if l == nil || l.limit <= 0 {
+		return func() {}, nil
+	}
+
+	l.mu.Lock()
+	if l.inFlight < l.limit && l.queue.Len() == 0 {
+		l.inFlight++
+		l.updateGauges()
+		l.mu.Unlock()
+		return l.releaser(), nil
+	}
+
+	depth := l.queue.Len()
+	if depth >= l.maxQueue {
+		err := l.shedLocked("queue_full", depth)
+		l.mu.Unlock()
+		return nil, err
+	}
+	if l.estimateWait(depth+1) > l.maxWait {
+		err := l.shedLocked("wait_budget", depth)
+		l.mu.Unlock()
+		return nil, err
+	}
+
+	waiter := &ragWaiter{ready: make(chan struct{})}
+	elem := l.queue.PushBack(waiter)
+	l.updateGauges()
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.maxWait)
+	defer timer.Stop()
+
+	select {
+	case <-waiter.ready:
+		return l.releaser(), nil
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+
+	l.mu.Lock()
+	if waiter.granted {
+		// A slot was handed over while giving up; use it unless the caller is gone
+		l.mu.Unlock()
+		release := l.releaser()
+		if ctx.Err() != nil {
+			release()
+			return nil, transportError(ctx, ctx.Err())
+		}
+		return release, nil
+	}
+
+	l.queue.Remove(elem)
+	defer l.mu.Unlock()
+	if ctx.Err() != nil {
+		l.updateGauges()
+		return nil, transportError(ctx, ctx.Err())
+	}
+	// The estimate was too optimistic; give up rather than exceed the budget
+	return nil, l.shedLocked("wait_timeout", l.queue.Len())
+}
+
+// releaser returns the function freeing a slot acquired now. The slot goes
+// to the next waiter, if any.
+func (l *RAGLimiter) releaser() func() {
+	start := time.Now()
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+
+			held := time.Since(start)
+			if l.avgLatency == 0 {
+				l.avgLatency = held
+			} else {
+				l.avgLatency += (held - l.avgLatency) / 5
+			}
+
+			if front := l.queue.Front(); front != nil {
+				waiter := l.queue.Remove(front).(*ragWaiter)
+				waiter.granted = true
+				close(waiter.ready)
+			} else {
+				l.inFlight--
+			}
+			l.updateGauges()
+		})
+	}
+}
+
+// estimateWait estimates how long the waiter at position would queue: slots
+// free up at a rate of limit per average call duration. Until a call has
+// completed there is no estimate, and only the wait budget applies.
+func (l *RAGLimiter) estimateWait(position int) time.Duration {
+	return time.Duration(position) * l.avgLatency / time.Duration(l.limit)
+}
+
+// shedLocked records a rejected caller and builds its error, suggesting a
+// retry once the current queue has drained
+func (l *RAGLimiter) shedLocked(reason string, depth int) error {
+	l.shed++
+	l.updateGauges()
+	middleware.RecordRAGShed(reason)
+
+	retryAfter := l.estimateWait(depth + 1)
+	if retryAfter <= 0 || retryAfter > l.maxWait {
+		retryAfter = l.maxWait
+	}
+	if retryAfter < time.Second {
+		retryAfter = time.Second
+	}
+	return &BusyError{RetryAfter: retryAfter.Round(time.Second), QueueDepth: depth}
+}
+
+func (l *RAGLimiter) updateGauges() {
+	middleware.SetRAGQueue(l.inFlight, l.queue.Len())
+}
+
+// Status reports the limiter's load for the health check, or nil when the
+// limit is disabled
+func (l *RAGLimiter) Status() *models.RAGQueueStatus {
+	if l == nil || l.limit <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return &models.RAGQueueStatus{
+		InFlight:     l.inFlight,
+		MaxInFlight:  l.limit,
+		QueueDepth:   l.queue.Len(),
+		MaxQueue:     l.maxQueue,
+		AvgLatencyMs: l.avgLatency.Milliseconds(),
+		Shed:         l.shed,
+	}
+}
@@ -0,0 +1,476 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/netguard"
+)
+
+// RAG service transports
+const (
+	RAGTransportHTTP = "http"
+	RAGTransportGRPC = "grpc"
+)
+
+// RAGTransport carries queries and documents to the RAG service
+type RAGTransport interface {
+	// Query answers a question in one response
+	Query(ctx context.Context, req RAGQueryRequest) (*RAGQueryResponse, error)
+	// QueryStream calls onToken with each generated fragment of the answer and
+	// returns the complete response. An error from onToken stops the stream.
+	QueryStream(ctx context.Context, req RAGQueryRequest, onToken func(string) error) (*RAGQueryResponse, error)
+	// Ingest sends a document to be chunked and embedded
+	Ingest(ctx context.Context, doc RAGIngestRequest) (*RAGIngestResponse, error)
+	// IngestProgress reports the progress of an ingestion started with a job
+	// ID. It returns nil if the job isn't known yet and
+	// ErrIngestProgressUnsupported if the service doesn't track progress.
+	IngestProgress(ctx context.Context, jobID string) (*RAGIngestProgress, error)
+	// DeleteDocument removes an ingested document's chunks by vector store ID
+	DeleteDocument(ctx context.Context, vectorStoreID string) error
+	// UpdateDocument replaces fields on an ingested document's chunks, e.g.
+	// its visibility, by vector store ID
+	UpdateDocument(ctx context.Context, vectorStoreID string, fields map[string]string) error
+	// Generation returns the state of a query started with a generation ID,
+	// nil if the service doesn't know it, or ErrGenerationUnsupported if the
+	// service doesn't keep generations
+	Generation(ctx context.Context, generationID string) (*RAGGeneration, error)
+	// Check returns an error if the RAG service is not serving
+	Check(ctx context.Context) error
+	// Close releases the transport's connections
+	Close() error
+}
+
+// RAGIngestRequest is a document to ingest. Fields are passed alongside the
+// content, e.g. the collection that chunks are tagged with. A non-empty job
+// ID lets the ingestion's progress be polled while it runs.
+type RAGIngestRequest struct {
+	FileName string
+	Content  io.Reader
+	Fields   map[string]string
+	JobID    string
+}
+
+// RAGIngestResponse describes an ingested document
+type RAGIngestResponse struct {
+	ChunkCount    int    `json:"chunk_count"`
+	VectorStoreID string `json:"vector_store_id"`
+}
+
+// RAGIngestProgress is how many of a document's chunks have been embedded.
+// The total is 0 until the service has finished chunking the document.
+type RAGIngestProgress struct {
+	ChunksProcessed int `json:"chunks_processed"`
+	ChunksTotal     int `json:"chunks_total"`
+}
+
+// ErrIngestProgressUnsupported is returned by IngestProgress when the RAG
+// service doesn't report ingestion progress
+var ErrIngestProgressUnsupported = errors.New("RAG service does not report ingestion progress")
+
+// RAGGeneration is the answer generated so far for a query started with a
+// generation ID. Done is set once the answer is complete.
+type RAGGeneration struct {
+	RAGQueryResponse
+	Done bool `json:"done"`
+}
+
+// ErrGenerationUnsupported is returned by Generation when the RAG service
+// doesn't keep generations to fetch later
+var ErrGenerationUnsupported = errors.New("RAG service does not keep generations")
+
+// NewRAGTransport creates the transport selected by RAG_TRANSPORT
+func NewRAGTransport(cfg *config.Config) (RAGTransport, error) {
+	switch cfg.RAGTransport {
+	case RAGTransportGRPC:
+		return newGRPCRAGTransport(cfg)
+	case RAGTransportHTTP, "":
+		return newHTTPRAGTransport(cfg.RAGServiceURL), nil
+	default:
+		return nil, fmt.Errorf("unknown RAG transport %q", cfg.RAGTransport)
+	}
+}
+
+// NewRAGFallbackTransport creates the transport for the fallback RAG
+// endpoint, or returns nil if RAG_SERVICE_FALLBACK_URL is not set. The
+// fallback only answers queries, over HTTP whatever RAG_TRANSPORT is.
+func NewRAGFallbackTransport(cfg *config.Config) RAGTransport {
+	if cfg.RAGServiceFallbackURL == "" {
+		return nil
+	}
+	return newHTTPRAGTransport(cfg.RAGServiceFallbackURL)
+}
+
+// httpIngestTimeout bounds ingestion when the caller sets no deadline
+const httpIngestTimeout = 300 * time.Second
+
+// httpRAGTransport calls the RAG service's JSON endpoints
+type httpRAGTransport struct {
+	baseURL      string
+	queryClient  *http.Client
+	ingestClient *http.Client
+	healthClient *http.Client
+}
+
+func newHTTPRAGTransport(baseURL string) *httpRAGTransport {
+	return &httpRAGTransport{
+		baseURL: baseURL,
+		queryClient: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+		// Ingestion is bounded by the caller's deadline or httpIngestTimeout,
+		// so large streamed documents aren't cut off by a fixed client timeout
+		ingestClient: &http.Client{},
+		healthClient: &http.Client{
+			Timeout: 3 * time.Second,
+		},
+	}
+}
+
+// Query makes HTTP request to RAG service. Queries with attachments go to
+// /rag/query/multimodal as a multipart form: the JSON request in the request
+// field and each image as an attachments file part.
+func (t *httpRAGTransport) Query(ctx context.Context, req RAGQueryRequest) (*RAGQueryResponse, error) {
+	url := fmt.Sprintf("%s/rag/query", t.baseURL)
+
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	body, contentType := bytes.NewBuffer(jsonData), "application/json"
+	if len(req.Attachments) > 0 {
+		url = fmt.Sprintf("%s/rag/query/multimodal", t.baseURL)
+		body, contentType, err = multimodalQueryBody(jsonData, req.Attachments)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", contentType)
+
+	resp, err := t.queryClient.Do(httpReq)
+	if err != nil {
+		return nil, transportError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &RAGError{
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+
+	var ragResp RAGQueryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ragResp); err != nil {
+		return nil, fmt.Errorf("%w: failed to decode response: %v", ErrRAGUnavailable, err)
+	}
+
+	return &ragResp, nil
+}
+
+// multimodalQueryBody builds the multipart form of a query with attachments
+func multimodalQueryBody(jsonData []byte, attachments []RAGAttachment) (*bytes.Buffer, string, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	if err := writer.WriteField("request", string(jsonData)); err != nil {
+		return nil, "", fmt.Errorf("failed to write request field: %w", err)
+	}
+	for i, attachment := range attachments {
+		fileName := attachment.FileName
+		if fileName == "" {
+			fileName = fmt.Sprintf("attachment-%d", i)
+		}
+
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{"name": "attachments", "filename": fileName}))
+		header.Set("Content-Type", attachment.ContentType)
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create attachment part: %w", err)
+		}
+		if _, err := part.Write(attachment.Data); err != nil {
+			return nil, "", fmt.Errorf("failed to write attachment: %w", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to close multipart writer: %w", err)
+	}
+
+	return body, writer.FormDataContentType(), nil
+}
+
+// QueryStream delivers the whole answer as a single token; the JSON endpoint
+// doesn't stream
+func (t *httpRAGTransport) QueryStream(ctx context.Context, req RAGQueryRequest, onToken func(string) error) (*RAGQueryResponse, error) {
+	resp, err := t.Query(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := onToken(resp.Response); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Ingest posts the document to /rag/ingest as a multipart form, streamed so
+// the content is never held in memory
+func (t *httpRAGTransport) Ingest(ctx context.Context, doc RAGIngestRequest) (*RAGIngestResponse, error) {
+	ctx, cancel := withDefaultTimeout(ctx, httpIngestTimeout)
+	defer cancel()
+
+	body, pipeWriter := io.Pipe()
+	// Stops the form writer if the request ends before the content is sent
+	defer body.Close()
+
+	writer := multipart.NewWriter(pipeWriter)
+
+	go func() {
+		pipeWriter.CloseWithError(writeIngestForm(writer, doc))
+	}()
+
+	url := fmt.Sprintf("%s/rag/ingest", t.baseURL)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := t.ingestClient.Do(req)
+	if err != nil {
+		return nil, transportError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, &RAGError{StatusCode: resp.StatusCode, Body: string(bodyBytes)}
+	}
+
+	var ingestResp RAGIngestResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ingestResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &ingestResp, nil
+}
+
+// IngestProgress calls GET /rag/ingest/jobs/{id}. A 404 means the job hasn't
+// been registered yet; 405 and 501 mean the service has no such endpoint.
+func (t *httpRAGTransport) IngestProgress(ctx context.Context, jobID string) (*RAGIngestProgress, error) {
+	endpoint := fmt.Sprintf("%s/rag/ingest/jobs/%s", t.baseURL, url.PathEscape(jobID))
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := t.healthClient.Do(req)
+	if err != nil {
+		return nil, transportError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return nil, ErrIngestProgressUnsupported
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &RAGError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var progress RAGIngestProgress
+	if err := json.NewDecoder(resp.Body).Decode(&progress); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &progress, nil
+}
+
+// Generation calls GET /rag/query/{generation_id}. A 404 means the service
+// doesn't know the generation, or no longer keeps it; 405 and 501 mean the
+// service has no such endpoint.
+func (t *httpRAGTransport) Generation(ctx context.Context, generationID string) (*RAGGeneration, error) {
+	endpoint := fmt.Sprintf("%s/rag/query/%s", t.baseURL, url.PathEscape(generationID))
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := t.healthClient.Do(req)
+	if err != nil {
+		return nil, transportError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return nil, ErrGenerationUnsupported
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &RAGError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var generation RAGGeneration
+	if err := json.NewDecoder(resp.Body).Decode(&generation); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &generation, nil
+}
+
+// DeleteDocument calls DELETE /rag/documents/{id}; an unknown ID is treated
+// as already deleted
+func (t *httpRAGTransport) DeleteDocument(ctx context.Context, vectorStoreID string) error {
+	endpoint := fmt.Sprintf("%s/rag/documents/%s", t.baseURL, url.PathEscape(vectorStoreID))
+	req, err := http.NewRequestWithContext(ctx, "DELETE", endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := t.queryClient.Do(req)
+	if err != nil {
+		return transportError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		body, _ := io.ReadAll(resp.Body)
+		return &RAGError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	return nil
+}
+
+// UpdateDocument patches the document's fields
+func (t *httpRAGTransport) UpdateDocument(ctx context.Context, vectorStoreID string, fields map[string]string) error {
+	endpoint := fmt.Sprintf("%s/rag/documents/%s", t.baseURL, url.PathEscape(vectorStoreID))
+
+	jsonData, err := json.Marshal(map[string]interface{}{"fields": fields})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PATCH", endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.queryClient.Do(req)
+	if err != nil {
+		return transportError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return &RAGError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	return nil
+}
+
+// writeIngestForm writes the document's file part and fields
+func writeIngestForm(writer *multipart.Writer, doc RAGIngestRequest) error {
+	part, err := writer.CreateFormFile("file", doc.FileName)
+	if err != nil {
+		return fmt.Errorf("failed to create form file: %w", err)
+	}
+
+	if _, err := io.Copy(part, doc.Content); err != nil {
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+
+	for name, value := range doc.Fields {
+		if err := writer.WriteField(name, value); err != nil {
+			return fmt.Errorf("failed to write %s field: %w", name, err)
+		}
+	}
+	if doc.JobID != "" {
+		if err := writer.WriteField("job_id", doc.JobID); err != nil {
+			return fmt.Errorf("failed to write job_id field: %w", err)
+		}
+	}
+
+	return writer.Close()
+}
+
+// Check calls the RAG service's /health endpoint
+func (t *httpRAGTransport) Check(ctx context.Context) error {
+	url := fmt.Sprintf("%s/health", t.baseURL)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := t.healthClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// guard makes the transport's requests through guard, for a RAG service at
+// a caller-supplied URL
+func (t *httpRAGTransport) guard(guard *netguard.Guard) {
+	for _, client := range []*http.Client{t.queryClient, t.ingestClient, t.healthClient} {
+		client.Transport = guard.Transport()
+	}
+}
+
+func (t *httpRAGTransport) Close() error {
+	t.queryClient.CloseIdleConnections()
+	t.ingestClient.CloseIdleConnections()
+	t.healthClient.CloseIdleConnections()
+	return nil
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP
+// date. It returns 0 when the header is missing or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,411 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/models"
+	ragpb "github.com/ai-support-assistant/backend/internal/ragclient/grpc"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// Upper bounds for calls whose context has no deadline, matching the HTTP transport
+const (
+	grpcQueryTimeout  = 60 * time.Second
+	grpcIngestTimeout = 300 * time.Second
+	grpcHealthTimeout = 3 * time.Second
+)
+
+// grpcIngestChunkSize is the size of the content messages a document is sent in
+const grpcIngestChunkSize = 64 * 1024
+
+// grpcRAGTransport calls the RAG service over gRPC. Request deadlines are
+// propagated to the service, and a dropped connection is re-established in
+// the background with exponential backoff; calls fail fast while it is down.
+type grpcRAGTransport struct {
+	conn   *grpc.ClientConn
+	client ragpb.RAGServiceClient
+	health healthpb.HealthClient
+}
+
+func newGRPCRAGTransport(cfg *config.Config) (*grpcRAGTransport, error) {
+	creds := insecure.NewCredentials()
+	if cfg.RAGGRPCTLS {
+		tlsConfig := &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			ServerName: cfg.RAGGRPCServerName,
+		}
+		if cfg.RAGGRPCCAFile != "" {
+			pem, err := os.ReadFile(cfg.RAGGRPCCAFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read RAG gRPC CA file: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in RAG gRPC CA file %s", cfg.RAGGRPCCAFile)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
+	backoffConfig := backoff.DefaultConfig
+	backoffConfig.MaxDelay = time.Duration(cfg.RAGGRPCBackoffMaxS) * time.Second
+
+	// Dial doesn't block; the connection is made and remade in the background
+	conn, err := grpc.Dial(cfg.RAGGRPCAddress,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff:           backoffConfig,
+			MinConnectTimeout: 5 * time.Second,
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial RAG service: %w", err)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"address": cfg.RAGGRPCAddress,
+		"tls":     cfg.RAGGRPCTLS,
+	}).Info("Using gRPC transport for RAG service")
+
+	return &grpcRAGTransport{
+		conn:   conn,
+		client: ragpb.NewRAGServiceClient(conn),
+		health: healthpb.NewHealthClient(conn),
+	}, nil
+}
+
+func (t *grpcRAGTransport) Query(ctx context.Context, req RAGQueryRequest) (*RAGQueryResponse, error) {
+	if len(req.Attachments) > 0 {
+		return nil, fmt.Errorf("%w: attachments are not supported over gRPC", ErrRAGBadRequest)
+	}
+
+	ctx, cancel := withDefaultTimeout(ctx, grpcQueryTimeout)
+	defer cancel()
+
+	resp, err := t.client.Query(ctx, queryRequestToProto(req))
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return queryResponseFromProto(resp), nil
+}
+
+func (t *grpcRAGTransport) QueryStream(ctx context.Context, req RAGQueryRequest, onToken func(string) error) (*RAGQueryResponse, error) {
+	if len(req.Attachments) > 0 {
+		return nil, fmt.Errorf("%w: attachments are not supported over gRPC", ErrRAGBadRequest)
+	}
+
+	ctx, cancel := withDefaultTimeout(ctx, grpcQueryTimeout)
+	defer cancel()
+
+	stream, err := t.client.QueryStream(ctx, queryRequestToProto(req))
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%w: stream ended without a final response", ErrRAGUnavailable)
+		}
+		if err != nil {
+			return nil, grpcError(ctx, err)
+		}
+
+		if final := chunk.GetFinal(); final != nil {
+			return queryResponseFromProto(final), nil
+		}
+		if err := onToken(chunk.GetToken()); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (t *grpcRAGTransport) Ingest(ctx context.Context, doc RAGIngestRequest) (*RAGIngestResponse, error) {
+	ctx, cancel := withDefaultTimeout(ctx, grpcIngestTimeout)
+	defer cancel()
+
+	stream, err := t.client.Ingest(ctx)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+
+	err = stream.Send(&ragpb.IngestRequest{
+		Part: &ragpb.IngestRequest_Metadata{
+			Metadata: &ragpb.IngestMetadata{FileName: doc.FileName, Fields: doc.Fields, JobId: doc.JobID},
+		},
+	})
+	if err != nil {
+		return nil, grpcSendError(ctx, stream, err)
+	}
+
+	buf := make([]byte, grpcIngestChunkSize)
+	for {
+		n, readErr := doc.Content.Read(buf)
+		if n > 0 {
+			err := stream.Send(&ragpb.IngestRequest{
+				Part: &ragpb.IngestRequest_Content{Content: buf[:n]},
+			})
+			if err != nil {
+				return nil, grpcSendError(ctx, stream, err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read document: %w", readErr)
+		}
+	}
+
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return &RAGIngestResponse{
+		ChunkCount:    int(resp.GetChunkCount()),
+		VectorStoreID: resp.GetVectorStoreId(),
+	}, nil
+}
+
+// IngestProgress treats an unknown job as not yet started and an
+// unimplemented method as a service that doesn't track progress
+func (t *grpcRAGTransport) IngestProgress(ctx context.Context, jobID string) (*RAGIngestProgress, error) {
+	ctx, cancel := context.WithTimeout(ctx, grpcHealthTimeout)
+	defer cancel()
+
+	resp, err := t.client.IngestProgress(ctx, &ragpb.IngestProgressRequest{JobId: jobID})
+	switch status.Code(err) {
+	case codes.OK:
+	case codes.NotFound:
+		return nil, nil
+	case codes.Unimplemented:
+		return nil, ErrIngestProgressUnsupported
+	default:
+		return nil, grpcError(ctx, err)
+	}
+	return &RAGIngestProgress{
+		ChunksProcessed: int(resp.GetChunksProcessed()),
+		ChunksTotal:     int(resp.GetChunksTotal()),
+	}, nil
+}
+
+// Generation treats an unknown generation as not kept and an unimplemented
+// method as a service that doesn't keep generations
+func (t *grpcRAGTransport) Generation(ctx context.Context, generationID string) (*RAGGeneration, error) {
+	ctx, cancel := context.WithTimeout(ctx, grpcHealthTimeout)
+	defer cancel()
+
+	resp, err := t.client.Generation(ctx, &ragpb.GenerationRequest{GenerationId: generationID})
+	switch status.Code(err) {
+	case codes.OK:
+	case codes.NotFound:
+		return nil, nil
+	case codes.Unimplemented:
+		return nil, ErrGenerationUnsupported
+	default:
+		return nil, grpcError(ctx, err)
+	}
+	return &RAGGeneration{
+		RAGQueryResponse: *queryResponseFromProto(resp.GetResponse()),
+		Done:             resp.GetDone(),
+	}, nil
+}
+
+// DeleteDocument treats an unknown vector store ID as already deleted
+func (t *grpcRAGTransport) DeleteDocument(ctx context.Context, vectorStoreID string) error {
+	ctx, cancel := withDefaultTimeout(ctx, grpcQueryTimeout)
+	defer cancel()
+
+	_, err := t.client.DeleteDocument(ctx, &ragpb.DeleteDocumentRequest{VectorStoreId: vectorStoreID})
+	if err != nil && status.Code(err) != codes.NotFound {
+		return grpcError(ctx, err)
+	}
+	return nil
+}
+
+func (t *grpcRAGTransport) UpdateDocument(ctx context.Context, vectorStoreID string, fields map[string]string) error {
+	ctx, cancel := withDefaultTimeout(ctx, grpcQueryTimeout)
+	defer cancel()
+
+	_, err := t.client.UpdateDocument(ctx, &ragpb.UpdateDocumentRequest{VectorStoreId: vectorStoreID, Fields: fields})
+	if err != nil {
+		return grpcError(ctx, err)
+	}
+	return nil
+}
+
+// Check uses the standard gRPC health service
+func (t *grpcRAGTransport) Check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, grpcHealthTimeout)
+	defer cancel()
+
+	resp, err := t.health.Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		return err
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("status %s", resp.GetStatus())
+	}
+	return nil
+}
+
+func (t *grpcRAGTransport) Close() error {
+	return t.conn.Close()
+}
+
+// optionalInt converts an optional proto field, keeping it nil when unset
+func optionalInt(v *int32) *int {
+	if v == nil {
+		return nil
+	}
+	i := int(*v)
+	return &i
+}
+
+// optionalInt32 converts to an optional proto field, leaving it unset when nil
+func optionalInt32(v *int) *int32 {
+	if v == nil {
+		return nil
+	}
+	i := int32(*v)
+	return &i
+}
+
+// optionalFloat32 converts to an optional proto field, leaving it unset when nil
+func optionalFloat32(v *float64) *float32 {
+	if v == nil {
+		return nil
+	}
+	f := float32(*v)
+	return &f
+}
+
+// withDefaultTimeout bounds ctx by timeout unless it already has a deadline
+func withDefaultTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// grpcSendError returns the stream's status after a failed Send, which only
+// reports io.EOF when the server has already ended the call
+func grpcSendError(ctx context.Context, stream ragpb.RAGService_IngestClient, err error) error {
+	if err == io.EOF {
+		_, err = stream.CloseAndRecv()
+	}
+	return grpcError(ctx, err)
+}
+
+// grpcError classifies a gRPC status the way transportError and RAGError
+// classify HTTP failures
+func grpcError(ctx context.Context, err error) error {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return ctx.Err()
+	}
+
+	st := status.Convert(err)
+	switch st.Code() {
+	case codes.DeadlineExceeded:
+		return fmt.Errorf("%w: %s", ErrTimeout, st.Message())
+	case codes.ResourceExhausted:
+		return &RAGError{StatusCode: http.StatusTooManyRequests, Body: st.Message(), RetryAfter: grpcRetryDelay(st)}
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange, codes.NotFound:
+		return fmt.Errorf("%w: %s", ErrRAGBadRequest, st.Message())
+	default:
+		return fmt.Errorf("%w: %v", ErrRAGUnavailable, err)
+	}
+}
+
+// grpcRetryDelay returns the delay from a status's RetryInfo detail, or 0
+func grpcRetryDelay(st *status.Status) time.Duration {
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok && info.GetRetryDelay() != nil {
+			return info.GetRetryDelay().AsDuration()
+		}
+	}
+	return 0
+}
+
+func queryRequestToProto(req RAGQueryRequest) *ragpb.QueryRequest {
+	return &ragpb.QueryRequest{
+		Query:              req.Query,
+		SessionId:          req.SessionID,
+		TopK:               int32(req.TopK),
+		Language:           req.Language,
+		Collections:        req.Collections,
+		PageUrl:            req.PageURL,
+		Locale:             req.Locale,
+		Model:              req.Model,
+		IncludeSuggestions: req.IncludeSuggestions,
+		MaxSuggestions:     int32(req.MaxSuggestions),
+		PromptTemplate:     req.PromptTemplate,
+		PromptVersion:      int32(req.PromptVersion),
+		PromptBody:         req.PromptBody,
+		Audience:           req.Audience,
+		Segment:            int32(req.Segment),
+		ContextReset:       req.ContextReset,
+		Temperature:        optionalFloat32(req.Temperature),
+		HistoryTurns:       optionalInt32(req.HistoryTurns),
+		ResponseLanguage:   req.ResponseLanguage,
+		Persona:            personaToProto(req.Persona),
+		GenerationId:       req.GenerationID,
+	}
+}
+
+// personaToProto converts the request's persona, leaving it unset when nil
+func personaToProto(persona *RAGPersona) *ragpb.Persona {
+	if persona == nil {
+		return nil
+	}
+	return &ragpb.Persona{
+		Prompt:    persona.Prompt,
+		Tone:      persona.Tone,
+		Signature: persona.Signature,
+	}
+}
+
+func queryResponseFromProto(resp *ragpb.QueryResponse) *RAGQueryResponse {
+	ragResp := &RAGQueryResponse{
+		Response:         resp.GetResponse(),
+		Context:          resp.GetContext(),
+		Suggestions:      resp.GetSuggestions(),
+		Model:            resp.GetModel(),
+		TokensUsed:       int(resp.GetTokensUsed()),
+		PromptTokens:     int(resp.GetPromptTokens()),
+		CompletionTokens: int(resp.GetCompletionTokens()),
+		PhaseTimings: models.PhaseTimings{
+			RetrievalMs:        optionalInt(resp.RetrievalMs),
+			GenerationMs:       optionalInt(resp.GenerationMs),
+			TimeToFirstTokenMs: optionalInt(resp.TimeToFirstTokenMs),
+		},
+	}
+	if ragResp.Context == nil {
+		ragResp.Context = []string{}
+	}
+	for _, source := range resp.GetSources() {
+		ragResp.Sources = append(ragResp.Sources, RAGSource{
+			Source: source.GetSource(),
+			DocID:  source.GetDocId(),
+		})
+	}
+	return ragResp
+}
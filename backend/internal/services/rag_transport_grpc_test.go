@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	ragpb "github.com/ai-support-assistant/backend/internal/ragclient/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeRAGServer keeps the generations it is given; methods it doesn't
+// override are unimplemented
+type fakeRAGServer struct {
+	ragpb.UnimplementedRAGServiceServer
+	generations map[string]*ragpb.GenerationResponse
+}
+
+func (f *fakeRAGServer) Generation(ctx context.Context, req *ragpb.GenerationRequest) (*ragpb.GenerationResponse, error) {
+	if f.generations == nil {
+		return nil, status.Error(codes.Unimplemented, "not implemented")
+	}
+	generation, ok := f.generations[req.GetGenerationId()]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "unknown generation")
+	}
+	return generation, nil
+}
+
+// newTestGRPCTransport serves server over an in-memory connection
+func newTestGRPCTransport(t *testing.T, server ragpb.RAGServiceServer) *grpcRAGTransport {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	ragpb.RegisterRAGServiceServer(srv, server)
+	go srv.Serve(listener)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &grpcRAGTransport{conn: conn, client: ragpb.NewRAGServiceClient(conn)}
+}
+
+func TestQueryRequestToProto(t *testing.T) {
+	temperature := 0.7
+	turns := 4
+	req := RAGQueryRequest{
+		Query:            "How do I reset my password?",
+		SessionID:        "s1",
+		TopK:             5,
+		Audience:         "customer",
+		Segment:          2,
+		ContextReset:     true,
+		Temperature:      &temperature,
+		HistoryTurns:     &turns,
+		ResponseLanguage: "de",
+		GenerationID:     "gen-1",
+		Persona:          &RAGPersona{Prompt: "Be brief.", Tone: "friendly", Signature: "- Ada"},
+	}
+
+	pb := queryRequestToProto(req)
+	if pb.GetQuery() != req.Query || pb.GetSessionId() != req.SessionID || pb.GetTopK() != 5 {
+		t.Errorf("query fields = %q, %q, %d", pb.GetQuery(), pb.GetSessionId(), pb.GetTopK())
+	}
+	if pb.GetSegment() != 2 || !pb.GetContextReset() || pb.GetAudience() != "customer" {
+		t.Errorf("segment fields = %d, %t, %q", pb.GetSegment(), pb.GetContextReset(), pb.GetAudience())
+	}
+	if pb.Temperature == nil || pb.GetTemperature() != float32(temperature) {
+		t.Errorf("Temperature = %v, want %v", pb.Temperature, temperature)
+	}
+	if pb.HistoryTurns == nil || pb.GetHistoryTurns() != 4 {
+		t.Errorf("HistoryTurns = %v, want 4", pb.HistoryTurns)
+	}
+	if pb.GetResponseLanguage() != "de" {
+		t.Errorf("ResponseLanguage = %q, want de", pb.GetResponseLanguage())
+	}
+	if pb.GetGenerationId() != "gen-1" {
+		t.Errorf("GenerationId = %q, want gen-1", pb.GetGenerationId())
+	}
+	if p := pb.GetPersona(); p.GetPrompt() != "Be brief." || p.GetTone() != "friendly" || p.GetSignature() != "- Ada" {
+		t.Errorf("Persona = %v", p)
+	}
+
+	// Unset optional fields stay unset rather than becoming zero
+	pb = queryRequestToProto(RAGQueryRequest{Query: "hi"})
+	if pb.Temperature != nil || pb.HistoryTurns != nil || pb.Persona != nil {
+		t.Errorf("optional fields set on an empty request: %v, %v, %v", pb.Temperature, pb.HistoryTurns, pb.Persona)
+	}
+}
+
+func TestQueryResponseFromProto(t *testing.T) {
+	retrieval := int32(40)
+	resp := queryResponseFromProto(&ragpb.QueryResponse{
+		Response:         "Use the reset link.",
+		Sources:          []*ragpb.Source{{Source: "faq.md", DocId: "d1"}},
+		Model:            "m1",
+		TokensUsed:       120,
+		PromptTokens:     100,
+		CompletionTokens: 20,
+		RetrievalMs:      &retrieval,
+	})
+
+	if resp.Response != "Use the reset link." || resp.Model != "m1" {
+		t.Errorf("response = %q, model = %q", resp.Response, resp.Model)
+	}
+	if resp.TokensUsed != 120 || resp.PromptTokens != 100 || resp.CompletionTokens != 20 {
+		t.Errorf("tokens = %d (%d prompt, %d completion), want 120 (100, 20)", resp.TokensUsed, resp.PromptTokens, resp.CompletionTokens)
+	}
+	if len(resp.Sources) != 1 || resp.Sources[0].DocID != "d1" {
+		t.Errorf("Sources = %+v", resp.Sources)
+	}
+	if resp.RetrievalMs == nil || *resp.RetrievalMs != 40 || resp.GenerationMs != nil {
+		t.Errorf("timings = %v, %v", resp.RetrievalMs, resp.GenerationMs)
+	}
+	// An empty context is a list, as the HTTP transport decodes it
+	if resp.Context == nil {
+		t.Error("Context is nil")
+	}
+}
+
+func TestGRPCGeneration(t *testing.T) {
+	transport := newTestGRPCTransport(t, &fakeRAGServer{generations: map[string]*ragpb.GenerationResponse{
+		"gen-1": {Response: &ragpb.QueryResponse{Response: "Partial answer", TokensUsed: 12}},
+		"gen-2": {Response: &ragpb.QueryResponse{Response: "Full answer"}, Done: true},
+	}})
+	ctx := context.Background()
+
+	generation, err := transport.Generation(ctx, "gen-1")
+	if err != nil {
+		t.Fatalf("Generation: %v", err)
+	}
+	if generation.Response != "Partial answer" || generation.TokensUsed != 12 || generation.Done {
+		t.Errorf("Generation(gen-1) = %+v", generation)
+	}
+
+	generation, err = transport.Generation(ctx, "gen-2")
+	if err != nil || !generation.Done {
+		t.Errorf("Generation(gen-2) = %+v, %v; want done", generation, err)
+	}
+
+	generation, err = transport.Generation(ctx, "unknown")
+	if err != nil || generation != nil {
+		t.Errorf("Generation(unknown) = %+v, %v; want nil, nil", generation, err)
+	}
+}
+
+func TestGRPCGenerationUnsupported(t *testing.T) {
+	transport := newTestGRPCTransport(t, &fakeRAGServer{})
+	if _, err := transport.Generation(context.Background(), "gen-1"); !errors.Is(err, ErrGenerationUnsupported) {
+		t.Errorf("Generation error = %v, want ErrGenerationUnsupported", err)
+	}
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/models"
+	ragpb "github.com/ai-support-assistant/backend/internal/ragclient/grpc"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// ragScenario is how a fake RAG service answers queries, served the same way
+// over HTTP and gRPC so both transports can be held to the same results
+type ragScenario struct {
+	answer *RAGQueryResponse
+	// tokens are the fragments a stream sends before the answer; the HTTP
+	// service can't stream, so its transport sends the answer as one token
+	tokens []string
+	// fail is rate_limited, bad_request, unavailable or slow; broken fails a
+	// gRPC stream after its tokens
+	fail string
+
+	mu       sync.Mutex
+	received *RAGQueryRequest
+}
+
+func (s *ragScenario) receive(req RAGQueryRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.received = &req
+}
+
+func (s *ragScenario) lastRequest() *RAGQueryRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.received
+}
+
+// ServeHTTP answers /rag/query like the RAG service's JSON endpoint
+func (s *ragScenario) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req RAGQueryRequest
+	json.NewDecoder(r.Body).Decode(&req)
+	s.receive(req)
+
+	switch s.fail {
+	case "rate_limited":
+		w.Header().Set("Retry-After", "2")
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	case "bad_request":
+		http.Error(w, "query too long", http.StatusBadRequest)
+	case "unavailable":
+		http.Error(w, "model offline", http.StatusServiceUnavailable)
+	case "slow":
+		<-r.Context().Done()
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.answer)
+	}
+}
+
+// grpcScenarioServer answers queries over gRPC from a ragScenario
+type grpcScenarioServer struct {
+	ragpb.UnimplementedRAGServiceServer
+	scenario *ragScenario
+}
+
+func (g *grpcScenarioServer) fail(ctx context.Context) error {
+	switch g.scenario.fail {
+	case "rate_limited":
+		st, _ := status.New(codes.ResourceExhausted, "slow down").WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(2 * time.Second)})
+		return st.Err()
+	case "bad_request":
+		return status.Error(codes.InvalidArgument, "query too long")
+	case "unavailable":
+		return status.Error(codes.Unavailable, "model offline")
+	case "slow":
+		<-ctx.Done()
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	return nil
+}
+
+func (g *grpcScenarioServer) Query(ctx context.Context, req *ragpb.QueryRequest) (*ragpb.QueryResponse, error) {
+	g.scenario.receive(queryRequestFromProto(req))
+	if err := g.fail(ctx); err != nil {
+		return nil, err
+	}
+	return queryResponseToProto(g.scenario.answer), nil
+}
+
+func (g *grpcScenarioServer) QueryStream(req *ragpb.QueryRequest, stream ragpb.RAGService_QueryStreamServer) error {
+	g.scenario.receive(queryRequestFromProto(req))
+	if err := g.fail(stream.Context()); err != nil {
+		return err
+	}
+	for _, token := range g.scenario.tokens {
+		if err := stream.Send(&ragpb.QueryChunk{Chunk: &ragpb.QueryChunk_Token{Token: token}}); err != nil {
+			return err
+		}
+	}
+	if g.scenario.fail == "broken" {
+		return status.Error(codes.Unavailable, "connection reset")
+	}
+	return stream.Send(&ragpb.QueryChunk{Chunk: &ragpb.QueryChunk_Final{Final: queryResponseToProto(g.scenario.answer)}})
+}
+
+// queryRequestFromProto reads back the fields the tests send
+func queryRequestFromProto(pb *ragpb.QueryRequest) RAGQueryRequest {
+	req := RAGQueryRequest{
+		Query:            pb.GetQuery(),
+		SessionID:        pb.GetSessionId(),
+		TopK:             int(pb.GetTopK()),
+		Language:         pb.GetLanguage(),
+		Collections:      pb.GetCollections(),
+		Audience:         pb.GetAudience(),
+		Segment:          int(pb.GetSegment()),
+		ResponseLanguage: pb.GetResponseLanguage(),
+		GenerationID:     pb.GetGenerationId(),
+	}
+	if persona := pb.GetPersona(); persona != nil {
+		req.Persona = &RAGPersona{Prompt: persona.GetPrompt(), Tone: persona.GetTone(), Signature: persona.GetSignature()}
+	}
+	return req
+}
+
+func queryResponseToProto(resp *RAGQueryResponse) *ragpb.QueryResponse {
+	pb := &ragpb.QueryResponse{
+		Response:         resp.Response,
+		Context:          resp.Context,
+		Suggestions:      resp.Suggestions,
+		Model:            resp.Model,
+		TokensUsed:       int32(resp.TokensUsed),
+		PromptTokens:     int32(resp.PromptTokens),
+		CompletionTokens: int32(resp.CompletionTokens),
+	}
+	if resp.RetrievalMs != nil {
+		retrieval := int32(*resp.RetrievalMs)
+		pb.RetrievalMs = &retrieval
+	}
+	for _, source := range resp.Sources {
+		pb.Sources = append(pb.Sources, &ragpb.Source{Source: source.Source, DocId: source.DocID})
+	}
+	return pb
+}
+
+// scenarioTransports serves the scenario over HTTP and gRPC, returning a
+// transport for each
+func scenarioTransports(t *testing.T, scenario *ragScenario) map[string]RAGTransport {
+	t.Helper()
+	server := httptest.NewServer(scenario)
+	t.Cleanup(server.Close)
+	return map[string]RAGTransport{
+		RAGTransportHTTP: newHTTPRAGTransport(server.URL),
+		RAGTransportGRPC: newTestGRPCTransport(t, &grpcScenarioServer{scenario: scenario}),
+	}
+}
+
+func TestRAGTransportsAgree(t *testing.T) {
+	retrieval := 35
+	answer := &RAGQueryResponse{
+		Response:         "Use the reset link on the sign-in page.",
+		Context:          []string{"Passwords are reset from the sign-in page."},
+		Sources:          []RAGSource{{Source: "account.md", DocID: "d1"}},
+		Suggestions:      []string{"How do I change my email?"},
+		Model:            "m1",
+		TokensUsed:       150,
+		PromptTokens:     120,
+		CompletionTokens: 30,
+		PhaseTimings:     models.PhaseTimings{RetrievalMs: &retrieval},
+	}
+	req := RAGQueryRequest{
+		Query:            "How do I reset my password?",
+		SessionID:        "s1",
+		TopK:             5,
+		Language:         "en",
+		Collections:      []string{"account"},
+		Audience:         "customer",
+		Segment:          2,
+		ResponseLanguage: "en",
+		GenerationID:     "gen-1",
+		Persona:          &RAGPersona{Prompt: "Be brief.", Tone: "friendly", Signature: "- Ada"},
+	}
+
+	tests := []struct {
+		name    string
+		fail    string
+		timeout time.Duration
+		want    error
+		check   func(t *testing.T, err error)
+	}{
+		{name: "answers"},
+		{name: "rate limited", fail: "rate_limited", want: ErrUpstreamRateLimited, check: func(t *testing.T, err error) {
+			var ragErr *RAGError
+			if !errors.As(err, &ragErr) || ragErr.StatusCode != http.StatusTooManyRequests || ragErr.RetryAfter != 2*time.Second {
+				t.Errorf("error = %#v, want a 429 retried after 2s", err)
+			}
+		}},
+		{name: "bad request", fail: "bad_request", want: ErrRAGBadRequest},
+		{name: "unavailable", fail: "unavailable", want: ErrRAGUnavailable},
+		{name: "past the deadline", fail: "slow", timeout: 50 * time.Millisecond, want: ErrTimeout},
+	}
+	for _, tt := range tests {
+		scenario := &ragScenario{answer: answer, tokens: []string{"Use the reset link ", "on the sign-in page."}, fail: tt.fail}
+		for name, transport := range scenarioTransports(t, scenario) {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				ctx := context.Background()
+				if tt.timeout > 0 {
+					var cancel context.CancelFunc
+					ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+					defer cancel()
+				}
+
+				resp, err := transport.Query(ctx, req)
+				if got := scenario.lastRequest(); got == nil || !reflect.DeepEqual(*got, req) {
+					t.Errorf("service received %+v, want %+v", got, req)
+				}
+				if tt.want != nil {
+					if !errors.Is(err, tt.want) {
+						t.Errorf("error = %v, want %v", err, tt.want)
+					}
+					if tt.check != nil {
+						tt.check(t, err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("Query: %v", err)
+				}
+				if !reflect.DeepEqual(resp, answer) {
+					t.Errorf("response = %+v, want %+v", resp, answer)
+				}
+			})
+		}
+	}
+}
+
+func TestRAGTransportsStream(t *testing.T) {
+	answer := &RAGQueryResponse{Response: "Use the reset link on the sign-in page.", Context: []string{}, Model: "m1", TokensUsed: 12}
+	scenario := &ragScenario{answer: answer, tokens: []string{"Use the reset link ", "on the sign-in page."}}
+	transports := scenarioTransports(t, scenario)
+	wantTokens := map[string]int{RAGTransportHTTP: 1, RAGTransportGRPC: 2}
+
+	for name, transport := range transports {
+		t.Run(name, func(t *testing.T) {
+			var tokens []string
+			resp, err := transport.QueryStream(context.Background(), RAGQueryRequest{Query: "How do I reset my password?"}, func(token string) error {
+				tokens = append(tokens, token)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("QueryStream: %v", err)
+			}
+			if !reflect.DeepEqual(resp, answer) {
+				t.Errorf("final response = %+v, want %+v", resp, answer)
+			}
+			if len(tokens) != wantTokens[name] || strings.Join(tokens, "") != answer.Response {
+				t.Errorf("tokens = %q, want the answer in %d parts", tokens, wantTokens[name])
+			}
+		})
+	}
+
+	// A caller that stops reading ends the stream with its error
+	stop := errors.New("client went away")
+	for name, transport := range transports {
+		_, err := transport.QueryStream(context.Background(), RAGQueryRequest{Query: "hi"}, func(string) error { return stop })
+		if !errors.Is(err, stop) {
+			t.Errorf("%s: error = %v, want the callback's error", name, err)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+)
+
+// regenerateTopKStep is how many more chunks each regeneration attempt retrieves
+const regenerateTopKStep = 3
+
+// RegenerateQuery answers an earlier query again, skipping the cache and
+// asking with different parameters so the answer differs. The new answer is
+// stored as a query linked to the original. Regenerating a regenerated
+// answer counts against the original, which can be regenerated at most
+// REGENERATE_MAX_ATTEMPTS times. Regenerations count against the caller's quota.
+func (s *QueryService) RegenerateQuery(ctx context.Context, queryID uint, sessionID, visitorID, tokenAudience, tokenUserID, tokenPlan string) (*models.QueryResponse, error) {
+	var original models.ChatQuery
+	if err := db.DB.WithContext(ctx).First(&original, queryID).Error; err != nil {
+		return nil, notFoundError("query", err)
+	}
+	// Another session's query is reported as missing, not revealed
+	if original.SessionID != sessionID || original.Synthetic {
+		return nil, fmt.Errorf("%w: query %d", ErrNotFound, queryID)
+	}
+	if original.ParentQueryID != nil {
+		parentID := *original.ParentQueryID
+		original = models.ChatQuery{}
+		if err := db.DB.WithContext(ctx).First(&original, parentID).Error; err != nil {
+			return nil, notFoundError("query", err)
+		}
+	}
+
+	var attempts int64
+	if err := db.DB.WithContext(ctx).Model(&models.ChatQuery{}).Where("parent_query_id = ?", original.ID).Count(&attempts).Error; err != nil {
+		return nil, fmt.Errorf("failed to count regenerations: %w", err)
+	}
+	if int(attempts) >= s.cfg.RegenerateMaxAttempts {
+		return nil, fmt.Errorf("%w: query %d has already been regenerated %d times", ErrLimitExceeded, original.ID, attempts)
+	}
+
+	// Images are only kept while ATTACHMENT_RETENTION is set
+	attachments, err := retainedAttachments(ctx, original)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.ProcessQuery(ctx, models.QueryRequest{
+		Query:             original.Query,
+		Attachments:       attachments,
+		SessionID:         original.SessionID,
+		UserID:            original.UserID,
+		VisitorID:         visitorID,
+		Metadata:          original.Metadata,
+		TokenAudience:     tokenAudience,
+		TokenUserID:       tokenUserID,
+		TokenPlan:         tokenPlan,
+		PersonaID:         original.PersonaID,
+		ParentQueryID:     &original.ID,
+		RegenerateAttempt: int(attempts) + 1,
+	})
+}
+
+// perturbForRegeneration widens retrieval with each attempt and switches to
+// the regeneration temperature and model
+func (s *QueryService) perturbForRegeneration(ragReq *RAGQueryRequest, attempt int) {
+	ragReq.TopK += regenerateTopKStep * attempt
+	temperature := s.cfg.RegenerateTemperature
+	ragReq.Temperature = &temperature
+	if s.cfg.RegenerateModel != "" {
+		ragReq.Model = s.cfg.RegenerateModel
+	}
+}
+
+// temperatureKey distinguishes temperature overrides in coalescing keys
+func temperatureKey(temperature *float64) string {
+	if temperature == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*temperature, 'f', -1, 64)
+}
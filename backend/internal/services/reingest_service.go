@@ -0,0 +1,555 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/audit"
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/lifecycle"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Re-ingestion job statuses
+const (
+	ReingestRunning   = "running"
+	ReingestCompleted = "completed"
+	ReingestCancelled = "cancelled"
+	ReingestFailed    = "failed"
+)
+
+// Re-ingestion item statuses
+const (
+	reingestPending   = "pending"
+	reingestInFlight  = "in_flight"
+	reingestCompleted = "completed"
+	reingestFailed    = "failed"
+	reingestSkipped   = "skipped"
+)
+
+// Reasons a document of a re-ingestion job was skipped
+const (
+	reingestSourceMissing   = "source_missing"   // only the text of whole plain-text uploads is kept
+	reingestDocumentChanged = "document_changed" // deleted, superseded or being ingested since the job started
+)
+
+// reingestCancelPollInterval is how often a job checks whether it was
+// cancelled through another instance
+const reingestCancelPollInterval = 5 * time.Second
+
+// reingestRetryDelay is the wait before retrying a document whose ingestion
+// failed with a retryable error; it doubles on each attempt
+const reingestRetryDelay = 2 * time.Second
+
+// reingestEvictTimeout bounds evicting cached answers once a job ends, which
+// happens even if the job was cancelled
+const reingestEvictTimeout = 30 * time.Second
+
+// ReingestService re-ingests the stored documents of the knowledge base, for
+// when the RAG service's chunking or embeddings changed. A job lists its
+// documents up front and ingests them a few at a time from the text kept at
+// upload, replacing each document's chunks; documents whose source wasn't
+// kept are skipped. Cached answers are evicted once, when the job ends.
+type ReingestService struct {
+	cfg       *config.Config
+	documents *DocumentService
+	lifecycle *lifecycle.Manager
+
+	// cancels stops the jobs running on this instance, by job ID
+	mu      sync.Mutex
+	cancels map[uint]func(reason string)
+}
+
+func NewReingestService(cfg *config.Config, documents *DocumentService, lc *lifecycle.Manager) *ReingestService {
+	return &ReingestService{
+		cfg:       cfg,
+		documents: documents,
+		lifecycle: lc,
+		cancels:   make(map[uint]func(reason string)),
+	}
+}
+
+// StartReingest lists the documents to re-ingest, records the job and starts
+// it in the background, returning the job to poll. Only one job runs at a time.
+func (s *ReingestService) StartReingest(ctx context.Context, req models.ReingestRequest) (*models.ReingestJob, error) {
+	if s.lifecycle.Stopping() {
+		return nil, fmt.Errorf("%w: server is shutting down", ErrOverloaded)
+	}
+
+	statuses := []string{"completed", "failed"}
+	switch req.Status {
+	case "":
+	case "completed", "failed":
+		statuses = []string{req.Status}
+	default:
+		return nil, validationError("status must be completed or failed")
+	}
+
+	scope := db.DB.WithContext(ctx).Where("status IN ?", statuses)
+	job := models.ReingestJob{
+		DocumentStatus: req.Status,
+		Status:         ReingestRunning,
+		CreatedBy:      audit.ActorFrom(ctx).UserID,
+	}
+	if req.Collection != "" {
+		var collection models.Collection
+		name := NormalizeCollectionName(req.Collection)
+		if err := db.DB.WithContext(ctx).Where("name = ?", name).First(&collection).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, validationError("unknown collection %q", name)
+			}
+			return nil, fmt.Errorf("failed to get collection: %w", err)
+		}
+		scope = scope.Where("collection_id = ?", collection.ID)
+		job.Collection = collection.Name
+	}
+
+	var documents []models.Document
+	if err := scope.Omit("extracted_text").Order("id").Find(&documents).Error; err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+	if len(documents) == 0 {
+		return nil, validationError("no documents to re-ingest")
+	}
+
+	items := make([]models.ReingestItem, len(documents))
+	for i, doc := range documents {
+		items[i] = models.ReingestItem{DocumentID: doc.ID, FileName: doc.FileName, Status: reingestPending}
+		if !canQueue(&doc) {
+			items[i].Status = reingestSkipped
+			items[i].Reason = reingestSourceMissing
+			job.Skipped++
+		}
+	}
+	job.Total = len(items)
+
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var running int64
+		if err := tx.Model(&models.ReingestJob{}).Where("status = ?", ReingestRunning).Count(&running).Error; err != nil {
+			return fmt.Errorf("failed to check running re-ingestion jobs: %w", err)
+		}
+		if running > 0 {
+			return fmt.Errorf("%w: a re-ingestion job is already running", ErrConflict)
+		}
+
+		if err := tx.Create(&job).Error; err != nil {
+			return fmt.Errorf("failed to save re-ingestion job: %w", err)
+		}
+		for i := range items {
+			items[i].JobID = job.ID
+		}
+		if err := tx.CreateInBatches(items, 500).Error; err != nil {
+			return fmt.Errorf("failed to save re-ingestion items: %w", err)
+		}
+		return audit.Record(ctx, tx, "document.reingest_all", "reingest_job", strconv.FormatUint(uint64(job.ID), 10), nil, job)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"reingest_job_id": job.ID,
+		"total":           job.Total,
+		"skipped":         job.Skipped,
+	}).Info("Re-ingestion started")
+
+	stop := s.track(job.ID)
+	started := s.lifecycle.Go("reingest", logrus.Fields{"reingest_job_id": job.ID}, func(ctx context.Context) {
+		s.run(ctx, job.ID, stop)
+	})
+	if !started {
+		s.untrack(job.ID)
+		s.finish(job.ID, ReingestFailed, "server shutting down")
+		job.Status = ReingestFailed
+		job.Error = "server shutting down"
+	}
+
+	return &job, nil
+}
+
+// GetReingestJob returns a re-ingestion job with a page of its documents,
+// optionally only those with a status, and their total
+func (s *ReingestService) GetReingestJob(ctx context.Context, id uint, status string, limit, offset int) (*models.ReingestJob, []models.ReingestItem, int64, error) {
+	var job models.ReingestJob
+	if err := db.DB.WithContext(ctx).First(&job, id).Error; err != nil {
+		return nil, nil, 0, notFoundError("re-ingestion job", err)
+	}
+
+	scope := func() *gorm.DB {
+		query := db.DB.WithContext(ctx).Model(&models.ReingestItem{}).Where("job_id = ?", id)
+		if status != "" {
+			query = query.Where("status = ?", status)
+		}
+		return query
+	}
+
+	var total int64
+	if err := scope().Count(&total).Error; err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to count re-ingestion items: %w", err)
+	}
+
+	items := []models.ReingestItem{}
+	if err := scope().Order("id").Limit(limit).Offset(offset).Find(&items).Error; err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to get re-ingestion items: %w", err)
+	}
+
+	return &job, items, total, nil
+}
+
+// CancelReingestJob stops a running re-ingestion job. Documents already
+// re-ingested keep their new chunks, and those in flight are finished; the
+// rest stay pending. The instance running it notices within
+// reingestCancelPollInterval if it isn't this one.
+func (s *ReingestService) CancelReingestJob(ctx context.Context, id uint) (*models.ReingestJob, error) {
+	var job models.ReingestJob
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&job, id).Error; err != nil {
+			return notFoundError("re-ingestion job", err)
+		}
+		if job.Status != ReingestRunning {
+			return fmt.Errorf("%w: re-ingestion job %d is %s", ErrConflict, id, job.Status)
+		}
+
+		before := job
+		job.Status = ReingestCancelled
+		job.CancelledBy = audit.ActorFrom(ctx).UserID
+		err := tx.Model(&job).Updates(map[string]interface{}{
+			"status":       job.Status,
+			"cancelled_by": job.CancelledBy,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to cancel re-ingestion job: %w", err)
+		}
+		return audit.Record(ctx, tx, "document.reingest_cancel", "reingest_job", strconv.FormatUint(uint64(id), 10), before, job)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	stop := s.cancels[id]
+	s.mu.Unlock()
+	if stop != nil {
+		stop(ReingestCancelled)
+	}
+
+	logrus.WithField("reingest_job_id", id).Info("Re-ingestion cancelled")
+	return &job, nil
+}
+
+// track registers a job about to start on this instance so it can be cancelled
+func (s *ReingestService) track(id uint) *shadowStop {
+	st := &shadowStop{}
+	s.mu.Lock()
+	s.cancels[id] = st.stop
+	s.mu.Unlock()
+	return st
+}
+
+func (s *ReingestService) untrack(id uint) {
+	s.mu.Lock()
+	delete(s.cancels, id)
+	s.mu.Unlock()
+}
+
+// run re-ingests the job's pending documents with a bounded worker pool
+// until they are done, the job is cancelled or shutdown begins. Documents
+// not started by then stay pending.
+func (s *ReingestService) run(ctx context.Context, id uint, st *shadowStop) {
+	defer s.untrack(id)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	st.mu.Lock()
+	st.cancel = cancel
+	if st.reason != "" {
+		// Cancelled before it started
+		cancel()
+	}
+	st.mu.Unlock()
+
+	var completed atomic.Int64
+	defer func() {
+		if r := recover(); r != nil {
+			logrus.WithField("reingest_job_id", id).Errorf("Re-ingestion panicked: %v", r)
+			s.finish(id, ReingestFailed, fmt.Sprintf("internal error: %v", r))
+		}
+		s.evictCache(ctx, id, completed.Load())
+	}()
+
+	var itemIDs []uint
+	err := db.DB.WithContext(ctx).Model(&models.ReingestItem{}).
+		Where("job_id = ? AND status = ?", id, reingestPending).
+		Order("id").
+		Pluck("id", &itemIDs).Error
+	if err != nil {
+		logrus.WithError(err).WithField("reingest_job_id", id).Error("Failed to list documents to re-ingest")
+		s.finish(id, ReingestFailed, err.Error())
+		return
+	}
+
+	watchDone := make(chan struct{})
+	defer close(watchDone)
+	go s.watch(ctx, id, st, watchDone)
+
+	queue := make(chan uint)
+	var wg sync.WaitGroup
+	for i := 0; i < s.cfg.ReingestConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for itemID := range queue {
+				if s.reingest(ctx, id, itemID) {
+					completed.Add(1)
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, itemID := range itemIDs {
+		select {
+		case <-ctx.Done():
+			break feed
+		case queue <- itemID:
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	switch st.stopReason() {
+	case ReingestCancelled:
+		s.finish(id, ReingestCancelled, "")
+	case shadowStopShutdown:
+		logrus.WithField("reingest_job_id", id).Warn("Re-ingestion interrupted by shutdown")
+		s.finish(id, ReingestFailed, "server shutting down")
+	default:
+		s.finish(id, ReingestCompleted, "")
+		logrus.WithFields(logrus.Fields{
+			"reingest_job_id": id,
+			"completed":       completed.Load(),
+		}).Info("Re-ingestion completed")
+	}
+}
+
+// watch stops a job when it is cancelled through another instance or
+// shutdown begins, until done is closed
+func (s *ReingestService) watch(ctx context.Context, id uint, st *shadowStop, done <-chan struct{}) {
+	ticker := time.NewTicker(reingestCancelPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-s.lifecycle.Context().Done():
+			st.stop(shadowStopShutdown)
+			return
+		case <-ticker.C:
+		}
+
+		var status string
+		err := db.DB.WithContext(ctx).Model(&models.ReingestJob{}).Where("id = ?", id).Pluck("status", &status).Error
+		if err != nil {
+			continue
+		}
+		if status != ReingestRunning {
+			st.stop(ReingestCancelled)
+			return
+		}
+	}
+}
+
+// reingest sends one document's kept text to the RAG service, replacing its
+// chunks, retrying failures worth retrying up to REINGEST_MAX_ATTEMPTS
+// times. A document that can't be re-ingested is marked failed like any
+// failed ingestion. It reports whether the document was re-ingested.
+func (s *ReingestService) reingest(ctx context.Context, jobID, itemID uint) bool {
+	var item models.ReingestItem
+	if err := db.DB.WithContext(ctx).First(&item, itemID).Error; err != nil {
+		if ctx.Err() == nil {
+			logrus.WithError(err).WithField("reingest_item_id", itemID).Warn("Failed to load re-ingestion item")
+		}
+		return false
+	}
+
+	logger := logrus.WithFields(logrus.Fields{"reingest_job_id": jobID, "doc_id": item.DocumentID})
+
+	var doc models.Document
+	if err := db.DB.WithContext(ctx).Preload("Collection").First(&doc, item.DocumentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.settle(jobID, item.ID, reingestSkipped, reingestDocumentChanged, 0, "", false)
+		} else if ctx.Err() == nil {
+			logger.WithError(err).Warn("Failed to load document for re-ingestion")
+		}
+		return false
+	}
+	if !canQueue(&doc) {
+		s.settle(jobID, item.ID, reingestSkipped, reingestSourceMissing, 0, "", false)
+		return false
+	}
+
+	// Claim the document the way the ingestion queue does, so it isn't
+	// ingested twice at once
+	claimed := db.DB.WithContext(ctx).Model(&models.Document{}).
+		Where("id = ? AND status = ?", doc.ID, doc.Status).
+		Where("status IN ?", []string{"completed", "failed"}).
+		Updates(map[string]interface{}{
+			"status":           "processing",
+			"error_code":       "",
+			"error_message":    "",
+			"progress_percent": nil,
+			"chunks_processed": 0,
+			"chunks_total":     0,
+		})
+	if claimed.Error != nil {
+		if ctx.Err() == nil {
+			logger.WithError(claimed.Error).Warn("Failed to claim document for re-ingestion")
+		}
+		return false
+	}
+	if claimed.RowsAffected == 0 {
+		s.settle(jobID, item.ID, reingestSkipped, reingestDocumentChanged, 0, "", false)
+		return false
+	}
+	s.start(jobID, item.ID)
+
+	fields := map[string]string{"visibility": doc.Visibility}
+	if doc.Collection != nil {
+		fields["collection"] = doc.Collection.Name
+	}
+	// replaces lets the RAG service drop the document's old chunks
+	if doc.VectorStoreID != "" {
+		fields["replaces"] = doc.VectorStoreID
+	}
+	addIngestOptionFields(fields, doc.IngestOptions)
+
+	delay := reingestRetryDelay
+	for attempt := 1; ; attempt++ {
+		ingestResp, err := s.documents.ingestWithProgress(ctx, doc.ID, RAGIngestRequest{
+			FileName: doc.FileName,
+			Content:  strings.NewReader(doc.ExtractedText),
+			Fields:   fields,
+		})
+		if err == nil {
+			s.documents.completeIngestion(ctx, doc.ID, doc.FileName, ingestResp)
+			s.settle(jobID, item.ID, reingestCompleted, "", attempt, "", true)
+			return true
+		}
+
+		if ctx.Err() != nil {
+			// Stopped mid-way: the old chunks were never replaced
+			db.DB.Model(&models.Document{}).Where("id = ?", doc.ID).Updates(map[string]interface{}{
+				"status":        doc.Status,
+				"error_code":    doc.ErrorCode,
+				"error_message": doc.ErrorMessage,
+			})
+			s.settle(jobID, item.ID, reingestPending, "", attempt, "", true)
+			return false
+		}
+		if !retryableIngestError(err) || attempt >= s.cfg.ReingestMaxAttempts {
+			err = s.documents.failIngestion(doc.ID, doc.FileName, IngestErrorFailed, err, "Failed to re-ingest document")
+			s.settle(jobID, item.ID, reingestFailed, "", attempt, err.Error(), true)
+			return false
+		}
+
+		logger.WithError(err).WithField("attempt", attempt).Warn("Failed to re-ingest document, retrying")
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// start marks a claimed document of a job in flight
+func (s *ReingestService) start(jobID, itemID uint) {
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.ReingestItem{}).Where("id = ?", itemID).Update("status", reingestInFlight).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.ReingestJob{}).Where("id = ?", jobID).
+			UpdateColumn("in_flight", gorm.Expr("in_flight + 1")).Error
+	})
+	if err != nil {
+		logrus.WithError(err).WithField("reingest_job_id", jobID).Warn("Failed to update re-ingestion progress")
+	}
+}
+
+// settle records the outcome of a document of a job and counts it. An
+// outcome of pending puts a document stopped mid-way back. inFlight is set
+// for documents that were started.
+func (s *ReingestService) settle(jobID, itemID uint, status, reason string, attempts int, errMessage string, inFlight bool) {
+	if len(errMessage) > maxDocumentErrorLength {
+		errMessage = errMessage[:maxDocumentErrorLength]
+	}
+
+	counters := map[string]interface{}{}
+	if inFlight {
+		counters["in_flight"] = gorm.Expr("in_flight - 1")
+	}
+	switch status {
+	case reingestCompleted:
+		counters["completed"] = gorm.Expr("completed + 1")
+	case reingestFailed:
+		counters["failed"] = gorm.Expr("failed + 1")
+	case reingestSkipped:
+		counters["skipped"] = gorm.Expr("skipped + 1")
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.ReingestItem{}).Where("id = ?", itemID).Updates(map[string]interface{}{
+			"status":   status,
+			"reason":   reason,
+			"attempts": attempts,
+			"error":    errMessage,
+		}).Error
+		if err != nil || len(counters) == 0 {
+			return err
+		}
+		return tx.Model(&models.ReingestJob{}).Where("id = ?", jobID).UpdateColumns(counters).Error
+	})
+	if err != nil {
+		logrus.WithError(err).WithField("reingest_job_id", jobID).Warn("Failed to update re-ingestion progress")
+	}
+}
+
+// finish records the final state of a job. A cancelled job keeps who
+// cancelled it.
+func (s *ReingestService) finish(id uint, status, errMessage string) {
+	now := time.Now().UTC()
+	err := db.DB.Model(&models.ReingestJob{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":       status,
+		"error":        errMessage,
+		"completed_at": &now,
+	}).Error
+	if err != nil {
+		logrus.WithError(err).WithField("reingest_job_id", id).Error("Failed to update re-ingestion job")
+	}
+}
+
+// evictCache drops cached answers once a job has re-ingested documents, since
+// they may quote chunks that no longer exist. This happens once per job
+// rather than per document, and even if the job was stopped.
+func (s *ReingestService) evictCache(ctx context.Context, id uint, completed int64) {
+	if completed == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), reingestEvictTimeout)
+	defer cancel()
+	evictQueryCache(ctx)
+	logrus.WithFields(logrus.Fields{
+		"reingest_job_id": id,
+		"completed":       completed,
+	}).Info("Evicted cached answers after re-ingestion")
+}
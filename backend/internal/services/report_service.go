@@ -0,0 +1,253 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/cache"
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/notify"
+	"github.com/sirupsen/logrus"
+)
+
+// Report triggers
+const (
+	ReportManual    = "manual"
+	ReportScheduled = "scheduled"
+)
+
+// reportTopN is the number of entries in the top query lists
+const reportTopN = 10
+
+// reportTemplate renders a report as a self-contained HTML email
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: Arial, sans-serif; color: #1f2937;">
+  <h2>Support Assistant Report</h2>
+  <p>{{.PeriodStart.Format "Jan 2, 2006"}} &ndash; {{.PeriodEnd.Format "Jan 2, 2006"}}</p>
+  <table cellpadding="6" style="border-collapse: collapse;">
+    <tr><td>Queries</td><td><strong>{{.QueryVolume}}</strong></td></tr>
+    <tr><td>Feedback received</td><td><strong>{{.FeedbackCount}}</strong></td></tr>
+    <tr><td>Positive feedback rate</td><td><strong>{{printf "%.1f" .PositiveFeedbackRate}}%</strong></td></tr>
+    <tr><td>Latency p95</td><td><strong>{{printf "%.0f" .LatencyP95Ms}} ms</strong></td></tr>
+    <tr><td>Tokens used</td><td><strong>{{.TokensUsed}}</strong></td></tr>
+    <tr><td>Estimated cost</td><td><strong>${{printf "%.2f" .EstimatedCost}}</strong></td></tr>
+  </table>
+  <h3>Top queries</h3>
+  {{if .TopQueries}}<ol>{{range .TopQueries}}<li>{{.Query}} ({{.Count}})</li>{{end}}</ol>{{else}}<p>No queries in this period.</p>{{end}}
+  <h3>Top negative-feedback topics</h3>
+  {{if .TopNegativeTopics}}<ol>{{range .TopNegativeTopics}}<li>{{.Query}} ({{.Count}})</li>{{end}}</ol>{{else}}<p>No negative feedback in this period.</p>{{end}}
+</body>
+</html>
+`))
+
+// GenerateReport computes the key metrics for a period and stores them as a report
+func (s *AnalyticsService) GenerateReport(ctx context.Context, start, end time.Time, trigger string) (*models.Report, error) {
+	data := models.ReportData{
+		PeriodStart: start.UTC(),
+		PeriodEnd:   end.UTC(),
+	}
+
+	inPeriod := "created_at >= ? AND created_at < ?"
+
+	if err := analyticsQueries().Where(inPeriod, start, end).Count(&data.QueryVolume).Error; err != nil {
+		return nil, fmt.Errorf("failed to count queries: %w", err)
+	}
+
+	var positive int64
+	db.DB.Model(&models.Feedback{}).Where(inPeriod, start, end).Count(&data.FeedbackCount)
+	db.DB.Model(&models.Feedback{}).Where(inPeriod, start, end).Where("score = ?", 1).Count(&positive)
+	if data.FeedbackCount > 0 {
+		data.PositiveFeedbackRate = float64(positive) / float64(data.FeedbackCount) * 100
+	}
+
+	var err error
+	data.TopQueries, err = topQueries(analyticsQueries().Where(inPeriod, start, end), reportTopN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top queries: %w", err)
+	}
+
+	data.TopNegativeTopics, err = topQueries(db.DB.Model(&models.Feedback{}).
+		Joins("JOIN chat_queries ON chat_queries.id = feedbacks.query_id AND chat_queries.synthetic = ?", false).
+		Where("feedbacks.score = ? AND feedbacks.created_at >= ? AND feedbacks.created_at < ?", -1, start, end), reportTopN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get negative feedback topics: %w", err)
+	}
+
+	if data.LatencyP95Ms, err = periodLatencyP95(start, end); err != nil {
+		return nil, fmt.Errorf("failed to compute latency: %w", err)
+	}
+
+	analyticsQueries().Where(inPeriod, start, end).Select("COALESCE(SUM(tokens_used), 0)").Scan(&data.TokensUsed)
+	data.EstimatedCost = float64(data.TokensUsed) / 1000 * s.cfg.CostPer1KTokens
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode report: %w", err)
+	}
+
+	var html bytes.Buffer
+	if err := reportTemplate.Execute(&html, data); err != nil {
+		return nil, fmt.Errorf("failed to render report: %w", err)
+	}
+
+	report := models.Report{
+		PeriodStart: data.PeriodStart,
+		PeriodEnd:   data.PeriodEnd,
+		Trigger:     trigger,
+		Data:        string(encoded),
+		HTML:        html.String(),
+		Summary:     &data,
+	}
+	if err := db.DB.Create(&report).Error; err != nil {
+		return nil, fmt.Errorf("failed to save report: %w", err)
+	}
+
+	return &report, nil
+}
+
+// GetReport returns a stored report with its decoded metrics
+func (s *AnalyticsService) GetReport(ctx context.Context, id uint) (*models.Report, error) {
+	var report models.Report
+
+	if err := db.DB.First(&report, id).Error; err != nil {
+		return nil, notFoundError("report", err)
+	}
+
+	var data models.ReportData
+	if err := json.Unmarshal([]byte(report.Data), &data); err != nil {
+		return nil, fmt.Errorf("failed to decode report: %w", err)
+	}
+	report.Summary = &data
+
+	return &report, nil
+}
+
+// periodLatencyP95 returns the 95th percentile latency of queries in [start, end)
+func periodLatencyP95(start, end time.Time) (float64, error) {
+	query := analyticsQueries().Where("created_at >= ? AND created_at < ?", start, end)
+
+	if isPostgres() {
+		var p95 float64
+		err := query.Select("COALESCE(PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY latency_ms), 0)").Row().Scan(&p95)
+		return p95, err
+	}
+
+	var latencies []float64
+	if err := query.Order("latency_ms ASC").Pluck("latency_ms", &latencies).Error; err != nil {
+		return 0, err
+	}
+	return percentile(latencies, 0.95), nil
+}
+
+// ReportScheduler periodically generates a report and emails it to the configured recipients
+type ReportScheduler struct {
+	analytics   *AnalyticsService
+	email       *notify.EmailSender
+	interval    time.Duration
+	period      time.Duration
+	recipients  []string
+	baseURL     string
+	maxAttempts int
+	backoff     time.Duration
+}
+
+func NewReportScheduler(cfg *config.Config, analytics *AnalyticsService, email *notify.EmailSender) *ReportScheduler {
+	return &ReportScheduler{
+		analytics:   analytics,
+		email:       email,
+		interval:    time.Duration(cfg.ReportIntervalH) * time.Hour,
+		period:      time.Duration(cfg.ReportPeriodDays) * 24 * time.Hour,
+		recipients:  cfg.ReportRecipients,
+		baseURL:     cfg.ReportBaseURL,
+		maxAttempts: 3,
+		backoff:     time.Minute,
+	}
+}
+
+// Start runs the scheduler until ctx is cancelled. It does nothing when no
+// interval, recipients or SMTP server are configured.
+func (r *ReportScheduler) Start(ctx context.Context) {
+	if r.interval <= 0 || len(r.recipients) == 0 || !r.email.Enabled() {
+		logrus.Info("Scheduled reports disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.run(ctx)
+			}
+		}
+	}()
+
+	logrus.WithFields(logrus.Fields{
+		"interval":   r.interval,
+		"recipients": len(r.recipients),
+	}).Info("Report scheduler started")
+}
+
+// run generates and emails one report; failures are logged and never stop the scheduler
+func (r *ReportScheduler) run(ctx context.Context) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			logrus.Errorf("Report scheduler panicked: %v", rec)
+		}
+	}()
+
+	end := time.Now().UTC().Truncate(time.Hour)
+
+	// Only one instance sends each scheduled report
+	if cache.Client != nil {
+		acquired, err := cache.SetNX(ctx, fmt.Sprintf("report:scheduled:%d", end.Unix()), true, r.interval/2)
+		if err == nil && !acquired {
+			return
+		}
+	}
+
+	report, err := r.analytics.GenerateReport(ctx, end.Add(-r.period), end, ReportScheduled)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to generate scheduled report")
+		return
+	}
+
+	subject := fmt.Sprintf("Support Assistant report: %s - %s",
+		report.PeriodStart.Format("Jan 2"), report.PeriodEnd.Format("Jan 2, 2006"))
+	link := fmt.Sprintf(`<p><a href="%s/api/admin/reports/%d?format=html">View this report online</a></p>`, r.baseURL, report.ID)
+	html := report.HTML + link
+
+	for attempt := 1; attempt <= r.maxAttempts; attempt++ {
+		err = r.email.SendHTML(r.recipients, subject, html)
+		if err == nil {
+			logrus.WithField("report_id", report.ID).Info("Scheduled report sent")
+			return
+		}
+
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"report_id": report.ID,
+			"attempt":   attempt,
+		}).Warn("Failed to email report")
+
+		if attempt < r.maxAttempts {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(r.backoff * time.Duration(1<<uint(attempt-1))):
+			}
+		}
+	}
+
+	logrus.WithError(err).WithField("report_id", report.ID).Error("Giving up on emailing report")
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/langdetect"
+	"github.com/ai-support-assistant/backend/internal/middleware"
+	"github.com/ai-support-assistant/backend/internal/ragclient"
+	"github.com/sirupsen/logrus"
+)
+
+// Response language modes
+const (
+	ResponseLanguageMatchUser = "match_user"
+	ResponseLanguageFixed     = "fixed"
+	ResponseLanguageAuto      = "auto"
+)
+
+// minTranslationBudget is the least time left before the request deadline
+// worth spending on a translation
+const minTranslationBudget = 500 * time.Millisecond
+
+// responseLanguage returns the language answers to a query detected in
+// language must be in, or "" to leave it to the RAG service
+func (s *QueryService) responseLanguage(language string) string {
+	switch s.cfg.ResponseLanguageMode {
+	case ResponseLanguageMatchUser:
+		if language == langdetect.Undetermined {
+			return ""
+		}
+		return language
+	case ResponseLanguageFixed:
+		return s.cfg.ResponseLanguage
+	default:
+		return ""
+	}
+}
+
+// enforceResponseLanguage checks an answer came back in the target language
+// and, with RESPONSE_TRANSLATION set, has the RAG service translate it if
+// not. The translation is skipped when too little of the request deadline
+// is left, and a failed translation leaves the answer as it was; it reports
+// whether the answer was translated.
+func (s *QueryService) enforceResponseLanguage(ctx context.Context, target string, resp *RAGQueryResponse) bool {
+	if target == "" || resp.Response == "" {
+		return false
+	}
+
+	detected := langdetect.Detect(resp.Response)
+	if detected == langdetect.Undetermined || detected == target {
+		middleware.RecordResponseLanguage("match")
+		return false
+	}
+
+	logger := logrus.WithFields(logrus.Fields{
+		"response_language": target,
+		"detected_language": detected,
+	})
+	if !s.cfg.ResponseTranslation {
+		middleware.RecordResponseLanguage("mismatch")
+		logger.Warn("Answer not in the response language")
+		return false
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < minTranslationBudget {
+		middleware.RecordResponseLanguage("mismatch")
+		logger.Warn("Answer not in the response language and no time left to translate it")
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(s.cfg.TranslationTimeoutMs)*time.Millisecond)
+	defer cancel()
+
+	translated, err := s.verifier.Translate(ctx, ragclient.Translation{
+		Text:   resp.Response,
+		Source: detected,
+		Target: target,
+	})
+	if err != nil {
+		middleware.RecordResponseLanguage("error")
+		logger.WithError(err).Warn("Failed to translate answer")
+		return false
+	}
+
+	middleware.RecordResponseLanguage("translated")
+	logger.Info("Translated answer into the response language")
+	resp.Response = translated
+	return true
+}
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/audit"
+	"github.com/ai-support-assistant/backend/internal/cache"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/lifecycle"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/webhook"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	// runtimeRedisTimeout bounds each Redis section so the endpoint stays cheap
+	// even when Redis is slow
+	runtimeRedisTimeout = 2 * time.Second
+
+	// runtimeScanCount and runtimeScanIterations cap a key scan at about
+	// 10,000 keys examined
+	runtimeScanCount      = 500
+	runtimeScanIterations = 20
+
+	// runtimeMaxBuckets caps the rate limit buckets whose counts are read
+	runtimeMaxBuckets = 5000
+
+	// runtimeMemorySample is how many cache keys are sized to estimate memory
+	runtimeMemorySample = 100
+
+	rateLimitKeyPrefix = "ratelimit:"
+)
+
+// queryCachePattern matches query cache entries ("query:" and a 16 character
+// hash), but not their refresh locks or no-cache markers
+var queryCachePattern = "query:" + strings.Repeat("?", 16)
+
+// RuntimeService reports live server state for operators: rate limit
+// buckets, the query cache, circuit breakers and background queues
+type RuntimeService struct {
+	health     *HealthService
+	limiter    *RAGLimiter
+	queries    *QueryService
+	jobs       *QueryJobService
+	dispatcher *webhook.Dispatcher
+	lifecycle  *lifecycle.Manager
+}
+
+func NewRuntimeService(health *HealthService, limiter *RAGLimiter, queries *QueryService, jobs *QueryJobService, dispatcher *webhook.Dispatcher, lc *lifecycle.Manager) *RuntimeService {
+	return &RuntimeService{
+		health:     health,
+		limiter:    limiter,
+		queries:    queries,
+		jobs:       jobs,
+		dispatcher: dispatcher,
+		lifecycle:  lc,
+	}
+}
+
+// GetState returns a snapshot of the runtime state with the top N rate limit
+// buckets. Redis sections that fail are left null, with the reason in Errors.
+func (s *RuntimeService) GetState(ctx context.Context, topN int) *models.RuntimeState {
+	state := &models.RuntimeState{
+		GeneratedAt:     time.Now().UTC(),
+		CircuitBreakers: s.health.Breakers(),
+		RAGQueue:        s.limiter.Status(),
+		WorkerQueues:    s.workerQueues(),
+		Errors:          make(map[string]string),
+	}
+
+	if cache.Client == nil {
+		state.Errors["redis"] = "redis is not configured"
+		return state
+	}
+
+	var err error
+	if state.RateLimits, err = s.rateLimitBuckets(ctx, topN); err != nil {
+		logrus.WithError(err).Warn("Failed to read rate limit buckets")
+		state.Errors["rate_limits"] = err.Error()
+	}
+	if state.QueryCache, err = s.queryCacheStats(ctx); err != nil {
+		logrus.WithError(err).Warn("Failed to read query cache stats")
+		state.Errors["query_cache"] = err.Error()
+	}
+
+	return state
+}
+
+// rateLimitBuckets scans a bounded number of rate limit keys and returns the
+// topN with the highest counts
+func (s *RuntimeService) rateLimitBuckets(ctx context.Context, topN int) (*models.RateLimitBuckets, error) {
+	ctx, cancel := context.WithTimeout(ctx, runtimeRedisTimeout)
+	defer cancel()
+
+	keys, complete, err := cache.ScanKeysBounded(ctx, rateLimitKeyPrefix+"*", runtimeScanCount, runtimeScanIterations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan rate limit keys: %w", err)
+	}
+	if len(keys) > runtimeMaxBuckets {
+		keys, complete = keys[:runtimeMaxBuckets], false
+	}
+
+	counts, ttls, err := cache.Counters(ctx, keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rate limit counts: %w", err)
+	}
+
+	buckets := make([]models.RateLimitBucket, 0, len(keys))
+	for i, key := range keys {
+		policy, subject, ok := parseRateLimitKey(key)
+		if !ok || counts[i] <= 0 {
+			continue
+		}
+		reset := 0
+		if ttls[i] > 0 {
+			reset = int(ttls[i].Seconds())
+		}
+		buckets = append(buckets, models.RateLimitBucket{
+			Key:          key,
+			Policy:       policy,
+			Subject:      subject,
+			Count:        counts[i],
+			ResetSeconds: reset,
+		})
+	}
+
+	sort.Slice(buckets, func(i, j int) bool {
+		if buckets[i].Count != buckets[j].Count {
+			return buckets[i].Count > buckets[j].Count
+		}
+		return buckets[i].Key < buckets[j].Key
+	})
+	if len(buckets) > topN {
+		buckets = buckets[:topN]
+	}
+
+	return &models.RateLimitBuckets{
+		Buckets:  buckets,
+		Scanned:  len(keys),
+		Complete: complete,
+	}, nil
+}
+
+// queryCacheStats counts query cache entries and estimates their memory from
+// a sample. When the scan is capped, the count is extrapolated from the share
+// of the keyspace examined.
+func (s *RuntimeService) queryCacheStats(ctx context.Context) (*models.QueryCacheStats, error) {
+	ctx, cancel := context.WithTimeout(ctx, runtimeRedisTimeout)
+	defer cancel()
+
+	dbSize, err := cache.DBSize(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key count: %w", err)
+	}
+	usedMemory, err := cache.UsedMemory(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read memory usage: %w", err)
+	}
+
+	keys, complete, err := cache.ScanKeysBounded(ctx, queryCachePattern, runtimeScanCount, runtimeScanIterations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan query cache keys: %w", err)
+	}
+
+	stats := &models.QueryCacheStats{
+		Keys:                 int64(len(keys)),
+		Exact:                complete,
+		RedisKeys:            dbSize,
+		RedisUsedMemoryBytes: usedMemory,
+	}
+	if examined := int64(runtimeScanCount * runtimeScanIterations); !complete && examined < dbSize {
+		stats.Keys = stats.Keys * dbSize / examined
+	}
+
+	sample := keys
+	if len(sample) > runtimeMemorySample {
+		sample = sample[:runtimeMemorySample]
+	}
+	if len(sample) > 0 {
+		sampled, err := cache.MemoryUsage(ctx, sample)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sample cache memory: %w", err)
+		}
+		stats.EstimatedBytes = sampled * stats.Keys / int64(len(sample))
+		if len(sample) < len(keys) {
+			stats.Exact = false
+		}
+	}
+
+	return stats, nil
+}
+
+// workerQueues reports the backlog of each background worker pool
+func (s *RuntimeService) workerQueues() []models.WorkerQueueState {
+	running := s.lifecycle.Running()
+
+	webhookDepth, webhookCapacity := s.dispatcher.QueueDepth()
+	jobDepth, jobCapacity := s.jobs.QueueDepth()
+	refreshing, refreshCapacity := s.queries.RefreshSlots()
+
+	queues := []models.WorkerQueueState{
+		{Name: "webhooks", Depth: webhookDepth, Capacity: webhookCapacity, Running: running["webhook_worker"]},
+		{Name: "query_jobs", Depth: jobDepth, Capacity: jobCapacity, Running: running["query_job_worker"]},
+		{Name: "cache_refresh", Capacity: refreshCapacity, Running: refreshing},
+	}
+
+	// Other tasks, such as ingestion and crawls, run unqueued
+	names := make([]string, 0, len(running))
+	for name := range running {
+		switch name {
+		case "webhook_worker", "query_job_worker", "cache_refresh":
+		default:
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		queues = append(queues, models.WorkerQueueState{Name: name, Running: running[name]})
+	}
+
+	return queues
+}
+
+// ResetRateLimit clears one rate limit bucket, given as "policy:subject" or
+// the full "ratelimit:policy:subject" key, and records it in the audit log
+func (s *RuntimeService) ResetRateLimit(ctx context.Context, key string) (*models.RateLimitBucket, error) {
+	if !strings.HasPrefix(key, rateLimitKeyPrefix) {
+		key = rateLimitKeyPrefix + key
+	}
+	policy, subject, ok := parseRateLimitKey(key)
+	if !ok {
+		return nil, fmt.Errorf("%w: key must be policy:subject", ErrInvalidRequest)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, runtimeRedisTimeout)
+	defer cancel()
+
+	counts, ttls, err := cache.Counters(ctx, []string{key})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rate limit bucket: %w", err)
+	}
+	if counts[0] <= 0 {
+		return nil, fmt.Errorf("rate limit bucket %w", ErrNotFound)
+	}
+
+	bucket := &models.RateLimitBucket{
+		Key:          key,
+		Policy:       policy,
+		Subject:      subject,
+		Count:        counts[0],
+		ResetSeconds: int(ttls[0].Seconds()),
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := audit.Record(ctx, tx, "ratelimit.reset", "rate_limit", key, bucket, nil); err != nil {
+			return err
+		}
+		return cache.Delete(ctx, key)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to reset rate limit: %w", err)
+	}
+
+	return bucket, nil
+}
+
+// parseRateLimitKey splits a "ratelimit:policy:subject" key
+func parseRateLimitKey(key string) (string, string, bool) {
+	policy, subject, ok := strings.Cut(strings.TrimPrefix(key, rateLimitKeyPrefix), ":")
+	if !ok || policy == "" || subject == "" {
+		return "", "", false
+	}
+	return policy, subject, true
+}
@@ -0,0 +1,342 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/crypto"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxSearchQueryLength caps the search text
+	maxSearchQueryLength = 200
+
+	// searchHeadlineOptions configures ts_headline snippets
+	searchHeadlineOptions = "StartSel=<mark>, StopSel=</mark>, MinWords=10, MaxWords=30, MaxFragments=2"
+
+	// searchSnippetRunes is how much text surrounds the first match in
+	// snippets built without Postgres
+	searchSnippetRunes = 120
+
+	// searchPhraseBoost lifts rows containing the search text as a phrase
+	// above rows that only contain its words; text ranks stay below it
+	searchPhraseBoost = 1.0
+
+	// searchMaxCandidates caps the matches ranked for a search, best text
+	// rank first; the total still counts every match
+	searchMaxCandidates = 1000
+)
+
+// SearchFilter narrows a conversation search. Zero values match everything.
+type SearchFilter struct {
+	Query     string
+	SessionID string
+	From      time.Time
+	To        time.Time
+	Limit     int
+	Offset    int
+}
+
+// SearchService searches past conversations by their query and response text
+type SearchService struct {
+	cfg *config.Config
+}
+
+func NewSearchService(cfg *config.Config) *SearchService {
+	return &SearchService{cfg: cfg}
+}
+
+// SearchConversations returns past queries matching the search text, best
+// match first, with highlighted snippets. With auth enabled, only the
+// requester's own conversations are searched. Postgres matches with
+// full-text search; other dialects fall back to substring matching.
+func (s *SearchService) SearchConversations(ctx context.Context, filter SearchFilter, requesterID string) ([]models.ConversationSearchResult, int64, error) {
+	text := strings.TrimSpace(filter.Query)
+	if text == "" {
+		return nil, 0, validationError("q is required")
+	}
+	if utf8.RuneCountInString(text) > maxSearchQueryLength {
+		return nil, 0, validationError("q must be at most %d characters", maxSearchQueryLength)
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.To.Before(filter.From) {
+		return nil, 0, validationError("to must not be before from")
+	}
+
+	// Conversations are only indexed in plaintext
+	if crypto.Active() != nil {
+		return nil, 0, fmt.Errorf("%w: conversation search is unavailable while encryption at rest is enabled", ErrInvalidRequest)
+	}
+
+	query := db.DB.WithContext(ctx).Model(&models.ChatQuery{})
+	if s.cfg.AuthEnabled {
+		if requesterID == "" {
+			return nil, 0, fmt.Errorf("%w: authentication required", ErrForbidden)
+		}
+		query = query.Where("user_id = ?", requesterID)
+	}
+	if filter.SessionID != "" {
+		query = query.Where("session_id = ?", filter.SessionID)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+
+	if isPostgres() {
+		return searchFullText(query, text, filter.Limit, filter.Offset)
+	}
+	return searchSubstring(query, text, filter.Limit, filter.Offset)
+}
+
+// searchCandidate is a matching row before ranking. Rank starts as its
+// text rank in [0, 1).
+type searchCandidate struct {
+	ID        uint
+	SessionID string
+	UserID    string
+	Query     string
+	Response  string
+	CreatedAt time.Time
+	Rank      float64
+}
+
+// searchFullText matches with websearch_to_tsquery, so quoted phrases, OR
+// and -exclusions work, taking cover density as the text rank. Snippets are
+// only highlighted for the page returned.
+func searchFullText(query *gorm.DB, text string, limit, offset int) ([]models.ConversationSearchResult, int64, error) {
+	query = query.
+		Joins("CROSS JOIN websearch_to_tsquery(?::regconfig, ?) AS tsq", models.SearchConfig, text).
+		Where("search_vector @@ tsq").
+		Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count search results: %w", err)
+	}
+
+	results := []models.ConversationSearchResult{}
+	if total == 0 {
+		return results, 0, nil
+	}
+
+	// Normalization 32 scales the rank to rank/(rank+1)
+	var candidates []searchCandidate
+	err := query.
+		Select("id, session_id, user_id, query, response, created_at, ts_rank_cd(search_vector, tsq, 32) AS rank").
+		Order("rank DESC, created_at DESC, id DESC").
+		Limit(searchMaxCandidates).
+		Scan(&candidates).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search conversations: %w", err)
+	}
+
+	page := rankSearchCandidates(candidates, normalizeSearchText(text), limit, offset)
+	if len(page) == 0 {
+		return results, total, nil
+	}
+	ids := make([]uint, len(page))
+	for i, candidate := range page {
+		ids[i] = candidate.ID
+	}
+
+	var headlines []models.ConversationSearchResult
+	err = query.
+		Select(fmt.Sprintf(`id AS query_id,
+			ts_headline('%[1]s', %[2]s, tsq, '%[4]s') AS query_headline,
+			ts_headline('%[1]s', %[3]s, tsq, '%[4]s') AS response_headline`,
+			models.SearchConfig, escapedHTMLColumn("query"), escapedHTMLColumn("response"), searchHeadlineOptions)).
+		Where("id IN ?", ids).
+		Scan(&headlines).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to highlight search results: %w", err)
+	}
+	byID := make(map[uint]models.ConversationSearchResult, len(headlines))
+	for _, headline := range headlines {
+		byID[headline.QueryID] = headline
+	}
+
+	for _, candidate := range page {
+		headline := byID[candidate.ID]
+		results = append(results, searchResult(candidate, headline.QueryHeadline, headline.ResponseHeadline))
+	}
+	return results, total, nil
+}
+
+// escapedHTMLColumn escapes a column's HTML in SQL, so snippets can be
+// rendered with only the <mark> highlights as markup
+func escapedHTMLColumn(column string) string {
+	return fmt.Sprintf("replace(replace(replace(coalesce(%s, ''), '&', '&amp;'), '<', '&lt;'), '>', '&gt;')", column)
+}
+
+// searchSubstring matches rows containing every word of the search text,
+// for development databases without full-text search, taking how often
+// the words occur as the text rank
+func searchSubstring(query *gorm.DB, text string, limit, offset int) ([]models.ConversationSearchResult, int64, error) {
+	phrase := normalizeSearchText(text)
+	terms := strings.Fields(phrase)
+	for _, term := range terms {
+		pattern := likePattern(term)
+		query = query.Where(`(LOWER(query) LIKE ? ESCAPE '\' OR LOWER(response) LIKE ? ESCAPE '\')`, pattern, pattern)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count search results: %w", err)
+	}
+
+	var candidates []searchCandidate
+	err := query.
+		Select("id, session_id, user_id, query, response, created_at").
+		Order("created_at DESC, id DESC").
+		Limit(searchMaxCandidates).
+		Scan(&candidates).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search conversations: %w", err)
+	}
+
+	for i := range candidates {
+		occurrences := 0
+		for _, term := range terms {
+			occurrences += strings.Count(normalizeSearchText(candidates[i].Query), term) +
+				strings.Count(normalizeSearchText(candidates[i].Response), term)
+		}
+		candidates[i].Rank = float64(occurrences) / float64(occurrences+1)
+	}
+
+	page := rankSearchCandidates(candidates, phrase, limit, offset)
+	results := make([]models.ConversationSearchResult, 0, len(page))
+	for _, candidate := range page {
+		results = append(results, searchResult(candidate, highlightSnippet(candidate.Query, terms), highlightSnippet(candidate.Response, terms)))
+	}
+	return results, total, nil
+}
+
+// rankSearchCandidates adds searchPhraseBoost to the rank of candidates
+// whose query or response contains phrase, so they come before any that
+// only share words with it, and returns the page at offset, best first
+func rankSearchCandidates(candidates []searchCandidate, phrase string, limit, offset int) []searchCandidate {
+	for i := range candidates {
+		if strings.Contains(normalizeSearchText(candidates[i].Query), phrase) ||
+			strings.Contains(normalizeSearchText(candidates[i].Response), phrase) {
+			candidates[i].Rank += searchPhraseBoost
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Rank != b.Rank {
+			return a.Rank > b.Rank
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID > b.ID
+	})
+
+	if offset >= len(candidates) {
+		return nil
+	}
+	end := len(candidates)
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+	return candidates[offset:end]
+}
+
+// normalizeSearchText lowercases text and collapses its whitespace, dropping
+// quotes, so phrases match however they were typed
+func normalizeSearchText(text string) string {
+	return strings.ToLower(strings.Join(strings.Fields(strings.ReplaceAll(text, `"`, " ")), " "))
+}
+
+func searchResult(candidate searchCandidate, queryHeadline, responseHeadline string) models.ConversationSearchResult {
+	return models.ConversationSearchResult{
+		QueryID:          candidate.ID,
+		SessionID:        candidate.SessionID,
+		UserID:           candidate.UserID,
+		Rank:             candidate.Rank,
+		QueryHeadline:    queryHeadline,
+		ResponseHeadline: responseHeadline,
+		CreatedAt:        candidate.CreatedAt,
+	}
+}
+
+// likePattern matches a lowercase term anywhere, escaping LIKE wildcards
+func likePattern(term string) string {
+	return "%" + escapeLike(term) + "%"
+}
+
+// escapeLike escapes the LIKE wildcards in a value matched literally
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// highlightSnippet returns the HTML-escaped text around the first matching
+// term, with every match wrapped in <mark> tags
+func highlightSnippet(text string, terms []string) string {
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	if len(lower) != len(runes) {
+		// Lowercasing changed the length; highlight nothing rather than misalign
+		lower = runes
+	}
+
+	// matchAt returns the length of the term matching at i, or 0
+	matchAt := func(i int) int {
+		for _, term := range terms {
+			t := []rune(term)
+			if i+len(t) <= len(lower) && string(lower[i:i+len(t)]) == term {
+				return len(t)
+			}
+		}
+		return 0
+	}
+
+	start := 0
+	for i := range lower {
+		if matchAt(i) > 0 {
+			start = i
+			break
+		}
+	}
+	start -= searchSnippetRunes / 2
+	if start < 0 {
+		start = 0
+	}
+	end := start + searchSnippetRunes*2
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("... ")
+	}
+	for i := start; i < end; {
+		if n := matchAt(i); n > 0 {
+			if i+n > end {
+				n = end - i
+			}
+			b.WriteString("<mark>" + html.EscapeString(string(runes[i:i+n])) + "</mark>")
+			i += n
+			continue
+		}
+		b.WriteString(html.EscapeString(string(runes[i])))
+		i++
+	}
+	if end < len(runes) {
+		b.WriteString(" ...")
+	}
+	return b.String()
+}
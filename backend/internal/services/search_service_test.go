@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/crypto"
+	"github.com/ai-support-assistant/backend/internal/models"
+)
+
+// searchRows answers a full-text search from seeded candidate rows,
+// recording the arguments of each statement
+type searchRows struct {
+	candidates [][]driver.Value
+	countArgs  []driver.NamedValue
+	headlined  []driver.Value
+}
+
+func (r *searchRows) answer(query string, args []driver.NamedValue) (*fakeRows, error) {
+	switch {
+	case strings.Contains(query, "count("):
+		r.countArgs = args
+		return &fakeRows{columns: []string{"count"}, values: [][]driver.Value{{int64(len(r.candidates))}}}, nil
+	case strings.Contains(query, "ts_headline"):
+		// The page's IDs follow the filters' arguments
+		var values [][]driver.Value
+		for _, arg := range args[len(r.countArgs):] {
+			r.headlined = append(r.headlined, arg.Value)
+			values = append(values, []driver.Value{arg.Value, fmt.Sprintf("query %v", arg.Value), fmt.Sprintf("response %v", arg.Value)})
+		}
+		return &fakeRows{columns: []string{"query_id", "query_headline", "response_headline"}, values: values}, nil
+	}
+	return &fakeRows{columns: []string{"id", "session_id", "user_id", "query", "response", "created_at", "rank"}, values: r.candidates}, nil
+}
+
+func TestSearchConversationsFullText(t *testing.T) {
+	created := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	rows := &searchRows{candidates: [][]driver.Value{
+		{int64(3), "s1", "u1", "Is there a refund for VAT on my order?", "Yes.", created, 0.6},
+		{int64(9), "s1", "u1", "How do I get a VAT  refund?", "Ask support.", created, 0.2},
+	}}
+	fake := useFakeDB(t, rows.answer)
+
+	service := NewSearchService(&config.Config{AuthEnabled: true})
+	results, total, err := service.SearchConversations(context.Background(), SearchFilter{Query: `"VAT refund"`, SessionID: "s1", Limit: 20}, "u1")
+	if err != nil {
+		t.Fatalf("SearchConversations: %v", err)
+	}
+	// Row 9 has the phrase, so it outranks row 3's higher text rank
+	if total != 2 || len(results) != 2 || results[0].QueryID != 9 || results[0].Rank != 1.2 || results[1].Rank != 0.6 {
+		t.Errorf("results = %+v (total %d), want the phrase match first", results, total)
+	}
+	if results[0].QueryHeadline != "query 9" || results[1].ResponseHeadline != "response 3" {
+		t.Errorf("results = %+v, want each row's headlines", results)
+	}
+
+	log := fake.log()
+	for _, want := range []string{
+		"websearch_to_tsquery($1::regconfig, $2)",
+		"search_vector @@ tsq",
+		"ts_rank_cd(search_vector, tsq, 32)",
+		"ts_headline('english'",
+		"user_id = ",
+		"session_id = ",
+		"id IN (",
+	} {
+		if !strings.Contains(log, want) {
+			t.Errorf("statements missing %q:\n%s", want, log)
+		}
+	}
+	if len(rows.countArgs) < 2 || rows.countArgs[0].Value != "english" || rows.countArgs[1].Value != `"VAT refund"` {
+		t.Errorf("tsquery args = %v, want the search config and the raw search text", rows.countArgs)
+	}
+}
+
+func TestSearchConversationsRanksPhrasesFirst(t *testing.T) {
+	// Seed a few hundred matches: some with the phrase, the rest with its
+	// words apart, text ranks drawn at random
+	random := rand.New(rand.NewSource(42))
+	created := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	phrases := map[int64]bool{}
+	rows := &searchRows{}
+	for id := int64(1); id <= 300; id++ {
+		query := "Was the VAT on my order charged twice? I want a refund"
+		if random.Intn(10) == 0 {
+			query = "Can I claim a VAT refund on my order?"
+			phrases[id] = true
+		}
+		at := created.Add(time.Duration(random.Intn(1000)) * time.Minute)
+		rows.candidates = append(rows.candidates, []driver.Value{id, "s1", "u1", query, "Ask support.", at, random.Float64()})
+	}
+	useFakeDB(t, rows.answer)
+	service := NewSearchService(&config.Config{})
+
+	var ranked []models.ConversationSearchResult
+	for offset := 0; offset < 300; offset += 50 {
+		page, total, err := service.SearchConversations(context.Background(), SearchFilter{Query: "vat refund", Limit: 50, Offset: offset}, "")
+		if err != nil {
+			t.Fatalf("SearchConversations at %d: %v", offset, err)
+		}
+		if total != 300 || len(page) != 50 {
+			t.Fatalf("page at %d has %d results of %d, want 50 of 300", offset, len(page), total)
+		}
+		ranked = append(ranked, page...)
+	}
+
+	seen := map[uint]bool{}
+	for i, result := range ranked {
+		if seen[result.QueryID] {
+			t.Fatalf("result %d repeats query %d", i, result.QueryID)
+		}
+		seen[result.QueryID] = true
+		if (i < len(phrases)) != phrases[int64(result.QueryID)] {
+			t.Fatalf("result %d is query %d (phrase %t), want the %d phrase matches first", i, result.QueryID, phrases[int64(result.QueryID)], len(phrases))
+		}
+		if i > 0 && result.Rank > ranked[i-1].Rank {
+			t.Fatalf("result %d ranks %g above result %d's %g", i, result.Rank, i-1, ranked[i-1].Rank)
+		}
+	}
+
+	// Each page highlighted only its own rows
+	if len(rows.headlined) != len(ranked) {
+		t.Errorf("highlighted %d rows for %d results", len(rows.headlined), len(ranked))
+	}
+}
+
+func TestRankSearchCandidates(t *testing.T) {
+	created := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	candidates := []searchCandidate{
+		{ID: 1, Query: "refund of VAT", CreatedAt: created, Rank: 0.9},
+		{ID: 2, Response: "Your VAT\nrefund is on its way", CreatedAt: created, Rank: 0.1},
+		{ID: 3, Query: "vat refund", CreatedAt: created.Add(time.Hour), Rank: 0.1},
+		{ID: 4, Query: "VAT and a refund", CreatedAt: created, Rank: 0.5},
+	}
+
+	page := rankSearchCandidates(candidates, normalizeSearchText(`"VAT Refund"`), 2, 1)
+	if len(page) != 2 || page[0].ID != 2 || page[1].ID != 1 {
+		t.Errorf("page = %+v, want queries 2 and 1 after the newest phrase match", page)
+	}
+	if rest := rankSearchCandidates(candidates[:1], "vat refund", 10, 1); rest != nil {
+		t.Errorf("page past the end = %+v, want none", rest)
+	}
+}
+
+func TestSearchConversationsNoMatches(t *testing.T) {
+	fake := useFakeDB(t, func(query string, args []driver.NamedValue) (*fakeRows, error) {
+		return &fakeRows{columns: []string{"count"}, values: [][]driver.Value{{int64(0)}}}, nil
+	})
+
+	results, total, err := NewSearchService(&config.Config{}).SearchConversations(context.Background(), SearchFilter{Query: "vat"}, "")
+	if err != nil || total != 0 || results == nil || len(results) != 0 {
+		t.Errorf("SearchConversations = %v, %d, %v; want an empty list", results, total, err)
+	}
+	if strings.Contains(fake.log(), "ts_headline") {
+		t.Error("searched for rows after counting none")
+	}
+}
+
+func TestSearchConversationsUnavailableWhileEncrypted(t *testing.T) {
+	cipher, err := crypto.New("k1", strings.Repeat("ab", 32), nil, "hash-key")
+	if err != nil {
+		t.Fatalf("crypto.New: %v", err)
+	}
+	crypto.Enable(cipher)
+	t.Cleanup(func() { crypto.Enable(nil) })
+	fake := useFakeDB(t, nil)
+
+	_, _, err = NewSearchService(&config.Config{}).SearchConversations(context.Background(), SearchFilter{Query: "vat"}, "")
+	if !errors.Is(err, ErrInvalidRequest) || !strings.Contains(err.Error(), "encryption at rest") {
+		t.Errorf("error = %v, want ErrInvalidRequest about encryption at rest", err)
+	}
+	if log := fake.log(); log != "" {
+		t.Errorf("queried the database while encrypted:\n%s", log)
+	}
+}
+
+func TestSearchConversationsValidation(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		filter    SearchFilter
+		auth      bool
+		requester string
+		want      error
+	}{
+		{"empty query", SearchFilter{Query: "   "}, false, "", ErrValidation},
+		{"query too long", SearchFilter{Query: strings.Repeat("a", maxSearchQueryLength+1)}, false, "", ErrValidation},
+		{"to before from", SearchFilter{Query: "vat", From: day, To: day.Add(-time.Hour)}, false, "", ErrValidation},
+		{"anonymous with auth", SearchFilter{Query: "vat"}, true, "", ErrForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeDB(t, nil)
+			_, _, err := NewSearchService(&config.Config{AuthEnabled: tt.auth}).SearchConversations(context.Background(), tt.filter, tt.requester)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	tests := map[string]string{
+		"vat":        "vat",
+		"100%":       `100\%`,
+		"first_name": `first\_name`,
+		`c:\temp`:    `c:\\temp`,
+	}
+	for in, want := range tests {
+		if got := escapeLike(in); got != want {
+			t.Errorf("escapeLike(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestHighlightSnippet(t *testing.T) {
+	got := highlightSnippet("Can I get a VAT refund? <b>urgent</b>", []string{"vat", "refund"})
+	want := "Can I get a <mark>VAT</mark> <mark>refund</mark>? &lt;b&gt;urgent&lt;/b&gt;"
+	if got != want {
+		t.Errorf("highlightSnippet = %q, want %q", got, want)
+	}
+
+	long := strings.Repeat("x ", 200) + "vat" + strings.Repeat(" y", 200)
+	if got := highlightSnippet(long, []string{"vat"}); !strings.HasPrefix(got, "... ") || !strings.HasSuffix(got, " ...") || !strings.Contains(got, "<mark>vat</mark>") {
+		t.Errorf("highlightSnippet on long text = %q, want an elided snippet around the match", got)
+	}
+}
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ai-support-assistant/backend/internal/audit"
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// Segment filter limits, keeping the generated SQL bounded
+const (
+	maxSegmentUserIDs  = 1000
+	maxSegmentPlans    = 20
+	maxSegmentMatchers = 20
+	maxSegmentValueLen = 2048
+)
+
+// Metadata matcher operators
+const (
+	MetadataEquals   = "equals"
+	MetadataPrefix   = "prefix"
+	MetadataContains = "contains"
+)
+
+// segmentMetadataFields is the allow-list of metadata fields a segment can
+// match, mapped to their keys in the stored metadata JSON. Only these keys
+// ever reach the SQL; values are always bound as parameters.
+var segmentMetadataFields = map[string]string{
+	"page_url":       "page_url",
+	"referrer":       "referrer",
+	"locale":         "locale",
+	"client_version": "client_version",
+	"user_agent":     "user_agent",
+}
+
+// SegmentService manages the saved segments analytics can be filtered by
+type SegmentService struct {
+	plans map[string]config.Plan
+}
+
+func NewSegmentService(cfg *config.Config) *SegmentService {
+	// Validated when the config was loaded
+	plans, _ := config.ParsePlans(cfg.Plans)
+
+	return &SegmentService{plans: plans}
+}
+
+// ValidateSegmentFilter checks a segment filter only uses allowed fields and
+// operators and stays within the limits
+func (s *SegmentService) ValidateSegmentFilter(filter models.SegmentFilter) error {
+	if len(filter.UserIDs) > maxSegmentUserIDs {
+		return validationError("a segment may list at most %d user IDs", maxSegmentUserIDs)
+	}
+	for _, userID := range filter.UserIDs {
+		if strings.TrimSpace(userID) == "" || len(userID) > 255 {
+			return validationError("user IDs must be 1 to 255 characters")
+		}
+	}
+
+	if len(filter.Plans) > maxSegmentPlans {
+		return validationError("a segment may list at most %d plans", maxSegmentPlans)
+	}
+	for _, plan := range filter.Plans {
+		if strings.TrimSpace(plan) == "" || len(plan) > 50 {
+			return validationError("plans must be 1 to 50 characters")
+		}
+		if _, ok := s.plans[plan]; !ok && len(s.plans) > 0 {
+			return validationError("plan %q is not in PLANS", plan)
+		}
+	}
+
+	if len(filter.Metadata) > maxSegmentMatchers {
+		return validationError("a segment may have at most %d metadata matchers", maxSegmentMatchers)
+	}
+	for _, matcher := range filter.Metadata {
+		if _, ok := segmentMetadataFields[matcher.Field]; !ok {
+			return validationError("metadata field %q can't be filtered on; use page_url, referrer, locale, client_version or user_agent", matcher.Field)
+		}
+		switch matcher.Op {
+		case MetadataEquals, MetadataPrefix, MetadataContains:
+		default:
+			return validationError("metadata op %q is not supported; use equals, prefix or contains", matcher.Op)
+		}
+		if matcher.Value == "" || len(matcher.Value) > maxSegmentValueLen {
+			return validationError("metadata values must be 1 to %d characters", maxSegmentValueLen)
+		}
+	}
+
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return validationError("from must be before to")
+	}
+	return nil
+}
+
+// validate checks a segment request beyond what binding validates.
+// excludeID is the segment being updated, or 0 when creating.
+func (s *SegmentService) validate(ctx context.Context, req models.AnalyticsSegmentRequest, excludeID uint) error {
+	var count int64
+	query := db.DB.WithContext(ctx).Model(&models.AnalyticsSegment{}).Where("name = ?", strings.TrimSpace(req.Name))
+	if excludeID != 0 {
+		query = query.Where("id <> ?", excludeID)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check segment: %w", err)
+	}
+	if count > 0 {
+		return validationError("segment %q already exists", strings.TrimSpace(req.Name))
+	}
+
+	return s.ValidateSegmentFilter(req.Filter)
+}
+
+// CreateSegment saves a new segment
+func (s *SegmentService) CreateSegment(ctx context.Context, req models.AnalyticsSegmentRequest) (*models.AnalyticsSegment, error) {
+	if err := s.validate(ctx, req, 0); err != nil {
+		return nil, err
+	}
+
+	segment := models.AnalyticsSegment{}
+	applySegmentRequest(&segment, req)
+
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&segment).Error; err != nil {
+			return fmt.Errorf("failed to save segment: %w", err)
+		}
+		return audit.Record(ctx, tx, "segment.create", "segment", strconv.FormatUint(uint64(segment.ID), 10), nil, segment)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &segment, nil
+}
+
+// GetSegments returns all segments by name
+func (s *SegmentService) GetSegments(ctx context.Context) ([]models.AnalyticsSegment, error) {
+	var segments []models.AnalyticsSegment
+
+	if err := db.DB.WithContext(ctx).Order("name ASC").Find(&segments).Error; err != nil {
+		return nil, fmt.Errorf("failed to get segments: %w", err)
+	}
+
+	return segments, nil
+}
+
+// GetSegmentByID returns a segment by ID
+func (s *SegmentService) GetSegmentByID(ctx context.Context, id uint) (*models.AnalyticsSegment, error) {
+	var segment models.AnalyticsSegment
+
+	if err := db.DB.WithContext(ctx).First(&segment, id).Error; err != nil {
+		return nil, notFoundError("segment", err)
+	}
+
+	return &segment, nil
+}
+
+// UpdateSegment updates a segment
+func (s *SegmentService) UpdateSegment(ctx context.Context, id uint, req models.AnalyticsSegmentRequest) (*models.AnalyticsSegment, error) {
+	if err := s.validate(ctx, req, id); err != nil {
+		return nil, err
+	}
+
+	segment, err := s.GetSegmentByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *segment
+	applySegmentRequest(segment, req)
+
+	err = db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(segment).Error; err != nil {
+			return fmt.Errorf("failed to update segment: %w", err)
+		}
+		return audit.Record(ctx, tx, "segment.update", "segment", strconv.FormatUint(uint64(id), 10), before, segment)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return segment, nil
+}
+
+// DeleteSegment deletes a segment
+func (s *SegmentService) DeleteSegment(ctx context.Context, id uint) error {
+	segment, err := s.GetSegmentByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.AnalyticsSegment{}, id).Error; err != nil {
+			return fmt.Errorf("failed to delete segment: %w", err)
+		}
+		return audit.Record(ctx, tx, "segment.delete", "segment", strconv.FormatUint(uint64(id), 10), segment, nil)
+	})
+}
+
+// applySegmentRequest copies a request's fields onto a segment
+func applySegmentRequest(segment *models.AnalyticsSegment, req models.AnalyticsSegmentRequest) {
+	segment.Name = strings.TrimSpace(req.Name)
+	segment.Description = req.Description
+	segment.Filter = req.Filter
+}
+
+// segmentScope restricts a query over chat_queries to a segment's queries;
+// a nil segment leaves it unrestricted. The filter is trusted to have been
+// validated, but fields outside the allow-list are still never used.
+func segmentScope(segment *models.AnalyticsSegment) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		if segment == nil {
+			return tx
+		}
+		filter := segment.Filter
+
+		if len(filter.UserIDs) > 0 {
+			tx = tx.Where("chat_queries.user_id IN ?", filter.UserIDs)
+		}
+		if len(filter.Plans) > 0 {
+			tx = tx.Where("chat_queries.plan IN ?", filter.Plans)
+		}
+		for _, matcher := range filter.Metadata {
+			key, ok := segmentMetadataFields[matcher.Field]
+			if !ok {
+				continue
+			}
+			field := metadataField(key)
+			switch matcher.Op {
+			case MetadataEquals:
+				tx = tx.Where(field+" = ?", matcher.Value)
+			case MetadataPrefix:
+				tx = tx.Where(field+` LIKE ? ESCAPE '\'`, escapeLike(matcher.Value)+"%")
+			case MetadataContains:
+				tx = tx.Where(field+` LIKE ? ESCAPE '\'`, likePattern(matcher.Value))
+			}
+		}
+		if filter.From != nil {
+			tx = tx.Where("chat_queries.created_at >= ?", *filter.From)
+		}
+		if filter.To != nil {
+			tx = tx.Where("chat_queries.created_at < ?", *filter.To)
+		}
+		return tx
+	}
+}
+
+// segmentQueries selects the analytics queries in a segment, or all of them
+// when segment is nil
+func segmentQueries(segment *models.AnalyticsSegment) *gorm.DB {
+	return analyticsQueries().Scopes(segmentScope(segment))
+}
+
+// metadataField returns the SQL expression reading an allow-listed key of a
+// query's metadata
+func metadataField(key string) string {
+	if isPostgres() {
+		return "chat_queries.metadata->>'" + key + "'"
+	}
+	return "json_extract(chat_queries.metadata, '$." + key + "')"
+}
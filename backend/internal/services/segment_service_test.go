@@ -0,0 +1,133 @@
+package services
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/models"
+)
+
+func TestValidateSegmentFilter(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	tests := []struct {
+		name   string
+		filter models.SegmentFilter
+		valid  bool
+	}{
+		{"every filter type", models.SegmentFilter{
+			UserIDs:  []string{"u1", "u2"},
+			Plans:    []string{"enterprise"},
+			Metadata: []models.MetadataMatcher{{Field: "page_url", Op: MetadataPrefix, Value: "https://m.example.com/"}, {Field: "locale", Op: MetadataEquals, Value: "de"}},
+			From:     &from,
+			To:       &to,
+		}, true},
+		{"empty", models.SegmentFilter{}, true},
+		{"too many user IDs", models.SegmentFilter{UserIDs: make([]string, maxSegmentUserIDs+1)}, false},
+		{"blank user ID", models.SegmentFilter{UserIDs: []string{" "}}, false},
+		{"unknown plan", models.SegmentFilter{Plans: []string{"platinum"}}, false},
+		{"field not allowed", models.SegmentFilter{Metadata: []models.MetadataMatcher{{Field: "email", Op: MetadataEquals, Value: "a@example.com"}}}, false},
+		{"sql as field", models.SegmentFilter{Metadata: []models.MetadataMatcher{{Field: "locale') OR 1=1 --", Op: MetadataEquals, Value: "de"}}}, false},
+		{"unknown op", models.SegmentFilter{Metadata: []models.MetadataMatcher{{Field: "locale", Op: "regex", Value: ".*"}}}, false},
+		{"empty value", models.SegmentFilter{Metadata: []models.MetadataMatcher{{Field: "locale", Op: MetadataEquals}}}, false},
+		{"value too long", models.SegmentFilter{Metadata: []models.MetadataMatcher{{Field: "referrer", Op: MetadataContains, Value: strings.Repeat("a", maxSegmentValueLen+1)}}}, false},
+		{"from after to", models.SegmentFilter{From: &to, To: &from}, false},
+	}
+
+	s := NewSegmentService(&config.Config{Plans: []string{"free=50:1000:false", "enterprise=0:0:true"}})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.ValidateSegmentFilter(tt.filter)
+			if tt.valid && err != nil {
+				t.Errorf("ValidateSegmentFilter: %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrValidation) {
+				t.Errorf("error = %v, want ErrValidation", err)
+			}
+		})
+	}
+}
+
+// countSegment counts the queries in segment against a fake database,
+// returning the statement and its arguments
+func countSegment(t *testing.T, segment *models.AnalyticsSegment) (string, []interface{}) {
+	t.Helper()
+	var args []interface{}
+	fake := useFakeDB(t, func(query string, named []driver.NamedValue) (*fakeRows, error) {
+		for _, arg := range named {
+			args = append(args, arg.Value)
+		}
+		return &fakeRows{columns: []string{"count"}, values: [][]driver.Value{{int64(0)}}}, nil
+	})
+
+	var count int64
+	if err := segmentQueries(segment).Count(&count).Error; err != nil {
+		t.Fatalf("Count: %v", err)
+	}
+	return fake.log(), args
+}
+
+func TestSegmentScopeCombinesFilters(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	query, args := countSegment(t, &models.AnalyticsSegment{Filter: models.SegmentFilter{
+		UserIDs: []string{"u1", "u2"},
+		Plans:   []string{"enterprise"},
+		Metadata: []models.MetadataMatcher{
+			{Field: "page_url", Op: MetadataPrefix, Value: "https://m.example.com/100%_off"},
+			{Field: "user_agent", Op: MetadataContains, Value: "iPhone"},
+			{Field: "locale", Op: MetadataEquals, Value: "de"},
+		},
+		From: &from,
+		To:   &to,
+	}})
+
+	for _, want := range []string{
+		"chat_queries.synthetic = ",
+		"chat_queries.user_id IN ($2,$3)",
+		"chat_queries.plan IN ($4)",
+		`chat_queries.metadata->>'page_url' LIKE $5 ESCAPE '\'`,
+		`chat_queries.metadata->>'user_agent' LIKE $6 ESCAPE '\'`,
+		"chat_queries.metadata->>'locale' = $7",
+		"chat_queries.created_at >= $8",
+		"chat_queries.created_at < $9",
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("statement missing %q:\n%s", want, query)
+		}
+	}
+
+	want := []interface{}{false, "u1", "u2", "enterprise", `https://m.example.com/100\%\_off%`, "%iPhone%", "de", from, to}
+	if len(args) != len(want) {
+		t.Fatalf("args = %v, want %v", args, want)
+	}
+	for i := range want {
+		if args[i] != want[i] {
+			t.Errorf("arg %d = %v, want %v", i+1, args[i], want[i])
+		}
+	}
+}
+
+func TestSegmentScopeNeverUsesUnlistedFields(t *testing.T) {
+	// A filter that skipped validation still can't put its field into the SQL
+	query, args := countSegment(t, &models.AnalyticsSegment{Filter: models.SegmentFilter{
+		Metadata: []models.MetadataMatcher{
+			{Field: "locale') OR 1=1 --", Op: MetadataEquals, Value: "de"},
+			{Field: "locale", Op: "regex", Value: ".*"},
+		},
+	}})
+	if strings.Contains(query, "OR 1=1") || strings.Contains(query, "metadata") || len(args) != 1 {
+		t.Errorf("statement used an unlisted field or op:\n%s\nargs %v", query, args)
+	}
+}
+
+func TestSegmentScopeWithoutSegment(t *testing.T) {
+	query, args := countSegment(t, nil)
+	if strings.Contains(query, "user_id") || strings.Contains(query, "metadata") || len(args) != 1 {
+		t.Errorf("nil segment restricted the statement:\n%s\nargs %v", query, args)
+	}
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/audit"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/middleware"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Session claim outcomes
+const (
+	SessionClaimClaimed        = "claimed"
+	SessionClaimAlreadyClaimed = "already_claimed"
+	SessionClaimInvalidToken   = "invalid_token"
+	SessionClaimConflict       = "conflict"
+)
+
+// unowned matches rows not yet linked to a user
+const unowned = "(user_id = '' OR user_id IS NULL)"
+
+// ClaimSession links an anonymous session to the authenticated user: the
+// session and its queries and feedback are stamped with the user's ID, so
+// their history and analytics include it. Claiming requires the claim token
+// handed out with the session's first answer, and claiming a session the
+// user already owns changes nothing. Sessions owned by another user, or with
+// queries from one, are refused with ErrConflict.
+func (s *SessionService) ClaimSession(ctx context.Context, sessionID, userID, token string) (*models.SessionClaim, error) {
+	if userID == "" {
+		return nil, fmt.Errorf("%w: authentication required", ErrForbidden)
+	}
+
+	var session models.Session
+	err := db.DB.WithContext(ctx).Where("session_id = ?", sessionID).First(&session).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	// Unknown sessions are refused like wrong tokens so claims can't be
+	// used to find out which session IDs exist
+	if err != nil || !claimTokenMatches(session.ClaimTokenHash, token) {
+		middleware.RecordSessionClaim(SessionClaimInvalidToken)
+		return nil, fmt.Errorf("%w: invalid claim token", ErrForbidden)
+	}
+
+	if session.UserID == userID {
+		middleware.RecordSessionClaim(SessionClaimAlreadyClaimed)
+		claim := &models.SessionClaim{SessionID: sessionID, UserID: userID}
+		if session.ClaimedAt != nil {
+			claim.ClaimedAt = *session.ClaimedAt
+		}
+		return claim, nil
+	}
+	if session.UserID != "" {
+		middleware.RecordSessionClaim(SessionClaimConflict)
+		return nil, fmt.Errorf("%w: session belongs to another user", ErrConflict)
+	}
+
+	claim := &models.SessionClaim{
+		SessionID: sessionID,
+		UserID:    userID,
+		ClaimedAt: time.Now().UTC(),
+	}
+	err = db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var foreign int64
+		if err := tx.Model(&models.ChatQuery{}).Where("session_id = ? AND user_id <> '' AND user_id <> ?", sessionID, userID).Count(&foreign).Error; err != nil {
+			return fmt.Errorf("failed to check session queries: %w", err)
+		}
+		if foreign > 0 {
+			return fmt.Errorf("%w: session has queries from another user", ErrConflict)
+		}
+
+		// Only an unowned session is updated, so of concurrent claims one wins
+		result := tx.Model(&models.Session{}).Where("id = ? AND "+unowned, session.ID).Updates(map[string]interface{}{
+			"user_id":    userID,
+			"claimed_at": claim.ClaimedAt,
+		})
+		if result.Error != nil {
+			return fmt.Errorf("failed to claim session: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: session was claimed by another request", ErrConflict)
+		}
+
+		result = tx.Model(&models.ChatQuery{}).Where("session_id = ? AND "+unowned, sessionID).Update("user_id", userID)
+		if result.Error != nil {
+			return fmt.Errorf("failed to link session queries: %w", result.Error)
+		}
+		claim.QueriesLinked = result.RowsAffected
+
+		result = tx.Model(&models.Feedback{}).Where("session_id = ? AND "+unowned, sessionID).Update("user_id", userID)
+		if result.Error != nil {
+			return fmt.Errorf("failed to link session feedback: %w", result.Error)
+		}
+		claim.FeedbackLinked = result.RowsAffected
+
+		return audit.Record(ctx, tx, "session.claim", "session", sessionID, nil, claim)
+	})
+	if err != nil {
+		if errors.Is(err, ErrConflict) {
+			middleware.RecordSessionClaim(SessionClaimConflict)
+		}
+		return nil, err
+	}
+
+	middleware.RecordSessionClaim(SessionClaimClaimed)
+	logrus.WithFields(logrus.Fields{
+		"session_id":      sessionID,
+		"user_id":         userID,
+		"queries_linked":  claim.QueriesLinked,
+		"feedback_linked": claim.FeedbackLinked,
+	}).Info("Session claimed")
+	return claim, nil
+}
+
+// issueClaimToken gives an unowned session without a claim token a new one
+// and returns it, or returns "" when the session already has one or isn't
+// stored yet. Only one query ever receives a session's token.
+func issueClaimToken(ctx context.Context, sessionID string) string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		logrus.WithError(err).Warn("Failed to generate session claim token")
+		return ""
+	}
+	token := hex.EncodeToString(b)
+
+	result := db.DB.WithContext(ctx).Model(&models.Session{}).
+		Where("session_id = ? AND (claim_token_hash = '' OR claim_token_hash IS NULL) AND "+unowned, sessionID).
+		Update("claim_token_hash", claimTokenHash(token))
+	if result.Error != nil {
+		logrus.WithError(result.Error).WithField("session_id", sessionID).Warn("Failed to issue session claim token")
+		return ""
+	}
+	if result.RowsAffected == 0 {
+		return ""
+	}
+	return token
+}
+
+// claimTokenHash is how a claim token is stored
+func claimTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// claimTokenMatches reports whether token is the one whose hash is stored;
+// a session without a stored hash can't be claimed
+func claimTokenMatches(storedHash, token string) bool {
+	if storedHash == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(claimTokenHash(token)), []byte(storedHash)) == 1
+}
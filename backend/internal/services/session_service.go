@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/activity"
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Session outcomes
+const (
+	SessionOutcomeOpen      = "open"
+	SessionOutcomeResolved  = "resolved"
+	SessionOutcomeEscalated = "escalated"
+	SessionOutcomeAbandoned = "abandoned"
+)
+
+// closedSessionOutcomes are the outcomes that end a session
+var closedSessionOutcomes = []string{SessionOutcomeResolved, SessionOutcomeEscalated, SessionOutcomeAbandoned}
+
+type SessionService struct {
+	abandonAfter  time.Duration
+	sweepInterval time.Duration
+}
+
+func NewSessionService(cfg *config.Config) *SessionService {
+	return &SessionService{
+		abandonAfter:  time.Duration(cfg.SessionAbandonAfterM) * time.Minute,
+		sweepInterval: time.Duration(cfg.SessionSweepIntervalS) * time.Second,
+	}
+}
+
+// Start periodically marks idle open sessions as abandoned until ctx is cancelled
+func (s *SessionService) Start(ctx context.Context) {
+	if s.abandonAfter <= 0 || s.sweepInterval <= 0 {
+		logrus.Info("Session abandonment sweep disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.sweepInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				count, err := s.abandonIdleSessions(ctx)
+				if err != nil {
+					logrus.WithError(err).Warn("Failed to mark idle sessions abandoned")
+				} else if count > 0 {
+					logrus.WithField("count", count).Info("Marked idle sessions abandoned")
+				}
+			}
+		}
+	}()
+}
+
+// abandonIdleSessions closes open sessions without activity for abandonAfter
+func (s *SessionService) abandonIdleSessions(ctx context.Context) (int64, error) {
+	now := time.Now().UTC()
+	result := db.DB.WithContext(ctx).Model(&models.Session{}).
+		Where("outcome = ? AND last_activity_at < ?", SessionOutcomeOpen, now.Add(-s.abandonAfter)).
+		Updates(map[string]interface{}{
+			"outcome":    SessionOutcomeAbandoned,
+			"outcome_at": now,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// SetOutcome records how a session ended. Setting the outcome a session
+// already has leaves it unchanged. Sessions from before outcomes were tracked
+// are created from their queries.
+func (s *SessionService) SetOutcome(ctx context.Context, sessionID, outcome string) (*models.Session, error) {
+	if !isClosedSessionOutcome(outcome) {
+		return nil, validationError("outcome must be one of resolved, escalated, abandoned")
+	}
+
+	var session models.Session
+	err := db.DB.WithContext(ctx).Where("session_id = ?", sessionID).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = createSessionFromQueries(db.DB.WithContext(ctx), sessionID, &session)
+	} else if err != nil {
+		err = fmt.Errorf("failed to get session: %w", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if session.Outcome == outcome {
+		return &session, nil
+	}
+
+	now := time.Now().UTC()
+	err = db.DB.WithContext(ctx).Model(&session).Updates(map[string]interface{}{
+		"outcome":    outcome,
+		"outcome_at": now,
+	}).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to update session outcome: %w", err)
+	}
+	session.Outcome = outcome
+	session.OutcomeAt = &now
+
+	logrus.WithFields(logrus.Fields{
+		"session_id": sessionID,
+		"outcome":    outcome,
+	}).Info("Session outcome recorded")
+
+	return &session, nil
+}
+
+// createSessionFromQueries creates the session row for a session that only
+// has queries, returning ErrNotFound if it has none
+func createSessionFromQueries(tx *gorm.DB, sessionID string, session *models.Session) error {
+	var lastActivity struct {
+		Count int64
+		Last  *time.Time
+	}
+	err := tx.Model(&models.ChatQuery{}).
+		Select("COUNT(*) AS count, MAX(created_at) AS last").
+		Where("session_id = ?", sessionID).
+		Scan(&lastActivity).Error
+	if err != nil {
+		return fmt.Errorf("failed to load session queries: %w", err)
+	}
+	if lastActivity.Count == 0 || lastActivity.Last == nil {
+		return fmt.Errorf("session %w", ErrNotFound)
+	}
+
+	*session = models.Session{
+		SessionID:      sessionID,
+		Outcome:        SessionOutcomeOpen,
+		LastActivityAt: *lastActivity.Last,
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(session).Error; err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return tx.Where("session_id = ?", sessionID).First(session).Error
+}
+
+// sessionSegment returns the conversation segment a new query in the session
+// belongs to. After idle without activity the query starts the next segment,
+// and reset is true. opening is true when the query has no earlier queries in
+// its segment to draw on. owner is the user who claimed the session, if any.
+// Lookup failures keep the query in the current segment.
+func sessionSegment(ctx context.Context, sessionID string, idle time.Duration) (segment int, reset, opening bool, owner string) {
+	var session models.Session
+	err := db.DB.WithContext(ctx).Select("segment", "last_activity_at", "user_id").Where("session_id = ?", sessionID).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, false, true, ""
+	}
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Warn("Failed to get session segment")
+		return 0, false, false, ""
+	}
+
+	if idle > 0 && time.Since(session.LastActivityAt) > idle {
+		return session.Segment + 1, true, true, session.UserID
+	}
+	return session.Segment, false, false, session.UserID
+}
+
+// recordSessionActivity marks a session active in the given segment,
+// reopening it if it was closed
+func recordSessionActivity(sessionID string, segment int) {
+	now := time.Now().UTC()
+	err := db.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "session_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"last_activity_at": now,
+			"segment":          segment,
+			"outcome":          SessionOutcomeOpen,
+			"outcome_at":       nil,
+			"updated_at":       now,
+		}),
+	}).Create(&models.Session{
+		SessionID:      sessionID,
+		Outcome:        SessionOutcomeOpen,
+		LastActivityAt: now,
+		Segment:        segment,
+	}).Error
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Warn("Failed to record session activity")
+	}
+}
+
+// isClosedSessionOutcome reports whether outcome ends a session
+func isClosedSessionOutcome(outcome string) bool {
+	for _, closed := range closedSessionOutcomes {
+		if outcome == closed {
+			return true
+		}
+	}
+	return false
+}
+
+// GetActiveSessions returns up to limit sessions with activity in the last
+// window, most recently active first, with their query and negative feedback
+// counts and an excerpt of their latest query
+func (s *SessionService) GetActiveSessions(ctx context.Context, window time.Duration, limit int) ([]models.ActiveSession, error) {
+	var sessions []models.Session
+	err := db.DB.WithContext(ctx).
+		Where("last_activity_at >= ?", time.Now().UTC().Add(-window)).
+		Order("last_activity_at DESC").
+		Limit(limit).
+		Find(&sessions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active sessions: %w", err)
+	}
+
+	active := make([]models.ActiveSession, len(sessions))
+	if len(sessions) == 0 {
+		return active, nil
+	}
+	ids := make([]string, len(sessions))
+	for i, session := range sessions {
+		ids[i] = session.SessionID
+	}
+
+	var queryCounts []struct {
+		SessionID string
+		Count     int64
+		LastID    uint
+	}
+	err = db.DB.WithContext(ctx).Model(&models.ChatQuery{}).
+		Select("session_id, COUNT(*) AS count, MAX(id) AS last_id").
+		Where("session_id IN ? AND synthetic = ?", ids, false).
+		Group("session_id").
+		Scan(&queryCounts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count session queries: %w", err)
+	}
+
+	counts := make(map[string]int64, len(queryCounts))
+	lastIDs := make([]uint, 0, len(queryCounts))
+	for _, row := range queryCounts {
+		counts[row.SessionID] = row.Count
+		lastIDs = append(lastIDs, row.LastID)
+	}
+
+	var lastQueries []models.ChatQuery
+	if len(lastIDs) > 0 {
+		err = db.DB.WithContext(ctx).Select("id", "session_id", "user_id", "query").Where("id IN ?", lastIDs).Find(&lastQueries).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to get latest session queries: %w", err)
+		}
+	}
+	latest := make(map[string]models.ChatQuery, len(lastQueries))
+	for _, query := range lastQueries {
+		latest[query.SessionID] = query
+	}
+
+	var feedbackCounts []struct {
+		SessionID string
+		Count     int64
+	}
+	err = db.DB.WithContext(ctx).Model(&models.Feedback{}).
+		Select("session_id, COUNT(*) AS count").
+		Where("session_id IN ? AND score = ?", ids, -1).
+		Group("session_id").
+		Scan(&feedbackCounts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count session feedback: %w", err)
+	}
+	negative := make(map[string]int64, len(feedbackCounts))
+	for _, row := range feedbackCounts {
+		negative[row.SessionID] = row.Count
+	}
+
+	for i, session := range sessions {
+		last := latest[session.SessionID]
+		active[i] = models.ActiveSession{
+			SessionID:             session.SessionID,
+			UserID:                last.UserID,
+			QueryCount:            counts[session.SessionID],
+			LastQueryExcerpt:      activity.Excerpt(last.Query),
+			NegativeFeedbackCount: negative[session.SessionID],
+			Outcome:               session.Outcome,
+			LastActivityAt:        session.LastActivityAt,
+		}
+	}
+	return active, nil
+}
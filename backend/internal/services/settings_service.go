@@ -0,0 +1,449 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/audit"
+	"github.com/ai-support-assistant/backend/internal/cache"
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/logging"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/moderation"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Hot-reloadable setting keys
+const (
+	SettingCacheTTL                 = "cache_ttl"
+	SettingRateLimitDefault         = "rate_limit_default"
+	SettingRateLimitQuery           = "rate_limit_query"
+	SettingRateLimitUpload          = "rate_limit_upload"
+	SettingRateLimitRead            = "rate_limit_read"
+	SettingRateLimitClaim           = "rate_limit_claim"
+	SettingModerationMode           = "moderation_mode"
+	SettingModerationRefusalMessage = "moderation_refusal_message"
+	SettingLogLevel                 = "log_level"
+	SettingFeedbackSamplePercent    = "feedback_sample_percent"
+	SettingCacheAdaptiveTTLPercent  = "cache_adaptive_ttl_percent"
+)
+
+// Setting value types
+const (
+	settingDuration  = "duration"
+	settingRateLimit = "rate_limit"
+	settingEnum      = "enum"
+	settingString    = "string"
+	settingLogLevel  = "log_level"
+	settingPercent   = "percent"
+)
+
+// settingTypes maps each hot-reloadable key to its value type
+var settingTypes = map[string]string{
+	SettingCacheTTL:                 settingDuration,
+	SettingRateLimitDefault:         settingRateLimit,
+	SettingRateLimitQuery:           settingRateLimit,
+	SettingRateLimitUpload:          settingRateLimit,
+	SettingRateLimitRead:            settingRateLimit,
+	SettingRateLimitClaim:           settingRateLimit,
+	SettingModerationMode:           settingEnum,
+	SettingModerationRefusalMessage: settingString,
+	SettingLogLevel:                 settingLogLevel,
+	SettingFeedbackSamplePercent:    settingPercent,
+	SettingCacheAdaptiveTTLPercent:  settingPercent,
+}
+
+// settingsChannel is the Redis pub/sub channel used to invalidate snapshots on all instances
+const settingsChannel = "settings:invalidate"
+
+// settingsSnapshot holds the parsed effective settings
+type settingsSnapshot struct {
+	cacheTTL       time.Duration
+	rateLimits     map[string]config.RateLimit
+	moderationMode string
+	refusalMessage string
+	logLevel       string
+
+	feedbackSamplePercent   int
+	cacheAdaptiveTTLPercent int
+
+	overrides map[string]models.Setting
+}
+
+// SettingsService serves hot-reloadable config values. Values stored in the
+// database override the environment-derived config.
+type SettingsService struct {
+	cfg             *config.Config
+	refreshInterval time.Duration
+
+	mu       sync.RWMutex
+	snapshot *settingsSnapshot
+}
+
+func NewSettingsService(cfg *config.Config) *SettingsService {
+	refresh := time.Duration(cfg.SettingsRefreshS) * time.Second
+	if refresh <= 0 {
+		refresh = 30 * time.Second
+	}
+
+	s := &SettingsService{
+		cfg:             cfg,
+		refreshInterval: refresh,
+	}
+	s.snapshot = s.defaults()
+	return s
+}
+
+// Start loads the settings and keeps them fresh until ctx is cancelled
+func (s *SettingsService) Start(ctx context.Context) {
+	if err := s.Reload(ctx); err != nil {
+		logrus.WithError(err).Warn("Failed to load runtime settings, using environment config")
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.refreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.Reload(ctx); err != nil {
+					logrus.WithError(err).Warn("Failed to refresh runtime settings")
+				}
+			}
+		}
+	}()
+
+	if cache.Client != nil {
+		go s.subscribe(ctx)
+	}
+}
+
+// subscribe reloads the snapshot whenever another instance changes a setting
+func (s *SettingsService) subscribe(ctx context.Context) {
+	pubsub, err := cache.Subscribe(ctx, settingsChannel)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to subscribe to settings changes, relying on periodic refresh")
+		return
+	}
+	defer pubsub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-pubsub.Channel():
+			if !ok {
+				return
+			}
+			if err := s.Reload(ctx); err != nil {
+				logrus.WithError(err).Warn("Failed to reload runtime settings")
+			}
+		}
+	}
+}
+
+// Reload rebuilds the snapshot from the database. Invalid stored values are
+// logged and ignored so a bad row can't take the service down.
+func (s *SettingsService) Reload(ctx context.Context) error {
+	var settings []models.Setting
+	if err := db.DB.WithContext(ctx).Find(&settings).Error; err != nil {
+		return fmt.Errorf("failed to load settings: %w", err)
+	}
+
+	snapshot := s.defaults()
+	for _, setting := range settings {
+		if err := applySetting(snapshot, setting.Key, setting.Value); err != nil {
+			logrus.WithError(err).WithField("key", setting.Key).Warn("Ignoring invalid runtime setting")
+			continue
+		}
+		snapshot.overrides[setting.Key] = setting
+	}
+
+	s.mu.Lock()
+	s.snapshot = snapshot
+	s.mu.Unlock()
+
+	if changed, err := logging.SetLevel(snapshot.logLevel); err != nil {
+		logrus.WithError(err).Warn("Ignoring invalid log level")
+	} else if changed {
+		logrus.WithField("level", snapshot.logLevel).Info("Log level changed")
+	}
+
+	return nil
+}
+
+// defaults builds a snapshot from the environment-derived config
+func (s *SettingsService) defaults() *settingsSnapshot {
+	return &settingsSnapshot{
+		cacheTTL: time.Duration(s.cfg.CacheTTL) * time.Second,
+		rateLimits: map[string]config.RateLimit{
+			SettingRateLimitDefault: {Requests: s.cfg.RateLimitRequests, WindowS: s.cfg.RateLimitWindow},
+			SettingRateLimitQuery:   s.cfg.RateLimitQuery,
+			SettingRateLimitUpload:  s.cfg.RateLimitUpload,
+			SettingRateLimitRead:    s.cfg.RateLimitRead,
+			SettingRateLimitClaim:   s.cfg.RateLimitClaim,
+		},
+		moderationMode: s.cfg.ModerationMode,
+		refusalMessage: s.cfg.ModerationRefusalMessage,
+		logLevel:       s.cfg.LogLevel,
+
+		feedbackSamplePercent:   s.cfg.FeedbackSamplePercent,
+		cacheAdaptiveTTLPercent: s.cfg.CacheAdaptiveTTLPercent,
+
+		overrides: make(map[string]models.Setting),
+	}
+}
+
+// current returns the active snapshot
+func (s *SettingsService) current() *settingsSnapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.snapshot
+}
+
+// CacheTTL returns how long cached responses stay fresh
+func (s *SettingsService) CacheTTL() time.Duration {
+	return s.current().cacheTTL
+}
+
+// RateLimit returns the budget for a rate limit policy by name (default, query, upload, read)
+func (s *SettingsService) RateLimit(name string) config.RateLimit {
+	return s.current().rateLimits["rate_limit_"+name]
+}
+
+// ModerationMode returns the moderation mode
+func (s *SettingsService) ModerationMode() string {
+	return s.current().moderationMode
+}
+
+// LogLevel returns the log level in effect
+func (s *SettingsService) LogLevel() string {
+	return s.current().logLevel
+}
+
+// ModerationRefusalMessage returns the message shown when content is blocked
+func (s *SettingsService) ModerationRefusalMessage() string {
+	return s.current().refusalMessage
+}
+
+// FeedbackSamplePercent returns the share of answers users are asked to rate
+func (s *SettingsService) FeedbackSamplePercent() int {
+	return s.current().feedbackSamplePercent
+}
+
+// CacheAdaptiveTTLPercent returns the share of queries cached for a TTL
+// chosen by their popularity
+func (s *SettingsService) CacheAdaptiveTTLPercent() int {
+	return s.current().cacheAdaptiveTTLPercent
+}
+
+// GetSettings returns the effective value of every hot-reloadable setting
+func (s *SettingsService) GetSettings(ctx context.Context) []models.SettingValue {
+	snapshot := s.current()
+
+	keys := make([]string, 0, len(settingTypes))
+	for key := range settingTypes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values := make([]models.SettingValue, 0, len(keys))
+	for _, key := range keys {
+		value := models.SettingValue{
+			Key:    key,
+			Value:  formatSetting(snapshot, key),
+			Type:   settingTypes[key],
+			Source: "environment",
+		}
+		if override, ok := snapshot.overrides[key]; ok {
+			updatedAt := override.UpdatedAt
+			value.Source = "database"
+			value.UpdatedAt = &updatedAt
+		}
+		values = append(values, value)
+	}
+
+	return values
+}
+
+// UpdateSettings validates and stores overrides, then invalidates the snapshot on all instances
+func (s *SettingsService) UpdateSettings(ctx context.Context, updates map[string]string) ([]models.SettingValue, error) {
+	if len(updates) == 0 {
+		return nil, validationError("no settings provided")
+	}
+
+	// Validate everything before writing anything
+	scratch := s.defaults()
+	for key, value := range updates {
+		if err := applySetting(scratch, key, value); err != nil {
+			return nil, validationError("invalid %s: %v", key, err)
+		}
+	}
+
+	settings := make([]models.Setting, 0, len(updates))
+	for key, value := range updates {
+		settings = append(settings, models.Setting{Key: key, Value: strings.TrimSpace(value)})
+	}
+
+	snapshot := s.current()
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "key"}},
+			DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+		}).Create(&settings).Error
+		if err != nil {
+			return fmt.Errorf("failed to save settings: %w", err)
+		}
+
+		for _, setting := range settings {
+			before := map[string]string{"value": formatSetting(snapshot, setting.Key)}
+			after := map[string]string{"value": setting.Value}
+			if err := audit.Record(ctx, tx, "setting.update", "setting", setting.Key, before, after); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidate(ctx)
+	return s.GetSettings(ctx), nil
+}
+
+// DeleteSetting removes an override so the environment value applies again
+func (s *SettingsService) DeleteSetting(ctx context.Context, key string) error {
+	if _, ok := settingTypes[key]; !ok {
+		return fmt.Errorf("setting %q %w", key, ErrNotFound)
+	}
+
+	snapshot := s.current()
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.Setting{}, "key = ?", key).Error; err != nil {
+			return fmt.Errorf("failed to delete setting: %w", err)
+		}
+
+		before := map[string]string{"value": formatSetting(snapshot, key)}
+		after := map[string]string{"value": formatSetting(s.defaults(), key)}
+		return audit.Record(ctx, tx, "setting.delete", "setting", key, before, after)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.invalidate(ctx)
+	return nil
+}
+
+// invalidate reloads the local snapshot and notifies other instances
+func (s *SettingsService) invalidate(ctx context.Context) {
+	if err := s.Reload(ctx); err != nil {
+		logrus.WithError(err).Warn("Failed to reload runtime settings")
+	}
+
+	if cache.Client != nil {
+		if err := cache.Publish(ctx, settingsChannel, "reload"); err != nil {
+			logrus.WithError(err).Warn("Failed to publish settings change")
+		}
+	}
+}
+
+// applySetting validates a raw value and stores it in the snapshot
+func applySetting(snapshot *settingsSnapshot, key, value string) error {
+	value = strings.TrimSpace(value)
+
+	switch settingTypes[key] {
+	case settingDuration:
+		ttl, err := parseDuration(value)
+		if err != nil {
+			return err
+		}
+		snapshot.cacheTTL = ttl
+	case settingRateLimit:
+		limit, err := config.ParseRateLimit(value, snapshot.rateLimits[key])
+		if err != nil {
+			return err
+		}
+		snapshot.rateLimits[key] = limit
+	case settingEnum:
+		if !moderation.IsValidMode(value) {
+			return fmt.Errorf("%q: must be one of off, log-only, enforce", value)
+		}
+		snapshot.moderationMode = value
+	case settingString:
+		if value == "" {
+			return fmt.Errorf("value must not be empty")
+		}
+		snapshot.refusalMessage = value
+	case settingLogLevel:
+		level, err := logging.ParseLevel(value)
+		if err != nil {
+			return err
+		}
+		snapshot.logLevel = level.String()
+	case settingPercent:
+		percent, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
+		if err != nil || percent < 0 || percent > 100 {
+			return fmt.Errorf("%q: expected a percentage from 0 to 100", value)
+		}
+		if key == SettingCacheAdaptiveTTLPercent {
+			snapshot.cacheAdaptiveTTLPercent = percent
+		} else {
+			snapshot.feedbackSamplePercent = percent
+		}
+	default:
+		return fmt.Errorf("unknown setting")
+	}
+
+	return nil
+}
+
+// formatSetting renders a snapshot value in the same format accepted on update
+func formatSetting(snapshot *settingsSnapshot, key string) string {
+	switch settingTypes[key] {
+	case settingDuration:
+		return snapshot.cacheTTL.String()
+	case settingRateLimit:
+		limit := snapshot.rateLimits[key]
+		return fmt.Sprintf("%d/%d", limit.Requests, limit.WindowS)
+	case settingEnum:
+		return snapshot.moderationMode
+	case settingLogLevel:
+		return snapshot.logLevel
+	case settingPercent:
+		if key == SettingCacheAdaptiveTTLPercent {
+			return strconv.Itoa(snapshot.cacheAdaptiveTTLPercent)
+		}
+		return strconv.Itoa(snapshot.feedbackSamplePercent)
+	default:
+		return snapshot.refusalMessage
+	}
+}
+
+// parseDuration accepts a Go duration ("1h30m") or a number of seconds
+func parseDuration(value string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0, fmt.Errorf("%q: must be positive", value)
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%q: expected a positive duration such as 3600 or 1h", value)
+	}
+	return d, nil
+}
@@ -0,0 +1,614 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ai-support-assistant/backend/internal/audit"
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/lifecycle"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/netguard"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Shadow run statuses
+const (
+	ShadowRunning   = "running"
+	ShadowCompleted = "completed"
+	ShadowCancelled = "cancelled"
+	ShadowFailed    = "failed"
+)
+
+// Reasons a shadow run stopped before replaying its whole sample
+const (
+	shadowStopTime      = "time_budget"
+	shadowStopTokens    = "token_budget"
+	shadowStopCancelled = "cancelled"
+	shadowStopShutdown  = "shutdown"
+)
+
+// shadowCancelPollInterval is how often a run checks whether it was
+// cancelled through another instance
+const shadowCancelPollInterval = 5 * time.Second
+
+// shadowSessionPrefix starts the session each replayed query is asked in.
+// Every query gets its own session, so neither service answers from
+// conversation history, and the sessions never get rows.
+const shadowSessionPrefix = "system:shadow-test"
+
+// ShadowTestService replays historical queries against the current RAG
+// service and a candidate deployment and records how their answers differ.
+// Replays call both services directly: they bypass the cache and aren't
+// recorded as chat queries.
+type ShadowTestService struct {
+	cfg       *config.Config
+	transport RAGTransport
+	limiter   *RAGLimiter
+	lifecycle *lifecycle.Manager
+
+	// cancels stops the runs executing on this instance, by run ID
+	mu      sync.Mutex
+	cancels map[uint]func(reason string)
+
+	// guard refuses candidate URLs on the server's own network, except in
+	// OUTBOUND_ALLOWED_CIDRS
+	guard *netguard.Guard
+}
+
+func NewShadowTestService(cfg *config.Config, transport RAGTransport, limiter *RAGLimiter, lc *lifecycle.Manager) *ShadowTestService {
+	return &ShadowTestService{
+		cfg:       cfg,
+		transport: transport,
+		limiter:   limiter,
+		lifecycle: lc,
+		cancels:   make(map[uint]func(reason string)),
+		guard:     outboundGuard(cfg),
+	}
+}
+
+// StartShadowTest samples historical queries and starts replaying them in
+// the background, returning the new run to poll
+func (s *ShadowTestService) StartShadowTest(ctx context.Context, req models.ShadowTestRequest) (*models.ShadowRun, error) {
+	if s.lifecycle.Stopping() {
+		return nil, fmt.Errorf("%w: server is shutting down", ErrOverloaded)
+	}
+
+	run := models.ShadowRun{
+		CandidateURL: strings.TrimRight(strings.TrimSpace(req.CandidateURL), "/"),
+		SampleSize:   req.SampleSize,
+		After:        req.After,
+		Before:       req.Before,
+		Status:       ShadowRunning,
+		MaxDurationS: req.MaxDurationS,
+		MaxTokens:    req.MaxTokens,
+		CreatedBy:    audit.ActorFrom(ctx).UserID,
+	}
+	if err := s.validate(ctx, &run); err != nil {
+		return nil, err
+	}
+
+	ids, err := sampleShadowQueries(ctx, run)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, validationError("no queries to replay in the given range")
+	}
+	run.Sampled = len(ids)
+
+	err = db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&run).Error; err != nil {
+			return fmt.Errorf("failed to save shadow run: %w", err)
+		}
+		return audit.Record(ctx, tx, "shadow_test.start", "shadow_run", strconv.FormatUint(uint64(run.ID), 10), nil, run)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"shadow_run_id": run.ID,
+		"candidate_url": run.CandidateURL,
+		"sampled":       run.Sampled,
+	}).Info("Shadow test started")
+
+	stop := s.track(run.ID)
+	started := s.lifecycle.Go("shadow_test", logrus.Fields{"shadow_run_id": run.ID}, func(ctx context.Context) {
+		s.run(ctx, run, ids, stop)
+	})
+	if !started {
+		s.untrack(run.ID)
+		s.finish(run.ID, ShadowCancelled, shadowStopShutdown, len(ids), "")
+		run.Status = ShadowCancelled
+		run.StopReason = shadowStopShutdown
+	}
+
+	return &run, nil
+}
+
+// validate checks a new run and fills its budgets from the configured limits
+func (s *ShadowTestService) validate(ctx context.Context, run *models.ShadowRun) error {
+	candidate, err := url.Parse(run.CandidateURL)
+	if err != nil || (candidate.Scheme != "http" && candidate.Scheme != "https") || candidate.Host == "" {
+		return validationError("candidate_url must be an http or https URL")
+	}
+	if run.CandidateURL == strings.TrimRight(s.cfg.RAGServiceURL, "/") {
+		return validationError("candidate_url is the current RAG service")
+	}
+	if err := checkDestination(ctx, s.guard, "candidate_url", run.CandidateURL); err != nil {
+		return err
+	}
+
+	if run.SampleSize > s.cfg.ShadowTestMaxSample {
+		return validationError("sample_size must be at most %d", s.cfg.ShadowTestMaxSample)
+	}
+	if run.After != nil && run.Before != nil && !run.After.Before(*run.Before) {
+		return validationError("after must be earlier than before")
+	}
+
+	switch {
+	case run.MaxDurationS == 0:
+		run.MaxDurationS = s.cfg.ShadowTestMaxDurationS
+	case run.MaxDurationS > s.cfg.ShadowTestMaxDurationS:
+		return validationError("max_duration_s must be at most %d", s.cfg.ShadowTestMaxDurationS)
+	}
+	switch {
+	case run.MaxTokens == 0:
+		run.MaxTokens = s.cfg.ShadowTestMaxTokens
+	case run.MaxTokens > s.cfg.ShadowTestMaxTokens:
+		return validationError("max_tokens must be at most %d", s.cfg.ShadowTestMaxTokens)
+	}
+	return nil
+}
+
+// sampleShadowQueries picks random historical queries answered by the RAG
+// service in the run's range. Synthetic queries, regenerations and canned or
+// moderated answers are left out.
+func sampleShadowQueries(ctx context.Context, run models.ShadowRun) ([]uint, error) {
+	scope := db.DB.WithContext(ctx).Model(&models.ChatQuery{}).
+		Where("synthetic = ? AND parent_query_id IS NULL", false).
+		Where("model NOT IN ?", []string{CannedModel, ModerationModel})
+	if run.After != nil {
+		scope = scope.Where("created_at >= ?", *run.After)
+	}
+	if run.Before != nil {
+		scope = scope.Where("created_at < ?", *run.Before)
+	}
+
+	var ids []uint
+	if err := scope.Order("RANDOM()").Limit(run.SampleSize).Pluck("id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("failed to sample queries: %w", err)
+	}
+	return ids, nil
+}
+
+// GetShadowRun returns a shadow run with a page of its comparisons, oldest
+// first, and the total number of comparisons
+func (s *ShadowTestService) GetShadowRun(ctx context.Context, id uint, limit, offset int) (*models.ShadowRun, []models.ShadowComparison, int64, error) {
+	var run models.ShadowRun
+	if err := db.DB.WithContext(ctx).First(&run, id).Error; err != nil {
+		return nil, nil, 0, notFoundError("shadow run", err)
+	}
+
+	var total int64
+	scope := func() *gorm.DB {
+		return db.DB.WithContext(ctx).Model(&models.ShadowComparison{}).Where("run_id = ?", id)
+	}
+	if err := scope().Count(&total).Error; err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to count shadow comparisons: %w", err)
+	}
+
+	comparisons := []models.ShadowComparison{}
+	if err := scope().Order("id").Limit(limit).Offset(offset).Find(&comparisons).Error; err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to get shadow comparisons: %w", err)
+	}
+
+	return &run, comparisons, total, nil
+}
+
+// CancelShadowTest stops a running shadow test. Comparisons recorded so far
+// are kept. The instance running it notices within
+// shadowCancelPollInterval if it isn't this one.
+func (s *ShadowTestService) CancelShadowTest(ctx context.Context, id uint) (*models.ShadowRun, error) {
+	var run models.ShadowRun
+	err := db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&run, id).Error; err != nil {
+			return notFoundError("shadow run", err)
+		}
+		if run.Status != ShadowRunning {
+			return fmt.Errorf("%w: shadow run %d is %s", ErrConflict, id, run.Status)
+		}
+
+		before := run
+		run.Status = ShadowCancelled
+		run.StopReason = shadowStopCancelled
+		run.CancelledBy = audit.ActorFrom(ctx).UserID
+		err := tx.Model(&run).Updates(map[string]interface{}{
+			"status":       run.Status,
+			"stop_reason":  run.StopReason,
+			"cancelled_by": run.CancelledBy,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to cancel shadow run: %w", err)
+		}
+		return audit.Record(ctx, tx, "shadow_test.cancel", "shadow_run", strconv.FormatUint(uint64(id), 10), before, run)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	stop := s.cancels[id]
+	s.mu.Unlock()
+	if stop != nil {
+		stop(shadowStopCancelled)
+	}
+
+	logrus.WithField("shadow_run_id", id).Info("Shadow test cancelled")
+	return &run, nil
+}
+
+// shadowStop cancels a run's context, remembering the first reason given
+type shadowStop struct {
+	mu     sync.Mutex
+	reason string
+	cancel context.CancelFunc
+}
+
+func (st *shadowStop) stop(reason string) {
+	st.mu.Lock()
+	if st.reason == "" {
+		st.reason = reason
+	}
+	cancel := st.cancel
+	st.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+func (st *shadowStop) stopReason() string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.reason
+}
+
+// track registers a run about to start on this instance so it can be cancelled
+func (s *ShadowTestService) track(id uint) *shadowStop {
+	st := &shadowStop{}
+	s.mu.Lock()
+	s.cancels[id] = st.stop
+	s.mu.Unlock()
+	return st
+}
+
+func (s *ShadowTestService) untrack(id uint) {
+	s.mu.Lock()
+	delete(s.cancels, id)
+	s.mu.Unlock()
+}
+
+// run replays the sampled queries a few at a time until they are done, the
+// run is cancelled or shutdown begins, or a budget is spent
+func (s *ShadowTestService) run(ctx context.Context, run models.ShadowRun, ids []uint, st *shadowStop) {
+	defer s.untrack(run.ID)
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(run.MaxDurationS)*time.Second)
+	defer cancel()
+	st.mu.Lock()
+	st.cancel = cancel
+	if st.reason != "" {
+		// Cancelled before it started
+		cancel()
+	}
+	st.mu.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			logrus.WithField("shadow_run_id", run.ID).Errorf("Shadow test panicked: %v", r)
+			s.finish(run.ID, ShadowFailed, "", 0, fmt.Sprintf("internal error: %v", r))
+		}
+	}()
+
+	candidate := newHTTPRAGTransport(run.CandidateURL)
+	candidate.guard(s.guard)
+	defer candidate.Close()
+
+	watchDone := make(chan struct{})
+	defer close(watchDone)
+	go s.watch(ctx, run.ID, st, watchDone)
+
+	var (
+		tokensMu sync.Mutex
+		tokens   int
+		skipMu   sync.Mutex
+		skipped  int
+	)
+	jobs := make(chan uint)
+	var wg sync.WaitGroup
+	for i := 0; i < s.cfg.ShadowTestConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range jobs {
+				if ctx.Err() != nil {
+					skipMu.Lock()
+					skipped++
+					skipMu.Unlock()
+					continue
+				}
+
+				used, ok := s.compare(ctx, run.ID, id, candidate)
+				if !ok {
+					skipMu.Lock()
+					skipped++
+					skipMu.Unlock()
+				}
+
+				tokensMu.Lock()
+				tokens += used
+				overBudget := tokens >= run.MaxTokens
+				tokensMu.Unlock()
+				if overBudget {
+					st.stop(shadowStopTokens)
+				}
+			}
+		}()
+	}
+	for _, id := range ids {
+		jobs <- id
+	}
+	close(jobs)
+	wg.Wait()
+
+	reason := st.stopReason()
+	if reason == "" && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		reason = shadowStopTime
+	}
+	status := ShadowCompleted
+	if reason == shadowStopCancelled || reason == shadowStopShutdown {
+		status = ShadowCancelled
+	}
+	s.finish(run.ID, status, reason, skipped, "")
+}
+
+// watch stops a run when it is cancelled through another instance or
+// shutdown begins, until done is closed
+func (s *ShadowTestService) watch(ctx context.Context, id uint, st *shadowStop, done <-chan struct{}) {
+	ticker := time.NewTicker(shadowCancelPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-s.lifecycle.Context().Done():
+			st.stop(shadowStopShutdown)
+			return
+		case <-ticker.C:
+		}
+
+		var status string
+		err := db.DB.WithContext(ctx).Model(&models.ShadowRun{}).Where("id = ?", id).Pluck("status", &status).Error
+		if err != nil {
+			continue
+		}
+		if status != ShadowRunning {
+			st.stop(shadowStopCancelled)
+			return
+		}
+	}
+}
+
+// compare asks both services one historical query at the same time and
+// records how their answers differ. It returns the tokens both used, and
+// false if the query was skipped because the run stopped or it was deleted.
+func (s *ShadowTestService) compare(ctx context.Context, runID, queryID uint, candidate RAGTransport) (int, bool) {
+	var query models.ChatQuery
+	if err := db.DB.WithContext(ctx).First(&query, queryID).Error; err != nil {
+		if ctx.Err() == nil {
+			logrus.WithError(err).WithField("query_id", queryID).Warn("Failed to load query for shadow test")
+		}
+		return 0, false
+	}
+
+	req := RAGQueryRequest{
+		Query:     query.Query,
+		SessionID: fmt.Sprintf("%s:%d:%d", shadowSessionPrefix, runID, queryID),
+		TopK:      defaultTopK,
+		Language:  query.Language,
+		Audience:  models.AudienceCustomer,
+	}
+
+	var current, proposed shadowAnswer
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		// The current service also serves live traffic, so replays wait
+		// their turn for it like any other query
+		release, err := s.limiter.Acquire(ctx)
+		if err != nil {
+			current.err = err
+			return
+		}
+		defer release()
+		current = askShadow(ctx, s.transport, req)
+	}()
+	go func() {
+		defer wg.Done()
+		proposed = askShadow(ctx, candidate, req)
+	}()
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return current.tokens() + proposed.tokens(), false
+	}
+
+	comparison := compareShadowAnswers(current, proposed)
+	comparison.RunID = runID
+	comparison.QueryID = queryID
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&comparison).Error; err != nil {
+			return err
+		}
+		counters := map[string]interface{}{
+			"tokens_used": gorm.Expr("tokens_used + ?", current.tokens()+proposed.tokens()),
+		}
+		if current.err != nil {
+			counters["current_errors"] = gorm.Expr("current_errors + 1")
+		}
+		if proposed.err != nil {
+			counters["candidate_errors"] = gorm.Expr("candidate_errors + 1")
+		}
+		if current.err == nil && proposed.err == nil {
+			counters["compared"] = gorm.Expr("compared + 1")
+		}
+		return tx.Model(&models.ShadowRun{}).Where("id = ?", runID).Updates(counters).Error
+	})
+	if err != nil {
+		logrus.WithError(err).WithField("shadow_run_id", runID).Error("Failed to save shadow comparison")
+	}
+
+	return current.tokens() + proposed.tokens(), true
+}
+
+// shadowAnswer is one service's answer to a replayed query
+type shadowAnswer struct {
+	resp    *RAGQueryResponse
+	latency time.Duration
+	err     error
+}
+
+func (a shadowAnswer) tokens() int {
+	if a.resp == nil {
+		return 0
+	}
+	return a.resp.TokensUsed
+}
+
+// askShadow asks a service a replayed query, timing the call
+func askShadow(ctx context.Context, transport RAGTransport, req RAGQueryRequest) shadowAnswer {
+	start := time.Now()
+	resp, err := transport.Query(ctx, req)
+	return shadowAnswer{resp: resp, latency: time.Since(start), err: err}
+}
+
+// compareShadowAnswers summarizes how the candidate's answer differs from
+// the current service's
+func compareShadowAnswers(current, candidate shadowAnswer) models.ShadowComparison {
+	comparison := models.ShadowComparison{
+		CurrentLatencyMs:   int(current.latency.Milliseconds()),
+		CandidateLatencyMs: int(candidate.latency.Milliseconds()),
+		CurrentTokens:      current.tokens(),
+		CandidateTokens:    candidate.tokens(),
+	}
+	if current.err != nil {
+		comparison.CurrentError = current.err.Error()
+	} else {
+		comparison.CurrentLength = utf8.RuneCountInString(current.resp.Response)
+		comparison.CurrentModel = current.resp.Model
+	}
+	if candidate.err != nil {
+		comparison.CandidateError = candidate.err.Error()
+	} else {
+		comparison.CandidateLength = utf8.RuneCountInString(candidate.resp.Response)
+		comparison.CandidateModel = candidate.resp.Model
+	}
+	if current.err != nil || candidate.err != nil {
+		return comparison
+	}
+
+	latencyDelta := comparison.CandidateLatencyMs - comparison.CurrentLatencyMs
+	tokenDelta := comparison.CandidateTokens - comparison.CurrentTokens
+	lengthDelta := comparison.CandidateLength - comparison.CurrentLength
+	similarity := answerSimilarity(current.resp.Response, candidate.resp.Response)
+	comparison.LatencyDeltaMs = &latencyDelta
+	comparison.TokenDelta = &tokenDelta
+	comparison.LengthDelta = &lengthDelta
+	comparison.Similarity = &similarity
+	return comparison
+}
+
+// answerSimilarity is the overlap of the distinct words of two answers, from
+// 0 for no words in common to 1 for the same words, ignoring case and order
+func answerSimilarity(a, b string) float64 {
+	wordsA, wordsB := answerWords(a), answerWords(b)
+	if len(wordsA) == 0 && len(wordsB) == 0 {
+		return 1
+	}
+
+	shared := 0
+	for word := range wordsA {
+		if wordsB[word] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(wordsA)+len(wordsB)-shared)
+}
+
+// answerWords returns the distinct lowercase words of an answer
+func answerWords(text string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		words[word] = true
+	}
+	return words
+}
+
+// finish records how a run ended and its averages over compared queries. A
+// run already cancelled keeps its status.
+func (s *ShadowTestService) finish(id uint, status, reason string, skipped int, errMsg string) {
+	var averages struct {
+		LatencyDelta *float64
+		TokenDelta   *float64
+		LengthDelta  *float64
+		Similarity   *float64
+	}
+	err := db.DB.Model(&models.ShadowComparison{}).
+		Where("run_id = ? AND similarity IS NOT NULL", id).
+		Select("AVG(latency_delta_ms) AS latency_delta, AVG(token_delta) AS token_delta, AVG(length_delta) AS length_delta, AVG(similarity) AS similarity").
+		Scan(&averages).Error
+	if err != nil {
+		logrus.WithError(err).WithField("shadow_run_id", id).Error("Failed to average shadow comparisons")
+	}
+
+	now := time.Now().UTC()
+	updates := map[string]interface{}{
+		"status":               gorm.Expr("CASE WHEN status = ? THEN ? ELSE status END", ShadowRunning, status),
+		"stop_reason":          gorm.Expr("CASE WHEN status = ? THEN ? ELSE stop_reason END", ShadowRunning, reason),
+		"skipped":              skipped,
+		"avg_latency_delta_ms": averages.LatencyDelta,
+		"avg_token_delta":      averages.TokenDelta,
+		"avg_length_delta":     averages.LengthDelta,
+		"avg_similarity":       averages.Similarity,
+		"error":                errMsg,
+		"finished_at":          now,
+	}
+	if err := db.DB.Model(&models.ShadowRun{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		logrus.WithError(err).WithField("shadow_run_id", id).Error("Failed to finish shadow run")
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"shadow_run_id":  id,
+		"status":         status,
+		"stop_reason":    reason,
+		"skipped":        skipped,
+		"avg_similarity": averages.Similarity,
+	}).Info("Shadow test finished")
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/cache"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/middleware"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+)
+
+// suggestionHistoryLimit bounds how many past session queries are compared
+// against suggestions when filtering duplicates
+const suggestionHistoryLimit = 50
+
+// RAGSuggestionsRequest asks the RAG service for follow-up questions to a conversation
+type RAGSuggestionsRequest struct {
+	SessionID      string           `json:"session_id"`
+	History        []RAGHistoryTurn `json:"history"`
+	MaxSuggestions int              `json:"max_suggestions"`
+}
+
+// RAGHistoryTurn is one question and answer of a session, oldest first
+type RAGHistoryTurn struct {
+	Query    string `json:"query"`
+	Response string `json:"response"`
+}
+
+// RAGSuggestionsResponse is the RAG service's list of follow-up questions
+type RAGSuggestionsResponse struct {
+	Suggestions []string `json:"suggestions"`
+}
+
+// GetSessionSuggestions returns follow-up questions generated from the
+// recent history of the session's current conversation segment. An empty list is returned while the RAG service
+// is degraded or doesn't support suggestions.
+func (s *QueryService) GetSessionSuggestions(ctx context.Context, sessionID string) ([]string, error) {
+	var history []models.ChatQuery
+	err := db.DB.WithContext(ctx).
+		Select("id", "query", "response", "segment").
+		Where("session_id = ?", sessionID).
+		Order("id DESC").
+		Limit(s.cfg.SuggestionsHistory).
+		Find(&history).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session history: %w", err)
+	}
+	if len(history) == 0 {
+		return nil, fmt.Errorf("session %w", ErrNotFound)
+	}
+	for i := range history {
+		if history[i].Segment != history[0].Segment {
+			history = history[:i]
+			break
+		}
+	}
+	if s.cfg.SuggestionsMax <= 0 {
+		return []string{}, nil
+	}
+
+	// A new query in the session changes the history, and so the key
+	cacheKey := cache.GenerateCacheKey("suggestions", sessionID, strconv.FormatUint(uint64(history[0].ID), 10))
+
+	var suggestions []string
+	err = cache.Get(ctx, cacheKey, &suggestions)
+	if err == nil {
+		middleware.RecordCacheHit("suggestions")
+		return s.filterSuggestions(ctx, sessionID, "", suggestions), nil
+	} else if err != redis.Nil {
+		logrus.WithError(err).Warn("Failed to get suggestions from cache")
+	}
+	middleware.RecordCacheMiss("suggestions")
+
+	if !s.health.RAGAvailable() {
+		return []string{}, nil
+	}
+
+	req := RAGSuggestionsRequest{SessionID: sessionID, MaxSuggestions: s.cfg.SuggestionsMax}
+	for i := len(history) - 1; i >= 0; i-- {
+		req.History = append(req.History, RAGHistoryTurn{Query: history[i].Query, Response: history[i].Response})
+	}
+
+	suggestions, err = s.callRAGSuggestions(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cache.Set(ctx, cacheKey, suggestions, s.settings.CacheTTL()); err != nil {
+		logrus.WithError(err).Warn("Failed to cache suggestions")
+	}
+
+	return s.filterSuggestions(ctx, sessionID, "", suggestions), nil
+}
+
+// callRAGSuggestions requests follow-up questions from the RAG service. A RAG
+// service without the endpoint yields no suggestions rather than an error.
+func (s *QueryService) callRAGSuggestions(ctx context.Context, req RAGSuggestionsRequest) ([]string, error) {
+	url := fmt.Sprintf("%s/rag/suggestions", s.cfg.RAGServiceURL)
+
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+	}
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, transportError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return []string{}, nil
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &RAGError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var ragResp RAGSuggestionsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ragResp); err != nil {
+		return nil, fmt.Errorf("%w: failed to decode response: %v", ErrRAGUnavailable, err)
+	}
+
+	if ragResp.Suggestions == nil {
+		return []string{}, nil
+	}
+	return ragResp.Suggestions, nil
+}
+
+// filterSuggestions drops suggestions that repeat, after normalization, the
+// current query, a question already asked in the session or another
+// suggestion, and caps the rest at the configured count
+func (s *QueryService) filterSuggestions(ctx context.Context, sessionID, query string, suggestions []string) []string {
+	if len(suggestions) == 0 || s.cfg.SuggestionsMax <= 0 {
+		return nil
+	}
+
+	// Query through the model so encrypted queries are decrypted
+	var asked []models.ChatQuery
+	err := db.DB.WithContext(ctx).
+		Select("id", "query").
+		Where("session_id = ?", sessionID).
+		Order("id DESC").
+		Limit(suggestionHistoryLimit).
+		Find(&asked).Error
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to load session history for suggestions")
+	}
+
+	seen := make(map[string]bool, len(asked)+len(suggestions)+1)
+	seen[normalizeQuery(query)] = true
+	for _, q := range asked {
+		seen[normalizeQuery(q.Query)] = true
+	}
+
+	filtered := make([]string, 0, s.cfg.SuggestionsMax)
+	for _, suggestion := range suggestions {
+		normalized := normalizeQuery(suggestion)
+		if normalized == "" || seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+
+		filtered = append(filtered, suggestion)
+		if len(filtered) == s.cfg.SuggestionsMax {
+			break
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/middleware"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// upstreamWindowBuckets is how many buckets the rate limit window is split into
+const upstreamWindowBuckets = 30
+
+// upstreamWindow counts RAG call attempts and 429s over a sliding window of
+// fixed buckets, so the rate reflects recent traffic only
+type upstreamWindow struct {
+	window time.Duration
+	width  time.Duration
+
+	mu      sync.Mutex
+	buckets [upstreamWindowBuckets]upstreamBucket
+}
+
+type upstreamBucket struct {
+	period  int64
+	calls   int64
+	limited int64
+}
+
+func newUpstreamWindow(window time.Duration) *upstreamWindow {
+	width := window / upstreamWindowBuckets
+	if width <= 0 {
+		width = time.Second
+	}
+	return &upstreamWindow{window: window, width: width}
+}
+
+// record counts one attempt, rate limited or not
+func (w *upstreamWindow) record(now time.Time, limited bool) {
+	period := now.UnixNano() / int64(w.width)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	bucket := &w.buckets[period%upstreamWindowBuckets]
+	if bucket.period != period {
+		*bucket = upstreamBucket{period: period}
+	}
+	bucket.calls++
+	if limited {
+		bucket.limited++
+	}
+}
+
+// status sums the buckets still inside the window
+func (w *upstreamWindow) status(now time.Time) *models.RAGRateLimitStatus {
+	oldest := now.UnixNano()/int64(w.width) - upstreamWindowBuckets + 1
+
+	w.mu.Lock()
+	var calls, limited int64
+	for _, bucket := range w.buckets {
+		if bucket.period >= oldest {
+			calls += bucket.calls
+			limited += bucket.limited
+		}
+	}
+	w.mu.Unlock()
+
+	status := &models.RAGRateLimitStatus{
+		WindowSeconds: int(w.window.Seconds()),
+		Calls:         calls,
+		RateLimited:   limited,
+		PerMinute:     float64(limited) / w.window.Minutes(),
+	}
+	if calls > 0 {
+		status.Ratio = float64(limited) / float64(calls)
+	}
+	return status
+}
+
+// queryWithRateLimitRetry calls a RAG endpoint, retrying 429 responses after
+// their Retry-After (or a doubling default delay) for as long as the request
+// deadline allows. Once retries run out it returns an UpstreamRateLimitError.
+// A stream that already sent tokens isn't retried.
+func (s *QueryService) queryWithRateLimitRetry(ctx context.Context, transport RAGTransport, req RAGQueryRequest) (*RAGQueryResponse, error) {
+	delay := time.Duration(s.cfg.RAGRateLimitRetryDelayMs) * time.Millisecond
+
+	for attempt := 0; ; attempt++ {
+		resp, err := queryTransport(ctx, transport, req)
+
+		var ragErr *RAGError
+		limited := errors.As(err, &ragErr) && ragErr.StatusCode == http.StatusTooManyRequests
+		s.health.recordUpstreamCall(limited)
+		if !limited || streamStarted(ctx) {
+			return resp, err
+		}
+
+		wait := ragErr.RetryAfter
+		if wait <= 0 {
+			wait = delay << attempt
+		}
+
+		logger := logrus.WithFields(logrus.Fields{
+			"attempt":     attempt + 1,
+			"retry_after": wait,
+		})
+
+		deadline, hasDeadline := ctx.Deadline()
+		if attempt >= s.cfg.RAGRateLimitMaxRetries || (hasDeadline && time.Until(deadline) <= wait) {
+			middleware.RecordRAGUpstreamRateLimited("rejected")
+			logger.Warn("RAG service rate limited, giving up")
+			return nil, &UpstreamRateLimitError{RetryAfter: wait}
+		}
+
+		middleware.RecordRAGUpstreamRateLimited("retried")
+		logger.Info("RAG service rate limited, retrying")
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
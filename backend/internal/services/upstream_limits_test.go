@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/config"
+)
+
+// rateLimitedRAGServer answers 429 with retryAfter to the first limited
+// queries, then 200, counting the queries it receives
+func rateLimitedRAGServer(t *testing.T, limited int32, retryAfter string) (*httptest.Server, *int32) {
+	t.Helper()
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= limited {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			http.Error(w, `{"detail":"provider rate limit"}`, http.StatusTooManyRequests)
+			return
+		}
+		json.NewEncoder(w).Encode(RAGQueryResponse{Response: "Use the reset link."})
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func newRateLimitTestService(maxRetries int) *QueryService {
+	cfg := &config.Config{RAGRateLimitMaxRetries: maxRetries, RAGRateLimitRetryDelayMs: 5, RAGRateLimitWindowS: 60}
+	return &QueryService{cfg: cfg, health: NewHealthService(cfg, nil, nil, nil, nil)}
+}
+
+func TestRateLimitRetrySucceeds(t *testing.T) {
+	server, calls := rateLimitedRAGServer(t, 2, "")
+	s := newRateLimitTestService(3)
+
+	resp, err := s.queryWithRateLimitRetry(context.Background(), newHTTPRAGTransport(server.URL), RAGQueryRequest{Query: "reset password"})
+	if err != nil {
+		t.Fatalf("queryWithRateLimitRetry: %v", err)
+	}
+	if resp.Response != "Use the reset link." || *calls != 3 {
+		t.Errorf("response = %q after %d calls, want the answer after 3", resp.Response, *calls)
+	}
+
+	status := s.health.upstream.status(time.Now())
+	if status.Calls != 3 || status.RateLimited != 2 {
+		t.Errorf("window = %d calls, %d limited; want 3, 2", status.Calls, status.RateLimited)
+	}
+}
+
+func TestRateLimitRetriesRunOut(t *testing.T) {
+	server, calls := rateLimitedRAGServer(t, 10, "")
+	s := newRateLimitTestService(2)
+
+	_, err := s.queryWithRateLimitRetry(context.Background(), newHTTPRAGTransport(server.URL), RAGQueryRequest{Query: "reset password"})
+	var limited *UpstreamRateLimitError
+	if !errors.As(err, &limited) || !errors.Is(err, ErrUpstreamRateLimited) {
+		t.Fatalf("error = %v, want an UpstreamRateLimitError", err)
+	}
+	if *calls != 3 {
+		t.Errorf("RAG service called %d times, want the first call and 2 retries", *calls)
+	}
+}
+
+func TestRateLimitRetryAfterBeyondDeadline(t *testing.T) {
+	server, calls := rateLimitedRAGServer(t, 1, "5")
+	s := newRateLimitTestService(3)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	start := time.Now()
+	_, err := s.queryWithRateLimitRetry(ctx, newHTTPRAGTransport(server.URL), RAGQueryRequest{Query: "reset password"})
+	var limited *UpstreamRateLimitError
+	if !errors.As(err, &limited) || limited.RetryAfter != 5*time.Second {
+		t.Fatalf("error = %v, want an UpstreamRateLimitError with the server's Retry-After", err)
+	}
+	if *calls != 1 || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("waited %v over %d calls, want to give up at once", time.Since(start), *calls)
+	}
+}
+
+func TestRateLimitOtherErrorsNotRetried(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	_, err := newRateLimitTestService(3).queryWithRateLimitRetry(context.Background(), newHTTPRAGTransport(server.URL), RAGQueryRequest{Query: "hi"})
+	var ragErr *RAGError
+	if !errors.As(err, &ragErr) || ragErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("error = %v, want the RAG service's 500", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"3", 3 * time.Second},
+		{"-1", 0},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestUpstreamWindowExpiresOldBuckets(t *testing.T) {
+	w := newUpstreamWindow(time.Minute)
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	w.record(start, true)
+	w.record(start.Add(30*time.Second), false)
+
+	if status := w.status(start.Add(40 * time.Second)); status.Calls != 2 || status.RateLimited != 1 || status.Ratio != 0.5 {
+		t.Errorf("status within window = %+v", status)
+	}
+	if status := w.status(start.Add(70 * time.Second)); status.Calls != 1 || status.RateLimited != 0 {
+		t.Errorf("status after the first call left the window = %+v", status)
+	}
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/middleware"
+	"github.com/ai-support-assistant/backend/internal/ragclient"
+	"github.com/sirupsen/logrus"
+)
+
+// Grounding verification modes
+const (
+	VerificationOff     = "off"
+	VerificationLog     = "log"
+	VerificationEnforce = "enforce"
+)
+
+// grounding is the outcome of verifying an answer against its context
+type grounding struct {
+	score       *float64 // nil when the answer wasn't verified or the verifier failed
+	unsupported int
+
+	// lowConfidence is set in enforce mode when the answer must be replaced
+	lowConfidence bool
+}
+
+// verifyGrounding asks the RAG service how well an answer is supported by
+// the context it was generated from. In log mode the verdict is only
+// recorded, and a failing verifier never holds up the answer. In enforce
+// mode an answer scoring below VERIFICATION_MIN_SCORE is low confidence, as
+// is one the verifier failed on. Answers without context aren't verified.
+func (s *QueryService) verifyGrounding(ctx context.Context, query string, resp *RAGQueryResponse) grounding {
+	mode := s.cfg.VerificationMode
+	if mode == VerificationOff || len(resp.Context) == 0 || resp.Response == "" {
+		return grounding{}
+	}
+	enforce := mode == VerificationEnforce
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(s.cfg.VerificationTimeoutMs)*time.Millisecond)
+	defer cancel()
+
+	result, err := s.verifier.VerifyGrounding(ctx, ragclient.GroundingCheck{
+		Query:   query,
+		Answer:  resp.Response,
+		Context: resp.Context,
+	})
+	if err != nil {
+		middleware.RecordVerification("error", 0)
+		logrus.WithError(err).WithField("mode", mode).Warn("Failed to verify answer grounding")
+		return grounding{lowConfidence: enforce}
+	}
+
+	score := result.Score
+	low := score < s.cfg.VerificationMinScore
+	if low {
+		middleware.RecordVerification("low_score", score)
+		logrus.WithFields(logrus.Fields{
+			"grounding_score":   score,
+			"unsupported_count": len(result.Unsupported),
+			"mode":              mode,
+		}).Warn("Answer poorly grounded in its context")
+	} else {
+		middleware.RecordVerification("grounded", score)
+	}
+
+	return grounding{
+		score:         &score,
+		unsupported:   len(result.Unsupported),
+		lowConfidence: low && enforce,
+	}
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ai-support-assistant/backend/internal/config"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/netguard"
+	"github.com/ai-support-assistant/backend/internal/webhook"
+)
+
+type WebhookService struct {
+	// guard refuses subscriber URLs on the server's own network
+	guard *netguard.Guard
+}
+
+func NewWebhookService(cfg *config.Config) *WebhookService {
+	return &WebhookService{guard: outboundGuard(cfg)}
+}
+
+// ValidateSubscription checks that all requested event types are supported
+func ValidateSubscription(req models.WebhookSubscriptionRequest) error {
+	for _, event := range req.EventTypes {
+		if !webhook.IsValidEvent(event) {
+			return validationError("unsupported event type %q", event)
+		}
+	}
+	return nil
+}
+
+// CreateSubscription registers a new webhook subscription
+func (s *WebhookService) CreateSubscription(ctx context.Context, req models.WebhookSubscriptionRequest) (*models.WebhookSubscription, error) {
+	if err := ValidateSubscription(req); err != nil {
+		return nil, err
+	}
+	if err := checkDestination(ctx, s.guard, "url", req.URL); err != nil {
+		return nil, err
+	}
+
+	sub := models.WebhookSubscription{
+		URL:        req.URL,
+		Secret:     req.Secret,
+		EventTypes: strings.Join(req.EventTypes, ","),
+		Enabled:    true,
+	}
+	if req.Enabled != nil {
+		sub.Enabled = *req.Enabled
+	}
+
+	if err := db.DB.Create(&sub).Error; err != nil {
+		return nil, fmt.Errorf("failed to save webhook subscription: %w", err)
+	}
+
+	return &sub, nil
+}
+
+// GetSubscriptions returns all webhook subscriptions
+func (s *WebhookService) GetSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	var subs []models.WebhookSubscription
+
+	if err := db.DB.Order("created_at DESC").Find(&subs).Error; err != nil {
+		return nil, fmt.Errorf("failed to get webhook subscriptions: %w", err)
+	}
+
+	return subs, nil
+}
+
+// GetSubscriptionByID returns a webhook subscription by ID
+func (s *WebhookService) GetSubscriptionByID(ctx context.Context, id uint) (*models.WebhookSubscription, error) {
+	var sub models.WebhookSubscription
+
+	if err := db.DB.First(&sub, id).Error; err != nil {
+		return nil, notFoundError("webhook subscription", err)
+	}
+
+	return &sub, nil
+}
+
+// UpdateSubscription updates a webhook subscription. An empty secret keeps the existing one.
+func (s *WebhookService) UpdateSubscription(ctx context.Context, id uint, req models.WebhookSubscriptionRequest) (*models.WebhookSubscription, error) {
+	if err := ValidateSubscription(req); err != nil {
+		return nil, err
+	}
+	if err := checkDestination(ctx, s.guard, "url", req.URL); err != nil {
+		return nil, err
+	}
+
+	sub, err := s.GetSubscriptionByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	sub.URL = req.URL
+	sub.EventTypes = strings.Join(req.EventTypes, ",")
+	if req.Secret != "" {
+		sub.Secret = req.Secret
+	}
+	if req.Enabled != nil {
+		sub.Enabled = *req.Enabled
+	}
+
+	if err := db.DB.Save(sub).Error; err != nil {
+		return nil, fmt.Errorf("failed to update webhook subscription: %w", err)
+	}
+
+	return sub, nil
+}
+
+// DeleteSubscription removes a webhook subscription and its delivery history
+func (s *WebhookService) DeleteSubscription(ctx context.Context, id uint) error {
+	if _, err := s.GetSubscriptionByID(ctx, id); err != nil {
+		return err
+	}
+
+	if err := db.DB.Where("subscription_id = ?", id).Delete(&models.WebhookDelivery{}).Error; err != nil {
+		return fmt.Errorf("failed to delete webhook deliveries: %w", err)
+	}
+
+	if err := db.DB.Delete(&models.WebhookSubscription{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+
+	return nil
+}
+
+// GetDeliveries returns recent delivery attempts for a subscription
+func (s *WebhookService) GetDeliveries(ctx context.Context, subscriptionID uint, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+
+	if err := db.DB.Where("subscription_id = ?", subscriptionID).Order("created_at DESC").Limit(limit).Find(&deliveries).Error; err != nil {
+		return nil, fmt.Errorf("failed to get webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,296 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/cache"
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultWidgetOrigin is the origin of the config served to unknown origins
+const DefaultWidgetOrigin = "default"
+
+// widgetCacheTTL bounds how long a resolved widget config is cached
+const widgetCacheTTL = 5 * time.Minute
+
+// themeColorPattern matches a hex color such as #2563eb
+var themeColorPattern = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// builtinWidgetConfig is served when no default config has been stored
+var builtinWidgetConfig = models.PublicWidgetConfig{
+	Origin:             DefaultWidgetOrigin,
+	WelcomeMessage:     "Hi! How can I help you today?",
+	ThemeColor:         "#2563eb",
+	SuggestedQuestions: []string{},
+	RateLimitTier:      "standard",
+}
+
+// resolvedWidget is the cached result of resolving an origin
+type resolvedWidget struct {
+	Config    models.PublicWidgetConfig `json:"config"`
+	Allowed   bool                      `json:"allowed"` // an enabled config exists for the exact origin
+	PersonaID *uint                     `json:"persona_id,omitempty"`
+}
+
+type WidgetService struct{}
+
+func NewWidgetService() *WidgetService {
+	return &WidgetService{}
+}
+
+// ValidateWidgetConfig checks a widget config request
+func ValidateWidgetConfig(req models.WidgetConfigRequest) error {
+	origin := NormalizeOrigin(req.Origin)
+	if origin != DefaultWidgetOrigin && !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+		return validationError("origin must be %q or start with http:// or https://", DefaultWidgetOrigin)
+	}
+	if req.ThemeColor != "" && !themeColorPattern.MatchString(req.ThemeColor) {
+		return validationError("theme_color must be a hex color such as #2563eb")
+	}
+	return nil
+}
+
+// NormalizeOrigin lowercases an origin and strips any trailing slash
+func NormalizeOrigin(origin string) string {
+	return strings.TrimRight(strings.ToLower(strings.TrimSpace(origin)), "/")
+}
+
+// GetPublicConfig resolves the widget config for an origin, falling back to the default config
+func (s *WidgetService) GetPublicConfig(ctx context.Context, origin string) models.PublicWidgetConfig {
+	return s.resolve(ctx, origin).Config
+}
+
+// IsOriginAllowed returns true if the origin has an enabled widget config
+func (s *WidgetService) IsOriginAllowed(ctx context.Context, origin string) bool {
+	return s.resolve(ctx, origin).Allowed
+}
+
+// PersonaID returns the persona of the config resolved for an origin, if any
+func (s *WidgetService) PersonaID(ctx context.Context, origin string) *uint {
+	if origin == "" {
+		origin = DefaultWidgetOrigin
+	}
+	return s.resolve(ctx, origin).PersonaID
+}
+
+// resolve looks up an origin's config, using Redis as a read-through cache
+func (s *WidgetService) resolve(ctx context.Context, origin string) resolvedWidget {
+	origin = NormalizeOrigin(origin)
+	cacheKey := "widget:config:" + origin
+
+	var resolved resolvedWidget
+	if err := cache.Get(ctx, cacheKey, &resolved); err == nil {
+		return resolved
+	}
+
+	resolved = resolvedWidget{Config: builtinWidgetConfig}
+
+	var configs []models.WidgetConfig
+	err := db.DB.Where("origin IN ? AND enabled = ?", []string{origin, DefaultWidgetOrigin}, true).Find(&configs).Error
+	if err != nil {
+		// Don't cache lookup failures
+		logrus.WithError(err).Warn("Failed to load widget config")
+		return resolved
+	}
+
+	for _, config := range configs {
+		if config.Origin == origin && origin != DefaultWidgetOrigin {
+			resolved = resolvedWidget{Config: toPublicWidgetConfig(config), Allowed: true, PersonaID: config.PersonaID}
+			break
+		}
+		resolved.Config = toPublicWidgetConfig(config)
+		resolved.PersonaID = config.PersonaID
+	}
+
+	if err := cache.Set(ctx, cacheKey, resolved, widgetCacheTTL); err != nil {
+		logrus.WithError(err).Debug("Failed to cache widget config")
+	}
+
+	return resolved
+}
+
+// CreateWidgetConfig saves a new widget config
+func (s *WidgetService) CreateWidgetConfig(ctx context.Context, req models.WidgetConfigRequest) (*models.WidgetConfig, error) {
+	if err := ValidateWidgetConfig(req); err != nil {
+		return nil, err
+	}
+	if err := validateWidgetPersona(ctx, req); err != nil {
+		return nil, err
+	}
+
+	var existing int64
+	db.DB.Model(&models.WidgetConfig{}).Where("origin = ?", NormalizeOrigin(req.Origin)).Count(&existing)
+	if existing > 0 {
+		return nil, validationError("a widget config for origin %q already exists", NormalizeOrigin(req.Origin))
+	}
+
+	config := models.WidgetConfig{Enabled: true, RateLimitTier: "standard"}
+	applyWidgetConfigRequest(&config, req)
+
+	if err := db.DB.Create(&config).Error; err != nil {
+		return nil, fmt.Errorf("failed to save widget config: %w", err)
+	}
+
+	s.invalidate(ctx)
+	return &config, nil
+}
+
+// GetWidgetConfigs returns all widget configs
+func (s *WidgetService) GetWidgetConfigs(ctx context.Context) ([]models.WidgetConfig, error) {
+	var configs []models.WidgetConfig
+
+	if err := db.DB.Order("origin ASC").Find(&configs).Error; err != nil {
+		return nil, fmt.Errorf("failed to get widget configs: %w", err)
+	}
+
+	return configs, nil
+}
+
+// GetWidgetConfigByID returns a widget config by ID
+func (s *WidgetService) GetWidgetConfigByID(ctx context.Context, id uint) (*models.WidgetConfig, error) {
+	var config models.WidgetConfig
+
+	if err := db.DB.First(&config, id).Error; err != nil {
+		return nil, notFoundError("widget config", err)
+	}
+
+	return &config, nil
+}
+
+// UpdateWidgetConfig updates a widget config
+func (s *WidgetService) UpdateWidgetConfig(ctx context.Context, id uint, req models.WidgetConfigRequest) (*models.WidgetConfig, error) {
+	if err := ValidateWidgetConfig(req); err != nil {
+		return nil, err
+	}
+	if err := validateWidgetPersona(ctx, req); err != nil {
+		return nil, err
+	}
+
+	config, err := s.GetWidgetConfigByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing int64
+	db.DB.Model(&models.WidgetConfig{}).Where("origin = ? AND id <> ?", NormalizeOrigin(req.Origin), id).Count(&existing)
+	if existing > 0 {
+		return nil, validationError("a widget config for origin %q already exists", NormalizeOrigin(req.Origin))
+	}
+
+	applyWidgetConfigRequest(config, req)
+
+	if err := db.DB.Save(config).Error; err != nil {
+		return nil, fmt.Errorf("failed to update widget config: %w", err)
+	}
+
+	s.invalidate(ctx)
+	return config, nil
+}
+
+// DeleteWidgetConfig deletes a widget config
+func (s *WidgetService) DeleteWidgetConfig(ctx context.Context, id uint) error {
+	if _, err := s.GetWidgetConfigByID(ctx, id); err != nil {
+		return err
+	}
+
+	if err := db.DB.Delete(&models.WidgetConfig{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete widget config: %w", err)
+	}
+
+	s.invalidate(ctx)
+	return nil
+}
+
+// validateWidgetPersona checks the persona a widget config request names exists
+func validateWidgetPersona(ctx context.Context, req models.WidgetConfigRequest) error {
+	if req.PersonaID == nil || *req.PersonaID == 0 {
+		return nil
+	}
+
+	var count int64
+	if err := db.DB.WithContext(ctx).Model(&models.Persona{}).Where("id = ?", *req.PersonaID).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check persona: %w", err)
+	}
+	if count == 0 {
+		return validationError("persona %d does not exist", *req.PersonaID)
+	}
+	return nil
+}
+
+// invalidate drops every cached resolution, since a change to the default
+// config affects all origins that fall back to it
+func (s *WidgetService) invalidate(ctx context.Context) {
+	if cache.Client == nil {
+		return
+	}
+
+	keys, err := cache.ScanKeys(ctx, "widget:config:*")
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to list cached widget configs")
+		return
+	}
+	for _, key := range keys {
+		if err := cache.Delete(ctx, key); err != nil {
+			logrus.WithError(err).WithField("key", key).Warn("Failed to invalidate cached widget config")
+		}
+	}
+}
+
+// applyWidgetConfigRequest copies request fields onto a widget config
+func applyWidgetConfigRequest(config *models.WidgetConfig, req models.WidgetConfigRequest) {
+	config.Origin = NormalizeOrigin(req.Origin)
+	config.WelcomeMessage = req.WelcomeMessage
+	config.ThemeColor = req.ThemeColor
+
+	questions := req.SuggestedQuestions
+	if questions == nil {
+		questions = []string{}
+	}
+	data, _ := json.Marshal(questions)
+	config.SuggestedQuestions = string(data)
+
+	if req.RateLimitTier != "" {
+		config.RateLimitTier = req.RateLimitTier
+	}
+	if req.PersonaID != nil {
+		config.PersonaID = nil
+		if id := *req.PersonaID; id != 0 {
+			config.PersonaID = &id
+		}
+	}
+	if req.Enabled != nil {
+		config.Enabled = *req.Enabled
+	}
+}
+
+// toPublicWidgetConfig converts a stored config into the public representation
+func toPublicWidgetConfig(config models.WidgetConfig) models.PublicWidgetConfig {
+	public := models.PublicWidgetConfig{
+		Origin:             config.Origin,
+		WelcomeMessage:     config.WelcomeMessage,
+		ThemeColor:         config.ThemeColor,
+		SuggestedQuestions: []string{},
+		RateLimitTier:      config.RateLimitTier,
+	}
+
+	if config.SuggestedQuestions != "" {
+		if err := json.Unmarshal([]byte(config.SuggestedQuestions), &public.SuggestedQuestions); err != nil {
+			logrus.WithError(err).WithField("widget_config_id", config.ID).Warn("Invalid suggested questions in widget config")
+		}
+	}
+	if public.WelcomeMessage == "" {
+		public.WelcomeMessage = builtinWidgetConfig.WelcomeMessage
+	}
+	if public.ThemeColor == "" {
+		public.ThemeColor = builtinWidgetConfig.ThemeColor
+	}
+
+	return public
+}
@@ -0,0 +1,54 @@
+package tokens
+
+import (
+	"math"
+	"unicode"
+)
+
+// charsPerToken is how many characters of a Latin-script word BPE tokenizers
+// such as cl100k typically merge into one token
+const charsPerToken = 4.0
+
+// Estimate approximates how many tokens a BPE tokenizer splits text into,
+// without a vocabulary: each word costs a token per charsPerToken characters,
+// punctuation and symbols a token each, and characters of scripts written
+// without spaces, such as Chinese or Japanese, a token each. It errs high on
+// code and low on rare words; per-model calibration factors correct the bias.
+func Estimate(text string) int {
+	total := 0.0
+	word := 0
+
+	flush := func() {
+		if word > 0 {
+			total += math.Ceil(float64(word) / charsPerToken)
+			word = 0
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+			flush()
+		case unicode.Is(unicode.Han, r), unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r), unicode.Is(unicode.Hangul, r), unicode.Is(unicode.Thai, r):
+			flush()
+			total++
+		case unicode.IsLetter(r), unicode.IsDigit(r), unicode.IsMark(r):
+			word++
+		default:
+			flush()
+			total++
+		}
+	}
+	flush()
+
+	return int(total)
+}
+
+// Calibrated scales an estimate by a model's calibration factor, rounding up;
+// a factor of 0 leaves it unchanged
+func Calibrated(estimate int, factor float64) int {
+	if factor <= 0 {
+		return estimate
+	}
+	return int(math.Ceil(float64(estimate) * factor))
+}
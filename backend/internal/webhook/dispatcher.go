@@ -0,0 +1,382 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ai-support-assistant/backend/internal/db"
+	"github.com/ai-support-assistant/backend/internal/deadletter"
+	"github.com/ai-support-assistant/backend/internal/lifecycle"
+	"github.com/ai-support-assistant/backend/internal/models"
+	"github.com/ai-support-assistant/backend/internal/netguard"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Event types that webhooks can subscribe to
+const (
+	EventQueryCompleted    = "query.completed"
+	EventFeedbackCreated   = "feedback.created"
+	EventDocumentCompleted = "document.completed"
+	EventDocumentFailed    = "document.failed"
+)
+
+// EventTypes lists all supported event types
+var EventTypes = []string{
+	EventQueryCompleted,
+	EventFeedbackCreated,
+	EventDocumentCompleted,
+	EventDocumentFailed,
+}
+
+// IsValidEvent returns true if the event type is supported
+func IsValidEvent(eventType string) bool {
+	for _, e := range EventTypes {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Payload is the JSON envelope POSTed to subscribers
+type Payload struct {
+	Event     string      `json:"event"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// job is either an event to fan out (subscription nil) or a single delivery attempt
+type job struct {
+	event        string
+	body         []byte
+	subscription *models.WebhookSubscription
+	deliveryID   uint
+	attempt      int
+}
+
+// Dispatcher delivers events to webhook subscribers using a bounded queue and worker pool
+type Dispatcher struct {
+	queue       chan job
+	workers     int
+	maxAttempts int
+	baseBackoff time.Duration
+	client      *http.Client
+	lifecycle   *lifecycle.Manager
+}
+
+// NewDispatcher creates a dispatcher; call Start to launch the workers.
+// Deliveries go through guard, which refuses subscribers on the server's own
+// network even if their URL has started resolving there since registration.
+func NewDispatcher(workers, queueSize, maxAttempts int, guard *netguard.Guard) *Dispatcher {
+	if workers <= 0 {
+		workers = 1
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+
+	return &Dispatcher{
+		queue:       make(chan job, queueSize),
+		workers:     workers,
+		maxAttempts: maxAttempts,
+		baseBackoff: 2 * time.Second,
+		client:      guard.Client(10 * time.Second),
+	}
+}
+
+// Start launches the worker goroutines. Workers finish their current job and
+// exit when shutdown begins; anything still queued is logged as abandoned.
+func (d *Dispatcher) Start(lc *lifecycle.Manager) {
+	d.lifecycle = lc
+	for i := 0; i < d.workers; i++ {
+		lc.Go("webhook_worker", logrus.Fields{"worker": i}, d.worker)
+	}
+	logrus.WithField("workers", d.workers).Info("Webhook dispatcher started")
+}
+
+// Dispatch queues an event for delivery without blocking.
+// Events are dropped when the queue is full so callers are never delayed.
+func (d *Dispatcher) Dispatch(event string, data interface{}) {
+	if d == nil {
+		return
+	}
+
+	body, err := json.Marshal(Payload{
+		Event:     event,
+		Timestamp: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		logrus.WithError(err).WithField("event", event).Error("Failed to marshal webhook payload")
+		return
+	}
+
+	if !d.enqueue(job{event: event, body: body}) {
+		logrus.WithField("event", event).Warn("Webhook queue full, dropping event")
+	}
+}
+
+// QueueDepth returns the number of queued jobs and the queue capacity
+func (d *Dispatcher) QueueDepth() (int, int) {
+	return len(d.queue), cap(d.queue)
+}
+
+// enqueue adds a job to the queue without blocking
+func (d *Dispatcher) enqueue(j job) bool {
+	select {
+	case d.queue <- j:
+		return true
+	default:
+		return false
+	}
+}
+
+// worker processes jobs from the queue until shutdown begins
+func (d *Dispatcher) worker(ctx context.Context) {
+	stop := d.lifecycle.Context().Done()
+	for {
+		select {
+		case <-stop:
+			d.abandonQueued()
+			return
+		case j := <-d.queue:
+			if j.subscription == nil {
+				d.fanOut(ctx, j)
+			} else {
+				d.deliver(ctx, j)
+			}
+		}
+	}
+}
+
+// abandonQueued empties the queue at shutdown, logging each job so deliveries
+// left pending can be reconciled later
+func (d *Dispatcher) abandonQueued() {
+	for {
+		select {
+		case j := <-d.queue:
+			logrus.WithFields(logrus.Fields{
+				"event":       j.event,
+				"delivery_id": j.deliveryID,
+				"attempt":     j.attempt,
+			}).Warn("Abandoned queued webhook at shutdown")
+		default:
+			return
+		}
+	}
+}
+
+// fanOut creates a delivery for every enabled subscription to the event
+func (d *Dispatcher) fanOut(ctx context.Context, j job) {
+	var subscriptions []models.WebhookSubscription
+	if err := db.DB.Where("enabled = ?", true).Find(&subscriptions).Error; err != nil {
+		logrus.WithError(err).Error("Failed to load webhook subscriptions")
+		return
+	}
+
+	for i := range subscriptions {
+		sub := subscriptions[i]
+		if !subscribesTo(sub, j.event) {
+			continue
+		}
+
+		delivery := models.WebhookDelivery{
+			SubscriptionID: sub.ID,
+			EventType:      j.event,
+			Payload:        string(j.body),
+			Status:         "pending",
+		}
+		if err := db.DB.Create(&delivery).Error; err != nil {
+			logrus.WithError(err).Error("Failed to record webhook delivery")
+			continue
+		}
+
+		d.deliver(ctx, job{
+			event:        j.event,
+			body:         j.body,
+			subscription: &sub,
+			deliveryID:   delivery.ID,
+			attempt:      1,
+		})
+	}
+}
+
+// deliver performs one delivery attempt and schedules a retry on failure
+func (d *Dispatcher) deliver(ctx context.Context, j job) {
+	statusCode, err := d.post(ctx, j)
+
+	updates := map[string]interface{}{
+		"attempts":      j.attempt,
+		"response_code": statusCode,
+	}
+
+	if err == nil {
+		updates["status"] = "success"
+		updates["error"] = ""
+		db.DB.Model(&models.WebhookDelivery{}).Where("id = ?", j.deliveryID).Updates(updates)
+		return
+	}
+
+	updates["error"] = err.Error()
+	logger := logrus.WithError(err).WithFields(logrus.Fields{
+		"subscription_id": j.subscription.ID,
+		"delivery_id":     j.deliveryID,
+		"attempt":         j.attempt,
+	})
+
+	if errors.Is(err, netguard.ErrBlockedDestination) {
+		// Retrying can't help, and the delivery can't be replayed either
+		updates["status"] = "failed"
+		db.DB.Model(&models.WebhookDelivery{}).Where("id = ?", j.deliveryID).Updates(updates)
+		logger.Warn("Webhook subscriber address is not allowed, giving up")
+		return
+	}
+
+	if j.attempt >= d.maxAttempts {
+		updates["status"] = "failed"
+		db.DB.Model(&models.WebhookDelivery{}).Where("id = ?", j.deliveryID).Updates(updates)
+		logger.Warn("Webhook delivery failed, giving up")
+		deadletter.Record(ctx, deadletter.OperationWebhookDelivery, deliveryPayload(j), j.attempt, err)
+		return
+	}
+
+	db.DB.Model(&models.WebhookDelivery{}).Where("id = ?", j.deliveryID).Updates(updates)
+	logger.Debug("Webhook delivery failed, scheduling retry")
+
+	// Exponential backoff; the retry re-enters the queue so workers never sleep
+	backoff := d.baseBackoff * time.Duration(1<<uint(j.attempt-1))
+	next := j
+	next.attempt++
+	time.AfterFunc(backoff, func() {
+		if d.lifecycle.Stopping() {
+			logger.Warn("Shutting down, webhook retry abandoned")
+			return
+		}
+		if !d.enqueue(next) {
+			db.DB.Model(&models.WebhookDelivery{}).Where("id = ?", next.deliveryID).Updates(map[string]interface{}{
+				"status": "failed",
+				"error":  "webhook queue full, retry dropped",
+			})
+			deadletter.Record(context.Background(), deadletter.OperationWebhookDelivery, deliveryPayload(j), j.attempt, errors.New("webhook queue full, retry dropped"))
+		}
+	})
+}
+
+// replayPayload is the dead-letter payload of a failed delivery; the event
+// body is replayed from the delivery record
+type replayPayload struct {
+	DeliveryID     uint   `json:"delivery_id"`
+	SubscriptionID uint   `json:"subscription_id"`
+	Event          string `json:"event"`
+}
+
+func deliveryPayload(j job) replayPayload {
+	return replayPayload{DeliveryID: j.deliveryID, SubscriptionID: j.subscription.ID, Event: j.event}
+}
+
+// Replay re-enqueues a dead-lettered delivery to its subscription, starting
+// over at the first attempt
+func (d *Dispatcher) Replay(ctx context.Context, payload json.RawMessage) error {
+	var p replayPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return fmt.Errorf("%w: invalid payload: %v", deadletter.ErrNotReplayable, err)
+	}
+
+	var delivery models.WebhookDelivery
+	if err := db.DB.WithContext(ctx).First(&delivery, p.DeliveryID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: webhook delivery %d no longer exists", deadletter.ErrNotReplayable, p.DeliveryID)
+		}
+		return fmt.Errorf("failed to get webhook delivery: %w", err)
+	}
+
+	var sub models.WebhookSubscription
+	if err := db.DB.WithContext(ctx).First(&sub, delivery.SubscriptionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: webhook subscription %d no longer exists", deadletter.ErrNotReplayable, delivery.SubscriptionID)
+		}
+		return fmt.Errorf("failed to get webhook subscription: %w", err)
+	}
+	if !sub.Enabled {
+		return fmt.Errorf("%w: webhook subscription %d is disabled", deadletter.ErrNotReplayable, sub.ID)
+	}
+
+	err := db.DB.WithContext(ctx).Model(&delivery).Updates(map[string]interface{}{
+		"status":   "pending",
+		"attempts": 0,
+		"error":    "",
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to reset webhook delivery: %w", err)
+	}
+
+	if !d.enqueue(job{
+		event:        delivery.EventType,
+		body:         []byte(delivery.Payload),
+		subscription: &sub,
+		deliveryID:   delivery.ID,
+		attempt:      1,
+	}) {
+		return errors.New("webhook queue is full")
+	}
+	return nil
+}
+
+// post sends the signed payload to the subscriber
+func (d *Dispatcher) post(ctx context.Context, j job) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", j.subscription.URL, bytes.NewReader(j.body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", j.event)
+	req.Header.Set("X-Webhook-Delivery", fmt.Sprintf("%d", j.deliveryID))
+	if j.subscription.Secret != "" {
+		req.Header.Set("X-Signature", Sign(j.body, j.subscription.Secret))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("subscriber returned status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Sign returns the hex-encoded HMAC-SHA256 of body using secret
+func Sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// subscribesTo returns true if the subscription includes the event type
+func subscribesTo(sub models.WebhookSubscription, event string) bool {
+	for _, e := range strings.Split(sub.EventTypes, ",") {
+		if strings.TrimSpace(e) == event {
+			return true
+		}
+	}
+	return false
+}
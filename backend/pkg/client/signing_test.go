@@ -0,0 +1,58 @@
+package client
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+const queryBody = `{"query":"Where is my order?","session_id":"s1"}`
+
+func TestSignature(t *testing.T) {
+	// Vectors computed independently of this package
+	tests := []struct {
+		name   string
+		secret string
+		method string
+		path   string
+		body   string
+		want   string
+	}{
+		{"post with body", "partner-secret", http.MethodPost, "/api/query", queryBody,
+			"c9e4849bbd98b8712f22b2c2ba0fd6c889d4a90c9d1d22ff8249f2a104ab08c9"},
+		{"get with query string", "partner-secret", http.MethodGet, "/api/history?session_id=s1&limit=10", "",
+			"5c5d1bc3b88eed0232130297f9ab45a98155d1364e0bbe9431396e93c6abf639"},
+		{"other secret", "another-secret", http.MethodPost, "/api/query", queryBody,
+			"24382d9efb08ae924c4f704527530efa5d93f520317303a1817aede9bcaaf5a5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Signature(tt.secret, 1700000000, tt.method, tt.path, []byte(tt.body)); got != tt.want {
+				t.Errorf("Signature = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignRequest(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://support.example.com/api/history?session_id=s1&limit=10", nil)
+	SignRequest(req, "partner", "partner-secret", nil, time.Unix(1700000000, 0))
+
+	if got := req.Header.Get(HeaderKeyID); got != "partner" {
+		t.Errorf("%s = %q, want partner", HeaderKeyID, got)
+	}
+	if got := req.Header.Get(HeaderTimestamp); got != "1700000000" {
+		t.Errorf("%s = %q, want 1700000000", HeaderTimestamp, got)
+	}
+	if got := req.Header.Get(HeaderSignature); got != "5c5d1bc3b88eed0232130297f9ab45a98155d1364e0bbe9431396e93c6abf639" {
+		t.Errorf("%s = %q, want the signature over the path and query string", HeaderSignature, got)
+	}
+
+	// The body is signed byte for byte
+	post, _ := http.NewRequest(http.MethodPost, "https://support.example.com/api/query", strings.NewReader(queryBody))
+	SignRequest(post, "partner", "partner-secret", []byte(queryBody+" "), time.Unix(1700000000, 0))
+	if post.Header.Get(HeaderSignature) == "c9e4849bbd98b8712f22b2c2ba0fd6c889d4a90c9d1d22ff8249f2a104ab08c9" {
+		t.Error("signature ignored a change to the body")
+	}
+}
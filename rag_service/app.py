@@ -65,6 +65,23 @@ class RetrainRequest(BaseModel):
     model_name: Optional[str] = None
 
 
+class RetrievalChunk(BaseModel):
+    id: str
+    text: str
+
+
+class RetrievalFeedbackRequest(BaseModel):
+    query_id: int
+    query: str
+    chunks: List[RetrievalChunk]
+    comment: Optional[str] = None
+
+
+class RetrievalDebugRequest(BaseModel):
+    query: str
+    chunks: List[RetrievalChunk]
+
+
 class HealthResponse(BaseModel):
     status: str
     timestamp: datetime
@@ -202,6 +219,39 @@ async def retrain_model(request: RetrainRequest):
         raise HTTPException(status_code=500, detail=f"Failed to retrain model: {str(e)}")
 
 
+@app.post("/rag/retrieval-feedback")
+async def retrieval_feedback(request: RetrievalFeedbackRequest):
+    """
+    Receive chunks that produced a negatively rated answer
+    Logged for down-weighting or re-chunking
+    """
+    logger.info(
+        f"Bad retrieval reported for query {request.query_id}: "
+        f"{[chunk.id for chunk in request.chunks]}"
+    )
+
+    return {
+        "status": "accepted",
+        "chunk_count": len(request.chunks)
+    }
+
+
+@app.post("/rag/debug")
+async def debug_retrieval(request: RetrievalDebugRequest):
+    """
+    Describe how a past query's context was retrieved
+    Used by the orchestrator's query debug bundles
+    """
+    try:
+        return query_engine.debug_retrieval(
+            query=request.query,
+            chunks=[chunk.dict() for chunk in request.chunks]
+        )
+    except Exception as e:
+        logger.error(f"Failed to debug retrieval: {e}")
+        raise HTTPException(status_code=500, detail=f"Failed to debug retrieval: {str(e)}")
+
+
 @app.get("/rag/stats")
 async def get_stats():
     """Get RAG service statistics"""
@@ -240,6 +240,57 @@ Helpful Answer:"""
             logger.error(f"Error processing query: {e}")
             raise
     
+    def debug_retrieval(self, query: str, chunks: List[Dict], search_k: int = 20) -> Dict:
+        """
+        Re-run retrieval for a past query and describe the chunks it was answered from
+
+        Args:
+            query: The question as asked
+            chunks: Chunks the answer was generated from, as {"id", "text"}
+            search_k: Number of documents to search when matching the chunks
+
+        Returns:
+            Dictionary with each found chunk's score, source and metadata, and
+            details of the search
+        """
+        search_k = max(search_k, len(chunks))
+        results = self.vector_store.similarity_search_with_score(query, k=search_k)
+
+        by_text = {}
+        for rank, (doc, score) in enumerate(results, start=1):
+            by_text.setdefault(doc.page_content, (rank, doc, score))
+
+        found = []
+        for chunk in chunks:
+            match = by_text.get(chunk["text"])
+            if match is None:
+                continue
+            rank, doc, score = match
+            metadata = dict(doc.metadata or {})
+            metadata["rank"] = rank
+            found.append({
+                "id": chunk["id"],
+                "doc_id": metadata.get("doc_id", ""),
+                "source": metadata.get("source", ""),
+                "score": float(score),
+                "metadata": metadata
+            })
+
+        return {
+            "chunks": found,
+            "details": {
+                "vector_db": settings.vector_db,
+                "collection": settings.qdrant_collection_name,
+                "embedding_provider": settings.embedding_provider,
+                "search_k": search_k,
+                "retrieved": len(results),
+                "matched": len(found),
+                # Chunks no longer retrieved for the query were re-ingested,
+                # deleted or outranked since it was answered
+                "unmatched": len(chunks) - len(found)
+            }
+        }
+    
     def _estimate_tokens(self, query: str, response: str, context: List[str]) -> int:
         """Estimate token usage using a simple character division to avoid external network calls."""
         try: